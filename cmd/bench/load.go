@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"math/rand"
+	"sync"
+	"time"
+
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// pacingInterval is how often the load generator releases the submissions that are due
+const pacingInterval = time.Millisecond
+
+// Window is the measured part of a run
+type Window struct {
+	From time.Time
+	To   time.Time
+}
+
+// Duration returns the length of the window
+func (w Window) Duration() time.Duration {
+	return w.To.Sub(w.From)
+}
+
+// Contains reports whether t is within the window
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.To)
+}
+
+// submission is the outcome of a single flash_submitTransaction call
+type submission struct {
+	sent    time.Time
+	latency time.Duration
+	id      string // Empty if the call failed
+}
+
+// loadGenerator sends transactions at a fixed total rate from a pool of
+// clients. Submissions that fall due while every client is busy are counted
+// as missed rather than queued, so an overloaded server shows as a lower
+// achieved rate instead of a growing backlog in the generator.
+type loadGenerator struct {
+	clients     []*gethrpc.Client
+	rate        int
+	payloadSize int
+	maxPriority int
+
+	mu          sync.Mutex
+	submissions []submission
+	missed      []time.Time
+}
+
+// newLoadGenerator connects the clients of a run to the session
+func newLoadGenerator(session *Session, config *SweepConfig, params RunParams) (*loadGenerator, error) {
+	g := &loadGenerator{
+		rate:        params.Rate,
+		payloadSize: params.PayloadSize,
+		maxPriority: config.MaxPriority,
+	}
+	for i := 0; i < config.Clients; i++ {
+		client, err := dial(session.URL, session.AuthToken)
+		if err != nil {
+			g.close()
+			return nil, err
+		}
+		g.clients = append(g.clients, client)
+	}
+	return g, nil
+}
+
+// close disconnects the clients
+func (g *loadGenerator) close() {
+	for _, client := range g.clients {
+		client.Close()
+	}
+}
+
+// Run sends the load for the warm-up and the measured duration, or until ctx
+// is cancelled, and returns the measured window
+func (g *loadGenerator) Run(ctx context.Context, warmup, duration time.Duration) Window {
+	defer g.close()
+
+	start := time.Now()
+	window := Window{From: start.Add(warmup), To: start.Add(warmup + duration)}
+
+	due := make(chan struct{}, len(g.clients))
+	var wg sync.WaitGroup
+	for i, client := range g.clients {
+		wg.Add(1)
+		go func(client *gethrpc.Client, seed int64) {
+			defer wg.Done()
+			g.submitLoop(client, rand.New(rand.NewSource(seed)), due)
+		}(client, start.UnixNano()+int64(i))
+	}
+
+	ticker := time.NewTicker(pacingInterval)
+	defer ticker.Stop()
+
+	released := 0
+pacing:
+	for {
+		select {
+		case now := <-ticker.C:
+			if !now.Before(window.To) {
+				break pacing
+			}
+			target := int(now.Sub(start).Seconds() * float64(g.rate))
+			for ; released < target; released++ {
+				select {
+				case due <- struct{}{}:
+				default:
+					g.mu.Lock()
+					g.missed = append(g.missed, now)
+					g.mu.Unlock()
+				}
+			}
+		case <-ctx.Done():
+			window.To = time.Now()
+			break pacing
+		}
+	}
+
+	close(due)
+	wg.Wait()
+	return window
+}
+
+// submitLoop submits a transaction with a random payload and priority for
+// every due submission
+func (g *loadGenerator) submitLoop(client *gethrpc.Client, rng *rand.Rand, due <-chan struct{}) {
+	payload := make([]byte, g.payloadSize)
+	for range due {
+		rng.Read(payload)
+		args := map[string]any{
+			"data":     base64.StdEncoding.EncodeToString(payload),
+			"priority": 1 + rng.Intn(g.maxPriority),
+		}
+
+		var result struct {
+			TransactionID string `json:"transaction_id"`
+			Added         bool   `json:"added"`
+		}
+		sent := time.Now()
+		err := client.Call(&result, "flash_submitTransaction", args)
+		s := submission{sent: sent, latency: time.Since(sent)}
+		if err == nil && result.Added {
+			s.id = result.TransactionID
+		}
+
+		g.mu.Lock()
+		g.submissions = append(g.submissions, s)
+		g.mu.Unlock()
+	}
+}
+
+// blockCollector records the blocks of a run and when each transaction was
+// first seen in one
+type blockCollector struct {
+	mu         sync.Mutex
+	blocks     []BlockSample
+	includedAt map[string]time.Time
+
+	done chan struct{}
+}
+
+// newBlockCollector collects the blocks until the channel is closed
+func newBlockCollector(blocks <-chan BlockSample) *blockCollector {
+	c := &blockCollector{
+		includedAt: make(map[string]time.Time),
+		done:       make(chan struct{}),
+	}
+	go func() {
+		defer close(c.done)
+		for sample := range blocks {
+			c.add(sample)
+		}
+	}()
+	return c
+}
+
+// add records a block and its transactions
+func (c *blockCollector) add(sample BlockSample) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.blocks = append(c.blocks, sample)
+	for _, tx := range sample.Block.Transactions {
+		if _, ok := c.includedAt[tx.ID]; !ok {
+			c.includedAt[tx.ID] = sample.Observed
+		}
+	}
+}
+
+// Close waits until the block channel is closed and every block is recorded
+func (c *blockCollector) Close() {
+	<-c.done
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SweepConfig represents the configuration of a benchmark sweep. Every
+// combination of block interval, rate and payload size is run once.
+type SweepConfig struct {
+	// Target; an in-process server is started for every run if server_url is empty
+	ServerURL   string `yaml:"server_url"`
+	WSURL       string `yaml:"ws_url"`       // Block feed endpoint (derived from server_url if empty)
+	AuthToken   string `yaml:"auth_token"`   // Bearer token for servers with auth enabled
+	AdminSocket string `yaml:"admin_socket"` // Unix socket used to set the block interval of a remote server
+	Genesis     string `yaml:"genesis"`      // Genesis file of the in-process server (built-in default if empty)
+
+	// Parameters swept
+	BlockIntervals []time.Duration `yaml:"block_intervals"`
+	Rates          []int           `yaml:"rates"`         // Total submissions per second
+	PayloadSizes   []int           `yaml:"payload_sizes"` // Transaction data size in bytes
+
+	// Every run
+	Clients         int `yaml:"clients"`          // Concurrent submitting connections
+	DurationSeconds int `yaml:"duration_seconds"` // Measured load per run
+	WarmupSeconds   int `yaml:"warmup_seconds"`   // Load sent before the measurement starts
+	MaxPriority     int `yaml:"max_priority"`     // Priorities are drawn uniformly from 1 to max_priority
+
+	// Report files; a report is skipped if its path is empty
+	JSONReport string `yaml:"json_report"`
+	CSVReport  string `yaml:"csv_report"`
+}
+
+// defaultSweepConfig returns the sweep used for settings missing from the file
+func defaultSweepConfig() *SweepConfig {
+	return &SweepConfig{
+		BlockIntervals:  []time.Duration{100 * time.Millisecond},
+		Rates:           []int{1000},
+		PayloadSizes:    []int{128},
+		Clients:         16,
+		DurationSeconds: 10,
+		WarmupSeconds:   2,
+		MaxPriority:     10,
+		JSONReport:      "bench-report.json",
+		CSVReport:       "bench-report.csv",
+	}
+}
+
+func main() {
+	configFile := flag.String("config", "cmd/bench/sweep.yaml", "Path to the sweep configuration file")
+	server := flag.String("server", "", "Benchmark a running server at this URL instead of an in-process one")
+	jsonReport := flag.String("json", "", "Write the JSON report to this file (overrides json_report)")
+	csvReport := flag.String("csv", "", "Write the CSV report to this file (overrides csv_report)")
+	flag.Parse()
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *server != "" {
+		config.ServerURL = *server
+	}
+	if *jsonReport != "" {
+		config.JSONReport = *jsonReport
+	}
+	if *csvReport != "" {
+		config.CSVReport = *csvReport
+	}
+	if err := config.validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// An interrupt ends the sweep; the runs completed so far are still reported
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := runSweep(ctx, config)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("Sweep failed: %v", err)
+	}
+	if report == nil {
+		os.Exit(1)
+	}
+
+	report.Print(os.Stdout)
+	if config.JSONReport != "" {
+		if err := report.WriteJSON(config.JSONReport); err != nil {
+			log.Fatalf("Failed to write JSON report: %v", err)
+		}
+		log.Printf("JSON report written to %s", config.JSONReport)
+	}
+	if config.CSVReport != "" {
+		if err := report.WriteCSV(config.CSVReport); err != nil {
+			log.Fatalf("Failed to write CSV report: %v", err)
+		}
+		log.Printf("CSV report written to %s", config.CSVReport)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// loadConfig loads the sweep configuration from a YAML file over the defaults
+func loadConfig(path string) (*SweepConfig, error) {
+	config := defaultSweepConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// validate checks the sweep parameters
+func (c *SweepConfig) validate() error {
+	if len(c.BlockIntervals) == 0 || len(c.Rates) == 0 || len(c.PayloadSizes) == 0 {
+		return errors.New("block_intervals, rates and payload_sizes must not be empty")
+	}
+	for _, interval := range c.BlockIntervals {
+		if interval <= 0 {
+			return fmt.Errorf("block_intervals: %v must be greater than 0", interval)
+		}
+	}
+	for _, rate := range c.Rates {
+		if rate <= 0 {
+			return fmt.Errorf("rates: %d must be greater than 0", rate)
+		}
+	}
+	for _, size := range c.PayloadSizes {
+		if size <= 0 {
+			return fmt.Errorf("payload_sizes: %d must be greater than 0", size)
+		}
+	}
+	if c.Clients <= 0 {
+		return errors.New("clients must be greater than 0")
+	}
+	if c.DurationSeconds <= 0 {
+		return errors.New("duration_seconds must be greater than 0")
+	}
+	if c.WarmupSeconds < 0 {
+		return errors.New("warmup_seconds cannot be negative")
+	}
+	if c.MaxPriority <= 0 {
+		return errors.New("max_priority must be greater than 0")
+	}
+
+	// The interval of a remote server can only be changed through the admin namespace
+	if c.ServerURL != "" && c.AdminSocket == "" && len(c.BlockIntervals) > 1 {
+		return errors.New("admin_socket is required to sweep the block interval of a remote server")
+	}
+	return nil
+}
+
+// runSweep runs every combination of the swept parameters against the target.
+// The report holds the completed runs, also when the sweep fails or is interrupted.
+func runSweep(ctx context.Context, config *SweepConfig) (*Report, error) {
+	var target Target
+	if config.ServerURL != "" {
+		target = newRemoteTarget(config)
+	} else {
+		target = newInProcessTarget(config)
+	}
+
+	report := newReport(target.Name(), config)
+	total := len(config.BlockIntervals) * len(config.Rates) * len(config.PayloadSizes)
+	log.Printf("Running %d benchmarks against %s (%ds each after %ds warm-up)", total, target.Name(), config.DurationSeconds, config.WarmupSeconds)
+
+	for _, interval := range config.BlockIntervals {
+		for _, rate := range config.Rates {
+			for _, size := range config.PayloadSizes {
+				if ctx.Err() != nil {
+					return report, ctx.Err()
+				}
+
+				params := RunParams{Interval: interval, Rate: rate, PayloadSize: size}
+				log.Printf("Run %d/%d: block interval %v, %d tx/s, %d byte payloads", len(report.Runs)+1, total, interval, rate, size)
+
+				result, err := runBenchmark(ctx, target, config, params)
+				if err != nil {
+					return report, fmt.Errorf("run %s: %v", params, err)
+				}
+				report.Runs = append(report.Runs, result)
+				log.Printf("Run %d/%d: %.0f tx/s included in %d blocks, block build mean %.3fms, inclusion p99 %.1fms",
+					len(report.Runs), total, result.IncludedTPS, result.Blocks, result.BuildMeanMs, result.InclusionP99Ms)
+			}
+		}
+	}
+	return report, nil
+}
+
+// runBenchmark starts the target at the interval of the run, sends the load
+// and summarizes what was observed in the measurement window
+func runBenchmark(ctx context.Context, target Target, config *SweepConfig, params RunParams) (*RunResult, error) {
+	session, err := target.Start(params.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	collector := newBlockCollector(session.Blocks)
+	load, err := newLoadGenerator(session, config, params)
+	if err != nil {
+		session.Stop()
+		return nil, err
+	}
+
+	warmup := time.Duration(config.WarmupSeconds) * time.Second
+	duration := time.Duration(config.DurationSeconds) * time.Second
+	window := load.Run(ctx, warmup, duration)
+
+	// Submissions at the end of the window are given a few blocks to be included
+	grace := max(3*params.Interval, time.Second)
+	select {
+	case <-time.After(grace):
+	case <-ctx.Done():
+	}
+
+	stats, err := session.Stop()
+	collector.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return summarize(params, window, load, collector, stats), nil
+}
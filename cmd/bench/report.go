@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"flashblock/internal/version"
+)
+
+// RunParams are the swept parameters of a run
+type RunParams struct {
+	Interval    time.Duration
+	Rate        int
+	PayloadSize int
+}
+
+// String describes the parameters in log messages
+func (p RunParams) String() string {
+	return fmt.Sprintf("interval=%v rate=%d payload=%d", p.Interval, p.Rate, p.PayloadSize)
+}
+
+// RunResult summarizes a run. Times are in milliseconds; the fields are also
+// the CSV columns, in order.
+type RunResult struct {
+	IntervalMs  float64 `json:"block_interval_ms"`
+	Rate        int     `json:"rate"`
+	PayloadSize int     `json:"payload_bytes"`
+	DurationS   float64 `json:"duration_s"`
+
+	// Load
+	Offered      int     `json:"offered"`   // Submissions due in the window
+	Submitted    int     `json:"submitted"` // Admitted by the mempool
+	Failed       int     `json:"failed"`    // Errors and submissions not added
+	Missed       int     `json:"missed"`    // Due while every client was busy
+	SubmittedTPS float64 `json:"submitted_tps"`
+	SubmitP50Ms  float64 `json:"submit_p50_ms"`
+	SubmitP99Ms  float64 `json:"submit_p99_ms"`
+
+	// Inclusion of the submissions made in the window
+	Included       int     `json:"included"`
+	IncludedTPS    float64 `json:"included_tps"`
+	InclusionP50Ms float64 `json:"inclusion_p50_ms"`
+	InclusionP99Ms float64 `json:"inclusion_p99_ms"`
+
+	// Blocks sealed in the window
+	Blocks             int     `json:"blocks"`
+	TxsPerBlock        float64 `json:"txs_per_block"`
+	BlockSpacingMeanMs float64 `json:"block_spacing_mean_ms"` // Time between consecutive block timestamps
+	BlockSpacingP99Ms  float64 `json:"block_spacing_p99_ms"`
+	BuildMeanMs        float64 `json:"build_mean_ms"`
+	BuildP50Ms         float64 `json:"build_p50_ms"` // Per-block build times are only known in-process
+	BuildP99Ms         float64 `json:"build_p99_ms"`
+	BuildMaxMs         float64 `json:"build_max_ms"`
+
+	// Server counters
+	Shed uint64 `json:"shed"` // Rejected by load shedding
+	Busy uint64 `json:"busy"` // Rejected with "server busy"
+}
+
+// Report is the consolidated result of a sweep
+type Report struct {
+	Version         string       `json:"version"`
+	Target          string       `json:"target"`
+	Started         time.Time    `json:"started"`
+	Clients         int          `json:"clients"`
+	DurationSeconds int          `json:"duration_seconds"`
+	WarmupSeconds   int          `json:"warmup_seconds"`
+	Runs            []*RunResult `json:"runs"`
+}
+
+// newReport creates an empty report for a sweep against the target
+func newReport(target string, config *SweepConfig) *Report {
+	return &Report{
+		Version:         version.String(),
+		Target:          target,
+		Started:         time.Now(),
+		Clients:         config.Clients,
+		DurationSeconds: config.DurationSeconds,
+		WarmupSeconds:   config.WarmupSeconds,
+		Runs:            []*RunResult{},
+	}
+}
+
+// summarize calculates the result of a run from the submissions and blocks
+// observed in the window
+func summarize(params RunParams, window Window, load *loadGenerator, collector *blockCollector, stats *ServerStats) *RunResult {
+	seconds := window.Duration().Seconds()
+	r := &RunResult{
+		IntervalMs:  ms(params.Interval),
+		Rate:        params.Rate,
+		PayloadSize: params.PayloadSize,
+		DurationS:   seconds,
+		Shed:        stats.TransactionsShed,
+		Busy:        stats.RequestsBusy,
+	}
+
+	var submitLatencies, inclusionLatencies []time.Duration
+	for _, s := range load.submissions {
+		if !window.Contains(s.sent) {
+			continue
+		}
+		r.Offered++
+		submitLatencies = append(submitLatencies, s.latency)
+		if s.id == "" {
+			r.Failed++
+			continue
+		}
+		r.Submitted++
+		if includedAt, ok := collector.includedAt[s.id]; ok {
+			r.Included++
+			inclusionLatencies = append(inclusionLatencies, includedAt.Sub(s.sent))
+		}
+	}
+	for _, t := range load.missed {
+		if window.Contains(t) {
+			r.Offered++
+			r.Missed++
+		}
+	}
+
+	var spacings, buildTimes []time.Duration
+	var transactions int
+	var last time.Time
+	for _, b := range collector.blocks {
+		if !window.Contains(b.Observed) {
+			continue
+		}
+		r.Blocks++
+		transactions += len(b.Block.Transactions)
+		if !last.IsZero() {
+			spacings = append(spacings, b.Block.Timestamp.Sub(last))
+		}
+		last = b.Block.Timestamp
+		if b.BuildTime > 0 {
+			buildTimes = append(buildTimes, b.BuildTime)
+		}
+	}
+
+	if seconds > 0 {
+		r.SubmittedTPS = float64(r.Submitted) / seconds
+		r.IncludedTPS = float64(r.Included) / seconds
+	}
+	if r.Blocks > 0 {
+		r.TxsPerBlock = float64(transactions) / float64(r.Blocks)
+	}
+
+	sortDurations(submitLatencies)
+	r.SubmitP50Ms = ms(percentile(submitLatencies, 50))
+	r.SubmitP99Ms = ms(percentile(submitLatencies, 99))
+
+	sortDurations(inclusionLatencies)
+	r.InclusionP50Ms = ms(percentile(inclusionLatencies, 50))
+	r.InclusionP99Ms = ms(percentile(inclusionLatencies, 99))
+
+	sortDurations(spacings)
+	r.BlockSpacingMeanMs = ms(mean(spacings))
+	r.BlockSpacingP99Ms = ms(percentile(spacings, 99))
+
+	// Remote servers only report the mean build time
+	if len(buildTimes) > 0 {
+		sortDurations(buildTimes)
+		r.BuildMeanMs = ms(mean(buildTimes))
+		r.BuildP50Ms = ms(percentile(buildTimes, 50))
+		r.BuildP99Ms = ms(percentile(buildTimes, 99))
+		r.BuildMaxMs = ms(buildTimes[len(buildTimes)-1])
+	} else {
+		r.BuildMeanMs = ms(stats.BuildTimeMean)
+	}
+	return r
+}
+
+// Print writes the runs as a table
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "\nBenchmark of %s (%s)\n", r.Target, r.Version)
+	fmt.Fprintf(w, "%d clients, %ds per run after %ds warm-up\n\n", r.Clients, r.DurationSeconds, r.WarmupSeconds)
+	fmt.Fprintf(w, "%10s %8s %8s | %10s %10s %8s | %8s %10s %10s | %10s %10s\n",
+		"interval", "rate", "payload", "submit/s", "include/s", "missed", "blocks", "tx/block", "build p99", "submit p99", "incl p99")
+	for _, run := range r.Runs {
+		fmt.Fprintf(w, "%8.0fms %8d %7dB | %10.1f %10.1f %8d | %8d %10.1f %8.3fms %8.2fms %8.1fms\n",
+			run.IntervalMs, run.Rate, run.PayloadSize,
+			run.SubmittedTPS, run.IncludedTPS, run.Missed,
+			run.Blocks, run.TxsPerBlock, run.BuildP99Ms,
+			run.SubmitP99Ms, run.InclusionP99Ms)
+	}
+	fmt.Fprintln(w)
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// WriteCSV writes one row per run, with the JSON field names as the header
+func (r *Report) WriteCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	t := reflect.TypeOf(RunResult{})
+	header := make([]string, t.NumField())
+	for i := range header {
+		header[i] = t.Field(i).Tag.Get("json")
+	}
+	w.Write(header)
+
+	for _, run := range r.Runs {
+		v := reflect.ValueOf(run).Elem()
+		row := make([]string, v.NumField())
+		for i := range row {
+			switch f := v.Field(i); f.Kind() {
+			case reflect.Float64:
+				row[i] = strconv.FormatFloat(f.Float(), 'f', -1, 64)
+			default:
+				row[i] = fmt.Sprint(f.Interface())
+			}
+		}
+		w.Write(row)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// sortDurations sorts durations in increasing order
+func sortDurations(durations []time.Duration) {
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+}
+
+// percentile returns the nearest-rank percentile of an already sorted slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(math.Ceil(p/100.0*float64(len(sorted)))) - 1
+	return sorted[min(max(index, 0), len(sorted)-1)]
+}
+
+// mean returns the average of the durations
+func mean(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	return sum / time.Duration(len(durations))
+}
+
+// ms converts a duration to fractional milliseconds
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"flashblock/internal/events"
+	"flashblock/internal/genesis"
+	"flashblock/internal/mempool"
+	"flashblock/internal/metrics"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+	"flashblock/internal/rpc"
+	"flashblock/internal/state"
+
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// blockQueueSize is the number of observed blocks waiting to be collected
+const blockQueueSize = 256
+
+// Target is a server benchmark runs are sent to
+type Target interface {
+	Name() string
+	Start(interval time.Duration) (*Session, error) // Prepares a run at the given block interval
+}
+
+// Session is a single run against a target
+type Session struct {
+	URL       string
+	AuthToken string
+	Blocks    <-chan BlockSample // Blocks built during the run
+
+	stop func() (*ServerStats, error)
+}
+
+// Stop ends the run and returns what the server measured during it
+func (s *Session) Stop() (*ServerStats, error) {
+	return s.stop()
+}
+
+// BlockSample is a block built during a run
+type BlockSample struct {
+	Block     *model.Block
+	BuildTime time.Duration // Zero if the target does not report it per block
+	Observed  time.Time
+}
+
+// ServerStats holds the server-side counters of a run
+type ServerStats struct {
+	BuildTimeMean    time.Duration // Mean block build time (used if blocks carry no build time)
+	TransactionsShed uint64        // Submissions rejected by load shedding
+	RequestsBusy     uint64        // Requests rejected with "server busy"
+}
+
+// inProcessTarget starts a fresh server in this process for every run, so runs
+// do not share mempool or chain state
+type inProcessTarget struct {
+	genesis string
+}
+
+// newInProcessTarget creates a target that runs the server in this process
+func newInProcessTarget(config *SweepConfig) *inProcessTarget {
+	return &inProcessTarget{genesis: config.Genesis}
+}
+
+// Name describes the target in the report
+func (t *inProcessTarget) Name() string {
+	return "in-process"
+}
+
+// Start creates the account state, mempool, block processor and JSON-RPC
+// server of a run and starts building blocks
+func (t *inProcessTarget) Start(interval time.Duration) (*Session, error) {
+	g, err := genesis.LoadOrDefault(t.genesis)
+	if err != nil {
+		return nil, err
+	}
+	stateDB, err := state.Open("", g)
+	if err != nil {
+		return nil, err
+	}
+
+	m := metrics.New()
+	bus := events.NewBus()
+	mp := mempool.New(&mempool.Config{
+		Validate: stateDB.Validate,
+		Events:   bus,
+	})
+	bp := processor.New(mp, &processor.Config{
+		Interval: interval,
+		State:    stateDB,
+		Events:   bus,
+	})
+
+	// Build times are taken from the sealed blocks, not from the logs
+	sealed := make(chan events.BlockSealed, blockQueueSize)
+	sub := bus.BlockSealed.Subscribe(sealed)
+	blocks := make(chan BlockSample, blockQueueSize)
+	go func() {
+		defer close(blocks)
+		for {
+			select {
+			case ev := <-sealed:
+				blocks <- BlockSample{Block: ev.Block, BuildTime: ev.BuildTime, Observed: time.Now()}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+
+	addr, err := freeAddr()
+	if err != nil {
+		sub.Unsubscribe()
+		stateDB.Close()
+		return nil, err
+	}
+	rpcServer := rpc.NewServer(mp, &rpc.Config{Addr: addr})
+	rpcServer.SetMetrics(m)
+	rpcServer.SetProcessor(bp)
+	rpcServer.SetState(stateDB)
+	if err := rpcServer.Start(); err != nil {
+		sub.Unsubscribe()
+		stateDB.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bp.Start(ctx)
+		close(done)
+	}()
+
+	stop := func() (*ServerStats, error) {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelShutdown()
+		rpcServer.CloseSubscriptions()
+		err := rpcServer.Shutdown(shutdownCtx)
+
+		mp.Close()
+		cancel()
+		<-done
+		sub.Unsubscribe()
+
+		if closeErr := stateDB.Close(); err == nil {
+			err = closeErr
+		}
+		return &ServerStats{
+			TransactionsShed: mp.ShedCount(),
+			RequestsBusy:     m.GetSnapshot().RequestsBusy,
+		}, err
+	}
+
+	return &Session{URL: "http://" + addr, Blocks: blocks, stop: stop}, nil
+}
+
+// freeAddr returns a loopback address with a port no one is listening on
+func freeAddr() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return listener.Addr().String(), nil
+}
+
+// remoteTarget sends the runs to a running server. The blocks are observed
+// through the newBlocks subscription and the build time is derived from the
+// server metrics before and after the run.
+type remoteTarget struct {
+	url         string
+	wsURL       string
+	authToken   string
+	adminSocket string
+}
+
+// newRemoteTarget creates a target for the server at the configured URL
+func newRemoteTarget(config *SweepConfig) *remoteTarget {
+	wsURL := config.WSURL
+	if wsURL == "" {
+		wsURL = strings.TrimSuffix(strings.Replace(config.ServerURL, "http", "ws", 1), "/") + "/ws"
+	}
+	return &remoteTarget{
+		url:         config.ServerURL,
+		wsURL:       wsURL,
+		authToken:   config.AuthToken,
+		adminSocket: config.AdminSocket,
+	}
+}
+
+// Name describes the target in the report
+func (t *remoteTarget) Name() string {
+	return t.url
+}
+
+// serverMetrics is the part of the flash_getMetrics result used by the benchmark
+type serverMetrics struct {
+	BlocksCreated      uint64  `json:"blocks_created"`
+	AvgBlockCreationMs float64 `json:"avg_block_creation_ms"`
+	TransactionsShed   uint64  `json:"transactions_shed"`
+}
+
+// Start sets the block interval if an admin socket is configured, subscribes
+// to the new blocks and records the metrics at the start of the run
+func (t *remoteTarget) Start(interval time.Duration) (*Session, error) {
+	if t.adminSocket != "" {
+		if err := t.setInterval(interval); err != nil {
+			return nil, fmt.Errorf("failed to set the block interval: %v", err)
+		}
+	}
+
+	client, err := dial(t.url, t.authToken)
+	if err != nil {
+		return nil, err
+	}
+	var before serverMetrics
+	if err := client.Call(&before, "flash_getMetrics"); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to read the server metrics: %v", err)
+	}
+
+	wsClient, err := dial(t.wsURL, t.authToken)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to block feed: %v", err)
+	}
+	notifications := make(chan *model.Block, blockQueueSize)
+	sub, err := wsClient.Subscribe(context.Background(), "flash", notifications, "newBlocks")
+	if err != nil {
+		wsClient.Close()
+		client.Close()
+		return nil, fmt.Errorf("failed to subscribe to new blocks: %v", err)
+	}
+
+	blocks := make(chan BlockSample, blockQueueSize)
+	go func() {
+		defer close(blocks)
+		for {
+			select {
+			case block := <-notifications:
+				blocks <- BlockSample{Block: block, Observed: time.Now()}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+
+	stop := func() (*ServerStats, error) {
+		defer client.Close()
+		sub.Unsubscribe()
+		wsClient.Close()
+
+		var after serverMetrics
+		if err := client.Call(&after, "flash_getMetrics"); err != nil {
+			return nil, fmt.Errorf("failed to read the server metrics: %v", err)
+		}
+		stats := &ServerStats{TransactionsShed: after.TransactionsShed - before.TransactionsShed}
+
+		// The server reports the mean since it started; the mean of the run is
+		// recovered from the totals at both ends
+		if built := after.BlocksCreated - before.BlocksCreated; built > 0 {
+			totalMs := after.AvgBlockCreationMs*float64(after.BlocksCreated) - before.AvgBlockCreationMs*float64(before.BlocksCreated)
+			stats.BuildTimeMean = time.Duration(totalMs / float64(built) * float64(time.Millisecond))
+		}
+		return stats, nil
+	}
+
+	return &Session{URL: t.url, AuthToken: t.authToken, Blocks: blocks, stop: stop}, nil
+}
+
+// setInterval changes the block interval of the server through admin_setConfig
+func (t *remoteTarget) setInterval(interval time.Duration) error {
+	client, err := gethrpc.Dial(t.adminSocket)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var result any
+	return client.Call(&result, "admin_setConfig", map[string]any{"block.interval": interval.String()})
+}
+
+// dial connects to a JSON-RPC endpoint, sending the bearer token if one is given
+func dial(url string, authToken string) (*gethrpc.Client, error) {
+	if authToken == "" {
+		return gethrpc.Dial(url)
+	}
+	return gethrpc.DialOptions(context.Background(), url, gethrpc.WithHeader("Authorization", "Bearer "+authToken))
+}
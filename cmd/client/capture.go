@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CaptureRecord describes a single submitted transaction in a capture file
+type CaptureRecord struct {
+	Offset   time.Duration `json:"offset_ns"` // Time since the start of the run
+	ClientID int           `json:"client"`
+	Data     string        `json:"data"` // Payload exactly as submitted
+	Priority int           `json:"priority"`
+	TxID     string        `json:"tx_id,omitempty"`
+}
+
+// CaptureRecorder writes submitted transactions to a capture file as JSON lines
+type CaptureRecorder struct {
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	mu   sync.Mutex
+}
+
+// NewCaptureRecorder creates a recorder writing to the given file path
+func NewCaptureRecorder(filePath string) (*CaptureRecorder, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %v", err)
+	}
+
+	w := bufio.NewWriter(file)
+	return &CaptureRecorder{
+		file: file,
+		w:    w,
+		enc:  json.NewEncoder(w),
+	}, nil
+}
+
+// Record appends a submission to the capture file
+func (c *CaptureRecorder) Record(rec *CaptureRecord) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.enc.Encode(rec); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write capture record: %v\n", err)
+	}
+}
+
+// Close flushes and closes the capture file
+func (c *CaptureRecorder) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.w.Flush(); err != nil {
+		c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
+
+// mempoolExport matches the result of flash_getMempool
+type mempoolExport struct {
+	Transactions []*TransactionInfo `json:"transactions"`
+}
+
+// loadCapture reads replay records from a client capture file or a mempool export.
+// Records are returned ordered by their offset from the start of the capture.
+func loadCapture(filePath string) ([]*CaptureRecord, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture file: %v", err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("capture file is empty")
+	}
+
+	var records []*CaptureRecord
+	if bytes.Contains(trimmed, []byte(`"transactions"`)) {
+		records, err = parseMempoolExport(trimmed)
+	} else {
+		records, err = parseCaptureLines(trimmed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Offset < records[j].Offset
+	})
+
+	return records, nil
+}
+
+// parseCaptureLines parses a JSON lines file written by CaptureRecorder
+func parseCaptureLines(data []byte) ([]*CaptureRecord, error) {
+	var records []*CaptureRecord
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var rec CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("invalid capture record on line %d: %v", line, err)
+		}
+		records = append(records, &rec)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read capture file: %v", err)
+	}
+
+	return records, nil
+}
+
+// parseMempoolExport converts a flash_getMempool result (either the bare result
+// or a full JSON-RPC response) into replay records
+func parseMempoolExport(data []byte) ([]*CaptureRecord, error) {
+	var export mempoolExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid mempool export: %v", err)
+	}
+
+	if export.Transactions == nil {
+		var response struct {
+			Result mempoolExport `json:"result"`
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, fmt.Errorf("invalid mempool export: %v", err)
+		}
+		export = response.Result
+	}
+
+	if len(export.Transactions) == 0 {
+		return nil, fmt.Errorf("mempool export contains no transactions")
+	}
+
+	// Offsets are relative to the oldest transaction in the export
+	first := export.Transactions[0].Timestamp
+	for _, tx := range export.Transactions {
+		if tx.Timestamp.Before(first) {
+			first = tx.Timestamp
+		}
+	}
+
+	records := make([]*CaptureRecord, 0, len(export.Transactions))
+	for _, tx := range export.Transactions {
+		records = append(records, &CaptureRecord{
+			Offset:   tx.Timestamp.Sub(first),
+			Data:     base64.StdEncoding.EncodeToString(tx.Data),
+			Priority: tx.Priority,
+			TxID:     tx.ID,
+		})
+	}
+
+	// Mempool order is arbitrary, so fix the order and spread records over clients
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Offset != records[j].Offset {
+			return records[i].Offset < records[j].Offset
+		}
+		return records[i].TxID < records[j].TxID
+	})
+	for i, rec := range records {
+		rec.ClientID = i
+	}
+
+	return records, nil
+}
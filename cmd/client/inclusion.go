@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// earlyInclusionTTL bounds how long an inclusion observed before its submission
+// was acknowledged is remembered
+const earlyInclusionTTL = 10 * time.Second
+
+// BlockInfo represents block info delivered by the newBlocks subscription
+type BlockInfo struct {
+	ID           string            `json:"id"`
+	Transactions []TransactionInfo `json:"transactions"`
+	Timestamp    time.Time         `json:"timestamp"`
+	PrevBlockID  string            `json:"prev_block_id"`
+}
+
+// InclusionTracker measures the time between transaction submission and the
+// moment the transaction is observed in a block
+type InclusionTracker struct {
+	client *rpc.Client
+	sub    *rpc.ClientSubscription
+	blocks chan *BlockInfo
+
+	verifier *BlockVerifier // Receives every observed block (optional)
+
+	mu        sync.Mutex
+	pending   map[string]time.Time // Submitted transactions not yet included
+	early     map[string]time.Time // Inclusions observed before the submission was tracked
+	latencies []time.Duration
+	submitted int
+}
+
+// InclusionReport summarizes the time-to-inclusion measurements
+type InclusionReport struct {
+	Submitted   int
+	Included    int
+	NotIncluded int
+	Latency     DurationSummary
+}
+
+// NewInclusionTracker connects to the block feed at wsURL and starts tracking inclusions.
+// Observed blocks are also passed to the verifier if one is given.
+func NewInclusionTracker(wsURL string, authToken string, verifier *BlockVerifier) (*InclusionTracker, error) {
+	client, err := dialServer(wsURL, authToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to block feed: %v", err)
+	}
+
+	t := &InclusionTracker{
+		client:   client,
+		blocks:   make(chan *BlockInfo, 64),
+		verifier: verifier,
+		pending:  make(map[string]time.Time),
+		early:    make(map[string]time.Time),
+	}
+
+	t.sub, err = client.Subscribe(context.Background(), "flash", t.blocks, "newBlocks")
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to subscribe to new blocks: %v", err)
+	}
+
+	go t.loop()
+
+	return t, nil
+}
+
+// blockFeedURL derives the WebSocket endpoint from the configured server URL
+func blockFeedURL(config *WorkloadConfig) string {
+	if config.WSURL != "" {
+		return config.WSURL
+	}
+
+	url := strings.Replace(config.ServerURL, "http", "ws", 1)
+	return strings.TrimSuffix(url, "/") + "/ws"
+}
+
+// loop consumes block notifications until the subscription ends
+func (t *InclusionTracker) loop() {
+	for {
+		select {
+		case block := <-t.blocks:
+			t.recordBlock(block, time.Now())
+			t.verifier.AddBlock(block)
+		case err := <-t.sub.Err():
+			if err != nil {
+				log.Printf("Inclusion tracker: subscription ended: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// recordBlock records the inclusion of every tracked transaction in the block
+func (t *InclusionTracker) recordBlock(block *BlockInfo, seenAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tx := range block.Transactions {
+		submittedAt, ok := t.pending[tx.ID]
+		if !ok {
+			// The submission may not have been acknowledged yet
+			t.early[tx.ID] = seenAt
+			continue
+		}
+
+		delete(t.pending, tx.ID)
+		t.latencies = append(t.latencies, seenAt.Sub(submittedAt))
+	}
+
+	// Forget early inclusions that belong to other workloads
+	for id, at := range t.early {
+		if seenAt.Sub(at) > earlyInclusionTTL {
+			delete(t.early, id)
+		}
+	}
+}
+
+// Track registers a submitted transaction
+func (t *InclusionTracker) Track(txID string, submittedAt time.Time) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.submitted++
+
+	if seenAt, ok := t.early[txID]; ok {
+		delete(t.early, txID)
+		t.latencies = append(t.latencies, seenAt.Sub(submittedAt))
+		return
+	}
+
+	t.pending[txID] = submittedAt
+}
+
+// Wait blocks until every tracked transaction is included or the timeout expires
+func (t *InclusionTracker) Wait(timeout time.Duration) {
+	if t == nil {
+		return
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		t.mu.Lock()
+		remaining := len(t.pending)
+		t.mu.Unlock()
+
+		if remaining == 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Report returns the time-to-inclusion measurements collected so far
+func (t *InclusionTracker) Report() *InclusionReport {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &InclusionReport{
+		Submitted:   t.submitted,
+		Included:    len(t.latencies),
+		NotIncluded: len(t.pending),
+		Latency:     summarizeDurations(t.latencies),
+	}
+}
+
+// Close stops the subscription and closes the connection
+func (t *InclusionTracker) Close() {
+	if t == nil {
+		return
+	}
+
+	t.sub.Unsubscribe()
+	t.client.Close()
+}
+
+// printInclusionReport writes the time-to-inclusion distribution
+func printInclusionReport(w io.Writer, r *InclusionReport) {
+	if r == nil {
+		fmt.Fprintln(w, "Time-to-inclusion: not measured (block feed unavailable)")
+		return
+	}
+
+	fmt.Fprintln(w, "Time-to-Inclusion (submission to block notification):")
+	fmt.Fprintf(w, "  Submitted: %d\n", r.Submitted)
+	if r.Submitted > 0 {
+		fmt.Fprintf(w, "  Included: %d (%.2f%%)\n", r.Included, float64(r.Included)*100/float64(r.Submitted))
+	} else {
+		fmt.Fprintf(w, "  Included: %d\n", r.Included)
+	}
+	fmt.Fprintf(w, "  Not included: %d\n", r.NotIncluded)
+
+	if r.Latency.Count > 0 {
+		printDurationSummary(w, r.Latency)
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
+	"os/signal"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/ethereum/go-ethereum/rpc"
@@ -16,9 +20,47 @@ import (
 // WorkloadConfig represents the configuration for the workload
 type WorkloadConfig struct {
 	NumClients        int    `yaml:"num_clients"`
-	RequestsPerSecond int    `yaml:"requests_per_second"`
-	DurationSeconds   int    `yaml:"duration_seconds"`
+	RequestsPerSecond int    `yaml:"requests_per_second"` // Per-client rate, used when target_rps is not set
+	TargetRPS         int    `yaml:"target_rps"`          // Total rate shared by all clients
+	DurationSeconds   int    `yaml:"duration_seconds"`    // 0 runs until interrupted
+	WarmupSeconds     int    `yaml:"warmup_seconds"`      // Initial period excluded from statistics
 	ServerURL         string `yaml:"server_url"`
+	WSURL             string `yaml:"ws_url"`     // Block feed endpoint (derived from server_url if empty)
+	AuthToken         string `yaml:"auth_token"` // Bearer token for servers with auth enabled
+
+	// Capture and replay
+	RecordFile  string  `yaml:"record_file"`  // Write every submission to this capture file
+	ReplayFile  string  `yaml:"replay_file"`  // Replay a capture file or mempool export instead of generating load
+	ReplaySpeed float64 `yaml:"replay_speed"` // Replay time scaling factor (2.0 = twice as fast)
+
+	MetricsAddr string `yaml:"metrics_addr"` // Serve live Prometheus metrics on this address
+
+	// Verify block invariants after the run using this ordering strategy
+	// (priority or none); verification is disabled when empty
+	VerifyOrdering string `yaml:"verify_ordering"`
+
+	// Priority distributions
+	Priority     PriorityConfig      `yaml:"priority"`      // Default distribution for all clients
+	ClientGroups []ClientGroupConfig `yaml:"client_groups"` // Per-group distributions, assigned to clients in order
+}
+
+// Workload holds the state shared by all clients during a run
+type Workload struct {
+	config   *WorkloadConfig
+	tracker  *InclusionTracker
+	recorder *CaptureRecorder
+	metrics  *LiveMetrics
+	schedule *RateScheduler // Global rate scheduler (nil for per-client rates)
+	verifier *BlockVerifier // Block invariant verifier (nil if disabled)
+	start    time.Time
+
+	submitted atomic.Uint64 // Successfully submitted transactions
+	failed    atomic.Uint64 // Submissions that returned an error
+
+	// Warm-up traffic is sent but excluded from the summary statistics
+	measureFrom     time.Time
+	warmupSubmitted atomic.Uint64
+	warmupFailed    atomic.Uint64
 }
 
 // SubmitTransactionArgs represents parameters for the submitTransaction method
@@ -52,7 +94,15 @@ type TransactionInfo struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// inclusionGracePeriod is how long to wait for outstanding inclusions after the workload ends
+const inclusionGracePeriod = 3 * time.Second
+
 func main() {
+	os.Exit(run())
+}
+
+// run executes the workload and returns the process exit code
+func run() int {
 	// Parse command-line flags
 	configFile := flag.String("config", "cmd/client/workload.yaml", "Path to the configuration file")
 	flag.Parse()
@@ -63,21 +113,153 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	log.Printf("Starting workload with %d clients, %d requests/sec per client, for %d seconds",
-		config.NumClients, config.RequestsPerSecond, config.DurationSeconds)
+	// Load replay records before connecting so errors surface early
+	var records []*CaptureRecord
+	if config.ReplayFile != "" {
+		records, err = loadCapture(config.ReplayFile)
+		if err != nil {
+			log.Fatalf("Failed to load replay file: %v", err)
+		}
+		log.Printf("Replaying %d transactions from %s with %d clients at %.2fx speed",
+			len(records), config.ReplayFile, config.NumClients, config.ReplaySpeed)
+	} else {
+		rate := fmt.Sprintf("%d requests/sec per client", config.RequestsPerSecond)
+		if config.TargetRPS > 0 {
+			rate = fmt.Sprintf("%d requests/sec in total", config.TargetRPS)
+		}
+
+		if config.DurationSeconds == 0 {
+			log.Printf("Starting workload with %d clients, %s, until interrupted", config.NumClients, rate)
+		} else {
+			log.Printf("Starting workload with %d clients, %s, for %d seconds", config.NumClients, rate, config.DurationSeconds)
+		}
+	}
+
+	// Stop all clients on interrupt; the summary is still printed
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if config.ReplayFile == "" && config.DurationSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(config.DurationSeconds)*time.Second)
+		defer cancel()
+	}
+
+	// Record submissions if requested
+	var recorder *CaptureRecorder
+	if config.RecordFile != "" {
+		recorder, err = NewCaptureRecorder(config.RecordFile)
+		if err != nil {
+			log.Fatalf("Failed to open record file: %v", err)
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				log.Printf("Failed to close record file: %v", err)
+			}
+		}()
+		log.Printf("Recording submissions to %s", config.RecordFile)
+	}
+
+	// Collect produced blocks for verification if requested
+	var verifier *BlockVerifier
+	if config.VerifyOrdering != "" {
+		verifier = NewBlockVerifier(config.VerifyOrdering)
+	}
+
+	// Track block inclusion of submitted transactions
+	tracker, err := NewInclusionTracker(blockFeedURL(config), config.AuthToken, verifier)
+	if err != nil {
+		log.Printf("Time-to-inclusion tracking disabled: %v", err)
+	}
+	defer tracker.Close()
+
+	w := &Workload{
+		config:   config,
+		tracker:  tracker,
+		recorder: recorder,
+		verifier: verifier,
+		start:    time.Now(),
+	}
+	w.measureFrom = w.start.Add(time.Duration(config.WarmupSeconds) * time.Second)
+
+	// Snapshot the counters at the end of the warm-up so its traffic can be excluded
+	if config.WarmupSeconds > 0 {
+		log.Printf("Warm-up: statistics of the first %d seconds are discarded", config.WarmupSeconds)
+		warmup := time.AfterFunc(time.Until(w.measureFrom), w.endWarmup)
+		defer warmup.Stop()
+	}
+
+	// Expose live metrics for the duration of the run
+	if config.MetricsAddr != "" {
+		metricsCtx, stopMetrics := context.WithCancel(context.Background())
+		defer stopMetrics()
+
+		w.metrics = NewLiveMetrics(w)
+		if err := w.metrics.Serve(metricsCtx, config.MetricsAddr); err != nil {
+			log.Fatalf("Failed to start metrics server: %v", err)
+		}
+	}
+
+	// Share the target rate between all clients
+	if records == nil && config.TargetRPS > 0 {
+		w.schedule = NewRateScheduler(float64(config.TargetRPS), config.NumClients)
+		go w.schedule.Run(ctx)
+	}
 
 	// Create a WaitGroup to wait for all clients to complete
 	var wg sync.WaitGroup
 
 	// Start the specified number of clients
-	for i := range config.NumClients {
-		wg.Add(1)
-		go runClient(i, config, &wg)
+	if records != nil {
+		assignments := assignReplayRecords(records, config.NumClients)
+		for i := range config.NumClients {
+			wg.Add(1)
+			go runReplayClient(ctx, i, w, assignments[i], &wg)
+		}
+	} else {
+		for i := range config.NumClients {
+			wg.Add(1)
+			go runClient(ctx, i, w, &wg)
+		}
 	}
 
+	// Restore default signal handling once stopping so a second interrupt exits immediately
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	// Wait for all clients to complete
 	wg.Wait()
-	log.Println("Workload completed")
+	end := time.Now()
+	interrupted := ctx.Err() == context.Canceled
+	if interrupted {
+		log.Println("Workload interrupted")
+	} else {
+		log.Println("Workload completed")
+	}
+
+	// Give the last transactions a chance to be included
+	tracker.Wait(inclusionGracePeriod)
+
+	printWorkloadSummary(os.Stdout, w, end, interrupted)
+	printInclusionReport(os.Stdout, tracker.Report())
+
+	if verifier == nil {
+		return 0
+	}
+
+	// Verify the produced blocks, including those retained by the server
+	if err := verifier.FetchBlocks(config.ServerURL, config.AuthToken); err != nil {
+		log.Printf("Failed to fetch blocks for verification: %v", err)
+	}
+
+	report := verifier.Verify()
+	printVerificationReport(os.Stdout, report)
+	if report.Failed() {
+		return 1
+	}
+	return 0
 }
 
 // loadConfig loads the workload configuration from a YAML file
@@ -96,11 +278,55 @@ func loadConfig(filePath string) (*WorkloadConfig, error) {
 	if config.NumClients <= 0 {
 		return nil, fmt.Errorf("num_clients must be greater than 0")
 	}
-	if config.RequestsPerSecond <= 0 {
-		return nil, fmt.Errorf("requests_per_second must be greater than 0")
+	if config.ReplayFile == "" {
+		// Rate and duration come from the capture file when replaying
+		if config.TargetRPS < 0 {
+			return nil, fmt.Errorf("target_rps cannot be negative")
+		}
+		if config.TargetRPS == 0 && config.RequestsPerSecond <= 0 {
+			return nil, fmt.Errorf("requests_per_second must be greater than 0")
+		}
+		if config.DurationSeconds < 0 {
+			return nil, fmt.Errorf("duration_seconds cannot be negative")
+		}
+		if config.DurationSeconds > 0 && config.WarmupSeconds >= config.DurationSeconds {
+			return nil, fmt.Errorf("warmup_seconds must be less than duration_seconds")
+		}
+	}
+	switch config.VerifyOrdering {
+	case "", OrderingPriority, OrderingNone:
+	default:
+		return nil, fmt.Errorf("unknown verify_ordering %q", config.VerifyOrdering)
+	}
+	if config.WarmupSeconds < 0 {
+		return nil, fmt.Errorf("warmup_seconds cannot be negative")
+	}
+	if config.ReplaySpeed == 0 {
+		config.ReplaySpeed = 1.0
+	}
+	if config.ReplaySpeed < 0 {
+		return nil, fmt.Errorf("replay_speed must be greater than 0")
+	}
+
+	config.Priority.setDefaults()
+	if err := config.Priority.validate(); err != nil {
+		return nil, fmt.Errorf("invalid priority: %v", err)
+	}
+
+	groupedClients := 0
+	for i := range config.ClientGroups {
+		group := &config.ClientGroups[i]
+		if group.Clients <= 0 {
+			return nil, fmt.Errorf("client group %q must have at least one client", group.Name)
+		}
+		group.Priority.setDefaults()
+		if err := group.Priority.validate(); err != nil {
+			return nil, fmt.Errorf("invalid priority for client group %q: %v", group.Name, err)
+		}
+		groupedClients += group.Clients
 	}
-	if config.DurationSeconds <= 0 {
-		return nil, fmt.Errorf("duration_seconds must be greater than 0")
+	if groupedClients > config.NumClients {
+		return nil, fmt.Errorf("client groups use %d clients but num_clients is %d", groupedClients, config.NumClients)
 	}
 	if config.ServerURL == "" {
 		return nil, fmt.Errorf("server_url cannot be empty")
@@ -110,14 +336,17 @@ func loadConfig(filePath string) (*WorkloadConfig, error) {
 }
 
 // runClient runs a single client that generates the specified workload
-func runClient(clientID int, config *WorkloadConfig, wg *sync.WaitGroup) {
+func runClient(ctx context.Context, clientID int, w *Workload, wg *sync.WaitGroup) {
 	defer wg.Done()
 
+	config := w.config
+
 	// Create a new random source with current time and client ID as seed
 	r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(clientID)))
+	priorities := NewPrioritySampler(config.priorityConfigFor(clientID), r)
 
 	// Connect to the server
-	client, err := rpc.Dial(config.ServerURL)
+	client, err := dialServer(config.ServerURL, config.AuthToken)
 	if err != nil {
 		log.Printf("Client %d: Failed to connect to the server: %v", clientID, err)
 		return
@@ -126,15 +355,19 @@ func runClient(clientID int, config *WorkloadConfig, wg *sync.WaitGroup) {
 
 	log.Printf("Client %d: Connected to server %s", clientID, config.ServerURL)
 
-	// Calculate interval between requests to achieve the desired rate
-	interval := time.Second / time.Duration(config.RequestsPerSecond)
-
-	// Create a timer to control the request rate
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	// Create a timer for the overall duration
-	timeout := time.After(time.Duration(config.DurationSeconds) * time.Second)
+	// Send whenever the global scheduler hands out a token, or at the per-client rate
+	var sendCh <-chan time.Time
+	if w.schedule != nil {
+		sendCh = w.schedule.Tokens(clientID)
+	} else {
+		// Calculate interval between requests to achieve the desired rate
+		interval := time.Second / time.Duration(config.RequestsPerSecond)
+
+		// Create a timer to control the request rate
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		sendCh = ticker.C
+	}
 
 	// Track transactions for status checking
 	var txIDs []string
@@ -144,21 +377,21 @@ func runClient(clientID int, config *WorkloadConfig, wg *sync.WaitGroup) {
 	txCounter := 0
 	for {
 		select {
-		case <-timeout:
-			// Duration complete
+		case <-ctx.Done():
+			// Duration complete or interrupted
 			log.Printf("Client %d: Completed workload (%d transactions sent)", clientID, txCounter)
 
 			// Check status of transactions (sample up to 10)
 			checkTransactionStatuses(client, txIDs, clientID)
 			return
 
-		case <-ticker.C:
+		case <-sendCh:
 			// Time to send another transaction
 			data := fmt.Sprintf("Client %d transaction %d", clientID, txCounter)
-			priority := r.Intn(100)
+			priority := priorities.Sample()
 
 			// Submit transaction
-			txID, err := submitTransaction(client, data, priority)
+			txID, err := w.submit(client, clientID, data, priority)
 			if err != nil {
 				log.Printf("Client %d: Failed to submit transaction: %v", clientID, err)
 				continue
@@ -203,6 +436,49 @@ func checkTransactionStatuses(client *rpc.Client, txIDs []string, clientID int)
 	}
 }
 
+// submit submits a transaction and records it for inclusion tracking and capture
+func (w *Workload) submit(client *rpc.Client, clientID int, data string, priority int) (string, error) {
+	submittedAt := time.Now()
+
+	txID, err := submitTransaction(client, data, priority)
+	w.metrics.ObserveLatency(time.Since(submittedAt))
+	if err != nil {
+		w.failed.Add(1)
+		return "", err
+	}
+
+	w.submitted.Add(1)
+	w.verifier.Accept(txID)
+	if !submittedAt.Before(w.measureFrom) {
+		w.tracker.Track(txID, submittedAt)
+	}
+	w.recorder.Record(&CaptureRecord{
+		Offset:   submittedAt.Sub(w.start),
+		ClientID: clientID,
+		Data:     data,
+		Priority: priority,
+		TxID:     txID,
+	})
+
+	return txID, nil
+}
+
+// endWarmup records the counters at the end of the warm-up phase
+func (w *Workload) endWarmup() {
+	w.warmupSubmitted.Store(w.submitted.Load())
+	w.warmupFailed.Store(w.failed.Load())
+	log.Println("Warm-up completed, measuring")
+}
+
+// dialServer connects to the server, authenticating with the bearer token if set
+func dialServer(url string, authToken string) (*rpc.Client, error) {
+	if authToken == "" {
+		return rpc.Dial(url)
+	}
+
+	return rpc.DialOptions(context.Background(), url, rpc.WithHeader("Authorization", "Bearer "+authToken))
+}
+
 // submitTransaction submits a transaction to the server
 func submitTransaction(client *rpc.Client, data string, priority int) (string, error) {
 	args := SubmitTransactionArgs{
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// metricsLatencyWindow is the number of recent submissions used for latency quantiles
+	metricsLatencyWindow = 10000
+
+	// metricsRateInterval is how often the live rates are recalculated
+	metricsRateInterval = time.Second
+)
+
+// metricsQuantiles are the latency quantiles exposed on the metrics endpoint
+var metricsQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// LiveMetrics exposes the live load generator metrics in Prometheus text format
+type LiveMetrics struct {
+	w *Workload
+
+	mu           sync.Mutex
+	latencies    []time.Duration // Ring buffer of recent submission latencies
+	next         int
+	latencySum   time.Duration
+	latencyCount uint64
+
+	offeredRate   float64
+	achievedRate  float64
+	errorRate     float64
+	lastOffered   uint64
+	lastSubmitted uint64
+	lastFailed    uint64
+	lastSample    time.Time
+}
+
+// NewLiveMetrics creates live metrics for the given workload
+func NewLiveMetrics(w *Workload) *LiveMetrics {
+	return &LiveMetrics{
+		w:          w,
+		latencies:  make([]time.Duration, 0, metricsLatencyWindow),
+		lastSample: time.Now(),
+	}
+}
+
+// ObserveLatency records the round-trip time of a submission
+func (m *LiveMetrics) ObserveLatency(d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.latencies) < metricsLatencyWindow {
+		m.latencies = append(m.latencies, d)
+	} else {
+		m.latencies[m.next] = d
+		m.next = (m.next + 1) % metricsLatencyWindow
+	}
+	m.latencySum += d
+	m.latencyCount++
+}
+
+// sampleRates recalculates the live rates from the workload counters
+func (m *LiveMetrics) sampleRates(now time.Time) {
+	submitted := m.w.submitted.Load()
+	failed := m.w.failed.Load()
+	offered := submitted + failed
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elapsed := now.Sub(m.lastSample).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	offeredDelta := offered - m.lastOffered
+	m.offeredRate = float64(offeredDelta) / elapsed
+	m.achievedRate = float64(submitted-m.lastSubmitted) / elapsed
+	if offeredDelta > 0 {
+		m.errorRate = float64(failed-m.lastFailed) / float64(offeredDelta)
+	} else {
+		m.errorRate = 0
+	}
+
+	m.lastOffered = offered
+	m.lastSubmitted = submitted
+	m.lastFailed = failed
+	m.lastSample = now
+}
+
+// ServeHTTP writes the metrics in Prometheus text exposition format
+func (m *LiveMetrics) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	submitted := m.w.submitted.Load()
+	failed := m.w.failed.Load()
+
+	m.mu.Lock()
+	sorted := make([]time.Duration, len(m.latencies))
+	copy(sorted, m.latencies)
+	latencySum := m.latencySum
+	latencyCount := m.latencyCount
+	offeredRate, achievedRate, errorRate := m.offeredRate, m.achievedRate, m.errorRate
+	m.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	writeMetric(rw, "flashblock_client_clients", "gauge", "Number of configured clients", float64(m.w.config.NumClients))
+	writeMetric(rw, "flashblock_client_offered_total", "counter", "Submission attempts", float64(submitted+failed))
+	writeMetric(rw, "flashblock_client_submitted_total", "counter", "Successful submissions", float64(submitted))
+	writeMetric(rw, "flashblock_client_failed_total", "counter", "Failed submissions", float64(failed))
+	writeMetric(rw, "flashblock_client_offered_rps", "gauge", "Offered submissions per second", offeredRate)
+	writeMetric(rw, "flashblock_client_achieved_rps", "gauge", "Successful submissions per second", achievedRate)
+	writeMetric(rw, "flashblock_client_error_ratio", "gauge", "Ratio of failed submissions over the last interval", errorRate)
+
+	fmt.Fprintln(rw, "# HELP flashblock_client_submit_latency_seconds Submission round-trip latency")
+	fmt.Fprintln(rw, "# TYPE flashblock_client_submit_latency_seconds summary")
+	for _, q := range metricsQuantiles {
+		fmt.Fprintf(rw, "flashblock_client_submit_latency_seconds{quantile=\"%g\"} %g\n", q, percentile(sorted, q*100).Seconds())
+	}
+	fmt.Fprintf(rw, "flashblock_client_submit_latency_seconds_sum %g\n", latencySum.Seconds())
+	fmt.Fprintf(rw, "flashblock_client_submit_latency_seconds_count %d\n", latencyCount)
+}
+
+// writeMetric writes a single unlabelled metric with its metadata
+func writeMetric(rw http.ResponseWriter, name, kind, help string, value float64) {
+	fmt.Fprintf(rw, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(rw, "# TYPE %s %s\n", name, kind)
+	fmt.Fprintf(rw, "%s %g\n", name, value)
+}
+
+// Serve exposes the metrics on addr until the context is cancelled
+func (m *LiveMetrics) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{Handler: mux}
+
+	go func() {
+		log.Printf("Client metrics listening on %s/metrics", addr)
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Client metrics server error: %v", err)
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(metricsRateInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				httpServer.Shutdown(shutdownCtx)
+				return
+			case now := <-ticker.C:
+				m.sampleRates(now)
+			}
+		}
+	}()
+
+	return nil
+}
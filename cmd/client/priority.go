@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Supported priority distributions
+const (
+	DistributionFixed   = "fixed"
+	DistributionUniform = "uniform"
+	DistributionZipf    = "zipf"
+	DistributionBimodal = "bimodal"
+)
+
+// PriorityConfig describes the distribution transaction priorities are drawn from
+type PriorityConfig struct {
+	Distribution string `yaml:"distribution"` // fixed, uniform, zipf or bimodal (default uniform)
+
+	// Range of generated priorities (uniform, zipf and bimodal)
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+
+	// fixed
+	Value int `yaml:"value"`
+
+	// zipf: P(k) is proportional to (v + k) ^ (-s), so most priorities are close to min
+	ZipfS float64 `yaml:"zipf_s"`
+	ZipfV float64 `yaml:"zipf_v"`
+
+	// bimodal: a mix of two normal distributions
+	LowMean      float64 `yaml:"low_mean"`
+	HighMean     float64 `yaml:"high_mean"`
+	StdDev       float64 `yaml:"stddev"`
+	HighFraction float64 `yaml:"high_fraction"` // Fraction of samples drawn from the high mode
+}
+
+// ClientGroupConfig assigns a priority distribution to a group of clients
+type ClientGroupConfig struct {
+	Name     string         `yaml:"name"`
+	Clients  int            `yaml:"clients"`
+	Priority PriorityConfig `yaml:"priority"`
+}
+
+// setDefaults fills in unset parameters
+func (c *PriorityConfig) setDefaults() {
+	if c.Distribution == "" {
+		c.Distribution = DistributionUniform
+	}
+	if c.Min == 0 && c.Max == 0 {
+		// Matches the historical r.Intn(100) behavior
+		c.Max = 99
+	}
+	if c.ZipfS == 0 {
+		c.ZipfS = 1.1
+	}
+	if c.ZipfV == 0 {
+		c.ZipfV = 1
+	}
+	if c.Distribution == DistributionBimodal {
+		if c.LowMean == 0 && c.HighMean == 0 {
+			c.LowMean = float64(c.Min) + float64(c.Max-c.Min)*0.2
+			c.HighMean = float64(c.Min) + float64(c.Max-c.Min)*0.8
+		}
+		if c.StdDev == 0 {
+			c.StdDev = float64(c.Max-c.Min) / 20
+		}
+		if c.HighFraction == 0 {
+			c.HighFraction = 0.1
+		}
+	}
+}
+
+// validate checks that the distribution parameters are usable
+func (c *PriorityConfig) validate() error {
+	switch c.Distribution {
+	case DistributionFixed:
+		return nil
+	case DistributionUniform, DistributionZipf, DistributionBimodal:
+	default:
+		return fmt.Errorf("unknown priority distribution %q", c.Distribution)
+	}
+
+	if c.Max < c.Min {
+		return fmt.Errorf("priority max must not be less than min")
+	}
+	if c.Distribution == DistributionZipf {
+		if c.ZipfS <= 1 {
+			return fmt.Errorf("zipf_s must be greater than 1")
+		}
+		if c.ZipfV < 1 {
+			return fmt.Errorf("zipf_v must be at least 1")
+		}
+	}
+	if c.Distribution == DistributionBimodal {
+		if c.StdDev < 0 {
+			return fmt.Errorf("stddev cannot be negative")
+		}
+		if c.HighFraction < 0 || c.HighFraction > 1 {
+			return fmt.Errorf("high_fraction must be between 0 and 1")
+		}
+	}
+
+	return nil
+}
+
+// PrioritySampler draws transaction priorities from a configured distribution
+type PrioritySampler struct {
+	config *PriorityConfig
+	r      *rand.Rand
+	zipf   *rand.Zipf
+}
+
+// NewPrioritySampler creates a sampler for the distribution using the given random source
+func NewPrioritySampler(config *PriorityConfig, r *rand.Rand) *PrioritySampler {
+	s := &PrioritySampler{
+		config: config,
+		r:      r,
+	}
+
+	if config.Distribution == DistributionZipf {
+		s.zipf = rand.NewZipf(r, config.ZipfS, config.ZipfV, uint64(config.Max-config.Min))
+	}
+
+	return s
+}
+
+// Sample returns the next priority
+func (s *PrioritySampler) Sample() int {
+	c := s.config
+
+	switch c.Distribution {
+	case DistributionFixed:
+		return c.Value
+	case DistributionZipf:
+		return c.Min + int(s.zipf.Uint64())
+	case DistributionBimodal:
+		mean := c.LowMean
+		if s.r.Float64() < c.HighFraction {
+			mean = c.HighMean
+		}
+		value := int(s.r.NormFloat64()*c.StdDev + mean)
+		return max(c.Min, min(c.Max, value))
+	default:
+		return c.Min + s.r.Intn(c.Max-c.Min+1)
+	}
+}
+
+// priorityConfigFor returns the priority distribution for the given client.
+// Clients are assigned to groups in order; clients beyond all groups use the default.
+func (c *WorkloadConfig) priorityConfigFor(clientID int) *PriorityConfig {
+	offset := 0
+	for i := range c.ClientGroups {
+		group := &c.ClientGroups[i]
+		if clientID < offset+group.Clients {
+			return &group.Priority
+		}
+		offset += group.Clients
+	}
+
+	return &c.Priority
+}
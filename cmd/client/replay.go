@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// assignReplayRecords distributes replay records over the given number of clients,
+// keeping records that were submitted by the same client together
+func assignReplayRecords(records []*CaptureRecord, numClients int) [][]*CaptureRecord {
+	assignments := make([][]*CaptureRecord, numClients)
+	for _, rec := range records {
+		idx := rec.ClientID % numClients
+		if idx < 0 {
+			idx += numClients
+		}
+		assignments[idx] = append(assignments[idx], rec)
+	}
+
+	return assignments
+}
+
+// runReplayClient submits the given records, reproducing their relative timing
+func runReplayClient(ctx context.Context, clientID int, w *Workload, records []*CaptureRecord, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if len(records) == 0 {
+		return
+	}
+
+	// Connect to the server
+	client, err := dialServer(w.config.ServerURL, w.config.AuthToken)
+	if err != nil {
+		log.Printf("Client %d: Failed to connect to the server: %v", clientID, err)
+		return
+	}
+	defer client.Close()
+
+	log.Printf("Client %d: Connected to server %s (%d transactions to replay)", clientID, w.config.ServerURL, len(records))
+
+	sent := 0
+	for _, rec := range records {
+		// Wait until the scaled offset of the record is reached
+		due := w.start.Add(time.Duration(float64(rec.Offset) / w.config.ReplaySpeed))
+		select {
+		case <-ctx.Done():
+			log.Printf("Client %d: Replay stopped (%d of %d transactions sent)", clientID, sent, len(records))
+			return
+		case <-time.After(time.Until(due)):
+		}
+
+		if _, err := w.submit(client, clientID, rec.Data, rec.Priority); err != nil {
+			log.Printf("Client %d: Failed to replay transaction: %v", clientID, err)
+			continue
+		}
+		sent++
+	}
+
+	log.Printf("Client %d: Completed replay (%d of %d transactions sent)", clientID, sent, len(records))
+}
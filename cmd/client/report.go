@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// printWorkloadSummary writes the overall submission statistics of the run,
+// excluding traffic sent during the warm-up phase
+func printWorkloadSummary(w io.Writer, wl *Workload, end time.Time, interrupted bool) {
+	elapsed := end.Sub(wl.measureFrom)
+
+	var submitted, failed uint64
+	warmup := wl.warmupSubmitted.Load() + wl.warmupFailed.Load()
+	if elapsed > 0 {
+		submitted = wl.submitted.Load() - wl.warmupSubmitted.Load()
+		failed = wl.failed.Load() - wl.warmupFailed.Load()
+	} else {
+		// The run ended before the warm-up completed
+		elapsed = 0
+		warmup = wl.submitted.Load() + wl.failed.Load()
+	}
+
+	fmt.Fprintln(w, "Workload Summary:")
+	fmt.Fprintf(w, "  Clients: %d\n", wl.config.NumClients)
+	if wl.config.WarmupSeconds > 0 {
+		fmt.Fprintf(w, "  Warm-up: %ds (excluded, %d transactions)\n", wl.config.WarmupSeconds, warmup)
+	}
+	if interrupted {
+		fmt.Fprintf(w, "  Duration: %v (interrupted)\n", elapsed.Round(time.Millisecond))
+	} else {
+		fmt.Fprintf(w, "  Duration: %v\n", elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "  Submitted: %d\n", submitted)
+	fmt.Fprintf(w, "  Failed: %d\n", failed)
+	if elapsed > 0 {
+		fmt.Fprintf(w, "  Achieved rate: %.2f tx/s\n", float64(submitted)/elapsed.Seconds())
+	}
+}
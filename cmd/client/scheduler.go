@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// schedulerTick is how often the scheduler refills the bucket and hands out tokens
+const schedulerTick = time.Millisecond
+
+// RateScheduler enforces a global request rate shared by all clients.
+// Tokens are generated from the elapsed time so the offered rate does not drift,
+// and handed out round-robin so every ready client gets an equal share.
+type RateScheduler struct {
+	rate    float64 // Tokens per second
+	burst   int     // Maximum number of undelivered tokens kept in the bucket
+	clients []chan time.Time
+	next    int // Next client to receive a token
+}
+
+// NewRateScheduler creates a scheduler issuing rate tokens per second over numClients clients
+func NewRateScheduler(rate float64, numClients int) *RateScheduler {
+	clients := make([]chan time.Time, numClients)
+	for i := range clients {
+		// Each client can hold a single token while it is busy sending
+		clients[i] = make(chan time.Time, 1)
+	}
+
+	return &RateScheduler{
+		rate:    rate,
+		burst:   numClients,
+		clients: clients,
+	}
+}
+
+// Tokens returns the channel the given client receives its send permits on
+func (s *RateScheduler) Tokens(clientID int) <-chan time.Time {
+	return s.clients[clientID]
+}
+
+// Run generates and distributes tokens until the context is cancelled
+func (s *RateScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	start := time.Now()
+	var issued int64 // Tokens generated since start
+	available := 0   // Tokens generated but not yet delivered
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			due := int64(now.Sub(start).Seconds() * s.rate)
+			available += int(due - issued)
+			issued = due
+
+			// Tokens that no client could take are dropped beyond the burst size
+			if available > s.burst {
+				available = s.burst
+			}
+
+			available -= s.distribute(now, available)
+		}
+	}
+}
+
+// distribute hands up to n tokens to ready clients in round-robin order and
+// returns the number of tokens delivered
+func (s *RateScheduler) distribute(now time.Time, n int) int {
+	delivered := 0
+	skipped := 0
+
+	for delivered < n && skipped < len(s.clients) {
+		select {
+		case s.clients[s.next] <- now:
+			delivered++
+			skipped = 0
+		default:
+			// Client still holds its previous token
+			skipped++
+		}
+		s.next = (s.next + 1) % len(s.clients)
+	}
+
+	return delivered
+}
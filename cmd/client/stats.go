@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// DurationSummary holds summary statistics for a set of durations
+type DurationSummary struct {
+	Count int
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// summarizeDurations calculates summary statistics for the given durations
+func summarizeDurations(durations []time.Duration) DurationSummary {
+	if len(durations) == 0 {
+		return DurationSummary{}
+	}
+
+	// Create a copy to avoid modifying the original slice
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+
+	return DurationSummary{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		Mean:  sum / time.Duration(len(sorted)),
+		P50:   percentile(sorted, 50),
+		P90:   percentile(sorted, 90),
+		P95:   percentile(sorted, 95),
+		P99:   percentile(sorted, 99),
+	}
+}
+
+// percentile returns the nearest-rank percentile of an already sorted slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	index := int(math.Ceil(p/100.0*float64(len(sorted)))) - 1
+	// Ensure index is within bounds
+	if index < 0 {
+		index = 0
+	} else if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+
+	return sorted[index]
+}
+
+// printDurationSummary writes the summary statistics in a human readable form
+func printDurationSummary(w io.Writer, s DurationSummary) {
+	fmt.Fprintf(w, "  Min: %v\n", s.Min)
+	fmt.Fprintf(w, "  Mean: %v\n", s.Mean)
+	fmt.Fprintf(w, "  Median: %v\n", s.P50)
+	fmt.Fprintf(w, "  90th Percentile: %v\n", s.P90)
+	fmt.Fprintf(w, "  95th Percentile: %v\n", s.P95)
+	fmt.Fprintf(w, "  99th Percentile: %v\n", s.P99)
+	fmt.Fprintf(w, "  Max: %v\n", s.Max)
+}
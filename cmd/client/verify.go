@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Supported block ordering strategies for verification
+const (
+	OrderingPriority = "priority" // Transactions sorted by priority, high to low
+	OrderingNone     = "none"     // No intra-block ordering is verified
+)
+
+// maxReportedViolations limits the number of examples printed per violation kind
+const maxReportedViolations = 10
+
+// GetBlocksResult represents the result of the getBlocks method
+type GetBlocksResult struct {
+	Blocks []*BlockInfo `json:"blocks"`
+	Count  int          `json:"count"`
+}
+
+// blockSummary keeps the parts of a block needed for verification
+type blockSummary struct {
+	id         string
+	txIDs      []string
+	priorities []int
+}
+
+// BlockVerifier collects produced blocks and checks their invariants after a run
+type BlockVerifier struct {
+	ordering string
+
+	mu       sync.Mutex
+	blocks   map[string]*blockSummary
+	order    []string        // Block IDs in the order they were first seen
+	accepted map[string]bool // Transactions acknowledged by the server
+}
+
+// VerificationReport lists the invariant violations found in the produced blocks
+type VerificationReport struct {
+	Blocks       int
+	Transactions int
+	Accepted     int
+	NotIncluded  int
+
+	Duplicates         []string // Transactions included more than once
+	AcceptedDuplicates int      // Accepted transactions included more than once
+	OrderViolations    []string // Blocks whose transactions break the ordering strategy
+}
+
+// NewBlockVerifier creates a verifier for the given ordering strategy
+func NewBlockVerifier(ordering string) *BlockVerifier {
+	return &BlockVerifier{
+		ordering: ordering,
+		blocks:   make(map[string]*blockSummary),
+		accepted: make(map[string]bool),
+	}
+}
+
+// Accept records a transaction the server acknowledged
+func (v *BlockVerifier) Accept(txID string) {
+	if v == nil {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.accepted[txID] = true
+}
+
+// AddBlock records a produced block; blocks seen more than once are stored once
+func (v *BlockVerifier) AddBlock(block *BlockInfo) {
+	if v == nil {
+		return
+	}
+
+	summary := &blockSummary{
+		id:         block.ID,
+		txIDs:      make([]string, len(block.Transactions)),
+		priorities: make([]int, len(block.Transactions)),
+	}
+	for i, tx := range block.Transactions {
+		summary.txIDs[i] = tx.ID
+		summary.priorities[i] = tx.Priority
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, exists := v.blocks[block.ID]; exists {
+		return
+	}
+	v.blocks[block.ID] = summary
+	v.order = append(v.order, block.ID)
+}
+
+// FetchBlocks adds the blocks still retained by the server to the collected set
+func (v *BlockVerifier) FetchBlocks(serverURL string, authToken string) error {
+	if v == nil {
+		return nil
+	}
+
+	client, err := dialServer(serverURL, authToken)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the server: %v", err)
+	}
+	defer client.Close()
+
+	var result GetBlocksResult
+	if err := client.Call(&result, "flash_getBlocks"); err != nil {
+		return fmt.Errorf("RPC error: %v", err)
+	}
+
+	for _, block := range result.Blocks {
+		v.AddBlock(block)
+	}
+
+	return nil
+}
+
+// Verify checks the collected blocks for duplicate inclusions and ordering violations
+func (v *BlockVerifier) Verify() *VerificationReport {
+	if v == nil {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	report := &VerificationReport{
+		Blocks:   len(v.blocks),
+		Accepted: len(v.accepted),
+	}
+
+	inclusions := make(map[string]int)
+	for _, id := range v.order {
+		block := v.blocks[id]
+
+		for i, txID := range block.txIDs {
+			report.Transactions++
+			inclusions[txID]++
+			if inclusions[txID] == 2 {
+				report.Duplicates = append(report.Duplicates, txID)
+				if v.accepted[txID] {
+					report.AcceptedDuplicates++
+				}
+			}
+
+			if v.ordering == OrderingPriority && i > 0 && block.priorities[i-1] < block.priorities[i] {
+				report.OrderViolations = append(report.OrderViolations,
+					fmt.Sprintf("block %s: transaction %d (priority %d) follows priority %d", id, i, block.priorities[i], block.priorities[i-1]))
+			}
+		}
+	}
+
+	for txID := range v.accepted {
+		if inclusions[txID] == 0 {
+			report.NotIncluded++
+		}
+	}
+
+	return report
+}
+
+// Failed reports whether any invariant was violated
+func (r *VerificationReport) Failed() bool {
+	return r != nil && (len(r.Duplicates) > 0 || len(r.OrderViolations) > 0)
+}
+
+// printVerificationReport writes the block verification results
+func printVerificationReport(w io.Writer, r *VerificationReport) {
+	if r == nil {
+		return
+	}
+
+	fmt.Fprintln(w, "Block Verification:")
+	fmt.Fprintf(w, "  Blocks checked: %d\n", r.Blocks)
+	fmt.Fprintf(w, "  Transactions checked: %d\n", r.Transactions)
+	fmt.Fprintf(w, "  Accepted transactions not seen in any block: %d of %d\n", r.NotIncluded, r.Accepted)
+
+	printViolations(w, "Duplicate inclusions", r.Duplicates)
+	if r.AcceptedDuplicates > 0 {
+		fmt.Fprintf(w, "  Accepted transactions included more than once: %d\n", r.AcceptedDuplicates)
+	}
+	printViolations(w, "Ordering violations", r.OrderViolations)
+
+	if r.Failed() {
+		fmt.Fprintln(w, "  Result: FAIL")
+	} else {
+		fmt.Fprintln(w, "  Result: PASS")
+	}
+}
+
+// printViolations writes the number of violations and a few examples
+func printViolations(w io.Writer, title string, violations []string) {
+	fmt.Fprintf(w, "  %s: %d\n", title, len(violations))
+	for i, violation := range violations {
+		if i == maxReportedViolations {
+			fmt.Fprintf(w, "    ... and %d more\n", len(violations)-maxReportedViolations)
+			break
+		}
+		fmt.Fprintf(w, "    %s\n", violation)
+	}
+}
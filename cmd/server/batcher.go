@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"flashblock/internal/batcher"
+	"flashblock/internal/config"
+	"flashblock/internal/datadir"
+	"flashblock/internal/logging"
+	"flashblock/internal/p2p"
+	"flashblock/internal/processor"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newBatcher creates the batcher of the sealed blocks and, with an L1
+// endpoint, connects the submitter posting the batches. Artifacts are kept in
+// the data directory, if there is one.
+func newBatcher(cfg *config.Config, dataDir *datadir.DataDir, bp *processor.BlockProcessor) (*batcher.Batcher, error) {
+	var submitter batcher.Submitter
+	if cfg.Batcher.L1URL != "" {
+		keyPath := cfg.BatcherKeyFile()
+		if keyPath == "" {
+			logging.Warnf("No data directory for %s, the batch transaction key changes on every start", cfg.Batcher.KeyFile)
+		}
+		key, err := p2p.LoadOrCreateKey(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Batcher.Timeout)
+		defer cancel()
+		l1, err := batcher.NewL1(ctx, cfg.Batcher.L1URL, key, common.HexToAddress(cfg.Batcher.Inbox))
+		if err != nil {
+			return nil, err
+		}
+		submitter = l1
+		log.Printf("Posting batches to the L1 inbox %s from %s", cfg.Batcher.Inbox, l1.Sender().Hex())
+	}
+
+	var dir string
+	if dataDir != nil {
+		dir = dataDir.Join(datadir.BatchesDir)
+	}
+	b, err := batcher.New(bp, submitter, &batcher.Config{
+		Algorithm:    cfg.Batcher.Algorithm,
+		Interval:     cfg.Batcher.Interval,
+		MaxBlocks:    cfg.Batcher.MaxBlocks,
+		MaxFrameSize: cfg.Batcher.MaxFrameSize,
+		Dir:          dir,
+		Timeout:      cfg.Batcher.Timeout,
+		MaxRetries:   cfg.Batcher.MaxRetries,
+		RetryBackoff: cfg.Batcher.RetryBackoff,
+	})
+	if err != nil {
+		if submitter != nil {
+			submitter.Close()
+		}
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"flashblock/internal/config"
+	"flashblock/internal/datadir"
+	"flashblock/internal/genesis"
+	"flashblock/internal/version"
+)
+
+// runCheckConfig validates the configuration and prints the effective settings
+func runCheckConfig(name string, args []string) error {
+	cfg, err := config.Load(name, args)
+	if err != nil {
+		return err
+	}
+
+	// The genesis file is part of the configuration
+	if _, err := genesis.LoadOrDefault(cfg.Genesis.File); err != nil {
+		return err
+	}
+
+	data, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Configuration is valid")
+	os.Stdout.Write(data)
+	return nil
+}
+
+// runExportGenesis prints the effective genesis
+func runExportGenesis(name string, args []string) error {
+	var configPath, genesisPath, out string
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&configPath, "config", "", "Configuration file (YAML)")
+	fs.StringVar(&genesisPath, "genesis", "", "Genesis JSON file (overrides genesis.file)")
+	fs.StringVar(&out, "out", "", "Output file (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// The genesis file is resolved like in serve: flag > environment > config file
+	var loadArgs []string
+	if configPath != "" {
+		loadArgs = append(loadArgs, "--config", configPath)
+	}
+	if genesisPath != "" {
+		loadArgs = append(loadArgs, "--genesis", genesisPath)
+	}
+	cfg, err := config.Load(name, loadArgs)
+	if err != nil {
+		return err
+	}
+
+	g, err := genesis.LoadOrDefault(cfg.Genesis.File)
+	if err != nil {
+		return err
+	}
+
+	data, err := g.Marshal()
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(out, data, 0644)
+}
+
+// runDB runs the data directory subcommands
+func runDB(name string, args []string) error {
+	if len(args) == 0 || args[0] != "inspect" {
+		return fmt.Errorf("usage: %s inspect [--datadir DIR | --config FILE]", name)
+	}
+	name, args = name+" inspect", args[1:]
+
+	var configPath, dataDirPath string
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&configPath, "config", "", "Configuration file (YAML)")
+	fs.StringVar(&dataDirPath, "datadir", "", "Data directory (overrides datadir)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// The data directory is resolved like in serve: flag > environment > config file
+	var loadArgs []string
+	if configPath != "" {
+		loadArgs = append(loadArgs, "--config", configPath)
+	}
+	if dataDirPath != "" {
+		loadArgs = append(loadArgs, "--datadir", dataDirPath)
+	}
+	cfg, err := config.Load(name, loadArgs)
+	if err != nil {
+		return err
+	}
+	if cfg.DataDir == "" {
+		return fmt.Errorf("no data directory configured")
+	}
+
+	report, err := datadir.Inspect(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	report.Print(os.Stdout)
+	return nil
+}
+
+// runVersion prints the version
+func runVersion(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	fmt.Println(version.String())
+	return nil
+}
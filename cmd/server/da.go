@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"flashblock/internal/config"
+	"flashblock/internal/da"
+	"flashblock/internal/datadir"
+	"flashblock/internal/logging"
+	"flashblock/internal/metrics"
+	"flashblock/internal/p2p"
+	"flashblock/internal/processor"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// newDAPublisher connects to the configured data-availability layer and
+// creates the publisher of the sealed blocks. References are logged in the
+// data directory, if there is one.
+func newDAPublisher(cfg *config.Config, dataDir *datadir.DataDir, bp *processor.BlockProcessor, m *metrics.Metrics) (*da.Publisher, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DA.Timeout)
+	defer cancel()
+
+	var client da.Client
+	switch cfg.DA.Layer {
+	case config.DACelestia:
+		// The namespace was validated with the configuration
+		namespace, _ := hexutil.Decode(cfg.DA.Namespace)
+		celestia, err := da.NewCelestia(ctx, cfg.DA.URL, cfg.DA.AuthToken, namespace, cfg.DA.GasPrice)
+		if err != nil {
+			return nil, err
+		}
+		client = celestia
+		log.Printf("Publishing sealed blocks to Celestia namespace %s", cfg.DA.Namespace)
+
+	case config.DAEthereum:
+		keyPath := cfg.DAKeyFile()
+		if keyPath == "" {
+			logging.Warnf("No data directory for %s, the blob transaction key changes on every start", cfg.DA.KeyFile)
+		}
+		key, err := p2p.LoadOrCreateKey(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		var to common.Address
+		if cfg.DA.To != "" {
+			to = common.HexToAddress(cfg.DA.To)
+		}
+		ethereum, err := da.NewEthereum(ctx, cfg.DA.URL, key, to)
+		if err != nil {
+			return nil, err
+		}
+		client = ethereum
+		log.Printf("Publishing sealed blocks in blob transactions from %s", crypto.PubkeyToAddress(key.PublicKey).Hex())
+	}
+
+	var logPath, pendingDir string
+	if dataDir != nil {
+		logPath = dataDir.Join(datadir.DADir, "references.jsonl")
+		pendingDir = dataDir.Join(datadir.DADir, "pending")
+	}
+	publisher, err := da.New(bp, client, m, &da.Config{
+		BatchSize:     cfg.DA.BatchSize,
+		MaxBatchDelay: cfg.DA.MaxBatchDelay,
+		Compress:      cfg.DA.Compress,
+		Timeout:       cfg.DA.Timeout,
+		MaxRetries:    cfg.DA.MaxRetries,
+		RetryBackoff:  cfg.DA.RetryBackoff,
+		LogPath:       logPath,
+		PendingDir:    pendingDir,
+	})
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return publisher, nil
+}
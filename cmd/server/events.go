@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/metrics"
+)
+
+// eventQueueSize is the number of events of a kind waiting to be recorded
+const eventQueueSize = 256
+
+// recordEvents keeps the metrics, if m is not nil, and the block log up to
+// date with the events of a chain for the lifetime of the process. Blocks of
+// an additional chain are logged with its name.
+func recordEvents(bus *events.Bus, m *metrics.Metrics, logBlocks bool, chain string) {
+	admitted := make(chan events.TxAdmitted, eventQueueSize)
+	removed := make(chan events.TxRemoved, eventQueueSize)
+	sealed := make(chan events.BlockSealed, eventQueueSize)
+	attested := make(chan events.BlockAttested, eventQueueSize)
+	changed := make(chan events.ConfigChanged, eventQueueSize)
+	bus.TxAdmitted.Subscribe(admitted)
+	bus.TxRemoved.Subscribe(removed)
+	bus.BlockSealed.Subscribe(sealed)
+	bus.BlockAttested.Subscribe(attested)
+	bus.ConfigChanged.Subscribe(changed)
+
+	go func() {
+		for {
+			select {
+			case <-admitted:
+				if m != nil {
+					m.IncrementTransactionsReceived()
+				}
+			case ev := <-removed:
+				if m != nil && ev.Reason != events.RemovedIncluded {
+					m.AddTransactionsDropped(uint64(len(ev.IDs)))
+				}
+			case ev := <-sealed:
+				// Imported blocks were built, and are counted, by another node
+				if ev.Imported {
+					continue
+				}
+				if m != nil {
+					m.IncrementBlocksCreated()
+					m.IncrementTransactionsProcessed(uint64(len(ev.Block.Transactions)))
+					m.RecordBlockCreationTime(ev.BuildTime)
+					m.CalculateMetrics()
+				}
+				if logBlocks && chain != "" {
+					logging.Infof("Block created on chain %s: ID=%s, Transactions=%d, Creation Time=%v", chain, ev.Block.ID, len(ev.Block.Transactions), ev.BuildTime)
+				} else if logBlocks {
+					logging.Infof("Block created: ID=%s, Transactions=%d, Creation Time=%v", ev.Block.ID, len(ev.Block.Transactions), ev.BuildTime)
+				}
+			case <-attested:
+				if m != nil {
+					m.IncrementBlocksAttested()
+				}
+			case ev := <-changed:
+				if m != nil {
+					m.AddConfigChanges(uint64(len(ev.Changes)))
+				}
+			}
+		}
+	}()
+}
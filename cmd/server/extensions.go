@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+
+	"flashblock/internal/config"
+	"flashblock/pkg/extension"
+)
+
+// loadPlugins loads the configured Go plugins, which register their
+// extensions when they are opened
+func loadPlugins(cfg *config.Config) error {
+	for _, path := range cfg.Extensions.Plugins {
+		if err := extension.LoadPlugin(path); err != nil {
+			return err
+		}
+		log.Printf("Loaded plugin %s", path)
+	}
+	return nil
+}
+
+// newExtensions creates the configured validators and orderer. Every chain
+// gets its own instances, so extensions may keep state per chain.
+func newExtensions(cfg *config.Config) ([]extension.Validator, extension.Orderer, error) {
+	var validators []extension.Validator
+	for _, c := range cfg.Extensions.Validators {
+		v, err := extension.NewValidator(c.Name, c.Options)
+		if err != nil {
+			return nil, nil, err
+		}
+		validators = append(validators, v)
+	}
+
+	var orderer extension.Orderer
+	if name := cfg.Extensions.Orderer.Name; name != "" {
+		var err error
+		if orderer, err = extension.NewOrderer(name, cfg.Extensions.Orderer.Options); err != nil {
+			return nil, nil, err
+		}
+	}
+	return validators, orderer, nil
+}
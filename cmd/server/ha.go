@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"flashblock/internal/config"
+	"flashblock/internal/ha"
+	"flashblock/internal/mempool"
+	"flashblock/internal/processor"
+)
+
+// haNode switches the node between building blocks as the leader and
+// following the leader on standby
+type haNode struct {
+	elector  *ha.Elector
+	standby  *ha.Standby
+	fixedTTL bool // The lease TTL is configured instead of derived from the block interval
+	bp       *processor.BlockProcessor
+	mp       *mempool.Mempool
+	fail     func(error) // Stops the node after its chain diverged from the leader's
+
+	mu          sync.Mutex
+	stopFollow  context.CancelFunc // Stops following the current leader
+	followingWG sync.WaitGroup
+}
+
+// newHANode creates the active/standby controller. The node starts on standby
+// until it wins the election, and seals blocks only while its lease is valid.
+func newHANode(cfg *config.Config, bp *processor.BlockProcessor, mp *mempool.Mempool, fail func(error)) *haNode {
+	nodeID := cfg.HA.NodeID
+	if nodeID == "" {
+		hostname, _ := os.Hostname()
+		nodeID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	ttl := cfg.HA.LeaseTTL
+	if ttl == 0 {
+		ttl = 4 * cfg.Block.Interval
+	}
+
+	n := &haNode{
+		standby:  ha.NewStandby(bp, mp, remoteAuthToken(cfg), cfg.Block.Interval),
+		fixedTTL: cfg.HA.LeaseTTL != 0,
+		bp:       bp,
+		mp:       mp,
+		fail:     fail,
+	}
+	n.standby.SetMempoolSyncInterval(cfg.HA.MempoolSyncInterval)
+	n.elector = ha.NewElector(&ha.Config{
+		LeaseFile: cfg.HA.LeaseFile,
+		NodeID:    nodeID,
+		URL:       cfg.HA.AdvertiseURL,
+		TTL:       ttl,
+	}, n.onChange)
+
+	bp.SetFence(n.elector)
+	bp.SetPaused(true)
+	mp.SetReadOnly(true)
+
+	return n
+}
+
+// Run takes part in the election until the context is cancelled
+func (n *haNode) Run(ctx context.Context) {
+	n.elector.Run(ctx)
+	n.follow(nil)
+}
+
+// SetInterval follows a change of the block interval: the lease TTL, unless
+// configured, stays four intervals so a leader still renews it in time
+func (n *haNode) SetInterval(interval time.Duration) {
+	if !n.fixedTTL {
+		n.elector.SetTTL(4 * interval)
+	}
+}
+
+// onChange applies a role change
+func (n *haNode) onChange(role ha.Role, leader *ha.Lease) {
+	if role == ha.RoleLeader {
+		// Stop importing before building on top of the imported chain
+		n.follow(nil)
+		n.mp.SetReadOnly(false)
+		n.bp.SetPaused(false)
+		return
+	}
+
+	n.bp.SetPaused(true)
+	n.mp.SetReadOnly(true)
+	n.follow(leader)
+}
+
+// follow stops following the current leader and starts following the given one
+func (n *haNode) follow(leader *ha.Lease) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.stopFollow != nil {
+		n.stopFollow()
+		n.followingWG.Wait()
+		n.stopFollow = nil
+	}
+	if leader == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.stopFollow = cancel
+	n.followingWG.Add(1)
+	go func() {
+		defer n.followingWG.Done()
+		if err := n.standby.Run(ctx, leader.URL); err != nil {
+			n.fail(err)
+		}
+	}()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"flashblock/internal/config"
+	"flashblock/internal/datadir"
+	"flashblock/internal/genesis"
+	"flashblock/internal/logging"
+	"flashblock/internal/mempool"
+	"flashblock/internal/processor"
+	"flashblock/internal/rpc"
+	"flashblock/internal/state"
+	"flashblock/internal/store"
+	"flashblock/internal/txindex"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// lane is an additional chain served next to the primary chain. It shares
+// nothing with the other chains but the process and the RPC listeners.
+type lane struct {
+	name      string
+	state     *state.DB
+	mempool   *mempool.Mempool
+	processor *processor.BlockProcessor
+	store     *store.BlockStore
+	stop      context.CancelFunc
+	done      chan struct{}
+}
+
+// openLanes opens the configured additional chains. Every chain, including
+// the primary one, must have its own chain ID.
+func openLanes(cfg *config.Config, dataDir *datadir.DataDir, primaryChainID uint64) ([]*lane, error) {
+	chainIDs := map[uint64]string{primaryChainID: "the primary chain"}
+
+	var lanes []*lane
+	for _, chain := range cfg.Chains {
+		l, err := openLane(cfg, chain, dataDir)
+		if err != nil {
+			closeLanes(lanes)
+			return nil, fmt.Errorf("chain %s: %v", chain.Name, err)
+		}
+		chainID := l.state.ChainID()
+		if other, ok := chainIDs[chainID]; ok {
+			closeLanes(append(lanes, l))
+			return nil, fmt.Errorf("chain %s has chain ID %d like %s", chain.Name, chainID, other)
+		}
+		chainIDs[chainID] = "chain " + chain.Name
+		lanes = append(lanes, l)
+	}
+	return lanes, nil
+}
+
+// openLane creates the account state, mempool, block processor and block
+// store of an additional chain. A chain stored in the data directory is
+// restored like the primary chain.
+func openLane(cfg *config.Config, chain config.ChainConfig, dataDir *datadir.DataDir) (_ *lane, err error) {
+	g, err := genesis.LoadOrDefault(chain.Genesis)
+	if err != nil {
+		return nil, err
+	}
+	if chain.ChainID != 0 {
+		g.ChainID = chain.ChainID
+	}
+
+	// Settings left at zero follow the primary chain
+	interval := chain.Interval
+	if interval == 0 {
+		interval = cfg.Block.Interval
+	}
+	maxStoredBlocks := chain.MaxStoredBlocks
+	if maxStoredBlocks == 0 {
+		maxStoredBlocks = cfg.Block.MaxStoredBlocks
+	}
+
+	var dir string
+	if dataDir != nil {
+		if dir, err = dataDir.Chain(chain.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	l := &lane{name: chain.Name, done: make(chan struct{})}
+	defer func() {
+		if err != nil {
+			l.close()
+		}
+	}()
+
+	var statePath string
+	if dir != "" {
+		statePath = filepath.Join(dir, datadir.StateDir)
+	}
+	if l.state, err = state.Open(statePath, g); err != nil {
+		return nil, err
+	}
+
+	validators, orderer, err := newExtensions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	l.mempool = mempool.New(&mempool.Config{
+		MaxSize:       chain.MempoolMaxSize,
+		MinPriority:   cfg.Mempool.MinPriority,
+		Blacklist:     cfg.Mempool.Blacklist,
+		PrivateTTL:    cfg.Mempool.PrivateTTL,
+		MaxPrivateTTL: cfg.Mempool.MaxPrivateTTL,
+		Validate:      l.state.Validate,
+		Validators:    validators,
+	})
+	// The chain has an event bus of its own, created with its mempool
+	recordEvents(l.mempool.Events(), nil, cfg.Log.Blocks, chain.Name)
+
+	index, err := txindex.Open(l.state.KeyValueStore())
+	if err != nil {
+		return nil, err
+	}
+	processorConfig := &processor.Config{
+		Interval:        interval,
+		MaxStoredBlocks: maxStoredBlocks,
+		MaxTransactions: chain.MaxTransactions,
+		State:           l.state,
+		FeeRecipient:    common.HexToAddress(cfg.Block.FeeRecipient),
+		Index:           index,
+		Orderer:         orderer,
+	}
+
+	if dir != "" {
+		var info *store.RecoveryInfo
+		l.store, info, err = store.OpenBlockStore(filepath.Join(dir, datadir.BlocksDir, "blocks.jsonl"), store.OpenOptions{
+			Recent: maxStoredBlocks,
+			Repair: cfg.Repair,
+			Index:  index,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if info.TruncatedBytes > 0 {
+			logging.Warnf("Repaired block store of chain %s: truncated %d bytes of an incomplete record", chain.Name, info.TruncatedBytes)
+		}
+		if info.Reindexed {
+			logging.Warnf("Rebuilt transaction index of chain %s: it did not match the block store", chain.Name)
+		}
+		processorConfig.Store = l.store
+	}
+
+	l.processor = processor.New(l.mempool, processorConfig)
+	if l.store != nil {
+		recent := l.store.Recent()
+		l.processor.Restore(recent)
+		if _, err = l.state.Restore(recent); err != nil {
+			return nil, err
+		}
+	}
+
+	_, head := l.processor.LatestBlock()
+	log.Printf("Chain %s initialized: chain ID %d, block interval %v, head at block %d", chain.Name, g.ChainID, interval, head)
+	return l, nil
+}
+
+// start starts building the blocks of the lane
+func (l *lane) start() {
+	var ctx context.Context
+	ctx, l.stop = context.WithCancel(context.Background())
+	go func() {
+		l.processor.Start(ctx)
+		close(l.done)
+	}()
+}
+
+// rpcLane returns the components of the lane served by the RPC server
+func (l *lane) rpcLane() rpc.Lane {
+	return rpc.Lane{
+		Name:      l.name,
+		Mempool:   l.mempool,
+		Processor: l.processor,
+		State:     l.state,
+	}
+}
+
+// close stops accepting transactions, waits for the block build in progress
+// and flushes the block store and account state
+func (l *lane) close() error {
+	if l.mempool != nil {
+		l.mempool.Close()
+	}
+	if l.stop != nil {
+		l.stop()
+		<-l.done
+	}
+
+	var err error
+	if l.store != nil {
+		err = l.store.Close()
+	}
+	if l.state != nil {
+		if closeErr := l.state.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// closeLanes closes every lane, returning the first error
+func closeLanes(lanes []*lane) error {
+	var err error
+	for _, l := range lanes {
+		if closeErr := l.close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("chain %s: %v", l.name, closeErr)
+		}
+	}
+	return err
+}
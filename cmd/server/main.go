@@ -1,119 +1,75 @@
 package main
 
 import (
-	"context"
-	"flag"
-	"io"
-	"log"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	"flashblock/internal/mempool"
-	"flashblock/internal/metrics"
-	"flashblock/internal/model"
-	"flashblock/internal/processor"
-	"flashblock/internal/rpc"
+	"path/filepath"
+	"strings"
 )
 
-func main() {
-	// Parse command line flags
-	var (
-		rpcAddr        = flag.String("rpc-addr", ":8080", "JSON-RPC server address")
-		blockInterval  = flag.Duration("block-interval", 250*time.Millisecond, "Block creation interval")
-		logBlockEvents = flag.Bool("log-blocks", true, "Log block creation events")
-		logFile        = flag.String("log-file", "logs/flashblock.log", "Log file path")
-		enableTDXQuote = flag.Bool("enable-tdx-quote", true, "Enable TDX attestation quote generation for blocks")
-	)
-	flag.Parse()
-
-	// Set up logger to write to both file and stdout
-	f, err := os.OpenFile(*logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalf("Error opening log file: %v", err)
-	}
-	defer f.Close()
+// command is a server subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(name string, args []string) error
+}
 
-	// Create a multi writer for both stdout and log file
-	multiWriter := io.MultiWriter(os.Stdout, f)
-	log.SetOutput(multiWriter)
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
-	log.Println("Starting FlashBlock server...")
+// defaultCommand runs when no subcommand is given, so plain flags keep starting the server
+const defaultCommand = "serve"
 
-	// Create metrics
-	m := metrics.New()
-	log.Println("Metrics initialized")
+// commands lists the available subcommands
+var commands = []*command{
+	{name: "serve", summary: "Run the block builder and JSON-RPC server", run: runServe},
+	{name: "check-config", summary: "Validate the configuration and print the effective settings", run: runCheckConfig},
+	{name: "db", summary: "Inspect the data directory (db inspect)", run: runDB},
+	{name: "export-genesis", summary: "Print the effective genesis as JSON", run: runExportGenesis},
+	{name: "version", summary: "Print the version", run: runVersion},
+}
 
-	// Create mempool
-	mp := mempool.New()
-	log.Println("Mempool initialized")
+func main() {
+	program := filepath.Base(os.Args[0])
+	args := os.Args[1:]
 
-	// Create block processor
-	processorConfig := &processor.Config{
-		Interval:       *blockInterval,
-		EnableTDXQuote: *enableTDXQuote,
+	// The first argument selects the subcommand unless it is a flag
+	name := defaultCommand
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	// Add block callback if logging is enabled
-	if *logBlockEvents {
-		processorConfig.BlockCallback = func(block *model.Block, blockCreationTime time.Duration) {
-			m.IncrementBlocksCreated()
-			m.IncrementTransactionsProcessed(uint64(len(block.Transactions)))
-			m.RecordBlockCreationTime(blockCreationTime)
-			m.CalculateMetrics()
-			log.Printf("Block created: ID=%s, Transactions=%d, Creation Time=%v", block.ID, len(block.Transactions), blockCreationTime)
-		}
+	if name == "help" {
+		printUsage(program)
+		return
 	}
 
-	bp := processor.New(mp, processorConfig)
-	log.Printf("Block processor initialized with interval: %v", *blockInterval)
-
-	if *enableTDXQuote {
-		log.Println("TDX quote generation is enabled")
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage(program)
+		os.Exit(2)
 	}
 
-	// Create JSON-RPC server with metrics
-	rpcServer := rpc.NewServer(mp, *rpcAddr)
-	log.Printf("JSON-RPC server initialized with address: %s", *rpcAddr)
-
-	// Set the processor reference in the RPC server
-	rpcServer.SetProcessor(bp)
-
-	// Add transaction hook to track metrics
-	rpcServer.AddTransactionHook(func(tx *model.Transaction, added bool) {
-		m.IncrementTransactionsReceived()
-		if !added {
-			m.IncrementTransactionsRejected()
-		}
-	})
-
-	// Create context that can be cancelled
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Start block processor in a goroutine
-	go bp.Start(ctx)
+	if err := cmd.run(program+" "+cmd.name, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
 
-	// Start JSON-RPC server in a goroutine
-	go func() {
-		if err := rpcServer.Start(ctx); err != nil {
-			log.Fatalf("JSON-RPC server error: %v", err)
+// findCommand returns the subcommand with the given name, or nil if there is none
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
 		}
-	}()
-
-	log.Println("System is ready. Press Ctrl+C to stop.")
-
-	// Wait for interrupt signal
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
-
-	// Shutdown gracefully
-	log.Println("Shutting down...")
-	cancel()
+	}
+	return nil
+}
 
-	// Give some time for goroutines to finish
-	time.Sleep(1 * time.Second)
-	log.Println("Server stopped")
+// printUsage writes the list of subcommands
+func printUsage(program string) {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", program)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "  %-16s %s\n", "help", "Show this message")
+	fmt.Fprintf(os.Stderr, "\nThe default command is %q. Run '%s <command> -h' for the flags of a command.\n", defaultCommand, program)
 }
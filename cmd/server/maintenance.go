@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/mempool"
+	"flashblock/internal/processor"
+	"flashblock/internal/rpc/admin"
+)
+
+// maintenanceMode rejects submissions and optionally holds block production
+// while an operator performs an upgrade or handles an incident
+type maintenanceMode struct {
+	mu        sync.Mutex
+	status    admin.MaintenanceStatus
+	mempool   *mempool.Mempool
+	processor *processor.BlockProcessor
+}
+
+// newMaintenanceMode creates the maintenance controller; the node starts in normal operation
+func newMaintenanceMode(mp *mempool.Mempool, bp *processor.BlockProcessor) *maintenanceMode {
+	return &maintenanceMode{
+		mempool:   mp,
+		processor: bp,
+	}
+}
+
+// Maintenance returns the current maintenance mode
+func (m *maintenanceMode) Maintenance() admin.MaintenanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.status
+}
+
+// SetMaintenance applies the given maintenance mode and audit-logs the change
+func (m *maintenanceMode) SetMaintenance(status admin.MaintenanceStatus, caller string) admin.MaintenanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Keep the original start time when only the options change
+	status.Since = nil
+	if status.Enabled {
+		since := time.Now()
+		if m.status.Enabled {
+			since = *m.status.Since
+		}
+		status.Since = &since
+	}
+
+	m.mempool.SetMaintenance(status.Enabled, status.Reason)
+	m.processor.SetHeld(status.PauseBlocks)
+
+	switch {
+	case status.Enabled:
+		logging.Infof("AUDIT: maintenance mode entered by %s (pause blocks: %v, reason: %q)", caller, status.PauseBlocks, status.Reason)
+	case m.status.Enabled:
+		logging.Infof("AUDIT: maintenance mode left by %s after %v", caller, time.Since(*m.status.Since).Round(time.Millisecond))
+	}
+
+	m.status = status
+	return status
+}
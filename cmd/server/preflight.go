@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"flashblock/internal/attest"
+	"flashblock/internal/config"
+	"flashblock/internal/datadir"
+	"flashblock/internal/genesis"
+	"flashblock/internal/store"
+	"flashblock/internal/version"
+)
+
+// minAuthTokenLength is the shortest auth token that is not reported as weak
+const minAuthTokenLength = 16
+
+// errSkipped marks a check that does not apply to the configuration
+var errSkipped = errors.New("skipped")
+
+// checkResult is the outcome of a single preflight check
+type checkResult struct {
+	status   string // OK, WARN, FAIL or SKIP
+	name     string
+	detail   string
+	warnings []string
+}
+
+// preflightCheck is one named check of the preflight report
+type preflightCheck struct {
+	name string
+	run  func(cfg *config.Config) (detail string, warnings []string, err error)
+}
+
+// preflightChecks lists the checks in the order they are run
+var preflightChecks = []preflightCheck{
+	{"configuration", checkConfiguration},
+	{"attestation", checkAttestation},
+	{"ports", checkPorts},
+	{"storage", checkStorage},
+	{"key material", checkKeyMaterial},
+}
+
+// runPreflight runs all preflight checks, prints the report and fails if any check failed
+func runPreflight(cfg *config.Config) error {
+	results := make([]checkResult, 0, len(preflightChecks))
+	for _, check := range preflightChecks {
+		detail, warnings, err := check.run(cfg)
+
+		result := checkResult{status: "OK", name: check.name, detail: detail, warnings: warnings}
+		switch {
+		case errors.Is(err, errSkipped):
+			result.status = "SKIP"
+		case err != nil:
+			result.status = "FAIL"
+			result.detail = err.Error()
+		case len(warnings) > 0:
+			result.status = "WARN"
+		}
+		results = append(results, result)
+	}
+
+	failed := printPreflightReport(os.Stdout, results)
+	if failed > 0 {
+		return fmt.Errorf("preflight check failed: %d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// printPreflightReport writes the results and returns the number of failed checks
+func printPreflightReport(w io.Writer, results []checkResult) int {
+	fmt.Fprintf(w, "Preflight check for %s\n\n", version.String())
+
+	failed := 0
+	for _, r := range results {
+		fmt.Fprintf(w, "  [%-4s] %-13s %s\n", r.status, r.name, r.detail)
+		for _, warning := range r.warnings {
+			fmt.Fprintf(w, "         %-13s warning: %s\n", "", warning)
+		}
+		if r.status == "FAIL" {
+			failed++
+		}
+	}
+
+	fmt.Fprintln(w)
+	if failed == 0 {
+		fmt.Fprintln(w, "All checks passed")
+	}
+	return failed
+}
+
+// checkConfiguration reports the validated configuration and loads the genesis
+func checkConfiguration(cfg *config.Config) (string, []string, error) {
+	// The configuration itself was validated when it was loaded
+	g, err := genesis.LoadOrDefault(cfg.Genesis.File)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var warnings []string
+	if len(cfg.RPC.AuthTokens) == 0 {
+		warnings = append(warnings, "rpc.auth_tokens is empty, the JSON-RPC API is not authenticated")
+	}
+	if cfg.DataDir == "" {
+		warnings = append(warnings, "datadir is not set, blocks and the mempool are not persisted")
+	}
+
+	return fmt.Sprintf("valid (chain ID %d, block interval %v)", g.ChainID, cfg.Block.Interval), warnings, nil
+}
+
+// checkAttestation verifies that the attestation provider can produce a quote
+func checkAttestation(cfg *config.Config) (string, []string, error) {
+	if !cfg.Attestation.Enabled {
+		return "disabled", nil, errSkipped
+	}
+	if !cfg.Role.BuildsBlocks() {
+		return "not used by nodes that do not build blocks", nil, errSkipped
+	}
+
+	provider, err := attest.NewTDXProvider()
+	if err != nil {
+		return "", nil, err
+	}
+
+	quote, err := provider.GetQuote([]byte("flashblock preflight"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("%s provider produced a %d byte quote", cfg.Attestation.Provider, len(quote)), nil, nil
+}
+
+// checkPorts verifies that the configured listen addresses and unix socket are available
+func checkPorts(cfg *config.Config) (string, []string, error) {
+	addrs := []string{cfg.RPC.Addr}
+	if cfg.RPC.WSAddr != "" {
+		addrs = append(addrs, cfg.RPC.WSAddr)
+	}
+	if cfg.RPC.AdminAddr != "" {
+		addrs = append(addrs, cfg.RPC.AdminAddr)
+	}
+	if cfg.Flashblocks.Addr != "" {
+		addrs = append(addrs, cfg.Flashblocks.Addr)
+	}
+	if cfg.P2P.Enabled {
+		addrs = append(addrs, cfg.P2P.ListenAddr)
+	}
+
+	for _, addr := range addrs {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return "", nil, fmt.Errorf("cannot listen on %s: %v", addr, err)
+		}
+		ln.Close()
+	}
+
+	if path := cfg.RPC.UnixSocket; path != "" {
+		// A socket that accepts connections belongs to a running server
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return "", nil, fmt.Errorf("unix socket %s is in use", path)
+		}
+		if err := probeWritable(filepath.Dir(path)); err != nil {
+			return "", nil, fmt.Errorf("unix socket directory: %v", err)
+		}
+		addrs = append(addrs, path)
+	}
+
+	return fmt.Sprintf("available: %v", addrs), nil, nil
+}
+
+// checkStorage verifies that the data directory, block store and log file are writable
+func checkStorage(cfg *config.Config) (string, []string, error) {
+	var detail string
+	if cfg.DataDir != "" {
+		// Opening the data directory also verifies that no other instance uses it
+		dataDir, err := datadir.Open(cfg.DataDir)
+		if err != nil {
+			return "", nil, err
+		}
+		defer dataDir.Close()
+
+		for _, dir := range []string{datadir.BlocksDir, datadir.MempoolDir, datadir.LogsDir, datadir.StateDir, datadir.DADir, datadir.BlobsDir, datadir.BatchesDir, datadir.IndexDir} {
+			if err := probeWritable(dataDir.Join(dir)); err != nil {
+				return "", nil, err
+			}
+		}
+
+		// Verify the stored chain without repairing it
+		blocks, info, err := store.OpenBlockStore(dataDir.Join(datadir.BlocksDir, "blocks.jsonl"), store.OpenOptions{})
+		if err != nil {
+			return "", nil, err
+		}
+		blocks.Close()
+
+		detail = fmt.Sprintf("data directory %s writable, %d stored blocks verified, ", dataDir.Path(), info.Blocks)
+	}
+
+	logFile := cfg.LogFile()
+	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return "", nil, fmt.Errorf("log file: %v", err)
+	}
+	f.Close()
+
+	return detail + fmt.Sprintf("log file %s writable", logFile), nil, nil
+}
+
+// checkKeyMaterial verifies the permissions of the key directory and the strength of the auth tokens
+func checkKeyMaterial(cfg *config.Config) (string, []string, error) {
+	var warnings []string
+	for i, token := range cfg.RPC.AuthTokens {
+		if len(token) < minAuthTokenLength {
+			warnings = append(warnings, fmt.Sprintf("auth token %d is shorter than %d characters", i+1, minAuthTokenLength))
+		}
+	}
+
+	if cfg.DataDir == "" {
+		if len(cfg.RPC.AuthTokens) == 0 {
+			return "no data directory or auth tokens", nil, errSkipped
+		}
+		return fmt.Sprintf("%d auth tokens", len(cfg.RPC.AuthTokens)), warnings, nil
+	}
+
+	keysDir := filepath.Join(cfg.DataDir, datadir.KeysDir)
+	info, err := os.Stat(keysDir)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", nil, fmt.Errorf("%s is accessible by other users (mode %#o)", keysDir, info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(keysDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Key files must be readable by the server and private to it
+	for _, entry := range entries {
+		path := filepath.Join(keysDir, entry.Name())
+		if entry.IsDir() {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("key file: %v", err)
+		}
+		fi, err := f.Stat()
+		f.Close()
+		if err != nil {
+			return "", nil, fmt.Errorf("key file: %v", err)
+		}
+		if fi.Mode().Perm()&0077 != 0 {
+			return "", nil, fmt.Errorf("key file %s is accessible by other users (mode %#o)", path, fi.Mode().Perm())
+		}
+	}
+
+	return fmt.Sprintf("%s private, %d key files, %d auth tokens", keysDir, len(entries), len(cfg.RPC.AuthTokens)), warnings, nil
+}
+
+// probeWritable creates, syncs and removes a file in dir
+func probeWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".preflight-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %v", dir, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.WriteString("flashblock"); err != nil {
+		return fmt.Errorf("%s is not writable: %v", dir, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync in %s: %v", dir, err)
+	}
+	return nil
+}
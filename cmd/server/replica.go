@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+
+	"flashblock/internal/attest"
+	"flashblock/internal/config"
+)
+
+// newReplicaVerifier creates the verifier of the blocks a replica imports
+// from the attestation policy
+func newReplicaVerifier(cfg *config.Config) (*attest.Verifier, error) {
+	p := cfg.Attestation.Policy
+	policy := &attest.Policy{
+		RequireQuote:       p.RequireQuote,
+		CheckCollateral:    p.CheckCollateral,
+		RequireTrustedTime: p.RequireTrustedTime,
+		MaxTimeUncertainty: p.MaxTimeUncertainty,
+	}
+
+	measurements := []struct {
+		value string
+		size  int
+		dst   *[]byte
+	}{
+		{p.MrTd, config.MeasurementSize, &policy.MrTd},
+		{p.MrSeam, config.MeasurementSize, &policy.MrSeam},
+		{p.MrConfigID, config.MeasurementSize, &policy.MrConfigID},
+		{p.MrOwner, config.MeasurementSize, &policy.MrOwner},
+		{p.TdAttributes, config.TdAttributesSize, &policy.TdAttributes},
+	}
+	for _, m := range measurements {
+		value, err := config.ParseMeasurement(m.value, m.size)
+		if err != nil {
+			return nil, err
+		}
+		*m.dst = value
+	}
+	for _, rtmr := range p.Rtmrs {
+		value, err := config.ParseMeasurement(rtmr, config.MeasurementSize)
+		if err != nil {
+			return nil, err
+		}
+		policy.Rtmrs = append(policy.Rtmrs, value)
+	}
+
+	log.Printf("Replica requires an attestation quote with MRTD %x on every block", policy.MrTd)
+	if policy.RequireTrustedTime {
+		log.Println("Replica requires a trusted timestamp on every block")
+	}
+	return attest.NewVerifier(policy), nil
+}
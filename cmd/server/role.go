@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"flashblock/internal/config"
+	"flashblock/internal/ha"
+	"flashblock/internal/ingest"
+	"flashblock/internal/mempool"
+	"flashblock/internal/processor"
+)
+
+// runRole connects a role-split node to its remote counterparts until the
+// context is cancelled: RPC and replica nodes follow the blocks of their
+// builder, and a builder consumes the mempool feeds of its RPC nodes. Followers
+// whose chain diverged from the builder's call fail.
+func runRole(ctx context.Context, cfg *config.Config, bp *processor.BlockProcessor, mp *mempool.Mempool, fail func(error)) {
+	authToken := remoteAuthToken(cfg)
+
+	switch cfg.Role.Mode {
+	case config.RoleRPC, config.RoleReplica:
+		// Transactions reach the builder through its subscription to this node's
+		// mempool feed; imported blocks remove them from the local mempool. Without
+		// a builder URL, blocks are only received from p2p peers. Replicas follow
+		// the builder the same way but accept no transactions.
+		if cfg.Role.BuilderURL == "" {
+			return
+		}
+		if err := ha.NewFollower(bp, mp, authToken, cfg.Block.Interval).Run(ctx, cfg.Role.BuilderURL); err != nil {
+			fail(err)
+		}
+
+	case config.RoleBuilder:
+		feed := ingest.NewFeed(mp, bp, authToken, cfg.Block.Interval)
+
+		var wg sync.WaitGroup
+		for _, url := range cfg.Role.MempoolFeeds {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				feed.Run(ctx, url)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// remoteAuthToken returns the bearer token used to connect to other nodes, which
+// share the auth tokens of this node
+func remoteAuthToken(cfg *config.Config) string {
+	if len(cfg.RPC.AuthTokens) > 0 {
+		return cfg.RPC.AuthTokens[0]
+	}
+	return ""
+}
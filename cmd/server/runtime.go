@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"flashblock/internal/config"
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/mempool"
+	"flashblock/internal/processor"
+	"flashblock/internal/rpc"
+)
+
+// runtimeConfig owns the active configuration and applies runtime-tunable
+// settings to the running components
+type runtimeConfig struct {
+	mu        sync.Mutex
+	cfg       *config.Config
+	name      string   // Command name used in flag parse errors
+	args      []string // Command line flags used to rebuild the configuration on reload
+	rpcServer *rpc.Server
+	mempool   *mempool.Mempool
+	processor *processor.BlockProcessor
+	ha        *haNode     // Leader election, nil unless active/standby mode is enabled
+	events    *events.Bus // Receives the applied changes
+}
+
+// newRuntimeConfig creates the holder for the active configuration
+func newRuntimeConfig(cfg *config.Config, name string, args []string, rpcServer *rpc.Server, mp *mempool.Mempool, bp *processor.BlockProcessor, ha *haNode, bus *events.Bus) *runtimeConfig {
+	return &runtimeConfig{
+		cfg:       cfg,
+		name:      name,
+		args:      args,
+		rpcServer: rpcServer,
+		mempool:   mp,
+		processor: bp,
+		ha:        ha,
+		events:    bus,
+	}
+}
+
+// Current returns a copy of the active configuration
+func (r *runtimeConfig) Current() config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return *r.cfg
+}
+
+// Reload re-reads the configuration (file, environment and flags) and applies the
+// runtime-tunable settings. Invalid configurations are rejected without applying anything.
+func (r *runtimeConfig) Reload() {
+	logging.Infof("Reloading configuration...")
+
+	next, err := config.Load(r.name, r.args)
+	if err == nil {
+		err = checkWatchdogInterval(next.Block.Interval)
+	}
+	if err != nil {
+		logging.Errorf("Configuration reload rejected: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changes := config.Diff(r.cfg, next)
+	if len(changes) == 0 {
+		logging.Infof("Configuration reloaded: no changes")
+		return
+	}
+
+	applied := *r.cfg
+	applied.ApplyReloadable(next)
+
+	var appliedChanges []config.Change
+	for _, change := range changes {
+		if change.Reloadable() {
+			logging.Infof("Configuration change applied: %s", change)
+			appliedChanges = append(appliedChanges, change)
+		} else {
+			logging.Warnf("Configuration change ignored (requires restart): %s", change)
+		}
+	}
+
+	r.apply(&applied)
+	r.cfg = &applied
+	if len(appliedChanges) > 0 {
+		r.events.Publish(events.ConfigChanged{Changes: appliedChanges})
+	}
+}
+
+// SetConfig changes admin-settable settings given by YAML path. The values are
+// validated together and either all or none are applied; every applied change
+// is audit-logged with the caller.
+func (r *runtimeConfig) SetConfig(values map[string]string, caller string) ([]config.Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Settings are applied in a stable order so errors are reproducible
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	next := *r.cfg
+	for _, key := range keys {
+		if !config.IsSettable(key) {
+			return nil, fmt.Errorf("%s cannot be changed at runtime", key)
+		}
+		if err := next.Set(key, values[key]); err != nil {
+			return nil, err
+		}
+	}
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkWatchdogInterval(next.Block.Interval); err != nil {
+		return nil, err
+	}
+
+	changes := config.Diff(r.cfg, &next)
+	for _, change := range changes {
+		logging.Infof("AUDIT: configuration change by %s: %s", caller, change)
+	}
+
+	r.apply(&next)
+	r.cfg = &next
+	if len(changes) > 0 {
+		r.events.Publish(events.ConfigChanged{Changes: changes})
+	}
+
+	return changes, nil
+}
+
+// apply pushes the runtime-tunable settings to the components
+func (r *runtimeConfig) apply(cfg *config.Config) {
+	// The level was validated with the configuration
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logging.SetLevel(level)
+
+	r.rpcServer.SetCORSOrigins(cfg.RPC.CORSOrigins)
+	r.rpcServer.SetRateLimit(cfg.RPC.RateLimit.RPS, cfg.RPC.RateLimit.Burst)
+	r.mempool.SetAdmissionRules(cfg.Mempool.MinPriority, cfg.Mempool.Blacklist)
+	r.processor.SetInterval(cfg.Block.Interval)
+	if r.ha != nil {
+		r.ha.SetInterval(cfg.Block.Interval)
+	}
+	r.processor.SetMaxTransactions(cfg.Block.MaxTransactions)
+}
@@ -0,0 +1,813 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"flashblock/internal/batcher"
+	"flashblock/internal/blobs"
+	"flashblock/internal/bundle"
+	"flashblock/internal/chaos"
+	"flashblock/internal/config"
+	"flashblock/internal/da"
+	"flashblock/internal/datadir"
+	"flashblock/internal/encrypted"
+	"flashblock/internal/events"
+	"flashblock/internal/feemarket"
+	"flashblock/internal/flashblocks"
+	"flashblock/internal/genesis"
+	"flashblock/internal/indexer"
+	"flashblock/internal/logging"
+	"flashblock/internal/mempool"
+	"flashblock/internal/metrics"
+	"flashblock/internal/p2p"
+	"flashblock/internal/preconf"
+	"flashblock/internal/processor"
+	"flashblock/internal/relay"
+	"flashblock/internal/rpc"
+	"flashblock/internal/shedding"
+	"flashblock/internal/signer"
+	"flashblock/internal/state"
+	"flashblock/internal/store"
+	"flashblock/internal/systemd"
+	"flashblock/internal/trustedtime"
+	"flashblock/internal/txindex"
+	"flashblock/internal/version"
+	"flashblock/internal/wal"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// runServe runs the block builder and the JSON-RPC server until interrupted
+func runServe(name string, args []string) error {
+	// Load configuration from defaults, config file and command line flags
+	cfg, err := config.Load(name, args)
+	if err != nil {
+		return err
+	}
+
+	// Deployment pipelines verify the environment without starting the server
+	if cfg.Check {
+		return runPreflight(cfg)
+	}
+
+	// systemd restarts a server that builds blocks less often than its watchdog expects
+	if err := checkWatchdogInterval(cfg.Block.Interval); err != nil {
+		return err
+	}
+
+	// Lock the data directory so a second instance cannot use the same state
+	var dataDir *datadir.DataDir
+	if cfg.DataDir != "" {
+		dataDir, err = datadir.Open(cfg.DataDir)
+		if err != nil {
+			return err
+		}
+		defer dataDir.Close()
+	}
+
+	// Set up logger to write to both file and stdout; the file is reopened on SIGUSR1
+	logFile, err := logging.OpenFile(cfg.LogFile())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	// Create a multi writer for both stdout and log file
+	multiWriter := io.MultiWriter(os.Stdout, logFile)
+	log.SetOutput(multiWriter)
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+
+	// The level was validated with the configuration
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logging.SetLevel(level)
+
+	log.Printf("Starting %s...", version.String())
+	if dataDir != nil {
+		log.Printf("Using data directory %s", dataDir.Path())
+	}
+
+	// Create metrics
+	m := metrics.New()
+	log.Println("Metrics initialized")
+
+	// The mempool, block processor and runtime configuration publish to the
+	// event bus; metrics and the block log are recorded from it
+	bus := events.NewBus()
+	recordEvents(bus, m, cfg.Log.Blocks, "")
+
+	// Faults are only injected when explicitly requested on the command line
+	var faults *chaos.Injector
+	if cfg.Chaos.Enabled {
+		faults = chaos.New(&chaos.Config{
+			BlockLatency:     cfg.Chaos.BlockLatency,
+			DropRate:         cfg.Chaos.DropRate,
+			AttestationDelay: cfg.Chaos.AttestationDelay,
+		})
+		logging.Warnf("Fault injection is enabled (%s); do not use this node in production", faults)
+	}
+
+	// The account state starts at the genesis and follows the chain
+	g, err := genesis.LoadOrDefault(cfg.Genesis.File)
+	if err != nil {
+		return err
+	}
+	var statePath string
+	if dataDir != nil {
+		statePath = dataDir.Join(datadir.StateDir)
+	}
+	stateDB, err := state.Open(statePath, g)
+	if err != nil {
+		return err
+	}
+	log.Printf("Account state initialized: chain ID %d, %d genesis accounts, gas limit %d, genesis root %s", g.ChainID, len(g.Alloc), g.GasLimit, stateDB.GenesisRoot().Hex())
+
+	// Extensions add admission rules and replace the transaction order
+	if err := loadPlugins(cfg); err != nil {
+		return err
+	}
+	validators, orderer, err := newExtensions(cfg)
+	if err != nil {
+		return err
+	}
+	if len(validators) > 0 || orderer != nil {
+		log.Printf("Extensions enabled: %d validators, orderer %q", len(validators), cfg.Extensions.Orderer.Name)
+	}
+
+	// Create mempool; new transactions are validated against the account state
+	mp := mempool.New(&mempool.Config{
+		MaxSize:       cfg.Mempool.MaxSize,
+		MinPriority:   cfg.Mempool.MinPriority,
+		Blacklist:     cfg.Mempool.Blacklist,
+		PrivateTTL:    cfg.Mempool.PrivateTTL,
+		MaxPrivateTTL: cfg.Mempool.MaxPrivateTTL,
+		Chaos:         faults,
+		Validate:      stateDB.Validate,
+		Validators:    validators,
+		Journal: wal.Config{
+			Sync:        cfg.Mempool.Journal.Sync,
+			CommitDelay: cfg.Mempool.Journal.CommitDelay,
+		},
+		Events: bus,
+	})
+	log.Println("Mempool initialized")
+
+	// Create block processor
+	// Bundles are only accepted by nodes that build blocks
+	var bundles *bundle.Pool
+	if cfg.Bundles.Enabled && cfg.Role.BuildsBlocks() {
+		bundles = bundle.NewPool(&bundle.Config{
+			MaxBundles:      cfg.Bundles.MaxBundles,
+			MaxTransactions: cfg.Bundles.MaxTransactions,
+		})
+	}
+
+	// Encrypted transactions are decrypted inside this process when a block is built
+	var encryptedPool *encrypted.Pool
+	if cfg.Encrypted.Enabled {
+		encryptedPool, err = encrypted.NewPool(&encrypted.Config{
+			MaxTransactions: cfg.Encrypted.MaxTransactions,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	processorConfig := &processor.Config{
+		Interval:        cfg.Block.Interval,
+		MaxStoredBlocks: cfg.Block.MaxStoredBlocks,
+		MaxTransactions: cfg.Block.MaxTransactions,
+		EnableTDXQuote:  cfg.Attestation.Enabled && cfg.Role.BuildsBlocks(),
+		Chaos:           faults,
+		State:           stateDB,
+		FeeRecipient:    common.HexToAddress(cfg.Block.FeeRecipient),
+		Bundles:         bundles,
+		Events:          bus,
+		Encrypted:       encryptedPool,
+		Orderer:         orderer,
+	}
+
+	// Blocks are timestamped with the time the Roughtime servers agree on, and
+	// the bounds of the timestamp become part of the attested block ID
+	var trustedClock *trustedtime.Clock
+	if cfg.TrustedTime.Enabled && cfg.Role.BuildsBlocks() {
+		trustedClock, err = newTrustedClock(cfg)
+		if err != nil {
+			return err
+		}
+		processorConfig.TrustedTime = trustedClock
+	}
+
+	// Replicas verify the attestation of every block they import
+	if cfg.Role.Mode == config.RoleReplica {
+		verifier, err := newReplicaVerifier(cfg)
+		if err != nil {
+			return err
+		}
+		processorConfig.Verifier = verifier
+	}
+
+	// Included transactions are indexed in the state database with every new
+	// block, and the index is reconciled with the stored chain
+	index, err := txindex.Open(stateDB.KeyValueStore())
+	if err != nil {
+		return err
+	}
+	processorConfig.Index = index
+
+	// Verify the stored chain before anything is served
+	var blockStore *store.BlockStore
+	if dataDir != nil {
+		var info *store.RecoveryInfo
+		blockStore, info, err = store.OpenBlockStore(dataDir.Join(datadir.BlocksDir, "blocks.jsonl"), store.OpenOptions{
+			Recent: cfg.Block.MaxStoredBlocks,
+			Repair: cfg.Repair,
+			Index:  index,
+		})
+		if err != nil {
+			return err
+		}
+		if info.TruncatedBytes > 0 {
+			logging.Warnf("Repaired block store: truncated %d bytes of an incomplete record", info.TruncatedBytes)
+		}
+		if info.Reindexed {
+			logging.Warnf("Rebuilt transaction index: it did not match the block store")
+		}
+		log.Printf("Block store verified: %d blocks (%d newly indexed), %d included transactions indexed", info.Blocks, info.Indexed, index.Len())
+		processorConfig.Store = blockStore
+	}
+
+	bp := processor.New(mp, processorConfig)
+	log.Printf("Block processor initialized with interval: %v", cfg.Block.Interval)
+
+	if blockStore != nil {
+		// Continue the chain from the latest stored block
+		recent := blockStore.Recent()
+		bp.Restore(recent)
+		if latest := blockStore.Latest(); latest != nil {
+			log.Printf("Restored chain head: number=%d, ID=%s", latest.Number, latest.ID)
+		}
+
+		// Blocks whose state was not written before a crash are executed again
+		replayed, err := stateDB.Restore(recent)
+		if err != nil {
+			return err
+		}
+		log.Printf("Restored account state: root %s, %d blocks re-executed", stateDB.Head().Hex(), replayed)
+
+		// Transactions in the latest blocks may not have been removed from the journal yet
+		included := make(map[string]bool)
+		for _, block := range recent {
+			for _, tx := range block.Transactions {
+				included[tx.ID] = true
+			}
+		}
+		restored, err := mp.OpenJournal(dataDir.Join(datadir.MempoolDir, "journal.jsonl"), func(id string) bool {
+			return included[id]
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Mempool journal replayed: %d pending transactions restored", restored)
+	}
+
+	if processorConfig.EnableTDXQuote {
+		log.Println("TDX quote generation is enabled")
+	}
+
+	// Additional chains run as isolated lanes next to the primary chain
+	lanes, err := openLanes(cfg, dataDir, g.ChainID)
+	if err != nil {
+		return err
+	}
+
+	// The socket permissions were validated with the configuration
+	unixMode, _ := cfg.RPC.UnixFileMode()
+
+	// Create JSON-RPC server with metrics
+	rpcServer := rpc.NewServer(mp, &rpc.Config{
+		Addr:            cfg.RPC.Addr,
+		WSAddr:          cfg.RPC.WSAddr,
+		AdminAddr:       cfg.RPC.AdminAddr,
+		FlashblocksAddr: cfg.Flashblocks.Addr,
+		UnixSocket:      cfg.RPC.UnixSocket,
+		UnixMode:        unixMode,
+		AuthTokens:      cfg.RPC.AuthTokens,
+		CORSOrigins:     cfg.RPC.CORSOrigins,
+		RateLimit:       cfg.RPC.RateLimit.RPS,
+		RateBurst:       cfg.RPC.RateLimit.Burst,
+		Role:            cfg.Role.Mode,
+
+		MaxConcurrent: cfg.RPC.Concurrency.MaxRequests,
+		QueueSize:     cfg.RPC.Concurrency.QueueSize,
+		QueueTimeout:  cfg.RPC.Concurrency.QueueTimeout,
+	})
+	log.Printf("JSON-RPC server initialized with address: %s", cfg.RPC.Addr)
+
+	// Metrics are scraped from the admin address
+	rpcServer.HandleAdmin("/metrics", m.Handler())
+
+	// Metrics are also reported by flash_getMetrics for the dashboard
+	rpcServer.SetMetrics(m)
+
+	// Set the processor reference in the RPC server
+	rpcServer.SetProcessor(bp)
+
+	// The eth namespace serves balances, nonces, code and storage
+	rpcServer.SetState(stateDB)
+
+	// eth_sendBundle submits bundles for atomic inclusion
+	rpcServer.SetBundlePool(bundles)
+
+	// Additional chains are served under suffixed namespaces and their own URL paths
+	for _, l := range lanes {
+		rpcServer.AddLane(l.rpcLane())
+	}
+
+	// The encryption key is published with a quote binding it to the enclave
+	if encryptedPool != nil {
+		quote, err := bp.Attest([]byte(strings.TrimPrefix(encryptedPool.KeyID().Hex(), "0x")))
+		if err != nil {
+			logging.Warnf("Failed to attest the encryption key: %v", err)
+		}
+		encryptedPool.SetQuote(quote)
+		rpcServer.SetEncryptedPool(encryptedPool)
+		log.Printf("Accepting encrypted transactions for key %s", encryptedPool.KeyID().Hex())
+	}
+
+	// Flashblocks consumers subscribe to the blocks in the rollup-boost payload format
+	if cfg.Flashblocks.Addr != "" {
+		feed := flashblocks.New(bus, &flashblocks.Config{
+			BlocksPerPayload: cfg.Flashblocks.BlocksPerPayload,
+			State:            stateDB,
+		})
+		feed.Start()
+		rpcServer.SetFlashblocksFeed(feed)
+	}
+
+	// Sealed blocks and preconfirmations are signed with the builder identity:
+	// an external signer if one is configured, otherwise their local keys
+	var externalSigner signer.Signer
+	if cfg.Signer.Type != config.SignerLocal {
+		externalSigner, err = newExternalSigner(cfg)
+		if err != nil {
+			return err
+		}
+		defer externalSigner.Close()
+	}
+
+	// Sealed blocks are pushed to external relays, signed by the relay key
+	var relays *relay.Publisher
+	if len(cfg.Relay.Endpoints) > 0 {
+		blockSigner, err := identitySigner(externalSigner, cfg.RelayKeyFile(), cfg.Relay.KeyFile, "relay signing key")
+		if err != nil {
+			return err
+		}
+		relays, err = relay.New(bp, m, &relay.Config{
+			Endpoints:    cfg.Relay.Endpoints,
+			Signer:       blockSigner,
+			Timeout:      cfg.Relay.Timeout,
+			MaxRetries:   cfg.Relay.MaxRetries,
+			RetryBackoff: cfg.Relay.RetryBackoff,
+		})
+		if err != nil {
+			return err
+		}
+		relays.Start()
+		rpcServer.SetRelayManager(relays)
+		log.Printf("Publishing sealed blocks to %d relays as %s", len(cfg.Relay.Endpoints), relays.Signer().Hex())
+	}
+	if trustedClock != nil {
+		rpcServer.SetTimeManager(trustedClock)
+	}
+
+	// Sealed blocks are posted to a data-availability layer, which references them
+	var daPublisher *da.Publisher
+	if cfg.DA.Layer != "" {
+		daPublisher, err = newDAPublisher(cfg, dataDir, bp, m)
+		if err != nil {
+			return err
+		}
+		daPublisher.Start()
+	}
+
+	// Sealed blocks are aggregated into compressed batches, which are posted
+	// to an L1 inbox if an endpoint is configured
+	var blockBatcher *batcher.Batcher
+	if cfg.Batcher.Enabled {
+		blockBatcher, err = newBatcher(cfg, dataDir, bp)
+		if err != nil {
+			return err
+		}
+		rpcServer.SetBatcher(blockBatcher)
+		blockBatcher.Start()
+		status := blockBatcher.Status()
+		log.Printf("Batching sealed blocks every %v with %s (%d batches, last block %d)", cfg.Batcher.Interval, cfg.Batcher.Algorithm, status.Batches, status.LastBlock)
+	}
+
+	// Old blocks are pruned from the block store under the retention policy;
+	// admin_prune and admin_pruning are available with any policy
+	var pruner *store.Pruner
+	if blockStore != nil {
+		pruner = store.NewPruner(blockStore, index, store.RetentionPolicy{
+			KeepBlocks:         cfg.Retention.KeepBlocks,
+			KeepAge:            time.Duration(cfg.Retention.KeepDays) * 24 * time.Hour,
+			CheckpointInterval: cfg.Retention.CheckpointInterval,
+			MinBlocks:          uint64(cfg.Block.MaxStoredBlocks),
+		}, cfg.Retention.Interval)
+		rpcServer.SetPruneManager(pruner)
+		if cfg.Retention.Enabled() {
+			pruner.Start()
+			log.Printf("Pruning the block store every %v (keep blocks: %d, keep days: %d)", cfg.Retention.Interval, cfg.Retention.KeepBlocks, cfg.Retention.KeepDays)
+		}
+	}
+
+	// The states of blocks below the finalized checkpoint are pruned and the
+	// state database compacted; admin_storage and admin_pruneState are
+	// available with any setting
+	var statePruner *state.Pruner
+	if dataDir != nil {
+		statePruner = state.NewPruner(stateDB, bp.GetProcessedBlocks, cfg.Retention.CheckpointInterval, cfg.Retention.StateInterval)
+		rpcServer.SetStorageManager(&storageManager{dataDir: dataDir, blocks: pruner, state: statePruner})
+		if cfg.Retention.State {
+			statePruner.Start()
+			log.Printf("Pruning the account state below the finalized checkpoint every %v", cfg.Retention.StateInterval)
+		}
+	}
+
+	// Congestion raises the minimum priority of new transactions instead of
+	// growing the queue; flash_estimatePriority reports it with any setting
+	market := feemarket.New(mp, bp, m, &feemarket.Config{
+		MempoolTarget: cfg.FeeMarket.MempoolTarget,
+		BlockTarget:   cfg.FeeMarket.BlockTarget,
+		MaxPriority:   cfg.FeeMarket.MaxPriority,
+		ChangeRate:    cfg.FeeMarket.ChangeRate,
+		Interval:      cfg.FeeMarket.Interval,
+	})
+	rpcServer.SetFeeMarket(market)
+	m.SetFeeFloor(mp.FeeFloor(), 0)
+	if cfg.FeeMarket.Enabled {
+		market.Start()
+		log.Printf("Fee market enabled: mempool target %.0f%%, block target %.0f%%, adjusted every %v", cfg.FeeMarket.MempoolTarget*100, cfg.FeeMarket.BlockTarget*100, cfg.FeeMarket.Interval)
+	}
+
+	// Under overload, low-priority submissions are rejected with a retry hint so
+	// high-priority transactions and reads keep their capacity
+	var shedder *shedding.Controller
+	if cfg.Shedding.Enabled {
+		shedder = shedding.New(mp, bp, rpcServer.QueuedRequests, m, &shedding.Config{
+			QueueThreshold:    cfg.Shedding.QueueThreshold,
+			LatencyThreshold:  cfg.Shedding.LatencyThreshold,
+			ProtectedPriority: cfg.Shedding.ProtectedPriority,
+			MaxShare:          cfg.Shedding.MaxShare,
+			Step:              cfg.Shedding.Step,
+			Interval:          cfg.Shedding.Interval,
+			RetryAfter:        cfg.Shedding.RetryAfter,
+		})
+		shedder.Start()
+		log.Printf("Load shedding enabled: queue threshold %d, block build threshold %v, adjusted every %v", cfg.Shedding.QueueThreshold, cfg.Shedding.LatencyThreshold, cfg.Shedding.Interval)
+	}
+
+	// Admitted transactions get a signed promise of inclusion within the window
+	if cfg.Preconf.Enabled {
+		receiptSigner, err := identitySigner(externalSigner, cfg.PreconfKeyFile(), cfg.Preconf.KeyFile, "preconfirmation signing key")
+		if err != nil {
+			return err
+		}
+		issuer, err := preconf.New(bp, &preconf.Config{
+			Signer:      receiptSigner,
+			ChainID:     g.ChainID,
+			Window:      cfg.Preconf.Window,
+			MaxReceipts: cfg.Preconf.MaxReceipts,
+		})
+		if err != nil {
+			return err
+		}
+		rpcServer.SetPreconfIssuer(issuer)
+		log.Printf("Issuing preconfirmations for the next %d blocks as %s", cfg.Preconf.Window, issuer.Signer().Hex())
+	}
+
+	// Blob transactions are accepted with their sidecars, which are kept apart
+	// from the blocks and pruned under their own retention
+	var blobStore *blobs.Store
+	if cfg.Blobs.Enabled {
+		var blobDir string
+		if dataDir != nil {
+			blobDir = dataDir.Join(datadir.BlobsDir)
+		}
+		blobStore, err = blobs.New(bp, &blobs.Config{
+			Dir:        blobDir,
+			KeepBlocks: cfg.Blobs.KeepBlocks,
+			KeepAge:    cfg.Blobs.KeepAge,
+			Interval:   cfg.Blobs.Interval,
+		})
+		if err != nil {
+			return err
+		}
+		rpcServer.SetBlobStore(blobStore)
+		blobStore.Start()
+		log.Printf("Accepting blob transactions: %d sidecars stored, pruned every %v (keep blocks: %d, keep age: %v)", blobStore.Status().Sidecars, cfg.Blobs.Interval, cfg.Blobs.KeepBlocks, cfg.Blobs.KeepAge)
+	}
+
+	// Blocks, transactions and receipts are indexed into a SQL database for
+	// flash_query, which keeps them after the blocks are pruned
+	var blockIndexer *indexer.Indexer
+	if cfg.Indexer.Driver != "" {
+		dsn := cfg.IndexerDSN()
+		if dsn == ":memory:" {
+			logging.Warnf("No data directory for the SQLite index, it is kept in memory")
+		}
+		blockIndexer, err = indexer.New(bp, &indexer.Config{
+			Driver:     cfg.Indexer.Driver,
+			DSN:        dsn,
+			MaxResults: cfg.Indexer.MaxResults,
+		})
+		if err != nil {
+			return err
+		}
+		rpcServer.SetIndexer(blockIndexer)
+		blockIndexer.Start()
+		log.Printf("Indexing blocks into %s (last indexed block %d)", cfg.Indexer.Driver, blockIndexer.LastBlock())
+	}
+
+	// A node whose chain diverged from its leader or builder cannot repair it by
+	// following and shuts down
+	failed := make(chan error, 1)
+	fail := func(err error) {
+		select {
+		case failed <- err:
+		default:
+		}
+	}
+
+	// In active/standby mode the node builds blocks only while it holds the leader lease
+	var haNode *haNode
+	if cfg.HA.Enabled {
+		haNode = newHANode(cfg, bp, mp, fail)
+	}
+
+	// RPC front-end nodes import the blocks of their builder instead of building
+	if cfg.Role.Mode == config.RoleRPC {
+		bp.SetPaused(true)
+		if cfg.Role.BuilderURL != "" {
+			log.Printf("Running as RPC front-end for builder %s", cfg.Role.BuilderURL)
+		} else {
+			log.Println("Running as RPC front-end following blocks from p2p peers")
+		}
+	} else if cfg.Role.Mode == config.RoleReplica {
+		// Replicas serve the verified chain and accept no transactions
+		bp.SetPaused(true)
+		mp.SetReadOnly(true)
+		if cfg.Role.BuilderURL != "" {
+			log.Printf("Running as replica of builder %s", cfg.Role.BuilderURL)
+		} else {
+			log.Println("Running as replica following blocks from p2p peers")
+		}
+	} else if cfg.Role.Mode == config.RoleBuilder {
+		log.Printf("Running as builder consuming mempool feeds %v", cfg.Role.MempoolFeeds)
+	}
+
+	// The processor has its own context so shutdown can stop it at a chosen point
+	processorCtx, stopProcessor := context.WithCancel(context.Background())
+	defer stopProcessor()
+
+	// Start block processor in a goroutine
+	processorDone := make(chan struct{})
+	go func() {
+		bp.Start(processorCtx)
+		close(processorDone)
+	}()
+	for _, l := range lanes {
+		l.start()
+	}
+
+	// Runtime-tunable settings are reloaded on SIGHUP and changed through admin_setConfig
+	runtimeCfg := newRuntimeConfig(cfg, name, args, rpcServer, mp, bp, haNode, bus)
+	rpcServer.SetConfigManager(runtimeCfg)
+
+	// Operators reject submissions and pause block production for upgrades through admin_setMaintenance
+	rpcServer.SetMaintenanceManager(newMaintenanceMode(mp, bp))
+
+	// Admitted transactions and sealed blocks are gossiped with the p2p peers;
+	// RPC front-end nodes import the blocks their peers announce
+	var gossip *p2p.Node
+	if cfg.P2P.Enabled {
+		var keyPath string
+		if dataDir != nil {
+			keyPath = dataDir.Join(datadir.KeysDir, "p2p.key")
+		}
+		key, err := p2p.LoadOrCreateKey(keyPath)
+		if err != nil {
+			return err
+		}
+		gossip, err = p2p.New(mp, bp, &p2p.Config{
+			ListenAddr:     cfg.P2P.ListenAddr,
+			Peers:          cfg.P2P.Peers,
+			Bootnodes:      cfg.P2P.Bootnodes,
+			Discovery:      cfg.P2P.Discovery,
+			MDNS:           cfg.P2P.MDNS,
+			MaxPeers:       cfg.P2P.MaxPeers,
+			Key:            key,
+			ChainID:        g.ChainID,
+			Genesis:        stateDB.GenesisRoot(),
+			ImportBlocks:   !cfg.Role.BuildsBlocks(),
+			VerifiedBlocks: processorConfig.Verifier != nil,
+			Included: func(txID string) bool {
+				_, ok := bp.LookupTransaction(txID)
+				return ok
+			},
+		})
+		if err != nil {
+			return err
+		}
+		rpcServer.SetPeerManager(gossip)
+	}
+
+	// Start JSON-RPC server
+	if err := rpcServer.Start(); err != nil {
+		return fmt.Errorf("JSON-RPC server error: %v", err)
+	}
+
+	if gossip != nil {
+		if err := gossip.Start(); err != nil {
+			return err
+		}
+		log.Printf("P2P gossip listening on %s", strings.Join(gossip.Addrs(), ", "))
+	}
+
+	log.Println("System is ready. Press Ctrl+C to stop.")
+	notifySystemd(systemd.Ready)
+
+	// Leader election starts once this node can serve standby nodes
+	haCtx, stopHA := context.WithCancel(context.Background())
+	defer stopHA()
+	haDone := make(chan struct{})
+	go func() {
+		if haNode != nil {
+			haNode.Run(haCtx)
+		}
+		close(haDone)
+	}()
+
+	// Role-split nodes connect to their remote counterparts once they can serve them
+	roleCtx, stopRole := context.WithCancel(context.Background())
+	defer stopRole()
+	roleDone := make(chan struct{})
+	go func() {
+		runRole(roleCtx, cfg, bp, mp, fail)
+		close(roleDone)
+	}()
+
+	// Watchdog heartbeats follow block production until shutdown
+	watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+	defer stopWatchdog()
+	go runWatchdog(watchdogCtx, bp, cfg.Block.Interval)
+
+	// Wait for interrupt signal, reloading the configuration on SIGHUP and
+	// reopening the log file after external rotation on SIGUSR1
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
+	var failure error
+wait:
+	for {
+		select {
+		case failure = <-failed:
+			logging.Errorf("%v; clear the blocks and state directories of the data directory to resync", failure)
+			break wait
+		case sig := <-sigCh:
+			switch sig {
+			case syscall.SIGUSR1:
+				if err := logFile.Reopen(); err != nil {
+					logging.Errorf("Failed to reopen log file: %v", err)
+					continue
+				}
+				logging.Infof("Reopened log file %s", logFile.Path())
+			case syscall.SIGHUP:
+				runtimeCfg.Reload()
+			default:
+				break wait
+			}
+		}
+	}
+
+	// Shutdown gracefully: writes are stopped first so the last block build sees a
+	// stable mempool, and subscribers receive that block before the connections close
+	log.Println("Shutting down...")
+	notifySystemd(systemd.Stopping)
+	stopWatchdog()
+	runShutdown([]shutdownStep{
+		{
+			name:    "stop accepting transactions",
+			timeout: time.Second,
+			run: func(ctx context.Context) error {
+				if cfg.FeeMarket.Enabled {
+					market.Close()
+				}
+				if shedder != nil {
+					shedder.Close()
+				}
+				mp.Close()
+				return nil
+			},
+		},
+		{
+			// A block takes at most one build, but TDX quote generation can be slow
+			name:    "wait for the in-flight block build",
+			timeout: cfg.Block.Interval + 5*time.Second,
+			run: func(ctx context.Context) error {
+				stopProcessor()
+				return waitFor(processorDone)(ctx)
+			},
+		},
+		{
+			name:    "stop the additional chains",
+			timeout: cfg.Block.Interval + 5*time.Second,
+			run: func(ctx context.Context) error {
+				return closeLanes(lanes)
+			},
+		},
+		{
+			// Nothing is imported from remote nodes once the stores are flushed
+			name:    "disconnect from remote nodes",
+			timeout: time.Second,
+			run: func(ctx context.Context) error {
+				stopRole()
+				if gossip != nil {
+					gossip.Stop()
+				}
+				if relays != nil {
+					relays.Close()
+				}
+				if daPublisher != nil {
+					daPublisher.Close()
+				}
+				if blockBatcher != nil {
+					blockBatcher.Close()
+				}
+				if trustedClock != nil {
+					trustedClock.Close()
+				}
+				return waitFor(roleDone)(ctx)
+			},
+		},
+		{
+			// Standby nodes take over once the lease is released
+			name:    "resign leadership",
+			timeout: time.Second,
+			run: func(ctx context.Context) error {
+				stopHA()
+				return waitFor(haDone)(ctx)
+			},
+		},
+		{
+			name:    "flush the mempool journal, block store and account state",
+			timeout: 5 * time.Second,
+			run: func(ctx context.Context) error {
+				if err := mp.CloseJournal(); err != nil {
+					return err
+				}
+				if pruner != nil {
+					pruner.Close()
+				}
+				if statePruner != nil {
+					statePruner.Close()
+				}
+				if blobStore != nil {
+					blobStore.Close()
+				}
+				if blockIndexer != nil {
+					blockIndexer.Close()
+				}
+				if blockStore != nil {
+					if err := blockStore.Close(); err != nil {
+						return err
+					}
+				}
+				return stateDB.Close()
+			},
+		},
+		{
+			name:    "close subscriptions",
+			timeout: time.Second,
+			run: func(ctx context.Context) error {
+				rpcServer.CloseSubscriptions()
+				return nil
+			},
+		},
+		{
+			name:    "stop the HTTP server",
+			timeout: 5 * time.Second,
+			run:     rpcServer.Shutdown,
+		},
+	})
+
+	log.Println("Server stopped")
+	return failure
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"flashblock/internal/logging"
+)
+
+// shutdownStep is one bounded step of the shutdown sequence
+type shutdownStep struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// runShutdown executes the steps in order. A step that fails or exceeds its
+// timeout is logged and the sequence continues with the next step.
+func runShutdown(steps []shutdownStep) {
+	start := time.Now()
+
+	for i, step := range steps {
+		logging.Infof("Shutdown step %d/%d: %s...", i+1, len(steps), step.name)
+		stepStart := time.Now()
+
+		ctx, cancel := context.WithTimeout(context.Background(), step.timeout)
+		done := make(chan error, 1)
+		go func() {
+			done <- step.run(ctx)
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				logging.Errorf("Shutdown step %q failed: %v", step.name, err)
+			} else {
+				logging.Infof("Shutdown step %q completed in %v", step.name, time.Since(stepStart))
+			}
+		case <-ctx.Done():
+			logging.Warnf("Shutdown step %q timed out after %v", step.name, step.timeout)
+		}
+		cancel()
+	}
+
+	logging.Infof("Shutdown completed in %v", time.Since(start))
+}
+
+// waitFor returns a step function that waits until done is closed
+func waitFor(done <-chan struct{}) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
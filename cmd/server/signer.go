@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"flashblock/internal/config"
+	"flashblock/internal/logging"
+	"flashblock/internal/p2p"
+	"flashblock/internal/signer"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// newExternalSigner connects to the configured remote signer or KMS key and
+// checks that it signs with its key, so a misconfigured signer fails at
+// startup rather than on the first block
+func newExternalSigner(cfg *config.Config) (signer.Signer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Signer.Timeout)
+	defer cancel()
+
+	var (
+		s   signer.Signer
+		err error
+	)
+	switch cfg.Signer.Type {
+	case config.SignerRemote:
+		s, err = signer.NewRemote(ctx, cfg.Signer.URL, cfg.Signer.AuthToken, common.HexToAddress(cfg.Signer.Address), cfg.Signer.Timeout)
+	case config.SignerAWSKMS:
+		s, err = signer.NewAWSKMS(ctx, cfg.Signer.Region, cfg.Signer.KeyID, cfg.Signer.URL, cfg.Signer.Timeout)
+	case config.SignerGCPKMS:
+		s, err = signer.NewGCPKMS(ctx, cfg.Signer.KeyID, cfg.Signer.URL, cfg.Signer.Timeout)
+	default:
+		return nil, fmt.Errorf("unknown signer type %q", cfg.Signer.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.SignHash(crypto.Keccak256Hash([]byte("flashblock signer check"))); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("signer check failed: %v", err)
+	}
+	log.Printf("Signing with the %s signer key %s", cfg.Signer.Type, s.Address().Hex())
+	return s, nil
+}
+
+// identitySigner returns the external signer if there is one, or else a
+// signer of the local key at keyPath, which is created on first use; what
+// names the key in the warning logged without a data directory
+func identitySigner(external signer.Signer, keyPath, keyFile, what string) (signer.Signer, error) {
+	if external != nil {
+		return external, nil
+	}
+	if keyPath == "" {
+		logging.Warnf("No data directory for %s, the %s changes on every start", keyFile, what)
+	}
+	key, err := p2p.LoadOrCreateKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return signer.NewLocal(key), nil
+}
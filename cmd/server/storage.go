@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flashblock/internal/datadir"
+	"flashblock/internal/rpc/admin"
+	"flashblock/internal/state"
+	"flashblock/internal/store"
+)
+
+// storageManager reports the disk usage of the data directory for
+// admin_storage and runs the state pruning passes of admin_pruneState
+type storageManager struct {
+	dataDir *datadir.DataDir
+	blocks  *store.Pruner
+	state   *state.Pruner
+}
+
+// Storage returns the usage of every subdirectory and what pruning the block
+// store and the account state would free now
+func (m *storageManager) Storage() (*admin.StorageReport, error) {
+	inspected, err := datadir.Inspect(m.dataDir.Path())
+	if err != nil {
+		return nil, err
+	}
+	report := &admin.StorageReport{
+		Path:  inspected.Path,
+		Dirs:  make(map[string]int64, len(inspected.Dirs)),
+		Total: inspected.Total,
+	}
+	for _, dir := range inspected.Dirs {
+		if dir.Exists {
+			report.Dirs[dir.Name] = dir.Bytes
+		}
+	}
+
+	if report.Blocks, err = m.blocks.Usage(); err != nil {
+		return nil, err
+	}
+	report.Reclaimable += report.Blocks.ReclaimableBytes
+
+	finalized, reclaimable, err := m.state.Reclaimable()
+	if err != nil {
+		return nil, err
+	}
+	report.State = &admin.StateUsage{
+		FinalizedBlock:   finalized,
+		ReclaimableBytes: reclaimable.Reclaimed,
+		ReclaimableNodes: reclaimable.Nodes,
+		ReclaimableCode:  reclaimable.Code,
+		Pruning:          m.state.Status(),
+	}
+	report.State.Bytes = report.State.Pruning.Bytes
+	report.Reclaimable += reclaimable.Reclaimed
+	return report, nil
+}
+
+// PruneState runs a state pruning pass now
+func (m *storageManager) PruneState() (*state.PruneResult, error) {
+	return m.state.Prune()
+}
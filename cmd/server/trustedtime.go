@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+
+	"flashblock/internal/config"
+	"flashblock/internal/trustedtime"
+)
+
+// newTrustedClock creates the clock timestamping the blocks from the Roughtime
+// servers of the configuration and synchronizes it
+func newTrustedClock(cfg *config.Config) (*trustedtime.Clock, error) {
+	t := cfg.TrustedTime
+	clockConfig := &trustedtime.Config{
+		Quorum:         t.Quorum,
+		SyncInterval:   t.SyncInterval,
+		Timeout:        t.Timeout,
+		MaxDrift:       t.MaxDriftPPM,
+		MaxUncertainty: t.MaxUncertainty,
+		Fallback:       t.Fallback,
+	}
+	for _, server := range t.Servers {
+		// The keys were validated with the configuration
+		key, err := trustedtime.ParsePublicKey(server.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		clockConfig.Servers = append(clockConfig.Servers, trustedtime.Server{
+			Name:      server.Name,
+			Address:   server.Address,
+			PublicKey: key,
+		})
+	}
+
+	clock, err := trustedtime.New(clockConfig)
+	if err != nil {
+		return nil, err
+	}
+	// Blocks wait for a later synchronization if the first one fails
+	if err := clock.Start(); err != nil {
+		log.Printf("Failed to synchronize trusted time: %v", err)
+	}
+	log.Printf("Timestamping blocks with trusted time from %d Roughtime servers (quorum %d, max uncertainty %v)",
+		len(clockConfig.Servers), clockConfig.Quorum, clockConfig.MaxUncertainty)
+	return clock, nil
+}
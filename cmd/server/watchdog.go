@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/processor"
+	"flashblock/internal/systemd"
+)
+
+// notifySystemd sends a state notification, logging failures
+func notifySystemd(state string) {
+	sent, err := systemd.Notify(state)
+	if err != nil {
+		logging.Warnf("Failed to notify systemd (%s): %v", state, err)
+		return
+	}
+	if sent {
+		logging.Debugf("Notified systemd: %s", state)
+	}
+}
+
+// checkWatchdogInterval returns an error if blocks are built too rarely for
+// the systemd watchdog: heartbeats are withheld once no tick completed within
+// the timeout, so the interval leaves room for a late tick.
+func checkWatchdogInterval(interval time.Duration) error {
+	timeout, ok := systemd.WatchdogTimeout()
+	if !ok || interval <= timeout/2 {
+		return nil
+	}
+	return fmt.Errorf("block.interval %v exceeds half the systemd watchdog timeout of %v", interval, timeout)
+}
+
+// runWatchdog sends systemd watchdog heartbeats while block production is alive.
+// Heartbeats stop when the processing loop has not completed a tick within the
+// watchdog timeout, so systemd restarts a stalled builder.
+func runWatchdog(ctx context.Context, bp *processor.BlockProcessor, blockInterval time.Duration) {
+	timeout, ok := systemd.WatchdogTimeout()
+	if !ok {
+		return
+	}
+
+	// Heartbeats are sent twice per timeout as systemd recommends
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	logging.Infof("systemd watchdog enabled with timeout %v", timeout)
+	started := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Allow the processor one interval plus the timeout to complete its first tick
+			last := bp.LastTick()
+			if last.IsZero() {
+				last = started.Add(blockInterval)
+			}
+
+			if stalled := now.Sub(last); stalled > timeout {
+				logging.Errorf("Block production stalled for %v, withholding watchdog heartbeat", stalled)
+				continue
+			}
+			notifySystemd(systemd.Watchdog)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package attest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"flashblock/internal/model"
+
+	"github.com/google/go-tdx-guest/validate"
+	"github.com/google/go-tdx-guest/verify"
+)
+
+// Errors
+var (
+	ErrMissingQuote     = errors.New("block has no attestation quote") // No quote when quotes are required
+	ErrMissingTimeProof = errors.New("block has no trusted timestamp") // No time proof when one is required
+)
+
+// Policy holds the expected measurements of the TD that builds blocks. Empty
+// measurements are not checked.
+type Policy struct {
+	RequireQuote    bool     // Reject blocks without a quote
+	CheckCollateral bool     // Fetch the TCB collateral and revocation lists from the Intel PCS
+	MrTd            []byte   // Measurement of the initial TD contents (48 bytes)
+	MrSeam          []byte   // Measurement of the TDX module (48 bytes)
+	MrConfigID      []byte   // Software-defined configuration ID (48 bytes)
+	MrOwner         []byte   // Software-defined owner ID (48 bytes)
+	TdAttributes    []byte   // TD attributes (8 bytes)
+	Rtmrs           [][]byte // Runtime measurement registers (4 of 48 bytes)
+
+	RequireTrustedTime bool          // Reject blocks whose timestamp has no proof from a trusted time source
+	MaxTimeUncertainty time.Duration // Reject blocks whose timestamp may be further from the true time (0 = any)
+}
+
+// Verifier checks the TDX quotes of blocks against a policy
+type Verifier struct {
+	policy *Policy
+}
+
+// NewVerifier creates a verifier for the given policy
+func NewVerifier(policy *Policy) *Verifier {
+	return &Verifier{policy: policy}
+}
+
+// VerifyBlock checks that the quote of a block is signed by a genuine TDX
+// platform, binds the block ID and matches the measurements of the policy.
+// Blocks without a quote pass unless the policy requires one. The time proof
+// is part of the block ID, so its bounds are covered by the quote.
+func (v *Verifier) VerifyBlock(block *model.Block) error {
+	if proof := block.TimeProof; proof == nil {
+		if v.policy.RequireTrustedTime {
+			return fmt.Errorf("%w: block %d (%s)", ErrMissingTimeProof, block.Number, block.ID)
+		}
+	} else if bound := v.policy.MaxTimeUncertainty; bound > 0 && proof.Uncertainty > bound {
+		return fmt.Errorf("timestamp of block %d (%s) is uncertain by %v, more than %v", block.Number, block.ID, proof.Uncertainty, bound)
+	}
+
+	if len(block.TDXQuote) == 0 {
+		if v.policy.RequireQuote {
+			return fmt.Errorf("%w: block %d (%s)", ErrMissingQuote, block.Number, block.ID)
+		}
+		return nil
+	}
+	if err := v.VerifyQuote(block.TDXQuote, []byte(block.ID)); err != nil {
+		return fmt.Errorf("invalid attestation quote of block %d (%s): %v", block.Number, block.ID, err)
+	}
+	return nil
+}
+
+// VerifyQuote checks the signature of a raw quote and that its report data
+// holds userData, padded with zeros as by GetQuote
+func (v *Verifier) VerifyQuote(quote, userData []byte) error {
+	options := verify.DefaultOptions()
+	options.Now = time.Now()
+	options.GetCollateral = v.policy.CheckCollateral
+	options.CheckRevocations = v.policy.CheckCollateral
+	if err := verify.RawTdxQuote(quote, options); err != nil {
+		return fmt.Errorf("signature verification failed: %v", err)
+	}
+
+	var reportData [64]byte
+	copy(reportData[:], userData)
+	err := validate.RawTdxQuote(quote, &validate.Options{
+		TdQuoteBodyOptions: validate.TdQuoteBodyOptions{
+			MrTd:         v.policy.MrTd,
+			MrSeam:       v.policy.MrSeam,
+			MrConfigID:   v.policy.MrConfigID,
+			MrOwner:      v.policy.MrOwner,
+			TdAttributes: v.policy.TdAttributes,
+			Rtmrs:        v.policy.Rtmrs,
+			ReportData:   reportData[:],
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("policy validation failed: %v", err)
+	}
+	return nil
+}
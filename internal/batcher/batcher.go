@@ -0,0 +1,582 @@
+// Package batcher aggregates the sealed blocks of the node into compressed
+// batches on a schedule, keeps their framed artifacts and optionally posts
+// the frames to an L1 chain, modeling the batch poster of a rollup.
+package batcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Batch statuses
+const (
+	StatusStored    = "stored"    // Kept locally, no L1 submission is configured
+	StatusPending   = "pending"   // Waiting for its frames to be posted
+	StatusSubmitted = "submitted" // All frames included on L1
+	StatusFailed    = "failed"    // Posting gave up after the retries
+)
+
+const (
+	queueSize       = 256  // Sealed blocks waiting to be batched
+	submitQueueSize = 1024 // Batches waiting to be posted
+	memoryBatches   = 256  // Artifacts kept without a directory
+	indexFile       = "batches.jsonl"
+	artifactExt     = ".bin"
+)
+
+// Config holds configuration for the batcher
+type Config struct {
+	Algorithm    string        // Compression algorithm: zlib, brotli or zstd
+	Interval     time.Duration // Time between batches
+	MaxBlocks    int           // Blocks per batch at most, sealing it early (0 = no limit)
+	MaxFrameSize int           // Bytes per frame at most, including the frame overhead
+	Dir          string        // Directory of the artifacts and the batch index (kept in memory if empty)
+	Timeout      time.Duration // Deadline of posting a frame, including its inclusion
+	MaxRetries   int           // Reposts of a frame that was not included
+	RetryBackoff time.Duration // Wait before the first repost, doubled for every further one
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Algorithm:    AlgorithmZstd,
+		Interval:     time.Minute,
+		MaxFrameSize: 120000,
+		Timeout:      2 * time.Minute,
+		MaxRetries:   3,
+		RetryBackoff: time.Second,
+	}
+}
+
+// Batch describes a batch of consecutive sealed blocks
+type Batch struct {
+	ID           uint64        `json:"id"`
+	FirstBlock   uint64        `json:"first_block"`
+	LastBlock    uint64        `json:"last_block"`
+	Transactions int           `json:"transactions"`
+	Algorithm    string        `json:"algorithm"`
+	RawSize      int           `json:"raw_size"` // Bytes of the RLP list of blocks before compression
+	Size         int           `json:"size"`     // Bytes of the framed artifact
+	Frames       int           `json:"frames"`
+	Hash         common.Hash   `json:"hash"` // Keccak-256 hash of the artifact
+	Created      time.Time     `json:"created"`
+	Status       string        `json:"status"`
+	L1Txs        []common.Hash `json:"l1_txs,omitempty"`   // Transactions carrying the posted frames, in order
+	L1Block      uint64        `json:"l1_block,omitempty"` // L1 block including the last posted frame
+	Error        string        `json:"error,omitempty"`    // Why posting failed
+}
+
+// Status summarizes the batches
+type Status struct {
+	Algorithm string `json:"algorithm"`
+	Interval  string `json:"interval"`
+	Batches   int    `json:"batches"`
+	LastBlock uint64 `json:"last_block"` // Last block in a batch
+	Pending   int    `json:"pending"`
+	Submitted int    `json:"submitted"`
+	Failed    int    `json:"failed"`
+	RawBytes  uint64 `json:"raw_bytes"` // Uncompressed size of all batches
+	Bytes     uint64 `json:"bytes"`     // Size of all artifacts
+}
+
+// Batcher aggregates the blocks sealed by the processor into batches. The
+// batches are recorded in an index, so numbering continues after a restart,
+// recent blocks sealed but not batched before a restart are batched first and
+// batches that were not fully posted are posted again.
+type Batcher struct {
+	processor *processor.BlockProcessor
+	submitter Submitter // Posts the frames (nil = batches are only stored)
+	config    *Config
+	index     *os.File
+
+	mu        sync.RWMutex
+	batches   []*Batch          // Ordered by ID
+	artifacts map[uint64][]byte // Kept in memory without a directory
+	lastBlock uint64            // Last block in a batch
+
+	submit chan *Batch
+	ctx    context.Context // Cancelled by Close, aborting a post in progress
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a batcher of the sealed blocks of the processor. Batches are
+// posted with the submitter unless it is nil.
+func New(bp *processor.BlockProcessor, submitter Submitter, config *Config) (*Batcher, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if !ValidAlgorithm(config.Algorithm) {
+		return nil, fmt.Errorf("unknown compression algorithm %q", config.Algorithm)
+	}
+	if config.MaxFrameSize <= FrameOverhead {
+		return nil, fmt.Errorf("frame size must exceed the %d byte frame overhead", FrameOverhead)
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig().Interval
+	}
+	b := &Batcher{
+		processor: bp,
+		submitter: submitter,
+		config:    config,
+		artifacts: make(map[uint64][]byte),
+		submit:    make(chan *Batch, submitQueueSize),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	if config.Dir == "" {
+		return b, nil
+	}
+
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create batch directory: %v", err)
+	}
+	path := filepath.Join(config.Dir, indexFile)
+	batches, err := readIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	b.batches = batches
+	for _, batch := range batches {
+		b.lastBlock = max(b.lastBlock, batch.LastBlock)
+	}
+	if b.index, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return nil, fmt.Errorf("failed to open batch index: %v", err)
+	}
+	return b, nil
+}
+
+// Start batches the sealed blocks every interval and posts the batches until
+// the batcher is closed
+func (b *Batcher) Start() {
+	blocks := make(chan events.BlockSealed, queueSize)
+	sub := b.processor.Events().BlockSealed.Subscribe(blocks)
+
+	// Recent blocks sealed before a restart without being batched come first
+	var pending []*model.Block
+	last := b.lastBlock
+	if last > 0 {
+		for _, block := range b.processor.GetProcessedBlocks() {
+			if block.Number > last {
+				pending = append(pending, block)
+				last = block.Number
+			}
+		}
+	}
+
+	if b.submitter != nil {
+		b.mu.RLock()
+		for _, batch := range b.batches {
+			if batch.Status == StatusPending || batch.Status == StatusFailed {
+				b.queue(batch)
+			}
+		}
+		b.mu.RUnlock()
+
+		b.wg.Add(1)
+		go b.post()
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer sub.Unsubscribe()
+
+		ticker := time.NewTicker(b.config.Interval)
+		defer ticker.Stop()
+		for {
+			if b.config.MaxBlocks > 0 && len(pending) >= b.config.MaxBlocks {
+				b.seal(pending[:b.config.MaxBlocks])
+				pending = pending[b.config.MaxBlocks:]
+				continue
+			}
+
+			select {
+			case ev := <-blocks:
+				// Blocks sealed while the recent blocks were collected arrive
+				// twice, and imported blocks are batched by their builder
+				if !ev.Imported && ev.Block.Number > last {
+					pending = append(pending, ev.Block)
+					last = ev.Block.Number
+				}
+			case <-ticker.C:
+				if len(pending) > 0 {
+					b.seal(pending)
+					pending = nil
+				}
+			case <-sub.Err():
+				return
+			case <-b.ctx.Done():
+				// Blocks waiting for the next interval are batched now; the
+				// batch is posted after a restart
+				if len(pending) > 0 {
+					b.seal(pending)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Close stops batching and posting
+func (b *Batcher) Close() {
+	b.cancel()
+	b.wg.Wait()
+
+	if b.submitter != nil {
+		b.submitter.Close()
+	}
+	if b.index != nil {
+		b.index.Close()
+	}
+}
+
+// Batches returns the most recent batches, oldest first; count 0 returns all
+func (b *Batcher) Batches(count int) []*Batch {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	batches := b.batches
+	if count > 0 && len(batches) > count {
+		batches = batches[len(batches)-count:]
+	}
+	result := make([]*Batch, len(batches))
+	for i, batch := range batches {
+		result[i] = batch.copy()
+	}
+	return result
+}
+
+// Get returns a batch with its artifact, which is nil if it is not kept (anymore)
+func (b *Batcher) Get(id uint64) (*Batch, []byte, bool) {
+	b.mu.RLock()
+	batch := b.find(id)
+	if batch == nil {
+		b.mu.RUnlock()
+		return nil, nil, false
+	}
+	batch = batch.copy()
+	b.mu.RUnlock()
+
+	artifact, err := b.artifact(id)
+	if err != nil {
+		logging.Errorf("Failed to read the artifact of batch %d: %v", id, err)
+	}
+	return batch, artifact, true
+}
+
+// Status summarizes the batches
+func (b *Batcher) Status() Status {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	status := Status{
+		Algorithm: b.config.Algorithm,
+		Interval:  b.config.Interval.String(),
+		Batches:   len(b.batches),
+		LastBlock: b.lastBlock,
+	}
+	for _, batch := range b.batches {
+		switch batch.Status {
+		case StatusPending:
+			status.Pending++
+		case StatusSubmitted:
+			status.Submitted++
+		case StatusFailed:
+			status.Failed++
+		}
+		status.RawBytes += uint64(batch.RawSize)
+		status.Bytes += uint64(batch.Size)
+	}
+	return status
+}
+
+// seal compresses blocks into the next batch, stores its artifact and queues
+// it for posting
+func (b *Batcher) seal(blocks []*model.Block) {
+	first, last := blocks[0].Number, blocks[len(blocks)-1].Number
+	b.mu.RLock()
+	id := uint64(1)
+	if len(b.batches) > 0 {
+		id = b.batches[len(b.batches)-1].ID + 1
+	}
+	b.mu.RUnlock()
+
+	frames, rawSize, err := encodeBatch(id, b.config.Algorithm, blocks, b.config.MaxFrameSize)
+	if err != nil {
+		logging.Errorf("Failed to batch blocks %d-%d: %v", first, last, err)
+		return
+	}
+	artifact := bytes.Join(frames, nil)
+
+	batch := &Batch{
+		ID:         id,
+		FirstBlock: first,
+		LastBlock:  last,
+		Algorithm:  b.config.Algorithm,
+		RawSize:    rawSize,
+		Size:       len(artifact),
+		Frames:     len(frames),
+		Hash:       crypto.Keccak256Hash(artifact),
+		Created:    time.Now(),
+		Status:     StatusStored,
+	}
+	for _, block := range blocks {
+		batch.Transactions += len(block.Transactions)
+	}
+	if b.submitter != nil {
+		batch.Status = StatusPending
+	}
+
+	if b.config.Dir != "" {
+		if err := b.writeArtifact(id, artifact); err != nil {
+			logging.Errorf("Failed to store batch %d of blocks %d-%d: %v", id, first, last, err)
+			return
+		}
+	}
+
+	b.mu.Lock()
+	if b.config.Dir == "" {
+		b.artifacts[id] = artifact
+		delete(b.artifacts, id-memoryBatches)
+	}
+	b.batches = append(b.batches, batch)
+	b.lastBlock = last
+	b.record(batch)
+	b.mu.Unlock()
+
+	logging.Infof("Batched blocks %d-%d into batch %d: %d bytes compressed with %s from %d bytes, %d frames", first, last, id, len(artifact), batch.Algorithm, rawSize, len(frames))
+	if b.submitter != nil {
+		b.queue(batch)
+	}
+}
+
+// queue adds a batch to the posting queue; a batch that does not fit is
+// posted after a restart
+func (b *Batcher) queue(batch *Batch) {
+	select {
+	case b.submit <- batch:
+	default:
+		logging.Warnf("Posting queue full, batch %d is posted after a restart", batch.ID)
+	}
+}
+
+// post posts the queued batches until the batcher is closed
+func (b *Batcher) post() {
+	defer b.wg.Done()
+	for {
+		select {
+		case batch := <-b.submit:
+			b.postBatch(batch)
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
+
+// postBatch posts the frames of a batch not posted yet, in order
+func (b *Batcher) postBatch(batch *Batch) {
+	artifact, err := b.artifact(batch.ID)
+	if err == nil && artifact == nil {
+		err = errors.New("artifact not kept")
+	}
+	var frames [][]byte
+	if err == nil {
+		frames, err = splitFrames(artifact)
+	}
+	if err != nil {
+		b.fail(batch, err)
+		return
+	}
+
+	b.mu.RLock()
+	posted := len(batch.L1Txs)
+	b.mu.RUnlock()
+	for i := posted; i < len(frames); i++ {
+		hash, l1Block, err := b.postFrame(frames[i])
+		if b.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			b.fail(batch, fmt.Errorf("frame %d: %v", i, err))
+			return
+		}
+
+		b.mu.Lock()
+		batch.L1Txs = append(batch.L1Txs, hash)
+		batch.L1Block = l1Block
+		if i == len(frames)-1 {
+			batch.Status = StatusSubmitted
+			batch.Error = ""
+		}
+		b.record(batch)
+		b.mu.Unlock()
+	}
+	logging.Infof("Posted batch %d of blocks %d-%d to L1 in %d transactions, included by block %d", batch.ID, batch.FirstBlock, batch.LastBlock, len(frames), batch.L1Block)
+}
+
+// postFrame posts a frame, retrying with exponential backoff
+func (b *Batcher) postFrame(frame []byte) (common.Hash, uint64, error) {
+	backoff := b.config.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= b.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-b.ctx.Done():
+				return common.Hash{}, 0, b.ctx.Err()
+			}
+			backoff *= 2
+		}
+
+		ctx, cancel := context.WithTimeout(b.ctx, b.config.Timeout)
+		hash, l1Block, postErr := b.submitter.Submit(ctx, frame)
+		cancel()
+		if postErr == nil {
+			return hash, l1Block, nil
+		}
+		err = postErr
+		if b.ctx.Err() != nil {
+			return common.Hash{}, 0, err
+		}
+		logging.Debugf("Posting a batch frame failed (attempt %d): %v", attempt+1, err)
+	}
+	return common.Hash{}, 0, err
+}
+
+// fail records that posting a batch failed
+func (b *Batcher) fail(batch *Batch, err error) {
+	logging.Warnf("Failed to post batch %d of blocks %d-%d to L1: %v", batch.ID, batch.FirstBlock, batch.LastBlock, err)
+	b.mu.Lock()
+	batch.Status = StatusFailed
+	batch.Error = err.Error()
+	b.record(batch)
+	b.mu.Unlock()
+}
+
+// find returns the batch with the given ID; the caller holds the lock
+func (b *Batcher) find(id uint64) *Batch {
+	i := sort.Search(len(b.batches), func(i int) bool { return b.batches[i].ID >= id })
+	if i < len(b.batches) && b.batches[i].ID == id {
+		return b.batches[i]
+	}
+	return nil
+}
+
+// record appends the current state of a batch to the index; the caller holds
+// the lock
+func (b *Batcher) record(batch *Batch) {
+	if b.index == nil {
+		return
+	}
+	if err := json.NewEncoder(b.index).Encode(batch); err != nil {
+		logging.Errorf("Failed to record batch %d: %v", batch.ID, err)
+	}
+}
+
+// artifact returns the artifact of a batch, or nil if it is not kept
+func (b *Batcher) artifact(id uint64) ([]byte, error) {
+	if b.config.Dir == "" {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return b.artifacts[id], nil
+	}
+	data, err := os.ReadFile(b.artifactPath(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// artifactPath returns the file of the artifact of a batch
+func (b *Batcher) artifactPath(id uint64) string {
+	return filepath.Join(b.config.Dir, fmt.Sprintf("%020d%s", id, artifactExt))
+}
+
+// writeArtifact stores the artifact of a batch atomically
+func (b *Batcher) writeArtifact(id uint64, artifact []byte) error {
+	path := b.artifactPath(id)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, artifact, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// copy returns a copy of the batch that is safe to hand out
+func (batch *Batch) copy() *Batch {
+	copied := *batch
+	copied.L1Txs = append([]common.Hash(nil), batch.L1Txs...)
+	return &copied
+}
+
+// splitFrames returns the encoded frames of an artifact
+func splitFrames(artifact []byte) ([][]byte, error) {
+	var frames [][]byte
+	for len(artifact) > 0 {
+		_, rest, err := parseFrame(artifact)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, artifact[:len(artifact)-len(rest)])
+		artifact = rest
+	}
+	return frames, nil
+}
+
+// readIndex returns the batches recorded in the index at path, ordered by ID.
+// A batch is recorded again whenever it changes, so the last record of a
+// batch wins. Invalid records, such as a record cut short by a crash, are
+// skipped.
+func readIndex(path string) ([]*Batch, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch index: %v", err)
+	}
+	defer file.Close()
+
+	byID := make(map[uint64]*Batch)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		batch := &Batch{}
+		if err := json.Unmarshal(scanner.Bytes(), batch); err != nil {
+			logging.Warnf("Skipping invalid batch index record %d: %v", line, err)
+			continue
+		}
+		byID[batch.ID] = batch
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch index: %v", err)
+	}
+
+	batches := make([]*Batch, 0, len(byID))
+	for _, batch := range byID {
+		batches = append(batches, batch)
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].ID < batches[j].ID })
+	return batches, nil
+}
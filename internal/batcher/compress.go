@@ -0,0 +1,126 @@
+package batcher
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms of batches
+const (
+	AlgorithmZlib   = "zlib"
+	AlgorithmBrotli = "brotli"
+	AlgorithmZstd   = "zstd"
+)
+
+// Algorithm bytes in the frame header
+const (
+	codeZlib   = 0x01
+	codeBrotli = 0x02
+	codeZstd   = 0x03
+)
+
+// maxBatchSize bounds the decompressed size of a batch, so a malformed
+// artifact cannot exhaust the memory of a reader
+const maxBatchSize = 64 << 20
+
+// algorithmCode returns the frame header byte of an algorithm
+func algorithmCode(algorithm string) (byte, error) {
+	switch algorithm {
+	case AlgorithmZlib:
+		return codeZlib, nil
+	case AlgorithmBrotli:
+		return codeBrotli, nil
+	case AlgorithmZstd:
+		return codeZstd, nil
+	default:
+		return 0, fmt.Errorf("unknown compression algorithm %q", algorithm)
+	}
+}
+
+// algorithmName returns the algorithm of a frame header byte
+func algorithmName(code byte) (string, error) {
+	switch code {
+	case codeZlib:
+		return AlgorithmZlib, nil
+	case codeBrotli:
+		return AlgorithmBrotli, nil
+	case codeZstd:
+		return AlgorithmZstd, nil
+	default:
+		return "", fmt.Errorf("unknown compression algorithm 0x%02x", code)
+	}
+}
+
+// ValidAlgorithm reports whether batches can be compressed with the algorithm
+func ValidAlgorithm(algorithm string) bool {
+	_, err := algorithmCode(algorithm)
+	return err == nil
+}
+
+// compress compresses data at the best ratio of the algorithm; batches are
+// built in the background, so size matters more than speed
+func compress(algorithm string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch algorithm {
+	case AlgorithmZlib:
+		writer, _ = zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	case AlgorithmBrotli:
+		writer = brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	case AlgorithmZstd:
+		encoder, err := zstd.NewWriter(&buf, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return nil, err
+		}
+		writer = encoder
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", algorithm)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress reverses compress
+func decompress(algorithm string, data []byte) ([]byte, error) {
+	var reader io.Reader
+	switch algorithm {
+	case AlgorithmZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reader = zr
+	case AlgorithmBrotli:
+		reader = brotli.NewReader(bytes.NewReader(data))
+	case AlgorithmZstd:
+		decoder, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		reader = decoder
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", algorithm)
+	}
+
+	out, err := io.ReadAll(io.LimitReader(reader, maxBatchSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxBatchSize {
+		return nil, fmt.Errorf("batch exceeds %d bytes when decompressed", maxBatchSize)
+	}
+	return out, nil
+}
@@ -0,0 +1,189 @@
+package batcher
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Frame layout: a batch is the RLP list of the canonical encodings of its
+// blocks, compressed and split into frames of at most the configured size.
+// Every frame is posted as the data of one L1 transaction:
+//
+//	version       1 byte  (FrameVersion)
+//	batch ID      8 bytes big-endian
+//	algorithm     1 byte  (0x01 zlib, 0x02 brotli, 0x03 zstd)
+//	frame number  2 bytes big-endian, counting from 0
+//	data length   4 bytes big-endian
+//	data          data length bytes of the compressed batch
+//	is last       1 byte  (0x01 on the last frame of the batch, 0x00 otherwise)
+//
+// The artifact of a batch is the concatenation of its frames.
+const (
+	FrameVersion    = 0x00
+	frameHeaderSize = 1 + 8 + 1 + 2 + 4
+	FrameOverhead   = frameHeaderSize + 1 // Bytes of a frame besides its data
+)
+
+// Frame is a part of a compressed batch
+type Frame struct {
+	BatchID   uint64
+	Algorithm byte
+	Number    uint16
+	Data      []byte
+	Last      bool
+}
+
+// MarshalBinary returns the encoding of the frame
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, FrameOverhead+len(f.Data))
+	buf = append(buf, FrameVersion)
+	buf = binary.BigEndian.AppendUint64(buf, f.BatchID)
+	buf = append(buf, f.Algorithm)
+	buf = binary.BigEndian.AppendUint16(buf, f.Number)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(f.Data)))
+	buf = append(buf, f.Data...)
+	if f.Last {
+		return append(buf, 0x01), nil
+	}
+	return append(buf, 0x00), nil
+}
+
+// parseFrame decodes the frame at the start of data and returns the rest
+func parseFrame(data []byte) (*Frame, []byte, error) {
+	if len(data) < FrameOverhead {
+		return nil, nil, errors.New("truncated frame header")
+	}
+	if data[0] != FrameVersion {
+		return nil, nil, fmt.Errorf("unsupported frame version 0x%02x", data[0])
+	}
+	f := &Frame{
+		BatchID:   binary.BigEndian.Uint64(data[1:9]),
+		Algorithm: data[9],
+		Number:    binary.BigEndian.Uint16(data[10:12]),
+	}
+	size := int(binary.BigEndian.Uint32(data[12:16]))
+	if len(data) < FrameOverhead+size {
+		return nil, nil, fmt.Errorf("truncated frame %d", f.Number)
+	}
+	f.Data = data[frameHeaderSize : frameHeaderSize+size]
+	switch data[frameHeaderSize+size] {
+	case 0x00:
+	case 0x01:
+		f.Last = true
+	default:
+		return nil, nil, fmt.Errorf("invalid last flag of frame %d", f.Number)
+	}
+	return f, data[FrameOverhead+size:], nil
+}
+
+// encodeBatch compresses blocks into the frames of batch id, each at most
+// maxFrameSize bytes long, and returns them with the uncompressed size. DA
+// references are not part of the encoding.
+func encodeBatch(id uint64, algorithm string, blocks []*model.Block, maxFrameSize int) ([][]byte, int, error) {
+	code, err := algorithmCode(algorithm)
+	if err != nil {
+		return nil, 0, err
+	}
+	if maxFrameSize <= FrameOverhead {
+		return nil, 0, fmt.Errorf("frame size must exceed the %d byte frame overhead", FrameOverhead)
+	}
+
+	encoded := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		copied := *block
+		copied.DA = nil
+		data, err := model.EncodeBlock(&copied)
+		if err != nil {
+			return nil, 0, err
+		}
+		encoded[i] = data
+	}
+	raw, err := rlp.EncodeToBytes(encoded)
+	if err != nil {
+		return nil, 0, err
+	}
+	compressed, err := compress(algorithm, raw)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to compress batch: %v", err)
+	}
+
+	chunk := maxFrameSize - FrameOverhead
+	count := max(1, (len(compressed)+chunk-1)/chunk)
+	if count > math.MaxUint16+1 {
+		return nil, 0, fmt.Errorf("batch of %d bytes needs %d frames", len(compressed), count)
+	}
+	frames := make([][]byte, count)
+	for i := range frames {
+		f := &Frame{
+			BatchID:   id,
+			Algorithm: code,
+			Number:    uint16(i),
+			Data:      compressed[i*chunk : min((i+1)*chunk, len(compressed))],
+			Last:      i == count-1,
+		}
+		if frames[i], err = f.MarshalBinary(); err != nil {
+			return nil, 0, err
+		}
+	}
+	return frames, len(raw), nil
+}
+
+// DecodeBatch reassembles the frames of a batch artifact, in order, and
+// returns the batch ID with the decoded blocks
+func DecodeBatch(artifact []byte) (uint64, []*model.Block, error) {
+	var (
+		id        uint64
+		algorithm byte
+		data      []byte
+		last      bool
+	)
+	for number := 0; len(artifact) > 0; number++ {
+		if last {
+			return 0, nil, errors.New("data after the last frame")
+		}
+		f, rest, err := parseFrame(artifact)
+		if err != nil {
+			return 0, nil, err
+		}
+		if number == 0 {
+			id, algorithm = f.BatchID, f.Algorithm
+		} else if f.BatchID != id || f.Algorithm != algorithm {
+			return 0, nil, fmt.Errorf("frame %d belongs to another batch", f.Number)
+		}
+		if int(f.Number) != number {
+			return 0, nil, fmt.Errorf("frame %d out of order, expected %d", f.Number, number)
+		}
+		data = append(data, f.Data...)
+		last = f.Last
+		artifact = rest
+	}
+	if !last {
+		return 0, nil, errors.New("missing last frame")
+	}
+
+	name, err := algorithmName(algorithm)
+	if err != nil {
+		return 0, nil, err
+	}
+	raw, err := decompress(name, data)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to decompress batch: %v", err)
+	}
+	var encoded [][]byte
+	if err := rlp.DecodeBytes(raw, &encoded); err != nil {
+		return 0, nil, fmt.Errorf("invalid batch encoding: %v", err)
+	}
+	blocks := make([]*model.Block, len(encoded))
+	for i, data := range encoded {
+		if blocks[i], err = model.DecodeBlock(data); err != nil {
+			return 0, nil, fmt.Errorf("invalid block %d of batch: %v", i, err)
+		}
+	}
+	return id, blocks, nil
+}
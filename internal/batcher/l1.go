@@ -0,0 +1,146 @@
+package batcher
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// receiptPollPeriod is the time between receipt lookups of a sent frame
+const receiptPollPeriod = 2 * time.Second
+
+// Submitter posts the frames of batches to an L1 chain
+type Submitter interface {
+	// Submit posts a frame and waits for its inclusion, returning the hash of
+	// the transaction carrying it and the L1 block that included it
+	Submit(ctx context.Context, frame []byte) (common.Hash, uint64, error)
+	Close()
+}
+
+// L1 posts frames as the calldata of transactions to a batch inbox address,
+// the way rollup batch posters do
+type L1 struct {
+	client  *ethclient.Client
+	key     *ecdsa.PrivateKey
+	from    common.Address
+	inbox   common.Address
+	chainID *big.Int
+}
+
+// NewL1 connects to the JSON-RPC endpoint of an L1 node. Transactions are
+// signed with key and sent to the inbox address.
+func NewL1(ctx context.Context, url string, key *ecdsa.PrivateKey, inbox common.Address) (*L1, error) {
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the L1 node: %v", err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to get the L1 chain ID: %v", err)
+	}
+	return &L1{
+		client:  client,
+		key:     key,
+		from:    crypto.PubkeyToAddress(key.PublicKey),
+		inbox:   inbox,
+		chainID: chainID,
+	}, nil
+}
+
+// Sender returns the address the frames are sent from
+func (l *L1) Sender() common.Address {
+	return l.from
+}
+
+// Submit sends the frame to the inbox and waits for its inclusion
+func (l *L1) Submit(ctx context.Context, frame []byte) (common.Hash, uint64, error) {
+	tx, err := l.newTransaction(ctx, frame)
+	if err != nil {
+		return common.Hash{}, 0, err
+	}
+	if err := l.client.SendTransaction(ctx, tx); err != nil {
+		return common.Hash{}, 0, fmt.Errorf("failed to send batch transaction: %v", err)
+	}
+
+	receipt, err := l.waitForReceipt(ctx, tx.Hash())
+	if err != nil {
+		return common.Hash{}, 0, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return common.Hash{}, 0, fmt.Errorf("batch transaction %s failed", tx.Hash().Hex())
+	}
+	return tx.Hash(), receipt.BlockNumber.Uint64(), nil
+}
+
+// newTransaction creates a signed dynamic-fee transaction carrying the frame
+func (l *L1) newTransaction(ctx context.Context, frame []byte) (*types.Transaction, error) {
+	nonce, err := l.client.PendingNonceAt(ctx, l.from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the nonce: %v", err)
+	}
+	tip, err := l.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the gas tip: %v", err)
+	}
+	head, err := l.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the latest header: %v", err)
+	}
+	if head.BaseFee == nil {
+		return nil, errors.New("the L1 chain does not support EIP-1559 fees")
+	}
+	gas, err := l.client.EstimateGas(ctx, ethereum.CallMsg{From: l.from, To: &l.inbox, Data: frame})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate the gas: %v", err)
+	}
+
+	// Twice the current base fee keeps the transaction valid while fees rise
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   l.chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &l.inbox,
+		Data:      frame,
+	})
+	return types.SignTx(tx, types.LatestSignerForChainID(l.chainID), l.key)
+}
+
+// waitForReceipt polls for the receipt of a transaction until the context ends
+func (l *L1) waitForReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	ticker := time.NewTicker(receiptPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := l.client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("failed to get the receipt of %s: %v", hash.Hex(), err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("batch transaction %s not included: %v", hash.Hex(), ctx.Err())
+		}
+	}
+}
+
+// Close disconnects from the node
+func (l *L1) Close() {
+	l.client.Close()
+}
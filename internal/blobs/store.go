@@ -0,0 +1,388 @@
+package blobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+)
+
+// fileExt is the extension of the sidecar files
+const fileExt = ".json"
+
+// Config holds configuration for the sidecar store
+type Config struct {
+	Dir        string        // Directory of the sidecar files (kept in memory if empty)
+	KeepBlocks uint64        // Blocks an included sidecar is kept for (0 = no block limit)
+	KeepAge    time.Duration // Age after which a sidecar is removed, included or not (0 = no age limit)
+	Interval   time.Duration // Time between pruning passes
+}
+
+// DefaultConfig returns the default configuration, which keeps sidecars for
+// the 4096 epochs Ethereum consensus clients serve them
+func DefaultConfig() *Config {
+	return &Config{
+		KeepAge:  18 * 24 * time.Hour,
+		Interval: 10 * time.Minute,
+	}
+}
+
+// Sidecar holds the blobs of a transaction with their KZG commitments and proofs
+type Sidecar struct {
+	TxHash      string               `json:"tx_hash"`
+	BlobHashes  []common.Hash        `json:"blob_hashes"` // Versioned hashes in the order of the blobs
+	Blobs       []kzg4844.Blob       `json:"blobs"`
+	Commitments []kzg4844.Commitment `json:"commitments"`
+	Proofs      []kzg4844.Proof      `json:"proofs"`
+	Received    time.Time            `json:"received"`
+}
+
+// Blob is a single blob of a sidecar
+type Blob struct {
+	VersionedHash common.Hash        `json:"versioned_hash"`
+	TxHash        string             `json:"tx_hash"`
+	Index         int                `json:"index"` // Position of the blob in the transaction
+	Blob          kzg4844.Blob       `json:"blob"`
+	Commitment    kzg4844.Commitment `json:"commitment"`
+	Proof         kzg4844.Proof      `json:"proof"`
+}
+
+// Status reports the contents of the store and the retention policy
+type Status struct {
+	Sidecars     int    `json:"sidecars"`
+	Blobs        int    `json:"blobs"`
+	Pending      int    `json:"pending"` // Sidecars of transactions not included yet
+	KeepBlocks   uint64 `json:"keep_blocks"`
+	KeepAge      string `json:"keep_age"`
+	TotalRemoved uint64 `json:"total_removed"` // Sidecars pruned since the start
+}
+
+// entry tracks a stored sidecar
+type entry struct {
+	hashes   []common.Hash
+	received time.Time
+	block    uint64   // Block that included the transaction (0 while pending)
+	sidecar  *Sidecar // Kept in memory without a directory
+}
+
+// Store keeps the blob sidecars of admitted transactions, one file per
+// transaction, apart from the blocks: blocks reference blobs by their
+// versioned hashes only. Sidecars are pruned under their own retention,
+// independent of the block store.
+type Store struct {
+	processor *processor.BlockProcessor
+	config    *Config
+
+	mu           sync.RWMutex
+	entries      map[string]*entry      // By transaction ID
+	byHash       map[common.Hash]string // Transaction ID by versioned hash
+	totalRemoved uint64
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New opens the sidecar store for the transactions of the processor. Stored
+// sidecars are loaded, and those of included transactions take the block
+// they were included in from the transaction index.
+func New(bp *processor.BlockProcessor, config *Config) (*Store, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig().Interval
+	}
+	s := &Store{
+		processor: bp,
+		config:    config,
+		entries:   make(map[string]*entry),
+		byHash:    make(map[common.Hash]string),
+		quit:      make(chan struct{}),
+	}
+	if config.Dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %v", err)
+	}
+	files, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob directory: %v", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileExt) {
+			continue
+		}
+		sidecar, err := s.read(strings.TrimSuffix(file.Name(), fileExt))
+		if err != nil {
+			logging.Warnf("Skipping blob sidecar %s: %v", file.Name(), err)
+			continue
+		}
+		e := &entry{hashes: sidecar.BlobHashes, received: sidecar.Received}
+		if loc, ok := bp.LookupTransaction(sidecar.TxHash); ok {
+			e.block = loc.BlockNumber
+		}
+		s.add(sidecar.TxHash, e)
+	}
+	return s, nil
+}
+
+// Start marks the sidecars of included transactions and prunes the store
+// every interval until it is closed
+func (s *Store) Start() {
+	blocks := make(chan events.BlockSealed, 64)
+	sub := s.processor.Events().BlockSealed.Subscribe(blocks)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer sub.Unsubscribe()
+
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case ev := <-blocks:
+				s.include(ev.Block)
+			case <-ticker.C:
+				s.Prune()
+			case <-sub.Err():
+				return
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background work
+func (s *Store) Close() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// Put stores the sidecar of an admitted transaction. The sidecar must have
+// been verified against the versioned hashes of the transaction.
+func (s *Store) Put(txID string, sidecar *types.BlobTxSidecar) error {
+	sc := &Sidecar{
+		TxHash:      txID,
+		BlobHashes:  sidecar.BlobHashes(),
+		Blobs:       sidecar.Blobs,
+		Commitments: sidecar.Commitments,
+		Proofs:      sidecar.Proofs,
+		Received:    time.Now(),
+	}
+	e := &entry{hashes: sc.BlobHashes, received: sc.Received}
+	if s.config.Dir == "" {
+		e.sidecar = sc
+	} else if err := s.write(sc); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(txID, e)
+	return nil
+}
+
+// Remove deletes the sidecar of a transaction, e.g. one that was not admitted
+func (s *Store) Remove(txID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(txID)
+}
+
+// Get returns the sidecar of a transaction
+func (s *Store) Get(txID string) (*Sidecar, bool) {
+	s.mu.RLock()
+	e, ok := s.entries[txID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if e.sidecar != nil {
+		return e.sidecar, true
+	}
+
+	sidecar, err := s.read(txID)
+	if err != nil {
+		// The file may have been pruned since the lookup
+		if !errors.Is(err, os.ErrNotExist) {
+			logging.Errorf("Failed to read blob sidecar of %s: %v", txID, err)
+		}
+		return nil, false
+	}
+	return sidecar, true
+}
+
+// GetBlob returns a blob by its versioned hash
+func (s *Store) GetBlob(hash common.Hash) (*Blob, bool) {
+	s.mu.RLock()
+	txID, ok := s.byHash[hash]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	sidecar, ok := s.Get(txID)
+	if !ok {
+		return nil, false
+	}
+	for i, h := range sidecar.BlobHashes {
+		if h == hash {
+			return &Blob{
+				VersionedHash: hash,
+				TxHash:        txID,
+				Index:         i,
+				Blob:          sidecar.Blobs[i],
+				Commitment:    sidecar.Commitments[i],
+				Proof:         sidecar.Proofs[i],
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// Prune removes the sidecars outside the retention: those included more than
+// KeepBlocks blocks before the head and those older than KeepAge. It returns
+// the number of removed sidecars.
+func (s *Store) Prune() int {
+	_, head := s.processor.LatestBlock()
+	now := time.Now()
+
+	s.mu.Lock()
+	var removed int
+	for txID, e := range s.entries {
+		expired := s.config.KeepAge > 0 && now.Sub(e.received) > s.config.KeepAge
+		if e.block > 0 && s.config.KeepBlocks > 0 && head >= e.block+s.config.KeepBlocks {
+			expired = true
+		}
+		if expired {
+			s.remove(txID)
+			removed++
+		}
+	}
+	s.totalRemoved += uint64(removed)
+	s.mu.Unlock()
+
+	if removed > 0 {
+		logging.Infof("Pruned %d blob sidecars in %v", removed, time.Since(now))
+	}
+	return removed
+}
+
+// Status returns the contents of the store and the retention policy
+func (s *Store) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := Status{
+		Sidecars:     len(s.entries),
+		KeepBlocks:   s.config.KeepBlocks,
+		KeepAge:      s.config.KeepAge.String(),
+		TotalRemoved: s.totalRemoved,
+	}
+	for _, e := range s.entries {
+		status.Blobs += len(e.hashes)
+		if e.block == 0 {
+			status.Pending++
+		}
+	}
+	return status
+}
+
+// include records the block of the blob transactions it includes
+func (s *Store) include(block *model.Block) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tx := range block.Transactions {
+		if len(tx.BlobHashes) == 0 {
+			continue
+		}
+		if e, ok := s.entries[tx.ID]; ok {
+			e.block = block.Number
+		}
+	}
+}
+
+// add indexes an entry; the caller holds the lock unless the store is being opened
+func (s *Store) add(txID string, e *entry) {
+	s.entries[txID] = e
+	for _, hash := range e.hashes {
+		s.byHash[hash] = txID
+	}
+}
+
+// remove deletes an entry and its file; the caller holds the lock
+func (s *Store) remove(txID string) {
+	e, ok := s.entries[txID]
+	if !ok {
+		return
+	}
+	delete(s.entries, txID)
+	for _, hash := range e.hashes {
+		// Another transaction may carry the same blob
+		if s.byHash[hash] == txID {
+			delete(s.byHash, hash)
+		}
+	}
+	if e.sidecar == nil {
+		if err := os.Remove(s.path(txID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logging.Errorf("Failed to remove blob sidecar of %s: %v", txID, err)
+		}
+	}
+}
+
+// path returns the file of the sidecar of a transaction
+func (s *Store) path(txID string) string {
+	return filepath.Join(s.config.Dir, txID+fileExt)
+}
+
+// write stores a sidecar file atomically
+func (s *Store) write(sidecar *Sidecar) error {
+	data, err := json.Marshal(sidecar)
+	if err != nil {
+		return err
+	}
+	path := s.path(sidecar.TxHash)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write blob sidecar: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write blob sidecar: %v", err)
+	}
+	return nil
+}
+
+// read loads a sidecar file
+func (s *Store) read(txID string) (*Sidecar, error) {
+	data, err := os.ReadFile(s.path(txID))
+	if err != nil {
+		return nil, err
+	}
+	var sidecar Sidecar
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return nil, err
+	}
+	if len(sidecar.Blobs) != len(sidecar.BlobHashes) || len(sidecar.Commitments) != len(sidecar.BlobHashes) || len(sidecar.Proofs) != len(sidecar.BlobHashes) {
+		return nil, errors.New("inconsistent number of blobs")
+	}
+	return &sidecar, nil
+}
@@ -0,0 +1,138 @@
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"flashblock/internal/model"
+)
+
+// Errors
+var (
+	ErrDuplicate    = errors.New("bundle already submitted")
+	ErrPoolFull     = errors.New("bundle pool is full")
+	ErrTargetPassed = errors.New("target block already created")
+	ErrEmpty        = errors.New("bundle has no transactions")
+	ErrTooLarge     = errors.New("bundle has too many transactions")
+)
+
+// Config holds configuration for the bundle pool
+type Config struct {
+	MaxBundles      int // Maximum pending bundles (0 = unlimited)
+	MaxTransactions int // Maximum transactions per bundle (0 = unlimited)
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MaxBundles:      1000,
+		MaxTransactions: 16,
+	}
+}
+
+// Pool keeps submitted bundles until their target block is built. Bundles are
+// private: unlike mempool transactions, they are neither gossiped nor
+// published before they are included.
+type Pool struct {
+	config  *Config
+	mu      sync.Mutex
+	byBlock map[uint64][]*model.Bundle // Pending bundles by target block in arrival order
+	ids     map[string]struct{}
+	count   int
+}
+
+// NewPool creates a bundle pool
+func NewPool(config *Config) *Pool {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &Pool{
+		config:  config,
+		byBlock: make(map[uint64][]*model.Bundle),
+		ids:     make(map[string]struct{}),
+	}
+}
+
+// Add keeps a bundle for its target block, which must come after the head
+func (p *Pool) Add(b *model.Bundle, head uint64) error {
+	if len(b.Transactions) == 0 {
+		return ErrEmpty
+	}
+	if p.config.MaxTransactions > 0 && len(b.Transactions) > p.config.MaxTransactions {
+		return fmt.Errorf("%w: %d, maximum %d", ErrTooLarge, len(b.Transactions), p.config.MaxTransactions)
+	}
+	if b.BlockNumber <= head {
+		return fmt.Errorf("%w: target %d, head %d", ErrTargetPassed, b.BlockNumber, head)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.ids[b.ID]; ok {
+		return ErrDuplicate
+	}
+	if p.config.MaxBundles > 0 && p.count >= p.config.MaxBundles {
+		return ErrPoolFull
+	}
+	if b.ReceivedAt.IsZero() {
+		b.ReceivedAt = time.Now()
+	}
+	p.byBlock[b.BlockNumber] = append(p.byBlock[b.BlockNumber], b)
+	p.ids[b.ID] = struct{}{}
+	p.count++
+	return nil
+}
+
+// Candidates returns the bundles targeting the given block that may be
+// included at time t, in arrival order. They stay in the pool until Discard is
+// called with a block at or after their target, so the bundles of a build that
+// is aborted are included in the next attempt. Bundles targeting earlier
+// blocks can no longer be included and are discarded.
+func (p *Pool) Candidates(number uint64, t time.Time) []*model.Bundle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if number > 0 {
+		p.discard(number - 1)
+	}
+	var candidates []*model.Bundle
+	for _, b := range p.byBlock[number] {
+		if b.Eligible(t) {
+			candidates = append(candidates, b)
+		}
+	}
+	return candidates
+}
+
+// Discard removes the bundles targeting the given block or earlier ones, once
+// the block is sealed, whether or not they were included
+func (p *Pool) Discard(number uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.discard(number)
+}
+
+// discard removes the bundles targeting the given block or earlier ones
+func (p *Pool) discard(number uint64) {
+	for target, bundles := range p.byBlock {
+		if target > number {
+			continue
+		}
+		for _, b := range bundles {
+			delete(p.ids, b.ID)
+			p.count--
+		}
+		delete(p.byBlock, target)
+	}
+}
+
+// Len returns the number of pending bundles
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.count
+}
@@ -0,0 +1,62 @@
+package bundle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"flashblock/internal/model"
+)
+
+func testBundle(id string, target uint64, txs int) *model.Bundle {
+	b := &model.Bundle{ID: id, BlockNumber: target}
+	for i := 0; i < txs; i++ {
+		b.Transactions = append(b.Transactions, &model.Transaction{ID: id + "-" + string(rune('a'+i))})
+	}
+	return b
+}
+
+func TestCandidatesUntilDiscarded(t *testing.T) {
+	p := NewPool(nil)
+	now := time.Unix(1_700_000_000, 0)
+	late := testBundle("late", 2, 1)
+	late.MinTimestamp = uint64(now.Unix()) + 10
+	for _, b := range []*model.Bundle{testBundle("a", 2, 1), testBundle("b", 2, 2), late, testBundle("next", 3, 1)} {
+		if err := p.Add(b, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Candidates are kept, so a build that is aborted gets them again
+	for attempt := 0; attempt < 2; attempt++ {
+		candidates := p.Candidates(2, now)
+		if len(candidates) != 2 || candidates[0].ID != "a" || candidates[1].ID != "b" {
+			t.Fatalf("attempt %d: candidates = %v, want a and b in arrival order", attempt, candidates)
+		}
+	}
+	if p.Len() != 4 {
+		t.Fatalf("pool has %d bundles, want 4", p.Len())
+	}
+
+	// Sealing the target block drops its bundles, included or not
+	p.Discard(2)
+	if p.Len() != 1 {
+		t.Fatalf("pool has %d bundles after the seal, want 1", p.Len())
+	}
+	if err := p.Add(testBundle("a", 3, 1), 2); err != nil {
+		t.Errorf("resubmission for a later block: %v", err)
+	}
+}
+
+func TestCandidatesDropPassedTargets(t *testing.T) {
+	p := NewPool(nil)
+	if err := p.Add(testBundle("a", 2, 1), 1); err != nil {
+		t.Fatal(err)
+	}
+	if candidates := p.Candidates(3, time.Now()); len(candidates) != 0 || p.Len() != 0 {
+		t.Errorf("%d candidates, %d pending, want the passed bundle dropped", len(candidates), p.Len())
+	}
+	if err := p.Add(testBundle("b", 1, 1), 1); !errors.Is(err, ErrTargetPassed) {
+		t.Errorf("Add for the head = %v, want %v", err, ErrTargetPassed)
+	}
+}
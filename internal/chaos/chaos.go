@@ -0,0 +1,68 @@
+package chaos
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Config holds the faults to inject
+type Config struct {
+	BlockLatency     time.Duration // Added to every block build
+	DropRate         float64       // Fraction of submissions that are silently dropped (0-1)
+	AttestationDelay time.Duration // Added to the attestation step of every block
+}
+
+// Injector injects artificial faults into the server so failover, watchdogs
+// and client retries can be tested against the real binary. All methods of a
+// nil Injector are no-ops, so components call them unconditionally.
+type Injector struct {
+	config Config
+}
+
+// New creates an injector for the given faults
+func New(config *Config) *Injector {
+	return &Injector{config: *config}
+}
+
+// DelayBlock sleeps for the configured block build latency
+func (i *Injector) DelayBlock() {
+	if i != nil && i.config.BlockLatency > 0 {
+		time.Sleep(i.config.BlockLatency)
+	}
+}
+
+// DelayAttestation sleeps for the configured attestation delay
+func (i *Injector) DelayAttestation() {
+	if i != nil && i.config.AttestationDelay > 0 {
+		time.Sleep(i.config.AttestationDelay)
+	}
+}
+
+// DropSubmission reports whether a submission should be dropped
+func (i *Injector) DropSubmission() bool {
+	return i != nil && i.config.DropRate > 0 && rand.Float64() < i.config.DropRate
+}
+
+// String describes the injected faults
+func (i *Injector) String() string {
+	if i == nil {
+		return "none"
+	}
+
+	var faults []string
+	if i.config.BlockLatency > 0 {
+		faults = append(faults, fmt.Sprintf("block latency %v", i.config.BlockLatency))
+	}
+	if i.config.DropRate > 0 {
+		faults = append(faults, fmt.Sprintf("%g%% of submissions dropped", i.config.DropRate*100))
+	}
+	if i.config.AttestationDelay > 0 {
+		faults = append(faults, fmt.Sprintf("attestation delay %v", i.config.AttestationDelay))
+	}
+	if len(faults) == 0 {
+		return "none"
+	}
+	return strings.Join(faults, ", ")
+}
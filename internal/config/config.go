@@ -0,0 +1,1282 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/trustedtime"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gopkg.in/yaml.v2"
+)
+
+// Config holds the complete server configuration
+type Config struct {
+	DataDir     string            `yaml:"datadir"` // Data directory for persistent state (disabled if empty)
+	RPC         RPCConfig         `yaml:"rpc"`
+	Block       BlockConfig       `yaml:"block"`
+	Retention   RetentionConfig   `yaml:"retention"`
+	Mempool     MempoolConfig     `yaml:"mempool"`
+	FeeMarket   FeeMarketConfig   `yaml:"fee_market"`
+	Shedding    SheddingConfig    `yaml:"load_shedding"`
+	Preconf     PreconfConfig     `yaml:"preconf"`
+	Signer      SignerConfig      `yaml:"signer"`
+	Blobs       BlobsConfig       `yaml:"blobs"`
+	Extensions  ExtensionsConfig  `yaml:"extensions"`
+	Bundles     BundlesConfig     `yaml:"bundles"`
+	Encrypted   EncryptedConfig   `yaml:"encrypted"`
+	Attestation AttestationConfig `yaml:"attestation"`
+	TrustedTime TrustedTimeConfig `yaml:"trusted_time"`
+	Genesis     GenesisConfig     `yaml:"genesis"`
+	HA          HAConfig          `yaml:"ha"`
+	Role        RoleConfig        `yaml:"role"`
+	P2P         P2PConfig         `yaml:"p2p"`
+	Flashblocks FlashblocksConfig `yaml:"flashblocks"`
+	Relay       RelayConfig       `yaml:"relay"`
+	DA          DAConfig          `yaml:"da"`
+	Batcher     BatcherConfig     `yaml:"batcher"`
+	Indexer     IndexerConfig     `yaml:"indexer"`
+	Chains      []ChainConfig     `yaml:"chains"` // Additional chains served next to the primary chain
+	Log         LogConfig         `yaml:"log"`
+
+	Repair bool `yaml:"-"` // Truncate an invalid block store tail at startup (command line only)
+	Check  bool `yaml:"-"` // Run the preflight checks and exit (command line only)
+
+	Chaos ChaosConfig `yaml:"-"` // Fault injection for resilience testing (command line only)
+}
+
+// RPCConfig holds the JSON-RPC server settings
+type RPCConfig struct {
+	Addr        string            `yaml:"addr"`         // Listen address for HTTP (and WebSocket unless ws_addr is set)
+	WSAddr      string            `yaml:"ws_addr"`      // Separate listen address for WebSocket (optional)
+	AdminAddr   string            `yaml:"admin_addr"`   // Listen address for the admin and metrics endpoints (disabled if empty)
+	UnixSocket  string            `yaml:"unix_socket"`  // Unix socket path for JSON-RPC and the admin namespace (disabled if empty)
+	UnixMode    string            `yaml:"unix_mode"`    // Octal permissions of the unix socket file
+	AuthTokens  []string          `yaml:"auth_tokens"`  // Accepted bearer tokens (auth disabled if empty)
+	CORSOrigins []string          `yaml:"cors_origins"` // Allowed browser origins ("*" allows any)
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Concurrency ConcurrencyConfig `yaml:"concurrency"`
+}
+
+// ConcurrencyConfig holds the overload protection settings of the JSON-RPC server
+type ConcurrencyConfig struct {
+	MaxRequests  int           `yaml:"max_requests"`  // Concurrently executing HTTP requests and WebSocket calls (0 = unlimited)
+	QueueSize    int           `yaml:"queue_size"`    // Requests waiting for a slot before "server busy" is returned
+	QueueTimeout time.Duration `yaml:"queue_timeout"` // Maximum time a queued request waits for a slot
+}
+
+// RateLimitConfig holds the per-client submission rate limit
+type RateLimitConfig struct {
+	RPS   float64 `yaml:"rps"`   // Submissions per second per client IP (0 = unlimited)
+	Burst int     `yaml:"burst"` // Submission burst per client IP (defaults to one second of rps)
+}
+
+// UnixFileMode parses the permissions of the unix socket file
+func (c *RPCConfig) UnixFileMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(c.UnixMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("rpc.unix_mode: invalid permissions %q", c.UnixMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// MaxBlockInterval is the longest supported block interval
+const MaxBlockInterval = time.Minute
+
+// BlockConfig holds the block production settings
+type BlockConfig struct {
+	Interval        time.Duration `yaml:"interval"`          // Block creation interval
+	MaxTransactions int           `yaml:"max_transactions"`  // Maximum transactions per block (0 = unlimited)
+	MaxStoredBlocks int           `yaml:"max_stored_blocks"` // Recent blocks kept in memory
+	FeeRecipient    string        `yaml:"fee_recipient"`     // Address credited with the priority fees
+}
+
+// RetentionConfig holds the block store retention settings. A block is pruned
+// once it is outside every configured window; without windows all blocks are kept.
+type RetentionConfig struct {
+	KeepBlocks         uint64        `yaml:"keep_blocks"`         // Most recent blocks kept (0 = no block limit)
+	KeepDays           int           `yaml:"keep_days"`           // Days of blocks kept (0 = no age limit)
+	CheckpointInterval uint64        `yaml:"checkpoint_interval"` // Blocks whose number is a multiple are kept as checkpoints (0 = none)
+	Interval           time.Duration `yaml:"interval"`            // Time between pruning passes
+	State              bool          `yaml:"state"`               // Prune the account states below the finalized checkpoint
+	StateInterval      time.Duration `yaml:"state_interval"`      // Time between state pruning and compaction passes
+}
+
+// Enabled reports whether blocks are pruned
+func (r RetentionConfig) Enabled() bool {
+	return r.KeepBlocks > 0 || r.KeepDays > 0
+}
+
+// MempoolConfig holds the mempool settings
+type MempoolConfig struct {
+	MaxSize       int           `yaml:"max_size"`        // Maximum pending transactions (0 = unlimited)
+	MinPriority   int           `yaml:"min_priority"`    // Fee floor for new transactions
+	Blacklist     []string      `yaml:"blacklist"`       // Sender or recipient addresses that are rejected
+	PrivateTTL    time.Duration `yaml:"private_ttl"`     // Default privacy TTL of private transactions
+	MaxPrivateTTL time.Duration `yaml:"max_private_ttl"` // Maximum privacy TTL of private transactions (0 = unlimited)
+	Journal       JournalConfig `yaml:"journal"`         // Write-ahead log of admitted transactions in the data directory
+}
+
+// JournalConfig holds the settings of the mempool journal. A submission is
+// acknowledged once its record is committed; records arriving during a commit
+// are committed together by the next one.
+type JournalConfig struct {
+	Sync        bool          `yaml:"sync"`         // Sync commits to disk, so acknowledged transactions survive a machine crash
+	CommitDelay time.Duration `yaml:"commit_delay"` // Time a commit waits to batch more records (0 = none)
+}
+
+// FeeMarketConfig holds the congestion pricing settings. While the mempool or
+// the recent blocks are fuller than their targets, the minimum priority of new
+// transactions rises above mempool.min_priority; it decays back when idle.
+type FeeMarketConfig struct {
+	Enabled       bool          `yaml:"enabled"`        // Raise the fee floor under congestion
+	MempoolTarget float64       `yaml:"mempool_target"` // Fraction of mempool.max_size above which the floor rises (0 = not measured)
+	BlockTarget   float64       `yaml:"block_target"`   // Fraction of block.max_transactions above which the floor rises (0 = not measured)
+	MaxPriority   int           `yaml:"max_priority"`   // Ceiling of the fee floor (0 = unlimited)
+	ChangeRate    float64       `yaml:"change_rate"`    // Maximum relative change of the floor per adjustment
+	Interval      time.Duration `yaml:"interval"`       // Time between adjustments
+}
+
+// SheddingConfig holds the load shedding settings. While the RPC queue or the
+// block build time is at its threshold, a growing share of the submissions is
+// rejected, lowest priority first; reads are never shed.
+type SheddingConfig struct {
+	Enabled           bool          `yaml:"enabled"`            // Shed low-priority submissions under overload
+	QueueThreshold    int           `yaml:"queue_threshold"`    // Queued HTTP requests at which the node is overloaded (0 = not measured)
+	LatencyThreshold  time.Duration `yaml:"latency_threshold"`  // Block build time at which the node is overloaded (0 = not measured)
+	ProtectedPriority int           `yaml:"protected_priority"` // Priority at and above which submissions are never shed (0 = none)
+	MaxShare          float64       `yaml:"max_share"`          // Largest share of the submitted priorities shed
+	Step              float64       `yaml:"step"`               // Change of the shed share per adjustment
+	Interval          time.Duration `yaml:"interval"`           // Time between adjustments
+	RetryAfter        time.Duration `yaml:"retry_after"`        // Wait suggested to shed clients
+}
+
+// PreconfConfig holds the settings of the signed preconfirmation receipts
+// returned for admitted transactions
+type PreconfConfig struct {
+	Enabled     bool   `yaml:"enabled"`      // Sign a receipt for every admitted transaction
+	KeyFile     string `yaml:"key_file"`     // Signing key, relative to the data directory if one is set
+	Window      uint64 `yaml:"window"`       // Blocks after the head a transaction is promised to be included in
+	MaxReceipts int    `yaml:"max_receipts"` // Receipts kept for flash_getPreconfirmation
+}
+
+// Signer types
+const (
+	SignerLocal  = "local"   // Key files in the data directory
+	SignerRemote = "remote"  // JSON-RPC remote signer implementing signer_signHash
+	SignerAWSKMS = "aws-kms" // ECC_SECG_P256K1 key in AWS KMS
+	SignerGCPKMS = "gcp-kms" // EC_SIGN_SECP256K1_SHA256 key version in Cloud KMS
+)
+
+// SignerConfig selects where the builder identity key signing relay blocks and
+// preconfirmation receipts is kept. With an external signer, the key never
+// enters process memory and the relay and preconfirmation key files are not used.
+type SignerConfig struct {
+	Type      string        `yaml:"type"`       // local, remote, aws-kms or gcp-kms
+	URL       string        `yaml:"url"`        // Endpoint of the remote signer, or of the KMS API instead of the default
+	AuthToken string        `yaml:"auth_token"` // Bearer token of the remote signer
+	Address   string        `yaml:"address"`    // Address of the key of the remote signer
+	KeyID     string        `yaml:"key_id"`     // AWS KMS key ID or ARN, or Cloud KMS key version resource name
+	Region    string        `yaml:"region"`     // AWS region of the key
+	Timeout   time.Duration `yaml:"timeout"`    // Deadline of a signature
+}
+
+// BlobsConfig holds the settings of EIP-4844 blob transactions. Their sidecars
+// are kept apart from the blocks and pruned under their own retention.
+type BlobsConfig struct {
+	Enabled    bool          `yaml:"enabled"`     // Accept blob transactions and keep their sidecars
+	KeepBlocks uint64        `yaml:"keep_blocks"` // Blocks a sidecar is kept for after inclusion (0 = no block limit)
+	KeepAge    time.Duration `yaml:"keep_age"`    // Age after which a sidecar is removed (0 = no age limit)
+	Interval   time.Duration `yaml:"interval"`    // Time between pruning passes
+}
+
+// ExtensionsConfig selects the admission validators and the ordering strategy
+// registered by extensions (see pkg/extension)
+type ExtensionsConfig struct {
+	Plugins    []string          `yaml:"plugins"`    // Go plugins loaded at startup, which register extensions
+	Validators []ExtensionConfig `yaml:"validators"` // Applied to new transactions in order
+	Orderer    ExtensionConfig   `yaml:"orderer"`    // Orders the transactions of new blocks (priority if unset)
+}
+
+// ExtensionConfig selects a registered extension and its options
+type ExtensionConfig struct {
+	Name    string            `yaml:"name"`
+	Options map[string]string `yaml:"options"`
+}
+
+// AttestationConfig holds the block attestation settings
+type AttestationConfig struct {
+	Enabled  bool         `yaml:"enabled"`  // Attach attestation quotes to blocks
+	Provider string       `yaml:"provider"` // Quote provider (tdx)
+	Policy   PolicyConfig `yaml:"policy"`   // Quotes of the blocks imported by replica nodes
+}
+
+// PolicyConfig holds the expected TDX measurements of the builder followed by
+// a replica, as hex strings. Empty measurements are not checked.
+type PolicyConfig struct {
+	RequireQuote    bool     `yaml:"require_quote"`    // Reject blocks without a quote
+	CheckCollateral bool     `yaml:"check_collateral"` // Check the TCB collateral and revocations with the Intel PCS
+	MrTd            string   `yaml:"mr_td"`            // Measurement of the initial TD contents (48 bytes)
+	MrSeam          string   `yaml:"mr_seam"`          // Measurement of the TDX module (48 bytes)
+	MrConfigID      string   `yaml:"mr_config_id"`     // Software-defined configuration ID (48 bytes)
+	MrOwner         string   `yaml:"mr_owner"`         // Software-defined owner ID (48 bytes)
+	TdAttributes    string   `yaml:"td_attributes"`    // TD attributes (8 bytes)
+	Rtmrs           []string `yaml:"rtmrs"`            // Runtime measurement registers (4 of 48 bytes)
+
+	RequireTrustedTime bool          `yaml:"require_trusted_time"` // Reject blocks without a trusted timestamp
+	MaxTimeUncertainty time.Duration `yaml:"max_time_uncertainty"` // Reject blocks whose timestamp is less certain (0 = any)
+}
+
+// TrustedTimeConfig holds the trusted time source of the block timestamps
+type TrustedTimeConfig struct {
+	Enabled        bool                    `yaml:"enabled"`         // Timestamp blocks with Roughtime instead of the host clock
+	Servers        []RoughtimeServerConfig `yaml:"servers"`         // Servers queried at every synchronization
+	Quorum         int                     `yaml:"quorum"`          // Servers that must agree on the time (0 = a majority)
+	SyncInterval   time.Duration           `yaml:"sync_interval"`   // Time between synchronizations
+	Timeout        time.Duration           `yaml:"timeout"`         // Deadline of a query
+	MaxDriftPPM    float64                 `yaml:"max_drift_ppm"`   // Maximum rate error of the local monotonic clock
+	MaxUncertainty time.Duration           `yaml:"max_uncertainty"` // Blocks are not built while the error bound of the time is larger
+	Fallback       bool                    `yaml:"fallback"`        // Timestamp with the host clock, without a proof, instead of pausing
+}
+
+// RoughtimeServerConfig holds a Roughtime server and its pinned public key
+type RoughtimeServerConfig struct {
+	Name      string `yaml:"name"`
+	Address   string `yaml:"address"`    // UDP host:port
+	PublicKey string `yaml:"public_key"` // Long-term Ed25519 key, base64 or hex
+}
+
+// Sizes of the TDX measurements in bytes
+const (
+	MeasurementSize  = 48
+	TdAttributesSize = 8
+	RtmrCount        = 4
+)
+
+// ParseMeasurement decodes a hex measurement of the given size in bytes, with
+// or without a 0x prefix. An empty string is no measurement.
+func ParseMeasurement(s string, size int) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	value, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(value) != size {
+		return nil, fmt.Errorf("invalid measurement %q (expected %d hex bytes)", s, size)
+	}
+	return value, nil
+}
+
+// validate checks the sizes of the measurements
+func (p *PolicyConfig) validate() error {
+	measurements := []struct {
+		name, value string
+		size        int
+	}{
+		{"mr_td", p.MrTd, MeasurementSize},
+		{"mr_seam", p.MrSeam, MeasurementSize},
+		{"mr_config_id", p.MrConfigID, MeasurementSize},
+		{"mr_owner", p.MrOwner, MeasurementSize},
+		{"td_attributes", p.TdAttributes, TdAttributesSize},
+	}
+	for _, m := range measurements {
+		if _, err := ParseMeasurement(m.value, m.size); err != nil {
+			return fmt.Errorf("attestation.policy.%s: %v", m.name, err)
+		}
+	}
+	if p.MaxTimeUncertainty < 0 {
+		return errors.New("attestation.policy.max_time_uncertainty cannot be negative")
+	}
+	if len(p.Rtmrs) > 0 && len(p.Rtmrs) != RtmrCount {
+		return fmt.Errorf("attestation.policy.rtmrs must hold %d registers", RtmrCount)
+	}
+	for _, rtmr := range p.Rtmrs {
+		if _, err := ParseMeasurement(rtmr, MeasurementSize); err != nil || rtmr == "" {
+			return fmt.Errorf("attestation.policy.rtmrs: invalid measurement %q (expected %d hex bytes)", rtmr, MeasurementSize)
+		}
+	}
+	return nil
+}
+
+// validate checks the servers and bounds of the trusted time source
+func (t *TrustedTimeConfig) validate() error {
+	if len(t.Servers) == 0 {
+		return errors.New("trusted_time.servers must be set when trusted_time.enabled is true")
+	}
+	for i, server := range t.Servers {
+		if server.Name == "" || server.Address == "" {
+			return fmt.Errorf("trusted_time.servers[%d]: name and address must be set", i)
+		}
+		if _, err := trustedtime.ParsePublicKey(server.PublicKey); err != nil {
+			return fmt.Errorf("trusted_time.servers[%d].public_key: %v", i, err)
+		}
+	}
+	if t.Quorum < 0 || t.Quorum > len(t.Servers) {
+		return fmt.Errorf("trusted_time.quorum must be between 0 and the %d servers", len(t.Servers))
+	}
+	if t.SyncInterval <= 0 || t.Timeout <= 0 {
+		return errors.New("trusted_time.sync_interval and trusted_time.timeout must be greater than 0")
+	}
+	if t.MaxDriftPPM <= 0 || t.MaxUncertainty <= 0 {
+		return errors.New("trusted_time.max_drift_ppm and trusted_time.max_uncertainty must be greater than 0")
+	}
+	return nil
+}
+
+// GenesisConfig holds the chain genesis settings
+type GenesisConfig struct {
+	File string `yaml:"file"` // Genesis JSON file (the default genesis is used if empty)
+}
+
+// HAConfig holds the active/standby settings
+type HAConfig struct {
+	Enabled      bool          `yaml:"enabled"`       // Elect a single block-building leader among the nodes
+	LeaseFile    string        `yaml:"lease_file"`    // Lease file on storage shared by all nodes
+	NodeID       string        `yaml:"node_id"`       // Unique node ID (defaults to hostname and process ID)
+	AdvertiseURL string        `yaml:"advertise_url"` // WebSocket RPC endpoint standby nodes follow when this node leads
+	LeaseTTL     time.Duration `yaml:"lease_ttl"`     // Failover time after the leader disappears (defaults to 4 block intervals)
+
+	MempoolSyncInterval time.Duration `yaml:"mempool_sync_interval"` // Time between mempool fingerprint exchanges with the leader (defaults to the block interval)
+}
+
+// ChainConfig holds the settings of an additional chain. Each chain is an
+// isolated lane with its own account state, mempool, block processor and
+// block store; settings left at zero follow the primary chain.
+type ChainConfig struct {
+	Name            string        `yaml:"name"`              // Lane name in the RPC namespace suffix and URL path
+	ChainID         uint64        `yaml:"chain_id"`          // Chain ID (overrides the chain ID of the genesis)
+	Genesis         string        `yaml:"genesis"`           // Genesis JSON file (the default genesis is used if empty)
+	Interval        time.Duration `yaml:"interval"`          // Block creation interval
+	MaxTransactions int           `yaml:"max_transactions"`  // Maximum transactions per block (0 = unlimited)
+	MaxStoredBlocks int           `yaml:"max_stored_blocks"` // Recent blocks kept in memory
+	MempoolMaxSize  int           `yaml:"mempool_max_size"`  // Maximum pending transactions (0 = unlimited)
+}
+
+// chainNamePattern matches valid lane names
+var chainNamePattern = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// Deployment roles of a node
+const (
+	RoleAll     = "all"     // Receive transactions and build blocks
+	RoleRPC     = "rpc"     // Receive transactions and follow a remote builder
+	RoleBuilder = "builder" // Build blocks from the transactions of remote RPC nodes
+	RoleReplica = "replica" // Serve the verified blocks of a remote builder read-only
+)
+
+// RoleConfig holds the role-split deployment settings
+type RoleConfig struct {
+	Mode         string   `yaml:"mode"`          // Role of the node (all, rpc, builder, replica)
+	BuilderURL   string   `yaml:"builder_url"`   // WebSocket RPC endpoint of the builder followed in rpc and replica mode
+	MempoolFeeds []string `yaml:"mempool_feeds"` // WebSocket RPC endpoints of the RPC nodes consumed in builder mode
+}
+
+// BuildsBlocks reports whether the role builds blocks rather than importing
+// the blocks of a remote builder
+func (r RoleConfig) BuildsBlocks() bool {
+	return r.Mode == RoleAll || r.Mode == RoleBuilder
+}
+
+// P2PConfig holds the transaction gossip settings
+type P2PConfig struct {
+	Enabled    bool     `yaml:"enabled"`     // Gossip admitted transactions with other nodes
+	ListenAddr string   `yaml:"listen_addr"` // TCP listen address for peers
+	Peers      []string `yaml:"peers"`       // Multiaddrs, with the peer ID, of the peers to stay connected to
+	Discovery  bool     `yaml:"discovery"`   // Learn peers from the bootnodes and other peers through peer exchange
+	Bootnodes  []string `yaml:"bootnodes"`   // Multiaddrs, with the peer ID, of the bootstrap nodes
+	MDNS       bool     `yaml:"mdns"`        // Find peers on the local network through mDNS
+	MaxPeers   int      `yaml:"max_peers"`   // Maximum number of connected peers
+}
+
+// BundlesConfig holds the eth_sendBundle settings
+type BundlesConfig struct {
+	Enabled         bool `yaml:"enabled"`          // Accept bundles on block-building nodes
+	MaxBundles      int  `yaml:"max_bundles"`      // Maximum pending bundles (0 = unlimited)
+	MaxTransactions int  `yaml:"max_transactions"` // Maximum transactions per bundle (0 = unlimited)
+}
+
+// EncryptedConfig holds the encrypted transaction settings
+type EncryptedConfig struct {
+	Enabled         bool `yaml:"enabled"`          // Accept encrypted transactions on block-building nodes
+	MaxTransactions int  `yaml:"max_transactions"` // Maximum pending encrypted transactions (0 = unlimited)
+}
+
+// FlashblocksConfig holds the flashblocks WebSocket feed settings
+type FlashblocksConfig struct {
+	Addr             string `yaml:"addr"`               // Listen address of the feed (disabled if empty)
+	BlocksPerPayload int    `yaml:"blocks_per_payload"` // Consecutive blocks grouped into a payload
+}
+
+// RelayConfig holds the settings of the publisher pushing sealed blocks to relays
+type RelayConfig struct {
+	Endpoints    []string      `yaml:"endpoints"`     // HTTP or WebSocket JSON-RPC endpoints of the relays (disabled if empty)
+	KeyFile      string        `yaml:"key_file"`      // Signing key, relative to the data directory if one is set
+	Timeout      time.Duration `yaml:"timeout"`       // Deadline of a single submission
+	MaxRetries   int           `yaml:"max_retries"`   // Resubmissions of a block a relay did not accept
+	RetryBackoff time.Duration `yaml:"retry_backoff"` // Wait before the first resubmission, doubled for every further one
+}
+
+// DA layers
+const (
+	DACelestia = "celestia" // Blobs submitted through a celestia-node
+	DAEthereum = "ethereum" // EIP-4844 blob transactions sent through an Ethereum node
+)
+
+// DAConfig holds the settings of the publisher posting sealed blocks to a data-availability layer
+type DAConfig struct {
+	Layer         string        `yaml:"layer"`           // celestia or ethereum (disabled if empty)
+	URL           string        `yaml:"url"`             // JSON-RPC endpoint of the celestia-node or Ethereum node
+	AuthToken     string        `yaml:"auth_token"`      // Bearer token of the celestia-node API
+	Namespace     string        `yaml:"namespace"`       // Hex Celestia namespace ID of 1 to 10 bytes
+	GasPrice      float64       `yaml:"gas_price"`       // Celestia gas price in utia (0 = estimated by the node)
+	KeyFile       string        `yaml:"key_file"`        // Key paying for blob transactions, relative to the data directory if one is set
+	To            string        `yaml:"to"`              // Recipient of blob transactions (defaults to the sender)
+	BatchSize     int           `yaml:"batch_size"`      // Blocks per submission
+	MaxBatchDelay time.Duration `yaml:"max_batch_delay"` // Time a partial batch waits for more blocks
+	Compress      bool          `yaml:"compress"`        // Compress batches with gzip
+	Timeout       time.Duration `yaml:"timeout"`         // Deadline of a submission, including its inclusion
+	MaxRetries    int           `yaml:"max_retries"`     // Resubmissions of a batch that was not included
+	RetryBackoff  time.Duration `yaml:"retry_backoff"`   // Wait before the first resubmission, doubled for every further one
+}
+
+// BatcherConfig holds the settings of the batcher aggregating sealed blocks
+// into compressed batches and optionally posting them to an L1 chain
+type BatcherConfig struct {
+	Enabled      bool          `yaml:"enabled"`        // Batch the sealed blocks
+	Algorithm    string        `yaml:"algorithm"`      // Compression algorithm: zlib, brotli or zstd
+	Interval     time.Duration `yaml:"interval"`       // Time between batches
+	MaxBlocks    int           `yaml:"max_blocks"`     // Blocks per batch at most, sealing it early (0 = no limit)
+	MaxFrameSize int           `yaml:"max_frame_size"` // Bytes per frame, and L1 transaction, at most
+	L1URL        string        `yaml:"l1_url"`         // JSON-RPC endpoint of the L1 node the frames are posted to (not posted if empty)
+	KeyFile      string        `yaml:"key_file"`       // Key paying for the L1 transactions, relative to the data directory if one is set
+	Inbox        string        `yaml:"inbox"`          // Batch inbox address the L1 transactions are sent to
+	Timeout      time.Duration `yaml:"timeout"`        // Deadline of posting a frame, including its inclusion
+	MaxRetries   int           `yaml:"max_retries"`    // Reposts of a frame that was not included
+	RetryBackoff time.Duration `yaml:"retry_backoff"`  // Wait before the first repost, doubled for every further one
+}
+
+// Indexer database drivers
+const (
+	IndexerSQLite   = "sqlite"
+	IndexerPostgres = "postgres"
+)
+
+// IndexerConfig holds the settings of the indexer writing blocks, transactions
+// and receipts into a SQL database for flash_query
+type IndexerConfig struct {
+	Driver     string `yaml:"driver"`      // Database: sqlite or postgres (disabled if empty)
+	DSN        string `yaml:"dsn"`         // SQLite database file, relative to the data directory if one is set, or Postgres connection string
+	MaxResults int    `yaml:"max_results"` // Transactions returned by a query at most
+}
+
+// ChaosConfig holds the faults injected for resilience testing. They can only be
+// set on the command line and require --chaos, so they are never enabled by a
+// configuration file or environment by accident.
+type ChaosConfig struct {
+	Enabled          bool
+	BlockLatency     time.Duration // Added to every block build
+	DropRate         float64       // Fraction of submissions that are silently dropped (0-1)
+	AttestationDelay time.Duration // Added to the attestation step of every block
+}
+
+// LogConfig holds the logging settings
+type LogConfig struct {
+	File   string `yaml:"file"`   // Log file path, relative to the data directory if one is set (logs are also written to stdout)
+	Level  string `yaml:"level"`  // Minimum log level (debug, info, warn, error)
+	Blocks bool   `yaml:"blocks"` // Log block creation events
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		RPC: RPCConfig{
+			Addr:        ":8080",
+			CORSOrigins: []string{"*"},
+			UnixMode:    "0600",
+			Concurrency: ConcurrencyConfig{
+				QueueSize:    1024,
+				QueueTimeout: 100 * time.Millisecond,
+			},
+		},
+		Mempool: MempoolConfig{
+			PrivateTTL:    time.Minute,
+			MaxPrivateTTL: 10 * time.Minute,
+			Journal: JournalConfig{
+				Sync: true,
+			},
+		},
+		FeeMarket: FeeMarketConfig{
+			MempoolTarget: 0.5,
+			BlockTarget:   0.5,
+			ChangeRate:    0.125,
+			Interval:      time.Second,
+		},
+		Shedding: SheddingConfig{
+			QueueThreshold:   64,
+			LatencyThreshold: 200 * time.Millisecond,
+			MaxShare:         0.9,
+			Step:             0.1,
+			Interval:         time.Second,
+			RetryAfter:       time.Second,
+		},
+		Block: BlockConfig{
+			Interval:        250 * time.Millisecond,
+			MaxStoredBlocks: 100,
+		},
+		Retention: RetentionConfig{
+			Interval:      time.Hour,
+			StateInterval: time.Hour,
+		},
+		Attestation: AttestationConfig{
+			Enabled:  true,
+			Provider: "tdx",
+		},
+		TrustedTime: TrustedTimeConfig{
+			SyncInterval:   time.Minute,
+			Timeout:        2 * time.Second,
+			MaxDriftPPM:    200,
+			MaxUncertainty: time.Second,
+		},
+		Role: RoleConfig{
+			Mode: RoleAll,
+		},
+		P2P: P2PConfig{
+			ListenAddr: ":30303",
+			MaxPeers:   25,
+		},
+		Bundles: BundlesConfig{
+			Enabled:         true,
+			MaxBundles:      1000,
+			MaxTransactions: 16,
+		},
+		Encrypted: EncryptedConfig{
+			MaxTransactions: 1000,
+		},
+		Flashblocks: FlashblocksConfig{
+			BlocksPerPayload: 1,
+		},
+		Preconf: PreconfConfig{
+			KeyFile:     "keys/preconf.key",
+			Window:      4,
+			MaxReceipts: 100000,
+		},
+		Signer: SignerConfig{
+			Type:    SignerLocal,
+			Timeout: 5 * time.Second,
+		},
+		Blobs: BlobsConfig{
+			KeepAge:  18 * 24 * time.Hour,
+			Interval: 10 * time.Minute,
+		},
+		Relay: RelayConfig{
+			KeyFile:      "keys/relay.key",
+			Timeout:      2 * time.Second,
+			MaxRetries:   3,
+			RetryBackoff: 500 * time.Millisecond,
+		},
+		DA: DAConfig{
+			KeyFile:       "keys/da.key",
+			BatchSize:     1,
+			MaxBatchDelay: 10 * time.Second,
+			Timeout:       time.Minute,
+			MaxRetries:    3,
+			RetryBackoff:  time.Second,
+		},
+		Batcher: BatcherConfig{
+			Algorithm:    "zstd",
+			Interval:     time.Minute,
+			MaxFrameSize: 120000,
+			KeyFile:      "keys/batcher.key",
+			Timeout:      2 * time.Minute,
+			MaxRetries:   3,
+			RetryBackoff: time.Second,
+		},
+		Indexer: IndexerConfig{
+			MaxResults: 1000,
+		},
+		Log: LogConfig{
+			File:   "logs/flashblock.log",
+			Level:  "info",
+			Blocks: true,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the file given by --config (or
+// FLASHBLOCK_CONFIG), FLASHBLOCK_* environment variables and the command line
+// flags, in increasing order of precedence
+func Load(name string, args []string) (*Config, error) {
+	// First pass only locates the configuration file
+	var path string
+	fs := newFlagSet(name, Default(), &path)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = os.Getenv(envConfigFile)
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	// Second pass applies the flags on top of the file and environment values
+	fs = newFlagSet(name, cfg, &path)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// newFlagSet binds the command line flags to cfg, using its current values as defaults
+func newFlagSet(name string, cfg *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", name)
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nEnvironment variables (override the config file, overridden by flags):\n  %s\n  %s\n",
+			envConfigFile, strings.Join(EnvKeys(), "\n  "))
+	}
+
+	fs.StringVar(path, "config", "", "Configuration file (YAML)")
+	fs.StringVar(&cfg.DataDir, "datadir", cfg.DataDir, "Data directory for persistent state (disabled if empty)")
+	fs.BoolVar(&cfg.Repair, "repair", cfg.Repair, "Truncate an invalid record at the tail of the block store at startup")
+	fs.BoolVar(&cfg.Check, "check", cfg.Check, "Run the preflight checks, print a report and exit")
+	fs.StringVar(&cfg.RPC.Addr, "rpc-addr", cfg.RPC.Addr, "JSON-RPC server address")
+	fs.StringVar(&cfg.RPC.WSAddr, "ws-addr", cfg.RPC.WSAddr, "Separate WebSocket server address (default: served on --rpc-addr)")
+	fs.StringVar(&cfg.RPC.UnixSocket, "rpc-unix-socket", cfg.RPC.UnixSocket, "Unix socket path for JSON-RPC and the admin namespace (disabled if empty)")
+	fs.StringVar(&cfg.RPC.UnixMode, "rpc-unix-mode", cfg.RPC.UnixMode, "Octal permissions of the unix socket file")
+	fs.IntVar(&cfg.RPC.Concurrency.MaxRequests, "rpc-max-concurrent", cfg.RPC.Concurrency.MaxRequests, "Concurrently executing JSON-RPC HTTP requests and WebSocket calls before requests are queued (0 = unlimited)")
+	fs.StringVar(&cfg.RPC.AdminAddr, "admin-addr", cfg.RPC.AdminAddr, "Admin and metrics server address (disabled if empty)")
+	fs.DurationVar(&cfg.Block.Interval, "block-interval", cfg.Block.Interval, "Block creation interval")
+	fs.IntVar(&cfg.Block.MaxTransactions, "block-max-txs", cfg.Block.MaxTransactions, "Maximum transactions per block (0 = unlimited)")
+	fs.StringVar(&cfg.Block.FeeRecipient, "fee-recipient", cfg.Block.FeeRecipient, "Address credited with the priority fees of executed transactions")
+	fs.Uint64Var(&cfg.Retention.KeepBlocks, "retention-blocks", cfg.Retention.KeepBlocks, "Most recent blocks kept in the block store (0 = no block limit)")
+	fs.IntVar(&cfg.Retention.KeepDays, "retention-days", cfg.Retention.KeepDays, "Days of blocks kept in the block store (0 = no age limit)")
+	fs.Uint64Var(&cfg.Retention.CheckpointInterval, "retention-checkpoint-interval", cfg.Retention.CheckpointInterval, "Keep every block whose number is a multiple of this as a checkpoint when pruning (0 = none)")
+	fs.BoolVar(&cfg.Retention.State, "retention-state", cfg.Retention.State, "Prune the account states below the finalized checkpoint and compact the state database")
+	fs.IntVar(&cfg.Mempool.MaxSize, "mempool-max-size", cfg.Mempool.MaxSize, "Maximum pending transactions (0 = unlimited)")
+	fs.DurationVar(&cfg.Mempool.PrivateTTL, "mempool-private-ttl", cfg.Mempool.PrivateTTL, "Default privacy TTL of private transactions")
+	fs.DurationVar(&cfg.Mempool.MaxPrivateTTL, "mempool-max-private-ttl", cfg.Mempool.MaxPrivateTTL, "Maximum privacy TTL of private transactions (0 = unlimited)")
+	fs.BoolVar(&cfg.Mempool.Journal.Sync, "mempool-journal-sync", cfg.Mempool.Journal.Sync, "Sync the mempool journal to disk before acknowledging submissions")
+	fs.BoolVar(&cfg.FeeMarket.Enabled, "fee-market", cfg.FeeMarket.Enabled, "Raise the minimum priority of new transactions while the mempool or blocks are congested")
+	fs.IntVar(&cfg.FeeMarket.MaxPriority, "fee-market-max-priority", cfg.FeeMarket.MaxPriority, "Ceiling of the congestion fee floor (0 = unlimited)")
+	fs.BoolVar(&cfg.Shedding.Enabled, "load-shedding", cfg.Shedding.Enabled, "Reject low-priority submissions first while RPC queueing or block building is overloaded")
+	fs.IntVar(&cfg.Shedding.ProtectedPriority, "load-shedding-protected-priority", cfg.Shedding.ProtectedPriority, "Priority at and above which submissions are never shed (0 = none)")
+	fs.BoolVar(&cfg.Preconf.Enabled, "preconf", cfg.Preconf.Enabled, "Return signed preconfirmation receipts for admitted transactions")
+	fs.StringVar(&cfg.Preconf.KeyFile, "preconf-key", cfg.Preconf.KeyFile, "Key file signing preconfirmation receipts")
+	fs.Uint64Var(&cfg.Preconf.Window, "preconf-window", cfg.Preconf.Window, "Blocks after the head a preconfirmed transaction is promised to be included in")
+	fs.StringVar(&cfg.Signer.Type, "signer", cfg.Signer.Type, "Signer of relay blocks and preconfirmations: local, remote, aws-kms or gcp-kms")
+	fs.StringVar(&cfg.Signer.URL, "signer-url", cfg.Signer.URL, "Endpoint of the remote signer, or of the KMS API instead of the default")
+	fs.StringVar(&cfg.Signer.Address, "signer-address", cfg.Signer.Address, "Address of the key of the remote signer")
+	fs.StringVar(&cfg.Signer.KeyID, "signer-key-id", cfg.Signer.KeyID, "AWS KMS key ID or ARN, or Cloud KMS key version resource name")
+	fs.StringVar(&cfg.Signer.Region, "signer-region", cfg.Signer.Region, "AWS region of the KMS key")
+	fs.BoolVar(&cfg.Blobs.Enabled, "blobs", cfg.Blobs.Enabled, "Accept EIP-4844 blob transactions and keep their sidecars")
+	fs.Uint64Var(&cfg.Blobs.KeepBlocks, "blobs-keep-blocks", cfg.Blobs.KeepBlocks, "Blocks a blob sidecar is kept for after inclusion (0 = no block limit)")
+	fs.DurationVar(&cfg.Blobs.KeepAge, "blobs-keep-age", cfg.Blobs.KeepAge, "Age after which a blob sidecar is removed (0 = no age limit)")
+	fs.BoolVar(&cfg.Log.Blocks, "log-blocks", cfg.Log.Blocks, "Log block creation events")
+	fs.StringVar(&cfg.Log.File, "log-file", cfg.Log.File, "Log file path")
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "Log level (debug, info, warn, error)")
+	fs.BoolVar(&cfg.Attestation.Enabled, "enable-tdx-quote", cfg.Attestation.Enabled, "Enable TDX attestation quote generation for blocks")
+	fs.BoolVar(&cfg.Attestation.Policy.RequireQuote, "require-quote", cfg.Attestation.Policy.RequireQuote, "Reject followed blocks without a TDX quote in replica mode")
+	fs.BoolVar(&cfg.Attestation.Policy.CheckCollateral, "check-collateral", cfg.Attestation.Policy.CheckCollateral, "Check the TCB collateral and revocations of quotes with the Intel PCS in replica mode")
+	fs.StringVar(&cfg.Attestation.Policy.MrTd, "mr-td", cfg.Attestation.Policy.MrTd, "Expected MRTD of the builder in replica mode (hex)")
+	fs.BoolVar(&cfg.Attestation.Policy.RequireTrustedTime, "require-trusted-time", cfg.Attestation.Policy.RequireTrustedTime, "Reject followed blocks without a trusted timestamp in replica mode")
+	fs.BoolVar(&cfg.TrustedTime.Enabled, "trusted-time", cfg.TrustedTime.Enabled, "Timestamp blocks with the Roughtime servers of the configuration instead of the host clock")
+	fs.StringVar(&cfg.Genesis.File, "genesis", cfg.Genesis.File, "Genesis JSON file")
+	fs.StringVar(&cfg.Role.Mode, "role", cfg.Role.Mode, "Node role: all, rpc (RPC front-end only), builder (builder only) or replica (verifying read replica)")
+	fs.StringVar(&cfg.Role.BuilderURL, "builder-url", cfg.Role.BuilderURL, "WebSocket RPC endpoint of the builder to follow in rpc and replica mode")
+	fs.Func("mempool-feeds", "Comma separated WebSocket RPC endpoints of the RPC nodes to consume in builder mode", func(urls string) error {
+		cfg.Role.MempoolFeeds = strings.Split(urls, ",")
+		return nil
+	})
+	fs.BoolVar(&cfg.P2P.Enabled, "p2p", cfg.P2P.Enabled, "Gossip admitted transactions with other nodes")
+	fs.StringVar(&cfg.P2P.ListenAddr, "p2p-listen", cfg.P2P.ListenAddr, "TCP listen address for p2p peers")
+	fs.Func("p2p-peers", "Comma separated multiaddrs, with the peer ID, of the p2p peers", func(urls string) error {
+		cfg.P2P.Peers = strings.Split(urls, ",")
+		return nil
+	})
+	fs.BoolVar(&cfg.P2P.Discovery, "p2p-discovery", cfg.P2P.Discovery, "Learn p2p peers through GossipSub peer exchange")
+	fs.BoolVar(&cfg.P2P.MDNS, "p2p-mdns", cfg.P2P.MDNS, "Find p2p peers on the local network through mDNS")
+	fs.Func("p2p-bootnodes", "Comma separated multiaddrs, with the peer ID, of the p2p bootstrap nodes", func(urls string) error {
+		cfg.P2P.Bootnodes = strings.Split(urls, ",")
+		return nil
+	})
+	fs.BoolVar(&cfg.Bundles.Enabled, "bundles", cfg.Bundles.Enabled, "Accept transaction bundles through eth_sendBundle")
+	fs.BoolVar(&cfg.Encrypted.Enabled, "encrypted-mempool", cfg.Encrypted.Enabled, "Accept transactions encrypted to the enclave key through flash_sendEncryptedTransaction")
+	fs.StringVar(&cfg.Flashblocks.Addr, "flashblocks-addr", cfg.Flashblocks.Addr, "Flashblocks WebSocket feed address (disabled if empty)")
+	fs.IntVar(&cfg.Flashblocks.BlocksPerPayload, "flashblocks-blocks-per-payload", cfg.Flashblocks.BlocksPerPayload, "Consecutive blocks grouped into a flashblocks payload")
+	fs.Func("relay-endpoints", "Comma separated HTTP or WebSocket JSON-RPC endpoints of the relays receiving sealed blocks", func(urls string) error {
+		cfg.Relay.Endpoints = strings.Split(urls, ",")
+		return nil
+	})
+	fs.StringVar(&cfg.Relay.KeyFile, "relay-key", cfg.Relay.KeyFile, "Key file signing the blocks submitted to relays")
+	fs.StringVar(&cfg.DA.Layer, "da-layer", cfg.DA.Layer, "Data-availability layer sealed blocks are published to: celestia or ethereum (disabled if empty)")
+	fs.StringVar(&cfg.DA.URL, "da-url", cfg.DA.URL, "JSON-RPC endpoint of the celestia-node or Ethereum node")
+	fs.StringVar(&cfg.DA.Namespace, "da-namespace", cfg.DA.Namespace, "Hex Celestia namespace ID of the published blocks")
+	fs.StringVar(&cfg.DA.KeyFile, "da-key", cfg.DA.KeyFile, "Key file paying for Ethereum blob transactions")
+	fs.IntVar(&cfg.DA.BatchSize, "da-batch-size", cfg.DA.BatchSize, "Blocks per DA submission")
+	fs.BoolVar(&cfg.DA.Compress, "da-compress", cfg.DA.Compress, "Compress DA batches with gzip")
+	fs.BoolVar(&cfg.Batcher.Enabled, "batcher", cfg.Batcher.Enabled, "Aggregate sealed blocks into compressed batches")
+	fs.StringVar(&cfg.Batcher.Algorithm, "batcher-algorithm", cfg.Batcher.Algorithm, "Compression algorithm of batches: zlib, brotli or zstd")
+	fs.DurationVar(&cfg.Batcher.Interval, "batcher-interval", cfg.Batcher.Interval, "Time between batches")
+	fs.IntVar(&cfg.Batcher.MaxBlocks, "batcher-max-blocks", cfg.Batcher.MaxBlocks, "Blocks per batch at most (0 = no limit)")
+	fs.StringVar(&cfg.Batcher.L1URL, "batcher-l1-url", cfg.Batcher.L1URL, "JSON-RPC endpoint of the L1 node batches are posted to (not posted if empty)")
+	fs.StringVar(&cfg.Batcher.Inbox, "batcher-inbox", cfg.Batcher.Inbox, "Batch inbox address on L1")
+	fs.StringVar(&cfg.Batcher.KeyFile, "batcher-key", cfg.Batcher.KeyFile, "Key file paying for the L1 batch transactions")
+	fs.StringVar(&cfg.Indexer.Driver, "indexer", cfg.Indexer.Driver, "Index blocks into a SQL database for flash_query: sqlite or postgres (disabled if empty)")
+	fs.StringVar(&cfg.Indexer.DSN, "indexer-dsn", cfg.Indexer.DSN, "SQLite database file or Postgres connection string of the indexer")
+	fs.BoolVar(&cfg.Chaos.Enabled, "chaos", cfg.Chaos.Enabled, "Enable fault injection for resilience testing (never in production)")
+	fs.DurationVar(&cfg.Chaos.BlockLatency, "chaos-block-latency", cfg.Chaos.BlockLatency, "Latency added to every block build (requires --chaos)")
+	fs.Float64Var(&cfg.Chaos.DropRate, "chaos-drop-rate", cfg.Chaos.DropRate, "Fraction of submissions that are accepted but dropped, 0-1 (requires --chaos)")
+	fs.DurationVar(&cfg.Chaos.AttestationDelay, "chaos-attestation-delay", cfg.Chaos.AttestationDelay, "Delay added to the attestation of every block (requires --chaos)")
+	fs.BoolVar(&cfg.HA.Enabled, "ha", cfg.HA.Enabled, "Enable active/standby mode")
+	fs.StringVar(&cfg.HA.LeaseFile, "ha-lease-file", cfg.HA.LeaseFile, "Leader lease file shared by all nodes")
+	fs.StringVar(&cfg.HA.AdvertiseURL, "ha-advertise-url", cfg.HA.AdvertiseURL, "WebSocket RPC endpoint of this node for standby nodes")
+
+	return fs
+}
+
+// LogFile returns the log file path, resolving relative paths inside the data directory
+func (c *Config) LogFile() string {
+	if c.DataDir == "" || filepath.IsAbs(c.Log.File) {
+		return c.Log.File
+	}
+	return filepath.Join(c.DataDir, c.Log.File)
+}
+
+// RelayKeyFile returns the relay signing key path, resolving relative paths
+// inside the data directory. Without a data directory, relative paths are
+// not used and an empty path is returned.
+func (c *Config) RelayKeyFile() string {
+	if filepath.IsAbs(c.Relay.KeyFile) {
+		return c.Relay.KeyFile
+	}
+	if c.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(c.DataDir, c.Relay.KeyFile)
+}
+
+// PreconfKeyFile returns the preconfirmation signing key path, resolving
+// relative paths like RelayKeyFile
+func (c *Config) PreconfKeyFile() string {
+	if filepath.IsAbs(c.Preconf.KeyFile) {
+		return c.Preconf.KeyFile
+	}
+	if c.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(c.DataDir, c.Preconf.KeyFile)
+}
+
+// DAKeyFile returns the blob transaction key path, resolving relative paths
+// like RelayKeyFile
+func (c *Config) DAKeyFile() string {
+	if filepath.IsAbs(c.DA.KeyFile) {
+		return c.DA.KeyFile
+	}
+	if c.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(c.DataDir, c.DA.KeyFile)
+}
+
+// BatcherKeyFile returns the L1 batch transaction key path, resolving
+// relative paths like RelayKeyFile
+func (c *Config) BatcherKeyFile() string {
+	if filepath.IsAbs(c.Batcher.KeyFile) {
+		return c.Batcher.KeyFile
+	}
+	if c.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(c.DataDir, c.Batcher.KeyFile)
+}
+
+// IndexerDSN returns the data source of the indexer. A relative SQLite
+// database file is resolved against the data directory and defaults to
+// index/index.db in it; without a data directory the database is kept in
+// memory.
+func (c *Config) IndexerDSN() string {
+	if c.Indexer.Driver != IndexerSQLite {
+		return c.Indexer.DSN
+	}
+	if c.Indexer.DSN != "" && filepath.IsAbs(c.Indexer.DSN) {
+		return c.Indexer.DSN
+	}
+	if c.DataDir == "" {
+		return ":memory:"
+	}
+	if c.Indexer.DSN == "" {
+		return filepath.Join(c.DataDir, "index", "index.db")
+	}
+	return filepath.Join(c.DataDir, c.Indexer.DSN)
+}
+
+// sensitiveKeys are the settings holding secrets, by YAML path. Their values
+// are redacted when the configuration is marshaled and when changes are logged.
+var sensitiveKeys = map[string]bool{
+	"rpc.auth_tokens":   true,
+	"signer.auth_token": true,
+	"da.auth_token":     true,
+	"indexer.dsn":       true, // A Postgres connection string may hold a password
+}
+
+// Marshal returns the YAML encoding of the configuration with secrets redacted
+func (c *Config) Marshal() ([]byte, error) {
+	redacted := *c
+	for key := range sensitiveKeys {
+		v, err := redacted.setting(key)
+		if err != nil {
+			return nil, err
+		}
+		if !v.IsZero() && (v.Kind() != reflect.Slice || v.Len() > 0) {
+			if err := setValue(v, "<redacted>"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return yaml.Marshal(&redacted)
+}
+
+// LoadFile applies the values of a YAML configuration file on top of the current configuration
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	return nil
+}
+
+// Validate checks the configuration for invalid values
+func (c *Config) Validate() error {
+	if c.RPC.Addr == "" {
+		return errors.New("rpc.addr cannot be empty")
+	}
+	if c.RPC.AdminAddr != "" && (c.RPC.AdminAddr == c.RPC.Addr || c.RPC.AdminAddr == c.RPC.WSAddr) {
+		return errors.New("rpc.admin_addr must differ from rpc.addr and rpc.ws_addr")
+	}
+	if _, err := c.RPC.UnixFileMode(); err != nil {
+		return err
+	}
+	if c.Block.Interval <= 0 {
+		return errors.New("block.interval must be greater than 0")
+	}
+	if c.Block.Interval > MaxBlockInterval {
+		return fmt.Errorf("block.interval cannot exceed %v", MaxBlockInterval)
+	}
+	if c.Block.MaxTransactions < 0 {
+		return errors.New("block.max_transactions cannot be negative")
+	}
+	if c.Block.MaxStoredBlocks <= 0 {
+		return errors.New("block.max_stored_blocks must be greater than 0")
+	}
+	if c.Block.FeeRecipient != "" && !common.IsHexAddress(c.Block.FeeRecipient) {
+		return fmt.Errorf("block.fee_recipient: invalid address %q", c.Block.FeeRecipient)
+	}
+	if c.Retention.KeepDays < 0 {
+		return errors.New("retention.keep_days cannot be negative")
+	}
+	if c.Retention.Enabled() {
+		if c.DataDir == "" {
+			return errors.New("retention requires a data directory")
+		}
+		if c.Retention.Interval <= 0 {
+			return errors.New("retention.interval must be greater than 0")
+		}
+	}
+	if c.Retention.State {
+		if c.DataDir == "" {
+			return errors.New("retention.state requires a data directory")
+		}
+		if c.Retention.StateInterval <= 0 {
+			return errors.New("retention.state_interval must be greater than 0")
+		}
+	}
+	if c.RPC.RateLimit.RPS < 0 {
+		return errors.New("rpc.rate_limit.rps cannot be negative")
+	}
+	if c.RPC.RateLimit.Burst < 0 {
+		return errors.New("rpc.rate_limit.burst cannot be negative")
+	}
+	if c.RPC.Concurrency.MaxRequests < 0 || c.RPC.Concurrency.QueueSize < 0 {
+		return errors.New("rpc.concurrency.max_requests and rpc.concurrency.queue_size cannot be negative")
+	}
+	if c.RPC.Concurrency.MaxRequests > 0 && c.RPC.Concurrency.QueueTimeout <= 0 && c.RPC.Concurrency.QueueSize > 0 {
+		return errors.New("rpc.concurrency.queue_timeout must be greater than 0 when requests are queued")
+	}
+	if c.Mempool.MaxSize < 0 {
+		return errors.New("mempool.max_size cannot be negative")
+	}
+	if c.Mempool.Journal.CommitDelay < 0 {
+		return errors.New("mempool.journal.commit_delay cannot be negative")
+	}
+	if c.Mempool.PrivateTTL <= 0 {
+		return errors.New("mempool.private_ttl must be greater than 0")
+	}
+	if c.Mempool.MaxPrivateTTL < 0 || (c.Mempool.MaxPrivateTTL > 0 && c.Mempool.PrivateTTL > c.Mempool.MaxPrivateTTL) {
+		return errors.New("mempool.max_private_ttl must be 0 or at least mempool.private_ttl")
+	}
+	if c.FeeMarket.Enabled {
+		if c.FeeMarket.MempoolTarget < 0 || c.FeeMarket.MempoolTarget > 1 || c.FeeMarket.BlockTarget < 0 || c.FeeMarket.BlockTarget > 1 {
+			return errors.New("fee_market.mempool_target and fee_market.block_target must be between 0 and 1")
+		}
+		if (c.Mempool.MaxSize == 0 || c.FeeMarket.MempoolTarget == 0) && (c.Block.MaxTransactions == 0 || c.FeeMarket.BlockTarget == 0) {
+			return errors.New("fee_market requires mempool.max_size or block.max_transactions with a target to measure congestion")
+		}
+		if c.FeeMarket.ChangeRate <= 0 || c.FeeMarket.ChangeRate > 1 {
+			return errors.New("fee_market.change_rate must be greater than 0 and at most 1")
+		}
+		if c.FeeMarket.Interval <= 0 {
+			return errors.New("fee_market.interval must be greater than 0")
+		}
+		if c.FeeMarket.MaxPriority < 0 || (c.FeeMarket.MaxPriority > 0 && c.FeeMarket.MaxPriority < c.Mempool.MinPriority) {
+			return errors.New("fee_market.max_priority must be 0 or at least mempool.min_priority")
+		}
+	}
+	if c.Shedding.Enabled {
+		if c.Shedding.QueueThreshold < 0 || c.Shedding.LatencyThreshold < 0 {
+			return errors.New("load_shedding.queue_threshold and load_shedding.latency_threshold cannot be negative")
+		}
+		queueMeasured := c.Shedding.QueueThreshold > 0 && c.RPC.Concurrency.MaxRequests > 0
+		if !queueMeasured && c.Shedding.LatencyThreshold == 0 {
+			return errors.New("load_shedding requires a latency_threshold, or a queue_threshold with rpc.concurrency.max_requests, to measure overload")
+		}
+		if c.Shedding.MaxShare <= 0 || c.Shedding.MaxShare > 1 {
+			return errors.New("load_shedding.max_share must be greater than 0 and at most 1")
+		}
+		if c.Shedding.Step <= 0 || c.Shedding.Step > 1 {
+			return errors.New("load_shedding.step must be greater than 0 and at most 1")
+		}
+		if c.Shedding.Interval <= 0 || c.Shedding.RetryAfter <= 0 {
+			return errors.New("load_shedding.interval and load_shedding.retry_after must be greater than 0")
+		}
+		if c.Shedding.ProtectedPriority < 0 {
+			return errors.New("load_shedding.protected_priority cannot be negative")
+		}
+	}
+	if c.Attestation.Enabled && c.Attestation.Provider != "tdx" {
+		return fmt.Errorf("unsupported attestation provider %q", c.Attestation.Provider)
+	}
+	if c.HA.Enabled {
+		if c.HA.LeaseFile == "" {
+			return errors.New("ha.lease_file must be set when ha.enabled is true")
+		}
+		if c.HA.AdvertiseURL == "" {
+			return errors.New("ha.advertise_url must be set when ha.enabled is true")
+		}
+		if c.HA.LeaseTTL < 0 {
+			return errors.New("ha.lease_ttl cannot be negative")
+		}
+		if c.HA.MempoolSyncInterval < 0 {
+			return errors.New("ha.mempool_sync_interval cannot be negative")
+		}
+	}
+	switch c.Role.Mode {
+	case RoleAll:
+	case RoleRPC, RoleReplica:
+		if c.Role.BuilderURL == "" && !c.P2P.Enabled {
+			return fmt.Errorf("role.builder_url or p2p.enabled must be set when role.mode is %s", c.Role.Mode)
+		}
+		if c.HA.Enabled {
+			return errors.New("ha.enabled requires a block-building role (all or builder)")
+		}
+		// A replica that accepts unattested blocks or any TD verifies nothing
+		if c.Role.Mode == RoleReplica && !c.Attestation.Policy.RequireQuote {
+			return errors.New("attestation.policy.require_quote must be set when role.mode is replica")
+		}
+		if c.Role.Mode == RoleReplica && c.Attestation.Policy.MrTd == "" {
+			return errors.New("attestation.policy.mr_td must be set when role.mode is replica")
+		}
+	case RoleBuilder:
+		if len(c.Role.MempoolFeeds) == 0 {
+			return errors.New("role.mempool_feeds must be set when role.mode is builder")
+		}
+	default:
+		return fmt.Errorf("unknown role.mode %q (expected all, rpc, builder or replica)", c.Role.Mode)
+	}
+	if err := c.Attestation.Policy.validate(); err != nil {
+		return err
+	}
+	if c.TrustedTime.Enabled {
+		if err := c.TrustedTime.validate(); err != nil {
+			return err
+		}
+	}
+	if c.P2P.Enabled {
+		if c.P2P.ListenAddr == "" {
+			return errors.New("p2p.listen_addr must be set when p2p.enabled is true")
+		}
+		if c.P2P.MaxPeers <= 0 {
+			return errors.New("p2p.max_peers must be greater than 0")
+		}
+		for _, addr := range c.P2P.Peers {
+			if _, err := peer.AddrInfoFromString(addr); err != nil {
+				return fmt.Errorf("p2p.peers: invalid multiaddr %q: %v", addr, err)
+			}
+		}
+		for _, addr := range c.P2P.Bootnodes {
+			if _, err := peer.AddrInfoFromString(addr); err != nil {
+				return fmt.Errorf("p2p.bootnodes: invalid multiaddr %q: %v", addr, err)
+			}
+		}
+	}
+	if c.Bundles.MaxBundles < 0 || c.Bundles.MaxTransactions < 0 {
+		return errors.New("bundles limits cannot be negative")
+	}
+	if c.Encrypted.Enabled && !c.Role.BuildsBlocks() {
+		return errors.New("encrypted.enabled requires a block-building role (all or builder)")
+	}
+	if c.Preconf.Enabled {
+		if !c.Role.BuildsBlocks() {
+			return errors.New("preconf.enabled requires a block-building role (all or builder)")
+		}
+		if c.Preconf.KeyFile == "" {
+			return errors.New("preconf.key_file must be set when preconf.enabled is true")
+		}
+		if c.Preconf.Window == 0 {
+			return errors.New("preconf.window must be greater than 0")
+		}
+		if c.Preconf.MaxReceipts <= 0 {
+			return errors.New("preconf.max_receipts must be greater than 0")
+		}
+	}
+	switch c.Signer.Type {
+	case SignerLocal:
+	case SignerRemote:
+		u, err := url.Parse(c.Signer.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			return fmt.Errorf("signer.url: invalid URL %q (expected http, https, ws or wss)", c.Signer.URL)
+		}
+		if !common.IsHexAddress(c.Signer.Address) {
+			return fmt.Errorf("signer.address: invalid address %q", c.Signer.Address)
+		}
+	case SignerAWSKMS, SignerGCPKMS:
+		if c.Signer.KeyID == "" {
+			return fmt.Errorf("signer.key_id must be set for %s", c.Signer.Type)
+		}
+		if c.Signer.Type == SignerAWSKMS && c.Signer.Region == "" {
+			return errors.New("signer.region must be set for aws-kms")
+		}
+		if c.Signer.URL != "" {
+			if u, err := url.Parse(c.Signer.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("signer.url: invalid URL %q (expected http or https)", c.Signer.URL)
+			}
+		}
+	default:
+		return fmt.Errorf("signer.type must be %s, %s, %s or %s, got %q", SignerLocal, SignerRemote, SignerAWSKMS, SignerGCPKMS, c.Signer.Type)
+	}
+	if c.Signer.Type != SignerLocal && c.Signer.Timeout <= 0 {
+		return errors.New("signer.timeout must be greater than 0")
+	}
+	if c.Blobs.Enabled {
+		if !c.Role.BuildsBlocks() {
+			return errors.New("blobs.enabled requires a block-building role (all or builder)")
+		}
+		if c.Blobs.KeepAge < 0 {
+			return errors.New("blobs.keep_age cannot be negative")
+		}
+		if c.Blobs.Interval <= 0 {
+			return errors.New("blobs.interval must be greater than 0")
+		}
+	}
+	for i, plugin := range c.Extensions.Plugins {
+		if plugin == "" {
+			return fmt.Errorf("extensions.plugins[%d] must not be empty", i)
+		}
+	}
+	for i, v := range c.Extensions.Validators {
+		if v.Name == "" {
+			return fmt.Errorf("extensions.validators[%d].name must be set", i)
+		}
+	}
+	if c.Extensions.Orderer.Name == "" && len(c.Extensions.Orderer.Options) > 0 {
+		return errors.New("extensions.orderer.name must be set when options are given")
+	}
+	if c.Encrypted.MaxTransactions < 0 {
+		return errors.New("encrypted.max_transactions cannot be negative")
+	}
+	if c.Flashblocks.Addr != "" {
+		if c.Flashblocks.Addr == c.RPC.Addr || c.Flashblocks.Addr == c.RPC.WSAddr || c.Flashblocks.Addr == c.RPC.AdminAddr {
+			return errors.New("flashblocks.addr must differ from the rpc addresses")
+		}
+		if c.Flashblocks.BlocksPerPayload <= 0 {
+			return errors.New("flashblocks.blocks_per_payload must be greater than 0")
+		}
+	}
+	if len(c.Relay.Endpoints) > 0 {
+		if !c.Role.BuildsBlocks() {
+			return errors.New("relay.endpoints require a block-building role (all or builder)")
+		}
+		for _, endpoint := range c.Relay.Endpoints {
+			u, err := url.Parse(endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+				return fmt.Errorf("relay.endpoints: invalid URL %q (expected http, https, ws or wss)", endpoint)
+			}
+		}
+		if c.Relay.KeyFile == "" {
+			return errors.New("relay.key_file must be set when relay.endpoints are set")
+		}
+		if c.Relay.Timeout <= 0 {
+			return errors.New("relay.timeout must be greater than 0")
+		}
+		if c.Relay.MaxRetries < 0 || c.Relay.RetryBackoff < 0 {
+			return errors.New("relay retries cannot be negative")
+		}
+	}
+	if c.DA.Layer != "" {
+		if c.DA.Layer != DACelestia && c.DA.Layer != DAEthereum {
+			return fmt.Errorf("da.layer must be %s or %s, got %q", DACelestia, DAEthereum, c.DA.Layer)
+		}
+		if !c.Role.BuildsBlocks() {
+			return errors.New("da.layer requires a block-building role (all or builder)")
+		}
+		u, err := url.Parse(c.DA.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			return fmt.Errorf("da.url: invalid URL %q (expected http, https, ws or wss)", c.DA.URL)
+		}
+		if c.DA.Layer == DACelestia {
+			namespace, err := hexutil.Decode(c.DA.Namespace)
+			if err != nil || len(namespace) == 0 || len(namespace) > 10 {
+				return fmt.Errorf("da.namespace: invalid namespace ID %q (expected 1 to 10 hex bytes)", c.DA.Namespace)
+			}
+			if c.DA.GasPrice < 0 {
+				return errors.New("da.gas_price cannot be negative")
+			}
+		}
+		if c.DA.Layer == DAEthereum {
+			if c.DA.KeyFile == "" {
+				return errors.New("da.key_file must be set for the ethereum layer")
+			}
+			if c.DA.To != "" && !common.IsHexAddress(c.DA.To) {
+				return fmt.Errorf("da.to: invalid address %q", c.DA.To)
+			}
+		}
+		if c.DA.BatchSize <= 0 {
+			return errors.New("da.batch_size must be greater than 0")
+		}
+		if c.DA.MaxBatchDelay <= 0 || c.DA.Timeout <= 0 {
+			return errors.New("da.max_batch_delay and da.timeout must be greater than 0")
+		}
+		if c.DA.MaxRetries < 0 || c.DA.RetryBackoff < 0 {
+			return errors.New("da retries cannot be negative")
+		}
+	}
+	if c.Batcher.Enabled {
+		if !c.Role.BuildsBlocks() {
+			return errors.New("batcher.enabled requires a block-building role (all or builder)")
+		}
+		if c.Batcher.Algorithm != "zlib" && c.Batcher.Algorithm != "brotli" && c.Batcher.Algorithm != "zstd" {
+			return fmt.Errorf("batcher.algorithm must be zlib, brotli or zstd, got %q", c.Batcher.Algorithm)
+		}
+		if c.Batcher.Interval <= 0 {
+			return errors.New("batcher.interval must be greater than 0")
+		}
+		if c.Batcher.MaxBlocks < 0 {
+			return errors.New("batcher.max_blocks cannot be negative")
+		}
+		// The frame header and last flag take 17 bytes
+		if c.Batcher.MaxFrameSize <= 17 {
+			return errors.New("batcher.max_frame_size must be greater than 17")
+		}
+		if c.Batcher.L1URL != "" {
+			u, err := url.Parse(c.Batcher.L1URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+				return fmt.Errorf("batcher.l1_url: invalid URL %q (expected http, https, ws or wss)", c.Batcher.L1URL)
+			}
+			if !common.IsHexAddress(c.Batcher.Inbox) {
+				return fmt.Errorf("batcher.inbox: invalid address %q", c.Batcher.Inbox)
+			}
+			if c.Batcher.KeyFile == "" {
+				return errors.New("batcher.key_file must be set to post batches")
+			}
+			if c.Batcher.Timeout <= 0 {
+				return errors.New("batcher.timeout must be greater than 0")
+			}
+			if c.Batcher.MaxRetries < 0 || c.Batcher.RetryBackoff < 0 {
+				return errors.New("batcher retries cannot be negative")
+			}
+		}
+	}
+	switch c.Indexer.Driver {
+	case "", IndexerSQLite:
+	case IndexerPostgres:
+		if c.Indexer.DSN == "" {
+			return errors.New("indexer.dsn must be set for postgres")
+		}
+	default:
+		return fmt.Errorf("indexer.driver must be sqlite or postgres, got %q", c.Indexer.Driver)
+	}
+	if c.Indexer.Driver != "" && c.Indexer.MaxResults <= 0 {
+		return errors.New("indexer.max_results must be greater than 0")
+	}
+	if len(c.Chains) > 0 {
+		if c.Role.Mode != RoleAll || c.HA.Enabled {
+			return errors.New("chains require role.mode all without ha.enabled")
+		}
+		names := make(map[string]bool)
+		chainIDs := make(map[uint64]bool)
+		for i, chain := range c.Chains {
+			if !chainNamePattern.MatchString(chain.Name) {
+				return fmt.Errorf("chains[%d].name: invalid name %q (expected lowercase letters and digits)", i, chain.Name)
+			}
+			if names[chain.Name] {
+				return fmt.Errorf("chains[%d].name: duplicate name %q", i, chain.Name)
+			}
+			names[chain.Name] = true
+			if chain.ChainID != 0 && chainIDs[chain.ChainID] {
+				return fmt.Errorf("chains[%d].chain_id: duplicate chain ID %d", i, chain.ChainID)
+			}
+			chainIDs[chain.ChainID] = true
+			if chain.Interval < 0 || chain.MaxTransactions < 0 || chain.MaxStoredBlocks < 0 || chain.MempoolMaxSize < 0 {
+				return fmt.Errorf("chains[%d]: limits cannot be negative", i)
+			}
+		}
+	}
+	if !c.Chaos.Enabled && c.Chaos != (ChaosConfig{}) {
+		return errors.New("fault injection flags require --chaos")
+	}
+	if c.Chaos.BlockLatency < 0 || c.Chaos.AttestationDelay < 0 {
+		return errors.New("chaos delays cannot be negative")
+	}
+	if c.Chaos.DropRate < 0 || c.Chaos.DropRate > 1 {
+		return errors.New("chaos drop rate must be between 0 and 1")
+	}
+	if c.Log.File == "" {
+		return errors.New("log.file cannot be empty")
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		return fmt.Errorf("log.level: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfigFile writes a configuration file to a temporary directory
+func writeConfigFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+rpc:
+  addr: ":9000"
+block:
+  max_transactions: 10
+mempool:
+  max_size: 100
+`)
+	t.Setenv(EnvPrefix+"_CONFIG", path)
+	t.Setenv(EnvPrefix+"_BLOCK_MAX_TRANSACTIONS", "20")
+	t.Setenv(EnvPrefix+"_MEMPOOL_MAX_SIZE", "200")
+
+	cfg, err := Load("test", []string{"--mempool-max-size", "300"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// Flags override the environment, which overrides the file, which
+	// overrides the defaults
+	if want := Default().Block.Interval; cfg.Block.Interval != want {
+		t.Errorf("block.interval = %v, want the default %v", cfg.Block.Interval, want)
+	}
+	if cfg.RPC.Addr != ":9000" {
+		t.Errorf("rpc.addr = %q, want the file value :9000", cfg.RPC.Addr)
+	}
+	if cfg.Block.MaxTransactions != 20 {
+		t.Errorf("block.max_transactions = %d, want the environment value 20", cfg.Block.MaxTransactions)
+	}
+	if cfg.Mempool.MaxSize != 300 {
+		t.Errorf("mempool.max_size = %d, want the flag value 300", cfg.Mempool.MaxSize)
+	}
+}
+
+func TestLoadConfigFlag(t *testing.T) {
+	env := writeConfigFile(t, "rpc:\n  addr: \":9000\"\n")
+	flag := writeConfigFile(t, "rpc:\n  addr: \":9001\"\n")
+	t.Setenv(EnvPrefix+"_CONFIG", env)
+
+	// --config takes precedence over FLASHBLOCK_CONFIG
+	cfg, err := Load("test", []string{"--config", flag})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.RPC.Addr != ":9001" {
+		t.Errorf("rpc.addr = %q, want :9001 from the --config file", cfg.RPC.Addr)
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	t.Setenv(EnvPrefix+"_BLOCK_MAX_TRANSACTIONS", "many")
+	if _, err := Load("test", nil); err == nil {
+		t.Error("invalid environment value accepted")
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// reloadableKeys are the settings that can be changed without a restart
+var reloadableKeys = map[string]bool{
+	"block.interval":         true,
+	"block.max_transactions": true,
+	"rpc.cors_origins":       true,
+	"rpc.rate_limit.rps":     true,
+	"rpc.rate_limit.burst":   true,
+	"mempool.min_priority":   true,
+	"mempool.blacklist":      true,
+	"log.level":              true,
+}
+
+// Change describes a configuration value that differs between two configurations
+type Change struct {
+	Key string // YAML path of the setting, e.g. rpc.addr
+	Old any
+	New any
+}
+
+// String formats the change for logging
+func (c Change) String() string {
+	if sensitiveKeys[c.Key] {
+		return fmt.Sprintf("%s: <redacted>", c.Key)
+	}
+	return fmt.Sprintf("%s: %v -> %v", c.Key, c.Old, c.New)
+}
+
+// Reloadable reports whether the setting can be changed without a restart
+func (c Change) Reloadable() bool {
+	return IsReloadable(c.Key)
+}
+
+// IsReloadable reports whether the setting with the given YAML path can be changed at runtime
+func IsReloadable(key string) bool {
+	return reloadableKeys[key]
+}
+
+// ReloadableKeys returns the YAML paths of all settings that can be changed at runtime
+func ReloadableKeys() []string {
+	keys := make([]string, 0, len(reloadableKeys))
+	for key := range reloadableKeys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Diff returns the settings whose values differ between old and new
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffValues(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", &changes)
+	return changes
+}
+
+// diffValues compares two struct values field by field
+func diffValues(a, b reflect.Value, prefix string, changes *[]Change) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		fa, fb := a.Field(i), b.Field(i)
+		if fa.Kind() == reflect.Struct && fa.Type() != durationType {
+			diffValues(fa, fb, key, changes)
+			continue
+		}
+
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			*changes = append(*changes, Change{Key: key, Old: fa.Interface(), New: fb.Interface()})
+		}
+	}
+}
+
+// ApplyReloadable copies the runtime-tunable settings of src into c
+func (c *Config) ApplyReloadable(src *Config) {
+	c.Block.Interval = src.Block.Interval
+	c.Block.MaxTransactions = src.Block.MaxTransactions
+	c.RPC.CORSOrigins = src.RPC.CORSOrigins
+	c.RPC.RateLimit = src.RPC.RateLimit
+	c.Mempool.MinPriority = src.Mempool.MinPriority
+	c.Mempool.Blacklist = src.Mempool.Blacklist
+	c.Log.Level = src.Log.Level
+}
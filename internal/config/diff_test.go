@@ -0,0 +1,105 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSensitiveKeysRedacted(t *testing.T) {
+	old, next := Default(), Default()
+	for key := range sensitiveKeys {
+		if err := old.Set(key, "old-secret"); err != nil {
+			t.Fatal(err)
+		}
+		if err := next.Set(key, "new-secret"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Neither the marshaled configuration nor the logged changes show a secret
+	data, err := next.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("marshaled configuration holds a secret:\n%s", data)
+	}
+	changes := Diff(old, next)
+	if len(changes) != len(sensitiveKeys) {
+		t.Fatalf("%d changes, want %d", len(changes), len(sensitiveKeys))
+	}
+	for _, change := range changes {
+		if s := change.String(); strings.Contains(s, "secret") {
+			t.Errorf("change logged as %q", s)
+		}
+	}
+
+	// Marshaling leaves the configuration untouched
+	if next.RPC.AuthTokens[0] != "new-secret" {
+		t.Errorf("auth tokens = %v after Marshal", next.RPC.AuthTokens)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old, next := Default(), Default()
+	if changes := Diff(old, next); len(changes) != 0 {
+		t.Fatalf("changes between equal configurations: %v", changes)
+	}
+
+	next.Log.Level = "debug"
+	next.RPC.RateLimit.RPS = 50
+	next.RPC.Addr = ":9000"
+	next.Mempool.Blacklist = []string{"0x01"}
+	changes := Diff(old, next)
+
+	want := map[string]bool{
+		"log.level":          true,
+		"rpc.rate_limit.rps": true,
+		"rpc.addr":           false,
+		"mempool.blacklist":  true,
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes %v, want %d", changes, len(want))
+	}
+	for _, change := range changes {
+		reloadable, ok := want[change.Key]
+		if !ok {
+			t.Errorf("unexpected change %s", change)
+			continue
+		}
+		if change.Reloadable() != reloadable {
+			t.Errorf("%s reloadable = %v, want %v", change.Key, change.Reloadable(), reloadable)
+		}
+	}
+	if got := (Change{Key: "log.level", Old: "info", New: "debug"}).String(); got != "log.level: info -> debug" {
+		t.Errorf("change logged as %q", got)
+	}
+}
+
+func TestApplyReloadable(t *testing.T) {
+	current, next := Default(), Default()
+	for key := range reloadableKeys {
+		var value string
+		switch key {
+		case "block.interval":
+			value = "2s"
+		case "rpc.cors_origins", "mempool.blacklist":
+			value = "a,b"
+		case "log.level":
+			value = "debug"
+		default:
+			value = "7"
+		}
+		if err := next.Set(key, value); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	next.RPC.Addr = ":9000"
+
+	// Every reloadable setting is applied and nothing else
+	current.ApplyReloadable(next)
+	changes := Diff(current, next)
+	if len(changes) != 1 || changes[0].Key != "rpc.addr" {
+		t.Errorf("changes after applying the reloadable settings: %v", changes)
+	}
+}
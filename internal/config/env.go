@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of environment variables overriding configuration values.
+// The variable name of a key is the prefix followed by its upper-cased YAML path,
+// e.g. FLASHBLOCK_RPC_ADDR for rpc.addr. Lists of values are comma separated;
+// lists of sections and maps are written in YAML, or JSON, as in the file.
+const EnvPrefix = "FLASHBLOCK"
+
+// envConfigFile names the configuration file when --config is not given
+const envConfigFile = EnvPrefix + "_CONFIG"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ApplyEnv overrides configuration values with the environment variables found by lookup
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	return applyEnv(reflect.ValueOf(c).Elem(), EnvPrefix, lookup)
+}
+
+// EnvKeys returns the names of all environment variables recognized by the configuration
+func EnvKeys() []string {
+	var keys []string
+	collectEnvKeys(reflect.TypeOf(Config{}), EnvPrefix, &keys)
+	return keys
+}
+
+// applyEnv walks the struct value and sets every field that has a matching variable
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := envKey(prefix, field)
+		if key == "" {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(v.Field(i), key, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// collectEnvKeys appends the variable names of all fields of the struct type
+func collectEnvKeys(t reflect.Type, prefix string, keys *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := envKey(prefix, field)
+		if key == "" {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			collectEnvKeys(field.Type, key, keys)
+			continue
+		}
+		*keys = append(*keys, key)
+	}
+}
+
+// envKey returns the variable name of a struct field, or "" if it has no YAML name
+func envKey(prefix string, field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "" || name == "-" {
+		return ""
+	}
+	return prefix + "_" + strings.ToUpper(name)
+}
+
+// setValue parses the string into the field according to its type
+func setValue(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return setYAML(v, value)
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		return setYAML(v, value)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// setYAML parses a list of sections or a map written in YAML, or JSON, as in
+// the configuration file, e.g. [{name: a, address: "host:2002"}] or {k: v}
+func setYAML(v reflect.Value, value string) error {
+	parsed := reflect.New(v.Type())
+	if err := yaml.UnmarshalStrict([]byte(value), parsed.Interface()); err != nil {
+		return err
+	}
+	v.Set(parsed.Elem())
+	return nil
+}
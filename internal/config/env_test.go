@@ -0,0 +1,95 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// envSamples returns a valid value for the variable of every field of the
+// struct type, by variable name
+func envSamples(t reflect.Type, prefix string, samples map[string]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := envKey(prefix, field)
+		if key == "" {
+			continue
+		}
+		switch {
+		case field.Type == durationType:
+			samples[key] = "1s"
+		case field.Type.Kind() == reflect.Struct:
+			envSamples(field.Type, key, samples)
+		case field.Type.Kind() == reflect.String:
+			samples[key] = "value"
+		case field.Type.Kind() == reflect.Bool:
+			samples[key] = "true"
+		case field.Type.Kind() == reflect.Float64:
+			samples[key] = "0.5"
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String:
+			samples[key] = "a,b"
+		case field.Type.Kind() == reflect.Slice:
+			samples[key] = "[{}]"
+		case field.Type.Kind() == reflect.Map:
+			samples[key] = "{k: v}"
+		default:
+			samples[key] = "1"
+		}
+	}
+}
+
+func TestEveryEnvKeyCanBeSet(t *testing.T) {
+	samples := make(map[string]string)
+	envSamples(reflect.TypeOf(Config{}), EnvPrefix, samples)
+	keys := EnvKeys()
+	if len(keys) != len(samples) {
+		t.Fatalf("%d variables advertised, %d settings", len(keys), len(samples))
+	}
+	for _, key := range keys {
+		value, ok := samples[key]
+		if !ok {
+			t.Errorf("%s does not name a setting", key)
+			continue
+		}
+		cfg := Default()
+		lookup := func(name string) (string, bool) {
+			if name == key {
+				return value, true
+			}
+			return "", false
+		}
+		if err := cfg.ApplyEnv(lookup); err != nil {
+			t.Errorf("%s=%s: %v", key, value, err)
+		}
+	}
+}
+
+func TestEnvSections(t *testing.T) {
+	env := map[string]string{
+		"FLASHBLOCK_CHAINS":                     `[{name: fast, interval: 50ms, max_transactions: 10}, {"name": "slow", "chain_id": 7}]`,
+		"FLASHBLOCK_EXTENSIONS_ORDERER_OPTIONS": `{window: "8"}`,
+		"FLASHBLOCK_TRUSTED_TIME_SERVERS":       `[{name: a, address: "time.example:2002", public_key: "key"}]`,
+	}
+	cfg := Default()
+	if err := cfg.ApplyEnv(func(key string) (string, bool) { v, ok := env[key]; return v, ok }); err != nil {
+		t.Fatal(err)
+	}
+	want := []ChainConfig{{Name: "fast", Interval: 50 * time.Millisecond, MaxTransactions: 10}, {Name: "slow", ChainID: 7}}
+	if !reflect.DeepEqual(cfg.Chains, want) {
+		t.Errorf("chains = %+v, want %+v", cfg.Chains, want)
+	}
+	if cfg.Extensions.Orderer.Options["window"] != "8" {
+		t.Errorf("orderer options = %v", cfg.Extensions.Orderer.Options)
+	}
+	if len(cfg.TrustedTime.Servers) != 1 || cfg.TrustedTime.Servers[0].Address != "time.example:2002" {
+		t.Errorf("trusted time servers = %+v", cfg.TrustedTime.Servers)
+	}
+
+	// Unknown fields are rejected as in the file
+	err := cfg.ApplyEnv(func(key string) (string, bool) {
+		return `[{name: a, bogus: 1}]`, key == "FLASHBLOCK_CHAINS"
+	})
+	if err == nil {
+		t.Error("chain with an unknown field accepted")
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// settableKeys are the settings that can be changed through the admin API
+var settableKeys = map[string]bool{
+	"block.interval":         true,
+	"block.max_transactions": true,
+	"mempool.min_priority":   true,
+	"rpc.rate_limit.rps":     true,
+	"rpc.rate_limit.burst":   true,
+	"log.level":              true,
+}
+
+// IsSettable reports whether the setting with the given YAML path can be changed through the admin API
+func IsSettable(key string) bool {
+	return settableKeys[key]
+}
+
+// SettableKeys returns the YAML paths of all settings that can be changed through the admin API
+func SettableKeys() []string {
+	keys := make([]string, 0, len(settableKeys))
+	for key := range settableKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Set parses the value of the setting with the given YAML path, e.g. block.interval,
+// using the same format as the environment variables
+func (c *Config) Set(key string, value string) error {
+	v, err := c.setting(key)
+	if err != nil {
+		return err
+	}
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("invalid value for %s: %v", key, err)
+	}
+	return nil
+}
+
+// setting returns the field of the setting with the given YAML path
+func (c *Config) setting(key string) (reflect.Value, error) {
+	v := reflect.ValueOf(c).Elem()
+	for _, name := range strings.Split(key, ".") {
+		field, ok := fieldByYAMLName(v, name)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("unknown setting %q", key)
+		}
+		v = field
+	}
+
+	if v.Kind() == reflect.Struct && v.Type() != durationType {
+		return reflect.Value{}, fmt.Errorf("%q is a section, not a setting", key)
+	}
+	return v, nil
+}
+
+// fieldByYAMLName returns the field of the struct value with the given YAML name
+func fieldByYAMLName(v reflect.Value, name string) (reflect.Value, bool) {
+	if v.Kind() != reflect.Struct || v.Type() == durationType {
+		return reflect.Value{}, false
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]; tag == name && tag != "-" {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
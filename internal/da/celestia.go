@@ -0,0 +1,98 @@
+package da
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Celestia namespace layout: a version byte followed by 28 ID bytes, of which
+// version 0 namespaces use the last 10
+const (
+	namespaceSize   = 29
+	namespaceIDSize = 10
+)
+
+// celestiaBlob is a blob in the JSON-RPC API of celestia-node
+type celestiaBlob struct {
+	Namespace    []byte `json:"namespace"`
+	Data         []byte `json:"data"`
+	ShareVersion uint32 `json:"share_version"`
+	Commitment   []byte `json:"commitment"`
+}
+
+// celestiaSubmitOptions are the transaction options of blob.Submit; the
+// node estimates the gas price if none is set
+type celestiaSubmitOptions struct {
+	GasPrice      float64 `json:"gas_price,omitempty"`
+	IsGasPriceSet bool    `json:"is_gas_price_set,omitempty"`
+}
+
+// Celestia submits blobs through the JSON-RPC API of a celestia-node
+type Celestia struct {
+	client    *rpc.Client
+	namespace []byte
+	gasPrice  float64
+}
+
+// NewCelestia connects to a celestia-node. The namespace ID has at most 10
+// bytes, and a gas price of 0 lets the node estimate it.
+func NewCelestia(ctx context.Context, url, authToken string, namespaceID []byte, gasPrice float64) (*Celestia, error) {
+	if len(namespaceID) == 0 || len(namespaceID) > namespaceIDSize {
+		return nil, fmt.Errorf("celestia namespace ID must have 1 to %d bytes", namespaceIDSize)
+	}
+	namespace := make([]byte, namespaceSize)
+	copy(namespace[namespaceSize-len(namespaceID):], namespaceID)
+
+	var options []rpc.ClientOption
+	if authToken != "" {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+authToken)
+		options = append(options, rpc.WithHeaders(header))
+	}
+	client, err := rpc.DialOptions(ctx, url, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to celestia-node: %v", err)
+	}
+	return &Celestia{client: client, namespace: namespace, gasPrice: gasPrice}, nil
+}
+
+// Submit publishes data as a blob and returns the height and commitment it was included with
+func (c *Celestia) Submit(ctx context.Context, data []byte) (*model.DAReference, error) {
+	blob := &celestiaBlob{Namespace: c.namespace, Data: data}
+	options := &celestiaSubmitOptions{GasPrice: c.gasPrice, IsGasPriceSet: c.gasPrice > 0}
+
+	var height uint64
+	if err := c.client.CallContext(ctx, &height, "blob.Submit", []*celestiaBlob{blob}, options); err != nil {
+		return nil, err
+	}
+
+	// The commitment is computed by the node, so it is read back from the included blob
+	var blobs []*celestiaBlob
+	if err := c.client.CallContext(ctx, &blobs, "blob.GetAll", height, [][]byte{c.namespace}); err != nil {
+		return nil, fmt.Errorf("failed to read the blob back at height %d: %v", height, err)
+	}
+	for _, included := range blobs {
+		if bytes.Equal(included.Data, data) {
+			return &model.DAReference{
+				Layer:      "celestia",
+				Height:     height,
+				Commitment: "0x" + hex.EncodeToString(included.Commitment),
+				Namespace:  "0x" + hex.EncodeToString(c.namespace),
+			}, nil
+		}
+	}
+	return nil, errors.New("submitted blob not found at its height")
+}
+
+// Close disconnects from the node
+func (c *Celestia) Close() {
+	c.client.Close()
+}
@@ -0,0 +1,202 @@
+package da
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/holiman/uint256"
+)
+
+// Blob layout: every 32 byte field element carries 31 bytes of data after a
+// zero byte, so its value stays below the field modulus
+const (
+	fieldElements     = 4096
+	fieldElementData  = 31
+	blobCapacity      = fieldElements * fieldElementData
+	maxBlobsPerTx     = 6 // Cancun limit
+	receiptPollPeriod = 2 * time.Second
+)
+
+// Ethereum publishes data in the blobs of EIP-4844 transactions
+type Ethereum struct {
+	client  *ethclient.Client
+	key     *ecdsa.PrivateKey
+	from    common.Address
+	to      common.Address
+	chainID *big.Int
+}
+
+// NewEthereum connects to an Ethereum JSON-RPC endpoint. Blob transactions
+// are signed with key and sent to the address to, or to the sender if to is
+// the zero address.
+func NewEthereum(ctx context.Context, url string, key *ecdsa.PrivateKey, to common.Address) (*Ethereum, error) {
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the Ethereum node: %v", err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to get the chain ID: %v", err)
+	}
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	if to == (common.Address{}) {
+		to = from
+	}
+	return &Ethereum{client: client, key: key, from: from, to: to, chainID: chainID}, nil
+}
+
+// Submit publishes data in a blob transaction and waits for its inclusion.
+// The blobs hold the 4 byte big-endian length of the data followed by the data.
+func (e *Ethereum) Submit(ctx context.Context, data []byte) (*model.DAReference, error) {
+	sidecar, err := newSidecar(data)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := e.newTransaction(ctx, sidecar)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.client.SendTransaction(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to send blob transaction: %v", err)
+	}
+
+	receipt, err := e.waitForReceipt(ctx, tx.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("blob transaction %s failed", tx.Hash().Hex())
+	}
+
+	hashes := tx.BlobHashes()
+	ref := &model.DAReference{
+		Layer:      "ethereum",
+		Height:     receipt.BlockNumber.Uint64(),
+		Commitment: hashes[0].Hex(),
+		TxHash:     tx.Hash().Hex(),
+	}
+	for _, hash := range hashes {
+		ref.BlobHashes = append(ref.BlobHashes, hash.Hex())
+	}
+	return ref, nil
+}
+
+// newTransaction creates a signed blob transaction carrying the sidecar
+func (e *Ethereum) newTransaction(ctx context.Context, sidecar *types.BlobTxSidecar) (*types.Transaction, error) {
+	nonce, err := e.client.PendingNonceAt(ctx, e.from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the nonce: %v", err)
+	}
+	tip, err := e.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the gas tip: %v", err)
+	}
+	head, err := e.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the latest header: %v", err)
+	}
+	if head.BaseFee == nil {
+		return nil, errors.New("the chain does not support EIP-1559 fees")
+	}
+	blobFee, err := e.client.BlobBaseFee(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the blob base fee: %v", err)
+	}
+
+	// Twice the current base fees keep the transaction valid while fees rise
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	blobFeeCap := new(big.Int).Mul(blobFee, big.NewInt(2))
+	if blobFeeCap.Sign() == 0 {
+		blobFeeCap.SetUint64(1)
+	}
+
+	tx := types.NewTx(&types.BlobTx{
+		ChainID:    uint256.MustFromBig(e.chainID),
+		Nonce:      nonce,
+		GasTipCap:  uint256.MustFromBig(tip),
+		GasFeeCap:  uint256.MustFromBig(feeCap),
+		Gas:        21000,
+		To:         e.to,
+		BlobFeeCap: uint256.MustFromBig(blobFeeCap),
+		BlobHashes: sidecar.BlobHashes(),
+		Sidecar:    sidecar,
+	})
+	return types.SignTx(tx, types.NewCancunSigner(e.chainID), e.key)
+}
+
+// waitForReceipt polls for the receipt of a transaction until the context ends
+func (e *Ethereum) waitForReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	ticker := time.NewTicker(receiptPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := e.client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("failed to get the receipt of %s: %v", hash.Hex(), err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("blob transaction %s not included: %v", hash.Hex(), ctx.Err())
+		}
+	}
+}
+
+// Close disconnects from the node
+func (e *Ethereum) Close() {
+	e.client.Close()
+}
+
+// newSidecar packs data, prefixed with its length, into blobs with their KZG
+// commitments and proofs
+func newSidecar(data []byte) (*types.BlobTxSidecar, error) {
+	payload := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	payload = append(payload, data...)
+
+	count := (len(payload) + blobCapacity - 1) / blobCapacity
+	if count > maxBlobsPerTx {
+		return nil, fmt.Errorf("batch of %d bytes needs %d blobs, at most %d fit in a transaction", len(data), count, maxBlobsPerTx)
+	}
+
+	sidecar := &types.BlobTxSidecar{}
+	for i := 0; i < count; i++ {
+		blob := new(kzg4844.Blob)
+		chunk := payload[i*blobCapacity : min((i+1)*blobCapacity, len(payload))]
+		for j := 0; j*fieldElementData < len(chunk); j++ {
+			copy(blob[j*32+1:(j+1)*32], chunk[j*fieldElementData:])
+		}
+
+		commitment, err := kzg4844.BlobToCommitment(blob)
+		if err != nil {
+			return nil, err
+		}
+		proof, err := kzg4844.ComputeBlobProof(blob, commitment)
+		if err != nil {
+			return nil, err
+		}
+		sidecar.Blobs = append(sidecar.Blobs, *blob)
+		sidecar.Commitments = append(sidecar.Commitments, commitment)
+		sidecar.Proofs = append(sidecar.Proofs, proof)
+	}
+	return sidecar, nil
+}
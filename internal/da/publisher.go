@@ -0,0 +1,459 @@
+package da
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/metrics"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Batch formats, given by the first byte of a published batch. The rest is
+// the RLP list of the canonical encodings of the blocks.
+const (
+	FormatPlain = 0x00 // Uncompressed
+	FormatGzip  = 0x01 // Compressed with gzip
+)
+
+// queueSize is the number of sealed blocks waiting to be batched
+const queueSize = 256
+
+// pendingExt is the file extension of the batches waiting for submission
+const pendingExt = ".batch"
+
+// Client submits data to a data-availability layer
+type Client interface {
+	// Submit publishes data and returns where it was included; the block
+	// range of the reference is filled in by the publisher
+	Submit(ctx context.Context, data []byte) (*model.DAReference, error)
+	Close()
+}
+
+// Config holds configuration for the DA publisher
+type Config struct {
+	BatchSize     int           // Blocks per submission
+	MaxBatchDelay time.Duration // Time a partial batch waits for more blocks
+	Compress      bool          // Compress batches with gzip
+	Timeout       time.Duration // Deadline of a submission, including its inclusion
+	MaxRetries    int           // Resubmissions of a batch that was not included
+	RetryBackoff  time.Duration // Wait before the first resubmission, doubled for every further one
+	LogPath       string        // Log of the published references (optional)
+	PendingDir    string        // Batches waiting for submission, kept across restarts (optional)
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		BatchSize:     1,
+		MaxBatchDelay: 10 * time.Second,
+		Timeout:       time.Minute,
+		MaxRetries:    3,
+		RetryBackoff:  time.Second,
+	}
+}
+
+// Publisher posts the blocks sealed by the processor to a data-availability
+// layer in batches and records the DA reference on the blocks. Batches are
+// submitted in order by their own goroutine, so a slow layer does not hold up
+// the sealed blocks, and a batch that cannot be submitted is retried until it
+// is included before any later batch. References are appended to a log and
+// pending batches are kept in a directory, so both survive a restart; recent
+// blocks that were not batched before a restart are batched first.
+type Publisher struct {
+	processor *processor.BlockProcessor
+	client    Client
+	metrics   *metrics.Metrics
+	config    *Config
+	log       *os.File
+	batched   uint64 // Number of the last block in a batch
+
+	mu      sync.Mutex      // Protects pending
+	pending []*pendingBatch // Batches waiting for submission, oldest first
+	added   chan struct{}   // Signals the submitter that a batch was added
+
+	ctx    context.Context // Cancelled by Close, aborting a submission in progress
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// pendingBatch is an encoded batch waiting for submission
+type pendingBatch struct {
+	first, last uint64
+	data        []byte
+}
+
+// New creates a publisher of the sealed blocks of the processor. The
+// references in the log are attached to the recent blocks of the processor,
+// so it must be called after the processor is restored.
+func New(bp *processor.BlockProcessor, client Client, m *metrics.Metrics, config *Config) (*Publisher, error) {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultConfig().BatchSize
+	}
+	p := &Publisher{
+		processor: bp,
+		client:    client,
+		metrics:   m,
+		config:    config,
+		added:     make(chan struct{}, 1),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	if config.LogPath != "" {
+		refs, err := readLog(config.LogPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			bp.RecordDA(ref)
+			p.batched = max(p.batched, ref.LastBlock)
+		}
+		if p.log, err = os.OpenFile(config.LogPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+			return nil, fmt.Errorf("failed to open DA log: %v", err)
+		}
+	}
+
+	if config.PendingDir != "" {
+		if err := os.MkdirAll(config.PendingDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create DA pending directory: %v", err)
+		}
+		pending, err := readPending(config.PendingDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, batch := range pending {
+			// A batch published just before a crash may still be on disk
+			if batch.last <= p.batched {
+				p.removePending(batch)
+				continue
+			}
+			p.pending = append(p.pending, batch)
+			p.batched = batch.last
+		}
+		if len(p.pending) > 0 {
+			logging.Infof("Resuming %d pending DA batches from block %d", len(p.pending), p.pending[0].first)
+		}
+	}
+	return p, nil
+}
+
+// Start publishes the sealed blocks until the publisher is closed
+func (p *Publisher) Start() {
+	blocks := make(chan events.BlockSealed, queueSize)
+	sub := p.processor.Events().BlockSealed.Subscribe(blocks)
+
+	// Recent blocks sealed before a restart without being batched come first
+	var batch []*model.Block
+	last := p.batched
+	if last > 0 {
+		for _, block := range p.processor.GetProcessedBlocks() {
+			if block.Number > last {
+				batch = append(batch, block)
+				last = block.Number
+			}
+		}
+	}
+
+	p.wg.Add(1)
+	go p.submitLoop()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer sub.Unsubscribe()
+
+		timer := time.NewTimer(p.config.MaxBatchDelay)
+		defer timer.Stop()
+		for {
+			if len(batch) >= p.config.BatchSize {
+				p.seal(batch[:p.config.BatchSize])
+				batch = batch[p.config.BatchSize:]
+				continue
+			}
+
+			select {
+			case ev := <-blocks:
+				// Blocks sealed while the recent blocks were collected arrive
+				// twice, and imported blocks are published by their builder
+				if ev.Imported || ev.Block.Number <= last {
+					continue
+				}
+				if len(batch) == 0 {
+					timer.Reset(p.config.MaxBatchDelay)
+				}
+				batch = append(batch, ev.Block)
+				last = ev.Block.Number
+			case <-timer.C:
+				// Partial batches are published once the first block waited long enough
+				if len(batch) > 0 {
+					p.seal(batch)
+					batch = nil
+				}
+			case <-sub.Err():
+				return
+			case <-p.ctx.Done():
+				// Blocks waiting for the batch to fill are batched now; the
+				// batch is published after a restart
+				if len(batch) > 0 {
+					p.seal(batch)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Close stops publishing; pending batches are published after a restart
+func (p *Publisher) Close() {
+	p.cancel()
+	p.wg.Wait()
+
+	p.client.Close()
+	if p.log != nil {
+		p.log.Close()
+	}
+}
+
+// seal encodes blocks into a batch, keeps it in the pending directory and
+// queues it for submission
+func (p *Publisher) seal(blocks []*model.Block) {
+	batch := &pendingBatch{first: blocks[0].Number, last: blocks[len(blocks)-1].Number}
+	data, err := EncodeBatch(blocks, p.config.Compress)
+	if err != nil {
+		logging.Errorf("Failed to encode blocks %d-%d for DA: %v", batch.first, batch.last, err)
+		return
+	}
+	batch.data = data
+
+	if p.config.PendingDir != "" {
+		if err := writePending(p.pendingPath(batch), data); err != nil {
+			logging.Errorf("Failed to keep DA batch of blocks %d-%d: %v", batch.first, batch.last, err)
+		}
+	}
+
+	p.mu.Lock()
+	p.pending = append(p.pending, batch)
+	p.mu.Unlock()
+
+	select {
+	case p.added <- struct{}{}:
+	default:
+	}
+}
+
+// submitLoop publishes the pending batches in order until the publisher is
+// closed. A batch that still fails after its retries is retried after the
+// longest backoff, so later batches never overtake it.
+func (p *Publisher) submitLoop() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		var batch *pendingBatch
+		if len(p.pending) > 0 {
+			batch = p.pending[0]
+		}
+		p.mu.Unlock()
+
+		if batch == nil {
+			select {
+			case <-p.added:
+				continue
+			case <-p.ctx.Done():
+				return
+			}
+		}
+
+		if err := p.publish(batch); err != nil {
+			if p.ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-time.After(max(p.config.RetryBackoff<<p.config.MaxRetries, time.Second)):
+			case <-p.ctx.Done():
+				return
+			}
+			continue
+		}
+
+		p.mu.Lock()
+		p.pending = p.pending[1:]
+		p.mu.Unlock()
+		p.removePending(batch)
+	}
+}
+
+// publish submits a batch, retrying with exponential backoff, and records its reference
+func (p *Publisher) publish(batch *pendingBatch) error {
+	first, last := batch.first, batch.last
+	backoff := p.config.RetryBackoff
+	var ref *model.DAReference
+	var err error
+	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-p.ctx.Done():
+				return p.ctx.Err()
+			}
+			backoff *= 2
+		}
+
+		ctx, cancel := context.WithTimeout(p.ctx, p.config.Timeout)
+		ref, err = p.client.Submit(ctx, batch.data)
+		cancel()
+		if err == nil {
+			break
+		}
+		if p.ctx.Err() != nil {
+			return err
+		}
+		logging.Debugf("DA submission of blocks %d-%d failed (attempt %d): %v", first, last, attempt+1, err)
+	}
+	if err != nil {
+		logging.Warnf("Failed to publish blocks %d-%d to DA, retrying: %v", first, last, err)
+		p.metrics.IncrementDAFailures()
+		return err
+	}
+
+	ref.FirstBlock, ref.LastBlock = first, last
+	p.processor.RecordDA(ref)
+	p.metrics.IncrementDASubmissions()
+	if p.log != nil {
+		if err := json.NewEncoder(p.log).Encode(ref); err != nil {
+			logging.Errorf("Failed to record DA reference of blocks %d-%d: %v", first, last, err)
+		}
+	}
+	logging.Infof("Published blocks %d-%d to %s at height %d (%d bytes)", first, last, ref.Layer, ref.Height, len(batch.data))
+	return nil
+}
+
+// pendingPath returns the file keeping a pending batch
+func (p *Publisher) pendingPath(batch *pendingBatch) string {
+	return filepath.Join(p.config.PendingDir, fmt.Sprintf("%020d-%020d%s", batch.first, batch.last, pendingExt))
+}
+
+// removePending deletes the file of a batch that no longer needs to be submitted
+func (p *Publisher) removePending(batch *pendingBatch) {
+	if p.config.PendingDir == "" {
+		return
+	}
+	if err := os.Remove(p.pendingPath(batch)); err != nil && !os.IsNotExist(err) {
+		logging.Warnf("Failed to remove published DA batch of blocks %d-%d: %v", batch.first, batch.last, err)
+	}
+}
+
+// EncodeBatch returns the encoding of a batch of blocks: the format byte
+// followed by the RLP list of their canonical encodings, compressed with gzip
+// if requested. DA references are not part of the encoding.
+func EncodeBatch(blocks []*model.Block, compress bool) ([]byte, error) {
+	encoded := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		copied := *block
+		copied.DA = nil
+		data, err := model.EncodeBlock(&copied)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = data
+	}
+	data, err := rlp.EncodeToBytes(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if !compress {
+		return append([]byte{FormatPlain}, data...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(FormatGzip)
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writePending stores a pending batch atomically
+func writePending(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// readPending returns the batches kept in dir, oldest first. Files that are
+// not pending batches, such as one cut short by a crash, are skipped.
+func readPending(dir string) ([]*pendingBatch, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DA pending directory: %v", err)
+	}
+
+	var pending []*pendingBatch
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, pendingExt) {
+			continue
+		}
+		batch := &pendingBatch{}
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, pendingExt), "%d-%d", &batch.first, &batch.last); err != nil || batch.first > batch.last {
+			logging.Warnf("Skipping invalid DA batch file %s", name)
+			continue
+		}
+		if batch.data, err = os.ReadFile(filepath.Join(dir, name)); err != nil {
+			return nil, fmt.Errorf("failed to read DA batch: %v", err)
+		}
+		pending = append(pending, batch)
+	}
+	// The names are zero-padded, so ReadDir returns them in block order
+	return pending, nil
+}
+
+// readLog returns the references recorded in the log at path. Invalid
+// records, such as a record cut short by a crash, are skipped.
+func readLog(path string) ([]*model.DAReference, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DA log: %v", err)
+	}
+	defer file.Close()
+
+	var refs []*model.DAReference
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		ref := &model.DAReference{}
+		if err := json.Unmarshal(scanner.Bytes(), ref); err != nil {
+			logging.Warnf("Skipping invalid DA log record %d: %v", line, err)
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read DA log: %v", err)
+	}
+	return refs, nil
+}
@@ -0,0 +1,157 @@
+package datadir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Subdirectories of the data directory
+const (
+	BlocksDir           = "blocks"
+	MempoolDir          = "mempool"
+	KeysDir             = "keys"
+	LogsDir             = "logs"
+	AttestationCacheDir = "attestation-cache"
+	StateDir            = "state"
+	DADir               = "da"
+	BlobsDir            = "blobs"   // Sidecars of blob transactions
+	BatchesDir          = "batches" // Batch artifacts and their index
+	IndexDir            = "index"   // SQLite database of the indexer
+	ChainsDir           = "chains"  // Holds a blocks and a state directory per additional chain
+)
+
+// lockFile is the name of the lock file guarding the data directory
+const lockFile = "LOCK"
+
+// layout lists the subdirectories with their permissions
+var layout = []struct {
+	name string
+	perm os.FileMode
+}{
+	{BlocksDir, 0755},
+	{MempoolDir, 0755},
+	{KeysDir, 0700}, // Private keys are only readable by the owner
+	{LogsDir, 0755},
+	{AttestationCacheDir, 0755},
+	{StateDir, 0755},
+	{DADir, 0755},
+	{BlobsDir, 0755},
+	{BatchesDir, 0755},
+	{IndexDir, 0755},
+}
+
+// ErrLocked is returned when the data directory is used by another process
+var ErrLocked = errors.New("data directory is in use by another process")
+
+// DataDir is an initialized and locked data directory
+type DataDir struct {
+	path string
+	lock *os.File
+}
+
+// Open creates the data directory layout if needed and locks it against use by
+// other processes. The lock is released by Close or when the process exits.
+func Open(path string) (*DataDir, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	lock, err := acquireLock(filepath.Join(path, lockFile))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range layout {
+		if err := os.MkdirAll(filepath.Join(path, dir.name), dir.perm); err != nil {
+			releaseLock(lock)
+			return nil, fmt.Errorf("failed to create %s directory: %v", dir.name, err)
+		}
+	}
+
+	return &DataDir{path: path, lock: lock}, nil
+}
+
+// acquireLock takes an exclusive lock on the lock file and records the process ID in it
+func acquireLock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			if pid := readPID(path); pid != 0 {
+				return nil, fmt.Errorf("%w (pid %d)", ErrLocked, pid)
+			}
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("failed to lock data directory: %v", err)
+	}
+
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return f, nil
+}
+
+// releaseLock unlocks and closes the lock file
+func releaseLock(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	f.Close()
+}
+
+// readPID returns the process ID recorded in the lock file, or 0 if there is none
+func readPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// Path returns the absolute path of the data directory
+func (d *DataDir) Path() string {
+	return d.path
+}
+
+// Join returns the path of a subdirectory or file inside the data directory
+func (d *DataDir) Join(elem ...string) string {
+	return filepath.Join(append([]string{d.path}, elem...)...)
+}
+
+// Chain creates the directory layout of an additional chain and returns its path
+func (d *DataDir) Chain(name string) (string, error) {
+	path := d.Join(ChainsDir, name)
+	for _, dir := range []string{BlocksDir, StateDir} {
+		if err := os.MkdirAll(filepath.Join(path, dir), 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s directory of chain %s: %v", dir, name, err)
+		}
+	}
+	return path, nil
+}
+
+// Close releases the lock on the data directory
+func (d *DataDir) Close() error {
+	if d.lock == nil {
+		return nil
+	}
+
+	// The PID is cleared so a stale lock file does not name a reused PID
+	d.lock.Truncate(0)
+	releaseLock(d.lock)
+	d.lock = nil
+	return nil
+}
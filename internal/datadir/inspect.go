@@ -0,0 +1,152 @@
+package datadir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// DirStats describes the contents of a data directory subdirectory
+type DirStats struct {
+	Name   string
+	Exists bool
+	Files  int
+	Bytes  int64
+}
+
+// Report describes a data directory without locking it
+type Report struct {
+	Path    string
+	Locked  bool // Whether a running process holds the lock
+	LockPID int  // Process ID recorded in the lock file
+	Dirs    []DirStats
+	Unknown []string // Entries that are not part of the layout
+	Total   int64
+}
+
+// Inspect reports the layout and usage of the data directory at path
+func Inspect(path string) (*Report, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open data directory: %v", err)
+	}
+
+	report := &Report{Path: path}
+	report.Locked, report.LockPID = lockState(filepath.Join(path, lockFile))
+
+	known := map[string]bool{lockFile: true}
+	for _, dir := range layout {
+		known[dir.name] = true
+
+		stats, err := dirStats(filepath.Join(path, dir.name))
+		if err != nil {
+			return nil, err
+		}
+		stats.Name = dir.name
+		report.Dirs = append(report.Dirs, stats)
+		report.Total += stats.Bytes
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !known[entry.Name()] {
+			report.Unknown = append(report.Unknown, entry.Name())
+		}
+	}
+
+	return report, nil
+}
+
+// lockState reports whether the lock file is held by a process and the recorded PID
+func lockState(path string) (bool, int) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, 0
+	}
+	defer f.Close()
+
+	pid := readPID(path)
+
+	// A shared lock cannot be taken while the server holds the exclusive lock
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		return errors.Is(err, syscall.EWOULDBLOCK), pid
+	}
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	return false, pid
+}
+
+// dirStats counts the files and bytes below dir
+func dirStats(dir string) (DirStats, error) {
+	var stats DirStats
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		stats.Exists = true
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			stats.Files++
+			stats.Bytes += info.Size()
+		}
+		return nil
+	})
+
+	return stats, err
+}
+
+// Print writes the report in a human readable form
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Data directory: %s\n", r.Path)
+	if r.Locked {
+		fmt.Fprintf(w, "Lock: held by pid %d\n", r.LockPID)
+	} else {
+		fmt.Fprintln(w, "Lock: not held")
+	}
+
+	fmt.Fprintln(w, "Contents:")
+	for _, dir := range r.Dirs {
+		if !dir.Exists {
+			fmt.Fprintf(w, "  %-18s missing\n", dir.Name+"/")
+			continue
+		}
+		fmt.Fprintf(w, "  %-18s %6d files  %s\n", dir.Name+"/", dir.Files, formatBytes(dir.Bytes))
+	}
+	fmt.Fprintf(w, "  %-18s %6s        %s\n", "total", "", formatBytes(r.Total))
+
+	for _, name := range r.Unknown {
+		fmt.Fprintf(w, "  Unknown entry: %s\n", name)
+	}
+}
+
+// formatBytes formats a size with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
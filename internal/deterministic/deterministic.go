@@ -0,0 +1,154 @@
+// Package deterministic provides a manual clock and sequential transaction
+// IDs for the model.Clock and model.IDGenerator hooks, so the mempool and the
+// block processor behave reproducibly in tests, property checks and fuzzing.
+package deterministic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"flashblock/internal/model"
+)
+
+// Clock is a clock whose time only moves when it is advanced. Timers fire,
+// in the order of their due times, while the clock is advanced past them.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+	seq    uint64 // Orders timers with the same due time by creation
+}
+
+// timer is a pending AfterFunc call
+type timer struct {
+	clock *Clock
+	due   time.Time
+	seq   uint64
+	f     func()
+}
+
+// NewClock creates a clock set to start
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the time of the clock
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc schedules f for when the clock is advanced by d. Unlike
+// time.AfterFunc, f is called synchronously by Advance.
+func (c *Clock) AfterFunc(d time.Duration, f func()) model.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	t := &timer{clock: c, due: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and calls the timers that became due,
+// each with the clock set to its due time
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		t := c.nextDue(end)
+		if t == nil {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		c.now = t.due
+		c.mu.Unlock()
+
+		// Called without the lock, so f may use the clock
+		t.f()
+	}
+}
+
+// nextDue removes and returns the earliest timer due at or before end; the
+// caller must hold mu
+func (c *Clock) nextDue(end time.Time) *timer {
+	sort.Slice(c.timers, func(i, j int) bool {
+		if !c.timers[i].due.Equal(c.timers[j].due) {
+			return c.timers[i].due.Before(c.timers[j].due)
+		}
+		return c.timers[i].seq < c.timers[j].seq
+	})
+	if len(c.timers) == 0 || c.timers[0].due.After(end) {
+		return nil
+	}
+	t := c.timers[0]
+	c.timers = c.timers[1:]
+	return t
+}
+
+// Pending returns the number of timers that have not fired or been stopped
+func (c *Clock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// Stop cancels the timer
+func (t *timer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IDs generates transaction IDs from a seed and a counter, so a run with the
+// same seed and the same sequence of transactions yields the same IDs. The
+// contents are hashed too, so the IDs stay unique across seeds.
+type IDs struct {
+	mu   sync.Mutex
+	seed string
+	next uint64
+}
+
+// NewIDs creates a generator for seed
+func NewIDs(seed string) *IDs {
+	return &IDs{seed: seed}
+}
+
+// TransactionID returns the hex-encoded SHA-256 hash of the seed, the counter
+// and the contents
+func (g *IDs) TransactionID(content []byte) string {
+	g.mu.Lock()
+	g.next++
+	n := g.next
+	g.mu.Unlock()
+
+	data := append([]byte(g.seed), 0)
+	data = strconv.AppendUint(data, n, 10)
+	data = append(data, 0)
+	data = append(data, content...)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// NewFactory creates a model factory with a clock set to start and IDs for seed
+func NewFactory(start time.Time, seed string) (*model.Factory, *Clock) {
+	clock := NewClock(start)
+	return model.NewFactory(clock, NewIDs(seed)), clock
+}
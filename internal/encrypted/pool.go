@@ -0,0 +1,195 @@
+package encrypted
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"flashblock/internal/eth"
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+// Errors
+var (
+	ErrDuplicate = errors.New("encrypted transaction already submitted")
+	ErrPoolFull  = errors.New("encrypted transaction pool is full")
+	ErrEmpty     = errors.New("ciphertext cannot be empty")
+	ErrTooLarge  = errors.New("ciphertext is too large")
+)
+
+// maxCiphertextSize limits the size of an encrypted transaction
+const maxCiphertextSize = 128 * 1024
+
+// Config holds configuration for the encrypted transaction pool
+type Config struct {
+	MaxTransactions int // Maximum pending encrypted transactions (0 = unlimited)
+}
+
+// Transaction is a submitted encrypted transaction
+type Transaction struct {
+	Commitment common.Hash // Keccak-256 hash of the ciphertext
+	Ciphertext []byte
+	ReceivedAt time.Time
+}
+
+// Decrypted is the outcome of decrypting the pending transactions for a block
+type Decrypted struct {
+	KeyID        common.Hash          // Key the transactions were decrypted with
+	Commitments  []common.Hash        // Every decrypted commitment in block order
+	Transactions []*model.Transaction // Valid transactions in commitment order
+}
+
+// Pool keeps encrypted transactions until the next block is built. The
+// transactions are encrypted to a key generated in memory when the pool is
+// created, so they can only be decrypted by this process; the key is lost on
+// restart, together with the pending transactions. Their contents are never
+// exposed before the block that includes them is built.
+//
+// The key is a single key held by the attested builder rather than a
+// committee key shared by threshold decryption: the builder is the only party
+// that orders transactions, and the attestation quote over the key ID binds
+// the key to its enclave.
+type Pool struct {
+	config *Config
+	key    *ecies.PrivateKey
+	keyID  common.Hash
+
+	mu      sync.Mutex // Protects quote and pending
+	quote   []byte     // Attestation quote over the key ID
+	pending map[common.Hash]*Transaction
+}
+
+// NewPool creates a pool with a new encryption key
+func NewPool(config *Config) (*Pool, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	key, err := ecies.GenerateKey(rand.Reader, crypto.S256(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the encryption key: %v", err)
+	}
+	p := &Pool{
+		config:  config,
+		key:     key,
+		pending: make(map[common.Hash]*Transaction),
+	}
+	p.keyID = crypto.Keccak256Hash(p.PublicKey())
+	return p, nil
+}
+
+// PublicKey returns the uncompressed secp256k1 public key clients encrypt to
+func (p *Pool) PublicKey() []byte {
+	return crypto.FromECDSAPub(p.key.PublicKey.ExportECDSA())
+}
+
+// KeyID returns the Keccak-256 hash of the public key
+func (p *Pool) KeyID() common.Hash {
+	return p.keyID
+}
+
+// Quote returns the attestation quote over the key ID, if one was generated
+func (p *Pool) Quote() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.quote
+}
+
+// SetQuote sets the attestation quote over the key ID
+func (p *Pool) SetQuote(quote []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.quote = quote
+}
+
+// Add keeps an encrypted transaction for the next block and returns its commitment
+func (p *Pool) Add(ciphertext []byte) (common.Hash, error) {
+	if len(ciphertext) == 0 {
+		return common.Hash{}, ErrEmpty
+	}
+	if len(ciphertext) > maxCiphertextSize {
+		return common.Hash{}, ErrTooLarge
+	}
+	commitment := crypto.Keccak256Hash(ciphertext)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.pending[commitment]; exists {
+		return commitment, ErrDuplicate
+	}
+	if p.config.MaxTransactions > 0 && len(p.pending) >= p.config.MaxTransactions {
+		return common.Hash{}, ErrPoolFull
+	}
+	p.pending[commitment] = &Transaction{
+		Commitment: commitment,
+		Ciphertext: ciphertext,
+		ReceivedAt: time.Now(),
+	}
+	return commitment, nil
+}
+
+// Len returns the number of pending encrypted transactions
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.pending)
+}
+
+// Decrypt removes the pending transactions and decrypts them in ascending
+// order of their commitments. The order is fixed before any transaction is
+// decrypted, so it cannot depend on their contents. Ciphertexts that do not
+// decrypt to a valid signed transaction are dropped.
+func (p *Pool) Decrypt() *Decrypted {
+	p.mu.Lock()
+	pending := make([]*Transaction, 0, len(p.pending))
+	for _, tx := range p.pending {
+		pending = append(pending, tx)
+	}
+	p.pending = make(map[common.Hash]*Transaction)
+	p.mu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return bytes.Compare(pending[i].Commitment[:], pending[j].Commitment[:]) < 0
+	})
+
+	result := &Decrypted{KeyID: p.keyID}
+	for _, entry := range pending {
+		result.Commitments = append(result.Commitments, entry.Commitment)
+		tx, err := p.decrypt(entry)
+		if err != nil {
+			logging.Debugf("Dropped encrypted transaction %s: %v", entry.Commitment.Hex(), err)
+			continue
+		}
+		result.Transactions = append(result.Transactions, tx)
+	}
+	return result
+}
+
+// decrypt decrypts a ciphertext into a signed transaction
+func (p *Pool) decrypt(entry *Transaction) (*model.Transaction, error) {
+	plaintext, err := p.key.Decrypt(entry.Ciphertext, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := eth.ParseRawTransaction(hex.EncodeToString(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	if tx.From == "" {
+		return nil, errors.New("invalid signature")
+	}
+	return tx, nil
+}
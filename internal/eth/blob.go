@@ -0,0 +1,40 @@
+package eth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+)
+
+// Errors of blob transactions
+var (
+	ErrMissingSidecar = errors.New("blob transaction without sidecar")
+	ErrInvalidSidecar = errors.New("invalid blob sidecar")
+)
+
+// VerifyBlobSidecar checks that a blob transaction carries a sidecar with one
+// blob, commitment and proof per versioned hash, that the commitments match
+// the versioned hashes and that every proof verifies the blob against its
+// commitment
+func VerifyBlobSidecar(tx *types.Transaction) error {
+	sidecar := tx.BlobTxSidecar()
+	if sidecar == nil {
+		return ErrMissingSidecar
+	}
+
+	hashes := tx.BlobHashes()
+	if len(sidecar.Blobs) != len(hashes) || len(sidecar.Proofs) != len(hashes) {
+		return fmt.Errorf("%w: %d blobs and %d proofs for %d versioned hashes", ErrInvalidSidecar, len(sidecar.Blobs), len(sidecar.Proofs), len(hashes))
+	}
+	if err := sidecar.ValidateBlobCommitmentHashes(hashes); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSidecar, err)
+	}
+	for i := range sidecar.Blobs {
+		if err := kzg4844.VerifyBlobProof(&sidecar.Blobs[i], sidecar.Commitments[i], sidecar.Proofs[i]); err != nil {
+			return fmt.Errorf("%w: blob %d: %v", ErrInvalidSidecar, i, err)
+		}
+	}
+	return nil
+}
@@ -30,17 +30,33 @@ func DecodeRawTransaction(rawTxHex string) (*types.Transaction, error) {
 		return nil, err
 	}
 
-	// Decode RLP encoded transaction
+	// Decode the binary encoding: a legacy RLP list or a typed envelope, with
+	// the sidecar of a blob transaction in the network form. Typed
+	// transactions wrapped in an RLP string are accepted as before.
 	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(rawTxBytes, tx); err != nil {
-		return nil, err
+	if err := tx.UnmarshalBinary(rawTxBytes); err != nil {
+		if rlpErr := rlp.DecodeBytes(rawTxBytes, tx); rlpErr != nil {
+			return nil, err
+		}
 	}
 
 	return tx, nil
 }
 
 // ConvertToModelTransaction converts an Ethereum transaction to a model.Transaction
-func ConvertToModelTransaction(ethTx *types.Transaction, rawTxHex string) (*model.Transaction, error) {
+// created by the factory, which stamps its time. The ID is the transaction hash,
+// so every node derives the same ID from the signed raw data.
+// The sidecar of a blob transaction is not part of the model: the raw data
+// is re-encoded without it, and the blobs are referenced by their versioned hashes.
+func ConvertToModelTransaction(f *model.Factory, ethTx *types.Transaction, rawTxHex string) (*model.Transaction, error) {
+	if ethTx.BlobTxSidecar() != nil {
+		raw, err := ethTx.WithoutBlobTxSidecar().MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		rawTxHex = hex.EncodeToString(raw)
+	}
+
 	var from string
 	signer := types.LatestSignerForChainID(ethTx.ChainId())
 	sender, err := types.Sender(signer, ethTx)
@@ -60,7 +76,7 @@ func ConvertToModelTransaction(ethTx *types.Transaction, rawTxHex string) (*mode
 	gasLimit := ethTx.Gas()
 	nonce := ethTx.Nonce()
 
-	return model.NewEthereumTransaction(
+	tx := f.NewEthereumTransaction(
 		from,
 		to,
 		value,
@@ -69,10 +85,16 @@ func ConvertToModelTransaction(ethTx *types.Transaction, rawTxHex string) (*mode
 		nonce,
 		data,
 		rawTxHex,
-	), nil
+	)
+	tx.ID = hex.EncodeToString(ethTx.Hash().Bytes())
+	for _, hash := range ethTx.BlobHashes() {
+		tx.BlobHashes = append(tx.BlobHashes, hash.Hex())
+	}
+	return tx, nil
 }
 
-// ParseRawTransaction parses a raw transaction hex string and returns a model.Transaction
+// ParseRawTransaction parses a raw transaction hex string and returns a
+// model.Transaction created with the wall clock
 func ParseRawTransaction(rawTxHex string) (*model.Transaction, error) {
 	// Decode the raw transaction
 	ethTx, err := DecodeRawTransaction(rawTxHex)
@@ -81,7 +103,7 @@ func ParseRawTransaction(rawTxHex string) (*model.Transaction, error) {
 	}
 
 	// Convert to our transaction model
-	return ConvertToModelTransaction(ethTx, rawTxHex)
+	return ConvertToModelTransaction(model.DefaultFactory, ethTx, rawTxHex)
 }
 
 // RecoverSender attempts to recover the sender address from a raw transaction
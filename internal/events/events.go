@@ -0,0 +1,129 @@
+// Package events is the internal event bus of a chain. The mempool, the block
+// processor and the runtime configuration publish what happens to them, and
+// the RPC subscriptions, metrics, gossip, the flashblocks feed and the block
+// publishers consume it, so the publishers do not know their consumers.
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"flashblock/internal/config"
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reasons a transaction is removed from the mempool
+const (
+	RemovedIncluded = "included" // Included in a block
+	RemovedInvalid  = "invalid"  // Can never be included, e.g. its nonce is used
+	RemovedExpired  = "expired"  // Private transaction dropped after its privacy TTL
+)
+
+// TxAdmitted is published when a transaction becomes a public pending
+// transaction: on admission, or when the privacy TTL of a private
+// transaction ends
+type TxAdmitted struct {
+	Tx *model.Transaction
+}
+
+// TxRemoved is published when pending transactions leave the mempool other
+// than by a sync with another node
+type TxRemoved struct {
+	IDs    []string
+	Reason string
+}
+
+// BlockSealed is published when a block becomes the chain head, once it is
+// attested and persisted. Imported blocks, created by another node or restored
+// from a snapshot, are published too.
+type BlockSealed struct {
+	Block     *model.Block
+	Imported  bool
+	BuildTime time.Duration // Time taken to build the block (zero if imported)
+}
+
+// BlockAttested is published when the TDX quote of a block built by this node
+// is generated, before the block is persisted
+type BlockAttested struct {
+	Block *model.Block
+	Quote []byte
+}
+
+// ConfigChanged is published when a configuration reload or admin_setConfig
+// applies changes
+type ConfigChanged struct {
+	Changes []config.Change
+}
+
+// Bus holds a feed per event. Send blocks until every subscriber received
+// the event, so subscribers drain their channels promptly, usually into a
+// buffered channel. Publishers holding a lock use Publish instead, so a slow
+// subscriber never holds up the component that publishes.
+type Bus struct {
+	TxAdmitted    event.FeedOf[TxAdmitted]
+	TxRemoved     event.FeedOf[TxRemoved]
+	BlockSealed   event.FeedOf[BlockSealed]
+	BlockAttested event.FeedOf[BlockAttested]
+	ConfigChanged event.FeedOf[ConfigChanged]
+
+	mu      sync.Mutex
+	pending []any         // Events waiting to be sent, oldest first
+	wake    chan struct{} // Signals the dispatcher that events are pending
+	start   sync.Once
+}
+
+// NewBus creates an event bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Publish queues an event to be sent on its feed and returns without waiting.
+// Events are sent from a single goroutine in the order they are published,
+// so publishers call it while holding the lock that orders their changes.
+func (b *Bus) Publish(ev any) {
+	b.start.Do(func() {
+		b.wake = make(chan struct{}, 1)
+		go b.dispatch()
+	})
+
+	b.mu.Lock()
+	b.pending = append(b.pending, ev)
+	b.mu.Unlock()
+
+	select {
+	case b.wake <- struct{}{}:
+	default:
+		// The dispatcher has yet to take the events already pending
+	}
+}
+
+// dispatch sends the published events for the lifetime of the process. The
+// queue is not bounded; it only grows while a subscriber falls behind.
+func (b *Bus) dispatch() {
+	for range b.wake {
+		b.mu.Lock()
+		pending := b.pending
+		b.pending = nil
+		b.mu.Unlock()
+
+		for _, ev := range pending {
+			switch ev := ev.(type) {
+			case TxAdmitted:
+				b.TxAdmitted.Send(ev)
+			case TxRemoved:
+				b.TxRemoved.Send(ev)
+			case BlockSealed:
+				b.BlockSealed.Send(ev)
+			case BlockAttested:
+				b.BlockAttested.Send(ev)
+			case ConfigChanged:
+				b.ConfigChanged.Send(ev)
+			default:
+				panic(fmt.Sprintf("events: unknown event %T", ev))
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package feemarket
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/mempool"
+	"flashblock/internal/metrics"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+)
+
+// Config holds configuration for the fee market
+type Config struct {
+	MempoolTarget float64       // Fraction of the mempool capacity above which the floor rises
+	BlockTarget   float64       // Fraction of the block size above which the floor rises
+	MaxPriority   int           // Ceiling of the fee floor (0 = unlimited)
+	ChangeRate    float64       // Maximum relative change of the floor per adjustment
+	Interval      time.Duration // Time between adjustments
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MempoolTarget: 0.5,
+		BlockTarget:   0.5,
+		ChangeRate:    0.125,
+		Interval:      time.Second,
+	}
+}
+
+// Estimate describes the current fee floor and the congestion behind it
+type Estimate struct {
+	MinPriority        int     `json:"min_priority"`        // Lowest priority admitted now
+	BasePriority       int     `json:"base_priority"`       // Configured minimum priority
+	Suggested          int     `json:"suggested"`           // Priority likely to be included in the next block
+	MempoolUtilization float64 `json:"mempool_utilization"` // Pending transactions per mempool capacity
+	BlockUtilization   float64 `json:"block_utilization"`   // Transactions per block size in the last period
+	Pressure           float64 `json:"pressure"`            // Utilization relative to the targets
+	Congested          bool    `json:"congested"`
+}
+
+// Market raises the fee floor of the mempool while the mempool or the recent
+// blocks are fuller than their targets and lets it decay towards the
+// configured minimum priority when they are not, so overload shows as a
+// rising price instead of a growing queue. Utilization is only measured
+// against a bounded mempool and block size.
+type Market struct {
+	mempool   *mempool.Mempool
+	processor *processor.BlockProcessor
+	metrics   *metrics.Metrics
+	config    *Config
+
+	mu          sync.Mutex
+	premium     float64 // Floor above the configured minimum priority
+	blockTxs    int     // Transactions in the blocks since the last adjustment
+	blockSlots  int     // Transactions those blocks could hold
+	blockUtil   float64 // Block utilization of the last period
+	pressure    float64
+	congested   bool
+	adjustments uint64
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a fee market for the mempool and the blocks of the processor
+func New(mp *mempool.Mempool, bp *processor.BlockProcessor, m *metrics.Metrics, config *Config) *Market {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig().Interval
+	}
+	return &Market{
+		mempool:   mp,
+		processor: bp,
+		metrics:   m,
+		config:    config,
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start adjusts the fee floor every interval until the market is closed
+func (fm *Market) Start() {
+	blocks := make(chan events.BlockSealed, 64)
+	sub := fm.processor.Events().BlockSealed.Subscribe(blocks)
+
+	fm.wg.Add(1)
+	go func() {
+		defer fm.wg.Done()
+		defer sub.Unsubscribe()
+
+		ticker := time.NewTicker(fm.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case ev := <-blocks:
+				fm.observe(ev.Block)
+			case <-ticker.C:
+				fm.adjust()
+			case <-sub.Err():
+				return
+			case <-fm.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the adjustments and removes the congestion floor
+func (fm *Market) Close() {
+	close(fm.quit)
+	fm.wg.Wait()
+	fm.mempool.SetFeeFloor(0)
+}
+
+// observe counts the transactions of a new block
+func (fm *Market) observe(block *model.Block) {
+	size := fm.processor.MaxTransactions()
+	if size <= 0 {
+		return
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.blockTxs += min(len(block.Transactions), size)
+	fm.blockSlots += size
+}
+
+// adjust moves the fee floor towards the pressure of the last period: up by
+// at least one while it is above 1, and down in proportion to the idle share
+// while it is below
+func (fm *Market) adjust() {
+	base := fm.mempool.MinPriority()
+	mempoolUtil := fm.mempoolUtilization()
+
+	fm.mu.Lock()
+	// Without a new block the last utilization holds while transactions wait
+	// for the next one; the chain is idle once nothing is pending
+	if fm.blockSlots > 0 {
+		fm.blockUtil = float64(fm.blockTxs) / float64(fm.blockSlots)
+	} else if fm.mempool.Size()+fm.mempool.PrivateSize() == 0 {
+		fm.blockUtil = 0
+	}
+	fm.blockTxs, fm.blockSlots = 0, 0
+	pressure := fm.pressureOf(mempoolUtil, fm.blockUtil)
+
+	rate := fm.config.ChangeRate
+	if pressure > 1 {
+		floor := float64(base) + fm.premium
+		fm.premium += math.Max(1, floor*rate*math.Min(pressure-1, 1))
+	} else {
+		fm.premium *= 1 - rate*(1-pressure)
+	}
+	if fm.config.MaxPriority > 0 {
+		fm.premium = math.Min(fm.premium, math.Max(float64(fm.config.MaxPriority-base), 0))
+	}
+	floor := base + int(fm.premium)
+
+	wasCongested := fm.congested
+	fm.congested = floor > base
+	fm.pressure = pressure
+	fm.adjustments++
+	fm.mu.Unlock()
+
+	fm.mempool.SetFeeFloor(floor)
+	if fm.metrics != nil {
+		fm.metrics.SetFeeFloor(fm.mempool.FeeFloor(), pressure)
+	}
+
+	switch {
+	case fm.congested && !wasCongested:
+		logging.Warnf("Congestion: raised the fee floor to %d (mempool %.0f%%, blocks %.0f%% full)", floor, mempoolUtil*100, fm.blockUtil*100)
+	case !fm.congested && wasCongested:
+		logging.Infof("Congestion cleared: the fee floor is back at %d", floor)
+	case fm.congested:
+		logging.Debugf("Fee floor %d at pressure %.2f", floor, pressure)
+	}
+}
+
+// mempoolUtilization returns the share of the mempool capacity in use
+func (fm *Market) mempoolUtilization() float64 {
+	capacity := fm.mempool.Capacity()
+	if capacity <= 0 {
+		return 0
+	}
+	return float64(fm.mempool.Size()+fm.mempool.PrivateSize()) / float64(capacity)
+}
+
+// pressureOf returns the highest utilization relative to its target
+func (fm *Market) pressureOf(mempoolUtil, blockUtil float64) float64 {
+	var pressure float64
+	if fm.mempool.Capacity() > 0 && fm.config.MempoolTarget > 0 {
+		pressure = mempoolUtil / fm.config.MempoolTarget
+	}
+	if fm.config.BlockTarget > 0 {
+		pressure = math.Max(pressure, blockUtil/fm.config.BlockTarget)
+	}
+	return pressure
+}
+
+// Estimate returns the current fee floor and the priority a transaction
+// likely needs to be included in the next block: above the lowest priority
+// of a full block of pending transactions, and at least the floor
+func (fm *Market) Estimate() *Estimate {
+	mempoolUtil := fm.mempoolUtilization()
+
+	fm.mu.Lock()
+	blockUtil := fm.blockUtil
+	pressure := fm.pressure
+	if fm.adjustments == 0 {
+		pressure = fm.pressureOf(mempoolUtil, blockUtil)
+	}
+	fm.mu.Unlock()
+
+	estimate := &Estimate{
+		MinPriority:        fm.mempool.FeeFloor(),
+		BasePriority:       fm.mempool.MinPriority(),
+		MempoolUtilization: mempoolUtil,
+		BlockUtilization:   blockUtil,
+		Pressure:           pressure,
+	}
+	estimate.Congested = estimate.MinPriority > estimate.BasePriority
+	estimate.Suggested = estimate.MinPriority
+	if size := fm.processor.MaxTransactions(); size > 0 {
+		pending := fm.mempool.GetSortedTransactions()
+		if len(pending) >= size {
+			estimate.Suggested = max(estimate.Suggested, pending[size-1].Priority+1)
+		}
+	}
+	return estimate
+}
@@ -0,0 +1,162 @@
+package feemarket
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"flashblock/internal/deterministic"
+	"flashblock/internal/mempool"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+)
+
+// newTestMarket creates a market on a mempool holding up to capacity
+// transactions and blocks of up to blockSize transactions
+func newTestMarket(t *testing.T, capacity, blockSize int, config *Config) (*Market, *mempool.Mempool) {
+	t.Helper()
+	clock := deterministic.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mp := mempool.New(&mempool.Config{MaxSize: capacity, Clock: clock, IDs: deterministic.NewIDs("seed")})
+	bp := processor.New(mp, processor.DefaultConfig())
+	bp.SetMaxTransactions(blockSize)
+	return New(mp, bp, nil, config), mp
+}
+
+// fill admits a transaction for each priority
+func fill(t *testing.T, mp *mempool.Mempool, priorities ...int) []*model.Transaction {
+	t.Helper()
+	txs := make([]*model.Transaction, len(priorities))
+	for i, priority := range priorities {
+		txs[i] = mp.Factory().NewTransaction([]byte(fmt.Sprintf("tx-%d", i)), priority)
+		if err := mp.Admit(txs[i]); err != nil {
+			t.Fatalf("Admit(%d): %v", i, err)
+		}
+	}
+	return txs
+}
+
+// block returns a block with n transactions
+func block(n int) *model.Block {
+	return &model.Block{Transactions: make([]*model.Transaction, n)}
+}
+
+func TestIdle(t *testing.T) {
+	fm, mp := newTestMarket(t, 10, 4, nil)
+	mp.SetAdmissionRules(2, nil)
+	fm.adjust()
+
+	estimate := fm.Estimate()
+	if estimate.MinPriority != 2 || estimate.Congested || estimate.Pressure != 0 {
+		t.Errorf("estimate = %+v, want the base priority without congestion", estimate)
+	}
+}
+
+func TestMempoolPressure(t *testing.T) {
+	fm, mp := newTestMarket(t, 10, 0, nil)
+	fill(t, mp, 5, 5, 5, 5, 5, 5, 5, 5)
+
+	// 80% of the mempool is in use, above the target of 50%; the floor rises
+	// by at least one per adjustment
+	fm.adjust()
+	fm.adjust()
+	estimate := fm.Estimate()
+	if estimate.MinPriority != 2 || !estimate.Congested {
+		t.Fatalf("estimate = %+v, want a floor of 2", estimate)
+	}
+	if estimate.MempoolUtilization != 0.8 || estimate.Pressure != 1.6 {
+		t.Errorf("utilization %v and pressure %v, want 0.8 and 1.6", estimate.MempoolUtilization, estimate.Pressure)
+	}
+
+	// Transactions below the floor are refused
+	tx := mp.Factory().NewTransaction([]byte("low"), 1)
+	var below *mempool.ErrBelowMinPriority
+	if err := mp.Admit(tx); !errors.As(err, &below) || below.MinPriority != 2 {
+		t.Errorf("Admit below the floor = %v, want the floor of 2", err)
+	}
+}
+
+func TestFloorDecays(t *testing.T) {
+	fm, mp := newTestMarket(t, 10, 0, nil)
+	txs := fill(t, mp, 5, 5, 5, 5, 5, 5, 5, 5)
+	for i := 0; i < 20; i++ {
+		fm.adjust()
+	}
+	high := mp.FeeFloor()
+
+	// Once the mempool drains, the floor decays back to the base priority
+	ids := make([]string, len(txs))
+	for i, tx := range txs {
+		ids[i] = tx.ID
+	}
+	mp.RemoveTransactions(ids, "included")
+	fm.adjust()
+	if floor := mp.FeeFloor(); floor >= high || floor == 0 {
+		t.Errorf("floor = %d after one idle adjustment, want it below %d but above 0", floor, high)
+	}
+	for i := 0; i < 100; i++ {
+		fm.adjust()
+	}
+	if estimate := fm.Estimate(); estimate.MinPriority != 0 || estimate.Congested {
+		t.Errorf("estimate = %+v, want the base priority without congestion", estimate)
+	}
+}
+
+func TestMaxPriority(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxPriority = 3
+	fm, mp := newTestMarket(t, 10, 0, config)
+	fill(t, mp, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5)
+	for i := 0; i < 50; i++ {
+		fm.adjust()
+	}
+	if floor := mp.FeeFloor(); floor != 3 {
+		t.Errorf("floor = %d, want the ceiling of 3", floor)
+	}
+}
+
+func TestBlockPressure(t *testing.T) {
+	fm, mp := newTestMarket(t, 0, 4, nil)
+	fill(t, mp, 5)
+
+	// Full blocks are above the target of 50%
+	fm.observe(block(4))
+	fm.observe(block(6))
+	fm.adjust()
+	if estimate := fm.Estimate(); estimate.BlockUtilization != 1 || !estimate.Congested {
+		t.Fatalf("estimate = %+v, want full blocks and congestion", estimate)
+	}
+
+	// Without a new block the utilization holds while transactions are pending
+	fm.adjust()
+	if estimate := fm.Estimate(); estimate.BlockUtilization != 1 || estimate.MinPriority != 2 {
+		t.Fatalf("estimate = %+v, want full blocks and a floor of 2", estimate)
+	}
+
+	fm.observe(block(1))
+	fm.adjust()
+	if estimate := fm.Estimate(); estimate.BlockUtilization != 0.25 || estimate.Pressure != 0.5 {
+		t.Errorf("estimate = %+v, want blocks 25%% full at pressure 0.5", estimate)
+	}
+}
+
+func TestSuggested(t *testing.T) {
+	fm, mp := newTestMarket(t, 0, 2, nil)
+	fill(t, mp, 5, 1, 3)
+
+	// A transaction must beat the lowest priority of a full block
+	if suggested := fm.Estimate().Suggested; suggested != 4 {
+		t.Errorf("suggested = %d, want 4", suggested)
+	}
+}
+
+func TestCloseRemovesFloor(t *testing.T) {
+	fm, mp := newTestMarket(t, 10, 0, nil)
+	fill(t, mp, 5, 5, 5, 5, 5, 5, 5, 5)
+	fm.Start()
+	fm.adjust()
+	fm.Close()
+	if floor := mp.FeeFloor(); floor != 0 {
+		t.Errorf("floor = %d after Close, want 0", floor)
+	}
+}
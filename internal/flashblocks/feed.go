@@ -0,0 +1,202 @@
+package flashblocks
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+	"flashblock/internal/state"
+	"flashblock/pkg/codec"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	clientQueueSize = 256              // Payloads waiting to be sent to a client
+	writeTimeout    = 10 * time.Second // Deadline for sending a message to a client
+	pingInterval    = 30 * time.Second // Keeps idle connections open through proxies
+)
+
+// Config holds configuration for the flashblocks feed
+type Config struct {
+	BlocksPerPayload int       // Consecutive blocks grouped into a payload
+	State            *state.DB // Reads the new account balances (optional)
+}
+
+// Feed streams every new block as a flashblock payload to the connected
+// WebSocket clients. Each payload is sent as a JSON text message, as done by
+// rollup-boost, so existing flashblocks consumers can subscribe directly.
+// Clients that do not keep up are disconnected.
+type Feed struct {
+	events   *events.Bus
+	builder  *builder
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex // Protects clients
+	clients map[*client]struct{}
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// client is a connected WebSocket consumer
+type client struct {
+	conn  *websocket.Conn
+	queue chan []byte
+	once  sync.Once
+}
+
+// New creates a feed of the blocks sealed on the event bus
+func New(bus *events.Bus, config *Config) *Feed {
+	return &Feed{
+		events:  bus,
+		builder: newBuilder(config.BlocksPerPayload, config.State),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true }, // Origins are checked by the CORS policy
+		},
+		clients: make(map[*client]struct{}),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start publishes the new blocks until the feed is closed
+func (f *Feed) Start() {
+	blocks := make(chan events.BlockSealed, clientQueueSize)
+	sub := f.events.BlockSealed.Subscribe(blocks)
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-blocks:
+				f.publish(ev.Block)
+			case <-sub.Err():
+				return
+			case <-f.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops publishing and disconnects all clients
+func (f *Feed) Close() {
+	close(f.quit)
+	f.wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for c := range f.clients {
+		c.close()
+		delete(f.clients, c)
+	}
+}
+
+// ClientCount returns the number of connected clients
+func (f *Feed) ClientCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.clients)
+}
+
+// ServeHTTP upgrades a request to a WebSocket connection that receives the payloads
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := f.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has responded with an error
+	}
+
+	c := &client{conn: conn, queue: make(chan []byte, clientQueueSize)}
+	f.mu.Lock()
+	select {
+	case <-f.quit:
+		f.mu.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	f.clients[c] = struct{}{}
+	f.mu.Unlock()
+	logging.Debugf("Flashblocks client connected: %s", r.RemoteAddr)
+
+	go f.writeLoop(c)
+
+	// Messages from clients are ignored; reading detects the disconnect
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	f.remove(c)
+	logging.Debugf("Flashblocks client disconnected: %s", r.RemoteAddr)
+}
+
+// publish sends the payload of a block to every client
+func (f *Feed) publish(block *model.Block) {
+	data, err := codec.EncodeFlashblock(f.builder.build(block))
+	if err != nil {
+		logging.Errorf("Failed to encode flashblock %d: %v", block.Number, err)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for c := range f.clients {
+		select {
+		case c.queue <- data:
+		default:
+			logging.Warnf("Disconnecting slow flashblocks client %s", c.conn.RemoteAddr())
+			c.close()
+			delete(f.clients, c)
+		}
+	}
+}
+
+// remove disconnects a client
+func (f *Feed) remove(c *client) {
+	f.mu.Lock()
+	delete(f.clients, c)
+	f.mu.Unlock()
+	c.close()
+}
+
+// writeLoop sends the queued payloads to a client until it disconnects
+func (f *Feed) writeLoop(c *client) {
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case data, ok := <-c.queue:
+			if !ok {
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				f.remove(c)
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				f.remove(c)
+				return
+			}
+		}
+	}
+}
+
+// close ends the connection of the client and its write loop
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.queue)
+		c.conn.Close()
+	})
+}
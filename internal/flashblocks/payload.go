@@ -0,0 +1,188 @@
+package flashblocks
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"strings"
+
+	"flashblock/internal/eth"
+	"flashblock/internal/model"
+	"flashblock/internal/state"
+	"flashblock/pkg/codec"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// receiptTypes names the receipt types as in the rollup-boost metadata
+var receiptTypes = map[uint8]string{
+	types.LegacyTxType:     "Legacy",
+	types.AccessListTxType: "Eip2930",
+	types.DynamicFeeTxType: "Eip1559",
+	types.BlobTxType:       "Eip4844",
+	types.SetCodeTxType:    "Eip7702",
+}
+
+// builder converts blocks to payloads, accumulating the diff of the current payload
+type builder struct {
+	blocksPerPayload uint64
+	state            *state.DB // Reads the new account balances (optional)
+
+	number   uint64 // Payload number of the accumulated blocks
+	gasUsed  uint64
+	receipts types.Receipts
+}
+
+// newBuilder creates a builder grouping blocksPerPayload blocks into a payload
+func newBuilder(blocksPerPayload int, db *state.DB) *builder {
+	return &builder{
+		blocksPerPayload: uint64(blocksPerPayload),
+		state:            db,
+	}
+}
+
+// build returns the payload of a block. Blocks are numbered from 1, so
+// payload n holds blocks (n-1)*blocksPerPayload+1 to n*blocksPerPayload.
+func (b *builder) build(block *model.Block) *codec.Flashblock {
+	number := (block.Number-1)/b.blocksPerPayload + 1
+	index := (block.Number - 1) % b.blocksPerPayload
+	if index == 0 || number != b.number {
+		// A payload joined after its start accumulates from the first block seen
+		b.number, b.gasUsed, b.receipts = number, 0, nil
+	}
+
+	payload := &codec.Flashblock{
+		PayloadID: payloadID(number),
+		Index:     index,
+		Metadata: codec.FlashblockMetadata{
+			BlockNumber:        number,
+			NewAccountBalances: b.balances(block),
+			Receipts:           make(map[common.Hash]map[string]*codec.FlashblockReceipt),
+		},
+	}
+	if index == 0 {
+		payload.Base = &codec.FlashblockBase{
+			ParentHash:    common.HexToHash(block.PrevBlockID),
+			FeeRecipient:  common.HexToAddress(block.FeeRecipient),
+			BlockNumber:   hexutil.Uint64(number),
+			GasLimit:      hexutil.Uint64(block.GasLimit * b.blocksPerPayload),
+			Timestamp:     hexutil.Uint64(block.Timestamp.Unix()),
+			ExtraData:     hexutil.Bytes{},
+			BaseFeePerGas: (*hexutil.Big)(new(big.Int)),
+		}
+	}
+
+	diff := &payload.Diff
+	diff.Transactions = make([]hexutil.Bytes, 0, len(block.Transactions))
+	diff.Withdrawals = []any{}
+	for i, tx := range block.Transactions {
+		// Transactions submitted without a raw encoding cannot be streamed
+		raw, err := hex.DecodeString(strings.TrimPrefix(tx.RawData, "0x"))
+		if err == nil && len(raw) > 0 {
+			diff.Transactions = append(diff.Transactions, raw)
+		}
+		if i >= len(block.Receipts) {
+			continue
+		}
+
+		receipt := b.addReceipt(tx, block.Receipts[i])
+		payload.Metadata.Receipts[common.HexToHash(tx.ID)] = map[string]*codec.FlashblockReceipt{
+			receiptTypes[receipt.Type]: toReceipt(receipt),
+		}
+	}
+	b.gasUsed += block.GasUsed
+
+	diff.StateRoot = common.HexToHash(block.StateRoot)
+	diff.BlockHash = common.HexToHash(block.ID)
+	diff.GasUsed = hexutil.Uint64(b.gasUsed)
+	diff.ReceiptsRoot = types.EmptyReceiptsHash
+	if len(b.receipts) > 0 {
+		diff.ReceiptsRoot = types.DeriveSha(b.receipts, trie.NewStackTrie(nil))
+	}
+	diff.LogsBloom = types.MergeBloom(b.receipts)
+	diff.WithdrawalsRoot = types.EmptyWithdrawalsHash
+
+	return payload
+}
+
+// addReceipt adds the receipt of a transaction to the payload
+func (b *builder) addReceipt(tx *model.Transaction, r *model.Receipt) *types.Receipt {
+	receipt := &types.Receipt{
+		Type:              types.LegacyTxType,
+		Status:            r.Status,
+		CumulativeGasUsed: b.gasUsed + r.CumulativeGasUsed,
+		Logs:              make([]*types.Log, len(r.Logs)),
+	}
+	if tx.RawData != "" {
+		if ethTx, err := eth.DecodeRawTransaction(tx.RawData); err == nil {
+			receipt.Type = ethTx.Type()
+		}
+	}
+	for i, l := range r.Logs {
+		topics := make([]common.Hash, len(l.Topics))
+		for j, topic := range l.Topics {
+			topics[j] = common.HexToHash(topic)
+		}
+		receipt.Logs[i] = &types.Log{
+			Address: common.HexToAddress(l.Address),
+			Topics:  topics,
+			Data:    l.Data,
+		}
+	}
+	receipt.Bloom = types.CreateBloom(receipt)
+
+	b.receipts = append(b.receipts, receipt)
+	return receipt
+}
+
+// balances returns the balances of the accounts touched by a block after it
+func (b *builder) balances(block *model.Block) map[common.Address]*hexutil.Big {
+	balances := make(map[common.Address]*hexutil.Big)
+	if b.state == nil || block.StateRoot == "" {
+		return balances
+	}
+	statedb, err := b.state.StateAt(common.HexToHash(block.StateRoot))
+	if err != nil {
+		return balances
+	}
+
+	add := func(address string) {
+		if address != "" {
+			addr := common.HexToAddress(address)
+			balances[addr] = (*hexutil.Big)(statedb.GetBalance(addr).ToBig())
+		}
+	}
+	add(block.FeeRecipient)
+	for _, tx := range block.Transactions {
+		add(tx.From)
+		add(tx.To)
+	}
+	for _, r := range block.Receipts {
+		add(r.ContractAddress)
+	}
+	return balances
+}
+
+// toReceipt converts a receipt to the metadata format
+func toReceipt(r *types.Receipt) *codec.FlashblockReceipt {
+	logs := make([]codec.FlashblockLog, len(r.Logs))
+	for i, l := range r.Logs {
+		logs[i] = codec.FlashblockLog{Address: l.Address, Topics: l.Topics, Data: l.Data}
+	}
+	return &codec.FlashblockReceipt{
+		Status:            hexutil.Uint64(r.Status),
+		CumulativeGasUsed: hexutil.Uint64(r.CumulativeGasUsed),
+		Logs:              logs,
+	}
+}
+
+// payloadID derives the 8 byte ID of a payload from its number
+func payloadID(number uint64) hexutil.Bytes {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], number)
+	return crypto.Keccak256(data[:])[:8]
+}
@@ -0,0 +1,153 @@
+package genesis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Defaults of the genesis
+const (
+	DefaultChainID  = 1337
+	DefaultGasLimit = 30_000_000 // Block gas limit
+)
+
+// Genesis describes the initial state of a FlashBlock chain
+type Genesis struct {
+	ChainID   uint64             `json:"chain_id"`
+	Timestamp uint64             `json:"timestamp"` // Unix time of the chain start
+	GasLimit  uint64             `json:"gas_limit"` // Gas available to the transactions of a block
+	Alloc     map[string]Account `json:"alloc"`     // Initial accounts by address
+}
+
+// Account is an account allocated at genesis
+type Account struct {
+	Balance string            `json:"balance"` // Balance in wei (decimal or 0x-prefixed hex)
+	Nonce   uint64            `json:"nonce,omitempty"`
+	Code    string            `json:"code,omitempty"`    // Contract code (0x-prefixed hex)
+	Storage map[string]string `json:"storage,omitempty"` // Contract storage slots (32 byte hex keys and values)
+}
+
+// ParseBalance returns the balance of the account in wei
+func (a Account) ParseBalance() (*big.Int, error) {
+	if a.Balance == "" {
+		return new(big.Int), nil
+	}
+	balance, ok := math.ParseBig256(a.Balance)
+	if !ok || balance.Sign() < 0 {
+		return nil, fmt.Errorf("invalid balance %q", a.Balance)
+	}
+	return balance, nil
+}
+
+// ParseCode returns the contract code of the account
+func (a Account) ParseCode() ([]byte, error) {
+	if a.Code == "" {
+		return nil, nil
+	}
+	code, err := hexutil.Decode(a.Code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid code: %v", err)
+	}
+	return code, nil
+}
+
+// ParseStorage returns the storage slots of the account
+func (a Account) ParseStorage() (map[common.Hash]common.Hash, error) {
+	storage := make(map[common.Hash]common.Hash, len(a.Storage))
+	for key, value := range a.Storage {
+		k, err := parseSlot(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage key %q: %v", key, err)
+		}
+		v, err := parseSlot(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage value %q: %v", value, err)
+		}
+		storage[k] = v
+	}
+	return storage, nil
+}
+
+// parseSlot decodes a hex storage key or value of at most 32 bytes
+func parseSlot(s string) (common.Hash, error) {
+	b, err := hexutil.Decode(s)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if len(b) > common.HashLength {
+		return common.Hash{}, fmt.Errorf("longer than %d bytes", common.HashLength)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// Default returns the genesis used when no genesis file is configured
+func Default() *Genesis {
+	return &Genesis{
+		ChainID:  DefaultChainID,
+		GasLimit: DefaultGasLimit,
+		Alloc:    make(map[string]Account),
+	}
+}
+
+// Load reads a genesis JSON file
+func Load(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis file: %v", err)
+	}
+
+	g := Default()
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, fmt.Errorf("failed to parse genesis file: %v", err)
+	}
+	if g.Alloc == nil {
+		g.Alloc = make(map[string]Account)
+	}
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid genesis file: %v", err)
+	}
+
+	return g, nil
+}
+
+// Validate checks the addresses and values of the allocated accounts
+func (g *Genesis) Validate() error {
+	if g.GasLimit == 0 {
+		return errors.New("gas_limit must be greater than 0")
+	}
+	for addr, account := range g.Alloc {
+		if !common.IsHexAddress(addr) {
+			return fmt.Errorf("invalid address %q in alloc", addr)
+		}
+		if _, err := account.ParseBalance(); err != nil {
+			return fmt.Errorf("account %s: %v", addr, err)
+		}
+		if _, err := account.ParseCode(); err != nil {
+			return fmt.Errorf("account %s: %v", addr, err)
+		}
+		if _, err := account.ParseStorage(); err != nil {
+			return fmt.Errorf("account %s: %v", addr, err)
+		}
+	}
+	return nil
+}
+
+// LoadOrDefault reads the genesis file, or returns the default genesis if path is empty
+func LoadOrDefault(path string) (*Genesis, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	return Load(path)
+}
+
+// Marshal returns the indented JSON encoding of the genesis
+func (g *Genesis) Marshal() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
@@ -0,0 +1,287 @@
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+)
+
+// Role is the role of a node in an active/standby group
+type Role int
+
+const (
+	RoleStandby Role = iota // Follows the leader and does not build blocks
+	RoleLeader              // Builds blocks
+)
+
+// String returns the role name
+func (r Role) String() string {
+	if r == RoleLeader {
+		return "leader"
+	}
+	return "standby"
+}
+
+// Lease is the leadership record stored in the lease file
+type Lease struct {
+	Holder  string    `json:"holder"`  // Node ID of the leader
+	URL     string    `json:"url"`     // WebSocket RPC endpoint of the leader
+	Expires time.Time `json:"expires"` // The lease may be taken over after this time
+}
+
+// Config holds the leader election settings
+type Config struct {
+	LeaseFile string        // Lease file on storage shared by all nodes
+	NodeID    string        // Unique ID of this node
+	URL       string        // WebSocket RPC endpoint advertised to standby nodes
+	TTL       time.Duration // Lease duration; a leader that stops renewing is replaced after it
+	Clock     model.Clock   // Times the leases (the wall clock if nil); tests set a deterministic one
+}
+
+// Elector elects a single leader among the nodes sharing a lease file.
+// The leader renews its lease several times per TTL; standby nodes take the
+// lease over once it expires.
+type Elector struct {
+	config   *Config
+	onChange func(role Role, leader *Lease)
+	ttl      atomic.Int64 // Lease duration in nanoseconds, changed by SetTTL
+	expires  atomic.Int64 // Unix nanoseconds when the lease held by this node expires (0 if none)
+
+	role    Role
+	leader  *Lease
+	renewed time.Time // When this node last renewed its lease
+}
+
+// NewElector creates an elector; onChange is called from Run whenever the role or leader changes
+func NewElector(config *Config, onChange func(role Role, leader *Lease)) *Elector {
+	if config.Clock == nil {
+		config.Clock = model.SystemClock{}
+	}
+	e := &Elector{
+		config:   config,
+		onChange: onChange,
+		role:     RoleStandby,
+	}
+	e.ttl.Store(int64(config.TTL))
+	return e
+}
+
+// SetTTL changes the lease duration from the next renewal on
+func (e *Elector) SetTTL(ttl time.Duration) {
+	if ttl > 0 {
+		e.ttl.Store(int64(ttl))
+	}
+}
+
+// TTL returns the lease duration
+func (e *Elector) TTL() time.Duration {
+	return time.Duration(e.ttl.Load())
+}
+
+// ErrLeaseExpired is returned by CheckLease when this node does not hold a valid lease
+var ErrLeaseExpired = errors.New("leader lease not held")
+
+// CheckLease returns an error unless this node holds a lease that has not
+// expired. Leaders check it before sealing a block, so a leader that failed
+// to renew in time never seals a block once a standby may have taken over.
+func (e *Elector) CheckLease() error {
+	expires := e.expires.Load()
+	if expires == 0 {
+		return ErrLeaseExpired
+	}
+	if now := e.config.Clock.Now(); !now.Before(time.Unix(0, expires)) {
+		return fmt.Errorf("%w: expired %v ago", ErrLeaseExpired, now.Sub(time.Unix(0, expires)))
+	}
+	return nil
+}
+
+// Run takes part in the election until the context is cancelled, then resigns
+// the lease if this node holds it so a standby can take over immediately
+func (e *Elector) Run(ctx context.Context) {
+	ttl := e.TTL()
+	ticker := time.NewTicker(ttl / 4)
+	defer ticker.Stop()
+
+	for {
+		e.round(e.config.Clock.Now())
+		if current := e.TTL(); current != ttl {
+			ttl = current
+			ticker.Reset(ttl / 4)
+		}
+
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// round runs one election round
+func (e *Elector) round(now time.Time) {
+	lease, err := e.tryAcquire(now)
+	if err != nil {
+		logging.Errorf("Leader election failed: %v", err)
+
+		// A leader that cannot renew steps down before its lease expires
+		if e.role == RoleLeader && now.Sub(e.renewed) > e.TTL()/2 {
+			e.expires.Store(0)
+			e.setRole(RoleStandby, nil)
+		}
+		return
+	}
+
+	if lease.Holder == e.config.NodeID {
+		e.renewed = now
+		e.expires.Store(lease.Expires.UnixNano())
+		e.setRole(RoleLeader, lease)
+	} else {
+		e.expires.Store(0)
+		e.setRole(RoleStandby, lease)
+	}
+}
+
+// setRole records the role and leader, calling onChange if either changed
+func (e *Elector) setRole(role Role, leader *Lease) {
+	changed := role != e.role || leaderKey(leader) != leaderKey(e.leader)
+	e.role = role
+	e.leader = leader
+
+	if changed {
+		if role == RoleLeader {
+			logging.Infof("Elected leader (node %s)", e.config.NodeID)
+		} else if leader != nil {
+			logging.Infof("Standing by for leader %s at %s", leader.Holder, leader.URL)
+		} else {
+			logging.Warnf("Standing by, no leader known")
+		}
+		e.onChange(role, leader)
+	}
+}
+
+// leaderKey returns the holder and URL of the lease as holder@url, or "" if
+// there is none
+func leaderKey(lease *Lease) string {
+	if lease == nil {
+		return ""
+	}
+	return lease.Holder + "@" + lease.URL
+}
+
+// tryAcquire reads the lease under the file lock and takes or renews it if
+// it is free, expired or already held by this node. It returns the current lease.
+func (e *Elector) tryAcquire(now time.Time) (*Lease, error) {
+	var lease *Lease
+	err := e.withLock(func() error {
+		current, err := readLease(e.config.LeaseFile)
+		if err != nil {
+			return err
+		}
+
+		if current != nil && current.Holder != e.config.NodeID && now.Before(current.Expires) {
+			lease = current
+			return nil
+		}
+
+		lease = &Lease{
+			Holder:  e.config.NodeID,
+			URL:     e.config.URL,
+			Expires: now.Add(e.TTL()),
+		}
+		return writeLease(e.config.LeaseFile, lease)
+	})
+
+	return lease, err
+}
+
+// resign gives up the lease if this node holds it
+func (e *Elector) resign() {
+	if e.role != RoleLeader {
+		return
+	}
+	e.expires.Store(0)
+
+	err := e.withLock(func() error {
+		current, err := readLease(e.config.LeaseFile)
+		if err != nil || current == nil || current.Holder != e.config.NodeID {
+			return err
+		}
+		return os.Remove(e.config.LeaseFile)
+	})
+	if err != nil {
+		logging.Errorf("Failed to resign leadership: %v", err)
+		return
+	}
+
+	e.role = RoleStandby
+	logging.Infof("Resigned leadership")
+}
+
+// withLock runs fn while holding an exclusive lock next to the lease file
+func (e *Elector) withLock(fn func() error) error {
+	f, err := os.OpenFile(e.config.LeaseFile+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open lease lock: %v", err)
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock lease: %v", err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	return fn()
+}
+
+// readLease reads the lease file, returning nil if there is no lease
+func readLease(path string) (*Lease, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease: %v", err)
+	}
+
+	var lease Lease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		// A damaged lease is treated as free
+		logging.Warnf("Ignoring invalid lease file: %v", err)
+		return nil, nil
+	}
+	return &lease, nil
+}
+
+// writeLease atomically replaces the lease file
+func writeLease(path string, lease *Lease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write lease: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write lease: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write lease: %v", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,197 @@
+package ha
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flashblock/internal/deterministic"
+)
+
+const testTTL = 4 * time.Second
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// change is a role change reported by an elector
+type change struct {
+	role   Role
+	leader string // leaderKey of the leader
+}
+
+// testElector is an elector whose role changes are recorded
+type testElector struct {
+	*Elector
+	changes []change
+}
+
+// newTestElector creates an elector for a node sharing the lease file in dir
+func newTestElector(dir, id string, clock *deterministic.Clock) *testElector {
+	e := &testElector{}
+	e.Elector = NewElector(&Config{
+		LeaseFile: filepath.Join(dir, "lease.json"),
+		NodeID:    id,
+		URL:       "ws://" + id + ":8546",
+		TTL:       testTTL,
+		Clock:     clock,
+	}, func(role Role, leader *Lease) {
+		e.changes = append(e.changes, change{role, leaderKey(leader)})
+	})
+	return e
+}
+
+// step runs an election round at the time of the clock
+func (e *testElector) step(clock *deterministic.Clock) {
+	e.round(clock.Now())
+}
+
+// last returns the last reported change
+func (e *testElector) last(t *testing.T) change {
+	t.Helper()
+	if len(e.changes) == 0 {
+		t.Fatalf("node %s reported no change", e.config.NodeID)
+	}
+	return e.changes[len(e.changes)-1]
+}
+
+func TestElection(t *testing.T) {
+	dir := t.TempDir()
+	clock := deterministic.NewClock(testStart)
+	a := newTestElector(dir, "a", clock)
+	b := newTestElector(dir, "b", clock)
+
+	a.step(clock)
+	b.step(clock)
+	if got, want := a.last(t), (change{RoleLeader, "a@ws://a:8546"}); got != want {
+		t.Errorf("a: %+v, want %+v", got, want)
+	}
+	if got, want := b.last(t), (change{RoleStandby, "a@ws://a:8546"}); got != want {
+		t.Errorf("b: %+v, want %+v", got, want)
+	}
+	if err := a.CheckLease(); err != nil {
+		t.Errorf("leader CheckLease: %v", err)
+	}
+	if err := b.CheckLease(); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("standby CheckLease = %v, want ErrLeaseExpired", err)
+	}
+
+	// A leader that renews in time keeps the lease
+	for i := 0; i < 8; i++ {
+		clock.Advance(testTTL / 4)
+		a.step(clock)
+		b.step(clock)
+	}
+	if len(a.changes) != 1 || len(b.changes) != 1 {
+		t.Errorf("changes while the leader renews: %+v, %+v", a.changes, b.changes)
+	}
+	if err := a.CheckLease(); err != nil {
+		t.Errorf("renewed CheckLease: %v", err)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	dir := t.TempDir()
+	clock := deterministic.NewClock(testStart)
+	a := newTestElector(dir, "a", clock)
+	b := newTestElector(dir, "b", clock)
+	a.step(clock)
+	b.step(clock)
+
+	// The lease is held until it expires
+	clock.Advance(testTTL - time.Millisecond)
+	b.step(clock)
+	if got := b.last(t); got.role != RoleStandby {
+		t.Fatalf("b took over an unexpired lease: %+v", got)
+	}
+
+	// A leader that stops renewing cannot seal once its lease expired, and a
+	// standby takes over
+	clock.Advance(time.Millisecond)
+	if err := a.CheckLease(); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("expired CheckLease = %v, want ErrLeaseExpired", err)
+	}
+	b.step(clock)
+	if got, want := b.last(t), (change{RoleLeader, "b@ws://b:8546"}); got != want {
+		t.Errorf("b: %+v, want %+v", got, want)
+	}
+	if err := b.CheckLease(); err != nil {
+		t.Errorf("new leader CheckLease: %v", err)
+	}
+
+	// The former leader stands by for the new one
+	a.step(clock)
+	if got, want := a.last(t), (change{RoleStandby, "b@ws://b:8546"}); got != want {
+		t.Errorf("a: %+v, want %+v", got, want)
+	}
+}
+
+func TestResign(t *testing.T) {
+	dir := t.TempDir()
+	clock := deterministic.NewClock(testStart)
+	a := newTestElector(dir, "a", clock)
+	b := newTestElector(dir, "b", clock)
+	a.step(clock)
+	b.step(clock)
+
+	// A resigned lease is taken over at once
+	a.resign()
+	if err := a.CheckLease(); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("CheckLease after resigning = %v, want ErrLeaseExpired", err)
+	}
+	b.step(clock)
+	if got := b.last(t); got.role != RoleLeader {
+		t.Errorf("b: %+v, want leader", got)
+	}
+
+	// A standby that resigns leaves the lease of the leader alone
+	a.resign()
+	if lease, err := readLease(b.config.LeaseFile); err != nil || lease == nil || lease.Holder != "b" {
+		t.Errorf("lease after a standby resigned: %+v, %v", lease, err)
+	}
+}
+
+func TestStepDownWhenRenewalFails(t *testing.T) {
+	dir := t.TempDir()
+	clock := deterministic.NewClock(testStart)
+	a := newTestElector(dir, "a", clock)
+	a.step(clock)
+
+	// The lease file cannot be read
+	if err := os.Remove(a.config.LeaseFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(a.config.LeaseFile, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The leader keeps its role while its lease is fresh, then steps down
+	// before it expires
+	clock.Advance(testTTL / 4)
+	a.step(clock)
+	if got := a.last(t); got.role != RoleLeader {
+		t.Fatalf("leader stepped down early: %+v", got)
+	}
+	clock.Advance(testTTL/4 + time.Millisecond)
+	a.step(clock)
+	if got, want := a.last(t), (change{RoleStandby, ""}); got != want {
+		t.Errorf("a: %+v, want %+v", got, want)
+	}
+	if err := a.CheckLease(); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("CheckLease after stepping down = %v, want ErrLeaseExpired", err)
+	}
+}
+
+func TestDamagedLease(t *testing.T) {
+	dir := t.TempDir()
+	clock := deterministic.NewClock(testStart)
+	a := newTestElector(dir, "a", clock)
+	if err := os.WriteFile(a.config.LeaseFile, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a.step(clock)
+	if got := a.last(t); got.role != RoleLeader {
+		t.Errorf("a: %+v, want leader of a damaged lease", got)
+	}
+}
@@ -0,0 +1,370 @@
+package ha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/mempool"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+	"flashblock/internal/state"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Standby keeps a standby node in sync with the leader: blocks are imported
+// from the leader's block feed and the mempool mirrors the leader's mempool
+type Standby struct {
+	processor *processor.BlockProcessor
+	mempool   *mempool.Mempool
+	authToken string        // Bearer token for the leader's RPC (optional)
+	interval  time.Duration // Reconnect interval
+	syncEvery time.Duration // Mempool sync interval
+	mirror    bool          // Replace the local mempool with the leader's mempool
+	peer      string        // Role of the followed node in log messages
+}
+
+// blocksResult is the result of flash_getBlocks
+type blocksResult struct {
+	Blocks []*model.Block `json:"blocks"`
+}
+
+// snapshotResult is the result of flash_getSnapshot
+type snapshotResult struct {
+	Blocks []*model.Block `json:"blocks"`
+}
+
+// maxPullTransactions is the number of missing transactions pulled from the leader per call
+const maxPullTransactions = 1024
+
+// mempoolResult is the result of flash_getMempool and flash_getMempoolTransactions
+type mempoolResult struct {
+	Transactions []*model.Transaction `json:"transactions"`
+}
+
+// NewStandby creates a standby synchronizer
+func NewStandby(bp *processor.BlockProcessor, mp *mempool.Mempool, authToken string, interval time.Duration) *Standby {
+	return &Standby{
+		processor: bp,
+		mempool:   mp,
+		authToken: authToken,
+		interval:  interval,
+		syncEvery: interval,
+		mirror:    true,
+		peer:      "leader",
+	}
+}
+
+// NewFollower creates a synchronizer that imports the blocks of a remote builder
+// without mirroring its mempool, as used by RPC front-end nodes. Imported blocks
+// remove their transactions from the local mempool.
+func NewFollower(bp *processor.BlockProcessor, mp *mempool.Mempool, authToken string, interval time.Duration) *Standby {
+	return &Standby{
+		processor: bp,
+		mempool:   mp,
+		authToken: authToken,
+		interval:  interval,
+		syncEvery: interval,
+		peer:      "builder",
+	}
+}
+
+// SetMempoolSyncInterval sets the time between mempool syncs, which bounds
+// how far the mempool falls behind the leader's (the reconnect interval if zero)
+func (s *Standby) SetMempoolSyncInterval(interval time.Duration) {
+	if interval > 0 {
+		s.syncEvery = interval
+	}
+}
+
+// ErrDiverged is returned when the local chain forked from the followed chain,
+// e.g. after a former leader sealed blocks the new leader never received
+var ErrDiverged = errors.New("local chain diverged")
+
+// Run follows the leader at url until the context is cancelled, reconnecting
+// after errors. Reconnecting cannot repair a diverged chain, so Run returns an
+// error wrapping ErrDiverged instead.
+func (s *Standby) Run(ctx context.Context, url string) error {
+	for {
+		err := s.follow(ctx, url)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, ErrDiverged) {
+			return err
+		}
+		logging.Warnf("Sync with %s %s interrupted: %v", s.peer, url, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// follow syncs with the leader over a single connection until an error occurs
+func (s *Standby) follow(ctx context.Context, url string) error {
+	var options []rpc.ClientOption
+	if s.authToken != "" {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+s.authToken)
+		options = append(options, rpc.WithHeaders(header))
+	}
+
+	client, err := rpc.DialOptions(ctx, url, options...)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Subscribe first so no block is missed between catching up and following
+	blocks := make(chan *model.Block, 64)
+	sub, err := client.Subscribe(ctx, "flash", blocks, "newBlocks")
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to blocks: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// An empty chain starts at a snapshot instead of replaying the history
+	if latestID, _ := s.processor.LatestBlock(); latestID == "" {
+		if err := s.bootstrap(ctx, client); err != nil {
+			return err
+		}
+	}
+	if err := s.catchUp(ctx, client); err != nil {
+		return err
+	}
+	logging.Infof("In sync with %s %s", s.peer, url)
+
+	ticker := time.NewTicker(s.syncEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return fmt.Errorf("block subscription ended: %v", err)
+		case block := <-blocks:
+			if err := s.importBlock(block); err != nil {
+				if !errors.Is(err, processor.ErrUnknownParent) {
+					return err
+				}
+				// A block was missed; fetch the recent blocks again
+				if err := s.catchUp(ctx, client); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := s.syncMempool(ctx, client); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// bootstrap anchors the empty local chain at a snapshot of the leader's
+// chain and account state, fetched page by page. Leaders that do not serve
+// snapshots, e.g. to nodes without an auth token, are caught up from their
+// retained blocks.
+func (s *Standby) bootstrap(ctx context.Context, client *rpc.Client) error {
+	var result snapshotResult
+	if err := client.CallContext(ctx, &result, "flash_getSnapshot"); err != nil {
+		logging.Warnf("No snapshot from the %s, catching up from its retained blocks: %v", s.peer, err)
+		return nil
+	}
+	if len(result.Blocks) == 0 {
+		return nil
+	}
+
+	// Blocks created without state tracking have no state to fetch
+	var snapshot *state.Snapshot
+	if root := result.Blocks[len(result.Blocks)-1].StateRoot; root != "" {
+		var err error
+		if snapshot, err = s.fetchState(ctx, client, root); err != nil {
+			logging.Warnf("No state snapshot from the %s, catching up from its retained blocks: %v", s.peer, err)
+			return nil
+		}
+	}
+	if err := s.processor.Bootstrap(result.Blocks, snapshot); err != nil {
+		return fmt.Errorf("failed to bootstrap from the %s snapshot: %v", s.peer, err)
+	}
+	latest := result.Blocks[len(result.Blocks)-1]
+	logging.Infof("Bootstrapped from a snapshot of the %s at block %d (%s)", s.peer, latest.Number, latest.ID)
+	return nil
+}
+
+// fetchState fetches the pages of the account state at root
+func (s *Standby) fetchState(ctx context.Context, client *rpc.Client, root string) (*state.Snapshot, error) {
+	args := map[string]any{"root": root}
+	var snapshot *state.Snapshot
+	for {
+		var page state.Snapshot
+		if err := client.CallContext(ctx, &page, "flash_getStateSnapshot", args); err != nil {
+			return nil, err
+		}
+		if snapshot == nil {
+			snapshot = &page
+		} else if err := snapshot.Merge(&page); err != nil {
+			return nil, err
+		}
+		if page.Next == nil {
+			return snapshot, nil
+		}
+		args["start"] = page.Next.Hex()
+	}
+}
+
+// catchUp imports the leader's recent blocks that are newer than the local head
+func (s *Standby) catchUp(ctx context.Context, client *rpc.Client) error {
+	var result blocksResult
+	if err := client.CallContext(ctx, &result, "flash_getBlocks"); err != nil {
+		return fmt.Errorf("failed to fetch blocks: %v", err)
+	}
+
+	for _, block := range result.Blocks {
+		err := s.importBlock(block)
+		if errors.Is(err, processor.ErrUnknownParent) {
+			latestID, _ := s.processor.LatestBlock()
+			if !s.retains(result.Blocks, latestID) {
+				return fmt.Errorf("too far behind the %s to catch up: %v", s.peer, err)
+			}
+			return fmt.Errorf("%w from the %s: %v", ErrDiverged, s.peer, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.syncMempool(ctx, client)
+}
+
+// retains reports whether the block with the given ID is among blocks
+func (s *Standby) retains(blocks []*model.Block, id string) bool {
+	for _, block := range blocks {
+		if block.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// importBlock imports a leader block unless it is already part of the local chain
+func (s *Standby) importBlock(block *model.Block) error {
+	if _, latestNumber := s.processor.LatestBlock(); block.Number <= latestNumber && latestNumber > 0 {
+		return nil
+	}
+
+	if err := s.processor.ImportBlock(block); err != nil {
+		return err
+	}
+	logging.Debugf("Imported block %d (%s) from the %s", block.Number, block.ID, s.peer)
+	return nil
+}
+
+// syncMempool keeps the mempool within one sync interval of the leader's.
+// The fingerprints of both mempools are compared and only the IDs of the
+// buckets that differ are exchanged; transactions missing locally are pulled
+// and those the leader no longer has are removed. Leaders without
+// fingerprints are mirrored in full.
+func (s *Standby) syncMempool(ctx context.Context, client *rpc.Client) error {
+	if !s.mirror {
+		return nil
+	}
+
+	var remote mempool.Fingerprint
+	if err := client.CallContext(ctx, &remote, "flash_getMempoolFingerprint"); err != nil {
+		if methodNotFound(err) {
+			return s.mirrorMempool(ctx, client)
+		}
+		return fmt.Errorf("failed to fetch mempool fingerprint: %v", err)
+	}
+	buckets := s.mempool.Fingerprint().Diff(&remote)
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	var remoteIDs []string
+	if err := client.CallContext(ctx, &remoteIDs, "flash_getMempoolIDs", buckets); err != nil {
+		return fmt.Errorf("failed to fetch mempool IDs: %v", err)
+	}
+	local := make(map[string]bool)
+	for _, id := range s.mempool.BucketIDs(buckets) {
+		local[id] = true
+	}
+
+	// The fingerprint may predate blocks that were imported meanwhile
+	included := s.includedIDs()
+	var missing []string
+	for _, id := range remoteIDs {
+		if local[id] {
+			delete(local, id)
+		} else if !included[id] {
+			missing = append(missing, id)
+		}
+	}
+	stale := make([]string, 0, len(local))
+	for id := range local {
+		stale = append(stale, id)
+	}
+
+	var pulled []*model.Transaction
+	for start := 0; start < len(missing); start += maxPullTransactions {
+		end := min(start+maxPullTransactions, len(missing))
+		var result mempoolResult
+		if err := client.CallContext(ctx, &result, "flash_getMempoolTransactions", missing[start:end]); err != nil {
+			return fmt.Errorf("failed to fetch transactions: %v", err)
+		}
+		pulled = append(pulled, result.Transactions...)
+	}
+
+	added, removed := s.mempool.Reconcile(pulled, stale)
+	logging.Debugf("Mempool synced with the leader: %d of %d buckets differed, %d added, %d removed", len(buckets), mempool.FingerprintBuckets, added, removed)
+	return nil
+}
+
+// mirrorMempool replaces the mempool with the leader's pending transactions
+func (s *Standby) mirrorMempool(ctx context.Context, client *rpc.Client) error {
+	var result mempoolResult
+	if err := client.CallContext(ctx, &result, "flash_getMempool"); err != nil {
+		return fmt.Errorf("failed to fetch mempool: %v", err)
+	}
+
+	// The snapshot may predate blocks that were imported meanwhile
+	included := s.includedIDs()
+	pending := result.Transactions[:0]
+	for _, tx := range result.Transactions {
+		if !included[tx.ID] {
+			pending = append(pending, tx)
+		}
+	}
+
+	added, removed := s.mempool.Sync(pending)
+	if added > 0 || removed > 0 {
+		logging.Debugf("Mempool synced with the leader: %d added, %d removed", added, removed)
+	}
+	return nil
+}
+
+// includedIDs returns the IDs of the transactions in the retained blocks
+func (s *Standby) includedIDs() map[string]bool {
+	included := make(map[string]bool)
+	for _, block := range s.processor.GetProcessedBlocks() {
+		for _, tx := range block.Transactions {
+			included[tx.ID] = true
+		}
+	}
+	return included
+}
+
+// methodNotFound reports whether a call failed because the remote node does
+// not implement the method
+func methodNotFound(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601
+}
@@ -0,0 +1,323 @@
+// Package indexer writes the blocks, transactions and receipts of the node
+// into a SQL database, SQLite or Postgres, and answers filtered searches over
+// them that the key-value block store cannot, e.g. all transactions of a
+// sender in a time range. The database keeps the blocks pruned by retention.
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+
+	_ "github.com/lib/pq"           // Registers the postgres driver
+	_ "github.com/mattn/go-sqlite3" // Registers the sqlite3 driver
+)
+
+// Database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+const (
+	queueSize   = 1024 // Blocks waiting to be written
+	writeBlocks = 256  // Blocks written in one database transaction at most
+	retryDelay  = 5 * time.Second
+)
+
+// schema creates the tables and indexes. The statements are valid for both
+// SQLite and Postgres; times are Unix milliseconds and wei amounts decimal
+// strings, since they may exceed 64 bits.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS blocks (
+		number BIGINT PRIMARY KEY,
+		id TEXT NOT NULL,
+		prev_id TEXT NOT NULL,
+		timestamp BIGINT NOT NULL,
+		transactions INTEGER NOT NULL,
+		gas_limit BIGINT NOT NULL,
+		gas_used BIGINT NOT NULL,
+		fee_recipient TEXT NOT NULL,
+		state_root TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS transactions (
+		id TEXT PRIMARY KEY,
+		block_number BIGINT NOT NULL,
+		position INTEGER NOT NULL,
+		block_time BIGINT NOT NULL,
+		received BIGINT NOT NULL,
+		sender TEXT NOT NULL,
+		recipient TEXT NOT NULL,
+		value TEXT NOT NULL,
+		gas_price TEXT NOT NULL,
+		gas_limit BIGINT NOT NULL,
+		nonce BIGINT NOT NULL,
+		priority BIGINT NOT NULL,
+		data_size INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS receipts (
+		tx_id TEXT PRIMARY KEY,
+		block_number BIGINT NOT NULL,
+		status INTEGER NOT NULL,
+		gas_used BIGINT NOT NULL,
+		cumulative_gas_used BIGINT NOT NULL,
+		contract_address TEXT NOT NULL,
+		logs INTEGER NOT NULL,
+		error TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS transactions_block ON transactions (block_number, position)`,
+	`CREATE INDEX IF NOT EXISTS transactions_sender ON transactions (sender, block_time)`,
+	`CREATE INDEX IF NOT EXISTS transactions_recipient ON transactions (recipient, block_time)`,
+	`CREATE INDEX IF NOT EXISTS transactions_time ON transactions (block_time)`,
+	`CREATE INDEX IF NOT EXISTS transactions_priority ON transactions (priority)`,
+	`CREATE INDEX IF NOT EXISTS receipts_block ON receipts (block_number)`,
+}
+
+// Config holds configuration for the indexer
+type Config struct {
+	Driver     string // sqlite or postgres
+	DSN        string // Database file of SQLite, connection string of Postgres
+	MaxResults int    // Transactions returned by a query at most
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Driver:     DriverSQLite,
+		DSN:        ":memory:",
+		MaxResults: 1000,
+	}
+}
+
+// Indexer writes the blocks of the processor into the database
+type Indexer struct {
+	processor *processor.BlockProcessor
+	config    *Config
+	db        *sql.DB
+
+	mu        sync.RWMutex
+	lastBlock uint64 // Highest indexed block
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New opens the database and creates the schema if it does not exist yet
+func New(bp *processor.BlockProcessor, config *Config) (*Indexer, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.MaxResults <= 0 {
+		config.MaxResults = DefaultConfig().MaxResults
+	}
+
+	var db *sql.DB
+	var err error
+	switch config.Driver {
+	case DriverSQLite:
+		db, err = sql.Open("sqlite3", sqliteDSN(config.DSN))
+	case DriverPostgres:
+		db, err = sql.Open("postgres", config.DSN)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", config.Driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the index database: %v", err)
+	}
+	if config.Driver == DriverSQLite {
+		// SQLite allows a single writer, and every connection to an in-memory
+		// database opens a database of its own
+		db.SetMaxOpenConns(1)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create the index schema: %v", err)
+		}
+	}
+
+	ix := &Indexer{
+		processor: bp,
+		config:    config,
+		db:        db,
+		quit:      make(chan struct{}),
+	}
+	var last sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(number) FROM blocks`).Scan(&last); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read the index database: %v", err)
+	}
+	ix.lastBlock = uint64(last.Int64)
+	return ix, nil
+}
+
+// sqliteDSN enables the write-ahead log, so queries do not wait for writes,
+// and a busy timeout for other processes holding the database file
+func sqliteDSN(dsn string) string {
+	if dsn == ":memory:" || strings.Contains(dsn, "?") {
+		return dsn
+	}
+	return "file:" + dsn + "?_journal_mode=WAL&_busy_timeout=5000"
+}
+
+// Start indexes the new blocks until the indexer is closed. Recent blocks
+// created while the indexer was not running are indexed first.
+func (ix *Indexer) Start() {
+	blocks := make(chan events.BlockSealed, queueSize)
+	sub := ix.processor.Events().BlockSealed.Subscribe(blocks)
+
+	var pending []*model.Block
+	last := ix.LastBlock()
+	for _, block := range ix.processor.GetProcessedBlocks() {
+		if block.Number > last {
+			pending = append(pending, block)
+		}
+	}
+
+	ix.wg.Add(1)
+	go func() {
+		defer ix.wg.Done()
+		defer sub.Unsubscribe()
+
+		// Blocks that failed to be written are kept and written again, so the
+		// index has no gaps once the database is reachable again
+		var retry <-chan time.Time
+		for {
+			if len(pending) > 0 && retry == nil {
+				n := min(len(pending), writeBlocks)
+				if err := ix.write(pending[:n]); err != nil {
+					logging.Errorf("Failed to index blocks %d-%d, retrying in %v: %v", pending[0].Number, pending[n-1].Number, retryDelay, err)
+					retry = time.After(retryDelay)
+				} else {
+					pending = pending[n:]
+					continue
+				}
+			}
+
+			select {
+			case ev := <-blocks:
+				// Blocks queued while the indexer was writing are written together
+				pending = append(pending, ev.Block)
+				for n := len(blocks); n > 0; n-- {
+					pending = append(pending, (<-blocks).Block)
+				}
+			case <-retry:
+				retry = nil
+			case <-sub.Err():
+				return
+			case <-ix.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops indexing and closes the database
+func (ix *Indexer) Close() {
+	close(ix.quit)
+	ix.wg.Wait()
+	ix.db.Close()
+}
+
+// LastBlock returns the number of the highest indexed block
+func (ix *Indexer) LastBlock() uint64 {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.lastBlock
+}
+
+// write indexes blocks in one database transaction. A block replacing an
+// indexed block of the same number, e.g. after a failover, removes it and the
+// blocks after it; blocks that are already indexed are skipped.
+func (ix *Indexer) write(blocks []*model.Block) error {
+	ctx := context.Background()
+	tx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	last := ix.LastBlock()
+	for _, block := range blocks {
+		var id string
+		err := tx.QueryRowContext(ctx, `SELECT id FROM blocks WHERE number = $1`, int64(block.Number)).Scan(&id)
+		switch {
+		case err == nil && id == block.ID:
+			continue
+		case err == nil:
+			for _, table := range []string{"receipts", "transactions"} {
+				if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE block_number >= $1`, int64(block.Number)); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM blocks WHERE number >= $1`, int64(block.Number)); err != nil {
+				return err
+			}
+			last = min(last, block.Number-1)
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+		if err := insertBlock(ctx, tx, block); err != nil {
+			return fmt.Errorf("block %d: %v", block.Number, err)
+		}
+		last = max(last, block.Number)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	ix.mu.Lock()
+	ix.lastBlock = last
+	ix.mu.Unlock()
+	logging.Debugf("Indexed blocks %d-%d", blocks[0].Number, blocks[len(blocks)-1].Number)
+	return nil
+}
+
+// insertBlock inserts the rows of a block, its transactions and their receipts
+func insertBlock(ctx context.Context, tx *sql.Tx, block *model.Block) error {
+	blockTime := block.Timestamp.UnixMilli()
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO blocks (number, id, prev_id, timestamp, transactions, gas_limit, gas_used, fee_recipient, state_root)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		int64(block.Number), block.ID, block.PrevBlockID, blockTime, len(block.Transactions),
+		int64(block.GasLimit), int64(block.GasUsed), strings.ToLower(block.FeeRecipient), block.StateRoot)
+	if err != nil {
+		return err
+	}
+
+	for i, t := range block.Transactions {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO transactions (id, block_number, position, block_time, received, sender, recipient, value, gas_price, gas_limit, nonce, priority, data_size)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			t.ID, int64(block.Number), i, blockTime, t.Timestamp.UnixMilli(),
+			strings.ToLower(t.From), strings.ToLower(t.To), decimal(t.Value), decimal(t.GasPrice),
+			int64(t.GasLimit), int64(t.Nonce), t.Priority, len(t.Data))
+		if err != nil {
+			return err
+		}
+
+		// Blocks created before the transactions were executed have no receipts
+		if i >= len(block.Receipts) || block.Receipts[i] == nil {
+			continue
+		}
+		r := block.Receipts[i]
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO receipts (tx_id, block_number, status, gas_used, cumulative_gas_used, contract_address, logs, error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			t.ID, int64(block.Number), int64(r.Status), int64(r.GasUsed), int64(r.CumulativeGasUsed),
+			strings.ToLower(r.ContractAddress), len(r.Logs), r.Error)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package indexer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Filter selects indexed transactions. Empty fields do not filter; ranges
+// are inclusive.
+type Filter struct {
+	Sender      string
+	Recipient   string
+	FromTime    time.Time // Earliest block time
+	ToTime      time.Time // Latest block time
+	MinPriority *int
+	MaxPriority *int
+	FromBlock   uint64
+	ToBlock     uint64
+	Status      *uint64 // Receipt status: 1 succeeded, 0 reverted
+	Limit       int     // Transactions at most (capped by the configuration, which is also the default)
+	Offset      int     // Matching transactions skipped
+	Descending  bool    // Newest first instead of oldest first
+}
+
+// Transaction is an indexed transaction with its receipt
+type Transaction struct {
+	ID          string    `json:"id"`
+	BlockNumber uint64    `json:"block_number"`
+	BlockID     string    `json:"block_id"`
+	Position    int       `json:"position"` // Index of the transaction in the block
+	BlockTime   time.Time `json:"block_time"`
+	Received    time.Time `json:"received"` // When the transaction was admitted
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Value       string    `json:"value"`     // Wei, decimal
+	GasPrice    string    `json:"gas_price"` // Wei, decimal
+	GasLimit    uint64    `json:"gas_limit"`
+	Nonce       uint64    `json:"nonce"`
+	Priority    int       `json:"priority"`
+	DataSize    int       `json:"data_size"`
+	Status      *uint64   `json:"status"` // Null for blocks created before execution
+	GasUsed     uint64    `json:"gas_used,omitempty"`
+	Logs        int       `json:"logs,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Query returns the transactions matching the filter, ordered by block and
+// position in the block
+func (ix *Indexer) Query(f Filter) ([]*Transaction, error) {
+	if f.Limit < 0 || f.Offset < 0 {
+		return nil, errors.New("limit and offset cannot be negative")
+	}
+	limit := ix.config.MaxResults
+	if f.Limit > 0 && f.Limit < limit {
+		limit = f.Limit
+	}
+
+	var where []string
+	var args []any
+	cond := func(expr string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(expr, len(args)))
+	}
+	if f.Sender != "" {
+		cond("t.sender = $%d", strings.ToLower(f.Sender))
+	}
+	if f.Recipient != "" {
+		cond("t.recipient = $%d", strings.ToLower(f.Recipient))
+	}
+	if !f.FromTime.IsZero() {
+		cond("t.block_time >= $%d", f.FromTime.UnixMilli())
+	}
+	if !f.ToTime.IsZero() {
+		cond("t.block_time <= $%d", f.ToTime.UnixMilli())
+	}
+	if f.MinPriority != nil {
+		cond("t.priority >= $%d", *f.MinPriority)
+	}
+	if f.MaxPriority != nil {
+		cond("t.priority <= $%d", *f.MaxPriority)
+	}
+	if f.FromBlock > 0 {
+		cond("t.block_number >= $%d", int64(f.FromBlock))
+	}
+	if f.ToBlock > 0 {
+		cond("t.block_number <= $%d", int64(f.ToBlock))
+	}
+	if f.Status != nil {
+		cond("r.status = $%d", int64(*f.Status))
+	}
+
+	query := `SELECT t.id, t.block_number, b.id, t.position, t.block_time, t.received, t.sender, t.recipient,
+		t.value, t.gas_price, t.gas_limit, t.nonce, t.priority, t.data_size, r.status, r.gas_used, r.logs, r.error
+		FROM transactions t
+		JOIN blocks b ON b.number = t.block_number
+		LEFT JOIN receipts r ON r.tx_id = t.id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if f.Descending {
+		query += " ORDER BY t.block_number DESC, t.position DESC"
+	} else {
+		query += " ORDER BY t.block_number, t.position"
+	}
+	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, f.Offset)
+
+	rows, err := ix.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var result []*Transaction
+	for rows.Next() {
+		var t Transaction
+		var blockNumber, blockTime, received, gasLimit, nonce int64
+		var status, gasUsed, logs sql.NullInt64
+		var txError sql.NullString
+		err := rows.Scan(&t.ID, &blockNumber, &t.BlockID, &t.Position, &blockTime, &received, &t.From, &t.To,
+			&t.Value, &t.GasPrice, &gasLimit, &nonce, &t.Priority, &t.DataSize, &status, &gasUsed, &logs, &txError)
+		if err != nil {
+			return nil, fmt.Errorf("query failed: %v", err)
+		}
+		t.BlockNumber = uint64(blockNumber)
+		t.BlockTime = time.UnixMilli(blockTime)
+		t.Received = time.UnixMilli(received)
+		t.GasLimit = uint64(gasLimit)
+		t.Nonce = uint64(nonce)
+		if status.Valid {
+			s := uint64(status.Int64)
+			t.Status = &s
+			t.GasUsed = uint64(gasUsed.Int64)
+			t.Logs = int(logs.Int64)
+			t.Error = txError.String
+		}
+		result = append(result, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	return result, nil
+}
+
+// decimal formats a wei amount, which is nil for transactions without one
+func decimal(v *big.Int) string {
+	if v == nil {
+		return "0"
+	}
+	return v.String()
+}
@@ -0,0 +1,123 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/mempool"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Feed admits the transactions of a remote mempool into the local mempool, so a
+// builder-only node builds blocks from transactions received by RPC front-end nodes
+type Feed struct {
+	mempool   *mempool.Mempool
+	processor *processor.BlockProcessor
+	authToken string        // Bearer token for the remote RPC (optional)
+	interval  time.Duration // Reconnect interval
+}
+
+// mempoolResult is the result of flash_getMempool
+type mempoolResult struct {
+	Transactions []*model.Transaction `json:"transactions"`
+}
+
+// NewFeed creates a mempool feed consumer
+func NewFeed(mp *mempool.Mempool, bp *processor.BlockProcessor, authToken string, interval time.Duration) *Feed {
+	return &Feed{
+		mempool:   mp,
+		processor: bp,
+		authToken: authToken,
+		interval:  interval,
+	}
+}
+
+// Run consumes the mempool feed at url until the context is cancelled, reconnecting after errors
+func (f *Feed) Run(ctx context.Context, url string) {
+	for {
+		err := f.consume(ctx, url)
+		if ctx.Err() != nil {
+			return
+		}
+		logging.Warnf("Mempool feed %s interrupted: %v", url, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(f.interval):
+		}
+	}
+}
+
+// consume admits the remote transactions over a single connection until an error occurs
+func (f *Feed) consume(ctx context.Context, url string) error {
+	var options []rpc.ClientOption
+	if f.authToken != "" {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+f.authToken)
+		options = append(options, rpc.WithHeaders(header))
+	}
+
+	client, err := rpc.DialOptions(ctx, url, options...)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Subscribe first so no transaction is missed between the snapshot and the feed
+	transactions := make(chan *model.Transaction, 256)
+	sub, err := client.Subscribe(ctx, "flash", transactions, "newTransactions")
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to transactions: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Transactions received while disconnected are still pending on the remote node
+	var snapshot mempoolResult
+	if err := client.CallContext(ctx, &snapshot, "flash_getMempool"); err != nil {
+		return fmt.Errorf("failed to fetch mempool: %v", err)
+	}
+
+	// The remote node may not have seen the latest blocks yet
+	included := make(map[string]bool)
+	for _, block := range f.processor.GetProcessedBlocks() {
+		for _, tx := range block.Transactions {
+			included[tx.ID] = true
+		}
+	}
+	admitted := 0
+	for _, tx := range snapshot.Transactions {
+		if !included[tx.ID] && f.admit(tx) {
+			admitted++
+		}
+	}
+	logging.Infof("Consuming mempool feed %s (%d pending transactions admitted)", url, admitted)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return fmt.Errorf("transaction subscription ended: %v", err)
+		case tx := <-transactions:
+			f.admit(tx)
+		}
+	}
+}
+
+// admit adds a remote transaction to the local mempool, keeping its ID so the
+// remote node can match it in the blocks, and reports whether it was added
+func (f *Feed) admit(tx *model.Transaction) bool {
+	err := f.mempool.Admit(tx)
+	if err != nil && !errors.Is(err, mempool.ErrDuplicate) {
+		logging.Debugf("Transaction %s from the mempool feed rejected: %v", tx.ID, err)
+	}
+	return err == nil
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File is a log file that can be reopened after it was moved by an external
+// log rotation tool, so new messages go to a file at the original path
+type File struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenFile opens the log file at path for appending, creating it if needed
+func OpenFile(path string) (*File, error) {
+	f, err := openAppend(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, file: f}, nil
+}
+
+// openAppend opens a file for appending
+func openAppend(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %v", err)
+	}
+	return f, nil
+}
+
+// Path returns the path of the log file
+func (f *File) Path() string {
+	return f.path
+}
+
+// Write appends p to the current log file
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Write(p)
+}
+
+// Reopen closes the current log file and opens the file at the original path.
+// On error the current file is kept so no messages are lost.
+func (f *File) Reopen() error {
+	next, err := openAppend(f.path)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	prev := f.file
+	f.file = next
+	f.mu.Unlock()
+
+	return prev.Close()
+}
+
+// Close closes the log file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
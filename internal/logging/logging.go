@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is the minimum severity of messages that are logged
+type Level int32
+
+// Log levels in increasing order of severity
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// level holds the current log level
+var level atomic.Int32
+
+func init() {
+	level.Store(int32(LevelInfo))
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) to a Level
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// String returns the name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+}
+
+// SetLevel changes the current log level
+func SetLevel(l Level) {
+	level.Store(int32(l))
+}
+
+// GetLevel returns the current log level
+func GetLevel() Level {
+	return Level(level.Load())
+}
+
+// Enabled reports whether messages of the given level are logged
+func Enabled(l Level) bool {
+	return l >= GetLevel()
+}
+
+// Debugf logs a verbose diagnostic message
+func Debugf(format string, args ...any) {
+	if Enabled(LevelDebug) {
+		log.Printf("DEBUG: "+format, args...)
+	}
+}
+
+// Infof logs an informational message
+func Infof(format string, args ...any) {
+	if Enabled(LevelInfo) {
+		log.Printf(format, args...)
+	}
+}
+
+// Warnf logs a warning
+func Warnf(format string, args ...any) {
+	if Enabled(LevelWarn) {
+		log.Printf("WARN: "+format, args...)
+	}
+}
+
+// Errorf logs an error
+func Errorf(format string, args ...any) {
+	if Enabled(LevelError) {
+		log.Printf("ERROR: "+format, args...)
+	}
+}
@@ -0,0 +1,118 @@
+package mempool
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+	"flashblock/internal/wal"
+)
+
+// journalRecord is a single mempool change; exactly one of the fields is set
+type journalRecord struct {
+	Add    *model.Transaction `json:"add,omitempty"`
+	Remove []string           `json:"remove,omitempty"`
+}
+
+// journal persists mempool changes so pending transactions survive a restart.
+// It is a write-ahead log: admissions wait for their record to be committed.
+type journal struct {
+	log *wal.Log
+}
+
+// replayJournal applies the journal records at path in order and returns the resulting
+// transactions. Invalid records, such as a record cut short by a crash, are skipped.
+func replayJournal(path string) (map[string]*model.Transaction, error) {
+	transactions := make(map[string]*model.Transaction)
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return transactions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mempool journal: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			logging.Warnf("Skipping invalid mempool journal record %d: %v", line, err)
+			continue
+		}
+
+		if record.Add != nil {
+			transactions[record.Add.ID] = record.Add
+		}
+		for _, id := range record.Remove {
+			delete(transactions, id)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mempool journal: %v", err)
+	}
+
+	return transactions, nil
+}
+
+// rotateJournal replaces the journal at path with one recording only the given
+// transactions and opens it for appending
+func rotateJournal(path string, transactions map[string]*model.Transaction, config wal.Config) (*journal, error) {
+	tmp := path + ".new"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mempool journal: %v", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, tx := range transactions {
+		if err := encoder.Encode(&journalRecord{Add: tx}); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write mempool journal: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write mempool journal: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to sync mempool journal: %v", err)
+	}
+	file.Close()
+
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("failed to replace mempool journal: %v", err)
+	}
+
+	log, err := wal.Open(path, config)
+	if err != nil {
+		return nil, err
+	}
+	return &journal{log: log}, nil
+}
+
+// write appends a record to the next commit of the journal and returns a
+// function waiting for the commit
+func (j *journal) write(record *journalRecord) func() error {
+	data, err := json.Marshal(record)
+	if err == nil {
+		var seq uint64
+		if seq, err = j.log.Append(data); err == nil {
+			return func() error { return j.log.Wait(seq) }
+		}
+	}
+	logging.Errorf("Failed to write mempool journal: %v", err)
+	return func() error { return err }
+}
+
+// close commits the appended records, syncs and closes the journal
+func (j *journal) close() error {
+	return j.log.Close()
+}
@@ -0,0 +1,95 @@
+package mempool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mempool.journal")
+	mp, clock := newTestMempool(t, "seed")
+	if _, err := mp.OpenJournal(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	ids := admit(t, mp, clock, time.Millisecond, 1, 2, 3, 4)
+	mp.RemoveTransactions([]string{ids[0]}, "included")
+	if err := mp.CloseJournal(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Transactions included in a block before the restart are dropped
+	restarted, _ := newTestMempool(t, "seed")
+	restored, err := restarted.OpenJournal(path, func(id string) bool { return id == ids[1] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.CloseJournal()
+
+	if restored != 2 || restarted.Size() != 2 {
+		t.Fatalf("restored %d transactions, size %d, want 2", restored, restarted.Size())
+	}
+	for _, id := range ids[2:] {
+		tx, ok := restarted.GetTransaction(id)
+		if !ok {
+			t.Fatalf("transaction %s not restored", id)
+		}
+		if original, _ := mp.GetTransaction(id); !tx.Timestamp.Equal(original.Timestamp) || tx.Priority != original.Priority {
+			t.Errorf("transaction %s restored as %+v, want %+v", id, tx, original)
+		}
+	}
+}
+
+func TestJournalTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mempool.journal")
+	mp, clock := newTestMempool(t, "seed")
+	if _, err := mp.OpenJournal(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	ids := admit(t, mp, clock, time.Millisecond, 1, 2)
+	if err := mp.CloseJournal(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A record cut short by a crash is skipped
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"add":{"id":"torn","da`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	restarted, restartedClock := newTestMempool(t, "restart")
+	restored, err := restarted.OpenJournal(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 2 {
+		t.Fatalf("restored %d transactions, want 2", restored)
+	}
+	for _, id := range ids {
+		if _, ok := restarted.GetTransaction(id); !ok {
+			t.Errorf("transaction %s not restored", id)
+		}
+	}
+
+	// The journal was compacted, so records appended after the torn one are
+	// replayed by the next restart
+	ids = append(ids, admit(t, restarted, restartedClock, time.Millisecond, 5)...)
+	if err := restarted.CloseJournal(); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := newTestMempool(t, "seed")
+	if restored, err := again.OpenJournal(path, nil); err != nil || restored != 3 {
+		t.Fatalf("restored %d transactions (%v), want 3", restored, err)
+	}
+	defer again.CloseJournal()
+	for _, id := range ids {
+		if _, ok := again.GetTransaction(id); !ok {
+			t.Errorf("transaction %s not restored", id)
+		}
+	}
+}
@@ -1,68 +1,388 @@
 package mempool
 
 import (
+	"errors"
+	"fmt"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"flashblock/internal/chaos"
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
 	"flashblock/internal/model"
+	"flashblock/internal/wal"
+	"flashblock/pkg/extension"
 )
 
-// TransactionHook is a function called when a transaction is processed
-type TransactionHook func(*model.Transaction, bool)
+// Admission errors
+var (
+	ErrDuplicate   = errors.New("transaction already in mempool")
+	ErrMempoolFull = errors.New("mempool is full")
+	ErrBlacklisted = errors.New("address is blacklisted")
+	ErrClosed      = errors.New("mempool is closed")
+	ErrReadOnly    = errors.New("mempool is read-only on a standby or replica node")
+	ErrPrivacyTTL  = errors.New("privacy TTL exceeds the maximum")
+	ErrNotDurable  = errors.New("transaction could not be persisted")
+)
+
+// MaintenanceErrorCode is the JSON-RPC error code of submissions rejected during maintenance
+const MaintenanceErrorCode = -32050
+
+// MaintenanceError is returned for new transactions while the node is in maintenance mode
+type MaintenanceError struct {
+	Reason string // Operator supplied reason (optional)
+}
+
+func (e *MaintenanceError) Error() string {
+	if e.Reason == "" {
+		return "node is in maintenance mode, submissions are temporarily rejected"
+	}
+	return fmt.Sprintf("node is in maintenance mode, submissions are temporarily rejected: %s", e.Reason)
+}
 
-// Mempool stores pending transactions in memory
+// ErrorCode returns the JSON-RPC error code, so clients can tell maintenance from other errors
+func (e *MaintenanceError) ErrorCode() int {
+	return MaintenanceErrorCode
+}
+
+// ShedErrorCode is the JSON-RPC error code of submissions shed under overload
+const ShedErrorCode = -32051
+
+// ShedError is returned for new transactions below the shedding threshold
+// while the node is overloaded
+type ShedError struct {
+	Priority    int
+	MinPriority int           // Lowest priority admitted while shedding
+	RetryAfter  time.Duration // Suggested wait before resubmitting
+}
+
+func (e *ShedError) Error() string {
+	return fmt.Sprintf("node is overloaded, priority %d is below the shedding threshold of %d, retry after %v", e.Priority, e.MinPriority, e.RetryAfter)
+}
+
+// ErrorCode returns the JSON-RPC error code, so clients can tell shedding from other errors
+func (e *ShedError) ErrorCode() int {
+	return ShedErrorCode
+}
+
+// ErrorData returns the retry hint, so clients can back off or raise the priority
+func (e *ShedError) ErrorData() any {
+	return map[string]any{
+		"min_priority":   e.MinPriority,
+		"retry_after_ms": e.RetryAfter.Milliseconds(),
+	}
+}
+
+// ErrBelowMinPriority is returned when a transaction does not meet the fee floor
+type ErrBelowMinPriority struct {
+	Priority    int
+	MinPriority int
+}
+
+func (e *ErrBelowMinPriority) Error() string {
+	return fmt.Sprintf("priority %d is below the minimum of %d", e.Priority, e.MinPriority)
+}
+
+// Mempool stores pending transactions in memory. Private transactions are
+// kept apart from the public ones until their privacy TTL ends: they are
+// block candidates, but are not returned by queries, published to
+// subscribers or journaled.
 type Mempool struct {
 	transactions map[string]*model.Transaction
-	hooks        []TransactionHook
+	private      map[string]*privateTx
+	config       *Config
+	minPriority  int               // Fee floor for new transactions
+	feeFloor     int               // Congestion fee floor, applied when above minPriority
+	blacklist    map[string]bool   // Lower-cased addresses whose transactions are rejected
+	closed       bool              // New transactions are rejected once closed
+	readOnly     bool              // New transactions are rejected on standby and replica nodes
+	maintenance  *MaintenanceError // New transactions are rejected while set
+	shedding     *ShedError        // New transactions below its priority are rejected while set
+	shed         atomic.Uint64     // Transactions rejected by shedding
+	journal      *journal          // Persists changes when a journal is open
+	events       *events.Bus       // Receives admitted and removed transactions, in the order of the changes
+	factory      *model.Factory    // Creates transactions with the clock and IDs of the configuration
 	mu           sync.RWMutex
 }
 
+// privateTx is a transaction that is not public until its privacy TTL ends
+type privateTx struct {
+	tx     *model.Transaction
+	expire bool // Dropped instead of published when the TTL ends
+	timer  model.Timer
+}
+
+// Config holds configuration for the mempool
+type Config struct {
+	MaxSize       int             // Maximum number of pending transactions (0 = unlimited)
+	PrivateTTL    time.Duration   // Default privacy TTL of private transactions
+	MaxPrivateTTL time.Duration   // Maximum privacy TTL of private transactions (0 = unlimited)
+	MinPriority   int             // Minimum priority of new transactions
+	Blacklist     []string        // Sender or recipient addresses whose transactions are rejected
+	Chaos         *chaos.Injector // Drops submissions for resilience testing (optional)
+
+	// Validate checks new transactions against the account state (optional)
+	Validate func(*model.Transaction) error
+
+	// Validators apply the admission rules of extensions, in order, after the
+	// built-in rules (optional)
+	Validators []extension.Validator
+
+	// Journal sets how the journal commits admitted transactions
+	Journal wal.Config
+
+	// Events receives the admitted and removed transactions (created if nil)
+	Events *events.Bus
+
+	// Clock times the privacy TTLs and IDs identifies the transactions created
+	// through the factory of the mempool (the wall clock and time-based IDs if
+	// nil); tests set deterministic ones
+	Clock model.Clock
+	IDs   model.IDGenerator
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MaxSize: 0,
+	}
+}
+
 // New creates a new empty mempool
-func New() *Mempool {
-	return &Mempool{
+func New(config *Config) *Mempool {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Events == nil {
+		config.Events = events.NewBus()
+	}
+
+	mp := &Mempool{
 		transactions: make(map[string]*model.Transaction),
-		hooks:        make([]TransactionHook, 0),
+		private:      make(map[string]*privateTx),
+		config:       config,
+		events:       config.Events,
+		factory:      model.NewFactory(config.Clock, config.IDs),
 	}
+	mp.SetAdmissionRules(config.MinPriority, config.Blacklist)
+
+	return mp
 }
 
-// AddTransactionHook adds a hook to be called when a transaction is added to the mempool
-func (mp *Mempool) AddTransactionHook(hook TransactionHook) {
+// SetAdmissionRules replaces the fee floor and address blacklist applied to new transactions
+func (mp *Mempool) SetAdmissionRules(minPriority int, blacklist []string) {
+	addresses := make(map[string]bool, len(blacklist))
+	for _, addr := range blacklist {
+		if addr != "" {
+			addresses[strings.ToLower(addr)] = true
+		}
+	}
+
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
-	mp.hooks = append(mp.hooks, hook)
+	mp.minPriority = minPriority
+	mp.blacklist = addresses
 }
 
-// AddTransaction adds a new transaction to the mempool
-func (mp *Mempool) AddTransaction(tx *model.Transaction) bool {
+// SetFeeFloor sets the congestion fee floor. New transactions must meet the
+// higher of it and the configured minimum priority.
+func (mp *Mempool) SetFeeFloor(floor int) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
-	// Check if transaction already exists
-	if _, exists := mp.transactions[tx.ID]; exists {
-		return false
+	mp.feeFloor = floor
+}
+
+// MinPriority returns the configured minimum priority of new transactions
+func (mp *Mempool) MinPriority() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return mp.minPriority
+}
+
+// FeeFloor returns the minimum priority new transactions must meet
+func (mp *Mempool) FeeFloor() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return max(mp.minPriority, mp.feeFloor)
+}
+
+// Capacity returns the maximum number of pending transactions (0 = unlimited)
+func (mp *Mempool) Capacity() int {
+	return mp.config.MaxSize
+}
+
+// Factory returns the factory creating transactions with the clock and IDs
+// of the mempool
+func (mp *Mempool) Factory() *model.Factory {
+	return mp.factory
+}
+
+// Clock returns the clock of the mempool
+func (mp *Mempool) Clock() model.Clock {
+	return mp.factory.Clock
+}
+
+// Events returns the event bus the mempool publishes to
+func (mp *Mempool) Events() *events.Bus {
+	return mp.events
+}
+
+// AddTransaction adds a new transaction to the mempool
+func (mp *Mempool) AddTransaction(tx *model.Transaction) bool {
+	return mp.Admit(tx) == nil
+}
+
+// Admit adds a new transaction to the mempool, returning the reason if it is
+// rejected. With a journal open, Admit returns once the transaction is
+// committed to it, so an admitted transaction survives a crash.
+func (mp *Mempool) Admit(tx *model.Transaction) error {
+	mp.mu.Lock()
+	if err := mp.checkAdmission(tx); err != nil {
+		mp.mu.Unlock()
+		if err == errDropped {
+			return nil
+		}
+		return err
 	}
 
 	// Add transaction to mempool
 	mp.transactions[tx.ID] = tx
+	var commit func() error
+	if mp.journal != nil {
+		commit = mp.journal.write(&journalRecord{Add: tx})
+	}
+	mp.events.Publish(events.TxAdmitted{Tx: tx})
+	mp.mu.Unlock()
 
-	// Execute transaction hooks outside the lock
-	added := true
-	go mp.executeHooks(tx, added)
+	// The commit is awaited without the lock, so concurrent admissions share it.
+	// The transaction stays pending if it fails, but is not acknowledged.
+	if commit != nil {
+		if err := commit(); err != nil {
+			return fmt.Errorf("%w: %v", ErrNotDurable, err)
+		}
+	}
+	return nil
+}
 
-	return added
+// AdmitPrivate adds a new transaction that stays private for ttl (the
+// configured default if zero). Afterwards it becomes public like an admitted
+// transaction, or is dropped if expire is set. Private transactions are not
+// journaled, so they do not survive a restart.
+func (mp *Mempool) AdmitPrivate(tx *model.Transaction, ttl time.Duration, expire bool) error {
+	if ttl <= 0 {
+		ttl = mp.config.PrivateTTL
+	}
+	if mp.config.MaxPrivateTTL > 0 && ttl > mp.config.MaxPrivateTTL {
+		return fmt.Errorf("%w: %v, maximum %v", ErrPrivacyTTL, ttl, mp.config.MaxPrivateTTL)
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if err := mp.checkAdmission(tx); err != nil {
+		if err == errDropped {
+			return nil
+		}
+		return err
+	}
+
+	entry := &privateTx{tx: tx, expire: expire}
+	entry.timer = mp.factory.Clock.AfterFunc(ttl, func() { mp.endPrivacy(entry) })
+	mp.private[tx.ID] = entry
+	return nil
 }
 
-// executeHooks runs all registered hooks for a transaction
-func (mp *Mempool) executeHooks(tx *model.Transaction, added bool) {
-	mp.mu.RLock()
-	hooks := make([]TransactionHook, len(mp.hooks))
-	copy(hooks, mp.hooks)
-	mp.mu.RUnlock()
+// endPrivacy publishes or drops a private transaction whose TTL ended,
+// unless it was included or removed meanwhile
+func (mp *Mempool) endPrivacy(entry *privateTx) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	id := entry.tx.ID
+	if mp.private[id] != entry {
+		return
+	}
+	delete(mp.private, id)
 
-	for _, hook := range hooks {
-		hook(tx, added)
+	if entry.expire {
+		logging.Debugf("Private transaction %s expired", id)
+		mp.events.Publish(events.TxRemoved{IDs: []string{id}, Reason: events.RemovedExpired})
+		return
 	}
+	mp.transactions[id] = entry.tx
+	if mp.journal != nil {
+		mp.journal.write(&journalRecord{Add: entry.tx})
+	}
+	logging.Debugf("Private transaction %s became public", id)
+	mp.events.Publish(events.TxAdmitted{Tx: entry.tx})
+}
+
+// errDropped reports a submission lost to an injected fault, which is
+// accepted without being added
+var errDropped = errors.New("dropped")
+
+// checkAdmission applies the admission rules to a new transaction. The caller
+// must hold the write lock.
+func (mp *Mempool) checkAdmission(tx *model.Transaction) error {
+	// Reject new transactions during shutdown
+	if mp.closed {
+		return ErrClosed
+	}
+
+	// An injected fault loses the transaction after it was accepted
+	if mp.config.Chaos.DropSubmission() {
+		logging.Debugf("Chaos: dropped transaction %s", tx.ID)
+		return errDropped
+	}
+	if mp.maintenance != nil {
+		return mp.maintenance
+	}
+	if mp.readOnly {
+		return ErrReadOnly
+	}
+
+	// Check if transaction already exists
+	if _, exists := mp.transactions[tx.ID]; exists {
+		return ErrDuplicate
+	}
+	if _, exists := mp.private[tx.ID]; exists {
+		return ErrDuplicate
+	}
+
+	// Apply admission rules
+	if mp.shedding != nil && tx.Priority < mp.shedding.MinPriority {
+		mp.shed.Add(1)
+		return &ShedError{Priority: tx.Priority, MinPriority: mp.shedding.MinPriority, RetryAfter: mp.shedding.RetryAfter}
+	}
+	if floor := max(mp.minPriority, mp.feeFloor); tx.Priority < floor {
+		return &ErrBelowMinPriority{Priority: tx.Priority, MinPriority: floor}
+	}
+	if mp.blacklist[strings.ToLower(tx.From)] || mp.blacklist[strings.ToLower(tx.To)] {
+		return ErrBlacklisted
+	}
+	if mp.config.Validate != nil {
+		if err := mp.config.Validate(tx); err != nil {
+			return err
+		}
+	}
+	for _, v := range mp.config.Validators {
+		if err := v.Validate(tx.ExtensionView()); err != nil {
+			return err
+		}
+	}
+
+	// Reject new transactions when the mempool is full
+	if mp.config.MaxSize > 0 && len(mp.transactions)+len(mp.private) >= mp.config.MaxSize {
+		return ErrMempoolFull
+	}
+	return nil
 }
 
 // GetTransaction retrieves a transaction by ID
@@ -90,34 +410,249 @@ func (mp *Mempool) GetAllTransactions() []*model.Transaction {
 	return txs
 }
 
-// GetSortedTransactions returns all transactions sorted by priority (high to low)
+// BlockCandidates returns the public and private transactions that may be
+// included in the next block in arrival order
+func (mp *Mempool) BlockCandidates() []*model.Transaction {
+	mp.mu.RLock()
+	txs := make([]*model.Transaction, 0, len(mp.transactions)+len(mp.private))
+	for _, tx := range mp.transactions {
+		txs = append(txs, tx)
+	}
+	for _, entry := range mp.private {
+		txs = append(txs, entry.tx)
+	}
+	mp.mu.RUnlock()
+
+	sortByArrival(txs)
+	return txs
+}
+
+// sortByArrival orders transactions by their timestamp, and by ID if equal,
+// so the order does not depend on map iteration
+func sortByArrival(txs []*model.Transaction) {
+	sort.Slice(txs, func(i, j int) bool {
+		if !txs[i].Timestamp.Equal(txs[j].Timestamp) {
+			return txs[i].Timestamp.Before(txs[j].Timestamp)
+		}
+		return txs[i].ID < txs[j].ID
+	})
+}
+
+// PrivateSize returns the number of private transactions
+func (mp *Mempool) PrivateSize() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return len(mp.private)
+}
+
+// GetSortedTransactions returns all transactions sorted by priority (high to
+// low), and in arrival order within a priority
 func (mp *Mempool) GetSortedTransactions() []*model.Transaction {
 	transactions := mp.GetAllTransactions()
+	sortByArrival(transactions)
 
 	// Sort transactions by priority (high to low)
-	sort.Slice(transactions, func(i, j int) bool {
+	sort.SliceStable(transactions, func(i, j int) bool {
 		return transactions[i].Priority > transactions[j].Priority
 	})
 
 	return transactions
 }
 
-// RemoveTransactions removes transactions with the given IDs from the mempool
-func (mp *Mempool) RemoveTransactions(ids []string) {
+// RemoveTransactions removes transactions with the given IDs from the
+// mempool for the given reason, one of the events.Removed* constants
+func (mp *Mempool) RemoveTransactions(ids []string, reason string) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
+	var removed []string
 	for _, id := range ids {
-		delete(mp.transactions, id)
+		if _, ok := mp.transactions[id]; ok {
+			delete(mp.transactions, id)
+			removed = append(removed, id)
+		}
+		if entry, ok := mp.private[id]; ok {
+			entry.timer.Stop()
+			delete(mp.private, id)
+			removed = append(removed, id)
+		}
+	}
+	if mp.journal != nil && len(ids) > 0 {
+		mp.journal.write(&journalRecord{Remove: ids})
+	}
+	if len(removed) > 0 {
+		mp.events.Publish(events.TxRemoved{IDs: removed, Reason: reason})
+	}
+}
+
+// OpenJournal restores the pending transactions recorded in the journal at path
+// and records subsequent changes in it. Transactions for which included reports
+// true are dropped. It returns the number of restored transactions.
+func (mp *Mempool) OpenJournal(path string, included func(id string) bool) (int, error) {
+	transactions, err := replayJournal(path)
+	if err != nil {
+		return 0, err
+	}
+	for id := range transactions {
+		if included != nil && included(id) {
+			delete(transactions, id)
+		}
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	// Restored transactions were admitted before the restart, so admission rules are not re-applied
+	for id, tx := range transactions {
+		mp.transactions[id] = tx
+	}
+
+	// The journal is compacted to the current pending set
+	j, err := rotateJournal(path, mp.transactions, mp.config.Journal)
+	if err != nil {
+		return 0, err
+	}
+	mp.journal = j
+
+	return len(transactions), nil
+}
+
+// CloseJournal syncs and closes the journal; later changes are no longer recorded
+func (mp *Mempool) CloseJournal() error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if mp.journal == nil {
+		return nil
+	}
+	err := mp.journal.close()
+	stats := mp.journal.log.Stats()
+	mp.journal = nil
+	logging.Debugf("Mempool journal closed: %d records in %d commits", stats.Records, stats.Commits)
+	return err
+}
+
+// SetReadOnly stops or resumes admitting new transactions; Sync still applies
+func (mp *Mempool) SetReadOnly(readOnly bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.readOnly = readOnly
+}
+
+// SetMaintenance rejects new transactions with a MaintenanceError giving reason
+// while enabled; pending transactions remain available
+func (mp *Mempool) SetMaintenance(enabled bool, reason string) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.maintenance = nil
+	if enabled {
+		mp.maintenance = &MaintenanceError{Reason: reason}
 	}
 }
 
+// InMaintenance reports whether new transactions are rejected for maintenance
+func (mp *Mempool) InMaintenance() bool {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return mp.maintenance != nil
+}
+
+// SetShedding rejects new transactions below minPriority with a ShedError
+// suggesting to retry after retryAfter while enabled
+func (mp *Mempool) SetShedding(enabled bool, minPriority int, retryAfter time.Duration) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.shedding = nil
+	if enabled {
+		mp.shedding = &ShedError{MinPriority: minPriority, RetryAfter: retryAfter}
+	}
+}
+
+// Shedding returns the lowest priority admitted and whether submissions are
+// shed
+func (mp *Mempool) Shedding() (int, bool) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	if mp.shedding == nil {
+		return 0, false
+	}
+	return mp.shedding.MinPriority, true
+}
+
+// ShedCount returns the number of transactions rejected by shedding
+func (mp *Mempool) ShedCount() uint64 {
+	return mp.shed.Load()
+}
+
+// Sync replaces the pending transactions with the given set, as maintained by
+// another node. Admission rules are not applied and no events are published.
+// It returns the number of added and removed transactions.
+func (mp *Mempool) Sync(transactions []*model.Transaction) (added int, removed int) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	next := make(map[string]*model.Transaction, len(transactions))
+	for _, tx := range transactions {
+		next[tx.ID] = tx
+	}
+
+	var removedIDs []string
+	for id := range mp.transactions {
+		if _, ok := next[id]; !ok {
+			delete(mp.transactions, id)
+			removedIDs = append(removedIDs, id)
+		}
+	}
+	if mp.journal != nil && len(removedIDs) > 0 {
+		mp.journal.write(&journalRecord{Remove: removedIDs})
+	}
+
+	for id, tx := range next {
+		if _, ok := mp.transactions[id]; ok {
+			continue
+		}
+		mp.transactions[id] = tx
+		added++
+		if mp.journal != nil {
+			mp.journal.write(&journalRecord{Add: tx})
+		}
+	}
+
+	return added, len(removedIDs)
+}
+
+// Close stops admitting new transactions; pending transactions remain available
+func (mp *Mempool) Close() {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.closed = true
+}
+
 // Clear removes all transactions from the mempool
 func (mp *Mempool) Clear() {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
+	if mp.journal != nil && len(mp.transactions) > 0 {
+		ids := make([]string, 0, len(mp.transactions))
+		for id := range mp.transactions {
+			ids = append(ids, id)
+		}
+		mp.journal.write(&journalRecord{Remove: ids})
+	}
+
 	mp.transactions = make(map[string]*model.Transaction)
+	for _, entry := range mp.private {
+		entry.timer.Stop()
+	}
+	mp.private = make(map[string]*privateTx)
 }
 
 // Size returns the number of transactions in the mempool
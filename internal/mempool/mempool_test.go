@@ -0,0 +1,176 @@
+package mempool
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"flashblock/internal/deterministic"
+	"flashblock/internal/events"
+	"flashblock/internal/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestMempool creates a mempool with a deterministic clock and IDs for seed
+func newTestMempool(t *testing.T, seed string) (*Mempool, *deterministic.Clock) {
+	t.Helper()
+	clock := deterministic.NewClock(testStart)
+	mp := New(&Config{Clock: clock, IDs: deterministic.NewIDs(seed)})
+	return mp, clock
+}
+
+// admit creates and admits a transaction for each priority, advancing the
+// clock by step before each, and returns their IDs
+func admit(t *testing.T, mp *Mempool, clock *deterministic.Clock, step time.Duration, priorities ...int) []string {
+	t.Helper()
+	ids := make([]string, len(priorities))
+	for i, priority := range priorities {
+		clock.Advance(step)
+		tx := mp.Factory().NewTransaction([]byte(fmt.Sprintf("tx-%d", i)), priority)
+		if err := mp.Admit(tx); err != nil {
+			t.Fatalf("Admit(%d): %v", i, err)
+		}
+		ids[i] = tx.ID
+	}
+	return ids
+}
+
+func txIDs(txs []*model.Transaction) []string {
+	ids := make([]string, len(txs))
+	for i, tx := range txs {
+		ids[i] = tx.ID
+	}
+	return ids
+}
+
+func TestDeterministicIDs(t *testing.T) {
+	mp1, clock1 := newTestMempool(t, "seed")
+	mp2, clock2 := newTestMempool(t, "seed")
+	ids1 := admit(t, mp1, clock1, time.Millisecond, 1, 2, 3)
+	ids2 := admit(t, mp2, clock2, time.Millisecond, 1, 2, 3)
+	if !reflect.DeepEqual(ids1, ids2) {
+		t.Fatalf("IDs differ for the same seed: %v, %v", ids1, ids2)
+	}
+
+	mp3, clock3 := newTestMempool(t, "other")
+	ids3 := admit(t, mp3, clock3, time.Millisecond, 1, 2, 3)
+	for i := range ids1 {
+		if ids1[i] == ids3[i] {
+			t.Errorf("transaction %d has the same ID for different seeds", i)
+		}
+	}
+
+	tx, ok := mp1.GetTransaction(ids1[1])
+	if !ok {
+		t.Fatal("admitted transaction not found")
+	}
+	if want := testStart.Add(2 * time.Millisecond); !tx.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", tx.Timestamp, want)
+	}
+}
+
+func TestBlockCandidatesArrivalOrder(t *testing.T) {
+	mp, clock := newTestMempool(t, "seed")
+	ids := admit(t, mp, clock, time.Millisecond, 5, 1, 9, 1)
+
+	if got := txIDs(mp.BlockCandidates()); !reflect.DeepEqual(got, ids) {
+		t.Errorf("candidates = %v, want arrival order %v", got, ids)
+	}
+}
+
+func TestBlockCandidatesSameTimestamp(t *testing.T) {
+	// Without the clock moving, the order falls back to the IDs
+	mp, clock := newTestMempool(t, "seed")
+	admit(t, mp, clock, 0, 1, 1, 1, 1)
+
+	first := txIDs(mp.BlockCandidates())
+	for i := 1; i < len(first); i++ {
+		if first[i-1] >= first[i] {
+			t.Fatalf("candidates with equal timestamps not ordered by ID: %v", first)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if got := txIDs(mp.BlockCandidates()); !reflect.DeepEqual(got, first) {
+			t.Fatalf("candidates = %v, want %v", got, first)
+		}
+	}
+}
+
+func TestSortedTransactions(t *testing.T) {
+	mp, clock := newTestMempool(t, "seed")
+	ids := admit(t, mp, clock, time.Millisecond, 1, 3, 2, 3, 1)
+
+	want := []string{ids[1], ids[3], ids[2], ids[0], ids[4]}
+	if got := txIDs(mp.GetSortedTransactions()); !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted = %v, want %v", got, want)
+	}
+}
+
+func TestPrivateTTL(t *testing.T) {
+	mp, clock := newTestMempool(t, "seed")
+	public := mp.Factory().NewTransaction([]byte("public"), 1)
+	expiring := mp.Factory().NewTransaction([]byte("expiring"), 1)
+	if err := mp.AdmitPrivate(public, time.Second, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := mp.AdmitPrivate(expiring, 2*time.Second, true); err != nil {
+		t.Fatal(err)
+	}
+	if mp.PrivateSize() != 2 || mp.Size() != 0 {
+		t.Fatalf("private = %d, public = %d, want 2 and 0", mp.PrivateSize(), mp.Size())
+	}
+
+	clock.Advance(time.Second)
+	if _, ok := mp.GetTransaction(public.ID); !ok || mp.PrivateSize() != 1 {
+		t.Fatalf("transaction not published when its TTL ended")
+	}
+
+	clock.Advance(time.Second)
+	if _, ok := mp.GetTransaction(expiring.ID); ok || mp.PrivateSize() != 0 {
+		t.Fatalf("expiring transaction not dropped when its TTL ended")
+	}
+	if clock.Pending() != 0 {
+		t.Errorf("%d timers pending", clock.Pending())
+	}
+}
+
+func TestStalledSubscriber(t *testing.T) {
+	mp, clock := newTestMempool(t, "seed")
+	stalled := make(chan events.TxAdmitted)
+	sub := mp.Events().TxAdmitted.Subscribe(stalled)
+	defer sub.Unsubscribe()
+
+	// A subscriber that never reads holds up neither admissions nor removals
+	done := make(chan []string)
+	go func() {
+		ids := make([]string, 2000)
+		for i := range ids {
+			clock.Advance(time.Millisecond)
+			tx := mp.Factory().NewTransaction([]byte(fmt.Sprintf("tx-%d", i)), 1)
+			if err := mp.Admit(tx); err != nil {
+				t.Errorf("Admit(%d): %v", i, err)
+			}
+			ids[i] = tx.ID
+		}
+		mp.RemoveTransactions(ids, events.RemovedIncluded)
+		done <- ids
+	}()
+	var ids []string
+	select {
+	case ids = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("admissions blocked by a stalled subscriber")
+	}
+	if n := len(mp.BlockCandidates()); n != 0 {
+		t.Errorf("%d transactions pending, want none", n)
+	}
+
+	// The subscriber receives the events in order once it reads
+	for i, id := range ids[:10] {
+		if ev := <-stalled; ev.Tx.ID != id {
+			t.Fatalf("event %d is for %s, want %s", i, ev.Tx.ID, id)
+		}
+	}
+}
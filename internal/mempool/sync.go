@@ -0,0 +1,133 @@
+package mempool
+
+import (
+	"hash/fnv"
+
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// FingerprintBuckets is the number of buckets the pending transactions are
+// split into by the hash of their ID
+const FingerprintBuckets = 64
+
+// Bucket summarizes the pending transactions of a bucket
+type Bucket struct {
+	Count int            `json:"count"`
+	Hash  hexutil.Uint64 `json:"hash"` // XOR of the 64-bit FNV-1a hashes of the IDs
+}
+
+// Fingerprint summarizes the public pending transactions, so two nodes find
+// the buckets their mempools differ in without exchanging every transaction
+type Fingerprint struct {
+	Count   int      `json:"count"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Diff returns the buckets in which the fingerprints differ
+func (f *Fingerprint) Diff(other *Fingerprint) []int {
+	var buckets []int
+	for i := range f.Buckets {
+		if i >= len(other.Buckets) || f.Buckets[i] != other.Buckets[i] {
+			buckets = append(buckets, i)
+		}
+	}
+	return buckets
+}
+
+// idHash returns the hash of a transaction ID used for fingerprints
+func idHash(id string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return h.Sum64()
+}
+
+// bucketOf returns the bucket of a transaction ID
+func bucketOf(id string) int {
+	return int(idHash(id) % FingerprintBuckets)
+}
+
+// Fingerprint returns the fingerprint of the public pending transactions
+func (mp *Mempool) Fingerprint() *Fingerprint {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	fp := &Fingerprint{
+		Count:   len(mp.transactions),
+		Buckets: make([]Bucket, FingerprintBuckets),
+	}
+	for id := range mp.transactions {
+		h := idHash(id)
+		bucket := &fp.Buckets[h%FingerprintBuckets]
+		bucket.Count++
+		bucket.Hash ^= hexutil.Uint64(h)
+	}
+	return fp
+}
+
+// BucketIDs returns the IDs of the public pending transactions in the given buckets
+func (mp *Mempool) BucketIDs(buckets []int) []string {
+	selected := make(map[int]bool, len(buckets))
+	for _, bucket := range buckets {
+		selected[bucket] = true
+	}
+
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	var ids []string
+	for id := range mp.transactions {
+		if selected[bucketOf(id)] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// GetTransactions returns the public pending transactions with the given IDs
+// that are still in the mempool
+func (mp *Mempool) GetTransactions(ids []string) []*model.Transaction {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	txs := make([]*model.Transaction, 0, len(ids))
+	for _, id := range ids {
+		if tx, ok := mp.transactions[id]; ok {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// Reconcile adds and removes pending transactions to match the mempool of
+// another node. Admission rules are not applied and no events are published.
+// It returns the number of added and removed transactions.
+func (mp *Mempool) Reconcile(add []*model.Transaction, remove []string) (added int, removed int) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	var removedIDs []string
+	for _, id := range remove {
+		if _, ok := mp.transactions[id]; ok {
+			delete(mp.transactions, id)
+			removedIDs = append(removedIDs, id)
+		}
+	}
+	if mp.journal != nil && len(removedIDs) > 0 {
+		mp.journal.write(&journalRecord{Remove: removedIDs})
+	}
+
+	for _, tx := range add {
+		if _, ok := mp.transactions[tx.ID]; ok {
+			continue
+		}
+		mp.transactions[tx.ID] = tx
+		added++
+		if mp.journal != nil {
+			mp.journal.write(&journalRecord{Add: tx})
+		}
+	}
+
+	return added, len(removedIDs)
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -12,16 +13,45 @@ type Metrics struct {
 	TransactionsReceived  uint64
 	TransactionsProcessed uint64
 	TransactionsRejected  uint64
+	TransactionsDropped   uint64 // Removed from the mempool without being included
+
+	// RPC metrics
+	RequestsBusy uint64 // Requests rejected with "server busy"
+
+	// Relay metrics
+	RelayDeliveries uint64 // Blocks accepted by a relay endpoint
+	RelayFailures   uint64 // Blocks a relay endpoint did not accept after all retries
+	RelayRetries    uint64 // Repeated block submissions to relay endpoints
+
+	// Data availability metrics
+	DASubmissions uint64 // Batches published to the DA layer
+	DAFailures    uint64 // Batches that could not be published after all retries
+
+	// Fee market metrics
+	FeeFloor    uint64  // Minimum priority of new transactions
+	FeePressure float64 // Utilization relative to the congestion targets (above 1 raises the floor)
+
+	// Load shedding metrics
+	TransactionsShed uint64  // Submissions rejected below the shedding threshold
+	ShedPriority     uint64  // Lowest priority admitted while shedding (0 when not shedding)
+	ShedShare        float64 // Share of the submitted priorities below the threshold
+	Overloaded       bool    // RPC queueing or block build time above its threshold
 
 	// Block metrics
 	BlocksCreated  uint64
+	BlocksAttested uint64 // Blocks with a TDX quote
 	TotalBlockTime time.Duration
 	LastBlockTime  time.Time
 
+	// Configuration metrics
+	ConfigChanges uint64 // Settings changed by reloads and admin_setConfig
+
 	// Performance metrics
 	StartTime      time.Time
 	ProcessedTPS   float64 // Transactions Per Second
 	AverageLatency time.Duration
+
+	mu sync.Mutex // Protects the non-atomic fields
 }
 
 // New creates a new metrics instance
@@ -47,41 +77,137 @@ func (m *Metrics) IncrementTransactionsRejected() {
 	atomic.AddUint64(&m.TransactionsRejected, 1)
 }
 
+// AddTransactionsDropped adds to the counter of transactions removed from the mempool without being included
+func (m *Metrics) AddTransactionsDropped(count uint64) {
+	atomic.AddUint64(&m.TransactionsDropped, count)
+}
+
+// IncrementRequestsBusy increments the counter of requests rejected under overload
+func (m *Metrics) IncrementRequestsBusy() {
+	atomic.AddUint64(&m.RequestsBusy, 1)
+}
+
+// IncrementRelayDeliveries increments the counter of blocks delivered to relays
+func (m *Metrics) IncrementRelayDeliveries() {
+	atomic.AddUint64(&m.RelayDeliveries, 1)
+}
+
+// IncrementRelayFailures increments the counter of blocks relays did not accept
+func (m *Metrics) IncrementRelayFailures() {
+	atomic.AddUint64(&m.RelayFailures, 1)
+}
+
+// IncrementRelayRetries increments the counter of repeated relay submissions
+func (m *Metrics) IncrementRelayRetries() {
+	atomic.AddUint64(&m.RelayRetries, 1)
+}
+
+// IncrementDASubmissions increments the counter of batches published to the DA layer
+func (m *Metrics) IncrementDASubmissions() {
+	atomic.AddUint64(&m.DASubmissions, 1)
+}
+
+// IncrementDAFailures increments the counter of batches that could not be published
+func (m *Metrics) IncrementDAFailures() {
+	atomic.AddUint64(&m.DAFailures, 1)
+}
+
+// SetFeeFloor records the fee floor and the congestion pressure it was adjusted for
+func (m *Metrics) SetFeeFloor(floor int, pressure float64) {
+	atomic.StoreUint64(&m.FeeFloor, uint64(max(floor, 0)))
+
+	m.mu.Lock()
+	m.FeePressure = pressure
+	m.mu.Unlock()
+}
+
+// SetShedding records the shedding counter and the state of the overload
+// controller after an adjustment
+func (m *Metrics) SetShedding(shed uint64, minPriority int, share float64, overloaded bool) {
+	atomic.StoreUint64(&m.TransactionsShed, shed)
+	atomic.StoreUint64(&m.ShedPriority, uint64(max(minPriority, 0)))
+
+	m.mu.Lock()
+	m.ShedShare = share
+	m.Overloaded = overloaded
+	m.mu.Unlock()
+}
+
 // IncrementBlocksCreated increments the created blocks counter
 func (m *Metrics) IncrementBlocksCreated() {
 	atomic.AddUint64(&m.BlocksCreated, 1)
 }
 
+// IncrementBlocksAttested increments the attested blocks counter
+func (m *Metrics) IncrementBlocksAttested() {
+	atomic.AddUint64(&m.BlocksAttested, 1)
+}
+
+// AddConfigChanges adds to the counter of applied configuration changes
+func (m *Metrics) AddConfigChanges(count uint64) {
+	atomic.AddUint64(&m.ConfigChanges, count)
+}
+
 // RecordBlockCreationTime records the time taken to create a block
 func (m *Metrics) RecordBlockCreationTime(duration time.Duration) {
 	// Add duration to total time (using nanoseconds for atomic operations)
 	atomic.AddUint64((*uint64)(unsafe.Pointer(&m.TotalBlockTime)), uint64(duration.Nanoseconds()))
+
+	m.mu.Lock()
 	m.LastBlockTime = time.Now()
+	m.mu.Unlock()
 }
 
 // CalculateMetrics calculates derived metrics like TPS and average latency
 func (m *Metrics) CalculateMetrics() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calculateMetrics()
+}
+
+// calculateMetrics updates the derived metrics; the caller must hold mu
+func (m *Metrics) calculateMetrics() {
 	uptime := time.Since(m.StartTime).Seconds()
 	if uptime > 0 {
-		m.ProcessedTPS = float64(m.TransactionsProcessed) / uptime
+		m.ProcessedTPS = float64(atomic.LoadUint64(&m.TransactionsProcessed)) / uptime
 	}
 
-	if m.BlocksCreated > 0 {
-		m.AverageLatency = time.Duration(int64(m.TotalBlockTime) / int64(m.BlocksCreated))
+	if blocks := atomic.LoadUint64(&m.BlocksCreated); blocks > 0 {
+		totalBlockTime := atomic.LoadUint64((*uint64)(unsafe.Pointer(&m.TotalBlockTime)))
+		m.AverageLatency = time.Duration(totalBlockTime / blocks)
 	}
 }
 
 // GetSnapshot returns a snapshot of the current metrics
 func (m *Metrics) GetSnapshot() *Metrics {
-	m.CalculateMetrics()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calculateMetrics()
 
 	// Create a copy of the metrics
 	snapshot := &Metrics{
 		TransactionsReceived:  atomic.LoadUint64(&m.TransactionsReceived),
 		TransactionsProcessed: atomic.LoadUint64(&m.TransactionsProcessed),
 		TransactionsRejected:  atomic.LoadUint64(&m.TransactionsRejected),
+		TransactionsDropped:   atomic.LoadUint64(&m.TransactionsDropped),
+		RequestsBusy:          atomic.LoadUint64(&m.RequestsBusy),
+		RelayDeliveries:       atomic.LoadUint64(&m.RelayDeliveries),
+		RelayFailures:         atomic.LoadUint64(&m.RelayFailures),
+		RelayRetries:          atomic.LoadUint64(&m.RelayRetries),
+		DASubmissions:         atomic.LoadUint64(&m.DASubmissions),
+		DAFailures:            atomic.LoadUint64(&m.DAFailures),
+		FeeFloor:              atomic.LoadUint64(&m.FeeFloor),
+		FeePressure:           m.FeePressure,
+		TransactionsShed:      atomic.LoadUint64(&m.TransactionsShed),
+		ShedPriority:          atomic.LoadUint64(&m.ShedPriority),
+		ShedShare:             m.ShedShare,
+		Overloaded:            m.Overloaded,
 		BlocksCreated:         atomic.LoadUint64(&m.BlocksCreated),
-		TotalBlockTime:        m.TotalBlockTime,
+		BlocksAttested:        atomic.LoadUint64(&m.BlocksAttested),
+		ConfigChanges:         atomic.LoadUint64(&m.ConfigChanges),
+		TotalBlockTime:        time.Duration(atomic.LoadUint64((*uint64)(unsafe.Pointer(&m.TotalBlockTime)))),
 		LastBlockTime:         m.LastBlockTime,
 		StartTime:             m.StartTime,
 		ProcessedTPS:          m.ProcessedTPS,
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	s := m.GetSnapshot()
+
+	writeMetric(w, "flashblock_transactions_received_total", "counter", "Transactions submitted to the server", float64(s.TransactionsReceived))
+	writeMetric(w, "flashblock_transactions_rejected_total", "counter", "Submitted transactions rejected by the mempool", float64(s.TransactionsRejected))
+	writeMetric(w, "flashblock_transactions_dropped_total", "counter", "Pending transactions removed without being included", float64(s.TransactionsDropped))
+	writeMetric(w, "flashblock_transactions_processed_total", "counter", "Transactions included in blocks", float64(s.TransactionsProcessed))
+	writeMetric(w, "flashblock_rpc_requests_busy_total", "counter", "JSON-RPC requests rejected because the server was busy", float64(s.RequestsBusy))
+	writeMetric(w, "flashblock_relay_deliveries_total", "counter", "Blocks accepted by relay endpoints", float64(s.RelayDeliveries))
+	writeMetric(w, "flashblock_relay_failures_total", "counter", "Blocks relay endpoints did not accept after all retries", float64(s.RelayFailures))
+	writeMetric(w, "flashblock_relay_retries_total", "counter", "Repeated block submissions to relay endpoints", float64(s.RelayRetries))
+	writeMetric(w, "flashblock_da_submissions_total", "counter", "Block batches published to the DA layer", float64(s.DASubmissions))
+	writeMetric(w, "flashblock_da_failures_total", "counter", "Block batches that could not be published to the DA layer", float64(s.DAFailures))
+	writeMetric(w, "flashblock_fee_floor", "gauge", "Minimum priority of new transactions", float64(s.FeeFloor))
+	writeMetric(w, "flashblock_fee_pressure", "gauge", "Mempool and block utilization relative to the congestion targets", s.FeePressure)
+	writeMetric(w, "flashblock_transactions_shed_total", "counter", "Submissions rejected by load shedding under overload", float64(s.TransactionsShed))
+	writeMetric(w, "flashblock_shedding_priority", "gauge", "Lowest priority admitted while shedding (0 when not shedding)", float64(s.ShedPriority))
+	writeMetric(w, "flashblock_shedding_share", "gauge", "Share of the submitted priorities below the shedding threshold", s.ShedShare)
+	writeMetric(w, "flashblock_overloaded", "gauge", "Whether RPC queueing or block build time is above its threshold", boolValue(s.Overloaded))
+	writeMetric(w, "flashblock_blocks_created_total", "counter", "Blocks created", float64(s.BlocksCreated))
+	writeMetric(w, "flashblock_blocks_attested_total", "counter", "Blocks attested with a TDX quote", float64(s.BlocksAttested))
+	writeMetric(w, "flashblock_config_changes_total", "counter", "Configuration changes applied at runtime", float64(s.ConfigChanges))
+	writeMetric(w, "flashblock_processed_tps", "gauge", "Included transactions per second since start", s.ProcessedTPS)
+	writeMetric(w, "flashblock_block_creation_seconds_avg", "gauge", "Average block creation time", s.AverageLatency.Seconds())
+	writeMetric(w, "flashblock_last_block_timestamp_seconds", "gauge", "Unix time of the last block", float64(s.LastBlockTime.UnixNano())/1e9)
+	writeMetric(w, "flashblock_uptime_seconds", "gauge", "Time since the server started", time.Since(s.StartTime).Seconds())
+}
+
+// boolValue returns 1 for true and 0 for false
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// writeMetric writes a single sample with its HELP and TYPE lines
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+}
+
+// Handler returns an HTTP handler serving the metrics for Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.WritePrometheus(w)
+	})
+}
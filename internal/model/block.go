@@ -1,48 +1,86 @@
 package model
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"time"
+
+	"flashblock/pkg/codec"
 )
 
 // Block represents a collection of transactions
 type Block struct {
+	Version      uint8          `json:"version,omitempty"` // Derivation of the ID: 0 for LegacyID, else the codec version
 	ID           string         `json:"id"`
+	Number       uint64         `json:"number"` // Height of the block, starting at 1
 	Transactions []*Transaction `json:"transactions"`
 	Timestamp    time.Time      `json:"timestamp"`
 	PrevBlockID  string         `json:"prev_block_id"`
+	StateRoot    string         `json:"state_root,omitempty"` // Account state root after the block
+	GasLimit     uint64         `json:"gas_limit,omitempty"`
+	GasUsed      uint64         `json:"gas_used,omitempty"`
+	FeeRecipient string         `json:"fee_recipient,omitempty"` // Address credited with the priority fees
+	Receipts     []*Receipt     `json:"receipts,omitempty"`      // Execution results by transaction
 	TDXQuote     []byte         `json:"tdx_quote,omitempty"`
+
+	// Encrypted transactions decrypted for the block: the ID of the key and
+	// the commitments in the order they were decrypted. Both are part of the
+	// block ID, so the attestation quote covers every use of the key.
+	EncryptionKey string   `json:"encryption_key,omitempty"`
+	Commitments   []string `json:"commitments,omitempty"`
+
+	// Bounds of the timestamp from a trusted time source. Part of the block
+	// ID, so the attestation quote covers the claim.
+	TimeProof *TimeProof `json:"time_proof,omitempty"`
+
+	DA *DAReference `json:"da,omitempty"` // Where the block was published for data availability
 }
 
-// NewBlock creates a new block with the given transactions and previous block ID
-func NewBlock(transactions []*Transaction, prevBlockID string) *Block {
-	timestamp := time.Now()
+// TimeProof states how far the timestamp of a block can be from the true
+// time, as enforced by the trusted time source that produced it
+type TimeProof struct {
+	Source      string        `json:"source"`      // roughtime
+	Servers     []string      `json:"servers"`     // Servers that agreed on the time at the last synchronization
+	Synced      time.Time     `json:"synced"`      // Trusted time of the last synchronization
+	Uncertainty time.Duration `json:"uncertainty"` // Maximum error of the timestamp in nanoseconds
+}
 
+// DAReference locates a batch of blocks published to a data-availability layer
+type DAReference struct {
+	Layer      string   `json:"layer"`                 // celestia or ethereum
+	Height     uint64   `json:"height"`                // Height of the DA block including the batch
+	Commitment string   `json:"commitment"`            // Blob commitment (Celestia) or first versioned blob hash (Ethereum)
+	Namespace  string   `json:"namespace,omitempty"`   // Celestia namespace
+	TxHash     string   `json:"tx_hash,omitempty"`     // Ethereum blob transaction
+	BlobHashes []string `json:"blob_hashes,omitempty"` // Versioned hashes of all blobs of the Ethereum transaction
+	FirstBlock uint64   `json:"first_block"`           // Number of the first block in the batch
+	LastBlock  uint64   `json:"last_block"`            // Number of the last block in the batch
+}
+
+// NewBlock creates a new block with the given number, transactions and
+// previous block ID with the wall clock
+func NewBlock(number uint64, transactions []*Transaction, prevBlockID string) *Block {
+	return DefaultFactory.NewBlock(number, transactions, prevBlockID)
+}
+
+// NewBlock creates a new block with the given number, transactions and previous block ID
+func (f *Factory) NewBlock(number uint64, transactions []*Transaction, prevBlockID string) *Block {
 	// Create a new block
 	block := &Block{
+		Number:       number,
 		Transactions: transactions,
-		Timestamp:    timestamp,
+		Timestamp:    f.Clock.Now(),
 		PrevBlockID:  prevBlockID,
 	}
 
 	// Generate block ID by hashing its contents
-	block.generateID()
+	block.ID = block.LegacyID()
 
 	return block
 }
 
-// generateID creates a unique ID for the block based on its contents
-func (b *Block) generateID() {
-	// Concatenate transaction IDs, timestamp, and previous block ID
-	var data []byte
-	for _, tx := range b.Transactions {
-		data = append(data, []byte(tx.ID)...)
-	}
-	data = append(data, []byte(b.Timestamp.String())...)
-	data = append(data, []byte(b.PrevBlockID)...)
-
-	// Hash the data to generate block ID
-	hash := sha256.Sum256(data)
-	b.ID = hex.EncodeToString(hash[:])
+// LegacyID returns the ID of a version 0 block derived from its contents.
+// The ID commits to the previous block ID, so stored blocks form a hash
+// chain. Newer blocks derive their IDs from the canonical encoding of the
+// codec package.
+func (b *Block) LegacyID() string {
+	return codec.LegacyID(b.wireHeader())
 }
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Bundle is an ordered list of transactions that is included atomically at
+// the top of its target block: either all of its transactions are included
+// in order, or none. A transaction that reverts fails the bundle unless it is
+// allowed to revert.
+type Bundle struct {
+	ID             string         `json:"id"` // Hash of the transaction hashes
+	Transactions   []*Transaction `json:"transactions"`
+	BlockNumber    uint64         `json:"block_number"`            // Target block
+	MinTimestamp   uint64         `json:"min_timestamp,omitempty"` // Earliest block time in Unix seconds (0 = any)
+	MaxTimestamp   uint64         `json:"max_timestamp,omitempty"` // Latest block time in Unix seconds (0 = any)
+	RevertingTxIDs []string       `json:"reverting_tx_ids,omitempty"`
+	ReceivedAt     time.Time      `json:"received_at"`
+}
+
+// CanRevert reports whether the transaction with the given ID may revert
+// without failing the bundle
+func (b *Bundle) CanRevert(txID string) bool {
+	for _, id := range b.RevertingTxIDs {
+		if id == txID {
+			return true
+		}
+	}
+	return false
+}
+
+// Eligible reports whether the bundle may be included in a block with the given time
+func (b *Bundle) Eligible(t time.Time) bool {
+	unix := uint64(t.Unix())
+	if b.MinTimestamp > 0 && unix < b.MinTimestamp {
+		return false
+	}
+	if b.MaxTimestamp > 0 && unix > b.MaxTimestamp {
+		return false
+	}
+	return true
+}
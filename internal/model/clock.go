@@ -0,0 +1,72 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Clock tells the time and schedules timers. The mempool and the block
+// processor take one, so tests can replace the wall clock with a
+// deterministic clock.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer // Calls f in its own goroutine once d has passed
+}
+
+// Timer is a timer scheduled by a Clock
+type Timer interface {
+	Stop() bool // Prevents the call, reporting false if it already happened or was stopped
+}
+
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc calls f after d
+func (SystemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// IDGenerator generates the IDs of new transactions from their contents
+type IDGenerator interface {
+	TransactionID(content []byte) string
+}
+
+// TimeIDs hashes the contents of a transaction with the current time of its
+// clock, so transactions with the same contents get distinct IDs
+type TimeIDs struct {
+	Clock Clock
+}
+
+// TransactionID returns the hex-encoded SHA-256 hash of the contents and the time
+func (g TimeIDs) TransactionID(content []byte) string {
+	hash := sha256.Sum256(append(content, []byte(g.Clock.Now().String())...))
+	return hex.EncodeToString(hash[:])
+}
+
+// Factory creates transactions and blocks with the time of its clock and the
+// IDs of its generator
+type Factory struct {
+	Clock Clock
+	IDs   IDGenerator
+}
+
+// NewFactory creates a factory; a nil clock is the wall clock and a nil
+// generator hashes the contents with the time of the clock
+func NewFactory(clock Clock, ids IDGenerator) *Factory {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	if ids == nil {
+		ids = TimeIDs{Clock: clock}
+	}
+	return &Factory{Clock: clock, IDs: ids}
+}
+
+// DefaultFactory creates transactions and blocks with the wall clock
+var DefaultFactory = NewFactory(nil, nil)
@@ -0,0 +1,24 @@
+package model
+
+// Receipt status values
+const (
+	ReceiptStatusFailed     = 0
+	ReceiptStatusSuccessful = 1
+)
+
+// Receipt is the result of executing a transaction
+type Receipt struct {
+	Status            uint64 `json:"status"`   // 1 if the transaction succeeded, 0 if it reverted
+	GasUsed           uint64 `json:"gas_used"` // Gas used by the transaction
+	CumulativeGasUsed uint64 `json:"cumulative_gas_used"`
+	ContractAddress   string `json:"contract_address,omitempty"` // Address of the created contract
+	Logs              []*Log `json:"logs,omitempty"`
+	Error             string `json:"error,omitempty"` // Reason the execution failed
+}
+
+// Log is an event emitted during the execution of a transaction
+type Log struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    []byte   `json:"data"`
+}
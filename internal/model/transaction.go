@@ -1,10 +1,10 @@
 package model
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"math/big"
 	"time"
+
+	"flashblock/pkg/extension"
 )
 
 // Transaction represents a single transaction in the system with Ethereum-compatible fields
@@ -22,24 +22,30 @@ type Transaction struct {
 	GasLimit uint64   `json:"gas_limit"` // Gas limit
 	Nonce    uint64   `json:"nonce"`     // Transaction nonce
 	RawData  string   `json:"raw_data"`  // Original raw transaction data
+
+	BlobHashes []string `json:"blob_hashes,omitempty"` // Versioned hashes of the blobs of an EIP-4844 transaction
 }
 
 // NewTransaction creates a new transaction with the given data and priority
+// with the wall clock
 func NewTransaction(data []byte, priority int) *Transaction {
-	// Generate a transaction ID by hashing the data and timestamp
-	hash := sha256.Sum256(append(data, []byte(time.Now().String())...))
+	return DefaultFactory.NewTransaction(data, priority)
+}
 
+// NewTransaction creates a new transaction with the given data and priority
+func (f *Factory) NewTransaction(data []byte, priority int) *Transaction {
 	return &Transaction{
-		ID:        hex.EncodeToString(hash[:]),
+		ID:        f.IDs.TransactionID(data),
 		Data:      data,
 		Priority:  priority,
-		Timestamp: time.Now(),
+		Timestamp: f.Clock.Now(),
 		Value:     new(big.Int),
 		GasPrice:  new(big.Int),
 	}
 }
 
-// NewEthereumTransaction creates a new transaction from Ethereum transaction data
+// NewEthereumTransaction creates a new transaction from Ethereum transaction
+// data with the wall clock
 func NewEthereumTransaction(
 	from string,
 	to string,
@@ -50,11 +56,24 @@ func NewEthereumTransaction(
 	data []byte,
 	rawData string,
 ) *Transaction {
-	// Generate a transaction ID by hashing the data and timestamp
-	hashInput := append(data, []byte(time.Now().String())...)
-	hashInput = append(hashInput, []byte(from)...)
-	hashInput = append(hashInput, []byte(to)...)
-	hash := sha256.Sum256(hashInput)
+	return DefaultFactory.NewEthereumTransaction(from, to, value, gasPrice, gasLimit, nonce, data, rawData)
+}
+
+// NewEthereumTransaction creates a new transaction from Ethereum transaction data
+func (f *Factory) NewEthereumTransaction(
+	from string,
+	to string,
+	value *big.Int,
+	gasPrice *big.Int,
+	gasLimit uint64,
+	nonce uint64,
+	data []byte,
+	rawData string,
+) *Transaction {
+	// The ID covers the data and both addresses
+	content := append([]byte{}, data...)
+	content = append(content, []byte(from)...)
+	content = append(content, []byte(to)...)
 
 	// Set priority based on gas price
 	priority := 0
@@ -66,10 +85,10 @@ func NewEthereumTransaction(
 	}
 
 	return &Transaction{
-		ID:        hex.EncodeToString(hash[:]),
+		ID:        f.IDs.TransactionID(content),
 		Data:      data,
 		Priority:  priority,
-		Timestamp: time.Now(),
+		Timestamp: f.Clock.Now(),
 		From:      from,
 		To:        to,
 		Value:     value,
@@ -79,3 +98,27 @@ func NewEthereumTransaction(
 		RawData:   rawData,
 	}
 }
+
+// ExtensionView returns the view of the transaction given to extensions
+func (tx *Transaction) ExtensionView() *extension.Transaction {
+	return &extension.Transaction{
+		ID:        tx.ID,
+		Data:      tx.Data,
+		Priority:  tx.Priority,
+		Timestamp: tx.Timestamp,
+		From:      tx.From,
+		To:        tx.To,
+		Value:     copyBig(tx.Value),
+		GasPrice:  copyBig(tx.GasPrice),
+		GasLimit:  tx.GasLimit,
+		Nonce:     tx.Nonce,
+	}
+}
+
+// copyBig returns a copy of a number, or zero if it is nil
+func copyBig(x *big.Int) *big.Int {
+	if x == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(x)
+}
@@ -0,0 +1,204 @@
+package model
+
+import (
+	"time"
+
+	"flashblock/pkg/codec"
+)
+
+// EncodeTransaction returns the canonical encoding of a transaction
+func EncodeTransaction(tx *Transaction) ([]byte, error) {
+	return codec.EncodeTransaction(tx.Wire())
+}
+
+// DecodeTransaction decodes a transaction from its canonical encoding
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	wire, err := codec.DecodeTransaction(data)
+	if err != nil {
+		return nil, err
+	}
+	return TransactionFromWire(wire), nil
+}
+
+// EncodeBlock returns the canonical encoding of a block
+func EncodeBlock(block *Block) ([]byte, error) {
+	return codec.EncodeBlock(block.Wire())
+}
+
+// DecodeBlock decodes a block from its canonical encoding. The ID is not
+// verified; see VerifyBlockID.
+func DecodeBlock(data []byte) (*Block, error) {
+	wire, err := codec.DecodeBlock(data)
+	if err != nil {
+		return nil, err
+	}
+	return BlockFromWire(wire), nil
+}
+
+// BlockID returns the ID of a block derived from its contents according to
+// the version of the block
+func BlockID(block *Block) (string, error) {
+	return codec.BlockID(block.Wire())
+}
+
+// VerifyBlockID checks that the ID of a block matches its contents
+func VerifyBlockID(block *Block) error {
+	return codec.VerifyBlockID(block.Wire())
+}
+
+// Wire returns the wire type of the transaction
+func (tx *Transaction) Wire() *codec.Transaction {
+	return &codec.Transaction{
+		ID:         tx.ID,
+		Data:       tx.Data,
+		Priority:   uint64(int64(tx.Priority)),
+		Timestamp:  codec.Nanos(tx.Timestamp),
+		From:       tx.From,
+		To:         tx.To,
+		Value:      tx.Value,
+		GasPrice:   tx.GasPrice,
+		GasLimit:   tx.GasLimit,
+		Nonce:      tx.Nonce,
+		RawData:    tx.RawData,
+		BlobHashes: tx.BlobHashes,
+	}
+}
+
+// TransactionFromWire returns the transaction of a wire type
+func TransactionFromWire(tx *codec.Transaction) *Transaction {
+	return &Transaction{
+		ID:         tx.ID,
+		Data:       tx.Data,
+		Priority:   int(int64(tx.Priority)),
+		Timestamp:  codec.Time(tx.Timestamp),
+		From:       tx.From,
+		To:         tx.To,
+		Value:      tx.Value,
+		GasPrice:   tx.GasPrice,
+		GasLimit:   tx.GasLimit,
+		Nonce:      tx.Nonce,
+		RawData:    tx.RawData,
+		BlobHashes: tx.BlobHashes,
+	}
+}
+
+// Wire returns the wire type of the block
+func (b *Block) Wire() *codec.Block {
+	wire := &codec.Block{
+		Version:  b.Version,
+		ID:       b.ID,
+		Header:   b.wireHeader(),
+		TDXQuote: b.TDXQuote,
+	}
+	for _, r := range b.Receipts {
+		receipt := &codec.Receipt{
+			Status:            r.Status,
+			GasUsed:           r.GasUsed,
+			CumulativeGasUsed: r.CumulativeGasUsed,
+			ContractAddress:   r.ContractAddress,
+			Error:             r.Error,
+		}
+		for _, l := range r.Logs {
+			receipt.Logs = append(receipt.Logs, &codec.Log{Address: l.Address, Topics: l.Topics, Data: l.Data})
+		}
+		wire.Receipts = append(wire.Receipts, receipt)
+	}
+	if ref := b.DA; ref != nil {
+		wire.DA = &codec.DAReference{
+			Layer:      ref.Layer,
+			Height:     ref.Height,
+			Commitment: ref.Commitment,
+			Namespace:  ref.Namespace,
+			TxHash:     ref.TxHash,
+			BlobHashes: ref.BlobHashes,
+			FirstBlock: ref.FirstBlock,
+			LastBlock:  ref.LastBlock,
+		}
+	}
+	return wire
+}
+
+// wireHeader returns the wire type of the fields the block ID commits to
+func (b *Block) wireHeader() *codec.Header {
+	h := &codec.Header{
+		Number:        b.Number,
+		Timestamp:     codec.Nanos(b.Timestamp),
+		PrevBlockID:   b.PrevBlockID,
+		StateRoot:     b.StateRoot,
+		GasLimit:      b.GasLimit,
+		GasUsed:       b.GasUsed,
+		FeeRecipient:  b.FeeRecipient,
+		Transactions:  make([]*codec.Transaction, len(b.Transactions)),
+		EncryptionKey: b.EncryptionKey,
+		Commitments:   b.Commitments,
+	}
+	for i, tx := range b.Transactions {
+		h.Transactions[i] = tx.Wire()
+	}
+	if p := b.TimeProof; p != nil {
+		h.TimeProof = &codec.TimeProof{
+			Source:      p.Source,
+			Servers:     p.Servers,
+			Synced:      codec.Nanos(p.Synced),
+			Uncertainty: uint64(p.Uncertainty),
+		}
+	}
+	return h
+}
+
+// BlockFromWire returns the block of a wire type
+func BlockFromWire(wire *codec.Block) *Block {
+	h := wire.Header
+	block := &Block{
+		Version:       wire.Version,
+		ID:            wire.ID,
+		Number:        h.Number,
+		Timestamp:     codec.Time(h.Timestamp),
+		PrevBlockID:   h.PrevBlockID,
+		StateRoot:     h.StateRoot,
+		GasLimit:      h.GasLimit,
+		GasUsed:       h.GasUsed,
+		FeeRecipient:  h.FeeRecipient,
+		EncryptionKey: h.EncryptionKey,
+		Commitments:   h.Commitments,
+		TDXQuote:      wire.TDXQuote,
+	}
+	if p := h.TimeProof; p != nil {
+		block.TimeProof = &TimeProof{
+			Source:      p.Source,
+			Servers:     p.Servers,
+			Synced:      codec.Time(p.Synced),
+			Uncertainty: time.Duration(p.Uncertainty),
+		}
+	}
+	block.Transactions = make([]*Transaction, len(h.Transactions))
+	for i, tx := range h.Transactions {
+		block.Transactions[i] = TransactionFromWire(tx)
+	}
+	for _, r := range wire.Receipts {
+		receipt := &Receipt{
+			Status:            r.Status,
+			GasUsed:           r.GasUsed,
+			CumulativeGasUsed: r.CumulativeGasUsed,
+			ContractAddress:   r.ContractAddress,
+			Error:             r.Error,
+		}
+		for _, l := range r.Logs {
+			receipt.Logs = append(receipt.Logs, &Log{Address: l.Address, Topics: l.Topics, Data: l.Data})
+		}
+		block.Receipts = append(block.Receipts, receipt)
+	}
+	if ref := wire.DA; ref != nil {
+		block.DA = &DAReference{
+			Layer:      ref.Layer,
+			Height:     ref.Height,
+			Commitment: ref.Commitment,
+			Namespace:  ref.Namespace,
+			TxHash:     ref.TxHash,
+			BlobHashes: ref.BlobHashes,
+			FirstBlock: ref.FirstBlock,
+			LastBlock:  ref.LastBlock,
+		}
+	}
+	return block
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"flashblock/pkg/codec"
+)
+
+func testBlock() *Block {
+	at := time.Date(2024, 1, 1, 0, 0, 1, 500, time.UTC)
+	tx := &Transaction{
+		ID:        "0x3f1c",
+		Data:      []byte{1, 2},
+		Priority:  -3,
+		Timestamp: at.Add(-time.Second),
+		From:      "0xfe3b557e8fb62b89f4916b721be55ceb828dbd73",
+		To:        "0x627306090abab3a6e1400e9345bc60c78a8bef57",
+		Value:     big.NewInt(10),
+		GasPrice:  big.NewInt(2),
+		GasLimit:  21000,
+		Nonce:     1,
+		RawData:   "0xf86c01",
+	}
+	block := &Block{
+		Version:       codec.Version,
+		Number:        7,
+		Transactions:  []*Transaction{tx},
+		Timestamp:     at,
+		PrevBlockID:   "9c56",
+		StateRoot:     "0x56e8",
+		GasLimit:      30_000_000,
+		GasUsed:       21000,
+		FeeRecipient:  "0x0000000000000000000000000000000000000001",
+		Receipts:      []*Receipt{{Status: ReceiptStatusSuccessful, GasUsed: 21000, CumulativeGasUsed: 21000}},
+		TDXQuote:      []byte{3},
+		EncryptionKey: "0x01",
+		Commitments:   []string{"0x02"},
+		TimeProof: &TimeProof{
+			Source:      "roughtime",
+			Servers:     []string{"time.example"},
+			Synced:      at.Add(-time.Minute),
+			Uncertainty: 10 * time.Millisecond,
+		},
+	}
+	id, err := BlockID(block)
+	if err != nil {
+		panic(err)
+	}
+	block.ID = id
+	return block
+}
+
+func TestBlockRoundTrip(t *testing.T) {
+	block := testBlock()
+	data, err := EncodeBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeBlock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, block) {
+		t.Errorf("decoded %+v, want %+v", decoded, block)
+	}
+	if err := VerifyBlockID(decoded); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransactionRoundTrip(t *testing.T) {
+	tx := testBlock().Transactions[0]
+	data, err := EncodeTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeTransaction(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, tx) {
+		t.Errorf("decoded %+v, want %+v", decoded, tx)
+	}
+}
+
+func TestLegacyID(t *testing.T) {
+	// Version 0 blocks keep the IDs they were stored with
+	block := testBlock()
+	block.Version = 0
+	block.TimeProof = nil
+	block.ID = block.LegacyID()
+	if err := VerifyBlockID(block); err != nil {
+		t.Fatal(err)
+	}
+	if id := codec.LegacyID(block.Wire().Header); id != block.ID {
+		t.Errorf("codec legacy ID = %s, want %s", id, block.ID)
+	}
+}
@@ -0,0 +1,207 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// announceBlock publishes a sealed or imported block unless it was received
+// from or published to the peers already
+func (n *Node) announceBlock(block *model.Block) {
+	if !n.seenBlocks.add(idHash(block.ID)) {
+		return
+	}
+	data, err := model.EncodeBlock(block)
+	if err != nil {
+		logging.Errorf("Failed to encode block %d for gossip: %v", block.Number, err)
+		return
+	}
+	if err := n.blkTopic.Publish(n.ctx, data); err != nil {
+		logging.Debugf("Failed to publish block %d: %v", block.Number, err)
+	}
+}
+
+// validateBlock imports a block published by a peer. Only the blocks this node
+// imported are forwarded to the other peers, so a node never relays a block
+// it would not follow itself. A block that does not extend the chain head
+// means blocks were missed, and they are requested from the peer.
+func (n *Node) validateBlock(_ context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	if msg.GetFrom() == n.host.ID() {
+		return pubsub.ValidationAccept
+	}
+	if !n.importsFrom(msg.GetFrom(), from) {
+		return pubsub.ValidationIgnore
+	}
+
+	block, err := model.DecodeBlock(msg.Data)
+	if err != nil {
+		logging.Debugf("Invalid block from peer %s: %v", from, err)
+		n.adjustScore(from, -invalidPenalty)
+		return pubsub.ValidationReject
+	}
+	if !n.seenBlocks.add(idHash(block.ID)) {
+		return pubsub.ValidationIgnore
+	}
+	if !n.importBlock(from, block, true) {
+		return pubsub.ValidationIgnore
+	}
+	return pubsub.ValidationAccept
+}
+
+// importBlock imports a block received from a peer and reports whether it
+// extended the chain
+func (n *Node) importBlock(from peer.ID, block *model.Block, announced bool) bool {
+	latestID, latestNumber := n.processor.LatestBlock()
+	if latestID != "" && block.Number <= latestNumber {
+		return false
+	}
+
+	err := n.processor.ImportBlock(block)
+	switch {
+	case err == nil:
+		n.adjustScore(from, usefulReward)
+		logging.Debugf("Imported block %d (%s) from peer %s", block.Number, block.ID, from)
+		return true
+	case errors.Is(err, processor.ErrUnknownParent):
+		if !announced {
+			logging.Warnf("Blocks from peer %s do not extend the local chain: %v", from, err)
+		} else if block.Number > latestNumber+1 {
+			n.requestBlocks(from)
+		}
+	default:
+		logging.Warnf("Invalid block %d from peer %s: %v", block.Number, from, err)
+		n.adjustScore(from, -invalidPenalty)
+	}
+	return false
+}
+
+// importsFrom reports whether a block published by author and received from
+// a peer is imported. A peer could otherwise make the node follow a chain it
+// made up, so without attestation checks only the blocks published or
+// forwarded by the configured peers are imported. A configured peer only
+// forwards the blocks it imported itself.
+func (n *Node) importsFrom(author, from peer.ID) bool {
+	if !n.config.ImportBlocks {
+		return false
+	}
+	_, trustedAuthor := n.static[author]
+	_, trustedPeer := n.static[from]
+	return n.config.VerifiedBlocks || trustedAuthor || trustedPeer
+}
+
+// requestBlocks fetches the retained blocks of a peer after the chain head,
+// unless a request to the peer is outstanding
+func (n *Node) requestBlocks(id peer.ID) {
+	n.mu.RLock()
+	p, ok := n.peers[id]
+	n.mu.RUnlock()
+	if !ok || !p.syncing.CompareAndSwap(false, true) {
+		return
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		defer p.syncing.Store(false)
+		if err := n.fetchBlocks(id); err != nil {
+			logging.Debugf("Failed to fetch blocks from peer %s: %v", id, err)
+		}
+	}()
+}
+
+// fetchBlocks requests the blocks after the chain head from a peer and imports
+// them. An empty chain is anchored at the oldest block the peer retains.
+func (n *Node) fetchBlocks(id peer.ID) error {
+	ctx, cancel := context.WithTimeout(n.ctx, syncTimeout)
+	defer cancel()
+	stream, err := n.host.NewStream(ctx, id, n.syncProtocol())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(syncTimeout))
+
+	var from uint64
+	if latestID, latestNumber := n.processor.LatestBlock(); latestID != "" {
+		from = latestNumber + 1
+	}
+	if err := rlp.Encode(stream, from); err != nil {
+		return err
+	}
+	if err := stream.CloseWrite(); err != nil {
+		return err
+	}
+
+	s := rlp.NewStream(stream, maxMessageSize)
+	for {
+		var batch [][]byte
+		if err := s.Decode(&batch); err == io.EOF {
+			return nil
+		} else if err != nil {
+			n.adjustScore(id, -invalidPenalty)
+			return err
+		}
+		for _, data := range batch {
+			block, err := model.DecodeBlock(data)
+			if err != nil {
+				n.adjustScore(id, -invalidPenalty)
+				return err
+			}
+			n.seenBlocks.add(idHash(block.ID))
+			latestID, latestNumber := n.processor.LatestBlock()
+			if latestID != "" && block.Number <= latestNumber {
+				continue
+			}
+			if !n.importBlock(id, block, false) {
+				return nil
+			}
+		}
+	}
+}
+
+// serveBlocks answers a block sync request with the retained blocks from the
+// requested number, in messages of up to maxBlocksPerMsg blocks
+func (n *Node) serveBlocks(stream network.Stream) {
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(syncTimeout))
+
+	var from uint64
+	if err := rlp.NewStream(stream, 16).Decode(&from); err != nil {
+		stream.Reset()
+		return
+	}
+
+	var batch [][]byte
+	for _, block := range n.processor.GetProcessedBlocks() {
+		if block.Number < from {
+			continue
+		}
+		data, err := model.EncodeBlock(block)
+		if err != nil {
+			stream.Reset()
+			return
+		}
+		batch = append(batch, data)
+
+		if len(batch) == maxBlocksPerMsg {
+			if err := rlp.Encode(stream, batch); err != nil {
+				return
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		rlp.Encode(stream, batch)
+	}
+}
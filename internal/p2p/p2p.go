@@ -0,0 +1,712 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flashblock/internal/eth"
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/mempool"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+	"flashblock/internal/version"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/control"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Protocol parameters
+const (
+	maxMessageSize  = 10 * 1024 * 1024
+	eventQueueSize  = 4096  // Admitted transactions waiting to be published
+	blockQueueSize  = 64    // Sealed blocks waiting to be published
+	maxBlocksPerMsg = 64    // Blocks per message of a block sync response
+	maxSeenHashes   = 65536 // Transactions remembered by the node
+	maxKnownBlocks  = 1024  // Blocks remembered by the node
+	syncTimeout     = 30 * time.Second
+	redialInterval  = 15 * time.Second   // Reconnection attempts to the configured peers
+	mdnsService     = "_flashblock._udp" // DNS-SD service nodes announce themselves with on the local network
+)
+
+// Peer scoring. Every peer starts at zero; useful messages raise the score up
+// to maxScore and invalid ones lower it. Peers falling below minScore are
+// disconnected.
+const (
+	maxScore       = 100
+	minScore       = -100
+	usefulReward   = 1  // A new transaction or block
+	rejectPenalty  = 5  // A transaction the mempool rejected
+	invalidPenalty = 25 // An undecodable message or a block that fails to import
+)
+
+// Errors
+var (
+	ErrChainMismatch = errors.New("peer is on a different chain")
+	errUnsigned      = errors.New("transaction without signed raw data")
+)
+
+// Config holds configuration for the gossip node
+type Config struct {
+	ListenAddr string            // TCP listen address, e.g. ":30303"
+	Peers      []string          // Multiaddrs, with the peer ID, of the peers to stay connected to
+	Bootnodes  []string          // Multiaddrs, with the peer ID, of the nodes other peers are learned from
+	Discovery  bool              // Learn peers from the bootnodes and the other peers through peer exchange
+	MDNS       bool              // Find peers on the local network through mDNS
+	MaxPeers   int               // Maximum number of connected peers
+	Key        *ecdsa.PrivateKey // Node key defining the peer ID
+	ChainID    uint64            // Peers must be on the same chain
+	Genesis    common.Hash       // Genesis state root, part of the topic and protocol names
+
+	// ImportBlocks imports the blocks announced by peers, as done by nodes
+	// that follow a builder; other nodes only announce their own blocks
+	ImportBlocks bool
+
+	// VerifiedBlocks reports that the processor verifies the attestation of
+	// imported blocks, so they are accepted from any peer. Otherwise blocks
+	// are only imported from the configured Peers.
+	VerifiedBlocks bool
+
+	// Included reports whether a transaction is already in a block; such
+	// transactions are not admitted again (optional)
+	Included func(txID string) bool
+}
+
+// PeerInfo describes a connected peer
+type PeerInfo struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"` // Client version of the peer
+	Addr        string    `json:"addr"` // Multiaddr of the connection, with the peer ID
+	RemoteAddr  string    `json:"remote_addr"`
+	Inbound     bool      `json:"inbound"`
+	Static      bool      `json:"static"` // Configured in the peer list
+	Score       int64     `json:"score"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// Node gossips admitted transactions and sealed blocks between flashblock
+// instances over libp2p GossipSub. Every transaction admitted to the local
+// mempool is published on the transaction topic of the chain, and
+// transactions received on it are admitted to the mempool before GossipSub
+// forwards them, so they spread to the whole network. Messages are identified
+// by the SHA-256 hash of their content, so each is handled once. Blocks are
+// published on the block topic as they are sealed or imported.
+type Node struct {
+	config     *Config
+	mempool    *mempool.Mempool
+	processor  *processor.BlockProcessor
+	host       host.Host
+	pubsub     *pubsub.PubSub
+	txTopic    *pubsub.Topic
+	blkTopic   *pubsub.Topic
+	static     map[peer.ID]peer.AddrInfo // Configured peers, whose blocks are imported
+	bootnodes  []peer.AddrInfo
+	seen       *hashSet // IDs of the transactions received from or published to peers
+	seenBlocks *hashSet // IDs of the blocks received from or published to peers
+
+	mu    sync.RWMutex // Protects peers
+	peers map[peer.ID]*peerState
+
+	ctx          context.Context // Cancelled by Stop
+	cancel       context.CancelFunc
+	cancelPubsub context.CancelFunc // Stops GossipSub once the goroutines publishing to it returned
+	mdns         mdns.Service       // Local network discovery (nil if disabled)
+	wg           sync.WaitGroup
+}
+
+// New creates a gossip node for the mempool and the blocks of the processor
+func New(mp *mempool.Mempool, bp *processor.BlockProcessor, config *Config) (*Node, error) {
+	peers, err := ParseAddrs(config.Peers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer: %v", err)
+	}
+	bootnodes, err := ParseAddrs(config.Bootnodes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootnode: %v", err)
+	}
+	listen, err := listenAddr(config.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	key, err := p2pcrypto.UnmarshalSecp256k1PrivateKey(crypto.FromECDSA(config.Key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid node key: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Node{
+		config:     config,
+		mempool:    mp,
+		processor:  bp,
+		static:     make(map[peer.ID]peer.AddrInfo),
+		bootnodes:  bootnodes,
+		seen:       newHashSet(maxSeenHashes),
+		seenBlocks: newHashSet(maxKnownBlocks),
+		peers:      make(map[peer.ID]*peerState),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	for _, info := range peers {
+		n.static[info.ID] = info
+	}
+
+	n.host, err = libp2p.New(
+		libp2p.Identity(key),
+		libp2p.ListenAddrs(listen),
+		libp2p.Transport(tcp.NewTCPTransport),
+		libp2p.UserAgent(version.ClientVersion()),
+		libp2p.ConnectionGater((*gater)(n)),
+	)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create p2p host: %v", err)
+	}
+	return n, nil
+}
+
+// ParseAddrs parses multiaddrs that end with the peer ID, e.g.
+// /ip4/10.0.0.2/tcp/30303/p2p/16Uiu2HAm...
+func ParseAddrs(addrs []string) ([]peer.AddrInfo, error) {
+	infos := make([]peer.AddrInfo, 0, len(addrs))
+	for _, addr := range addrs {
+		info, err := peer.AddrInfoFromString(addr)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", addr, err)
+		}
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+// listenAddr converts a TCP listen address to a multiaddr, listening on all
+// interfaces if the host is empty
+func listenAddr(addr string) (ma.Multiaddr, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %v", addr, err)
+	}
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid listen address %q: host must be an IP address", addr)
+	}
+	proto := "ip4"
+	if ip.To4() == nil {
+		proto = "ip6"
+	}
+	return ma.NewMultiaddr(fmt.Sprintf("/%s/%s/tcp/%s", proto, host, port))
+}
+
+// topic returns the name of a topic of the chain. Nodes on another chain use
+// other topics, so they never exchange messages.
+func (n *Node) topic(name string) string {
+	return fmt.Sprintf("/flashblock/%d/%s/%s/1", n.config.ChainID, n.config.Genesis.Hex(), name)
+}
+
+// syncProtocol returns the protocol blocks are requested with, which is also
+// how peers on the same chain are recognized
+func (n *Node) syncProtocol() protocol.ID {
+	return protocol.ID(n.topic("blocks/sync"))
+}
+
+// messageID identifies a message by the hash of its content, so the same
+// transaction or block published by several nodes is handled once
+func messageID(msg *pb.Message) string {
+	hash := sha256.Sum256(msg.Data)
+	return string(hash[:])
+}
+
+// Start listens for peers, connects to the configured peers and starts gossiping
+func (n *Node) Start() error {
+	// Peers are tracked once they are identified as nodes of the same chain
+	sub, err := n.host.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted))
+	if err != nil {
+		return err
+	}
+	n.host.Network().Notify(&network.NotifyBundle{DisconnectedF: n.disconnected})
+	n.host.SetStreamHandler(n.syncProtocol(), n.serveBlocks)
+
+	direct := make([]peer.AddrInfo, 0, len(n.static))
+	for _, info := range n.static {
+		direct = append(direct, info)
+	}
+	// A topic blocks publishing forever once GossipSub is stopped, so it
+	// outlives the goroutines of the node
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancelPubsub = cancel
+	n.pubsub, err = pubsub.NewGossipSub(ctx, n.host,
+		pubsub.WithMessageIdFn(messageID),
+		pubsub.WithMaxMessageSize(maxMessageSize),
+		pubsub.WithPeerExchange(n.config.Discovery),
+		pubsub.WithDirectPeers(direct),
+	)
+	if err != nil {
+		sub.Close()
+		return fmt.Errorf("failed to start gossip: %v", err)
+	}
+	if n.txTopic, err = n.join("transactions", n.validateTransaction); err != nil {
+		sub.Close()
+		return err
+	}
+	if n.blkTopic, err = n.join("blocks", n.validateBlock); err != nil {
+		sub.Close()
+		return err
+	}
+
+	if n.config.MDNS {
+		n.mdns = mdns.NewMdnsService(n.host, mdnsService, (*mdnsNotifee)(n))
+		if err := n.mdns.Start(); err != nil {
+			sub.Close()
+			return fmt.Errorf("failed to start mDNS discovery: %v", err)
+		}
+	}
+
+	n.wg.Add(3)
+	go func() {
+		defer n.wg.Done()
+		defer sub.Close()
+		for {
+			select {
+			case ev := <-sub.Out():
+				n.identified(ev.(event.EvtPeerIdentificationCompleted))
+			case <-n.ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		defer n.wg.Done()
+		n.dialLoop()
+	}()
+	go func() {
+		defer n.wg.Done()
+		n.publishLoop()
+	}()
+	return nil
+}
+
+// join joins a topic of the chain with its validator, and subscribes to it so
+// this node is part of its mesh. The messages are handled by the validator.
+func (n *Node) join(name string, validate func(context.Context, peer.ID, *pubsub.Message) pubsub.ValidationResult) (*pubsub.Topic, error) {
+	topic := n.topic(name)
+	if err := n.pubsub.RegisterTopicValidator(topic, validate); err != nil {
+		return nil, fmt.Errorf("failed to register validator of %s: %v", topic, err)
+	}
+	t, err := n.pubsub.Join(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join %s: %v", topic, err)
+	}
+	sub, err := t.Subscribe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %v", topic, err)
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		defer sub.Cancel()
+		for {
+			if _, err := sub.Next(n.ctx); err != nil {
+				return
+			}
+		}
+	}()
+	return t, nil
+}
+
+// publishLoop publishes the admitted transactions and the sealed blocks
+func (n *Node) publishLoop() {
+	bus := n.processor.Events()
+	txs := make(chan events.TxAdmitted, eventQueueSize)
+	txSub := bus.TxAdmitted.Subscribe(txs)
+	defer txSub.Unsubscribe()
+	blocks := make(chan events.BlockSealed, blockQueueSize)
+	blockSub := bus.BlockSealed.Subscribe(blocks)
+	defer blockSub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-txs:
+			n.broadcast(ev.Tx)
+		case ev := <-blocks:
+			n.announceBlock(ev.Block)
+		case <-txSub.Err():
+			return
+		case <-blockSub.Err():
+			return
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+// dialLoop connects to the bootnodes once and keeps the connections to the
+// configured peers, redialing them when they drop
+func (n *Node) dialLoop() {
+	for _, info := range n.bootnodes {
+		go n.dial(info)
+	}
+	ticker := time.NewTicker(redialInterval)
+	defer ticker.Stop()
+	for {
+		for _, info := range n.static {
+			if n.host.Network().Connectedness(info.ID) != network.Connected {
+				go n.dial(info)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+// dial connects to a peer
+func (n *Node) dial(info peer.AddrInfo) {
+	ctx, cancel := context.WithTimeout(n.ctx, redialInterval)
+	defer cancel()
+	if err := n.host.Connect(ctx, info); err != nil {
+		logging.Debugf("Failed to connect to p2p peer %s: %v", info.ID, err)
+	}
+}
+
+// Stop disconnects all peers and stops the node
+func (n *Node) Stop() {
+	if n.mdns != nil {
+		n.mdns.Close()
+	}
+	n.cancel()
+	n.wg.Wait()
+	if n.cancelPubsub != nil {
+		n.cancelPubsub()
+	}
+	n.host.Close()
+}
+
+// ID returns the peer ID of the node
+func (n *Node) ID() string {
+	return n.host.ID().String()
+}
+
+// Addrs returns the multiaddrs other nodes use to connect to this node
+func (n *Node) Addrs() []string {
+	addrs := make([]string, 0, len(n.host.Addrs()))
+	for _, addr := range n.host.Addrs() {
+		addrs = append(addrs, fmt.Sprintf("%s/p2p/%s", addr, n.host.ID()))
+	}
+	return addrs
+}
+
+// PeerCount returns the number of connected peers
+func (n *Node) PeerCount() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return len(n.peers)
+}
+
+// Peers describes the connected peers, ordered by ID
+func (n *Node) Peers() []PeerInfo {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	infos := make([]PeerInfo, 0, len(n.peers))
+	for _, p := range n.peers {
+		info := p.info
+		info.Score = p.score.Load()
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// identified starts tracking a peer once it is identified. Peers that do not
+// serve the block sync protocol of the chain are on another chain, or are no
+// flashblock nodes, and are disconnected.
+func (n *Node) identified(ev event.EvtPeerIdentificationCompleted) {
+	supported := false
+	for _, id := range ev.Protocols {
+		supported = supported || id == n.syncProtocol()
+	}
+	if !supported {
+		logging.Debugf("Disconnecting p2p peer %s: %v", ev.Peer, ErrChainMismatch)
+		n.host.Network().ClosePeer(ev.Peer)
+		return
+	}
+
+	n.mu.Lock()
+	if _, ok := n.peers[ev.Peer]; ok {
+		n.mu.Unlock()
+		return
+	}
+	_, static := n.static[ev.Peer]
+	p := &peerState{info: PeerInfo{
+		ID:          ev.Peer.String(),
+		Addr:        fmt.Sprintf("%s/p2p/%s", ev.Conn.RemoteMultiaddr(), ev.Peer),
+		RemoteAddr:  ev.Conn.RemoteMultiaddr().String(),
+		Inbound:     ev.Conn.Stat().Direction == network.DirInbound,
+		Static:      static,
+		ConnectedAt: time.Now(),
+	}}
+	if agent, err := n.host.Peerstore().Get(ev.Peer, "AgentVersion"); err == nil {
+		p.info.Name, _ = agent.(string)
+	}
+	n.peers[ev.Peer] = p
+	n.mu.Unlock()
+	logging.Infof("P2P peer connected: %s (%s)", ev.Peer, p.info.RemoteAddr)
+
+	// Blocks sealed while this node was not connected are fetched from the peer
+	if n.importsFrom(ev.Peer, ev.Peer) {
+		n.requestBlocks(ev.Peer)
+	}
+}
+
+// disconnected stops tracking a peer once its last connection closes
+func (n *Node) disconnected(_ network.Network, conn network.Conn) {
+	id := conn.RemotePeer()
+	if n.host.Network().Connectedness(id) == network.Connected {
+		return
+	}
+	n.mu.Lock()
+	_, ok := n.peers[id]
+	delete(n.peers, id)
+	n.mu.Unlock()
+	if ok {
+		logging.Infof("P2P peer disconnected: %s", id)
+	}
+}
+
+// adjustScore changes the score of a peer, which cannot exceed maxScore, and
+// disconnects it once it falls below minScore
+func (n *Node) adjustScore(id peer.ID, delta int64) {
+	n.mu.RLock()
+	p, ok := n.peers[id]
+	n.mu.RUnlock()
+	if !ok {
+		return
+	}
+	for {
+		old := p.score.Load()
+		score := min(old+delta, maxScore)
+		if !p.score.CompareAndSwap(old, score) {
+			continue
+		}
+		if score < minScore {
+			logging.Infof("Disconnecting p2p peer %s: score too low (%d)", id, score)
+			go n.host.Network().ClosePeer(id)
+		}
+		return
+	}
+}
+
+// broadcast publishes an admitted transaction unless it was received from or
+// published to the peers already. Transactions are known by ID, as the time
+// stamped by each node makes the encoding of a transaction differ between nodes.
+func (n *Node) broadcast(tx *model.Transaction) {
+	if !n.seen.add(idHash(tx.ID)) {
+		return
+	}
+	data, err := model.EncodeTransaction(tx)
+	if err != nil {
+		logging.Errorf("Failed to encode transaction %s for gossip: %v", tx.ID, err)
+		return
+	}
+	if err := n.txTopic.Publish(n.ctx, data); err != nil {
+		logging.Debugf("Failed to publish transaction %s: %v", tx.ID, err)
+	}
+}
+
+// validateTransaction admits a transaction received from a peer. Only the
+// transactions the mempool admits are forwarded to the other peers.
+func (n *Node) validateTransaction(_ context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	if msg.GetFrom() == n.host.ID() {
+		return pubsub.ValidationAccept
+	}
+	tx, err := model.DecodeTransaction(msg.Data)
+	if err == nil {
+		tx, err = n.authenticate(tx)
+	}
+	if err != nil {
+		logging.Debugf("Invalid transaction from peer %s: %v", from, err)
+		n.adjustScore(from, -invalidPenalty)
+		return pubsub.ValidationReject
+	}
+	// Only authenticated transactions are remembered, so a forged transaction
+	// cannot shadow the genuine one with the same ID
+	if !n.seen.add(idHash(tx.ID)) {
+		return pubsub.ValidationIgnore
+	}
+	if n.config.Included != nil && n.config.Included(tx.ID) {
+		return pubsub.ValidationIgnore
+	}
+
+	switch err := n.mempool.Admit(tx); err {
+	case nil:
+		n.adjustScore(from, usefulReward)
+		return pubsub.ValidationAccept
+	case mempool.ErrDuplicate:
+		return pubsub.ValidationIgnore
+	default:
+		logging.Debugf("Transaction %s from peer %s not admitted: %v", tx.ID, from, err)
+		n.adjustScore(from, -rejectPenalty)
+		return pubsub.ValidationIgnore
+	}
+}
+
+// authenticate rebuilds a transaction received from a peer from its signed raw
+// data, so a peer cannot forge its sender, ID or any other field. Unsigned
+// transactions are rejected, as the peer could claim any sender for them.
+func (n *Node) authenticate(tx *model.Transaction) (*model.Transaction, error) {
+	if tx.RawData == "" {
+		return nil, errUnsigned
+	}
+	ethTx, err := eth.DecodeRawTransaction(tx.RawData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw transaction: %v", err)
+	}
+	signed, err := eth.ConvertToModelTransaction(n.mempool.Factory(), ethTx, tx.RawData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw transaction: %v", err)
+	}
+	if signed.From == "" {
+		return nil, errors.New("invalid transaction signature")
+	}
+	if signed.ID != tx.ID || !strings.EqualFold(signed.From, tx.From) {
+		return nil, fmt.Errorf("transaction %s from %s does not match its signed raw data (%s from %s)", tx.ID, tx.From, signed.ID, signed.From)
+	}
+	return signed, nil
+}
+
+// peerState is a connected node of the same chain
+type peerState struct {
+	info    PeerInfo
+	score   atomic.Int64
+	syncing atomic.Bool // Blocks were requested and not received yet
+}
+
+// mdnsNotifee connects to the nodes found on the local network. They are not
+// configured peers, so their blocks are only imported if blocks are verified.
+type mdnsNotifee Node
+
+// HandlePeerFound connects to a node found through mDNS unless the node has
+// all the peers it may have
+func (m *mdnsNotifee) HandlePeerFound(info peer.AddrInfo) {
+	n := (*Node)(m)
+	if info.ID == n.host.ID() || n.host.Network().Connectedness(info.ID) == network.Connected {
+		return
+	}
+	if n.config.MaxPeers > 0 && n.PeerCount() >= n.config.MaxPeers {
+		return
+	}
+	logging.Debugf("Found p2p peer %s through mDNS", info.ID)
+	n.dial(info)
+}
+
+// gater limits the number of connected peers; the configured peers are
+// always accepted
+type gater Node
+
+func (g *gater) InterceptPeerDial(peer.ID) bool               { return true }
+func (g *gater) InterceptAddrDial(peer.ID, ma.Multiaddr) bool { return true }
+func (g *gater) InterceptAccept(network.ConnMultiaddrs) bool  { return true }
+func (g *gater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+func (g *gater) InterceptSecured(dir network.Direction, id peer.ID, _ network.ConnMultiaddrs) bool {
+	n := (*Node)(g)
+	if _, ok := n.static[id]; ok || dir != network.DirInbound || n.config.MaxPeers <= 0 {
+		return true
+	}
+	return n.PeerCount() < n.config.MaxPeers
+}
+
+// idHash identifies a transaction or block in a hash set
+func idHash(id string) [32]byte {
+	return sha256.Sum256([]byte(id))
+}
+
+// hashSet is a set of hashes that forgets the oldest entries beyond its capacity
+type hashSet struct {
+	mu     sync.Mutex
+	hashes map[[32]byte]struct{}
+	order  [][32]byte // Ring buffer of hashes in insertion order
+	next   int
+}
+
+// newHashSet creates a set holding at most capacity hashes
+func newHashSet(capacity int) *hashSet {
+	return &hashSet{
+		hashes: make(map[[32]byte]struct{}, capacity),
+		order:  make([][32]byte, 0, capacity),
+	}
+}
+
+// add inserts a hash and reports whether it was new
+func (s *hashSet) add(hash [32]byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.hashes[hash]; ok {
+		return false
+	}
+	if len(s.order) < cap(s.order) {
+		s.order = append(s.order, hash)
+	} else {
+		delete(s.hashes, s.order[s.next])
+		s.order[s.next] = hash
+		s.next = (s.next + 1) % len(s.order)
+	}
+	s.hashes[hash] = struct{}{}
+	return true
+}
+
+// LoadOrCreateKey reads the node key from path, creating it on first use.
+// Without a path, a new key is generated for every start.
+func LoadOrCreateKey(path string) (*ecdsa.PrivateKey, error) {
+	if path == "" {
+		return crypto.GenerateKey()
+	}
+
+	key, err := crypto.LoadECDSA(path)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load node key: %v", err)
+	}
+
+	if key, err = crypto.GenerateKey(); err != nil {
+		return nil, err
+	}
+	if err := crypto.SaveECDSA(path, key); err != nil {
+		return nil, fmt.Errorf("failed to save node key: %v", err)
+	}
+	return key, nil
+}
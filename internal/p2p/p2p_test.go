@@ -0,0 +1,421 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"flashblock/internal/eth"
+	"flashblock/internal/mempool"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const testChainID = 1337
+
+// testNode is a started gossip node with its own mempool and processor
+type testNode struct {
+	*Node
+	mp *mempool.Mempool
+	bp *processor.BlockProcessor
+}
+
+// newTestNode creates a node on chainID listening on a free local port and
+// connected to peers; it is stopped when the test ends
+func newTestNode(t *testing.T, chainID uint64, importBlocks bool, peers ...*testNode) *testNode {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var addrs []string
+	for _, p := range peers {
+		addrs = append(addrs, p.Addrs()[0])
+	}
+	return startTestNode(t, &Config{
+		ListenAddr:   "127.0.0.1:0",
+		Peers:        addrs,
+		MaxPeers:     10,
+		Key:          key,
+		ChainID:      chainID,
+		Genesis:      common.HexToHash("0x01"),
+		ImportBlocks: importBlocks,
+	})
+}
+
+// startTestNode starts a node with the configuration, stopping it when the
+// test ends
+func startTestNode(t *testing.T, config *Config) *testNode {
+	t.Helper()
+	mp := mempool.New(nil)
+	bp := processor.New(mp, processor.DefaultConfig())
+	n, err := New(mp, bp, config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(n.Stop)
+	return &testNode{Node: n, mp: mp, bp: bp}
+}
+
+// newIdleNode creates a node that is not started, whose validators are
+// called directly
+func newIdleNode(t *testing.T) *testNode {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := mempool.New(nil)
+	bp := processor.New(mp, processor.DefaultConfig())
+	n, err := New(mp, bp, &Config{ListenAddr: "127.0.0.1:0", MaxPeers: 10, Key: key, ChainID: testChainID})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { n.host.Close() })
+	return &testNode{Node: n, mp: mp, bp: bp}
+}
+
+// waitFor fails the test unless cond holds within five seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForMesh waits until every node sees the others subscribed to both topics
+func waitForMesh(t *testing.T, nodes ...*testNode) {
+	t.Helper()
+	waitFor(t, "topic subscriptions", func() bool {
+		for _, n := range nodes {
+			if len(n.txTopic.ListPeers()) < len(nodes)-1 || len(n.blkTopic.ListPeers()) < len(nodes)-1 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// signedTx creates a signed transaction of the key with the nonce
+func signedTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64) *model.Transaction {
+	t.Helper()
+	to := common.HexToAddress("0x02")
+	ethTx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(testChainID)), &types.LegacyTx{
+		Nonce:    nonce,
+		To:       &to,
+		Value:    big.NewInt(1),
+		Gas:      21000,
+		GasPrice: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ethTx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := eth.ParseRawTransaction("0x" + hex.EncodeToString(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// message wraps data in a gossip message published and forwarded by from
+func message(from peer.ID, data []byte) *pubsub.Message {
+	return &pubsub.Message{Message: &pb.Message{Data: data, From: []byte(from)}, ReceivedFrom: from}
+}
+
+// track adds a connected peer to the node and returns its ID
+func (n *testNode) track(t *testing.T) peer.ID {
+	t.Helper()
+	key, _, err := p2pcrypto.GenerateSecp256k1Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.mu.Lock()
+	n.peers[id] = &peerState{info: PeerInfo{ID: id.String()}}
+	n.mu.Unlock()
+	return id
+}
+
+func (n *testNode) score(id peer.ID) int64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.peers[id].score.Load()
+}
+
+func TestGossipTransactions(t *testing.T) {
+	a := newTestNode(t, testChainID, false)
+	b := newTestNode(t, testChainID, false, a)
+	c := newTestNode(t, testChainID, false, b)
+	waitForMesh(t, a, b)
+	waitForMesh(t, b, c)
+
+	// A transaction admitted to one node reaches nodes it is not connected to
+	key, _ := crypto.GenerateKey()
+	tx := signedTx(t, key, 0)
+	if err := a.mp.Admit(tx); err != nil {
+		t.Fatalf("Admit: %v", err)
+	}
+	for _, n := range []*testNode{b, c} {
+		waitFor(t, "gossiped transaction", func() bool {
+			_, ok := n.mp.GetTransaction(tx.ID)
+			return ok
+		})
+	}
+
+	if got := a.Peers(); len(got) != 1 || got[0].ID != b.ID() || got[0].Inbound != true {
+		t.Errorf("Peers() = %+v, want the inbound peer %s", got, b.ID())
+	}
+	if got := b.Peers(); len(got) != 2 {
+		t.Errorf("Peers() = %+v, want 2 peers", got)
+	}
+}
+
+func TestGossipBlocks(t *testing.T) {
+	builder := newTestNode(t, testChainID, false)
+	follower := newTestNode(t, testChainID, true, builder)
+	waitForMesh(t, builder, follower)
+
+	key, _ := crypto.GenerateKey()
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		if err := builder.mp.Admit(signedTx(t, key, nonce)); err != nil {
+			t.Fatalf("Admit: %v", err)
+		}
+		if builder.bp.Step() == nil {
+			t.Fatalf("block %d not sealed", nonce+1)
+		}
+	}
+
+	want, number := builder.bp.LatestBlock()
+	waitFor(t, "imported blocks", func() bool {
+		id, _ := follower.bp.LatestBlock()
+		return id == want
+	})
+	if _, got := follower.bp.LatestBlock(); got != number {
+		t.Errorf("follower at block %d, want %d", got, number)
+	}
+}
+
+func TestBlockSync(t *testing.T) {
+	builder := newTestNode(t, testChainID, false)
+	key, _ := crypto.GenerateKey()
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		if err := builder.mp.Admit(signedTx(t, key, nonce)); err != nil {
+			t.Fatalf("Admit: %v", err)
+		}
+		if builder.bp.Step() == nil {
+			t.Fatalf("block %d not sealed", nonce+1)
+		}
+	}
+
+	// Blocks sealed before a follower connects are fetched from the peer
+	follower := newTestNode(t, testChainID, true, builder)
+	want, _ := builder.bp.LatestBlock()
+	waitFor(t, "synced blocks", func() bool {
+		id, _ := follower.bp.LatestBlock()
+		return id == want
+	})
+}
+
+func TestChainIsolation(t *testing.T) {
+	a := newTestNode(t, testChainID, false)
+	b := newTestNode(t, testChainID+1, false)
+
+	info, err := peer.AddrInfoFromString(a.Addrs()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.host.Connect(context.Background(), *info); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	// Both nodes disconnect the peer of the other chain once it is identified
+	waitFor(t, "disconnect", func() bool {
+		return len(a.host.Network().ConnsToPeer(b.host.ID())) == 0
+	})
+	if a.PeerCount() != 0 || b.PeerCount() != 0 {
+		t.Errorf("peer counts %d and %d, want 0", a.PeerCount(), b.PeerCount())
+	}
+}
+
+func TestMDNSDiscovery(t *testing.T) {
+	var nodes []*testNode
+	for i := 0; i < 2; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, startTestNode(t, &Config{
+			ListenAddr: "127.0.0.1:0",
+			MDNS:       true,
+			MaxPeers:   10,
+			Key:        key,
+			ChainID:    testChainID,
+		}))
+	}
+
+	// Nodes on the local network connect without configured peers
+	waitFor(t, "peers found through mDNS", func() bool {
+		return nodes[0].PeerCount() == 1 && nodes[1].PeerCount() == 1
+	})
+}
+
+func TestMDNSPeerLimit(t *testing.T) {
+	a := newIdleNode(t)
+	b := newTestNode(t, testChainID, false)
+	a.config.MaxPeers = 1
+	a.track(t)
+
+	// A node found on the local network is not dialed beyond the peer limit
+	info, err := peer.AddrInfoFromString(b.Addrs()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	(*mdnsNotifee)(a.Node).HandlePeerFound(*info)
+	if a.host.Network().Connectedness(info.ID) == network.Connected {
+		t.Error("connected beyond the peer limit")
+	}
+
+	a.config.MaxPeers = 2
+	(*mdnsNotifee)(a.Node).HandlePeerFound(*info)
+	if a.host.Network().Connectedness(info.ID) != network.Connected {
+		t.Error("found peer not connected")
+	}
+}
+
+func TestValidateTransaction(t *testing.T) {
+	n := newIdleNode(t)
+	from := n.track(t)
+	key, _ := crypto.GenerateKey()
+
+	tx := signedTx(t, key, 0)
+	data, err := model.EncodeTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.validateTransaction(context.Background(), from, message(from, data)); got != pubsub.ValidationAccept {
+		t.Fatalf("new transaction: %v, want accept", got)
+	}
+	if _, ok := n.mp.GetTransaction(tx.ID); !ok {
+		t.Fatal("transaction not admitted")
+	}
+	if got := n.score(from); got != usefulReward {
+		t.Errorf("score %d after a new transaction, want %d", got, usefulReward)
+	}
+
+	// The same transaction again is handled once
+	if got := n.validateTransaction(context.Background(), from, message(from, data)); got != pubsub.ValidationIgnore {
+		t.Errorf("duplicate transaction: %v, want ignore", got)
+	}
+	if got := n.score(from); got != usefulReward {
+		t.Errorf("score %d after a duplicate, want %d", got, usefulReward)
+	}
+
+	// A transaction whose sender does not match its signed raw data is rejected
+	forged := signedTx(t, key, 1)
+	forged.From = common.HexToAddress("0x03").Hex()
+	if data, err = model.EncodeTransaction(forged); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.validateTransaction(context.Background(), from, message(from, data)); got != pubsub.ValidationReject {
+		t.Errorf("forged transaction: %v, want reject", got)
+	}
+	if _, ok := n.mp.GetTransaction(forged.ID); ok {
+		t.Error("forged transaction admitted")
+	}
+	if got, want := n.score(from), int64(usefulReward-invalidPenalty); got != want {
+		t.Errorf("score %d after a forged transaction, want %d", got, want)
+	}
+
+	// An unsigned transaction is rejected
+	unsigned := n.mp.Factory().NewTransaction([]byte("unsigned"), 1)
+	if data, err = model.EncodeTransaction(unsigned); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.validateTransaction(context.Background(), from, message(from, data)); got != pubsub.ValidationReject {
+		t.Errorf("unsigned transaction: %v, want reject", got)
+	}
+}
+
+func TestScoreDisconnects(t *testing.T) {
+	n := newIdleNode(t)
+	id := n.track(t)
+
+	for i := 0; i < 2*maxScore; i++ {
+		n.adjustScore(id, usefulReward)
+	}
+	if got := n.score(id); got != maxScore {
+		t.Errorf("score %d, want it capped at %d", got, maxScore)
+	}
+
+	// Invalid messages lower the score until the peer is disconnected
+	for n.score(id) >= minScore {
+		n.adjustScore(id, -invalidPenalty)
+	}
+	if got, want := n.score(id), int64(maxScore-9*invalidPenalty); got != want {
+		t.Errorf("score %d, want %d", got, want)
+	}
+}
+
+func TestHashSet(t *testing.T) {
+	s := newHashSet(2)
+	if !s.add([32]byte{1}) || !s.add([32]byte{2}) {
+		t.Fatal("new hashes not added")
+	}
+	if s.add([32]byte{1}) {
+		t.Error("known hash added again")
+	}
+
+	// The oldest hash is forgotten beyond the capacity
+	s.add([32]byte{3})
+	if !s.add([32]byte{1}) {
+		t.Error("oldest hash still known beyond the capacity")
+	}
+	if s.add([32]byte{3}) {
+		t.Error("recent hash forgotten")
+	}
+}
+
+func TestParseAddrs(t *testing.T) {
+	n := newIdleNode(t)
+	addr := n.Addrs()[0]
+	infos, err := ParseAddrs([]string{addr})
+	if err != nil {
+		t.Fatalf("ParseAddrs(%q): %v", addr, err)
+	}
+	if infos[0].ID != n.host.ID() {
+		t.Errorf("peer ID %s, want %s", infos[0].ID, n.host.ID())
+	}
+	if _, err := ParseAddrs([]string{"/ip4/127.0.0.1/tcp/30303"}); err == nil {
+		t.Error("multiaddr without a peer ID accepted")
+	}
+	if _, err := listenAddr("localhost:30303"); err == nil {
+		t.Error("listen address with a host name accepted")
+	}
+}
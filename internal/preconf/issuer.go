@@ -0,0 +1,151 @@
+package preconf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+	"flashblock/internal/signer"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrNotSchedulable is returned for transactions that cannot be promised
+// inclusion in the window, e.g. because of a nonce gap or missing funds
+var ErrNotSchedulable = errors.New("transaction cannot be preconfirmed")
+
+// Config holds configuration for the receipt issuer
+type Config struct {
+	Signer      signer.Signer // Signs the receipts
+	ChainID     uint64        // Chain the receipts are valid for
+	Window      uint64        // Blocks after the head a transaction is promised to be included in
+	MaxReceipts int           // Receipts kept for lookups, the oldest are dropped first
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Window:      4,
+		MaxReceipts: 100000,
+	}
+}
+
+// Issuer signs preconfirmation receipts for admitted transactions and keeps
+// the most recent ones for lookups
+type Issuer struct {
+	processor *processor.BlockProcessor
+	config    *Config
+	signer    common.Address
+
+	mu       sync.Mutex
+	receipts map[common.Hash]*Receipt
+	order    []common.Hash // Hashes of the kept receipts, oldest first
+}
+
+// New creates an issuer of receipts for the blocks of the processor
+func New(bp *processor.BlockProcessor, config *Config) (*Issuer, error) {
+	if config.Signer == nil {
+		return nil, errors.New("preconfirmation signer is not set")
+	}
+	if config.Window == 0 {
+		config.Window = DefaultConfig().Window
+	}
+	if config.MaxReceipts <= 0 {
+		config.MaxReceipts = DefaultConfig().MaxReceipts
+	}
+	return &Issuer{
+		processor: bp,
+		config:    config,
+		signer:    config.Signer.Address(),
+		receipts:  make(map[common.Hash]*Receipt),
+	}, nil
+}
+
+// Signer returns the address receipts are signed by
+func (i *Issuer) Signer() common.Address {
+	return i.signer
+}
+
+// Issue returns the receipt of an admitted transaction, signing one that
+// promises inclusion in the next Window blocks unless it was issued before.
+// Only transactions that can be included right away are preconfirmed.
+func (i *Issuer) Issue(tx *model.Transaction) (*Receipt, error) {
+	hash := common.HexToHash(tx.ID)
+
+	i.mu.Lock()
+	receipt, ok := i.receipts[hash]
+	i.mu.Unlock()
+	if ok {
+		return receipt, nil
+	}
+	if err := i.check(tx); err != nil {
+		return nil, err
+	}
+
+	// The lock is not held while signing, which may take a round trip to a
+	// remote signer; a receipt signed concurrently for the same hash wins
+	receipt, err := i.sign(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if existing, ok := i.receipts[hash]; ok {
+		return existing, nil
+	}
+	i.receipts[hash] = receipt
+	i.order = append(i.order, hash)
+	if len(i.order) > i.config.MaxReceipts {
+		delete(i.receipts, i.order[0])
+		i.order = i.order[1:]
+	}
+	logging.Debugf("Preconfirmed transaction %s for blocks %d-%d", tx.ID, receipt.FromBlock, receipt.ToBlock)
+	return receipt, nil
+}
+
+// Sign returns a receipt for a transaction without keeping it, e.g. for a
+// private transaction that must not be found by its hash
+func (i *Issuer) Sign(tx *model.Transaction) (*Receipt, error) {
+	if err := i.check(tx); err != nil {
+		return nil, err
+	}
+	return i.sign(common.HexToHash(tx.ID))
+}
+
+// check verifies that the transaction can be included in the window
+func (i *Issuer) check(tx *model.Transaction) error {
+	if err := i.processor.CheckSchedulable(tx, i.config.Window); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotSchedulable, err)
+	}
+	return nil
+}
+
+// sign creates the receipt of a transaction hash for the current head
+func (i *Issuer) sign(hash common.Hash) (*Receipt, error) {
+	_, head := i.processor.LatestBlock()
+	receipt := &Receipt{
+		TxHash:    hash,
+		ChainID:   i.config.ChainID,
+		FromBlock: head + 1,
+		ToBlock:   head + i.config.Window,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if err := receipt.sign(i.config.Signer); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// Get returns the kept receipt of a transaction
+func (i *Issuer) Get(hash common.Hash) (*Receipt, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	receipt, ok := i.receipts[hash]
+	return receipt, ok
+}
@@ -0,0 +1,95 @@
+package preconf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"flashblock/internal/signer"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// domain separates the digest of a receipt from other signed messages
+const domain = "flashblock preconfirmation"
+
+// Outcomes of a receipt against the chain
+const (
+	StatusPending = "pending" // Not included yet, the window is still open
+	StatusKept    = "kept"    // Included within the window
+	StatusLate    = "late"    // Included after the window
+	StatusBroken  = "broken"  // Not included and the window has passed
+)
+
+// Receipt is the signed promise of a builder to include a transaction in one
+// of the blocks FromBlock to ToBlock. The signature is made over Digest, so
+// anyone can check it and hold the signer to the promise.
+type Receipt struct {
+	TxHash    common.Hash    `json:"tx_hash"`
+	ChainID   uint64         `json:"chain_id"`
+	FromBlock uint64         `json:"from_block"` // First block of the promised window
+	ToBlock   uint64         `json:"to_block"`   // Last block of the promised window
+	Timestamp int64          `json:"timestamp"`  // Unix time of the admission in milliseconds
+	Signer    common.Address `json:"signer"`
+	Signature hexutil.Bytes  `json:"signature"` // 65 byte [R || S || V] secp256k1 signature
+}
+
+// Digest returns the Keccak-256 hash the signature is made over: the domain
+// followed by the chain ID, the transaction hash, the window and the
+// timestamp, with the numbers as 8 byte big-endian integers
+func (r *Receipt) Digest() common.Hash {
+	buf := make([]byte, 0, len(domain)+8+common.HashLength+3*8)
+	buf = append(buf, domain...)
+	buf = binary.BigEndian.AppendUint64(buf, r.ChainID)
+	buf = append(buf, r.TxHash.Bytes()...)
+	buf = binary.BigEndian.AppendUint64(buf, r.FromBlock)
+	buf = binary.BigEndian.AppendUint64(buf, r.ToBlock)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(r.Timestamp))
+	return crypto.Keccak256Hash(buf)
+}
+
+// sign sets the signer and signature of the receipt
+func (r *Receipt) sign(s signer.Signer) error {
+	signature, err := s.SignHash(r.Digest())
+	if err != nil {
+		return fmt.Errorf("failed to sign preconfirmation: %v", err)
+	}
+	r.Signer = s.Address()
+	r.Signature = signature
+	return nil
+}
+
+// Verify checks that the receipt is signed by its signer
+func (r *Receipt) Verify() error {
+	if len(r.Signature) != crypto.SignatureLength {
+		return fmt.Errorf("invalid signature length %d", len(r.Signature))
+	}
+	if r.FromBlock > r.ToBlock {
+		return errors.New("invalid block window")
+	}
+	pub, err := crypto.SigToPub(r.Digest().Bytes(), r.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != r.Signer {
+		return fmt.Errorf("signed by %s, not %s", signer.Hex(), r.Signer.Hex())
+	}
+	return nil
+}
+
+// Status returns the outcome of the receipt at the given head, given whether
+// and in which block the transaction was included
+func (r *Receipt) Status(head uint64, included bool, block uint64) string {
+	switch {
+	case included && block <= r.ToBlock:
+		return StatusKept
+	case included:
+		return StatusLate
+	case head >= r.ToBlock:
+		return StatusBroken
+	default:
+		return StatusPending
+	}
+}
@@ -2,31 +2,93 @@ package processor
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"flashblock/internal/attest"
+	"flashblock/internal/bundle"
+	"flashblock/internal/chaos"
+	"flashblock/internal/encrypted"
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
 	"flashblock/internal/mempool"
 	"flashblock/internal/model"
+	"flashblock/internal/state"
+	"flashblock/internal/txindex"
+	"flashblock/pkg/codec"
+	"flashblock/pkg/extension"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 // BlockProcessor processes transactions from the mempool and creates blocks
 type BlockProcessor struct {
 	mempool         *mempool.Mempool
+	mu              sync.RWMutex // Protects latestBlockID, latestNumber and processedBlocks
 	latestBlockID   string
+	latestNumber    uint64
 	processedBlocks []*model.Block
-	blockCallback   func(*model.Block, time.Duration)
 	config          *Config
 	tdxProvider     *attest.TDXProvider // TDX provider for quote generation
+	events          *events.Bus         // Receives the sealed and attested blocks
+	factory         *model.Factory      // Creates blocks with the clock of the configuration
+	lastTick        atomic.Int64        // Unix nanoseconds of the last completed processing tick
+	paused          atomic.Bool         // Blocks are not built while paused (standby)
+	held            atomic.Bool         // Blocks are not built while held (maintenance)
+	buildMu         sync.Mutex          // Serializes block builds and imports
+	interval        atomic.Int64        // Block creation interval, changeable at runtime
+	maxTransactions atomic.Int64        // Maximum transactions per block, changeable at runtime
+	intervalChanged chan struct{}       // Signals the processing loop to reset its ticker
+	fence           Fence               // Must allow every seal (optional, protected by buildMu)
 }
 
 // Config holds configuration for the block processor
 type Config struct {
 	Interval        time.Duration
-	BlockCallback   func(*model.Block, time.Duration)
-	MaxStoredBlocks int  // Maximum number of recent blocks to keep in memory
-	EnableTDXQuote  bool // Whether to generate TDX quotes for blocks
+	MaxStoredBlocks int               // Maximum number of recent blocks to keep in memory
+	MaxTransactions int               // Maximum number of transactions per block (0 = unlimited)
+	EnableTDXQuote  bool              // Whether to generate TDX quotes for blocks
+	Store           BlockStore        // Persists blocks before they are published (optional)
+	Chaos           *chaos.Injector   // Injects faults for resilience testing (optional)
+	Index           *txindex.Index    // Locations of included transactions (created if nil)
+	State           *state.DB         // Account state updated by every block (optional)
+	FeeRecipient    common.Address    // Receives the priority fees of executed transactions
+	Bundles         *bundle.Pool      // Bundles included at the top of their target block (optional)
+	Encrypted       *encrypted.Pool   // Encrypted transactions decrypted at block-build time (optional)
+	Verifier        BlockVerifier     // Verifies the attestation of imported blocks (optional)
+	Orderer         extension.Orderer // Orders the candidate transactions instead of their priority (optional)
+	Events          *events.Bus       // Receives the sealed and attested blocks (the bus of the mempool if nil)
+	Clock           model.Clock       // Timestamps the blocks and times their builds (the clock of the mempool if nil)
+	TrustedTime     TimeSource        // Timestamps the blocks instead of the clock, with a proof (optional)
+}
+
+// TimeSource timestamps blocks with a time that can be trusted and the proof
+// of its bounds, which becomes part of the block ID
+type TimeSource interface {
+	Stamp() (time.Time, *model.TimeProof, error)
+}
+
+// ErrUnknownParent is returned when an imported block does not extend the chain head
+var ErrUnknownParent = errors.New("block does not extend the chain head")
+
+// BlockStore persists created blocks
+type BlockStore interface {
+	Append(block *model.Block) error
+}
+
+// Fence allows sealing blocks only while this node may build them, e.g. while
+// it holds the leader lease of an active/standby group
+type Fence interface {
+	CheckLease() error
+}
+
+// BlockVerifier verifies blocks received from other nodes before they are imported
+type BlockVerifier interface {
+	VerifyBlock(block *model.Block) error
 }
 
 // DefaultConfig returns the default configuration
@@ -43,78 +105,289 @@ func New(mempool *mempool.Mempool, config *Config) *BlockProcessor {
 	if config == nil {
 		config = DefaultConfig()
 	}
+	if config.MaxStoredBlocks <= 0 {
+		config.MaxStoredBlocks = DefaultConfig().MaxStoredBlocks
+	}
+	if config.Index == nil {
+		config.Index = txindex.New()
+	}
+	if config.Events == nil {
+		config.Events = mempool.Events()
+	}
+	if config.Clock == nil {
+		config.Clock = mempool.Clock()
+	}
 
 	bp := &BlockProcessor{
 		mempool:         mempool,
 		latestBlockID:   "",
 		processedBlocks: make([]*model.Block, 0),
-		blockCallback:   config.BlockCallback,
 		config:          config,
+		events:          config.Events,
+		factory:         model.NewFactory(config.Clock, nil),
+		intervalChanged: make(chan struct{}, 1),
 	}
+	bp.interval.Store(int64(config.Interval))
+	bp.maxTransactions.Store(int64(config.MaxTransactions))
 
 	// Initialize TDX provider if quote generation is enabled
 	if config.EnableTDXQuote {
 		provider, err := attest.NewTDXProvider()
 		if err != nil {
-			log.Printf("Warning: Failed to initialize TDX provider: %v. TDX quotes will be disabled.", err)
+			logging.Warnf("Failed to initialize TDX provider: %v. TDX quotes will be disabled.", err)
 			// Disable TDX quote generation if not supported
 			bp.config.EnableTDXQuote = false
 		} else {
 			bp.tdxProvider = provider
-			log.Println("TDX quote provider initialized successfully")
+			logging.Infof("TDX quote provider initialized successfully")
 		}
 	}
 
 	return bp
 }
 
-// Start begins the block processing loop
+// Start begins the block processing loop. Blocks are built one at a time, and a
+// build in progress is completed before Start returns after ctx is cancelled.
 func (bp *BlockProcessor) Start(ctx context.Context) {
-	ticker := time.NewTicker(bp.config.Interval)
+	ticker := time.NewTicker(bp.Interval())
 	defer ticker.Stop()
 
-	log.Printf("Block processor started with interval: %v", bp.config.Interval)
+	logging.Infof("Block processor started with interval: %v", bp.Interval())
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Block processor stopped")
+			logging.Infof("Block processor stopped")
 			return
+		case <-bp.intervalChanged:
+			ticker.Reset(bp.Interval())
 		case <-ticker.C:
-			go bp.processNextBlock()
+			// Ticks missed during a slow build are dropped by the ticker
+			bp.Step()
+			// Liveness is tracked on the wall clock, which the watchdog compares with
+			bp.lastTick.Store(time.Now().UnixNano())
 		}
 	}
 }
 
-// processNextBlock creates a new block from the mempool transactions
-func (bp *BlockProcessor) processNextBlock() {
+// Step builds the next block at once, as every tick of Start does, and
+// returns it, or nil if no block was sealed. Nothing is built while block
+// building is paused or held. Tests drive the processor with Step and a
+// deterministic clock instead of Start, so the blocks are reproducible.
+func (bp *BlockProcessor) Step() *model.Block {
+	if bp.paused.Load() || bp.held.Load() {
+		return nil
+	}
+	return bp.processNextBlock()
+}
+
+// processNextBlock creates a new block from the mempool transactions and
+// returns it if it was sealed
+func (bp *BlockProcessor) processNextBlock() (sealed *model.Block) {
+	bp.buildMu.Lock()
+	defer bp.buildMu.Unlock()
+
 	// Start measuring block creation time
-	startTime := time.Now()
+	clock := bp.config.Clock
+	startTime := clock.Now()
 
-	// Get all transactions from mempool
-	transactions := bp.mempool.GetAllTransactions()
+	// Get the public and private mempool transactions, and the bundles targeting this block
+	transactions := bp.mempool.BlockCandidates()
+	now := clock.Now()
+	var timeProof *model.TimeProof
+	if bp.config.TrustedTime != nil {
+		// No block is built while the time cannot be trusted
+		var err error
+		if now, timeProof, err = bp.config.TrustedTime.Stamp(); err != nil {
+			logging.Debugf("Not building block %d: %v", bp.latestNumber+1, err)
+			return
+		}
+	}
+	header := bp.NextHeader(now)
+	// Bundles stay in the pool until the block is sealed, so a failed build
+	// does not lose them
+	var bundles []*model.Bundle
+	if bp.config.Bundles != nil {
+		bundles = bp.config.Bundles.Candidates(header.Number, header.Time)
+	}
+
+	// Encrypted transactions are only decrypted now that the block is built.
+	// Those that do not end up in a sealed block are kept for later blocks.
+	var decrypted *encrypted.Decrypted
+	var invalid []*model.Transaction
+	if bp.config.Encrypted != nil && bp.config.Encrypted.Len() > 0 {
+		decrypted = bp.config.Encrypted.Decrypt()
+		defer func() { bp.keepDecrypted(decrypted, sealed, invalid) }()
+	}
 
 	// Skip if there are no transactions
-	if len(transactions) == 0 {
+	if len(transactions) == 0 && len(bundles) == 0 && decrypted == nil {
 		return
 	}
 
-	// Sort transactions by priority fee (high to low)
-	sort.Slice(transactions, func(i, j int) bool {
-		// Compare transactions by priority (higher priority first)
-		return transactions[i].Priority > transactions[j].Priority
-	})
+	transactions = bp.order(transactions)
+
+	// Decrypted transactions keep their commitment order ahead of the mempool
+	if decrypted != nil {
+		transactions = append(decrypted.Transactions, transactions...)
+	}
+
+	// Leave lower priority transactions for later blocks if the block is full
+	maxTxs := int(bp.maxTransactions.Load())
+	var result *state.Result
+	if bp.config.State != nil {
+		// Execute the bundles and transactions on the account state; transactions
+		// that can never be included are dropped, and those with a nonce gap stay
+		// pending. Bundles that fail are dropped as a whole.
+		var err error
+		result, err = bp.config.State.Execute(header, bundles, transactions, maxTxs)
+		if err != nil {
+			logging.Errorf("Failed to execute block %d: %v", header.Number, err)
+			return
+		}
+		for id, err := range result.Excluded {
+			logging.Debugf("Bundle %s not included in block %d: %v", id, header.Number, err)
+		}
+		invalid = result.Invalid
+		if len(result.Invalid) > 0 {
+			invalidIDs := make([]string, len(result.Invalid))
+			for i, tx := range result.Invalid {
+				invalidIDs[i] = tx.ID
+			}
+			bp.mempool.RemoveTransactions(invalidIDs, events.RemovedInvalid)
+			logging.Debugf("Dropped %d invalid transactions", len(invalidIDs))
+		}
+		transactions = result.Applied
+		if len(transactions) == 0 && decrypted == nil {
+			return
+		}
+	} else {
+		// Without execution, bundles are included at the top unchecked, as
+		// long as they fit in the block, and count against its limit
+		var bundled []*model.Transaction
+		for _, b := range bundles {
+			if maxTxs > 0 && len(bundled)+len(b.Transactions) > maxTxs {
+				logging.Debugf("Bundle %s not included in block %d: %v", b.ID, header.Number, state.ErrBundleLimit)
+				continue
+			}
+			bundled = append(bundled, b.Transactions...)
+		}
+		if limit := maxTxs - len(bundled); maxTxs > 0 && len(transactions) > limit {
+			transactions = transactions[:limit]
+		}
+		transactions = append(bundled, transactions...)
+	}
 
 	// Create a new block
-	block := model.NewBlock(transactions, bp.latestBlockID)
+	block := bp.factory.NewBlock(bp.latestNumber+1, transactions, bp.latestBlockID)
+	block.TimeProof = timeProof
+	if result != nil || bp.config.TrustedTime != nil {
+		// The block takes the context it was executed in, and the time it was stamped with
+		block.Timestamp = header.Time
+	}
+	if result != nil {
+		block.StateRoot = result.Root.Hex()
+		block.GasLimit = header.GasLimit
+		block.GasUsed = result.GasUsed
+		block.FeeRecipient = header.FeeRecipient.Hex()
+		block.Receipts = result.Receipts
+	}
+	if decrypted != nil {
+		// The block records every use of the decryption key, even if a
+		// decrypted transaction was left out
+		block.EncryptionKey = decrypted.KeyID.Hex()
+		block.Commitments = make([]string, len(decrypted.Commitments))
+		for i, commitment := range decrypted.Commitments {
+			block.Commitments[i] = commitment.Hex()
+		}
+	}
+
+	// The ID is derived from the canonical encoding of the complete block
+	block.Version = codec.Version
+	id, err := model.BlockID(block)
+	if err != nil {
+		logging.Errorf("Failed to derive the ID of block %d: %v", block.Number, err)
+		return
+	}
+	block.ID = id
+	bp.config.Chaos.DelayBlock()
 
-	// Generate TDX quote if enabled
+	// Generate TDX quote if enabled; an injected delay simulates a slow provider
+	bp.config.Chaos.DelayAttestation()
 	if bp.config.EnableTDXQuote && bp.tdxProvider != nil {
 		bp.generateTDXQuoteForBlock(block)
 	}
 
+	// A leader whose lease ran out during the build drops the block, since a
+	// standby may already build on the same parent
+	if bp.fence != nil {
+		if err := bp.fence.CheckLease(); err != nil {
+			logging.Warnf("Dropped block %d: %v", block.Number, err)
+			return
+		}
+	}
+
+	// Persist the block before it takes effect; the transactions stay pending if it fails
+	if bp.config.Store != nil {
+		if err := bp.config.Store.Append(block); err != nil {
+			logging.Errorf("Failed to persist block %s: %v", block.ID, err)
+			return
+		}
+	}
+
+	if result != nil {
+		bp.config.State.SetHead(result.Root)
+	}
+	bp.commitBlock(block)
+	if bp.config.Bundles != nil {
+		bp.config.Bundles.Discard(block.Number)
+	}
+	sealed = block
+
+	// Notify block subscribers
+	bp.events.Publish(events.BlockSealed{Block: block, BuildTime: clock.Now().Sub(startTime)})
+	return sealed
+}
+
+// keepDecrypted admits the decrypted transactions that are not in the sealed
+// block (nil if none was sealed) to the mempool as private transactions that
+// expire instead of becoming public, so transactions cut by the block limits,
+// waiting for a nonce or left out of a failed build are included later
+// without being revealed. Transactions found invalid are dropped.
+func (bp *BlockProcessor) keepDecrypted(decrypted *encrypted.Decrypted, sealed *model.Block, invalid []*model.Transaction) {
+	done := make(map[string]bool)
+	for _, tx := range invalid {
+		done[tx.ID] = true
+	}
+	if sealed != nil {
+		for _, tx := range sealed.Transactions {
+			done[tx.ID] = true
+		}
+	}
+
+	kept := 0
+	for _, tx := range decrypted.Transactions {
+		if done[tx.ID] {
+			continue
+		}
+		if err := bp.mempool.AdmitPrivate(tx, 0, true); err != nil && err != mempool.ErrDuplicate {
+			logging.Debugf("Dropped decrypted transaction %s: %v", tx.ID, err)
+			continue
+		}
+		kept++
+	}
+	if kept > 0 {
+		logging.Debugf("Kept %d decrypted transactions for later blocks", kept)
+	}
+}
+
+// commitBlock makes the block the chain head and removes its transactions from the mempool
+func (bp *BlockProcessor) commitBlock(block *model.Block) {
+	bp.mu.Lock()
+
 	// Update latest block ID
 	bp.latestBlockID = block.ID
+	bp.latestNumber = block.Number
 
 	// Add block to processed blocks
 	bp.processedBlocks = append(bp.processedBlocks, block)
@@ -126,20 +399,216 @@ func (bp *BlockProcessor) processNextBlock() {
 		bp.processedBlocks = bp.processedBlocks[excess:]
 	}
 
+	bp.mu.Unlock()
+
+	// Index the transactions before they leave the mempool, so they are always found
+	if err := bp.config.Index.Add(block); err != nil {
+		logging.Errorf("Failed to index block %d: %v", block.Number, err)
+	}
+
 	// Remove processed transactions from mempool
-	txIDs := make([]string, len(transactions))
-	for i, tx := range transactions {
+	txIDs := make([]string, len(block.Transactions))
+	for i, tx := range block.Transactions {
 		txIDs[i] = tx.ID
 	}
-	bp.mempool.RemoveTransactions(txIDs)
+	bp.mempool.RemoveTransactions(txIDs, events.RemovedIncluded)
+}
+
+// ImportBlock adds a block created by another node on top of the chain head.
+// The block must link to the head, unless the chain is empty, in which case
+// it anchors the chain, and pass the configured verifier. Imported blocks are
+// persisted and published like created blocks.
+func (bp *BlockProcessor) ImportBlock(block *model.Block) error {
+	bp.buildMu.Lock()
+	defer bp.buildMu.Unlock()
+
+	if err := model.VerifyBlockID(block); err != nil {
+		return err
+	}
+	if bp.config.Verifier != nil {
+		if err := bp.config.Verifier.VerifyBlock(block); err != nil {
+			return err
+		}
+	}
 
-	// Calculate block creation time
-	blockCreationTime := time.Since(startTime)
+	latestID, latestNumber := bp.LatestBlock()
+	if latestID != "" {
+		if block.PrevBlockID != latestID {
+			return fmt.Errorf("%w: block %d links to %s, head is %d (%s)", ErrUnknownParent, block.Number, block.PrevBlockID, latestNumber, latestID)
+		}
+		if block.Number != latestNumber+1 {
+			return fmt.Errorf("block %s has number %d, expected %d", block.ID, block.Number, latestNumber+1)
+		}
+	}
 
-	// Call the callback if set
-	if bp.blockCallback != nil {
-		bp.blockCallback(block, blockCreationTime)
+	// The transactions must execute on the account state with the recorded
+	// gas used and state root; the receipts are taken from the local execution
+	var result *state.Result
+	if bp.config.State != nil {
+		var err error
+		result, err = bp.config.State.ApplyBlock(block)
+		if err != nil {
+			return fmt.Errorf("failed to apply block %s: %v", block.ID, err)
+		}
+		block.Receipts = result.Receipts
 	}
+
+	if bp.config.Store != nil {
+		if err := bp.config.Store.Append(block); err != nil {
+			return fmt.Errorf("failed to persist block %s: %v", block.ID, err)
+		}
+	}
+
+	if result != nil {
+		bp.config.State.SetHead(result.Root)
+	}
+	bp.commitBlock(block)
+
+	// Notify block subscribers
+	bp.events.Publish(events.BlockSealed{Block: block, Imported: true})
+	return nil
+}
+
+// Bootstrap anchors an empty chain at the blocks of a snapshot, given oldest
+// first, without executing them. The account state after the latest block is
+// imported from the snapshot state unless it is stored already, and the
+// receipts are taken from the snapshot. The blocks are persisted and
+// published like imported blocks.
+func (bp *BlockProcessor) Bootstrap(blocks []*model.Block, snapshot *state.Snapshot) error {
+	bp.buildMu.Lock()
+	defer bp.buildMu.Unlock()
+
+	if latestID, _ := bp.LatestBlock(); latestID != "" {
+		return errors.New("cannot bootstrap a chain that is not empty")
+	}
+	if len(blocks) == 0 {
+		return errors.New("snapshot has no blocks")
+	}
+	for i, block := range blocks {
+		if err := model.VerifyBlockID(block); err != nil {
+			return err
+		}
+		if bp.config.Verifier != nil {
+			if err := bp.config.Verifier.VerifyBlock(block); err != nil {
+				return err
+			}
+		}
+		if i > 0 && (block.PrevBlockID != blocks[i-1].ID || block.Number != blocks[i-1].Number+1) {
+			return fmt.Errorf("snapshot block %d (%s) does not extend block %d", block.Number, block.ID, blocks[i-1].Number)
+		}
+	}
+
+	latest := blocks[len(blocks)-1]
+	if bp.config.State != nil && latest.StateRoot != "" {
+		root := common.HexToHash(latest.StateRoot)
+		if snapshot != nil && snapshot.Root == root && !bp.config.State.HasState(root) {
+			if err := bp.config.State.ImportSnapshot(snapshot); err != nil {
+				return err
+			}
+		}
+		if !bp.config.State.HasState(root) {
+			return fmt.Errorf("%w: state root %s of block %d", state.ErrStateNotAvailable, latest.StateRoot, latest.Number)
+		}
+		bp.config.State.SetHead(root)
+	}
+
+	for _, block := range blocks {
+		if bp.config.Store != nil {
+			if err := bp.config.Store.Append(block); err != nil {
+				return fmt.Errorf("failed to persist block %s: %v", block.ID, err)
+			}
+		}
+		bp.commitBlock(block)
+		bp.events.Publish(events.BlockSealed{Block: block, Imported: true})
+	}
+	return nil
+}
+
+// NextHeader returns the context the next block is executed in at time t.
+// The gas limit is only known when the account state is tracked.
+func (bp *BlockProcessor) NextHeader(t time.Time) *state.Header {
+	latestID, latestNumber := bp.LatestBlock()
+	header := &state.Header{
+		Number:       latestNumber + 1,
+		Time:         t,
+		ParentID:     latestID,
+		FeeRecipient: bp.config.FeeRecipient,
+	}
+	if bp.config.State != nil {
+		header.GasLimit = bp.config.State.GasLimit()
+	}
+	return header
+}
+
+// Interval returns the block creation interval
+func (bp *BlockProcessor) Interval() time.Duration {
+	return time.Duration(bp.interval.Load())
+}
+
+// SetInterval changes the block creation interval; the next block follows the new interval
+func (bp *BlockProcessor) SetInterval(interval time.Duration) {
+	if interval <= 0 || bp.interval.Swap(int64(interval)) == int64(interval) {
+		return
+	}
+
+	select {
+	case bp.intervalChanged <- struct{}{}:
+	default:
+		// A change is already pending; the loop reads the latest interval
+	}
+}
+
+// SetMaxTransactions changes the maximum number of transactions per block (0 = unlimited)
+func (bp *BlockProcessor) SetMaxTransactions(maxTransactions int) {
+	bp.maxTransactions.Store(int64(maxTransactions))
+}
+
+// MaxTransactions returns the maximum number of transactions per block (0 = unlimited)
+func (bp *BlockProcessor) MaxTransactions() int {
+	return int(bp.maxTransactions.Load())
+}
+
+// SetPaused stops or resumes block building. A build in progress completes first.
+func (bp *BlockProcessor) SetPaused(paused bool) {
+	bp.buildMu.Lock()
+	defer bp.buildMu.Unlock()
+
+	bp.paused.Store(paused)
+}
+
+// Paused reports whether block building is paused
+func (bp *BlockProcessor) Paused() bool {
+	return bp.paused.Load()
+}
+
+// SetHeld stops or resumes block building independently of the standby pause,
+// e.g. during maintenance. A build in progress completes first.
+func (bp *BlockProcessor) SetHeld(held bool) {
+	bp.buildMu.Lock()
+	defer bp.buildMu.Unlock()
+
+	bp.held.Store(held)
+}
+
+// SetFence sets the fence every block build must pass before the block is sealed
+func (bp *BlockProcessor) SetFence(fence Fence) {
+	bp.buildMu.Lock()
+	defer bp.buildMu.Unlock()
+
+	bp.fence = fence
+}
+
+// Held reports whether block building is held
+func (bp *BlockProcessor) Held() bool {
+	return bp.held.Load()
+}
+
+// Attest returns a TDX quote over data, or nil if quote generation is disabled
+func (bp *BlockProcessor) Attest(data []byte) ([]byte, error) {
+	if !bp.config.EnableTDXQuote || bp.tdxProvider == nil {
+		return nil, nil
+	}
+	return bp.tdxProvider.GetQuote(data)
 }
 
 // generateTDXQuoteForBlock generates a TDX quote for the given block
@@ -150,15 +619,182 @@ func (bp *BlockProcessor) generateTDXQuoteForBlock(block *model.Block) {
 
 	quoteData, err = bp.tdxProvider.GetQuote([]byte(block.ID))
 	if err != nil {
-		log.Printf("Failed to generate TDX quote for block %s: %v", block.ID, err)
+		logging.Errorf("Failed to generate TDX quote for block %s: %v", block.ID, err)
 		return
 	}
 
 	block.TDXQuote = quoteData
-	log.Printf("Generated TDX quote for block %s (%d bytes)", block.ID, len(quoteData))
+	logging.Debugf("Generated TDX quote for block %s (%d bytes)", block.ID, len(quoteData))
+	bp.events.Publish(events.BlockAttested{Block: block, Quote: quoteData})
+}
+
+// Restore continues the chain from previously created blocks, given oldest
+// first. It must be called before Start.
+func (bp *BlockProcessor) Restore(blocks []*model.Block) {
+	if len(blocks) == 0 {
+		return
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	latest := blocks[len(blocks)-1]
+	bp.latestBlockID = latest.ID
+	bp.latestNumber = latest.Number
+
+	if len(blocks) > bp.config.MaxStoredBlocks {
+		blocks = blocks[len(blocks)-bp.config.MaxStoredBlocks:]
+	}
+	bp.processedBlocks = append([]*model.Block(nil), blocks...)
+}
+
+// LatestBlock returns the ID and number of the latest block
+func (bp *BlockProcessor) LatestBlock() (string, uint64) {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+
+	return bp.latestBlockID, bp.latestNumber
+}
+
+// order sorts the candidates of a block by priority fee (high to low), unless
+// an extension orders them; the candidates are in arrival order, which is
+// kept within a priority. Transactions the orderer returns that are not
+// candidates, or that it returns more than once, are dropped.
+func (bp *BlockProcessor) order(candidates []*model.Transaction) []*model.Transaction {
+	if bp.config.Orderer == nil {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			// Compare transactions by priority (higher priority first)
+			return candidates[i].Priority > candidates[j].Priority
+		})
+		return candidates
+	}
+
+	views := make([]*extension.Transaction, len(candidates))
+	byID := make(map[string]*model.Transaction, len(candidates))
+	for i, tx := range candidates {
+		views[i] = tx.ExtensionView()
+		byID[tx.ID] = tx
+	}
+	ordered := bp.config.Orderer.Order(views)
+	transactions := make([]*model.Transaction, 0, len(ordered))
+	dropped := 0
+	for _, view := range ordered {
+		var tx *model.Transaction
+		if view != nil {
+			tx = byID[view.ID]
+		}
+		if tx == nil {
+			dropped++
+			continue
+		}
+		// Each candidate is taken once
+		delete(byID, tx.ID)
+		transactions = append(transactions, tx)
+	}
+	if dropped > 0 {
+		logging.Warnf("Dropped %d transactions the orderer returned that were not candidates or were repeated", dropped)
+	}
+	return transactions
+}
+
+// CheckSchedulable checks that a pending transaction is included in one of
+// the next blocks even if nothing else arrives: it can run on the head state,
+// no transaction of its sender with the same nonce is ordered ahead of it, and
+// the transactions ahead of it leave room for it in the blocks
+func (bp *BlockProcessor) CheckSchedulable(tx *model.Transaction, blocks uint64) error {
+	if bp.config.State != nil {
+		if err := bp.config.State.CheckExecutable(tx); err != nil {
+			return err
+		}
+	}
+
+	// The candidates are ordered as the next block would order them, after
+	// the encrypted transactions
+	candidates := bp.order(bp.mempool.BlockCandidates())
+	var ahead int
+	var gas uint64
+	if bp.config.Encrypted != nil {
+		ahead = bp.config.Encrypted.Len()
+	}
+	pending := false
+	for _, candidate := range candidates {
+		if candidate.ID == tx.ID {
+			pending = true
+			break
+		}
+		if tx.From != "" && candidate.From == tx.From && candidate.Nonce == tx.Nonce {
+			return fmt.Errorf("transaction %s of the same sender and nonce is ordered first", candidate.ID)
+		}
+		ahead++
+		gas += candidate.GasLimit
+	}
+	if !pending {
+		return errors.New("transaction is not pending")
+	}
+
+	if maxTxs := bp.MaxTransactions(); maxTxs > 0 && uint64(ahead) >= blocks*uint64(maxTxs) {
+		return fmt.Errorf("%d transactions are ahead of it, %d fit in %d blocks", ahead, blocks*uint64(maxTxs), blocks)
+	}
+	if bp.config.State != nil && tx.From != "" {
+		if limit := blocks * bp.config.State.GasLimit(); gas+tx.GasLimit > limit {
+			return fmt.Errorf("transactions ahead of it use up to %d gas, %d fits in %d blocks", gas, limit, blocks)
+		}
+	}
+	return nil
+}
+
+// LookupTransaction returns the location of an included transaction
+func (bp *BlockProcessor) LookupTransaction(txID string) (txindex.Location, bool) {
+	return bp.config.Index.Lookup(txID)
+}
+
+// GetBlock returns a recent block by ID, or nil if it is not kept in memory
+func (bp *BlockProcessor) GetBlock(id string) *model.Block {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+
+	for i := len(bp.processedBlocks) - 1; i >= 0; i-- {
+		if bp.processedBlocks[i].ID == id {
+			return bp.processedBlocks[i]
+		}
+	}
+	return nil
+}
+
+// RecordDA attaches a DA reference to the recent blocks of its batch. Blocks
+// handed out earlier are not modified; they are replaced by copies.
+func (bp *BlockProcessor) RecordDA(ref *model.DAReference) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	for i, block := range bp.processedBlocks {
+		if block.Number >= ref.FirstBlock && block.Number <= ref.LastBlock {
+			recorded := *block
+			recorded.DA = ref
+			bp.processedBlocks[i] = &recorded
+		}
+	}
+}
+
+// LastTick returns when the processing loop last completed a tick, including
+// ticks without transactions. It is zero before the first tick.
+func (bp *BlockProcessor) LastTick() time.Time {
+	nanos := bp.lastTick.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// Events returns the event bus the processor publishes to
+func (bp *BlockProcessor) Events() *events.Bus {
+	return bp.events
 }
 
 // GetProcessedBlocks returns all blocks that have been processed
 func (bp *BlockProcessor) GetProcessedBlocks() []*model.Block {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+
 	return bp.processedBlocks
 }
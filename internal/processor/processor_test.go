@@ -0,0 +1,303 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"flashblock/internal/bundle"
+	"flashblock/internal/deterministic"
+	"flashblock/internal/events"
+	"flashblock/internal/mempool"
+	"flashblock/internal/model"
+	"flashblock/pkg/extension"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestProcessor creates a block processor on a mempool with a
+// deterministic clock and IDs for seed
+func newTestProcessor(t *testing.T, seed string, config *Config) (*BlockProcessor, *mempool.Mempool, *deterministic.Clock) {
+	t.Helper()
+	clock := deterministic.NewClock(testStart)
+	mp := mempool.New(&mempool.Config{Clock: clock, IDs: deterministic.NewIDs(seed)})
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return New(mp, config), mp, clock
+}
+
+// admit creates and admits a transaction for each priority, one millisecond apart
+func admit(t *testing.T, mp *mempool.Mempool, clock *deterministic.Clock, priorities ...int) []*model.Transaction {
+	t.Helper()
+	txs := make([]*model.Transaction, len(priorities))
+	for i, priority := range priorities {
+		clock.Advance(time.Millisecond)
+		txs[i] = mp.Factory().NewTransaction([]byte(fmt.Sprintf("tx-%d", i)), priority)
+		if err := mp.Admit(txs[i]); err != nil {
+			t.Fatalf("Admit(%d): %v", i, err)
+		}
+	}
+	return txs
+}
+
+func txIDs(txs []*model.Transaction) []string {
+	ids := make([]string, len(txs))
+	for i, tx := range txs {
+		ids[i] = tx.ID
+	}
+	return ids
+}
+
+// run admits the same transactions before each of three blocks and returns
+// the IDs of the blocks
+func run(t *testing.T, seed string) []string {
+	t.Helper()
+	bp, mp, clock := newTestProcessor(t, seed, nil)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		admit(t, mp, clock, 1, 3, 2)
+		clock.Advance(bp.Interval())
+		block := bp.Step()
+		if block == nil {
+			t.Fatalf("block %d not sealed", i+1)
+		}
+		ids = append(ids, block.ID)
+	}
+	return ids
+}
+
+func TestReproducibleBlocks(t *testing.T) {
+	first := run(t, "seed")
+	if second := run(t, "seed"); !reflect.DeepEqual(first, second) {
+		t.Fatalf("block IDs differ between runs: %v, %v", first, second)
+	}
+	if other := run(t, "other"); reflect.DeepEqual(first, other) {
+		t.Fatalf("block IDs equal for different seeds: %v", first)
+	}
+}
+
+func TestStep(t *testing.T) {
+	bp, mp, clock := newTestProcessor(t, "seed", nil)
+	if block := bp.Step(); block != nil {
+		t.Fatalf("sealed block %d without transactions", block.Number)
+	}
+
+	txs := admit(t, mp, clock, 1)
+	bp.SetPaused(true)
+	if block := bp.Step(); block != nil {
+		t.Fatal("sealed a block while paused")
+	}
+	bp.SetPaused(false)
+
+	block := bp.Step()
+	if block == nil {
+		t.Fatal("block not sealed")
+	}
+	if block.Number != 1 || !block.Timestamp.Equal(clock.Now()) {
+		t.Errorf("block %d at %v, want 1 at %v", block.Number, block.Timestamp, clock.Now())
+	}
+	if id, number := bp.LatestBlock(); id != block.ID || number != 1 {
+		t.Errorf("latest block = %s %d, want %s 1", id, number, block.ID)
+	}
+	if mp.Size() != 0 {
+		t.Errorf("%d transactions left in the mempool", mp.Size())
+	}
+	if loc, ok := bp.LookupTransaction(txs[0].ID); !ok || loc.BlockID != block.ID {
+		t.Errorf("transaction not indexed in block %s: %+v", block.ID, loc)
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	bp, mp, clock := newTestProcessor(t, "seed", nil)
+	txs := admit(t, mp, clock, 1, 3, 2, 3, 1)
+	bp.SetMaxTransactions(3)
+
+	// Higher priorities first, in arrival order within a priority
+	block := bp.Step()
+	want := []string{txs[1].ID, txs[3].ID, txs[2].ID}
+	if got := txIDs(block.Transactions); !reflect.DeepEqual(got, want) {
+		t.Fatalf("block 1 = %v, want %v", got, want)
+	}
+
+	// The transactions cut by the limit are left for the next block
+	block = bp.Step()
+	want = []string{txs[0].ID, txs[4].ID}
+	if got := txIDs(block.Transactions); !reflect.DeepEqual(got, want) {
+		t.Fatalf("block 2 = %v, want %v", got, want)
+	}
+}
+
+func TestOrdererOutputSanitized(t *testing.T) {
+	var received []string
+	orderer := extension.OrdererFunc(func(txs []*extension.Transaction) []*extension.Transaction {
+		received = nil
+		for _, tx := range txs {
+			received = append(received, tx.ID)
+		}
+		// Reverse the candidates, repeat one, and add a transaction and a nil
+		// that are not candidates
+		out := []*extension.Transaction{nil, {ID: "unknown"}}
+		for i := len(txs) - 1; i >= 0; i-- {
+			out = append(out, txs[i])
+		}
+		return append(out, txs[0])
+	})
+	config := DefaultConfig()
+	config.Orderer = orderer
+	bp, mp, clock := newTestProcessor(t, "seed", config)
+	txs := admit(t, mp, clock, 3, 1, 2)
+
+	block := bp.Step()
+	if block == nil {
+		t.Fatal("block not sealed")
+	}
+	// The orderer sees the candidates in arrival order, not by priority
+	if want := txIDs(txs); !reflect.DeepEqual(received, want) {
+		t.Errorf("orderer received %v, want %v", received, want)
+	}
+	want := []string{txs[2].ID, txs[1].ID, txs[0].ID}
+	if got := txIDs(block.Transactions); !reflect.DeepEqual(got, want) {
+		t.Errorf("block = %v, want %v", got, want)
+	}
+}
+
+func TestOrdererLeavesTransactionsPending(t *testing.T) {
+	config := DefaultConfig()
+	config.Orderer = extension.OrdererFunc(func(txs []*extension.Transaction) []*extension.Transaction {
+		return txs[:1]
+	})
+	bp, mp, clock := newTestProcessor(t, "seed", config)
+	txs := admit(t, mp, clock, 1, 2)
+
+	block := bp.Step()
+	if got := txIDs(block.Transactions); !reflect.DeepEqual(got, []string{txs[0].ID}) {
+		t.Fatalf("block = %v, want %v", got, txs[0].ID)
+	}
+	if _, ok := mp.GetTransaction(txs[1].ID); !ok {
+		t.Error("transaction left out by the orderer not pending")
+	}
+}
+
+func TestRestoreContinuesChain(t *testing.T) {
+	bp, mp, clock := newTestProcessor(t, "seed", nil)
+	var blocks []*model.Block
+	for i := 0; i < 3; i++ {
+		admit(t, mp, clock, 1)
+		blocks = append(blocks, bp.Step())
+	}
+
+	// A restarted processor picks up from the blocks loaded from the store
+	restarted, mp, clock := newTestProcessor(t, "restart", nil)
+	restarted.Restore(blocks)
+	if id, number := restarted.LatestBlock(); id != blocks[2].ID || number != 3 {
+		t.Fatalf("latest block = %s %d, want %s 3", id, number, blocks[2].ID)
+	}
+	if got := restarted.GetBlock(blocks[1].ID); got == nil {
+		t.Error("restored block not found")
+	}
+
+	admit(t, mp, clock, 1)
+	block := restarted.Step()
+	if block == nil || block.Number != 4 || block.PrevBlockID != blocks[2].ID {
+		t.Fatalf("next block = %+v, want block 4 linking to %s", block, blocks[2].ID)
+	}
+	if err := model.VerifyBlockID(block); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStalledSubscriber(t *testing.T) {
+	bp, mp, clock := newTestProcessor(t, "seed", nil)
+	stalled := make(chan events.BlockSealed)
+	sub := bp.Events().BlockSealed.Subscribe(stalled)
+	defer sub.Unsubscribe()
+
+	// A subscriber that never reads does not hold up block production
+	done := make(chan []string)
+	go func() {
+		var ids []string
+		for i := 0; i < 50; i++ {
+			if err := mp.Admit(mp.Factory().NewTransaction([]byte(fmt.Sprintf("tx-%d", i)), 1)); err != nil {
+				t.Errorf("Admit(%d): %v", i, err)
+			}
+			clock.Advance(bp.Interval())
+			if block := bp.Step(); block != nil {
+				ids = append(ids, block.ID)
+			}
+		}
+		done <- ids
+	}()
+	var ids []string
+	select {
+	case ids = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("block production blocked by a stalled subscriber")
+	}
+	if len(ids) != 50 {
+		t.Fatalf("%d blocks sealed, want 50", len(ids))
+	}
+
+	// The subscriber receives the blocks in order once it reads
+	for i, id := range ids[:5] {
+		if ev := <-stalled; ev.Block.ID != id {
+			t.Fatalf("event %d is for block %s, want %s", i, ev.Block.ID, id)
+		}
+	}
+}
+
+// fenceFunc checks the lease with a function
+type fenceFunc func() error
+
+func (f fenceFunc) CheckLease() error { return f() }
+
+func TestAbortedBuildKeepsBundles(t *testing.T) {
+	pool := bundle.NewPool(nil)
+	config := DefaultConfig()
+	config.Bundles = pool
+	bp, mp, clock := newTestProcessor(t, "seed", config)
+	bp.SetMaxTransactions(4)
+	pending := admit(t, mp, clock, 3, 2, 1)
+
+	small := &model.Bundle{ID: "small", BlockNumber: 1}
+	large := &model.Bundle{ID: "large", BlockNumber: 1}
+	for i := 0; i < 2; i++ {
+		small.Transactions = append(small.Transactions, mp.Factory().NewTransaction([]byte(fmt.Sprintf("small-%d", i)), 0))
+	}
+	for i := 0; i < 3; i++ {
+		large.Transactions = append(large.Transactions, mp.Factory().NewTransaction([]byte(fmt.Sprintf("large-%d", i)), 0))
+	}
+	for _, b := range []*model.Bundle{small, large} {
+		if err := pool.Add(b, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A build dropped by the fence leaves the bundles for the next attempt
+	lost := errors.New("lease lost")
+	bp.SetFence(fenceFunc(func() error { return lost }))
+	clock.Advance(bp.Interval())
+	if block := bp.Step(); block != nil {
+		t.Fatalf("block %d sealed without the lease", block.Number)
+	}
+	if pool.Len() != 2 {
+		t.Fatalf("pool has %d bundles after the aborted build, want 2", pool.Len())
+	}
+
+	// Bundles count against the limit: the bundle that does not fit is left
+	// out as a whole, and the mempool fills the rest of the block
+	bp.SetFence(fenceFunc(func() error { return nil }))
+	block := bp.Step()
+	if block == nil {
+		t.Fatal("block not sealed")
+	}
+	want := append(txIDs(small.Transactions), pending[0].ID, pending[1].ID)
+	if got := txIDs(block.Transactions); !reflect.DeepEqual(got, want) {
+		t.Errorf("block transactions = %v, want %v", got, want)
+	}
+	if pool.Len() != 0 {
+		t.Errorf("pool has %d bundles after the seal, want none", pool.Len())
+	}
+}
@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned to clients that exceed their request rate
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// idleBucketTTL is how long a full, unused bucket is kept before it is dropped
+const idleBucketTTL = time.Minute
+
+// bucket is a token bucket for a single key
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// Limiter applies a token bucket rate limit per key (e.g. per client IP)
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64 // Tokens added per second (0 = unlimited)
+	burst     int     // Bucket capacity
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time // Current time, replaced in tests
+}
+
+// New creates a limiter allowing rate requests per second with the given burst per key.
+// A rate of 0 disables limiting.
+func New(rate float64, burst int) *Limiter {
+	l := &Limiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+	l.SetLimit(rate, burst)
+
+	return l
+}
+
+// SetLimit changes the rate and burst; existing buckets keep their tokens
+func (l *Limiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if burst < 1 {
+		// Allow at least a single request, and a second worth of requests by default
+		burst = max(1, int(rate))
+	}
+
+	l.rate = rate
+	l.burst = burst
+}
+
+// Limit returns the current rate and burst
+func (l *Limiter) Limit() (float64, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.rate, l.burst
+}
+
+// Allow reports whether a request for key may proceed, consuming a token if so
+func (l *Limiter) Allow(key string) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	// Refill the bucket for the elapsed time
+	b.tokens = min(float64(l.burst), b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate)
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
+
+// sweep drops buckets that have not been used for a while
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleBucketTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idleBucketTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// PeerKey returns the rate limiting key for a remote address, dropping the port
+func PeerKey(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestLimiter creates a limiter whose time only moves when the returned
+// function is called
+func newTestLimiter(rate float64, burst int) (*Limiter, func(time.Duration)) {
+	l := New(rate, burst)
+	now := l.lastSweep
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+// allowed returns how many of n requests for key are allowed
+func allowed(l *Limiter, key string, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if l.Allow(key) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestBurst(t *testing.T) {
+	l, _ := newTestLimiter(10, 5)
+	if n := allowed(l, "a", 10); n != 5 {
+		t.Errorf("%d requests allowed, want the burst of 5", n)
+	}
+	// Keys have their own buckets
+	if n := allowed(l, "b", 10); n != 5 {
+		t.Errorf("%d requests allowed for another key, want 5", n)
+	}
+}
+
+func TestRefill(t *testing.T) {
+	l, advance := newTestLimiter(10, 5)
+	allowed(l, "a", 5)
+	if l.Allow("a") {
+		t.Fatal("request allowed with an empty bucket")
+	}
+
+	advance(300 * time.Millisecond)
+	if n := allowed(l, "a", 10); n != 3 {
+		t.Errorf("%d requests allowed after 300ms, want 3", n)
+	}
+
+	// The bucket does not fill beyond the burst
+	advance(time.Hour)
+	if n := allowed(l, "a", 10); n != 5 {
+		t.Errorf("%d requests allowed after an hour, want 5", n)
+	}
+}
+
+func TestDefaultBurst(t *testing.T) {
+	l, _ := newTestLimiter(3, 0)
+	if _, burst := l.Limit(); burst != 3 {
+		t.Errorf("burst = %d, want a second of requests", burst)
+	}
+	l, _ = newTestLimiter(0.5, 0)
+	if _, burst := l.Limit(); burst != 1 {
+		t.Errorf("burst = %d, want 1", burst)
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	l, _ := newTestLimiter(0, 0)
+	if n := allowed(l, "a", 1000); n != 1000 {
+		t.Errorf("%d of 1000 requests allowed without a limit", n)
+	}
+	var nilLimiter *Limiter
+	if !nilLimiter.Allow("a") {
+		t.Error("nil limiter refused a request")
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	l, advance := newTestLimiter(1, 1)
+	allowed(l, "a", 1)
+
+	// A reload keeps the tokens of existing buckets, and refills at the new rate
+	l.SetLimit(100, 10)
+	if l.Allow("a") {
+		t.Fatal("empty bucket refilled by the new limit")
+	}
+	advance(50 * time.Millisecond)
+	if n := allowed(l, "a", 20); n != 5 {
+		t.Errorf("%d requests allowed after 50ms at 100/s, want 5", n)
+	}
+	if rate, burst := l.Limit(); rate != 100 || burst != 10 {
+		t.Errorf("limit = %v %d, want 100 10", rate, burst)
+	}
+
+	// Disabling the limit allows everything
+	l.SetLimit(0, 0)
+	if n := allowed(l, "a", 100); n != 100 {
+		t.Errorf("%d of 100 requests allowed after the limit was disabled", n)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l, advance := newTestLimiter(10, 5)
+	allowed(l, "a", 5)
+	advance(idleBucketTTL / 2)
+	allowed(l, "b", 1)
+
+	advance(idleBucketTTL/2 + time.Second)
+	l.Allow("c")
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("idle bucket not dropped")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Error("recently used bucket dropped")
+	}
+}
+
+func TestPeerKey(t *testing.T) {
+	for addr, want := range map[string]string{
+		"192.0.2.1:8545":   "192.0.2.1",
+		"[2001:db8::1]:80": "2001:db8::1",
+		"192.0.2.1":        "192.0.2.1",
+		"@":                "@",
+	} {
+		if got := PeerKey(addr); got != want {
+			t.Errorf("PeerKey(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
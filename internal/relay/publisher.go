@@ -0,0 +1,300 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/metrics"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+	"flashblock/internal/signer"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// submitMethod is the JSON-RPC method relays implement to receive blocks
+const submitMethod = "relay_submitBlock"
+
+// queueSize is the number of blocks waiting to be delivered to an endpoint
+const queueSize = 64
+
+// Config holds configuration for the relay publisher
+type Config struct {
+	Endpoints    []string      // HTTP or WebSocket JSON-RPC endpoints of the relays
+	Signer       signer.Signer // Signs the submitted blocks
+	Timeout      time.Duration // Deadline of a single submission
+	MaxRetries   int           // Resubmissions of a block an endpoint did not accept
+	RetryBackoff time.Duration // Wait before the first resubmission, doubled for every further one
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Timeout:      2 * time.Second,
+		MaxRetries:   3,
+		RetryBackoff: 500 * time.Millisecond,
+	}
+}
+
+// SignedBlock is the parameter of relay_submitBlock. The signature is made
+// over the Keccak-256 hash of the block exactly as encoded in the request, so
+// relays verify it on the raw JSON and recover the signer from it.
+type SignedBlock struct {
+	Block     json.RawMessage `json:"block"`
+	Signer    common.Address  `json:"signer"`
+	Signature hexutil.Bytes   `json:"signature"` // 65 byte [R || S || V] secp256k1 signature
+
+	number uint64 // Block number for logging
+}
+
+// EndpointStatus reports the deliveries to a relay endpoint
+type EndpointStatus struct {
+	URL         string `json:"url"`
+	Delivered   uint64 `json:"delivered"`
+	Failed      uint64 `json:"failed"`
+	Queued      int    `json:"queued"`
+	LastBlock   uint64 `json:"last_block"` // Number of the last delivered block
+	LastError   string `json:"last_error,omitempty"`
+	LastLatency string `json:"last_latency,omitempty"` // Time the last delivery took, including retries
+}
+
+// Publisher pushes every block sealed by the processor to the relay
+// endpoints. Each endpoint receives the blocks in order from its own queue,
+// so a slow or unreachable relay does not delay the others; blocks that do
+// not fit into the queue of an endpoint are dropped for it.
+type Publisher struct {
+	processor *processor.BlockProcessor
+	metrics   *metrics.Metrics
+	config    *Config
+	signer    common.Address
+	endpoints []*endpoint
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// endpoint is a relay and the blocks waiting to be delivered to it
+type endpoint struct {
+	url   string
+	queue chan *SignedBlock
+
+	mu     sync.Mutex // Protects client and status
+	client *rpc.Client
+	status EndpointStatus
+}
+
+// New creates a publisher of the sealed blocks of the processor
+func New(bp *processor.BlockProcessor, m *metrics.Metrics, config *Config) (*Publisher, error) {
+	if config.Signer == nil {
+		return nil, errors.New("relay signer is not set")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig().Timeout
+	}
+
+	p := &Publisher{
+		processor: bp,
+		metrics:   m,
+		config:    config,
+		signer:    config.Signer.Address(),
+		quit:      make(chan struct{}),
+	}
+	for _, url := range config.Endpoints {
+		p.endpoints = append(p.endpoints, &endpoint{
+			url:    url,
+			queue:  make(chan *SignedBlock, queueSize),
+			status: EndpointStatus{URL: url},
+		})
+	}
+	return p, nil
+}
+
+// Signer returns the address the submitted blocks are signed by
+func (p *Publisher) Signer() common.Address {
+	return p.signer
+}
+
+// Start publishes the sealed blocks until the publisher is closed
+func (p *Publisher) Start() {
+	blocks := make(chan events.BlockSealed, queueSize)
+	sub := p.processor.Events().BlockSealed.Subscribe(blocks)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-blocks:
+				// Only blocks built by this node are submitted
+				if !ev.Imported {
+					p.enqueue(ev.Block)
+				}
+			case <-sub.Err():
+				return
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+
+	for _, e := range p.endpoints {
+		p.wg.Add(1)
+		go func(e *endpoint) {
+			defer p.wg.Done()
+			p.deliverLoop(e)
+		}(e)
+	}
+}
+
+// Close stops publishing; blocks still queued are not delivered
+func (p *Publisher) Close() {
+	close(p.quit)
+	p.wg.Wait()
+
+	for _, e := range p.endpoints {
+		e.mu.Lock()
+		if e.client != nil {
+			e.client.Close()
+			e.client = nil
+		}
+		e.mu.Unlock()
+	}
+}
+
+// Status returns the delivery status of every endpoint
+func (p *Publisher) Status() []EndpointStatus {
+	status := make([]EndpointStatus, len(p.endpoints))
+	for i, e := range p.endpoints {
+		e.mu.Lock()
+		status[i] = e.status
+		e.mu.Unlock()
+		status[i].Queued = len(e.queue)
+	}
+	return status
+}
+
+// enqueue signs a block and queues it for every endpoint
+func (p *Publisher) enqueue(block *model.Block) {
+	signed, err := p.sign(block)
+	if err != nil {
+		logging.Errorf("Failed to sign block %d for the relays: %v", block.Number, err)
+		return
+	}
+
+	for _, e := range p.endpoints {
+		select {
+		case e.queue <- signed:
+		default:
+			logging.Warnf("Relay %s is falling behind, dropping block %d", e.url, block.Number)
+			p.metrics.IncrementRelayFailures()
+			e.mu.Lock()
+			e.status.Failed++
+			e.mu.Unlock()
+		}
+	}
+}
+
+// sign encodes a block and signs the encoding
+func (p *Publisher) sign(block *model.Block) (*SignedBlock, error) {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := p.config.Signer.SignHash(crypto.Keccak256Hash(data))
+	if err != nil {
+		return nil, err
+	}
+	return &SignedBlock{Block: data, Signer: p.signer, Signature: signature, number: block.Number}, nil
+}
+
+// deliverLoop delivers the queued blocks of an endpoint until the publisher is closed
+func (p *Publisher) deliverLoop(e *endpoint) {
+	for {
+		select {
+		case signed := <-e.queue:
+			p.deliver(e, signed)
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// deliver submits a block to an endpoint, retrying with exponential backoff
+func (p *Publisher) deliver(e *endpoint, signed *SignedBlock) {
+	start := time.Now()
+	backoff := p.config.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			p.metrics.IncrementRelayRetries()
+			select {
+			case <-time.After(backoff):
+			case <-p.quit:
+				return
+			}
+			backoff *= 2
+		}
+
+		if err = p.submit(e, signed); err == nil {
+			p.metrics.IncrementRelayDeliveries()
+			e.mu.Lock()
+			e.status.Delivered++
+			e.status.LastBlock = signed.number
+			e.status.LastError = ""
+			e.status.LastLatency = time.Since(start).String()
+			e.mu.Unlock()
+			return
+		}
+		logging.Debugf("Relay %s did not accept block %d (attempt %d): %v", e.url, signed.number, attempt+1, err)
+	}
+
+	logging.Warnf("Failed to deliver block %d to relay %s: %v", signed.number, e.url, err)
+	p.metrics.IncrementRelayFailures()
+	e.mu.Lock()
+	e.status.Failed++
+	e.status.LastError = err.Error()
+	e.mu.Unlock()
+}
+
+// submit sends a block to an endpoint once, connecting first if needed. The
+// connection is dropped after an error so the next attempt reconnects.
+func (p *Publisher) submit(e *endpoint, signed *SignedBlock) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+
+	e.mu.Lock()
+	client := e.client
+	e.mu.Unlock()
+	if client == nil {
+		var err error
+		if client, err = rpc.DialContext(ctx, e.url); err != nil {
+			return fmt.Errorf("failed to connect: %v", err)
+		}
+		e.mu.Lock()
+		e.client = client
+		e.mu.Unlock()
+	}
+
+	err := client.CallContext(ctx, nil, submitMethod, signed)
+	if err != nil {
+		// Errors returned by the relay do not affect the connection
+		if _, ok := err.(rpc.Error); !ok {
+			e.mu.Lock()
+			e.client = nil
+			e.mu.Unlock()
+			client.Close()
+		}
+	}
+	return err
+}
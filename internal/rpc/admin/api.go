@@ -0,0 +1,361 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"flashblock/internal/config"
+	"flashblock/internal/logging"
+	"flashblock/internal/p2p"
+	"flashblock/internal/relay"
+	"flashblock/internal/state"
+	"flashblock/internal/store"
+	"flashblock/internal/trustedtime"
+	"flashblock/internal/version"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ConfigManager gives access to the active server configuration
+type ConfigManager interface {
+	Current() config.Config
+	SetConfig(values map[string]string, caller string) ([]config.Change, error)
+}
+
+// MaintenanceManager switches the node in and out of maintenance mode
+type MaintenanceManager interface {
+	Maintenance() MaintenanceStatus
+	SetMaintenance(status MaintenanceStatus, caller string) MaintenanceStatus
+}
+
+// PeerManager reports the connected p2p peers
+type PeerManager interface {
+	Peers() []p2p.PeerInfo
+}
+
+// RelayManager reports the deliveries of sealed blocks to the relays
+type RelayManager interface {
+	Status() []relay.EndpointStatus
+}
+
+// TimeManager reports the synchronization of the trusted time source
+type TimeManager interface {
+	Status() trustedtime.Status
+}
+
+// PruneManager prunes the block store under its retention policy
+type PruneManager interface {
+	Prune() (*store.PruneResult, error)
+	Status() store.PruneStatus
+}
+
+// StorageManager reports the disk usage of the data directory and prunes the
+// account state
+type StorageManager interface {
+	Storage() (*StorageReport, error)
+	PruneState() (*state.PruneResult, error)
+}
+
+// StorageReport describes the disk usage of the data directory and the space
+// pruning would free now under the configured policies
+type StorageReport struct {
+	Path        string           `json:"path"`
+	Dirs        map[string]int64 `json:"dirs"` // Bytes by subdirectory
+	Total       int64            `json:"total"`
+	Blocks      *store.Usage     `json:"blocks"`
+	State       *StateUsage      `json:"state"`
+	Reclaimable int64            `json:"reclaimable"` // Estimated bytes freed by pruning the blocks and the state
+}
+
+// StateUsage describes the disk usage of the account state
+type StateUsage struct {
+	Bytes            int64             `json:"bytes"`
+	FinalizedBlock   uint64            `json:"finalized_block"`   // States of older blocks are pruned
+	ReclaimableBytes int64             `json:"reclaimable_bytes"` // Size of the unreachable entries before compaction
+	ReclaimableNodes int               `json:"reclaimable_nodes"`
+	ReclaimableCode  int               `json:"reclaimable_code"`
+	Pruning          state.PruneStatus `json:"pruning"`
+}
+
+// MaintenanceStatus describes the maintenance mode of the node
+type MaintenanceStatus struct {
+	Enabled     bool       `json:"enabled"`
+	PauseBlocks bool       `json:"pause_blocks"`    // Block production is paused
+	Reason      string     `json:"reason"`          // Returned to rejected submitters
+	Since       *time.Time `json:"since,omitempty"` // When maintenance mode was entered
+}
+
+// MaintenanceArgs represents the parameters of the setMaintenance method
+type MaintenanceArgs struct {
+	PauseBlocks bool   `json:"pause_blocks"`
+	Reason      string `json:"reason"`
+}
+
+// API defines the Admin RPC methods. The admin namespace is only served on the
+// unix socket, so access is controlled by the socket file permissions.
+type API struct {
+	endpoints   map[string]string
+	config      ConfigManager
+	maintenance MaintenanceManager
+	peers       PeerManager
+	relays      RelayManager
+	pruner      PruneManager
+	storage     StorageManager
+	clock       TimeManager
+	startTime   time.Time
+}
+
+// NodeInfoResult describes the running server
+type NodeInfoResult struct {
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit"`
+	BuildDate string            `json:"build_date"`
+	StartTime time.Time         `json:"start_time"`
+	Uptime    string            `json:"uptime"`
+	Endpoints map[string]string `json:"endpoints"` // Listen addresses by surface
+}
+
+// ConfigChange describes a changed setting
+type ConfigChange struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// SetConfigResult represents the result of the setConfig method
+type SetConfigResult struct {
+	Changes []ConfigChange `json:"changes"`
+}
+
+// GetConfigResult represents the result of the getConfig method
+type GetConfigResult struct {
+	Config   string   `json:"config"`   // Active configuration as YAML, secrets redacted
+	Settable []string `json:"settable"` // Settings accepted by setConfig
+}
+
+// NewAPI creates a new Admin API; endpoints lists the listen addresses by surface
+func NewAPI(endpoints map[string]string, config ConfigManager, maintenance MaintenanceManager, peers PeerManager, relays RelayManager, pruner PruneManager, storage StorageManager, clock TimeManager) *API {
+	return &API{
+		endpoints:   endpoints,
+		config:      config,
+		maintenance: maintenance,
+		peers:       peers,
+		relays:      relays,
+		pruner:      pruner,
+		storage:     storage,
+		clock:       clock,
+		startTime:   time.Now(),
+	}
+}
+
+// NodeInfo returns the version and endpoints of the server
+func (api *API) NodeInfo() (*NodeInfoResult, error) {
+	return &NodeInfoResult{
+		Version:   version.Version,
+		Commit:    version.Commit,
+		BuildDate: version.Date,
+		StartTime: api.startTime,
+		Uptime:    time.Since(api.startTime).String(),
+		Endpoints: api.endpoints,
+	}, nil
+}
+
+// GetConfig returns the active configuration
+func (api *API) GetConfig() (*GetConfigResult, error) {
+	if api.config == nil {
+		return nil, errors.New("configuration is not available")
+	}
+
+	cfg := api.config.Current()
+	data, err := cfg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetConfigResult{
+		Config:   string(data),
+		Settable: config.SettableKeys(),
+	}, nil
+}
+
+// SetConfig changes settings given by YAML path, e.g. {"block.interval": "500ms"}.
+// The changes are applied together, or not at all if any value is invalid.
+func (api *API) SetConfig(ctx context.Context, values map[string]any) (*SetConfigResult, error) {
+	if api.config == nil {
+		return nil, errors.New("configuration is not available")
+	}
+	if len(values) == 0 {
+		return nil, errors.New("no settings given")
+	}
+
+	settings := make(map[string]string, len(values))
+	for key, value := range values {
+		s, err := formatValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+		settings[key] = s
+	}
+
+	changes, err := api.config.SetConfig(settings, caller(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SetConfigResult{Changes: make([]ConfigChange, len(changes))}
+	for i, change := range changes {
+		result.Changes[i] = ConfigChange{
+			Key: change.Key,
+			Old: fmt.Sprint(change.Old),
+			New: fmt.Sprint(change.New),
+		}
+	}
+	return result, nil
+}
+
+// SetLogLevel changes the log level (debug, info, warn or error) without a restart,
+// e.g. to enable verbose logging during an incident. The change is audit-logged like
+// any other setConfig change and is reverted by a configuration reload.
+func (api *API) SetLogLevel(ctx context.Context, level string) (*SetConfigResult, error) {
+	return api.SetConfig(ctx, map[string]any{"log.level": level})
+}
+
+// GetLogLevel returns the current log level
+func (api *API) GetLogLevel() string {
+	return logging.GetLevel().String()
+}
+
+// SetMaintenance enters maintenance mode (enabled true) or leaves it. While in
+// maintenance, submissions are rejected with a dedicated error code and reads keep
+// working; block production is paused if pause_blocks is set.
+func (api *API) SetMaintenance(ctx context.Context, enabled bool, args *MaintenanceArgs) (*MaintenanceStatus, error) {
+	if api.maintenance == nil {
+		return nil, errors.New("maintenance mode is not available")
+	}
+
+	status := MaintenanceStatus{Enabled: enabled}
+	if enabled && args != nil {
+		status.PauseBlocks = args.PauseBlocks
+		status.Reason = args.Reason
+	}
+
+	status = api.maintenance.SetMaintenance(status, caller(ctx))
+	return &status, nil
+}
+
+// GetMaintenance returns the maintenance mode of the node
+func (api *API) GetMaintenance() (*MaintenanceStatus, error) {
+	if api.maintenance == nil {
+		return nil, errors.New("maintenance mode is not available")
+	}
+
+	status := api.maintenance.Maintenance()
+	return &status, nil
+}
+
+// Peers returns the connected p2p peers with their scores
+func (api *API) Peers() ([]p2p.PeerInfo, error) {
+	if api.peers == nil {
+		return nil, errors.New("p2p networking is not enabled")
+	}
+	return api.peers.Peers(), nil
+}
+
+// Relays returns the delivery status of the relay endpoints
+func (api *API) Relays() ([]relay.EndpointStatus, error) {
+	if api.relays == nil {
+		return nil, errors.New("relay publishing is not enabled")
+	}
+	return api.relays.Status(), nil
+}
+
+// TrustedTime returns the synchronization of the trusted time source of the
+// block timestamps
+func (api *API) TrustedTime() (*trustedtime.Status, error) {
+	if api.clock == nil {
+		return nil, errors.New("trusted time is not enabled")
+	}
+	status := api.clock.Status()
+	return &status, nil
+}
+
+// Prune removes the blocks outside the retention window from the block store
+// now instead of waiting for the next background pass
+func (api *API) Prune(ctx context.Context) (*store.PruneResult, error) {
+	if api.pruner == nil {
+		return nil, errors.New("block store is not enabled")
+	}
+	logging.Infof("AUDIT: pruning triggered by %s", caller(ctx))
+	return api.pruner.Prune()
+}
+
+// Pruning returns the retention policy of the block store and the result of
+// the last pruning pass
+func (api *API) Pruning() (*store.PruneStatus, error) {
+	if api.pruner == nil {
+		return nil, errors.New("block store is not enabled")
+	}
+	status := api.pruner.Status()
+	return &status, nil
+}
+
+// Storage reports the disk usage of the block store, the account state and the
+// other stores in the data directory, and the space pruning would free now
+func (api *API) Storage() (*StorageReport, error) {
+	if api.storage == nil {
+		return nil, errors.New("data directory is not enabled")
+	}
+	return api.storage.Storage()
+}
+
+// PruneState removes the account states below the finalized checkpoint and
+// compacts the state database now instead of waiting for the next background pass
+func (api *API) PruneState(ctx context.Context) (*state.PruneResult, error) {
+	if api.storage == nil {
+		return nil, errors.New("data directory is not enabled")
+	}
+	logging.Infof("AUDIT: state pruning triggered by %s", caller(ctx))
+	return api.storage.PruneState()
+}
+
+// formatValue converts a JSON value to the string format used by the configuration
+func formatValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", errors.New("lists must contain strings")
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// caller describes the client of a request for audit logging
+func caller(ctx context.Context) string {
+	peer := rpc.PeerInfoFromContext(ctx)
+
+	who := peer.Transport + " client"
+	if peer.RemoteAddr != "" {
+		who += " " + peer.RemoteAddr
+	}
+	if peer.HTTP.UserAgent != "" {
+		who += fmt.Sprintf(" (%s)", peer.HTTP.UserAgent)
+	}
+	return who
+}
@@ -0,0 +1,38 @@
+package rpc
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// newAuthHandler wraps next so that requests must carry one of the given bearer
+// tokens. Authentication is disabled when no tokens are configured.
+func newAuthHandler(next http.Handler, tokens []string) http.Handler {
+	if len(tokens) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !validToken(token, tokens) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validToken reports whether token matches one of the accepted tokens
+func validToken(token string, tokens []string) bool {
+	valid := false
+	for _, t := range tokens {
+		// Compare every token in constant time
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
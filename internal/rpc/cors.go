@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// corsPolicy is a CORS origin policy that can be replaced at runtime. Browser
+// requests from other origins are rejected, including WebSocket upgrades.
+type corsPolicy struct {
+	origins atomic.Pointer[[]string]
+}
+
+// newCORSPolicy creates a policy with the given allowed origins ("*" allows any origin)
+func newCORSPolicy(origins []string) *corsPolicy {
+	p := &corsPolicy{}
+	p.SetOrigins(origins)
+	return p
+}
+
+// SetOrigins replaces the allowed origins
+func (p *corsPolicy) SetOrigins(origins []string) {
+	allowed := make([]string, len(origins))
+	copy(allowed, origins)
+	p.origins.Store(&allowed)
+}
+
+// allowed reports whether the origin may access the server
+func (p *corsPolicy) allowed(origin string) bool {
+	for _, o := range *p.origins.Load() {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler wraps next so that it applies the policy
+func (p *corsPolicy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			// Not a browser request
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !p.allowed(origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+
+		// Answer preflight requests directly
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package rpc
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// dashboardHTML is the status dashboard page; it polls flash_getMetrics from the browser
+//
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// explorerHTML is the block explorer page; it browses blocks, transactions and
+// the mempool through the flash RPCs from the browser
+//
+//go:embed explorer.html
+var explorerHTML []byte
+
+// pageHandler serves an embedded page. The pages themselves contain no data,
+// so they are served without authentication; their RPC calls are authenticated.
+func pageHandler(page []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+}
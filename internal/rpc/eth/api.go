@@ -1,20 +1,45 @@
 package eth
 
 import (
+	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"flashblock/internal/blobs"
+	"flashblock/internal/bundle"
 	"flashblock/internal/eth"
+	"flashblock/internal/logging"
 	"flashblock/internal/mempool"
+	"flashblock/internal/model"
+	"flashblock/internal/preconf"
+	"flashblock/internal/processor"
+	"flashblock/internal/ratelimit"
+	"flashblock/internal/state"
+	"flashblock/internal/txindex"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
-// TransactionHook is a function called when a transaction is processed
-type TransactionHook = mempool.TransactionHook
+// errNoState is returned by the state methods when the node does not track the account state
+var errNoState = errors.New("account state is not available")
 
 // API represents the Ethereum compatible JSON-RPC API
 type API struct {
-	mempool *mempool.Mempool
+	mempool   *mempool.Mempool
+	processor *processor.BlockProcessor
+	state     *state.DB
+	limiter   *ratelimit.Limiter
+	bundles   *bundle.Pool
+	preconf   *preconf.Issuer // Signs the receipts of admitted transactions (optional)
+	blobs     *blobs.Store    // Keeps the sidecars of blob transactions (optional)
+	role      string          // Deployment role; RPC front-end nodes do not accept private transactions
 }
 
 // SendRawTransactionArgs represents the arguments for eth_sendRawTransaction
@@ -28,39 +53,293 @@ type SendRawTransactionResult struct {
 }
 
 // NewAPI creates a new Ethereum API instance
-func NewAPI(mempool *mempool.Mempool, hooks []TransactionHook) *API {
+func NewAPI(mempool *mempool.Mempool, processor *processor.BlockProcessor, state *state.DB, limiter *ratelimit.Limiter) *API {
 	return &API{
-		mempool: mempool,
+		mempool:   mempool,
+		processor: processor,
+		state:     state,
+		limiter:   limiter,
+	}
+}
+
+// SetRole sets the deployment role of the node (all, rpc or builder)
+func (api *API) SetRole(role string) {
+	api.role = role
+}
+
+// SetBundles sets the pool receiving the bundles of eth_sendBundle
+func (api *API) SetBundles(pool *bundle.Pool) {
+	api.bundles = pool
+}
+
+// SetPreconfIssuer sets the issuer of the receipts of admitted transactions,
+// which are fetched with flash_getPreconfirmation
+func (api *API) SetPreconfIssuer(issuer *preconf.Issuer) {
+	api.preconf = issuer
+}
+
+// SetBlobStore sets the store of the sidecars of blob transactions. Blob
+// transactions are rejected without one.
+func (api *API) SetBlobStore(store *blobs.Store) {
+	api.blobs = store
+}
+
+// ChainId implements the eth_chainId RPC method
+func (api *API) ChainId() (hexutil.Uint64, error) {
+	if api.state == nil {
+		return 0, errNoState
+	}
+	return hexutil.Uint64(api.state.ChainID()), nil
+}
+
+// BlockNumber implements the eth_blockNumber RPC method
+func (api *API) BlockNumber() hexutil.Uint64 {
+	if api.processor == nil {
+		return 0
+	}
+	_, number := api.processor.LatestBlock()
+	return hexutil.Uint64(number)
+}
+
+// GetBalance implements the eth_getBalance RPC method
+func (api *API) GetBalance(address common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	statedb, err := api.stateAt(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(statedb.GetBalance(address).ToBig()), nil
+}
+
+// GetTransactionCount implements the eth_getTransactionCount RPC method. The
+// pending count includes consecutive nonces of the sender's pending transactions.
+func (api *API) GetTransactionCount(address common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	statedb, err := api.stateAt(blockNrOrHash)
+	if err != nil {
+		return 0, err
+	}
+	nonce := statedb.GetNonce(address)
+
+	if blockNrOrHash != nil {
+		if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+			pending := make(map[uint64]bool)
+			for _, tx := range api.mempool.GetAllTransactions() {
+				if tx.From != "" && common.HexToAddress(tx.From) == address {
+					pending[tx.Nonce] = true
+				}
+			}
+			for pending[nonce] {
+				nonce++
+			}
+		}
+	}
+	return hexutil.Uint64(nonce), nil
+}
+
+// GetCode implements the eth_getCode RPC method
+func (api *API) GetCode(address common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	statedb, err := api.stateAt(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetCode(address), nil
+}
+
+// GetStorageAt implements the eth_getStorageAt RPC method
+func (api *API) GetStorageAt(address common.Address, slot string, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	key, err := hexutil.DecodeBig(slot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage slot %q: %v", slot, err)
+	}
+	statedb, err := api.stateAt(blockNrOrHash)
+	if err != nil {
+		return nil, err
 	}
+	value := statedb.GetState(address, common.BigToHash(key))
+	return value[:], nil
+}
+
+// stateAt returns the account state after the given block. The latest and
+// pending states are the head state; other blocks must be kept in memory.
+// A missing block argument selects the latest block.
+func (api *API) stateAt(blockNrOrHash *rpc.BlockNumberOrHash) (*gethstate.StateDB, error) {
+	if api.state == nil {
+		return nil, errNoState
+	}
+	if blockNrOrHash == nil {
+		return api.state.StateAt(api.state.Head())
+	}
+
+	var block *model.Block
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block = api.getBlock(strings.TrimPrefix(hash.Hex(), "0x"))
+		if block == nil {
+			return nil, fmt.Errorf("block %s is not available", hash.Hex())
+		}
+	} else {
+		number, _ := blockNrOrHash.Number()
+		switch {
+		case number == rpc.EarliestBlockNumber || number == 0:
+			return api.state.StateAt(api.state.GenesisRoot())
+		case number < 0:
+			// Latest, pending, safe and finalized are the same on a single builder
+			return api.state.StateAt(api.state.Head())
+		}
+		block = api.getBlockByNumber(uint64(number))
+		if block == nil {
+			return nil, fmt.Errorf("block %d is not available", number)
+		}
+	}
+
+	if block.StateRoot == "" {
+		return nil, fmt.Errorf("block %d has no state root", block.Number)
+	}
+	return api.state.StateAt(common.HexToHash(block.StateRoot))
+}
+
+// getBlock returns a block kept in memory by ID
+func (api *API) getBlock(id string) *model.Block {
+	if api.processor == nil {
+		return nil
+	}
+	return api.processor.GetBlock(id)
+}
+
+// getBlockByNumber returns a block kept in memory by number
+func (api *API) getBlockByNumber(number uint64) *model.Block {
+	if api.processor == nil {
+		return nil
+	}
+	blocks := api.processor.GetProcessedBlocks()
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if blocks[i].Number == number {
+			return blocks[i]
+		}
+	}
+	return nil
 }
 
 // SendRawTransaction implements the eth_sendRawTransaction RPC method
-func (api *API) SendRawTransaction(rawTx string) (string, error) {
+func (api *API) SendRawTransaction(ctx context.Context, rawTx string) (string, error) {
+	// Apply the per-client submission rate limit
+	if !api.limiter.Allow(ratelimit.PeerKey(rpc.PeerInfoFromContext(ctx).RemoteAddr)) {
+		return "", ratelimit.ErrRateLimited
+	}
+
 	// Remove "0x" prefix if present
 	rawTx = strings.TrimPrefix(rawTx, "0x")
 
-	// Parse the raw transaction
-	tx, err := eth.ParseRawTransaction(rawTx)
+	// Parse the raw transaction, storing the sidecar of a blob transaction
+	tx, sidecar, err := api.parseRawTransaction(rawTx)
 	if err != nil {
-		return "", fmt.Errorf("invalid raw transaction: %w", err)
+		return "", err
 	}
 
-	// Add transaction to mempool
-	api.mempool.AddTransaction(tx)
+	// Add transaction to mempool; resubmitting a known transaction is not an error
+	err = api.mempool.Admit(tx)
+	if err != nil && err != mempool.ErrDuplicate {
+		if sidecar {
+			api.blobs.Remove(tx.ID)
+		}
+		return "", err
+	}
+	if err == nil && api.preconf != nil {
+		if _, err := api.preconf.Issue(tx); errors.Is(err, preconf.ErrNotSchedulable) {
+			logging.Debugf("Not preconfirming transaction %s: %v", tx.ID, err)
+		} else if err != nil {
+			logging.Errorf("Failed to preconfirm transaction %s: %v", tx.ID, err)
+		}
+	}
 
 	// Return the transaction hash (ID)
 	return "0x" + tx.ID, nil
 }
 
+// PrivateTxOptions are the options of eth_sendPrivateRawTransaction
+type PrivateTxOptions struct {
+	TTL    uint64 `json:"ttl"`    // Privacy TTL in seconds (server default if zero)
+	Expire bool   `json:"expire"` // Drop the transaction instead of publishing it after the TTL
+}
+
+// errPrivateOnRPCNode rejects private transactions on RPC front-end nodes,
+// which only reach the builder through the public mempool feed
+var errPrivateOnRPCNode = errors.New("private transactions must be sent to a block-building node")
+
+// SendPrivateRawTransaction implements the eth_sendPrivateRawTransaction RPC
+// method. The transaction is eligible for inclusion but not exposed through
+// mempool queries, gossip or pending subscriptions until its privacy TTL ends.
+func (api *API) SendPrivateRawTransaction(ctx context.Context, rawTx string, opts *PrivateTxOptions) (string, error) {
+	// Apply the per-client submission rate limit
+	if !api.limiter.Allow(ratelimit.PeerKey(rpc.PeerInfoFromContext(ctx).RemoteAddr)) {
+		return "", ratelimit.ErrRateLimited
+	}
+	if api.role == "rpc" {
+		return "", errPrivateOnRPCNode
+	}
+
+	tx, sidecar, err := api.parseRawTransaction(strings.TrimPrefix(rawTx, "0x"))
+	if err != nil {
+		return "", err
+	}
+
+	var ttl time.Duration
+	var expire bool
+	if opts != nil {
+		ttl = time.Duration(opts.TTL) * time.Second
+		expire = opts.Expire
+	}
+	if err := api.mempool.AdmitPrivate(tx, ttl, expire); err != nil && err != mempool.ErrDuplicate {
+		if sidecar {
+			api.blobs.Remove(tx.ID)
+		}
+		return "", err
+	}
+	return "0x" + tx.ID, nil
+}
+
+// errNoBlobs rejects blob transactions on nodes that do not keep sidecars
+var errNoBlobs = errors.New("blob transactions are not accepted by this node")
+
+// parseRawTransaction decodes a raw transaction. The sidecar of a blob
+// transaction is verified against its versioned hashes and stored before the
+// transaction is admitted; sidecar reports whether one was stored.
+func (api *API) parseRawTransaction(rawTx string) (tx *model.Transaction, sidecar bool, err error) {
+	ethTx, err := eth.DecodeRawTransaction(rawTx)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid raw transaction: %w", err)
+	}
+	if tx, err = eth.ConvertToModelTransaction(api.mempool.Factory(), ethTx, rawTx); err != nil {
+		return nil, false, fmt.Errorf("invalid raw transaction: %w", err)
+	}
+	if ethTx.Type() != types.BlobTxType {
+		return tx, false, nil
+	}
+
+	if api.blobs == nil {
+		return nil, false, errNoBlobs
+	}
+	if err := eth.VerifyBlobSidecar(ethTx); err != nil {
+		return nil, false, fmt.Errorf("invalid blob transaction: %w", err)
+	}
+	if err := api.blobs.Put(tx.ID, ethTx.BlobTxSidecar()); err != nil {
+		logging.Errorf("Failed to store the blob sidecar of %s: %v", tx.ID, err)
+		return nil, false, errors.New("failed to store blob sidecar")
+	}
+	return tx, true, nil
+}
+
 // GetTransactionByHash implements the eth_getTransactionByHash RPC method
 func (api *API) GetTransactionByHash(hash string) (map[string]any, error) {
 	// Remove "0x" prefix if present
 	hash = strings.TrimPrefix(hash, "0x")
 
-	// Get transaction from mempool
+	// Get transaction from mempool, or from a recent block if it was included
 	tx, exists := api.mempool.GetTransaction(hash)
+	var loc *txindex.Location
 	if !exists {
-		return nil, nil // Return null if transaction not found
+		tx, loc = api.findIncluded(hash)
+		if tx == nil {
+			return nil, nil // Return null if transaction not found
+		}
 	}
 
 	// Convert to Ethereum format
@@ -94,13 +373,107 @@ func (api *API) GetTransactionByHash(hash string) (map[string]any, error) {
 	if tx.Nonce > 0 {
 		result["nonce"] = fmt.Sprintf("0x%x", tx.Nonce)
 	}
+	if loc != nil {
+		result["blockHash"] = "0x" + loc.BlockID
+		result["blockNumber"] = fmt.Sprintf("0x%x", loc.BlockNumber)
+		result["transactionIndex"] = fmt.Sprintf("0x%x", loc.Index)
+	}
 
 	return result, nil
 }
 
-// GetTransactionReceipt implements the eth_getTransactionReceipt RPC method
+// GetTransactionReceipt implements the eth_getTransactionReceipt RPC method.
+// Receipts are available for included transactions. The status and gas used
+// are always known; the cumulative gas, created contract and logs are reported
+// while the block is kept in memory.
 func (api *API) GetTransactionReceipt(hash string) (map[string]any, error) {
-	// This is a simplified version that will always return null
-	// In a real implementation, you would check if the transaction is in a processed block
-	return nil, nil
+	// Remove "0x" prefix if present
+	hash = strings.TrimPrefix(hash, "0x")
+
+	if api.processor == nil {
+		return nil, nil
+	}
+	loc, ok := api.processor.LookupTransaction(hash)
+	if !ok {
+		return nil, nil // Pending and unknown transactions have no receipt
+	}
+
+	status := "0x1"
+	if loc.Failed {
+		status = "0x0"
+	}
+	result := map[string]any{
+		"transactionHash":   "0x" + hash,
+		"transactionIndex":  fmt.Sprintf("0x%x", loc.Index),
+		"blockHash":         "0x" + loc.BlockID,
+		"blockNumber":       fmt.Sprintf("0x%x", loc.BlockNumber),
+		"from":              nil,
+		"to":                nil,
+		"status":            status,
+		"gasUsed":           fmt.Sprintf("0x%x", loc.GasUsed),
+		"cumulativeGasUsed": fmt.Sprintf("0x%x", loc.GasUsed),
+		"contractAddress":   nil,
+		"logs":              []any{},
+	}
+
+	// The sender, recipient and execution details are known while the block is kept in memory
+	block := api.getBlock(loc.BlockID)
+	if block == nil {
+		return result, nil
+	}
+	tx := block.Transactions[loc.Index]
+	if tx.From != "" {
+		result["from"] = tx.From
+	}
+	if tx.To != "" {
+		result["to"] = tx.To
+	}
+	if len(block.Receipts) != len(block.Transactions) {
+		return result, nil // Created before the transactions were executed
+	}
+
+	receipt := block.Receipts[loc.Index]
+	result["cumulativeGasUsed"] = fmt.Sprintf("0x%x", receipt.CumulativeGasUsed)
+	if receipt.ContractAddress != "" {
+		result["contractAddress"] = receipt.ContractAddress
+	}
+
+	// Log indexes count the logs of the whole block
+	logIndex := 0
+	for _, r := range block.Receipts[:loc.Index] {
+		logIndex += len(r.Logs)
+	}
+	logs := make([]map[string]any, len(receipt.Logs))
+	for i, l := range receipt.Logs {
+		logs[i] = map[string]any{
+			"address":          l.Address,
+			"topics":           l.Topics,
+			"data":             "0x" + hex.EncodeToString(l.Data),
+			"blockHash":        "0x" + block.ID,
+			"blockNumber":      fmt.Sprintf("0x%x", block.Number),
+			"transactionHash":  "0x" + hash,
+			"transactionIndex": fmt.Sprintf("0x%x", loc.Index),
+			"logIndex":         fmt.Sprintf("0x%x", logIndex+i),
+			"removed":          false,
+		}
+	}
+	result["logs"] = logs
+
+	return result, nil
+}
+
+// findIncluded returns an included transaction and its location if its block is kept in memory
+func (api *API) findIncluded(hash string) (*model.Transaction, *txindex.Location) {
+	if api.processor == nil {
+		return nil, nil
+	}
+	loc, ok := api.processor.LookupTransaction(hash)
+	if !ok {
+		return nil, nil
+	}
+	block := api.processor.GetBlock(loc.BlockID)
+	if block == nil {
+		return nil, nil
+	}
+	return block.Transactions[loc.Index], &loc
 }
@@ -0,0 +1,200 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"flashblock/internal/eth"
+	"flashblock/internal/model"
+	"flashblock/internal/ratelimit"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// errNoBundles is returned by eth_sendBundle when the node does not build blocks
+var errNoBundles = errors.New("bundles are not accepted by this node")
+
+// SendBundleArgs represents the arguments of eth_sendBundle
+type SendBundleArgs struct {
+	Txs               []string       `json:"txs"`                    // Raw signed transactions in inclusion order
+	BlockNumber       hexutil.Uint64 `json:"blockNumber"`            // Target block
+	MinTimestamp      uint64         `json:"minTimestamp,omitempty"` // Earliest block time in Unix seconds
+	MaxTimestamp      uint64         `json:"maxTimestamp,omitempty"` // Latest block time in Unix seconds
+	RevertingTxHashes []common.Hash  `json:"revertingTxHashes,omitempty"`
+}
+
+// SendBundleResult represents the result of eth_sendBundle
+type SendBundleResult struct {
+	BundleHash common.Hash `json:"bundleHash"`
+}
+
+// CallBundleArgs represents the arguments of eth_callBundle
+type CallBundleArgs struct {
+	Txs              []string       `json:"txs"`
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`                // Block the bundle is simulated in (default: the next block)
+	StateBlockNumber string         `json:"stateBlockNumber,omitempty"` // Only "latest" is supported
+	Timestamp        uint64         `json:"timestamp,omitempty"`        // Block time in Unix seconds (default: now)
+}
+
+// CallBundleResult represents the result of eth_callBundle
+type CallBundleResult struct {
+	BundleHash       common.Hash          `json:"bundleHash"`
+	Results          []CallBundleTxResult `json:"results"`
+	TotalGasUsed     uint64               `json:"totalGasUsed"`
+	StateBlockNumber uint64               `json:"stateBlockNumber"`
+}
+
+// CallBundleTxResult is the outcome of a simulated bundle transaction
+type CallBundleTxResult struct {
+	TxHash      common.Hash `json:"txHash"` // Ethereum transaction hash
+	FromAddress string      `json:"fromAddress"`
+	ToAddress   string      `json:"toAddress,omitempty"`
+	GasUsed     uint64      `json:"gasUsed"`
+	Error       string      `json:"error,omitempty"` // Why the transaction reverted or cannot be included
+}
+
+// SendBundle implements the eth_sendBundle RPC method. The bundle is kept
+// private until it is included atomically at the top of its target block;
+// if it cannot be included there, it is dropped.
+func (api *API) SendBundle(ctx context.Context, args SendBundleArgs) (*SendBundleResult, error) {
+	if !api.limiter.Allow(ratelimit.PeerKey(rpc.PeerInfoFromContext(ctx).RemoteAddr)) {
+		return nil, ratelimit.ErrRateLimited
+	}
+	if api.bundles == nil || api.processor == nil {
+		return nil, errNoBundles
+	}
+	if args.BlockNumber == 0 {
+		return nil, errors.New("blockNumber is required")
+	}
+
+	txs, hashes, err := api.parseBundle(args.Txs)
+	if err != nil {
+		return nil, err
+	}
+	b := &model.Bundle{
+		ID:           bundleHash(hashes).Hex()[2:],
+		Transactions: txs,
+		BlockNumber:  uint64(args.BlockNumber),
+		MinTimestamp: args.MinTimestamp,
+		MaxTimestamp: args.MaxTimestamp,
+	}
+
+	// Reverting transactions are given by their Ethereum hash or by their ID on this node
+	for _, hash := range args.RevertingTxHashes {
+		found := false
+		for i, tx := range txs {
+			if hashes[i] == hash || common.HexToHash(tx.ID) == hash {
+				b.RevertingTxIDs = append(b.RevertingTxIDs, tx.ID)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("reverting transaction %s is not part of the bundle", hash.Hex())
+		}
+	}
+
+	_, head := api.processor.LatestBlock()
+	if err := api.bundles.Add(b, head); err != nil {
+		return nil, err
+	}
+	return &SendBundleResult{BundleHash: common.HexToHash(b.ID)}, nil
+}
+
+// CallBundle implements the eth_callBundle RPC method. The transactions are
+// executed in order on top of the latest state without being included, and
+// the outcome of each is returned.
+func (api *API) CallBundle(ctx context.Context, args CallBundleArgs) (*CallBundleResult, error) {
+	if api.state == nil || api.processor == nil {
+		return nil, errNoState
+	}
+	if args.StateBlockNumber != "" && args.StateBlockNumber != "latest" {
+		return nil, fmt.Errorf("unsupported stateBlockNumber %q: only latest is supported", args.StateBlockNumber)
+	}
+
+	txs, hashes, err := api.parseBundle(args.Txs)
+	if err != nil {
+		return nil, err
+	}
+
+	blockTime := time.Now()
+	if args.Timestamp > 0 {
+		blockTime = time.Unix(int64(args.Timestamp), 0)
+	}
+	header := api.processor.NextHeader(blockTime)
+	if args.BlockNumber > 0 {
+		header.Number = uint64(args.BlockNumber)
+	}
+
+	sim, err := api.state.Simulate(header, nil, txs)
+	if err != nil {
+		return nil, err
+	}
+	receipts, errs := sim.Receipts, sim.Errors
+
+	result := &CallBundleResult{
+		BundleHash:       bundleHash(hashes),
+		Results:          make([]CallBundleTxResult, len(txs)),
+		StateBlockNumber: header.Number - 1,
+	}
+	for i, tx := range txs {
+		r := CallBundleTxResult{
+			TxHash:      hashes[i],
+			FromAddress: tx.From,
+			ToAddress:   tx.To,
+		}
+		switch {
+		case errs[i] != nil:
+			r.Error = errs[i].Error()
+		case receipts[i].Status == model.ReceiptStatusFailed:
+			r.GasUsed = receipts[i].GasUsed
+			r.Error = receipts[i].Error
+		default:
+			r.GasUsed = receipts[i].GasUsed
+		}
+		result.TotalGasUsed += r.GasUsed
+		result.Results[i] = r
+	}
+	return result, nil
+}
+
+// parseBundle decodes the raw transactions of a bundle and returns them with
+// their Ethereum hashes. Every transaction must be signed.
+func (api *API) parseBundle(rawTxs []string) ([]*model.Transaction, []common.Hash, error) {
+	if len(rawTxs) == 0 {
+		return nil, nil, errors.New("bundle has no transactions")
+	}
+
+	txs := make([]*model.Transaction, len(rawTxs))
+	hashes := make([]common.Hash, len(rawTxs))
+	for i, raw := range rawTxs {
+		raw = strings.TrimPrefix(raw, "0x")
+		ethTx, err := eth.DecodeRawTransaction(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid raw transaction %d: %w", i, err)
+		}
+		tx, err := eth.ConvertToModelTransaction(api.mempool.Factory(), ethTx, raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid raw transaction %d: %w", i, err)
+		}
+		if tx.From == "" {
+			return nil, nil, fmt.Errorf("invalid raw transaction %d: invalid signature", i)
+		}
+		txs[i], hashes[i] = tx, ethTx.Hash()
+	}
+	return txs, hashes, nil
+}
+
+// bundleHash identifies a bundle by the hash of its transaction hashes
+func bundleHash(hashes []common.Hash) common.Hash {
+	var data []byte
+	for _, hash := range hashes {
+		data = append(data, hash.Bytes()...)
+	}
+	return crypto.Keccak256Hash(data)
+}
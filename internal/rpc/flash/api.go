@@ -1,23 +1,47 @@
 package flash
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"time"
 
+	"flashblock/internal/batcher"
+	"flashblock/internal/blobs"
+	"flashblock/internal/encrypted"
+	"flashblock/internal/events"
+	"flashblock/internal/feemarket"
+	"flashblock/internal/indexer"
 	"flashblock/internal/mempool"
+	"flashblock/internal/metrics"
 	"flashblock/internal/model"
+	"flashblock/internal/preconf"
 	"flashblock/internal/processor"
-)
+	"flashblock/internal/ratelimit"
+	"flashblock/internal/state"
+	"flashblock/internal/txindex"
+	"flashblock/internal/version"
 
-// TransactionHook is a function called when a transaction is processed
-type TransactionHook = mempool.TransactionHook
+	"github.com/ethereum/go-ethereum/rpc"
+)
 
 // API defines the Flash RPC methods
 type API struct {
 	mempool   *mempool.Mempool
 	processor *processor.BlockProcessor
+	metrics   *metrics.Metrics
+	limiter   *ratelimit.Limiter
+	state     *state.DB         // Account state transactions are simulated on (optional)
+	encrypted *encrypted.Pool   // Receives encrypted transactions (optional)
+	feeMarket *feemarket.Market // Reported by estimatePriority (optional)
+	preconf   *preconf.Issuer   // Signs the receipts of admitted transactions (optional)
+	blobs     *blobs.Store      // Keeps the sidecars of blob transactions (optional)
+	batcher   *batcher.Batcher  // Aggregates the sealed blocks into batches (optional)
+	indexer   *indexer.Indexer  // Answers queries over the indexed blocks (optional)
+	role      string            // Deployment role reported by getStatus
 	startTime time.Time
+
+	stateSnapshots bool // Serve getStateSnapshot, only to trusted clients
 }
 
 // SubmitTransactionArgs represents parameters for the submitTransaction method
@@ -28,8 +52,17 @@ type SubmitTransactionArgs struct {
 
 // SubmitTransactionResult represents the result of the submitTransaction method
 type SubmitTransactionResult struct {
-	TransactionID string `json:"transaction_id"`
-	Added         bool   `json:"added"`
+	TransactionID   string           `json:"transaction_id"`
+	Added           bool             `json:"added"`
+	Preconfirmation *preconf.Receipt `json:"preconfirmation,omitempty"` // Signed inclusion promise, if enabled
+}
+
+// SendPrivateTransactionArgs represents parameters for the sendPrivateTransaction method
+type SendPrivateTransactionArgs struct {
+	Data     string `json:"data"`
+	Priority int    `json:"priority"`
+	TTLMs    int    `json:"ttl_ms"` // Privacy TTL (server default if zero)
+	Expire   bool   `json:"expire"` // Drop the transaction instead of publishing it after the TTL
 }
 
 // GetTransactionStatusArgs represents parameters for the getTransactionStatus method
@@ -37,12 +70,39 @@ type GetTransactionStatusArgs struct {
 	ID string `json:"id"`
 }
 
+// Transaction statuses reported by getTransactionStatus
+const (
+	TxStatusPending  = "pending"  // Waiting in the mempool
+	TxStatusIncluded = "included" // Included in a block
+	TxStatusUnknown  = "unknown"  // Never seen, dropped or rejected
+)
+
 // GetTransactionStatusResult represents the result of the getTransactionStatus method
 type GetTransactionStatusResult struct {
-	Exists      bool               `json:"exists"`
+	Exists      bool               `json:"exists"` // The transaction is pending in the mempool
+	Status      string             `json:"status"`
 	Transaction *model.Transaction `json:"transaction,omitempty"`
+	Inclusion   *txindex.Location  `json:"inclusion,omitempty"` // Set once the transaction is included
+}
+
+// WaitForInclusionArgs represents parameters for the waitForInclusion method
+type WaitForInclusionArgs struct {
+	ID        string `json:"id"`
+	TimeoutMs int    `json:"timeout_ms"` // Maximum wait (default 10s, at most 60s)
+}
+
+// WaitForInclusionResult represents the result of the waitForInclusion method
+type WaitForInclusionResult struct {
+	Included  bool              `json:"included"`
+	Inclusion *txindex.Location `json:"inclusion,omitempty"`
 }
 
+// Wait limits of waitForInclusion
+const (
+	defaultInclusionTimeout = 10 * time.Second
+	maxInclusionTimeout     = 60 * time.Second
+)
+
 // GetBlocksResult represents a list of blocks
 type GetBlocksResult struct {
 	Blocks []*model.Block `json:"blocks"`
@@ -58,23 +118,66 @@ type GetMempoolResult struct {
 // StatusResult represents the system status
 type StatusResult struct {
 	Status          string `json:"status"`
+	Role            string `json:"role,omitempty"`
 	Uptime          string `json:"uptime"`
 	Version         string `json:"version"`
+	Commit          string `json:"commit"`
+	BuildDate       string `json:"build_date"`
 	MempoolSize     int    `json:"mempool_size"`
 	BlocksProcessed int    `json:"blocks_processed"`
 }
 
+// recentBlockSummaries is the number of blocks summarized by getMetrics
+const recentBlockSummaries = 20
+
+// BlockSummary describes a block without its transactions
+type BlockSummary struct {
+	Number       uint64    `json:"number"`
+	ID           string    `json:"id"`
+	Transactions int       `json:"transactions"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// MetricsResult represents the server metrics
+type MetricsResult struct {
+	Status                string          `json:"status"`
+	Uptime                float64         `json:"uptime_seconds"`
+	TransactionsReceived  uint64          `json:"transactions_received"`
+	TransactionsRejected  uint64          `json:"transactions_rejected"`
+	TransactionsProcessed uint64          `json:"transactions_processed"`
+	BlocksCreated         uint64          `json:"blocks_created"`
+	ProcessedTPS          float64         `json:"processed_tps"`
+	AvgBlockCreationMs    float64         `json:"avg_block_creation_ms"`
+	MempoolSize           int             `json:"mempool_size"`
+	FeeFloor              int             `json:"fee_floor"`                   // Minimum priority of new transactions
+	SheddingPriority      *int            `json:"shedding_priority,omitempty"` // Lowest priority admitted while overloaded
+	TransactionsShed      uint64          `json:"transactions_shed"`
+	RecentBlocks          []*BlockSummary `json:"recent_blocks"` // Newest first
+}
+
 // NewAPI creates a new Flash API instance
-func NewAPI(mempool *mempool.Mempool, processor *processor.BlockProcessor, hooks []TransactionHook) *API {
+func NewAPI(mempool *mempool.Mempool, processor *processor.BlockProcessor, metrics *metrics.Metrics, limiter *ratelimit.Limiter) *API {
 	return &API{
 		mempool:   mempool,
 		processor: processor,
+		metrics:   metrics,
+		limiter:   limiter,
 		startTime: time.Now(),
 	}
 }
 
+// SetRole sets the deployment role of the node (all, rpc or builder)
+func (api *API) SetRole(role string) {
+	api.role = role
+}
+
 // SubmitTransaction handles transaction submission
-func (api *API) SubmitTransaction(args SubmitTransactionArgs) (*SubmitTransactionResult, error) {
+func (api *API) SubmitTransaction(ctx context.Context, args SubmitTransactionArgs) (*SubmitTransactionResult, error) {
+	// Apply the per-client submission rate limit
+	if !api.limiter.Allow(ratelimit.PeerKey(rpc.PeerInfoFromContext(ctx).RemoteAddr)) {
+		return nil, ratelimit.ErrRateLimited
+	}
+
 	// Validate parameters
 	if args.Data == "" {
 		return nil, errors.New("data cannot be empty")
@@ -92,16 +195,67 @@ func (api *API) SubmitTransaction(args SubmitTransactionArgs) (*SubmitTransactio
 	}
 
 	// Create transaction
-	tx := model.NewTransaction(data, args.Priority)
+	tx := api.mempool.Factory().NewTransaction(data, args.Priority)
 
-	// Add to mempool
-	added := api.mempool.AddTransaction(tx)
+	// Add to mempool; duplicates and a full mempool are reported as not added
+	err = api.mempool.Admit(tx)
+	if err != nil && err != mempool.ErrDuplicate && err != mempool.ErrMempoolFull {
+		return nil, err
+	}
 
 	// Return result
-	return &SubmitTransactionResult{
+	result := &SubmitTransactionResult{
 		TransactionID: tx.ID,
-		Added:         added,
-	}, nil
+		Added:         err == nil,
+	}
+	if result.Added {
+		result.Preconfirmation = api.preconfirm(tx, false)
+	}
+	return result, nil
+}
+
+// errPrivateOnRPCNode rejects private transactions on RPC front-end nodes,
+// which only reach the builder through the public mempool feed
+var errPrivateOnRPCNode = errors.New("private transactions must be sent to a block-building node")
+
+// SendPrivateTransaction submits a transaction that is eligible for inclusion
+// but hidden from mempool queries, gossip and pending subscriptions until its
+// privacy TTL ends
+func (api *API) SendPrivateTransaction(ctx context.Context, args SendPrivateTransactionArgs) (*SubmitTransactionResult, error) {
+	// Apply the per-client submission rate limit
+	if !api.limiter.Allow(ratelimit.PeerKey(rpc.PeerInfoFromContext(ctx).RemoteAddr)) {
+		return nil, ratelimit.ErrRateLimited
+	}
+	if api.role == "rpc" {
+		return nil, errPrivateOnRPCNode
+	}
+	if args.Data == "" {
+		return nil, errors.New("data cannot be empty")
+	}
+	if args.TTLMs < 0 {
+		return nil, errors.New("ttl_ms cannot be negative")
+	}
+
+	// Decode base64 data, otherwise use the original string as bytes
+	data, err := base64.StdEncoding.DecodeString(args.Data)
+	if err != nil {
+		data = []byte(args.Data)
+	}
+	tx := api.mempool.Factory().NewTransaction(data, args.Priority)
+
+	ttl := time.Duration(args.TTLMs) * time.Millisecond
+	err = api.mempool.AdmitPrivate(tx, ttl, args.Expire)
+	if err != nil && err != mempool.ErrDuplicate && err != mempool.ErrMempoolFull {
+		return nil, err
+	}
+	result := &SubmitTransactionResult{
+		TransactionID: tx.ID,
+		Added:         err == nil,
+	}
+	if result.Added {
+		result.Preconfirmation = api.preconfirm(tx, true)
+	}
+	return result, nil
 }
 
 // GetTransactionStatus checks the status of a transaction
@@ -113,12 +267,64 @@ func (api *API) GetTransactionStatus(args GetTransactionStatusArgs) (*GetTransac
 
 	// Get transaction from mempool
 	tx, exists := api.mempool.GetTransaction(args.ID)
+	if exists {
+		return &GetTransactionStatusResult{
+			Exists:      true,
+			Status:      TxStatusPending,
+			Transaction: tx,
+		}, nil
+	}
 
-	// Return result
-	return &GetTransactionStatusResult{
-		Exists:      exists,
-		Transaction: tx,
-	}, nil
+	// Look up included transactions in the index
+	if api.processor != nil {
+		if loc, ok := api.processor.LookupTransaction(args.ID); ok {
+			result := &GetTransactionStatusResult{
+				Status:    TxStatusIncluded,
+				Inclusion: &loc,
+			}
+			if block := api.processor.GetBlock(loc.BlockID); block != nil {
+				result.Transaction = block.Transactions[loc.Index]
+			}
+			return result, nil
+		}
+	}
+
+	return &GetTransactionStatusResult{Status: TxStatusUnknown}, nil
+}
+
+// WaitForInclusion waits until the transaction is included in a block or the
+// timeout expires, so clients need not poll getTransactionStatus
+func (api *API) WaitForInclusion(ctx context.Context, args WaitForInclusionArgs) (*WaitForInclusionResult, error) {
+	if args.ID == "" {
+		return nil, errors.New("transaction ID cannot be empty")
+	}
+	if api.processor == nil {
+		return nil, errors.New("block processor not available")
+	}
+
+	timeout := defaultInclusionTimeout
+	if args.TimeoutMs > 0 {
+		timeout = min(time.Duration(args.TimeoutMs)*time.Millisecond, maxInclusionTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Subscribe before the lookup so an inclusion in between is not missed
+	blocks := make(chan events.BlockSealed, 16)
+	sub := api.processor.Events().BlockSealed.Subscribe(blocks)
+	defer sub.Unsubscribe()
+
+	for {
+		if loc, ok := api.processor.LookupTransaction(args.ID); ok {
+			return &WaitForInclusionResult{Included: true, Inclusion: &loc}, nil
+		}
+
+		select {
+		case <-blocks:
+		case <-ctx.Done():
+			return &WaitForInclusionResult{Included: false}, nil
+		}
+	}
 }
 
 // GetBlocks returns all processed blocks
@@ -134,6 +340,49 @@ func (api *API) GetBlocks() (*GetBlocksResult, error) {
 	}, nil
 }
 
+// NewBlocks creates a subscription that is notified of every newly created
+// block. A client that reads too slowly misses blocks until it catches up.
+func (api *API) NewBlocks(ctx context.Context) (*rpc.Subscription, error) {
+	if api.processor == nil {
+		return nil, errors.New("block processor not available")
+	}
+
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	blocks := make(chan events.BlockSealed, blockNotificationQueue)
+	sub := api.processor.Events().BlockSealed.Subscribe(blocks)
+	go forward("newBlocks", blocks, sub, rpcSub.Err(), blockNotificationQueue, func(ev events.BlockSealed) error {
+		return notifier.Notify(rpcSub.ID, ev.Block)
+	})
+
+	return rpcSub, nil
+}
+
+// NewTransactions creates a subscription that is notified of every transaction
+// admitted to the mempool. Builder-only nodes consume it as their mempool feed.
+// A client that reads too slowly misses transactions until it catches up.
+func (api *API) NewTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	transactions := make(chan events.TxAdmitted, transactionNotificationQueue)
+	sub := api.mempool.Events().TxAdmitted.Subscribe(transactions)
+	go forward("newTransactions", transactions, sub, rpcSub.Err(), transactionNotificationQueue, func(ev events.TxAdmitted) error {
+		return notifier.Notify(rpcSub.ID, ev.Tx)
+	})
+
+	return rpcSub, nil
+}
+
 // GetMempool returns all transactions in the mempool
 func (api *API) GetMempool() (*GetMempoolResult, error) {
 	transactions := api.mempool.GetAllTransactions()
@@ -146,15 +395,70 @@ func (api *API) GetMempool() (*GetMempoolResult, error) {
 // GetStatus returns system status
 func (api *API) GetStatus() (*StatusResult, error) {
 	var blocksProcessed int
+	status := "running"
 	if api.processor != nil {
 		blocksProcessed = len(api.processor.GetProcessedBlocks())
+		// RPC front-end and replica nodes never build, so they are not on standby
+		if api.processor.Paused() && api.role != "rpc" && api.role != "replica" {
+			status = "standby"
+		}
+	}
+	if api.mempool.InMaintenance() {
+		status = "maintenance"
 	}
 
 	return &StatusResult{
-		Status:          "running",
+		Status:          status,
+		Role:            api.role,
 		Uptime:          time.Since(api.startTime).String(),
-		Version:         "1.0.0",
+		Version:         version.Version,
+		Commit:          version.Commit,
+		BuildDate:       version.Date,
 		MempoolSize:     api.mempool.Size(),
 		BlocksProcessed: blocksProcessed,
 	}, nil
 }
+
+// GetMetrics returns the server metrics and a summary of the most recent blocks
+func (api *API) GetMetrics() (*MetricsResult, error) {
+	status, err := api.GetStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MetricsResult{
+		Status:       status.Status,
+		Uptime:       time.Since(api.startTime).Seconds(),
+		MempoolSize:  api.mempool.Size(),
+		FeeFloor:     api.mempool.FeeFloor(),
+		RecentBlocks: make([]*BlockSummary, 0, recentBlockSummaries),
+	}
+	if priority, ok := api.mempool.Shedding(); ok {
+		result.SheddingPriority = &priority
+	}
+	result.TransactionsShed = api.mempool.ShedCount()
+
+	if api.metrics != nil {
+		snapshot := api.metrics.GetSnapshot()
+		result.TransactionsReceived = snapshot.TransactionsReceived
+		result.TransactionsRejected = snapshot.TransactionsRejected
+		result.TransactionsProcessed = snapshot.TransactionsProcessed
+		result.BlocksCreated = snapshot.BlocksCreated
+		result.ProcessedTPS = snapshot.ProcessedTPS
+		result.AvgBlockCreationMs = float64(snapshot.AverageLatency) / float64(time.Millisecond)
+	}
+
+	if api.processor != nil {
+		blocks := api.processor.GetProcessedBlocks()
+		for i := len(blocks) - 1; i >= 0 && len(result.RecentBlocks) < recentBlockSummaries; i-- {
+			result.RecentBlocks = append(result.RecentBlocks, &BlockSummary{
+				Number:       blocks[i].Number,
+				ID:           blocks[i].ID,
+				Transactions: len(blocks[i].Transactions),
+				Timestamp:    blocks[i].Timestamp,
+			})
+		}
+	}
+
+	return result, nil
+}
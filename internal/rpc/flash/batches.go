@@ -0,0 +1,61 @@
+package flash
+
+import (
+	"errors"
+
+	"flashblock/internal/batcher"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// errNoBatcher is returned when the node does not batch its blocks
+var errNoBatcher = errors.New("batcher is not enabled")
+
+// BatchResult represents the result of the getBatch method
+type BatchResult struct {
+	*batcher.Batch
+	Data hexutil.Bytes `json:"data"` // Framed artifact, null if it is not kept (anymore)
+}
+
+// SetBatcher sets the batcher aggregating the sealed blocks
+func (api *API) SetBatcher(b *batcher.Batcher) {
+	api.batcher = b
+}
+
+// GetBatches returns the most recent batches, oldest first, or all batches
+// if count is omitted or 0
+func (api *API) GetBatches(count *int) ([]*batcher.Batch, error) {
+	if api.batcher == nil {
+		return nil, errNoBatcher
+	}
+	var n int
+	if count != nil {
+		if *count < 0 {
+			return nil, errors.New("count cannot be negative")
+		}
+		n = *count
+	}
+	return api.batcher.Batches(n), nil
+}
+
+// GetBatch returns a batch with its framed artifact, or null if there is no
+// batch with the ID
+func (api *API) GetBatch(id hexutil.Uint64) (*BatchResult, error) {
+	if api.batcher == nil {
+		return nil, errNoBatcher
+	}
+	batch, data, ok := api.batcher.Get(uint64(id))
+	if !ok {
+		return nil, nil
+	}
+	return &BatchResult{Batch: batch, Data: data}, nil
+}
+
+// GetBatcherStatus returns a summary of the batches
+func (api *API) GetBatcherStatus() (*batcher.Status, error) {
+	if api.batcher == nil {
+		return nil, errNoBatcher
+	}
+	status := api.batcher.Status()
+	return &status, nil
+}
@@ -0,0 +1,54 @@
+package flash
+
+import (
+	"errors"
+	"strings"
+
+	"flashblock/internal/blobs"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// errNoBlobs is returned when the node does not keep blob sidecars
+var errNoBlobs = errors.New("blob sidecars are not enabled")
+
+// SetBlobStore sets the store of the sidecars of blob transactions
+func (api *API) SetBlobStore(store *blobs.Store) {
+	api.blobs = store
+}
+
+// GetBlobSidecar returns the sidecar of a blob transaction, or null if it is
+// not kept (anymore)
+func (api *API) GetBlobSidecar(hash string) (*blobs.Sidecar, error) {
+	if api.blobs == nil {
+		return nil, errNoBlobs
+	}
+	sidecar, ok := api.blobs.Get(strings.TrimPrefix(hash, "0x"))
+	if !ok {
+		return nil, nil
+	}
+	return sidecar, nil
+}
+
+// GetBlob returns a blob with its commitment and proof by its versioned
+// hash, as referenced by the blob_hashes of a transaction, or null if it is
+// not kept (anymore)
+func (api *API) GetBlob(versionedHash common.Hash) (*blobs.Blob, error) {
+	if api.blobs == nil {
+		return nil, errNoBlobs
+	}
+	blob, ok := api.blobs.GetBlob(versionedHash)
+	if !ok {
+		return nil, nil
+	}
+	return blob, nil
+}
+
+// GetBlobStatus returns the contents and retention of the sidecar store
+func (api *API) GetBlobStatus() (*blobs.Status, error) {
+	if api.blobs == nil {
+		return nil, errNoBlobs
+	}
+	status := api.blobs.Status()
+	return &status, nil
+}
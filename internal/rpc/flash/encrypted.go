@@ -0,0 +1,66 @@
+package flash
+
+import (
+	"context"
+	"errors"
+
+	"flashblock/internal/encrypted"
+	"flashblock/internal/ratelimit"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// errNoEncrypted is returned when the node does not accept encrypted transactions
+var errNoEncrypted = errors.New("encrypted transactions are not accepted by this node")
+
+// EncryptionKeyResult represents the result of the getEncryptionKey method
+type EncryptionKeyResult struct {
+	PublicKey hexutil.Bytes `json:"public_key"` // Uncompressed secp256k1 public key
+	KeyID     common.Hash   `json:"key_id"`     // Keccak-256 hash of the public key
+	TDXQuote  hexutil.Bytes `json:"tdx_quote,omitempty"`
+}
+
+// SendEncryptedTransactionResult represents the result of the sendEncryptedTransaction method
+type SendEncryptedTransactionResult struct {
+	Commitment common.Hash `json:"commitment"` // Keccak-256 hash of the ciphertext
+}
+
+// SetEncryptedPool sets the pool receiving encrypted transactions
+func (api *API) SetEncryptedPool(pool *encrypted.Pool) {
+	api.encrypted = pool
+}
+
+// GetEncryptionKey returns the key transactions are encrypted to, with the
+// attestation quote binding it to the enclave
+func (api *API) GetEncryptionKey() (*EncryptionKeyResult, error) {
+	if api.encrypted == nil {
+		return nil, errNoEncrypted
+	}
+	return &EncryptionKeyResult{
+		PublicKey: api.encrypted.PublicKey(),
+		KeyID:     api.encrypted.KeyID(),
+		TDXQuote:  api.encrypted.Quote(),
+	}, nil
+}
+
+// SendEncryptedTransaction submits a raw signed transaction encrypted to the
+// encryption key. It stays encrypted until the next block is built, and its
+// position in the block is given by its commitment.
+func (api *API) SendEncryptedTransaction(ctx context.Context, ciphertext hexutil.Bytes) (*SendEncryptedTransactionResult, error) {
+	// Apply the per-client submission rate limit
+	if !api.limiter.Allow(ratelimit.PeerKey(rpc.PeerInfoFromContext(ctx).RemoteAddr)) {
+		return nil, ratelimit.ErrRateLimited
+	}
+	if api.encrypted == nil {
+		return nil, errNoEncrypted
+	}
+
+	// Resubmitting a known transaction is not an error
+	commitment, err := api.encrypted.Add(ciphertext)
+	if err != nil && err != encrypted.ErrDuplicate {
+		return nil, err
+	}
+	return &SendEncryptedTransactionResult{Commitment: commitment}, nil
+}
@@ -0,0 +1,25 @@
+package flash
+
+import (
+	"flashblock/internal/feemarket"
+)
+
+// SetFeeMarket sets the fee market reported by estimatePriority
+func (api *API) SetFeeMarket(market *feemarket.Market) {
+	api.feeMarket = market
+}
+
+// EstimatePriority returns the minimum priority new transactions must meet
+// and the priority suggested for inclusion in the next block. The minimum
+// rises above the configured one while the node is congested.
+func (api *API) EstimatePriority() (*feemarket.Estimate, error) {
+	if api.feeMarket != nil {
+		return api.feeMarket.Estimate(), nil
+	}
+	floor := api.mempool.FeeFloor()
+	return &feemarket.Estimate{
+		MinPriority:  floor,
+		BasePriority: api.mempool.MinPriority(),
+		Suggested:    floor,
+	}, nil
+}
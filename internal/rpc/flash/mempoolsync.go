@@ -0,0 +1,44 @@
+package flash
+
+import (
+	"fmt"
+
+	"flashblock/internal/mempool"
+)
+
+// maxSyncTransactions is the number of transactions getMempoolTransactions returns at most
+const maxSyncTransactions = 1024
+
+// GetMempoolFingerprint returns the fingerprint of the public pending
+// transactions. Standby nodes compare it with their own to find the buckets
+// their mempool differs in.
+func (api *API) GetMempoolFingerprint() (*mempool.Fingerprint, error) {
+	return api.mempool.Fingerprint(), nil
+}
+
+// GetMempoolIDs returns the IDs of the public pending transactions in the given buckets
+func (api *API) GetMempoolIDs(buckets []int) ([]string, error) {
+	for _, bucket := range buckets {
+		if bucket < 0 || bucket >= mempool.FingerprintBuckets {
+			return nil, fmt.Errorf("invalid bucket %d (expected 0 to %d)", bucket, mempool.FingerprintBuckets-1)
+		}
+	}
+	ids := api.mempool.BucketIDs(buckets)
+	if ids == nil {
+		ids = []string{}
+	}
+	return ids, nil
+}
+
+// GetMempoolTransactions returns the public pending transactions with the
+// given IDs; IDs that are no longer pending are skipped
+func (api *API) GetMempoolTransactions(ids []string) (*GetMempoolResult, error) {
+	if len(ids) > maxSyncTransactions {
+		return nil, fmt.Errorf("too many transactions requested: %d, maximum %d", len(ids), maxSyncTransactions)
+	}
+	transactions := api.mempool.GetTransactions(ids)
+	return &GetMempoolResult{
+		Transactions: transactions,
+		Count:        len(transactions),
+	}, nil
+}
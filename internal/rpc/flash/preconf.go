@@ -0,0 +1,94 @@
+package flash
+
+import (
+	"errors"
+	"strings"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+	"flashblock/internal/preconf"
+	"flashblock/internal/txindex"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// errNoPreconf is returned when the node does not issue preconfirmations
+var errNoPreconf = errors.New("preconfirmations are not enabled")
+
+// VerifyPreconfirmationResult represents the result of the verifyPreconfirmation method
+type VerifyPreconfirmationResult struct {
+	Valid     bool              `json:"valid"`           // The signature matches the signer
+	Error     string            `json:"error,omitempty"` // Why the receipt is invalid
+	Issued    bool              `json:"issued"`          // Signed by this node
+	Status    string            `json:"status,omitempty"`
+	Inclusion *txindex.Location `json:"inclusion,omitempty"`
+}
+
+// SetPreconfIssuer sets the issuer of the receipts of admitted transactions
+func (api *API) SetPreconfIssuer(issuer *preconf.Issuer) {
+	api.preconf = issuer
+}
+
+// GetPreconfirmation returns the receipt issued for a transaction, or null
+// if none is kept
+func (api *API) GetPreconfirmation(hash string) (*preconf.Receipt, error) {
+	if api.preconf == nil {
+		return nil, errNoPreconf
+	}
+	receipt, ok := api.preconf.Get(common.HexToHash(hash))
+	if !ok {
+		return nil, nil
+	}
+	return receipt, nil
+}
+
+// VerifyPreconfirmation checks the signature of a receipt and, for receipts
+// of this chain, whether the promise was kept: the transaction was included
+// within the window (kept), after it (late), not yet (pending) or not at all
+// although the window has passed (broken)
+func (api *API) VerifyPreconfirmation(receipt preconf.Receipt) (*VerifyPreconfirmationResult, error) {
+	result := &VerifyPreconfirmationResult{}
+	if err := receipt.Verify(); err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Valid = true
+	if api.preconf != nil {
+		result.Issued = receipt.Signer == api.preconf.Signer()
+	}
+	if api.processor == nil || (api.state != nil && receipt.ChainID != api.state.ChainID()) {
+		return result, nil
+	}
+
+	_, head := api.processor.LatestBlock()
+	txID := strings.TrimPrefix(receipt.TxHash.Hex(), "0x")
+	location, included := api.processor.LookupTransaction(txID)
+	if included {
+		result.Inclusion = &location
+	}
+	result.Status = receipt.Status(head, included, location.BlockNumber)
+	return result, nil
+}
+
+// preconfirm returns the receipt of an admitted transaction if
+// preconfirmations are enabled. Receipts of private transactions are not
+// kept, so their hash cannot be looked up.
+func (api *API) preconfirm(tx *model.Transaction, private bool) *preconf.Receipt {
+	if api.preconf == nil {
+		return nil
+	}
+	issue := api.preconf.Issue
+	if private {
+		issue = api.preconf.Sign
+	}
+	receipt, err := issue(tx)
+	if errors.Is(err, preconf.ErrNotSchedulable) {
+		logging.Debugf("Not preconfirming transaction %s: %v", tx.ID, err)
+		return nil
+	}
+	if err != nil {
+		logging.Errorf("Failed to preconfirm transaction %s: %v", tx.ID, err)
+		return nil
+	}
+	return receipt
+}
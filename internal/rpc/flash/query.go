@@ -0,0 +1,81 @@
+package flash
+
+import (
+	"errors"
+	"time"
+
+	"flashblock/internal/indexer"
+)
+
+// errNoIndexer is returned when the node does not index its blocks
+var errNoIndexer = errors.New("indexer is not enabled")
+
+// QueryArgs represents parameters for the query method. Omitted fields do not
+// filter; ranges are inclusive.
+type QueryArgs struct {
+	From        string     `json:"from"` // Sender address
+	To          string     `json:"to"`   // Recipient address
+	FromTime    *time.Time `json:"from_time"`
+	ToTime      *time.Time `json:"to_time"`
+	MinPriority *int       `json:"min_priority"`
+	MaxPriority *int       `json:"max_priority"`
+	FromBlock   uint64     `json:"from_block"`
+	ToBlock     uint64     `json:"to_block"`
+	Status      *uint64    `json:"status"` // 1 succeeded, 0 reverted
+	Limit       int        `json:"limit"`
+	Offset      int        `json:"offset"`
+	Order       string     `json:"order"` // asc (default) or desc
+}
+
+// QueryResult represents the result of the query method
+type QueryResult struct {
+	Transactions []*indexer.Transaction `json:"transactions"`
+	Count        int                    `json:"count"`
+	LastBlock    uint64                 `json:"last_block"` // Highest indexed block
+}
+
+// SetIndexer sets the indexer writing the blocks into a SQL database
+func (api *API) SetIndexer(ix *indexer.Indexer) {
+	api.indexer = ix
+}
+
+// Query searches the indexed transactions, e.g. by sender, time range or
+// priority range, including those of blocks pruned by retention
+func (api *API) Query(args QueryArgs) (*QueryResult, error) {
+	if api.indexer == nil {
+		return nil, errNoIndexer
+	}
+	filter := indexer.Filter{
+		Sender:      args.From,
+		Recipient:   args.To,
+		MinPriority: args.MinPriority,
+		MaxPriority: args.MaxPriority,
+		FromBlock:   args.FromBlock,
+		ToBlock:     args.ToBlock,
+		Status:      args.Status,
+		Limit:       args.Limit,
+		Offset:      args.Offset,
+	}
+	if args.FromTime != nil {
+		filter.FromTime = *args.FromTime
+	}
+	if args.ToTime != nil {
+		filter.ToTime = *args.ToTime
+	}
+	switch args.Order {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return nil, errors.New("order must be asc or desc")
+	}
+
+	txs, err := api.indexer.Query(filter)
+	if err != nil {
+		return nil, err
+	}
+	if txs == nil {
+		txs = []*indexer.Transaction{}
+	}
+	return &QueryResult{Transactions: txs, Count: len(txs), LastBlock: api.indexer.LastBlock()}, nil
+}
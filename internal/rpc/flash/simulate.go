@@ -0,0 +1,132 @@
+package flash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"flashblock/internal/eth"
+	"flashblock/internal/model"
+	"flashblock/internal/ratelimit"
+	"flashblock/internal/state"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxSimulatedTransactions limits the transactions of a simulate request
+const maxSimulatedTransactions = 64
+
+// Outcomes of a simulated transaction
+const (
+	SimulationSuccess  = "success"  // Executed successfully
+	SimulationReverted = "reverted" // Included, but the execution reverted
+	SimulationInvalid  = "invalid"  // Cannot be included, e.g. because of its nonce or funds
+)
+
+// SimulateArgs represents parameters for the simulate method
+type SimulateArgs struct {
+	Transactions []string `json:"transactions"` // Raw signed transactions, executed in order
+	Latest       bool     `json:"latest"`       // Simulate on the latest state instead of the pending state
+}
+
+// SimulateResult represents the result of the simulate method
+type SimulateResult struct {
+	BlockNumber uint64                  `json:"block_number"`         // Block the transactions are simulated in
+	Pending     int                     `json:"pending_transactions"` // Pending transactions executed before them
+	GasUsed     uint64                  `json:"gas_used"`             // Gas used by the simulated transactions
+	Results     []*SimulatedTransaction `json:"results"`
+}
+
+// SimulatedTransaction is the outcome of a simulated transaction
+type SimulatedTransaction struct {
+	Hash            common.Hash     `json:"hash"` // Ethereum transaction hash
+	From            string          `json:"from"`
+	To              string          `json:"to,omitempty"`
+	Status          string          `json:"status"`
+	GasUsed         uint64          `json:"gas_used"`
+	Error           string          `json:"error,omitempty"` // Why the transaction reverted or is invalid
+	ContractAddress string          `json:"contract_address,omitempty"`
+	Logs            []*model.Log    `json:"logs,omitempty"`
+	StateDiff       state.StateDiff `json:"state_diff,omitempty"`
+}
+
+// SetState sets the account state transactions are simulated on
+func (api *API) SetState(db *state.DB) {
+	api.state = db
+}
+
+// Simulate executes transactions in order without admitting them and reports
+// their gas used, status and state changes. By default they are executed on
+// the pending state: after the public mempool transactions, ordered the way
+// the next block would order them. Private transactions and bundles are not
+// part of the pending state.
+func (api *API) Simulate(ctx context.Context, args SimulateArgs) (*SimulateResult, error) {
+	// Simulations cost as much as submissions, so they share the rate limit
+	if !api.limiter.Allow(ratelimit.PeerKey(rpc.PeerInfoFromContext(ctx).RemoteAddr)) {
+		return nil, ratelimit.ErrRateLimited
+	}
+	if api.state == nil || api.processor == nil {
+		return nil, errors.New("account state is not available")
+	}
+	if len(args.Transactions) == 0 {
+		return nil, errors.New("transactions cannot be empty")
+	}
+	if len(args.Transactions) > maxSimulatedTransactions {
+		return nil, fmt.Errorf("at most %d transactions can be simulated", maxSimulatedTransactions)
+	}
+
+	txs := make([]*model.Transaction, len(args.Transactions))
+	hashes := make([]common.Hash, len(args.Transactions))
+	for i, raw := range args.Transactions {
+		raw = strings.TrimPrefix(raw, "0x")
+		ethTx, err := eth.DecodeRawTransaction(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid raw transaction %d: %w", i, err)
+		}
+		tx, err := eth.ConvertToModelTransaction(api.mempool.Factory(), ethTx, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid raw transaction %d: %w", i, err)
+		}
+		txs[i], hashes[i] = tx, ethTx.Hash()
+	}
+
+	var pending []*model.Transaction
+	if !args.Latest {
+		pending = api.mempool.GetSortedTransactions()
+	}
+	header := api.processor.NextHeader(time.Now())
+	sim, err := api.state.Simulate(header, pending, txs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SimulateResult{
+		BlockNumber: header.Number,
+		Pending:     len(sim.Pending),
+		Results:     make([]*SimulatedTransaction, len(txs)),
+	}
+	for i, tx := range txs {
+		r := &SimulatedTransaction{Hash: hashes[i], From: tx.From, To: tx.To}
+		if err := sim.Errors[i]; err != nil {
+			r.Status = SimulationInvalid
+			r.Error = err.Error()
+		} else {
+			receipt := sim.Receipts[i]
+			r.Status = SimulationSuccess
+			if receipt.Status == model.ReceiptStatusFailed {
+				r.Status = SimulationReverted
+				r.Error = receipt.Error
+			}
+			r.GasUsed = receipt.GasUsed
+			r.ContractAddress = receipt.ContractAddress
+			r.Logs = receipt.Logs
+			r.StateDiff = sim.Diffs[i]
+			result.GasUsed += receipt.GasUsed
+		}
+		result.Results[i] = r
+	}
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package flash
+
+import (
+	"errors"
+	"time"
+
+	"flashblock/internal/model"
+	"flashblock/internal/state"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SnapshotArgs represents parameters for the getSnapshot method
+type SnapshotArgs struct {
+	Blocks int `json:"blocks"` // Most recent blocks to include (0 = all retained blocks)
+}
+
+// StateSnapshotArgs represents parameters for the getStateSnapshot method
+type StateSnapshotArgs struct {
+	Root  string `json:"root"`  // State root of the snapshot head
+	Start string `json:"start"` // Account key the page starts at, the next of the previous page (optional)
+	Limit int    `json:"limit"` // Nodes and code per page (default and at most maxStateSnapshotPage)
+}
+
+// maxStateSnapshotPage is the largest number of nodes and code returned by getStateSnapshot
+const maxStateSnapshotPage = 10000
+
+// errStateSnapshotsDisabled is returned for state snapshots on unauthenticated surfaces
+var errStateSnapshotsDisabled = errors.New("state snapshots are only served over IPC or with rpc.auth_tokens set")
+
+// SnapshotHeader describes the head of a snapshot
+type SnapshotHeader struct {
+	Number      uint64    `json:"number"`
+	ID          string    `json:"id"`
+	PrevBlockID string    `json:"prev_block_id"`
+	StateRoot   string    `json:"state_root,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// SnapshotResult represents the result of the getSnapshot method
+type SnapshotResult struct {
+	Head   *SnapshotHeader `json:"head"`   // Nil if the chain has no blocks yet
+	Blocks []*model.Block  `json:"blocks"` // Consecutive blocks ending at the head, oldest first
+}
+
+// SetStateSnapshots allows serving getStateSnapshot, which walks the whole
+// account state and must only be reachable by trusted clients
+func (api *API) SetStateSnapshots(enabled bool) {
+	api.stateSnapshots = enabled
+}
+
+// GetSnapshot returns a consistent snapshot of the chain for bootstrapping a
+// node: the head and the recent blocks up to it. The account state after the
+// head is fetched with getStateSnapshot, so the node continues from the head
+// without replaying history.
+func (api *API) GetSnapshot(args *SnapshotArgs) (*SnapshotResult, error) {
+	if api.processor == nil {
+		return nil, errors.New("block processor not available")
+	}
+	if args == nil {
+		args = &SnapshotArgs{}
+	}
+	if args.Blocks < 0 {
+		return nil, errors.New("blocks cannot be negative")
+	}
+
+	// The retained blocks are copied at once, so they end at a single head
+	blocks := api.processor.GetProcessedBlocks()
+	if len(blocks) == 0 {
+		return &SnapshotResult{Blocks: []*model.Block{}}, nil
+	}
+	if args.Blocks > 0 && len(blocks) > args.Blocks {
+		blocks = blocks[len(blocks)-args.Blocks:]
+	}
+	head := blocks[len(blocks)-1]
+
+	result := &SnapshotResult{
+		Head: &SnapshotHeader{
+			Number:      head.Number,
+			ID:          head.ID,
+			PrevBlockID: head.PrevBlockID,
+			StateRoot:   head.StateRoot,
+			Timestamp:   head.Timestamp,
+		},
+		Blocks: blocks,
+	}
+	return result, nil
+}
+
+// GetStateSnapshot returns a page of the account state at a state root, as
+// the nodes of the state and storage tries and the contract code of the
+// accounts from start on. The next page starts at the next field of the
+// result, which is omitted on the last page.
+func (api *API) GetStateSnapshot(args StateSnapshotArgs) (*state.Snapshot, error) {
+	if !api.stateSnapshots {
+		return nil, errStateSnapshotsDisabled
+	}
+	if api.state == nil {
+		return nil, errors.New("account state is not available")
+	}
+	if args.Limit < 0 {
+		return nil, errors.New("limit cannot be negative")
+	}
+	if args.Limit == 0 || args.Limit > maxStateSnapshotPage {
+		args.Limit = maxStateSnapshotPage
+	}
+	root := common.HexToHash(args.Root)
+	if !api.state.HasState(root) {
+		return nil, state.ErrStateNotAvailable
+	}
+	return api.state.SnapshotPage(root, common.HexToHash(args.Start), args.Limit)
+}
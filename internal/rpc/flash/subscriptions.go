@@ -0,0 +1,57 @@
+package flash
+
+import (
+	"flashblock/internal/logging"
+
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Notifications waiting to be written to a subscriber; a subscriber that falls
+// further behind misses notifications until it catches up
+const (
+	blockNotificationQueue       = 16
+	transactionNotificationQueue = 256
+)
+
+// forward writes the events received on ch to a subscriber with notify until
+// done is closed, then unsubscribes sub. Events are taken from ch as soon as
+// they are sent, so a client that reads slowly never holds up the event bus:
+// events arriving while queueSize notifications are waiting are dropped.
+func forward[T any](name string, ch <-chan T, sub event.Subscription, done <-chan error, queueSize int, notify func(T) error) {
+	defer sub.Unsubscribe()
+
+	queue := make(chan T, queueSize)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case ev := <-queue:
+				// A failed write closes the connection, which ends the subscription
+				notify(ev)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	dropped := 0
+	for {
+		select {
+		case ev := <-ch:
+			select {
+			case queue <- ev:
+			default:
+				if dropped == 0 {
+					logging.Warnf("Subscriber to %s fell behind, dropping notifications", name)
+				}
+				dropped++
+			}
+		case <-done:
+			if dropped > 0 {
+				logging.Infof("Subscriber to %s missed %d notifications", name, dropped)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package flash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/event"
+)
+
+func TestForwardDropsForSlowSubscriber(t *testing.T) {
+	var feed event.FeedOf[int]
+	ch := make(chan int, 4)
+	sub := feed.Subscribe(ch)
+	done := make(chan error)
+	release := make(chan struct{})
+	delivered := make(chan int, 100)
+	go forward("test", ch, sub, done, 4, func(ev int) error {
+		<-release
+		delivered <- ev
+		return nil
+	})
+
+	// A subscriber that does not read holds up neither the feed nor its
+	// other subscribers
+	sent := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			feed.Send(i)
+		}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("feed blocked by a slow subscriber")
+	}
+
+	// The notifications waiting when it fell behind are still delivered, in order
+	close(release)
+	last := -1
+	for i := 0; i < 5; i++ {
+		select {
+		case ev := <-delivered:
+			if ev <= last {
+				t.Errorf("notification %d after %d", ev, last)
+			}
+			last = ev
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d notifications delivered, want 5", i)
+		}
+	}
+
+	// Ending the subscription unsubscribes from the feed
+	close(done)
+	deadline := time.Now().Add(5 * time.Second)
+	for feed.Send(100) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("still subscribed after the subscription ended")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
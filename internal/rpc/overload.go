@@ -0,0 +1,307 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// serverBusyCode is the JSON-RPC error code of requests rejected under overload
+const serverBusyCode = -32005
+
+// serverBusyResponse is returned when no handler slot becomes available in time.
+// The request body is not read, so the ID is unknown.
+var serverBusyResponse = fmt.Sprintf(`{"jsonrpc":"2.0","id":null,"error":{"code":%d,"message":"server busy"}}`+"\n", serverBusyCode)
+
+// wsReadLimit is the maximum size of a WebSocket message, as in go-ethereum
+const wsReadLimit = 32 * 1024 * 1024
+
+// overloadGuard bounds the number of concurrently executing JSON-RPC requests:
+// HTTP requests and the calls made over WebSocket connections share the slots.
+// Requests beyond the limit wait in a bounded queue; when the queue is full or
+// the wait times out they are rejected immediately, so goroutines do not pile up
+// and delay block building.
+type overloadGuard struct {
+	slots   chan struct{} // One token per executing request
+	queue   chan struct{} // One token per waiting request
+	timeout time.Duration // Maximum time a request waits for a slot
+	onBusy  func()        // Called for every rejected request
+}
+
+// newOverloadGuard creates a guard allowing maxConcurrent executing and queueSize
+// waiting requests. A maxConcurrent of 0 disables the guard.
+func newOverloadGuard(maxConcurrent, queueSize int, timeout time.Duration, onBusy func()) *overloadGuard {
+	if maxConcurrent <= 0 {
+		return nil
+	}
+
+	return &overloadGuard{
+		slots:   make(chan struct{}, maxConcurrent),
+		queue:   make(chan struct{}, queueSize),
+		timeout: timeout,
+		onBusy:  onBusy,
+	}
+}
+
+// Handler wraps next with the concurrency limit. WebSocket upgrades are passed
+// through, as a connection would hold its slot for its whole lifetime; the
+// calls made over connections served by WebsocketHandler take slots instead.
+func (g *overloadGuard) Handler(next http.Handler) http.Handler {
+	if g == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !g.acquire(r.Context()) {
+			g.onBusy()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(serverBusyResponse))
+			return
+		}
+		defer g.release()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquire takes a handler slot, waiting in the queue if all slots are in use.
+// It reports false if the queue is full, the wait timed out or the client left.
+func (g *overloadGuard) acquire(ctx context.Context) bool {
+	select {
+	case g.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	// Wait for a slot only if there is room in the queue
+	select {
+	case g.queue <- struct{}{}:
+	default:
+		return false
+	}
+	defer func() { <-g.queue }()
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+
+	select {
+	case g.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Queued returns the number of requests waiting for a slot
+func (g *overloadGuard) Queued() int {
+	if g == nil {
+		return 0
+	}
+	return len(g.queue)
+}
+
+// release frees a handler slot
+func (g *overloadGuard) release() {
+	<-g.slots
+}
+
+// WebsocketHandler serves JSON-RPC over WebSocket with every call taking a
+// handler slot until its response is written. Like an HTTP request, a batch
+// takes a single slot. Calls rejected under overload
+// are answered with the server busy error without reaching the server.
+// Origins are checked by the CORS policy.
+func (g *overloadGuard) WebsocketHandler(server *rpc.Server) http.Handler {
+	if g == nil {
+		return server.WebsocketHandler([]string{"*"})
+	}
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     func(*http.Request) bool { return true },
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.SetReadLimit(wsReadLimit)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		c := &guardedConn{conn: conn, guard: g, ctx: ctx}
+		defer c.releaseAll()
+		defer cancel()
+		server.ServeCodec(rpc.NewFuncCodec(c, c.encode, c.decode), 0)
+	})
+}
+
+// guardedConn is a WebSocket connection whose calls hold handler slots
+type guardedConn struct {
+	conn    *websocket.Conn
+	guard   *overloadGuard
+	ctx     context.Context // Done when the connection is closed
+	writeMu sync.Mutex      // Serializes responses and busy errors
+	mu      sync.Mutex
+	held    int // Slots held by messages waiting for their response
+}
+
+// wsMessage holds the fields that tell calls, responses and notifications apart
+type wsMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// parseMessages returns the messages of a single or batch JSON-RPC message
+func parseMessages(data []byte) ([]wsMessage, bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var msgs []wsMessage
+		json.Unmarshal(data, &msgs)
+		return msgs, true
+	}
+	var msg wsMessage
+	json.Unmarshal(data, &msg)
+	return []wsMessage{msg}, false
+}
+
+// hasID reports whether the message carries a request ID
+func (m wsMessage) hasID() bool {
+	return len(m.ID) > 0 && string(m.ID) != "null"
+}
+
+// decode reads the next message that got a slot. Messages that cannot be
+// admitted are answered with the server busy error; notifications, which get
+// no response, do not take a slot.
+func (c *guardedConn) decode(v any) error {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		msgs, batch := parseMessages(data)
+		if !hasCalls(msgs) {
+			return json.Unmarshal(data, v)
+		}
+		if c.guard.acquire(c.ctx) {
+			c.mu.Lock()
+			c.held++
+			c.mu.Unlock()
+			return json.Unmarshal(data, v)
+		}
+
+		c.guard.onBusy()
+		if err := c.writeBusy(msgs, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// expectsResponse reports whether the server answers the message: everything
+// with an ID but responses, including invalid requests
+func (m wsMessage) expectsResponse() bool {
+	return m.hasID() && m.Result == nil && m.Error == nil
+}
+
+// hasCalls reports whether any of the messages expects a response
+func hasCalls(msgs []wsMessage) bool {
+	for _, msg := range msgs {
+		if msg.expectsResponse() {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBusy answers the calls of a rejected message with the server busy error
+func (c *guardedConn) writeBusy(msgs []wsMessage, batch bool) error {
+	var responses []json.RawMessage
+	for _, msg := range msgs {
+		if !msg.expectsResponse() {
+			continue
+		}
+		responses = append(responses, json.RawMessage(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"error":{"code":%d,"message":"server busy"}}`, msg.ID, serverBusyCode)))
+	}
+
+	var err error
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if batch {
+		err = c.conn.WriteJSON(responses)
+	} else if len(responses) == 1 {
+		err = c.conn.WriteMessage(websocket.TextMessage, responses[0])
+	}
+	return err
+}
+
+// encode writes a message from the server, releasing the slot of the message
+// it answers. Subscription notifications carry a method and no ID.
+func (c *guardedConn) encode(v any, isErrorResponse bool) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	msgs, _ := parseMessages(data)
+	for _, msg := range msgs {
+		if msg.Method == "" && msg.hasID() {
+			c.mu.Lock()
+			answered := c.held > 0
+			if answered {
+				c.held--
+			}
+			c.mu.Unlock()
+			if answered {
+				c.guard.release()
+			}
+			break
+		}
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// releaseAll releases the slots of calls that were never answered
+func (c *guardedConn) releaseAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ; c.held > 0; c.held-- {
+		c.guard.release()
+	}
+}
+
+// Close closes the connection
+func (c *guardedConn) Close() error {
+	return c.conn.Close()
+}
+
+// SetWriteDeadline sets the deadline of the next write
+func (c *guardedConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// RemoteAddr returns the client address, used to rate limit submissions
+func (c *guardedConn) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
@@ -2,104 +2,507 @@ package rpc
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"time"
 
+	"flashblock/internal/batcher"
+	"flashblock/internal/blobs"
+	"flashblock/internal/bundle"
+	"flashblock/internal/encrypted"
+	"flashblock/internal/feemarket"
+	"flashblock/internal/flashblocks"
+	"flashblock/internal/indexer"
+	"flashblock/internal/logging"
 	"flashblock/internal/mempool"
+	"flashblock/internal/metrics"
+	"flashblock/internal/preconf"
 	"flashblock/internal/processor"
+	"flashblock/internal/ratelimit"
+	adminapi "flashblock/internal/rpc/admin"
 	ethapi "flashblock/internal/rpc/eth"
 	flashapi "flashblock/internal/rpc/flash"
+	web3api "flashblock/internal/rpc/web3"
+	"flashblock/internal/state"
 
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
-// TransactionHook is a function called when a transaction is processed
-type TransactionHook = mempool.TransactionHook
-
 // Server represents a JSON-RPC server
 type Server struct {
-	mempool   *mempool.Mempool
-	processor *processor.BlockProcessor
-	addr      string
-	rpcServer *rpc.Server
+	mempool     *mempool.Mempool
+	processor   *processor.BlockProcessor
+	state       *state.DB                   // Account state served by the eth namespace
+	configMgr   adminapi.ConfigManager      // Backs the admin configuration methods
+	maintenance adminapi.MaintenanceManager // Backs the admin maintenance methods
+	peers       adminapi.PeerManager        // Backs admin_peers (nil without p2p)
+	relays      adminapi.RelayManager       // Backs admin_relays (nil without relays)
+	pruner      adminapi.PruneManager       // Backs the admin pruning methods (nil without a block store)
+	storage     adminapi.StorageManager     // Backs admin_storage and admin_pruneState (nil without a data directory)
+	clock       adminapi.TimeManager        // Backs admin_trustedTime (nil without trusted time)
+	flashblocks *flashblocks.Feed           // Served on FlashblocksAddr (optional)
+	bundles     *bundle.Pool                // Receives eth_sendBundle (nil if bundles are not accepted)
+	encrypted   *encrypted.Pool             // Receives flash_sendEncryptedTransaction (nil if disabled)
+	feeMarket   *feemarket.Market           // Backs flash_estimatePriority (optional)
+	preconf     *preconf.Issuer             // Signs the receipts of admitted transactions (nil if disabled)
+	blobs       *blobs.Store                // Keeps the sidecars of blob transactions (nil if disabled)
+	batcher     *batcher.Batcher            // Aggregates the sealed blocks into batches (nil if disabled)
+	indexer     *indexer.Indexer            // Writes the blocks into a SQL database (nil if disabled)
+	metrics     *metrics.Metrics
+	config      *Config
+	lanes       []Lane // Additional chains
+	rpcServer   *rpc.Server
+	laneServers []*rpc.Server // Serve the URL paths of the additional chains
+	httpServers []*http.Server
+	ipcServer   *rpc.Server // Serves the unix socket, including the admin namespace
+	ipcListener net.Listener
+	limiter     *ratelimit.Limiter // Per-client submission rate limit
+	cors        *corsPolicy
+	overload    *overloadGuard // Bounds concurrently executing public HTTP requests
+	admin       *http.ServeMux // Endpoints served on the admin address
+}
+
+// Lane is an additional chain with its own mempool, processor and account
+// state. Its flash and eth namespaces are served with the lane name as a
+// suffix, e.g. eth.fast_blockNumber, and unsuffixed on the URL path
+// /chains/<name> (/chains/<name>/ws for WebSocket on the HTTP address).
+type Lane struct {
+	Name      string
+	Mempool   *mempool.Mempool
+	Processor *processor.BlockProcessor
+	State     *state.DB
+}
+
+// Config holds configuration for the JSON-RPC server
+type Config struct {
+	Addr            string      // Listen address for HTTP (and WebSocket unless WSAddr is set)
+	WSAddr          string      // Separate listen address for WebSocket (optional)
+	AdminAddr       string      // Listen address for the admin and metrics endpoints (disabled if empty)
+	FlashblocksAddr string      // Listen address for the flashblocks feed (disabled if empty)
+	UnixSocket      string      // Unix socket path for JSON-RPC including the admin namespace (disabled if empty)
+	UnixMode        os.FileMode // Permissions of the unix socket file
+	AuthTokens      []string    // Accepted bearer tokens (auth disabled if empty)
+	CORSOrigins     []string    // Allowed browser origins ("*" allows any)
+	RateLimit       float64     // Submissions per second per client IP (0 = unlimited)
+	RateBurst       int         // Submission burst per client IP
+	Role            string      // Deployment role reported by flash_getStatus
+
+	MaxConcurrent int           // Concurrently executing HTTP requests (0 = unlimited)
+	QueueSize     int           // Requests waiting for a slot before "server busy" is returned
+	QueueTimeout  time.Duration // Maximum time a request waits for a slot
 }
 
 // NewServer creates a new JSON-RPC server
-func NewServer(mempool *mempool.Mempool, addr string) *Server {
+func NewServer(mempool *mempool.Mempool, config *Config) *Server {
 	server := &Server{
 		mempool: mempool,
-		addr:    addr,
+		config:  config,
+		limiter: ratelimit.New(config.RateLimit, config.RateBurst),
+		cors:    newCORSPolicy(config.CORSOrigins),
+		admin:   http.NewServeMux(),
 	}
+	server.overload = newOverloadGuard(config.MaxConcurrent, config.QueueSize, config.QueueTimeout, func() {
+		if server.metrics != nil {
+			server.metrics.IncrementRequestsBusy()
+		}
+	})
+
+	server.admin.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
 
 	return server
 }
 
+// HandleAdmin registers a handler on the admin address
+func (s *Server) HandleAdmin(pattern string, handler http.Handler) {
+	s.admin.Handle(pattern, handler)
+}
+
+// QueuedRequests returns the number of HTTP requests waiting for a handler
+// slot, which is always 0 without a concurrency limit
+func (s *Server) QueuedRequests() int {
+	return s.overload.Queued()
+}
+
+// SetRateLimit changes the per-client submission rate limit
+func (s *Server) SetRateLimit(rate float64, burst int) {
+	s.limiter.SetLimit(rate, burst)
+}
+
+// SetCORSOrigins changes the allowed browser origins
+func (s *Server) SetCORSOrigins(origins []string) {
+	s.cors.SetOrigins(origins)
+}
+
 // SetProcessor sets the block processor reference
 func (s *Server) SetProcessor(bp *processor.BlockProcessor) {
 	s.processor = bp
 }
 
-// AddTransactionHook adds a hook to be called when a transaction is processed
-func (s *Server) AddTransactionHook(hook TransactionHook) {
-	// Register hook with mempool directly
-	s.mempool.AddTransactionHook(hook)
+// SetState sets the account state served by the eth namespace
+func (s *Server) SetState(db *state.DB) {
+	s.state = db
+}
+
+// SetMetrics sets the metrics reported by flash_getMetrics
+func (s *Server) SetMetrics(m *metrics.Metrics) {
+	s.metrics = m
+}
+
+// SetConfigManager sets the configuration manager used by the admin namespace
+func (s *Server) SetConfigManager(m adminapi.ConfigManager) {
+	s.configMgr = m
+}
+
+// SetMaintenanceManager sets the maintenance mode controller used by the admin namespace
+func (s *Server) SetMaintenanceManager(m adminapi.MaintenanceManager) {
+	s.maintenance = m
+}
+
+// SetPeerManager sets the p2p node reported by admin_peers
+func (s *Server) SetPeerManager(m adminapi.PeerManager) {
+	s.peers = m
+}
+
+// SetRelayManager sets the relay publisher reported by admin_relays
+func (s *Server) SetRelayManager(m adminapi.RelayManager) {
+	s.relays = m
+}
+
+// SetTimeManager sets the trusted time source reported by admin_trustedTime
+func (s *Server) SetTimeManager(m adminapi.TimeManager) {
+	s.clock = m
+}
+
+// SetPruneManager sets the block store pruner used by the admin namespace
+func (s *Server) SetPruneManager(m adminapi.PruneManager) {
+	s.pruner = m
 }
 
-// Start starts the JSON-RPC server
-func (s *Server) Start(ctx context.Context) error {
+// SetStorageManager sets the data directory usage reporter used by the admin namespace
+func (s *Server) SetStorageManager(m adminapi.StorageManager) {
+	s.storage = m
+}
+
+// SetBundlePool sets the pool receiving the bundles of eth_sendBundle
+func (s *Server) SetBundlePool(pool *bundle.Pool) {
+	s.bundles = pool
+}
+
+// SetEncryptedPool sets the pool receiving encrypted transactions
+func (s *Server) SetEncryptedPool(pool *encrypted.Pool) {
+	s.encrypted = pool
+}
+
+// SetFeeMarket sets the fee market reported by flash_estimatePriority
+func (s *Server) SetFeeMarket(market *feemarket.Market) {
+	s.feeMarket = market
+}
+
+// SetPreconfIssuer sets the issuer of the preconfirmation receipts
+func (s *Server) SetPreconfIssuer(issuer *preconf.Issuer) {
+	s.preconf = issuer
+}
+
+// SetBlobStore sets the store of the sidecars of blob transactions
+func (s *Server) SetBlobStore(store *blobs.Store) {
+	s.blobs = store
+}
+
+// SetBatcher sets the batcher aggregating the sealed blocks into batches
+func (s *Server) SetBatcher(b *batcher.Batcher) {
+	s.batcher = b
+}
+
+// SetIndexer sets the indexer writing the blocks into a SQL database
+func (s *Server) SetIndexer(ix *indexer.Indexer) {
+	s.indexer = ix
+}
+
+// SetFlashblocksFeed sets the flashblocks feed served on the flashblocks address
+func (s *Server) SetFlashblocksFeed(feed *flashblocks.Feed) {
+	s.flashblocks = feed
+}
+
+// AddLane serves an additional chain
+func (s *Server) AddLane(lane Lane) {
+	s.lanes = append(s.lanes, lane)
+}
+
+// surface is a set of endpoints served on one listen address
+type surface struct {
+	name    string
+	addr    string
+	handler http.Handler
+}
+
+// Start registers the APIs and starts serving HTTP and WebSocket requests in the background
+func (s *Server) Start() error {
 	// Create a new RPC server
 	s.rpcServer = rpc.NewServer()
 
-	// Create and register Flash API (empty hooks since we now register them with mempool)
-	flashAPI := flashapi.NewAPI(s.mempool, s.processor, nil)
+	// Create and register Flash API
+	flashAPI := flashapi.NewAPI(s.mempool, s.processor, s.metrics, s.limiter)
+	flashAPI.SetRole(s.config.Role)
+	flashAPI.SetState(s.state)
+	flashAPI.SetEncryptedPool(s.encrypted)
+	flashAPI.SetFeeMarket(s.feeMarket)
+	flashAPI.SetPreconfIssuer(s.preconf)
+	flashAPI.SetBlobStore(s.blobs)
+	flashAPI.SetBatcher(s.batcher)
+	flashAPI.SetIndexer(s.indexer)
+	flashAPI.SetStateSnapshots(len(s.config.AuthTokens) > 0)
 	if err := s.rpcServer.RegisterName("flash", flashAPI); err != nil {
 		return err
 	}
 
-	// Create and register Ethereum API (empty hooks since we now register them with mempool)
-	ethAPI := ethapi.NewAPI(s.mempool, nil)
+	// Create and register Ethereum API
+	ethAPI := ethapi.NewAPI(s.mempool, s.processor, s.state, s.limiter)
+	ethAPI.SetBundles(s.bundles)
+	ethAPI.SetPreconfIssuer(s.preconf)
+	ethAPI.SetBlobStore(s.blobs)
+	ethAPI.SetRole(s.config.Role)
 	if err := s.rpcServer.RegisterName("eth", ethAPI); err != nil {
 		return err
 	}
 
-	// Set up HTTP server with WebSocket support
-	mux := http.NewServeMux()
+	// Create and register Web3 API
+	if err := s.rpcServer.RegisterName("web3", web3api.NewAPI()); err != nil {
+		return err
+	}
 
-	// Handle JSON-RPC requests via HTTP POST
-	mux.Handle("/", s.rpcServer)
+	// Additional chains are served under suffixed namespaces and on their own paths
+	laneHandlers := make(map[string]http.Handler)
+	for _, lane := range s.lanes {
+		if err := s.registerLane(s.rpcServer, lane, "."+lane.Name, s.limiter); err != nil {
+			return err
+		}
+		laneServer := rpc.NewServer()
+		if err := s.registerLane(laneServer, lane, "", s.limiter); err != nil {
+			return err
+		}
+		if err := laneServer.RegisterName("web3", web3api.NewAPI()); err != nil {
+			return err
+		}
+		s.laneServers = append(s.laneServers, laneServer)
+		laneHandlers["/chains/"+lane.Name] = laneServer
+	}
+
+	// JSON-RPC requests are served via HTTP POST, WebSocket upgrades on /ws
+	wsHandler := s.overload.WebsocketHandler(s.rpcServer)
+	httpMux := http.NewServeMux()
+	httpMux.Handle("/", s.rpcServer)
+	for path, handler := range laneHandlers {
+		httpMux.Handle(path, handler)
+	}
 
-	// Handle Websocket requests
-	mux.Handle("/ws", s.rpcServer.WebsocketHandler([]string{"*"}))
+	// The dashboard and the explorer are served next to the RPC endpoint they call
+	rootMux := http.NewServeMux()
+	rootMux.Handle("/dashboard", pageHandler(dashboardHTML))
+	rootMux.Handle("/explorer", pageHandler(explorerHTML))
+	rootMux.Handle("/", s.publicHandler(httpMux))
 
-	// Create and configure HTTP server
-	httpServer := &http.Server{
-		Addr:    s.addr,
-		Handler: mux,
+	surfaces := []surface{
+		{name: "HTTP", addr: s.config.Addr, handler: rootMux},
 	}
 
-	// Create TCP listener
-	listener, err := net.Listen("tcp", s.addr)
-	if err != nil {
+	if s.config.WSAddr == "" || s.config.WSAddr == s.config.Addr {
+		// WebSocket shares the HTTP listener
+		httpMux.Handle("/ws", wsHandler)
+		for i, lane := range s.lanes {
+			httpMux.Handle("/chains/"+lane.Name+"/ws", s.overload.WebsocketHandler(s.laneServers[i]))
+		}
+		surfaces[0].name = "HTTP and WebSocket"
+	} else {
+		wsMux := http.NewServeMux()
+		wsMux.Handle("/", wsHandler)
+		for i, lane := range s.lanes {
+			wsMux.Handle("/chains/"+lane.Name, s.overload.WebsocketHandler(s.laneServers[i]))
+		}
+		surfaces = append(surfaces, surface{name: "WebSocket", addr: s.config.WSAddr, handler: s.publicHandler(wsMux)})
+	}
+
+	if s.config.FlashblocksAddr != "" && s.flashblocks != nil {
+		surfaces = append(surfaces, surface{name: "flashblocks", addr: s.config.FlashblocksAddr, handler: s.publicHandler(s.flashblocks)})
+	}
+
+	if s.config.AdminAddr != "" {
+		surfaces = append(surfaces, surface{name: "admin", addr: s.config.AdminAddr, handler: s.admin})
+	}
+
+	// Bind every address before serving so a busy port fails the start
+	listeners := make([]net.Listener, 0, len(surfaces))
+	for _, sf := range surfaces {
+		listener, err := net.Listen("tcp", sf.addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on %s (%s): %v", sf.addr, sf.name, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	if s.config.UnixSocket != "" {
+		if err := s.startIPC(surfaces); err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return err
+		}
+	}
+
+	for i, sf := range surfaces {
+		httpServer := &http.Server{
+			Addr:    sf.addr,
+			Handler: sf.handler,
+		}
+		s.httpServers = append(s.httpServers, httpServer)
+
+		// Start server in a goroutine
+		go func(name string, listener net.Listener) {
+			logging.Infof("JSON-RPC server listening on %s (%s)", listener.Addr(), name)
+			if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("JSON-RPC server error: %v", err)
+			}
+		}(sf.name, listeners[i])
+	}
+
+	return nil
+}
+
+// startIPC serves JSON-RPC on the unix socket. Access is controlled by the socket
+// file permissions, so neither bearer tokens nor rate limits apply, and the
+// admin namespace is available.
+func (s *Server) startIPC(surfaces []surface) error {
+	s.ipcServer = rpc.NewServer()
+
+	ipcFlashAPI := flashapi.NewAPI(s.mempool, s.processor, s.metrics, nil)
+	ipcFlashAPI.SetRole(s.config.Role)
+	ipcFlashAPI.SetState(s.state)
+	ipcFlashAPI.SetEncryptedPool(s.encrypted)
+	ipcFlashAPI.SetFeeMarket(s.feeMarket)
+	ipcFlashAPI.SetPreconfIssuer(s.preconf)
+	ipcFlashAPI.SetBlobStore(s.blobs)
+	ipcFlashAPI.SetBatcher(s.batcher)
+	ipcFlashAPI.SetIndexer(s.indexer)
+	ipcFlashAPI.SetStateSnapshots(true)
+	if err := s.ipcServer.RegisterName("flash", ipcFlashAPI); err != nil {
 		return err
 	}
+	ipcEthAPI := ethapi.NewAPI(s.mempool, s.processor, s.state, nil)
+	ipcEthAPI.SetBundles(s.bundles)
+	ipcEthAPI.SetPreconfIssuer(s.preconf)
+	ipcEthAPI.SetBlobStore(s.blobs)
+	ipcEthAPI.SetRole(s.config.Role)
+	if err := s.ipcServer.RegisterName("eth", ipcEthAPI); err != nil {
+		return err
+	}
+	if err := s.ipcServer.RegisterName("web3", web3api.NewAPI()); err != nil {
+		return err
+	}
+	for _, lane := range s.lanes {
+		if err := s.registerLane(s.ipcServer, lane, "."+lane.Name, nil); err != nil {
+			return err
+		}
+	}
 
-	// Start server in a goroutine
-	go func() {
-		log.Printf("JSON-RPC server listening on %s (HTTP and WebSocket)", s.addr)
-		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("JSON-RPC server error: %v", err)
+	endpoints := map[string]string{"unix": s.config.UnixSocket}
+	for _, sf := range surfaces {
+		endpoints[sf.name] = sf.addr
+	}
+	if err := s.ipcServer.RegisterName("admin", adminapi.NewAPI(endpoints, s.configMgr, s.maintenance, s.peers, s.relays, s.pruner, s.storage, s.clock)); err != nil {
+		return err
+	}
+
+	// A socket file left behind by a previous run is removed; other files are not touched
+	if info, err := os.Lstat(s.config.UnixSocket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", s.config.UnixSocket)
+		}
+		if err := os.Remove(s.config.UnixSocket); err != nil {
+			return fmt.Errorf("failed to remove stale socket: %v", err)
 		}
+	}
+
+	listener, err := net.Listen("unix", s.config.UnixSocket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s (unix): %v", s.config.UnixSocket, err)
+	}
+	if err := os.Chmod(s.config.UnixSocket, s.config.UnixMode); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set socket permissions: %v", err)
+	}
+	s.ipcListener = listener
+
+	go func() {
+		logging.Infof("JSON-RPC server listening on %s (unix, mode %#o)", s.config.UnixSocket, s.config.UnixMode.Perm())
+		// ServeListener returns once the listener is closed on shutdown
+		s.ipcServer.ServeListener(listener)
 	}()
 
-	// Wait for context cancellation to stop server
-	<-ctx.Done()
-	log.Println("Shutting down JSON-RPC server...")
+	return nil
+}
 
-	// Create a timeout context for shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// registerLane registers the flash and eth namespaces of a lane on server,
+// with suffix appended to the namespace names
+func (s *Server) registerLane(server *rpc.Server, lane Lane, suffix string, limiter *ratelimit.Limiter) error {
+	flashAPI := flashapi.NewAPI(lane.Mempool, lane.Processor, nil, limiter)
+	flashAPI.SetRole(s.config.Role)
+	flashAPI.SetState(lane.State)
+	flashAPI.SetStateSnapshots(server == s.ipcServer || len(s.config.AuthTokens) > 0)
+	if err := server.RegisterName("flash"+suffix, flashAPI); err != nil {
+		return err
+	}
+	ethAPI := ethapi.NewAPI(lane.Mempool, lane.Processor, lane.State, limiter)
+	ethAPI.SetRole(s.config.Role)
+	return server.RegisterName("eth"+suffix, ethAPI)
+}
+
+// publicHandler applies the CORS policy and authentication to the public RPC
+// surfaces. The CORS policy comes first so preflight requests, which carry no
+// credentials, are answered and rejections carry the CORS headers.
+func (s *Server) publicHandler(next http.Handler) http.Handler {
+	return s.cors.Handler(newAuthHandler(s.overload.Handler(next), s.config.AuthTokens))
+}
+
+// CloseSubscriptions ends all subscriptions and closes the WebSocket connections,
+// including those of the flashblocks feed.
+// New RPC requests are rejected afterwards.
+func (s *Server) CloseSubscriptions() {
+	if s.rpcServer != nil {
+		s.rpcServer.Stop()
+	}
+	for _, laneServer := range s.laneServers {
+		laneServer.Stop()
+	}
+	if s.ipcServer != nil {
+		s.ipcServer.Stop()
+	}
+	if s.flashblocks != nil {
+		s.flashblocks.Close()
+	}
+}
+
+// Shutdown stops the HTTP servers, waiting for in-flight requests until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	var firstErr error
+	for _, httpServer := range s.httpServers {
+		if err := httpServer.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// Closing the listener also removes the socket file
+	if s.ipcListener != nil {
+		if err := s.ipcListener.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
 
-	return httpServer.Shutdown(shutdownCtx)
+	return firstErr
 }
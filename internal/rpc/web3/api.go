@@ -0,0 +1,16 @@
+package web3
+
+import "flashblock/internal/version"
+
+// API defines the Web3 RPC methods
+type API struct{}
+
+// NewAPI creates a new Web3 API instance
+func NewAPI() *API {
+	return &API{}
+}
+
+// ClientVersion returns the server name, version, commit and platform
+func (api *API) ClientVersion() string {
+	return version.ClientVersion()
+}
@@ -0,0 +1,187 @@
+// Package shedding rejects low-priority submissions while the node is
+// overloaded, so high-priority transactions and reads keep their capacity.
+package shedding
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"flashblock/internal/events"
+	"flashblock/internal/logging"
+	"flashblock/internal/mempool"
+	"flashblock/internal/metrics"
+	"flashblock/internal/model"
+	"flashblock/internal/processor"
+)
+
+// maxSamples bounds the priorities of included transactions kept per adjustment
+const maxSamples = 4096
+
+// Config holds configuration for the overload controller
+type Config struct {
+	QueueThreshold    int           // Queued RPC requests at which the node is overloaded (0 = not measured)
+	LatencyThreshold  time.Duration // Block build time at which the node is overloaded (0 = not measured)
+	ProtectedPriority int           // Priority at and above which submissions are never shed (0 = none)
+	MaxShare          float64       // Largest share of the submitted priorities shed
+	Step              float64       // Change of the shed share per adjustment
+	Interval          time.Duration // Time between adjustments
+	RetryAfter        time.Duration // Wait suggested to shed clients
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		QueueThreshold:   64,
+		LatencyThreshold: 200 * time.Millisecond,
+		MaxShare:         0.9,
+		Step:             0.1,
+		Interval:         time.Second,
+		RetryAfter:       time.Second,
+	}
+}
+
+// Controller measures the RPC queueing and the block build time and, while
+// either is at its threshold, sheds a growing share of the submissions: those
+// below the matching quantile of the priorities of the pending and recently
+// included transactions. The share shrinks again once the node recovers.
+// Reads are never shed, and neither are transactions at the protected priority.
+type Controller struct {
+	mempool   *mempool.Mempool
+	processor *processor.BlockProcessor
+	queued    func() int // Queued RPC requests
+	metrics   *metrics.Metrics
+	config    *Config
+
+	// Owned by the adjustment loop
+	share       float64 // Share of the priorities shed
+	minPriority int     // Lowest priority admitted while shedding
+	shedding    bool
+	buildTime   time.Duration // Longest block build since the last adjustment
+	samples     []int         // Priorities of the transactions included since the last adjustment
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates an overload controller for the mempool; queued returns the
+// number of RPC requests waiting to be served
+func New(mp *mempool.Mempool, bp *processor.BlockProcessor, queued func() int, m *metrics.Metrics, config *Config) *Controller {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig().Interval
+	}
+	return &Controller{
+		mempool:   mp,
+		processor: bp,
+		queued:    queued,
+		metrics:   m,
+		config:    config,
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start adjusts the shedding every interval until the controller is closed
+func (c *Controller) Start() {
+	blocks := make(chan events.BlockSealed, 64)
+	sub := c.processor.Events().BlockSealed.Subscribe(blocks)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer sub.Unsubscribe()
+
+		ticker := time.NewTicker(c.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case ev := <-blocks:
+				// Imported blocks were built by another node
+				if !ev.Imported {
+					c.observe(ev.Block, ev.BuildTime)
+				}
+			case <-ticker.C:
+				c.adjust()
+			case <-sub.Err():
+				return
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the adjustments and the shedding
+func (c *Controller) Close() {
+	close(c.quit)
+	c.wg.Wait()
+	c.mempool.SetShedding(false, 0, 0)
+}
+
+// observe records the build time and the priorities of a new block
+func (c *Controller) observe(block *model.Block, buildTime time.Duration) {
+	c.buildTime = max(c.buildTime, buildTime)
+	for _, tx := range block.Transactions {
+		if len(c.samples) == maxSamples {
+			break
+		}
+		c.samples = append(c.samples, tx.Priority)
+	}
+}
+
+// adjust grows the shed share by a step while the node is overloaded and
+// shrinks it otherwise, and moves the threshold to the matching quantile
+func (c *Controller) adjust() {
+	queued := c.queued()
+
+	buildTime := c.buildTime
+	samples := c.samples
+	c.buildTime, c.samples = 0, nil
+
+	overloaded := (c.config.QueueThreshold > 0 && queued >= c.config.QueueThreshold) ||
+		(c.config.LatencyThreshold > 0 && buildTime >= c.config.LatencyThreshold)
+	if overloaded {
+		c.share = min(c.share+c.config.Step, c.config.MaxShare)
+	} else {
+		c.share -= c.config.Step
+		// Steps do not add up exactly in floating point
+		if c.share < c.config.Step/2 {
+			c.share = 0
+		}
+	}
+
+	// Without samples the last threshold holds
+	for _, tx := range c.mempool.GetAllTransactions() {
+		samples = append(samples, tx.Priority)
+	}
+	wasShedding := c.shedding
+	c.shedding = c.share > 0 && wasShedding
+	if c.share > 0 && len(samples) > 0 {
+		sort.Ints(samples)
+		c.minPriority = samples[min(int(c.share*float64(len(samples))), len(samples)-1)]
+		if c.config.ProtectedPriority > 0 {
+			c.minPriority = min(c.minPriority, c.config.ProtectedPriority)
+		}
+		c.shedding = true
+	}
+
+	c.mempool.SetShedding(c.shedding, c.minPriority, c.config.RetryAfter)
+	if c.metrics != nil {
+		shedPriority := 0
+		if c.shedding {
+			shedPriority = c.minPriority
+		}
+		c.metrics.SetShedding(c.mempool.ShedCount(), shedPriority, c.share, overloaded)
+	}
+
+	switch {
+	case c.shedding && !wasShedding:
+		logging.Warnf("Overloaded: shedding submissions below priority %d (%d queued requests, block build %v)", c.minPriority, queued, buildTime)
+	case !c.shedding && wasShedding:
+		logging.Infof("Overload cleared: no longer shedding submissions")
+	case c.shedding:
+		logging.Debugf("Shedding submissions below priority %d (%.0f%% of the priorities)", c.minPriority, c.share*100)
+	}
+}
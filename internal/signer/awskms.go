@@ -0,0 +1,199 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// AWSKMS signs with an ECC_SECG_P256K1 key in AWS KMS. Requests are signed
+// with Signature Version 4 using the credentials of the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and optional AWS_SESSION_TOKEN environment variables.
+type AWSKMS struct {
+	client   *http.Client
+	endpoint string
+	region   string
+	keyID    string
+	address  common.Address
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// awsError is the error body of the KMS API
+type awsError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// NewAWSKMS loads the public key of the KMS key keyID in region. The endpoint
+// overrides the regional KMS endpoint, e.g. for a VPC endpoint, if it is set.
+func NewAWSKMS(ctx context.Context, region, keyID, endpoint string, timeout time.Duration) (*AWSKMS, error) {
+	k := &AWSKMS{
+		client:       &http.Client{Timeout: timeout},
+		endpoint:     endpoint,
+		region:       region,
+		keyID:        keyID,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if k.accessKey == "" || k.secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set for AWS KMS")
+	}
+	if k.endpoint == "" {
+		k.endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com/", region)
+	}
+
+	var out struct {
+		PublicKey []byte `json:"PublicKey"`
+		KeySpec   string `json:"KeySpec"`
+	}
+	if err := k.call(ctx, "GetPublicKey", map[string]string{"KeyId": keyID}, &out); err != nil {
+		return nil, fmt.Errorf("failed to get the public key of %s: %v", keyID, err)
+	}
+	if out.KeySpec != "ECC_SECG_P256K1" {
+		return nil, fmt.Errorf("key %s has spec %s, not ECC_SECG_P256K1", keyID, out.KeySpec)
+	}
+	pub, err := parsePublicKey(out.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	k.address = crypto.PubkeyToAddress(*pub)
+	return k, nil
+}
+
+// Address returns the address of the key
+func (k *AWSKMS) Address() common.Address {
+	return k.address
+}
+
+// SignHash has KMS sign the hash as a digest
+func (k *AWSKMS) SignHash(hash common.Hash) ([]byte, error) {
+	in := map[string]any{
+		"KeyId":            k.keyID,
+		"Message":          hash.Bytes(),
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": "ECDSA_SHA_256",
+	}
+	var out struct {
+		Signature []byte `json:"Signature"`
+	}
+	if err := k.call(context.Background(), "Sign", in, &out); err != nil {
+		return nil, fmt.Errorf("AWS KMS: %v", err)
+	}
+	return fromDER(out.Signature, hash, k.address)
+}
+
+// Close does nothing
+func (k *AWSKMS) Close() {}
+
+// call invokes a KMS action with a JSON body
+func (k *AWSKMS) call(ctx context.Context, action string, in any, out any) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	k.sign(req, payload, time.Now().UTC())
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e awsError
+		if json.Unmarshal(body, &e) == nil && e.Type != "" {
+			return fmt.Errorf("%s: %s", e.Type, e.Message)
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// sign adds the Signature Version 4 authorization of the request
+func (k *AWSKMS) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if k.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", k.sessionToken)
+	}
+
+	// The signed headers are sorted by their lowercase names
+	headers := []string{"content-type", "host", "x-amz-date"}
+	if k.sessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	headers = append(headers, "x-amz-target")
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(payload),
+	}, "\n")
+
+	scope := date + "/" + k.region + "/kms/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+k.secretKey), date)
+	key = hmacSHA256(key, k.region)
+	key = hmacSHA256(key, "kms")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", k.accessKey, scope, signedHeaders, signature))
+}
+
+// sha256Hex returns the hex SHA-256 hash of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
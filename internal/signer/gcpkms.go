@@ -0,0 +1,178 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// gcpEndpoint is the default Cloud KMS API endpoint
+	gcpEndpoint = "https://cloudkms.googleapis.com"
+	// gcpTokenURL returns access tokens of the service account of the
+	// instance, e.g. of a Confidential Space workload
+	gcpTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// GCPKMS signs with an EC_SIGN_SECP256K1_SHA256 key version in Cloud KMS. The
+// requests are authorized with the access token of the
+// GOOGLE_OAUTH_ACCESS_TOKEN environment variable or, if it is not set, of the
+// service account of the instance from the metadata server.
+type GCPKMS struct {
+	client   *http.Client
+	endpoint string
+	name     string // Resource name of the key version
+	address  common.Address
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time // Zero for a token from the environment
+}
+
+// NewGCPKMS loads the public key of the key version with the resource name
+// projects/*/locations/*/keyRings/*/cryptoKeys/*/cryptoKeyVersions/*. The
+// endpoint overrides the Cloud KMS endpoint if it is set.
+func NewGCPKMS(ctx context.Context, name, endpoint string, timeout time.Duration) (*GCPKMS, error) {
+	k := &GCPKMS{
+		client:   &http.Client{Timeout: timeout},
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		name:     name,
+		token:    os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+	}
+	if k.endpoint == "" {
+		k.endpoint = gcpEndpoint
+	}
+
+	var out struct {
+		Pem       string `json:"pem"`
+		Algorithm string `json:"algorithm"`
+	}
+	if err := k.call(ctx, http.MethodGet, "/v1/"+name+"/publicKey", nil, &out); err != nil {
+		return nil, fmt.Errorf("failed to get the public key of %s: %v", name, err)
+	}
+	if out.Algorithm != "EC_SIGN_SECP256K1_SHA256" {
+		return nil, fmt.Errorf("key %s has algorithm %s, not EC_SIGN_SECP256K1_SHA256", name, out.Algorithm)
+	}
+	block, _ := pem.Decode([]byte(out.Pem))
+	if block == nil {
+		return nil, errors.New("invalid public key PEM")
+	}
+	pub, err := parsePublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	k.address = crypto.PubkeyToAddress(*pub)
+	return k, nil
+}
+
+// Address returns the address of the key
+func (k *GCPKMS) Address() common.Address {
+	return k.address
+}
+
+// SignHash has Cloud KMS sign the hash as a digest
+func (k *GCPKMS) SignHash(hash common.Hash) ([]byte, error) {
+	in := map[string]any{"digest": map[string][]byte{"sha256": hash.Bytes()}}
+	var out struct {
+		Signature []byte `json:"signature"`
+	}
+	if err := k.call(context.Background(), http.MethodPost, "/v1/"+k.name+":asymmetricSign", in, &out); err != nil {
+		return nil, fmt.Errorf("GCP KMS: %v", err)
+	}
+	return fromDER(out.Signature, hash, k.address)
+}
+
+// Close does nothing
+func (k *GCPKMS) Close() {}
+
+// call invokes a Cloud KMS method, with a JSON body unless in is nil
+func (k *GCPKMS) call(ctx context.Context, method, path string, in any, out any) error {
+	token, err := k.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, k.endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, e.Error.Message)
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// accessToken returns the token authorizing the requests, refreshing the
+// token of the metadata server a minute before it expires
+func (k *GCPKMS) accessToken(ctx context.Context) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.token != "" && (k.expires.IsZero() || time.Until(k.expires) > time.Minute) {
+		return k.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token from the metadata server: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get an access token from the metadata server: %s", resp.Status)
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("invalid access token response: %v", err)
+	}
+	k.token = out.AccessToken
+	k.expires = time.Now().Add(time.Duration(out.ExpiresIn) * time.Second)
+	return k.token, nil
+}
@@ -0,0 +1,72 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// signMethod is the JSON-RPC method remote signers implement: it takes the
+// address of the key and the hash and returns the 65 byte signature
+const signMethod = "signer_signHash"
+
+// Remote signs through a JSON-RPC remote signer
+type Remote struct {
+	client  *rpc.Client
+	address common.Address
+	timeout time.Duration
+}
+
+// NewRemote connects to a remote signer holding the key of address. The
+// bearer token authenticates the requests, if it is set.
+func NewRemote(ctx context.Context, url, authToken string, address common.Address, timeout time.Duration) (*Remote, error) {
+	var options []rpc.ClientOption
+	if authToken != "" {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+authToken)
+		options = append(options, rpc.WithHeaders(header))
+	}
+	client, err := rpc.DialOptions(ctx, url, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the remote signer: %v", err)
+	}
+	return &Remote{client: client, address: address, timeout: timeout}, nil
+}
+
+// Address returns the address of the key
+func (r *Remote) Address() common.Address {
+	return r.address
+}
+
+// SignHash has the remote signer sign the hash and checks the signature
+func (r *Remote) SignHash(hash common.Hash) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var signature hexutil.Bytes
+	if err := r.client.CallContext(ctx, &signature, signMethod, r.address, hash); err != nil {
+		return nil, fmt.Errorf("remote signer: %v", err)
+	}
+	if len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("remote signer returned a signature of %d bytes", len(signature))
+	}
+	// Signers following the Ethereum JSON-RPC conventions return V as 27 or 28
+	if signature[64] >= 27 {
+		signature[64] -= 27
+	}
+	if err := verify(signature, hash, r.address); err != nil {
+		return nil, fmt.Errorf("remote signer: %v", err)
+	}
+	return signature, nil
+}
+
+// Close disconnects from the remote signer
+func (r *Remote) Close() {
+	r.client.Close()
+}
@@ -0,0 +1,112 @@
+// Package signer signs with the builder identity key, which is kept either in
+// process memory or outside the process by a remote signer or a cloud KMS, so
+// the key never leaves the TEE or the HSM that holds it.
+package signer
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Signer signs 32 byte hashes with a secp256k1 key
+type Signer interface {
+	// Address returns the address of the key
+	Address() common.Address
+	// SignHash returns the 65 byte [R || S || V] signature of the hash, with
+	// V 0 or 1, as made by crypto.Sign
+	SignHash(hash common.Hash) ([]byte, error)
+	// Close releases the connection to the signer, if any
+	Close()
+}
+
+// Local signs with a key in process memory
+type Local struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewLocal returns a signer of the key
+func NewLocal(key *ecdsa.PrivateKey) *Local {
+	return &Local{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// Address returns the address of the key
+func (l *Local) Address() common.Address {
+	return l.address
+}
+
+// SignHash signs the hash
+func (l *Local) SignHash(hash common.Hash) ([]byte, error) {
+	return crypto.Sign(hash.Bytes(), l.key)
+}
+
+// Close does nothing
+func (l *Local) Close() {}
+
+// secp256k1N is the order of the secp256k1 curve
+var secp256k1N = crypto.S256().Params().N
+
+// fromDER converts an ASN.1 DER ECDSA signature, as returned by KMS services,
+// into the recoverable form: S is replaced by N-S if it is in the upper half
+// (EIP-2) and V is the recovery ID that yields the address
+func fromDER(der []byte, hash common.Hash, address common.Address) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("invalid DER signature")
+	}
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.Cmp(secp256k1N) >= 0 || sig.S.Cmp(secp256k1N) >= 0 {
+		return nil, errors.New("signature out of range")
+	}
+	if sig.S.Cmp(new(big.Int).Rsh(secp256k1N, 1)) > 0 {
+		sig.S.Sub(secp256k1N, sig.S)
+	}
+
+	signature := make([]byte, crypto.SignatureLength)
+	sig.R.FillBytes(signature[:32])
+	sig.S.FillBytes(signature[32:64])
+	for v := byte(0); v < 2; v++ {
+		signature[64] = v
+		if verify(signature, hash, address) == nil {
+			return signature, nil
+		}
+	}
+	return nil, fmt.Errorf("signature is not made by %s", address.Hex())
+}
+
+// verify checks that a recoverable signature of the hash is made by address
+func verify(signature []byte, hash common.Hash, address common.Address) error {
+	pub, err := crypto.SigToPub(hash.Bytes(), signature)
+	if err != nil {
+		return err
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != address {
+		return fmt.Errorf("signed by %s, not %s", signer.Hex(), address.Hex())
+	}
+	return nil
+}
+
+// parsePublicKey parses a DER SubjectPublicKeyInfo holding a secp256k1 key,
+// which the x509 package does not support
+func parsePublicKey(der []byte) (*ecdsa.PublicKey, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	rest, err := asn1.Unmarshal(der, &spki)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("invalid public key encoding")
+	}
+	pub, err := crypto.UnmarshalPubkey(spki.PublicKey.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("not a secp256k1 public key: %v", err)
+	}
+	return pub, nil
+}
@@ -0,0 +1,132 @@
+package state
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/holiman/uint256"
+)
+
+// StateDiff holds the accounts changed by a transaction
+type StateDiff map[common.Address]*AccountDiff
+
+// AccountDiff holds the changes of an account; unchanged fields are nil
+type AccountDiff struct {
+	Balance *BalanceDiff                 `json:"balance,omitempty"`
+	Nonce   *NonceDiff                   `json:"nonce,omitempty"`
+	Code    *CodeDiff                    `json:"code,omitempty"`
+	Storage map[common.Hash]*StorageDiff `json:"storage,omitempty"`
+}
+
+// BalanceDiff is a balance before and after a transaction
+type BalanceDiff struct {
+	From *hexutil.Big `json:"from"`
+	To   *hexutil.Big `json:"to"`
+}
+
+// NonceDiff is a nonce before and after a transaction
+type NonceDiff struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// CodeDiff is the code of an account before and after a transaction
+type CodeDiff struct {
+	From hexutil.Bytes `json:"from"`
+	To   hexutil.Bytes `json:"to"`
+}
+
+// StorageDiff is a storage slot before and after a transaction
+type StorageDiff struct {
+	From common.Hash `json:"from"`
+	To   common.Hash `json:"to"`
+}
+
+// diffTracker records the accounts and storage slots touched by a
+// transaction. Only the locations are recorded: the diff compares the state
+// before and after the transaction, so changes reverted by the EVM vanish.
+type diffTracker struct {
+	accounts map[common.Address]struct{}
+	slots    map[common.Address]map[common.Hash]struct{}
+}
+
+// newDiffTracker creates a tracker without touched locations
+func newDiffTracker() *diffTracker {
+	t := &diffTracker{}
+	t.reset()
+	return t
+}
+
+// reset forgets the touched locations before the next transaction
+func (t *diffTracker) reset() {
+	t.accounts = make(map[common.Address]struct{})
+	t.slots = make(map[common.Address]map[common.Hash]struct{})
+}
+
+// hooks returns the state hooks recording the touched locations
+func (t *diffTracker) hooks() *tracing.Hooks {
+	touch := func(addr common.Address) {
+		t.accounts[addr] = struct{}{}
+	}
+	return &tracing.Hooks{
+		OnBalanceChange: func(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+			touch(addr)
+		},
+		OnNonceChangeV2: func(addr common.Address, prev, new uint64, reason tracing.NonceChangeReason) {
+			touch(addr)
+		},
+		OnCodeChange: func(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+			touch(addr)
+		},
+		OnStorageChange: func(addr common.Address, slot common.Hash, prev, new common.Hash) {
+			touch(addr)
+			if t.slots[addr] == nil {
+				t.slots[addr] = make(map[common.Hash]struct{})
+			}
+			t.slots[addr][slot] = struct{}{}
+		},
+	}
+}
+
+// diff returns the changes of the touched locations between pre and post
+func (t *diffTracker) diff(pre, post *gethstate.StateDB) StateDiff {
+	diff := make(StateDiff)
+	for addr := range t.accounts {
+		account := &AccountDiff{}
+		changed := false
+		if from, to := pre.GetBalance(addr), post.GetBalance(addr); !from.Eq(to) {
+			account.Balance = &BalanceDiff{From: toBig(from), To: toBig(to)}
+			changed = true
+		}
+		if from, to := pre.GetNonce(addr), post.GetNonce(addr); from != to {
+			account.Nonce = &NonceDiff{From: hexutil.Uint64(from), To: hexutil.Uint64(to)}
+			changed = true
+		}
+		if from, to := pre.GetCode(addr), post.GetCode(addr); !bytes.Equal(from, to) {
+			account.Code = &CodeDiff{From: from, To: to}
+			changed = true
+		}
+		for slot := range t.slots[addr] {
+			if from, to := pre.GetState(addr, slot), post.GetState(addr, slot); from != to {
+				if account.Storage == nil {
+					account.Storage = make(map[common.Hash]*StorageDiff)
+				}
+				account.Storage[slot] = &StorageDiff{From: from, To: to}
+				changed = true
+			}
+		}
+		if changed {
+			diff[addr] = account
+		}
+	}
+	return diff
+}
+
+// toBig converts a balance for JSON encoding
+func toBig(v *uint256.Int) *hexutil.Big {
+	return (*hexutil.Big)(v.ToBig())
+}
@@ -0,0 +1,416 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"flashblock/internal/eth"
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	gethstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Header is the context the transactions of a block are executed in
+type Header struct {
+	Number       uint64
+	Time         time.Time
+	ParentID     string // ID of the previous block, returned by BLOCKHASH
+	GasLimit     uint64
+	FeeRecipient common.Address // Receives the priority fees
+}
+
+// Result is the outcome of executing transactions on top of the head state
+type Result struct {
+	Root     common.Hash          // State root after the applied transactions
+	GasUsed  uint64               // Gas used by the applied transactions
+	Applied  []*model.Transaction // Transactions included in execution order
+	Receipts []*model.Receipt     // Receipts of the applied transactions
+	Invalid  []*model.Transaction // Transactions that can never be included
+	Bundles  []*model.Bundle      // Bundles included at the top of the block
+	Excluded map[string]error     // Reasons bundles were left out, by bundle ID
+}
+
+// newChainConfig returns the fork rules of a chain: every fork up to Cancun
+// is active from genesis, and there is no base fee.
+func newChainConfig(chainID uint64) *params.ChainConfig {
+	zero := uint64(0)
+	return &params.ChainConfig{
+		ChainID:                 new(big.Int).SetUint64(chainID),
+		HomesteadBlock:          new(big.Int),
+		EIP150Block:             new(big.Int),
+		EIP155Block:             new(big.Int),
+		EIP158Block:             new(big.Int),
+		ByzantiumBlock:          new(big.Int),
+		ConstantinopleBlock:     new(big.Int),
+		PetersburgBlock:         new(big.Int),
+		IstanbulBlock:           new(big.Int),
+		MuirGlacierBlock:        new(big.Int),
+		BerlinBlock:             new(big.Int),
+		LondonBlock:             new(big.Int),
+		ArrowGlacierBlock:       new(big.Int),
+		GrayGlacierBlock:        new(big.Int),
+		MergeNetsplitBlock:      new(big.Int),
+		ShanghaiTime:            &zero,
+		CancunTime:              &zero,
+		TerminalTotalDifficulty: new(big.Int),
+		BlobScheduleConfig: &params.BlobScheduleConfig{
+			Cancun: params.DefaultCancunBlobConfig,
+		},
+	}
+}
+
+// newEVM creates an EVM executing on statedb in the context of header
+func (db *DB) newEVM(header *Header, statedb vm.StateDB) *vm.EVM {
+	blockContext := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash: func(number uint64) common.Hash {
+			// Only the parent is known from the block itself
+			if number+1 == header.Number && header.ParentID != "" {
+				return common.HexToHash(header.ParentID)
+			}
+			return common.Hash{}
+		},
+		Coinbase:    header.FeeRecipient,
+		GasLimit:    header.GasLimit,
+		BlockNumber: new(big.Int).SetUint64(header.Number),
+		Time:        uint64(header.Time.Unix()),
+		Difficulty:  new(big.Int),
+		BaseFee:     new(big.Int),
+		BlobBaseFee: big.NewInt(1),
+		Random:      &common.Hash{},
+	}
+	return vm.NewEVM(blockContext, statedb, db.config, vm.Config{})
+}
+
+// Execute runs the bundles and then the transactions in the given order on
+// top of the head state and commits the result. A bundle is included only if
+// all of its transactions apply and none reverts unless allowed; otherwise it
+// leaves no trace. A transaction whose nonce is ahead of its sender's nonce,
+// or that does not fit in the remaining block gas, is retried after the other
+// transactions and left out if it still does not apply. At most limit
+// transactions are applied (0 = unlimited). The head does not change until
+// SetHead is called.
+func (db *DB) Execute(header *Header, bundles []*model.Bundle, txs []*model.Transaction, limit int) (*Result, error) {
+	statedb, err := db.StateAt(db.Head())
+	if err != nil {
+		return nil, err
+	}
+	evm := db.newEVM(header, statedb)
+	gasPool := new(core.GasPool).AddGas(header.GasLimit)
+
+	result := &Result{}
+	for _, bundle := range bundles {
+		if limit > 0 && len(result.Applied)+len(bundle.Transactions) > limit {
+			result.exclude(bundle, ErrBundleLimit)
+			continue
+		}
+		bundleState, receipts, err := db.applyBundle(header, statedb, gasPool, bundle, len(result.Applied), result.GasUsed)
+		if err != nil {
+			result.exclude(bundle, err)
+			continue
+		}
+
+		// The bundle was executed on a copy, which becomes the block state
+		statedb = bundleState
+		evm = db.newEVM(header, statedb)
+		result.Applied = append(result.Applied, bundle.Transactions...)
+		result.Receipts = append(result.Receipts, receipts...)
+		result.GasUsed = receipts[len(receipts)-1].CumulativeGasUsed
+		result.Bundles = append(result.Bundles, bundle)
+	}
+
+	db.applyTransactions(evm, statedb, gasPool, result, txs, limit)
+
+	if result.Root, err = db.commit(statedb, header.Number); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// applyTransactions applies transactions in the given order, retrying those
+// with a nonce gap or without enough block gas after the others, and records
+// the outcome in result. At most limit transactions are applied (0 = unlimited).
+func (db *DB) applyTransactions(evm *vm.EVM, statedb *gethstate.StateDB, gasPool *core.GasPool, result *Result, txs []*model.Transaction, limit int) {
+	pending := txs
+	for len(pending) > 0 {
+		var deferred []*model.Transaction
+		for _, tx := range pending {
+			if limit > 0 && len(result.Applied) >= limit {
+				break
+			}
+			receipt, err := db.applyTransaction(evm, statedb, gasPool, tx, len(result.Applied), result.GasUsed)
+			switch {
+			case errors.Is(err, core.ErrNonceTooHigh), errors.Is(err, core.ErrGasLimitReached):
+				deferred = append(deferred, tx)
+			case err != nil:
+				result.Invalid = append(result.Invalid, tx)
+			default:
+				result.Applied = append(result.Applied, tx)
+				result.Receipts = append(result.Receipts, receipt)
+				result.GasUsed = receipt.CumulativeGasUsed
+			}
+		}
+
+		// Stop once a pass makes no progress; the deferred transactions stay pending
+		if len(deferred) == len(pending) {
+			break
+		}
+		pending = deferred
+	}
+}
+
+// exclude records why a bundle was left out
+func (r *Result) exclude(bundle *model.Bundle, err error) {
+	if r.Excluded == nil {
+		r.Excluded = make(map[string]error)
+	}
+	r.Excluded[bundle.ID] = err
+}
+
+// applyBundle executes the transactions of a bundle on a copy of statedb
+// and returns the copy with their receipts. The copy is discarded and the
+// gas pool restored if a transaction cannot be included or reverts without
+// being allowed to.
+func (db *DB) applyBundle(header *Header, statedb *gethstate.StateDB, gasPool *core.GasPool, bundle *model.Bundle, index int, cumulativeGasUsed uint64) (*gethstate.StateDB, []*model.Receipt, error) {
+	bundleState := statedb.Copy()
+	evm := db.newEVM(header, bundleState)
+	gas := gasPool.Gas()
+
+	receipts := make([]*model.Receipt, 0, len(bundle.Transactions))
+	for i, tx := range bundle.Transactions {
+		receipt, err := db.applyTransaction(evm, bundleState, gasPool, tx, index+i, cumulativeGasUsed)
+		if err == nil && receipt.Status == model.ReceiptStatusFailed && !bundle.CanRevert(tx.ID) {
+			err = fmt.Errorf("%w: %s", ErrBundleReverted, receipt.Error)
+		}
+		if err != nil {
+			gasPool.SetGas(gas)
+			return nil, nil, fmt.Errorf("transaction %d (%s): %w", i, tx.ID, err)
+		}
+		receipts = append(receipts, receipt)
+		cumulativeGasUsed = receipt.CumulativeGasUsed
+	}
+	return bundleState, receipts, nil
+}
+
+// Simulation is the outcome of simulated transactions
+type Simulation struct {
+	Pending  []*model.Transaction // Pending transactions applied before the simulated ones
+	Receipts []*model.Receipt     // Receipts by simulated transaction (nil if it cannot be included)
+	Errors   []error              // Why a simulated transaction cannot be included
+	Diffs    []StateDiff          // State changes by simulated transaction
+}
+
+// Simulate executes transactions in order on top of the head state without
+// committing them. The pending transactions are applied first, the way the
+// next block would apply them, so the simulated transactions see the pending
+// state. A simulated transaction that cannot be included has no receipt; its
+// error is returned at the same position, and the following transactions are
+// executed without it.
+func (db *DB) Simulate(header *Header, pending, txs []*model.Transaction) (*Simulation, error) {
+	statedb, err := db.StateAt(db.Head())
+	if err != nil {
+		return nil, err
+	}
+	gasPool := new(core.GasPool).AddGas(header.GasLimit)
+
+	result := &Result{}
+	db.applyTransactions(db.newEVM(header, statedb), statedb, gasPool, result, pending, 0)
+
+	// The simulated transactions run on a hooked state recording what they touch
+	tracker := newDiffTracker()
+	evm := db.newEVM(header, gethstate.NewHookedState(statedb, tracker.hooks()))
+	sim := &Simulation{
+		Pending:  result.Applied,
+		Receipts: make([]*model.Receipt, len(txs)),
+		Errors:   make([]error, len(txs)),
+		Diffs:    make([]StateDiff, len(txs)),
+	}
+	gasUsed := result.GasUsed
+	for i, tx := range txs {
+		pre := statedb.Copy()
+		tracker.reset()
+		sim.Receipts[i], sim.Errors[i] = db.applyTransaction(evm, statedb, gasPool, tx, len(result.Applied)+i, gasUsed)
+		if sim.Errors[i] == nil {
+			gasUsed = sim.Receipts[i].CumulativeGasUsed
+			sim.Diffs[i] = tracker.diff(pre, statedb)
+		}
+	}
+	return sim, nil
+}
+
+// ApplyBlock executes the transactions of a block created by another node or
+// stored earlier on top of the head state and commits the result. All
+// transactions must apply, and the gas used and state root must match the
+// ones recorded in the block. Blocks created before the transactions were
+// executed have no gas limit and only transfer values. The head does not
+// change until SetHead is called.
+func (db *DB) ApplyBlock(block *model.Block) (*Result, error) {
+	statedb, err := db.StateAt(db.Head())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Applied: block.Transactions}
+	if block.GasLimit == 0 {
+		for i, tx := range block.Transactions {
+			if err := applyTransfer(statedb, tx); err != nil {
+				return nil, fmt.Errorf("block %d transaction %d (%s): %w", block.Number, i, tx.ID, err)
+			}
+		}
+	} else {
+		header := &Header{
+			Number:       block.Number,
+			Time:         block.Timestamp,
+			ParentID:     block.PrevBlockID,
+			GasLimit:     block.GasLimit,
+			FeeRecipient: common.HexToAddress(block.FeeRecipient),
+		}
+		evm := db.newEVM(header, statedb)
+		gasPool := new(core.GasPool).AddGas(header.GasLimit)
+
+		for i, tx := range block.Transactions {
+			receipt, err := db.applyTransaction(evm, statedb, gasPool, tx, i, result.GasUsed)
+			if err != nil {
+				return nil, fmt.Errorf("block %d transaction %d (%s): %w", block.Number, i, tx.ID, err)
+			}
+			result.Receipts = append(result.Receipts, receipt)
+			result.GasUsed = receipt.CumulativeGasUsed
+		}
+		if result.GasUsed != block.GasUsed {
+			return nil, fmt.Errorf("%w: block %d records %d, computed %d", ErrGasUsedMismatch, block.Number, block.GasUsed, result.GasUsed)
+		}
+	}
+
+	if result.Root, err = db.commit(statedb, block.Number); err != nil {
+		return nil, err
+	}
+	if block.StateRoot != "" && common.HexToHash(block.StateRoot) != result.Root {
+		return nil, fmt.Errorf("%w: block %d records %s, computed %s", ErrStateRootMismatch, block.Number, block.StateRoot, result.Root.Hex())
+	}
+	return result, nil
+}
+
+// applyTransaction executes a transaction at the given position of the block
+// and returns its receipt. A transaction that cannot be included leaves the
+// state unchanged; one that reverts is included with a failed receipt.
+// Transactions without a sender do not touch the state.
+func (db *DB) applyTransaction(evm *vm.EVM, statedb *gethstate.StateDB, gasPool *core.GasPool, tx *model.Transaction, index int, cumulativeGasUsed uint64) (*model.Receipt, error) {
+	if tx.From == "" {
+		return &model.Receipt{Status: model.ReceiptStatusSuccessful, CumulativeGasUsed: cumulativeGasUsed}, nil
+	}
+	if tx.GasLimit > evm.Context.GasLimit {
+		return nil, fmt.Errorf("%w: gas %d, block gas limit %d", ErrGasLimitExceeded, tx.GasLimit, evm.Context.GasLimit)
+	}
+
+	msg, err := db.toMessage(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	txHash := common.HexToHash(tx.ID)
+	statedb.SetTxContext(txHash, index)
+	snapshot := statedb.Snapshot()
+	gas := gasPool.Gas()
+
+	execution, err := core.ApplyMessage(evm, msg, gasPool)
+	if err != nil {
+		statedb.RevertToSnapshot(snapshot)
+		gasPool.SetGas(gas)
+		return nil, err
+	}
+	statedb.Finalise(true)
+
+	receipt := &model.Receipt{
+		Status:            model.ReceiptStatusSuccessful,
+		GasUsed:           execution.UsedGas,
+		CumulativeGasUsed: cumulativeGasUsed + execution.UsedGas,
+	}
+	if execution.Failed() {
+		receipt.Status = model.ReceiptStatusFailed
+		receipt.Error = execution.Err.Error()
+	}
+	if msg.To == nil {
+		receipt.ContractAddress = crypto.CreateAddress(msg.From, msg.Nonce).Hex()
+	}
+	for _, l := range statedb.GetLogs(txHash, evm.Context.BlockNumber.Uint64(), common.Hash{}) {
+		topics := make([]string, len(l.Topics))
+		for i, topic := range l.Topics {
+			topics[i] = topic.Hex()
+		}
+		receipt.Logs = append(receipt.Logs, &model.Log{Address: l.Address.Hex(), Topics: topics, Data: l.Data})
+	}
+	return receipt, nil
+}
+
+// toMessage converts a transaction into an EVM message. Transactions with a
+// sender are decoded from their signed raw data, which verifies the signature
+// and chain ID; the fields the state checks elsewhere must match it.
+func (db *DB) toMessage(tx *model.Transaction) (*core.Message, error) {
+	if tx.RawData == "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsigned, tx.ID)
+	}
+
+	ethTx, err := eth.DecodeRawTransaction(tx.RawData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw transaction: %v", err)
+	}
+	msg, err := core.TransactionToMessage(ethTx, types.LatestSignerForChainID(db.config.ChainID), new(big.Int))
+	if err != nil {
+		return nil, err
+	}
+	if msg.From != common.HexToAddress(tx.From) {
+		return nil, fmt.Errorf("sender %s does not match the signature of %s", tx.From, msg.From.Hex())
+	}
+	value := tx.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	if msg.Nonce != tx.Nonce || msg.GasLimit != tx.GasLimit || msg.Value.Cmp(value) != 0 {
+		return nil, fmt.Errorf("transaction %s does not match its signed raw data", tx.ID)
+	}
+	return msg, nil
+}
+
+// applyTransfer transfers the value of a transaction and increments the
+// sender's nonce, the way blocks were applied before the transactions were
+// executed. Contract creations only increment the nonce, and transactions
+// without a sender do not touch the state.
+func applyTransfer(statedb *gethstate.StateDB, tx *model.Transaction) error {
+	if tx.From == "" {
+		return nil
+	}
+	if tx.RawData == "" {
+		return fmt.Errorf("%w: %s", ErrUnsigned, tx.ID)
+	}
+
+	from := common.HexToAddress(tx.From)
+	switch nonce := statedb.GetNonce(from); {
+	case tx.Nonce < nonce:
+		return fmt.Errorf("%w: address %s, tx nonce %d, state nonce %d", ErrNonceTooLow, tx.From, tx.Nonce, nonce)
+	case tx.Nonce > nonce:
+		return fmt.Errorf("%w: address %s, tx nonce %d, state nonce %d", ErrNonceTooHigh, tx.From, tx.Nonce, nonce)
+	}
+	if err := checkFunds(statedb, from, tx); err != nil {
+		return err
+	}
+
+	// Value was checked against the balance, so it fits in 256 bits
+	if tx.Value != nil && tx.Value.Sign() > 0 && tx.To != "" {
+		value, _ := uint256.FromBig(tx.Value)
+		statedb.SubBalance(from, value, tracing.BalanceChangeTransfer)
+		statedb.AddBalance(common.HexToAddress(tx.To), value, tracing.BalanceChangeTransfer)
+	}
+	statedb.SetNonce(from, tx.Nonce+1, tracing.NonceChangeEoACall)
+	return nil
+}
@@ -0,0 +1,277 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// PruneResult describes a state pruning pass
+type PruneResult struct {
+	Roots     int   `json:"roots"`     // States kept
+	Nodes     int   `json:"nodes"`     // Trie nodes removed
+	Code      int   `json:"code"`      // Contract code entries removed
+	Reclaimed int64 `json:"reclaimed"` // Bytes of the removed entries
+	Compacted bool  `json:"compacted"` // The database was compacted afterwards
+}
+
+// candidate is an entry that was not reachable when the database was scanned
+type candidate struct {
+	key  []byte
+	hash common.Hash
+	code bool
+	size int64
+}
+
+// marks holds the trie nodes and contract code reachable from the kept roots
+type marks struct {
+	nodes map[common.Hash]struct{}
+	code  map[common.Hash]struct{}
+}
+
+// Prune removes the trie nodes and contract code that are not reachable from
+// the kept roots, the head, the genesis or the latest commits, and compacts
+// the database so the space is returned to the file system. States that are
+// not stored are skipped. Blocks keep being built during the pass; commits
+// only wait while the unreachable entries are deleted.
+func (db *DB) Prune(keep []common.Hash) (*PruneResult, error) {
+	return db.prune(keep, false)
+}
+
+// Reclaimable returns what Prune would remove with the same roots, without
+// removing anything
+func (db *DB) Reclaimable(keep []common.Hash) (*PruneResult, error) {
+	return db.prune(keep, true)
+}
+
+// prune marks the reachable entries and sweeps the others. The mark and the
+// scan run concurrently with commits; the roots committed in the meantime
+// are marked before anything is deleted, so nodes they share with pruned
+// states survive.
+func (db *DB) prune(keep []common.Hash, dryRun bool) (*PruneResult, error) {
+	db.passMu.Lock()
+	defer db.passMu.Unlock()
+
+	db.pruneMu.Lock()
+	db.pruning, db.passRoots = true, nil
+	roots := append([]common.Hash{db.genesis, db.Head()}, keep...)
+	for i := 0; i < min(db.commits, recentRoots); i++ {
+		roots = append(roots, db.committed[i])
+	}
+	db.pruneMu.Unlock()
+	defer func() {
+		db.pruneMu.Lock()
+		db.pruning, db.passRoots = false, nil
+		db.pruneMu.Unlock()
+	}()
+
+	m := &marks{nodes: make(map[common.Hash]struct{}), code: make(map[common.Hash]struct{})}
+	kept := make(map[common.Hash]bool)
+	for _, root := range roots {
+		if err := db.mark(m, root, kept); err != nil {
+			return nil, err
+		}
+	}
+
+	// Candidates are collected without blocking commits
+	var candidates []candidate
+	it := db.disk.NewIterator(nil, nil)
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+		if len(key) == common.HashLength {
+			hash := common.BytesToHash(key)
+			if _, ok := m.nodes[hash]; ok || !rawdb.IsLegacyTrieNode(key, value) {
+				continue
+			}
+			candidates = append(candidates, candidate{key: common.CopyBytes(key), hash: hash, size: int64(len(key) + len(value))})
+		} else if ok, hash := rawdb.IsCodeKey(key); ok {
+			if _, ok := m.code[common.BytesToHash(hash)]; ok {
+				continue
+			}
+			candidates = append(candidates, candidate{key: common.CopyBytes(key), hash: common.BytesToHash(hash), code: true, size: int64(len(key) + len(value))})
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return nil, fmt.Errorf("failed to scan state database: %v", err)
+	}
+
+	result, err := db.sweep(m, kept, candidates, dryRun)
+	if err != nil || dryRun || result.Nodes+result.Code == 0 {
+		return result, err
+	}
+
+	// Deleted entries only free space once their files are compacted. Commits
+	// are not blocked meanwhile; compaction keeps what they write.
+	if err := db.disk.Compact(nil, nil); err != nil {
+		return result, fmt.Errorf("failed to compact state database: %v", err)
+	}
+	result.Compacted = true
+	return result, nil
+}
+
+// sweep deletes the candidates that are still unreachable once the roots
+// committed during the pass are marked. Commits wait until it returns.
+func (db *DB) sweep(m *marks, kept map[common.Hash]bool, candidates []candidate, dryRun bool) (*PruneResult, error) {
+	// Roots committed during the pass may have written entries that are candidates again
+	db.pruneMu.Lock()
+	defer db.pruneMu.Unlock()
+	for _, root := range append(db.passRoots, db.Head()) {
+		if err := db.mark(m, root, kept); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &PruneResult{Roots: len(kept)}
+	batch := db.disk.NewBatch()
+	for _, c := range candidates {
+		marked := m.nodes
+		if c.code {
+			marked = m.code
+		}
+		if _, ok := marked[c.hash]; ok {
+			continue
+		}
+		if c.code {
+			result.Code++
+		} else {
+			result.Nodes++
+		}
+		result.Reclaimed += c.size
+		if dryRun {
+			continue
+		}
+		if err := batch.Delete(c.key); err != nil {
+			return nil, err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return nil, fmt.Errorf("failed to prune state: %v", err)
+			}
+			batch.Reset()
+		}
+	}
+	if dryRun || result.Nodes+result.Code == 0 {
+		return result, nil
+	}
+	if err := batch.Write(); err != nil {
+		return nil, fmt.Errorf("failed to prune state: %v", err)
+	}
+	return result, nil
+}
+
+// mark marks the entries reachable from root. Subtries that are already
+// marked are not visited again, so marking the states of consecutive blocks
+// only walks what changed between them.
+func (db *DB) mark(m *marks, root common.Hash, kept map[common.Hash]bool) error {
+	if kept[root] || root == types.EmptyRootHash || !db.HasState(root) {
+		return nil
+	}
+	kept[root] = true
+
+	accounts, err := trie.New(trie.StateTrieID(root), db.triedb)
+	if err != nil {
+		return err
+	}
+	it, err := accounts.NodeIterator(nil)
+	if err != nil {
+		return err
+	}
+	descend := true
+	for it.Next(descend) {
+		descend = true
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if _, ok := m.nodes[hash]; ok {
+				descend = false
+				continue
+			}
+			m.nodes[hash] = struct{}{}
+		}
+		if !it.Leaf() {
+			continue
+		}
+
+		var account types.StateAccount
+		if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+			return fmt.Errorf("invalid account: %v", err)
+		}
+		if account.Root != types.EmptyRootHash {
+			id := trie.StorageTrieID(root, common.BytesToHash(it.LeafKey()), account.Root)
+			if err := db.markStorage(m, id); err != nil {
+				return err
+			}
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != types.EmptyCodeHash {
+			m.code[codeHash] = struct{}{}
+		}
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("incomplete state %s: %v", root.Hex(), err)
+	}
+	return nil
+}
+
+// markStorage marks the nodes of a storage trie, skipping marked subtries
+func (db *DB) markStorage(m *marks, id *trie.ID) error {
+	if _, ok := m.nodes[id.Root]; ok {
+		return nil
+	}
+	storage, err := trie.New(id, db.triedb)
+	if err != nil {
+		return err
+	}
+	it, err := storage.NodeIterator(nil)
+	if err != nil {
+		return err
+	}
+	descend := true
+	for it.Next(descend) {
+		descend = true
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if _, ok := m.nodes[hash]; ok {
+				descend = false
+				continue
+			}
+			m.nodes[hash] = struct{}{}
+		}
+	}
+	return it.Error()
+}
+
+// DiskUsage returns the size of the database files, or 0 for an in-memory database
+func (db *DB) DiskUsage() (int64, error) {
+	if db.path == "" {
+		return 0, nil
+	}
+
+	var size int64
+	err := filepath.WalkDir(db.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files are replaced while LevelDB compacts
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
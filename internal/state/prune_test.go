@@ -0,0 +1,165 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"flashblock/internal/genesis"
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/holiman/uint256"
+)
+
+var (
+	testAccount  = common.HexToAddress("0xfe3b557e8fb62b89f4916b721be55ceb828dbd73")
+	testContract = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+)
+
+// newTestDB creates an in-memory state with a funded account and commits n
+// states after the genesis. Every state changes the balance, the code and a
+// storage slot of the contract, so it has nodes and code of its own.
+func newTestDB(t *testing.T, n int) (*DB, []common.Hash) {
+	t.Helper()
+	db, err := Open("", &genesis.Genesis{
+		ChainID:  1,
+		GasLimit: 30_000_000,
+		Alloc:    map[string]genesis.Account{testAccount.Hex(): {Balance: "1000000"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	roots := make([]common.Hash, n)
+	parent := db.GenesisRoot()
+	for i := range roots {
+		statedb, err := db.StateAt(parent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statedb.AddBalance(testAccount, uint256.NewInt(uint64(i+1)), tracing.BalanceChangeUnspecified)
+		statedb.SetCode(testContract, []byte{0x60, byte(i), 0x00})
+		statedb.SetState(testContract, common.BigToHash(common.Big1), common.BytesToHash([]byte{byte(i + 1)}))
+		statedb.SetState(testContract, common.BytesToHash([]byte{byte(i + 2)}), common.BytesToHash([]byte{1}))
+		if parent, err = db.commit(statedb, uint64(i+1)); err != nil {
+			t.Fatal(err)
+		}
+		roots[i] = parent
+	}
+	db.SetHead(parent)
+	return db, roots
+}
+
+// checkComplete fails unless every node and the code of the state are stored
+func checkComplete(t *testing.T, db *DB, root common.Hash) {
+	t.Helper()
+	m := &marks{nodes: make(map[common.Hash]struct{}), code: make(map[common.Hash]struct{})}
+	if err := db.mark(m, root, make(map[common.Hash]bool)); err != nil {
+		t.Fatalf("state %s: %v", root.Hex(), err)
+	}
+	for hash := range m.code {
+		if len(rawdb.ReadCode(db.disk, hash)) == 0 {
+			t.Fatalf("state %s: code %s missing", root.Hex(), hash.Hex())
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	// The last recentRoots commits are kept; the states before them are not
+	const n = recentRoots + 4
+	db, roots := newTestDB(t, n)
+
+	reclaimable, err := db.Reclaimable(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := db.Prune(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Nodes == 0 || result.Code != 4 || !result.Compacted {
+		t.Errorf("result = %+v, want the nodes and the 4 contracts of the pruned states", result)
+	}
+	if reclaimable.Nodes != result.Nodes || reclaimable.Code != result.Code || reclaimable.Reclaimed != result.Reclaimed {
+		t.Errorf("reclaimable = %+v, want what the pass removed: %+v", reclaimable, result)
+	}
+
+	for i, root := range roots {
+		if i < 4 {
+			if db.HasState(root) {
+				t.Errorf("state %d not pruned", i+1)
+			}
+			continue
+		}
+		checkComplete(t, db, root)
+	}
+	checkComplete(t, db, db.GenesisRoot())
+
+	statedb, err := db.StateAt(db.Head())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := statedb.GetBalance(testAccount).Uint64(); balance != 1000000+n*(n+1)/2 {
+		t.Errorf("head balance = %d, want %d", balance, 1000000+n*(n+1)/2)
+	}
+
+	// A second pass finds nothing left to remove
+	if result, err = db.Prune(nil); err != nil {
+		t.Fatal(err)
+	}
+	if result.Nodes+result.Code != 0 || result.Compacted {
+		t.Errorf("second result = %+v, want nothing removed", result)
+	}
+}
+
+func TestPruneKeepsRoots(t *testing.T) {
+	db, roots := newTestDB(t, recentRoots+4)
+
+	result, err := db.Prune([]common.Hash{roots[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != 3 {
+		t.Errorf("%d contracts removed, want 3", result.Code)
+	}
+	checkComplete(t, db, roots[1])
+	for _, i := range []int{0, 2, 3} {
+		if db.HasState(roots[i]) {
+			t.Errorf("state %d not pruned", i+1)
+		}
+	}
+}
+
+func TestPrunerKeepsFromCheckpoint(t *testing.T) {
+	db, roots := newTestDB(t, 10)
+	blocks := make([]*model.Block, len(roots))
+	for i, root := range roots {
+		blocks[i] = &model.Block{Number: uint64(i + 1), StateRoot: root.Hex()}
+	}
+
+	// The states from the latest checkpoint to the head are kept
+	p := NewPruner(db, func() []*model.Block { return blocks }, 4, time.Hour)
+	finalized, keep := p.keep()
+	if finalized != 8 || len(keep) != 3 || keep[0] != roots[7] || keep[2] != roots[9] {
+		t.Errorf("keep = %d %v, want the roots of blocks 8 to 10", finalized, keep)
+	}
+
+	// Without checkpoints, all states of the recent blocks are kept
+	p = NewPruner(db, func() []*model.Block { return blocks }, 0, time.Hour)
+	if finalized, keep = p.keep(); finalized != 1 || len(keep) != 10 {
+		t.Errorf("keep = %d with %d roots, want all 10 from block 1", finalized, len(keep))
+	}
+
+	if _, err := p.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range roots {
+		checkComplete(t, db, root)
+	}
+	if status := p.Status(); status.LastRun == nil || status.LastError != "" {
+		t.Errorf("status = %+v, want a successful pass", status)
+	}
+}
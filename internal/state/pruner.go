@@ -0,0 +1,162 @@
+package state
+
+import (
+	"sync"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PruneStatus reports the state pruning schedule and passes
+type PruneStatus struct {
+	Interval       string       `json:"interval"`        // Time between background passes
+	FinalizedBlock uint64       `json:"finalized_block"` // States of older blocks are pruned
+	Bytes          int64        `json:"bytes"`           // Size of the state database
+	TotalReclaimed int64        `json:"total_reclaimed"` // Bytes freed since the start
+	LastRun        *time.Time   `json:"last_run,omitempty"`
+	LastResult     *PruneResult `json:"last_result,omitempty"`
+	LastError      string       `json:"last_error,omitempty"`
+}
+
+// Pruner removes the states of the blocks below the finalized checkpoint in
+// the background and compacts the database. The finalized checkpoint is the
+// latest recent block whose number is a multiple of the checkpoint interval,
+// or the oldest recent block if there is none; the states from it to the
+// head are kept.
+type Pruner struct {
+	db                 *DB
+	blocks             func() []*model.Block // Recent blocks, oldest first
+	checkpointInterval uint64
+	interval           time.Duration
+
+	mu             sync.Mutex
+	lastRun        time.Time
+	lastResult     *PruneResult
+	lastError      string
+	totalReclaimed int64
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPruner creates a pruner that runs a pass every interval over the states
+// of the blocks returned by blocks
+func NewPruner(db *DB, blocks func() []*model.Block, checkpointInterval uint64, interval time.Duration) *Pruner {
+	return &Pruner{
+		db:                 db,
+		blocks:             blocks,
+		checkpointInterval: checkpointInterval,
+		interval:           interval,
+		quit:               make(chan struct{}),
+	}
+}
+
+// Start runs the background passes, the first one after an interval so the
+// startup is not slowed down
+func (p *Pruner) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.Prune()
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background passes, waiting for a pass in progress
+func (p *Pruner) Close() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// keep returns the number of the finalized checkpoint and the state roots
+// from it to the head
+func (p *Pruner) keep() (uint64, []common.Hash) {
+	blocks := p.blocks()
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+
+	start := 0
+	if p.checkpointInterval > 0 {
+		for i := len(blocks) - 1; i >= 0; i-- {
+			if blocks[i].Number%p.checkpointInterval == 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	roots := make([]common.Hash, 0, len(blocks)-start)
+	for _, block := range blocks[start:] {
+		if block.StateRoot != "" {
+			roots = append(roots, common.HexToHash(block.StateRoot))
+		}
+	}
+	return blocks[start].Number, roots
+}
+
+// Prune runs a pass now
+func (p *Pruner) Prune() (*PruneResult, error) {
+	start := time.Now()
+	finalized, roots := p.keep()
+	result, err := p.db.Prune(roots)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastRun = start
+	if err != nil {
+		p.lastError = err.Error()
+		logging.Errorf("Failed to prune state: %v", err)
+		return nil, err
+	}
+	p.lastResult = result
+	p.lastError = ""
+	p.totalReclaimed += result.Reclaimed
+
+	if result.Nodes+result.Code > 0 {
+		logging.Infof("Pruned state below block %d: %d trie nodes and %d contracts removed (%d bytes) in %v",
+			finalized, result.Nodes, result.Code, result.Reclaimed, time.Since(start))
+	}
+	return result, nil
+}
+
+// Reclaimable returns the number of the finalized checkpoint and what a pass
+// would remove now
+func (p *Pruner) Reclaimable() (uint64, *PruneResult, error) {
+	finalized, roots := p.keep()
+	result, err := p.db.Reclaimable(roots)
+	return finalized, result, err
+}
+
+// Status returns the schedule and the result of the last pass
+func (p *Pruner) Status() PruneStatus {
+	finalized, _ := p.keep()
+	status := PruneStatus{
+		Interval:       p.interval.String(),
+		FinalizedBlock: finalized,
+	}
+	status.Bytes, _ = p.db.DiskUsage()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status.TotalReclaimed = p.totalReclaimed
+	status.LastResult = p.lastResult
+	status.LastError = p.lastError
+	if !p.lastRun.IsZero() {
+		lastRun := p.lastRun
+		status.LastRun = &lastRun
+	}
+	return status
+}
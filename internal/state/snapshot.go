@@ -0,0 +1,171 @@
+package state
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Snapshot is the complete account state at a state root: the nodes of the
+// account trie and of every storage trie, and the contract code. Nodes and
+// code are stored by their Keccak-256 hash, so a snapshot is verified against
+// its root when it is imported. A snapshot may be split into pages, which are
+// merged with Merge before the snapshot is imported.
+type Snapshot struct {
+	Root  common.Hash     `json:"root"`
+	Nodes []hexutil.Bytes `json:"nodes"`
+	Code  []hexutil.Bytes `json:"code"`
+	Next  *common.Hash    `json:"next,omitempty"` // Account key the next page starts at (nil on the last page)
+}
+
+// Snapshot returns the account state at the given root
+func (db *DB) Snapshot(root common.Hash) (*Snapshot, error) {
+	return db.SnapshotPage(root, common.Hash{}, 0)
+}
+
+// SnapshotPage returns the part of the account state at root that holds the
+// accounts from the account key start on. The page ends with the account
+// that brings it to limit nodes and code (0 = no limit), so an account is
+// never split between pages.
+func (db *DB) SnapshotPage(root, start common.Hash, limit int) (*Snapshot, error) {
+	snapshot := &Snapshot{Root: root}
+	full := func() bool { return limit > 0 && len(snapshot.Nodes)+len(snapshot.Code) >= limit }
+
+	// The nodes above an account are visited on the page of the first
+	// account below them; seeking past the start key skips them on later pages
+	var from []byte
+	if start != (common.Hash{}) {
+		from = start.Bytes()
+	}
+	next, err := db.walk(root, from, func(_ common.Hash, node []byte) {
+		snapshot.Nodes = append(snapshot.Nodes, node)
+	}, func(_ common.Hash, code []byte) {
+		snapshot.Code = append(snapshot.Code, code)
+	}, full)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStateNotAvailable, err)
+	}
+	if next != nil {
+		key := common.BytesToHash(next)
+		snapshot.Next = &key
+	}
+	return snapshot, nil
+}
+
+// Merge adds the nodes and code of the next page of the snapshot
+func (s *Snapshot) Merge(page *Snapshot) error {
+	if page.Root != s.Root {
+		return fmt.Errorf("snapshot page of root %s, expected %s", page.Root.Hex(), s.Root.Hex())
+	}
+	s.Nodes = append(s.Nodes, page.Nodes...)
+	s.Code = append(s.Code, page.Code...)
+	s.Next = page.Next
+	return nil
+}
+
+// ImportSnapshot stores the account state of a snapshot. It fails unless the
+// snapshot holds the complete state at its root. The head is not changed.
+func (db *DB) ImportSnapshot(snapshot *Snapshot) error {
+	batch := db.disk.NewBatch()
+	for _, node := range snapshot.Nodes {
+		rawdb.WriteLegacyTrieNode(batch, crypto.Keccak256Hash(node), node)
+	}
+	for _, code := range snapshot.Code {
+		rawdb.WriteCode(batch, crypto.Keccak256Hash(code), code)
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to write state snapshot: %v", err)
+	}
+
+	// Every node and contract must be reachable from the root
+	if _, err := db.walk(snapshot.Root, nil, func(common.Hash, []byte) {}, func(common.Hash, []byte) {}, nil); err != nil {
+		return fmt.Errorf("incomplete state snapshot for root %s: %v", snapshot.Root.Hex(), err)
+	}
+	return nil
+}
+
+// walk visits the stored nodes of the account trie at root and of the storage
+// tries of its accounts, and the code of its contracts, from the account key
+// start on (nil for all). Nodes embedded in their parent and code shared by several
+// contracts are visited once. If done reports true after an account, the walk
+// stops and returns the key of the next account to start from.
+func (db *DB) walk(root common.Hash, start []byte, onNode, onCode func(common.Hash, []byte), done func() bool) ([]byte, error) {
+	if root == types.EmptyRootHash {
+		return nil, nil
+	}
+	accounts, err := trie.New(trie.StateTrieID(root), db.triedb)
+	if err != nil {
+		return nil, err
+	}
+
+	seenTries := make(map[common.Hash]bool)
+	seenCode := make(map[common.Hash]bool)
+	it, err := accounts.NodeIterator(start)
+	if err != nil {
+		return nil, err
+	}
+	for it.Next(true) {
+		if it.Hash() != (common.Hash{}) {
+			onNode(it.Hash(), it.NodeBlob())
+		}
+		if !it.Leaf() {
+			continue
+		}
+
+		var account types.StateAccount
+		if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+			return nil, fmt.Errorf("invalid account: %v", err)
+		}
+		if account.Root != types.EmptyRootHash && !seenTries[account.Root] {
+			seenTries[account.Root] = true
+			id := trie.StorageTrieID(root, common.BytesToHash(it.LeafKey()), account.Root)
+			if err := db.walkStorage(id, onNode); err != nil {
+				return nil, err
+			}
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != types.EmptyCodeHash && !seenCode[codeHash] {
+			seenCode[codeHash] = true
+			code := rawdb.ReadCode(db.disk, codeHash)
+			if len(code) == 0 {
+				return nil, fmt.Errorf("missing code %s", codeHash.Hex())
+			}
+			onCode(codeHash, code)
+		}
+
+		// The next page starts at the key after this account, unless it was the last
+		if done != nil && done() {
+			next := new(big.Int).SetBytes(it.LeafKey())
+			next.Add(next, big.NewInt(1))
+			if next.BitLen() > 8*common.HashLength {
+				return nil, it.Error()
+			}
+			return common.BigToHash(next).Bytes(), it.Error()
+		}
+	}
+	return nil, it.Error()
+}
+
+// walkStorage visits the stored nodes of a storage trie
+func (db *DB) walkStorage(id *trie.ID, onNode func(common.Hash, []byte)) error {
+	storage, err := trie.New(id, db.triedb)
+	if err != nil {
+		return err
+	}
+	it, err := storage.NodeIterator(nil)
+	if err != nil {
+		return err
+	}
+	for it.Next(true) {
+		if it.Hash() != (common.Hash{}) {
+			onNode(it.Hash(), it.NodeBlob())
+		}
+	}
+	return it.Error()
+}
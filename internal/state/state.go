@@ -0,0 +1,342 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"flashblock/internal/genesis"
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gethstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/leveldb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// LevelDB cache size in megabytes and number of open file handles
+const (
+	dbCache   = 16
+	dbHandles = 16
+)
+
+// recentRoots is the number of latest committed roots kept by pruning
+const recentRoots = 16
+
+// Errors
+var (
+	ErrNonceTooLow        = errors.New("nonce too low")
+	ErrNonceTooHigh       = errors.New("nonce too high")
+	ErrInsufficientFunds  = errors.New("insufficient funds for value and gas")
+	ErrGasLimitExceeded   = errors.New("transaction exceeds the block gas limit")
+	ErrStateRootMismatch  = errors.New("state root mismatch")
+	ErrGasUsedMismatch    = errors.New("gas used mismatch")
+	ErrStateNotAvailable  = errors.New("state is not available")
+	ErrBundleReverted     = errors.New("bundle transaction reverted")
+	ErrBundleLimit        = errors.New("bundle exceeds the transaction limit")
+	ErrUnsigned           = errors.New("transaction has a sender but no signed raw data")
+	errValueOutOfRange    = errors.New("value exceeds 256 bits")
+	errCannotRebuildState = errors.New("state cannot be rebuilt from the recent blocks")
+)
+
+// DB is the account state of the chain. The state trie of every block is
+// stored by its root, and the head is the state after the latest block.
+type DB struct {
+	mu       sync.RWMutex // Protects head
+	disk     ethdb.Database
+	triedb   *triedb.Database
+	db       *gethstate.CachingDB
+	config   *params.ChainConfig // Fork rules of the EVM
+	gasLimit uint64              // Block gas limit
+	genesis  common.Hash
+	head     common.Hash
+	path     string // Directory of the database (empty if in memory)
+
+	// Commits wait while pruning removes nodes. The latest committed roots are
+	// always kept, as their blocks may not be the head yet, and so are the
+	// roots committed during a pruning pass.
+	passMu    sync.Mutex // Serializes pruning passes
+	pruneMu   sync.Mutex
+	committed [recentRoots]common.Hash
+	commits   int
+	pruning   bool
+	passRoots []common.Hash
+}
+
+// Open opens the state database at path, or an in-memory database if path is
+// empty, and commits the genesis state. The head starts at the genesis state.
+func Open(path string, g *genesis.Genesis) (*DB, error) {
+	var disk ethdb.Database
+	if path == "" {
+		disk = rawdb.NewMemoryDatabase()
+	} else {
+		kv, err := leveldb.New(path, dbCache, dbHandles, "", false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open state database: %v", err)
+		}
+		disk = rawdb.NewDatabase(kv)
+	}
+
+	tdb := triedb.NewDatabase(disk, triedb.HashDefaults)
+	db := &DB{
+		disk:     disk,
+		triedb:   tdb,
+		db:       gethstate.NewDatabase(tdb, nil),
+		config:   newChainConfig(g.ChainID),
+		gasLimit: g.GasLimit,
+		path:     path,
+	}
+
+	root, err := db.commitGenesis(g)
+	if err != nil {
+		disk.Close()
+		return nil, err
+	}
+	db.genesis = root
+	db.head = root
+
+	return db, nil
+}
+
+// commitGenesis writes the accounts allocated at genesis and returns the state root
+func (db *DB) commitGenesis(g *genesis.Genesis) (common.Hash, error) {
+	statedb, err := gethstate.New(types.EmptyRootHash, db.db)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	for hex, account := range g.Alloc {
+		addr := common.HexToAddress(hex)
+		balance, err := account.ParseBalance()
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("genesis account %s: %v", hex, err)
+		}
+		code, err := account.ParseCode()
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("genesis account %s: %v", hex, err)
+		}
+		storage, err := account.ParseStorage()
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("genesis account %s: %v", hex, err)
+		}
+		amount, overflow := uint256.FromBig(balance)
+		if overflow {
+			return common.Hash{}, fmt.Errorf("genesis account %s: %w", hex, errValueOutOfRange)
+		}
+
+		statedb.SetBalance(addr, amount, tracing.BalanceIncreaseGenesisBalance)
+		statedb.SetNonce(addr, account.Nonce, tracing.NonceChangeGenesis)
+		if len(code) > 0 {
+			statedb.SetCode(addr, code)
+		}
+		for key, value := range storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+
+	return db.commit(statedb, 0)
+}
+
+// commit writes the changes of statedb to disk and returns the new state root
+func (db *DB) commit(statedb *gethstate.StateDB, number uint64) (common.Hash, error) {
+	db.pruneMu.Lock()
+	defer db.pruneMu.Unlock()
+
+	root, err := statedb.Commit(number, true, false)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to commit state: %v", err)
+	}
+	if err := db.triedb.Commit(root, false); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to write state: %v", err)
+	}
+	db.committed[db.commits%recentRoots] = root
+	db.commits++
+	if db.pruning {
+		db.passRoots = append(db.passRoots, root)
+	}
+	return root, nil
+}
+
+// Close closes the state database
+func (db *DB) Close() error {
+	return db.disk.Close()
+}
+
+// KeyValueStore returns the database of the state, which other chain data
+// may share to be written alongside it
+func (db *DB) KeyValueStore() ethdb.KeyValueStore {
+	return db.disk
+}
+
+// ChainID returns the chain ID of the genesis
+func (db *DB) ChainID() uint64 {
+	return db.config.ChainID.Uint64()
+}
+
+// GasLimit returns the block gas limit of the genesis
+func (db *DB) GasLimit() uint64 {
+	return db.gasLimit
+}
+
+// GenesisRoot returns the state root of the genesis
+func (db *DB) GenesisRoot() common.Hash {
+	return db.genesis
+}
+
+// Head returns the state root after the latest block
+func (db *DB) Head() common.Hash {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.head
+}
+
+// SetHead makes the state with the given root the head. The state must have
+// been committed by Execute or ApplyBlock.
+func (db *DB) SetHead(root common.Hash) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.head = root
+}
+
+// HasState reports whether the state with the given root is stored
+func (db *DB) HasState(root common.Hash) bool {
+	_, err := gethstate.New(root, db.db)
+	return err == nil
+}
+
+// StateAt returns the state with the given root for reading
+func (db *DB) StateAt(root common.Hash) (*gethstate.StateDB, error) {
+	statedb, err := gethstate.New(root, db.db)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStateNotAvailable, err)
+	}
+	return statedb, nil
+}
+
+// Validate checks a new transaction against the head state. Transactions
+// without a sender do not touch the state and are always valid; those with a
+// sender must carry the signed raw transaction it was recovered from.
+func (db *DB) Validate(tx *model.Transaction) error {
+	if tx.From == "" {
+		return nil
+	}
+
+	statedb, err := db.StateAt(db.Head())
+	if err != nil {
+		return err
+	}
+
+	// Signed transactions must be valid on this chain and pay for their intrinsic gas
+	msg, err := db.toMessage(tx)
+	if err != nil {
+		return err
+	}
+	if tx.GasLimit > db.gasLimit {
+		return fmt.Errorf("%w: gas %d, block gas limit %d", ErrGasLimitExceeded, tx.GasLimit, db.gasLimit)
+	}
+	intrinsic, err := core.IntrinsicGas(msg.Data, msg.AccessList, msg.SetCodeAuthorizations, msg.To == nil, true, true, true)
+	if err != nil {
+		return err
+	}
+	if tx.GasLimit < intrinsic {
+		return fmt.Errorf("%w: gas %d, minimum %d", core.ErrIntrinsicGas, tx.GasLimit, intrinsic)
+	}
+
+	from := common.HexToAddress(tx.From)
+	if nonce := statedb.GetNonce(from); tx.Nonce < nonce {
+		return fmt.Errorf("%w: address %s, tx nonce %d, state nonce %d", ErrNonceTooLow, tx.From, tx.Nonce, nonce)
+	}
+	return checkFunds(statedb, from, tx)
+}
+
+// CheckExecutable checks that a transaction can run on the head state right
+// now: it is valid and carries the next nonce of its sender
+func (db *DB) CheckExecutable(tx *model.Transaction) error {
+	if err := db.Validate(tx); err != nil || tx.From == "" {
+		return err
+	}
+	statedb, err := db.StateAt(db.Head())
+	if err != nil {
+		return err
+	}
+	if nonce := statedb.GetNonce(common.HexToAddress(tx.From)); tx.Nonce != nonce {
+		return fmt.Errorf("%w: address %s, tx nonce %d, state nonce %d", ErrNonceTooHigh, tx.From, tx.Nonce, nonce)
+	}
+	return nil
+}
+
+// Restore sets the head to the state after the latest of the recent blocks,
+// given oldest first, and returns the number of blocks that were re-executed
+// because their state was not stored. Blocks created before the state was
+// tracked have no state root; the state starts at genesis after them.
+func (db *DB) Restore(blocks []*model.Block) (int, error) {
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+
+	// Find the latest block whose state is stored
+	base, start := db.genesis, -1
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if blocks[i].StateRoot == "" {
+			start = i + 1
+			break
+		}
+		if root := common.HexToHash(blocks[i].StateRoot); db.HasState(root) {
+			base, start = root, i+1
+			break
+		}
+	}
+	if start < 0 {
+		if blocks[0].Number != 1 {
+			return 0, fmt.Errorf("%w: no state stored for blocks %d to %d", errCannotRebuildState, blocks[0].Number, blocks[len(blocks)-1].Number)
+		}
+		start = 0
+	}
+
+	db.SetHead(base)
+	for _, block := range blocks[start:] {
+		result, err := db.ApplyBlock(block)
+		if err != nil {
+			return 0, err
+		}
+		db.SetHead(result.Root)
+	}
+	return len(blocks) - start, nil
+}
+
+// checkFunds verifies that the sender can pay the value and the maximum gas cost
+func checkFunds(statedb *gethstate.StateDB, from common.Address, tx *model.Transaction) error {
+	cost := new(uint256.Int)
+	if tx.GasPrice != nil && tx.GasPrice.Sign() > 0 {
+		gasPrice, overflow := uint256.FromBig(tx.GasPrice)
+		if overflow {
+			return fmt.Errorf("gas price: %w", errValueOutOfRange)
+		}
+		if _, overflow := cost.MulOverflow(gasPrice, uint256.NewInt(tx.GasLimit)); overflow {
+			return fmt.Errorf("gas cost: %w", errValueOutOfRange)
+		}
+	}
+	if tx.Value != nil && tx.Value.Sign() > 0 {
+		value, overflow := uint256.FromBig(tx.Value)
+		if overflow {
+			return fmt.Errorf("value: %w", errValueOutOfRange)
+		}
+		if _, overflow := cost.AddOverflow(cost, value); overflow {
+			return fmt.Errorf("cost: %w", errValueOutOfRange)
+		}
+	}
+
+	if balance := statedb.GetBalance(from); balance.Lt(cost) {
+		return fmt.Errorf("%w: address %s, balance %s, cost %s", ErrInsufficientFunds, from.Hex(), balance, cost)
+	}
+	return nil
+}
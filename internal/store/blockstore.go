@@ -0,0 +1,309 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"flashblock/internal/model"
+	"flashblock/internal/txindex"
+)
+
+// BlockStore is an append-only log of blocks, one record per line. A record
+// is the hex canonical encoding of a block; stores written before the codec
+// hold JSON documents, which are still read. The blocks form a hash chain
+// that is verified when the store is opened. Pruning removes the oldest
+// blocks, so the first record may link to a block that is no longer stored.
+type BlockStore struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	first  uint64         // Number of the first stored block
+	latest *model.Block   // Last stored block (nil if the store is empty)
+	recent []*model.Block // Most recent blocks loaded at open
+	count  uint64
+
+	checkpoints    *os.File // Checkpoint blocks kept when their range is pruned
+	checkpointPath string
+	lastCheckpoint uint64 // Number of the latest checkpoint (0 if there is none)
+	numCheckpoints int
+	pruneMu        sync.Mutex // Serializes pruning passes
+}
+
+// OpenOptions configure how a block store is opened
+type OpenOptions struct {
+	Recent int            // Number of most recent blocks kept for Recent
+	Repair bool           // Truncate an invalid record at the tail instead of failing
+	Index  *txindex.Index // Reconciled with the valid blocks (optional)
+}
+
+// RecoveryInfo describes the result of opening a block store
+type RecoveryInfo struct {
+	Blocks         uint64 // Valid blocks in the store
+	TruncatedBytes int64  // Bytes removed from the tail by repair
+	Indexed        uint64 // Blocks added to the transaction index
+	Reindexed      bool   // The transaction index did not match the store and was rebuilt
+}
+
+// OpenBlockStore opens or creates the block store at path and verifies its integrity
+func OpenBlockStore(path string, opts OpenOptions) (*BlockStore, *RecoveryInfo, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open block store: %v", err)
+	}
+
+	s := &BlockStore{path: path, file: file, checkpointPath: filepath.Join(filepath.Dir(path), checkpointFile)}
+	info, err := s.verify(opts)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if err := s.openCheckpoints(); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	// New blocks are appended after the last valid record
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return s, info, nil
+}
+
+// verify reads every record, checking the block IDs and the links between
+// blocks, and indexes the blocks after the head of the transaction index
+func (s *BlockStore) verify(opts OpenOptions) (*RecoveryInfo, error) {
+	info := &RecoveryInfo{}
+	reader := bufio.NewReader(s.file)
+
+	var indexed uint64
+	var indexedID string
+	if opts.Index != nil {
+		indexed, indexedID = opts.Index.Head()
+	}
+	matched := indexedID == "" // The head of the index is a stored block
+
+	var offset int64 // End of the last valid record
+	for line := 1; ; line++ {
+		record, readErr := reader.ReadBytes('\n')
+		if len(record) == 0 && readErr == io.EOF {
+			break
+		}
+		if readErr != nil && readErr != io.EOF {
+			return nil, fmt.Errorf("failed to read block store: %v", readErr)
+		}
+
+		block, err := s.checkRecord(record, readErr == io.EOF)
+		if err != nil {
+			// Only the last record can be the result of an interrupted write
+			rest, _ := io.Copy(io.Discard, reader)
+			if rest > 0 {
+				return nil, fmt.Errorf("block store is corrupted at record %d: %v", line, err)
+			}
+			if !opts.Repair {
+				return nil, fmt.Errorf("block store has an invalid record at its tail (record %d: %v); start with --repair to truncate it", line, err)
+			}
+
+			info.TruncatedBytes = int64(len(record))
+			if err := s.file.Truncate(offset); err != nil {
+				return nil, fmt.Errorf("failed to truncate block store: %v", err)
+			}
+			break
+		}
+
+		offset += int64(len(record))
+		if s.latest == nil {
+			s.first = block.Number
+		}
+		s.latest = block
+		s.count++
+		if block.Number == indexed && block.ID == indexedID {
+			matched = true
+		}
+		if opts.Index != nil && (indexedID == "" || block.Number > indexed) {
+			if err := opts.Index.Add(block); err != nil {
+				return nil, err
+			}
+			info.Indexed++
+		}
+		if opts.Recent > 0 {
+			s.recent = append(s.recent, block)
+			if len(s.recent) > opts.Recent {
+				s.recent = s.recent[1:]
+			}
+		}
+	}
+
+	info.Blocks = s.count
+	if opts.Index != nil && !matched {
+		if err := s.reconcileIndex(opts.Index, info); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// reconcileIndex repairs a transaction index whose head is not a stored block.
+// The index is truncated if the store lost its last blocks, and rebuilt from
+// the store if it belongs to another chain.
+func (s *BlockStore) reconcileIndex(index *txindex.Index, info *RecoveryInfo) error {
+	number, _ := index.Head()
+	if s.latest != nil && number > s.latest.Number && index.BlockID(s.latest.Number) == s.latest.ID {
+		return index.Truncate(s.latest)
+	}
+
+	if err := index.Truncate(nil); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	info.Indexed = 0
+	info.Reindexed = true
+	reader := bufio.NewReader(s.file)
+	for range s.count {
+		record, err := reader.ReadBytes('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read block store: %v", err)
+		}
+		block, err := decodeRecord(record)
+		if err != nil {
+			return fmt.Errorf("invalid record: %v", err)
+		}
+		if err := index.Add(block); err != nil {
+			return err
+		}
+		info.Indexed++
+	}
+	return nil
+}
+
+// checkRecord decodes a record and verifies that it extends the chain
+func (s *BlockStore) checkRecord(record []byte, last bool) (*model.Block, error) {
+	if last || !bytes.HasSuffix(record, []byte{'\n'}) {
+		return nil, fmt.Errorf("incomplete record")
+	}
+
+	block, err := decodeRecord(record)
+	if err != nil {
+		return nil, fmt.Errorf("invalid record: %v", err)
+	}
+	if err := model.VerifyBlockID(block); err != nil {
+		return nil, err
+	}
+
+	// The first record anchors the chain; a standby may start from a recent leader block
+	if s.latest == nil {
+		return block, nil
+	}
+
+	if block.PrevBlockID != s.latest.ID {
+		return nil, fmt.Errorf("block %s links to %q instead of %q", block.ID, block.PrevBlockID, s.latest.ID)
+	}
+	if block.Number != s.latest.Number+1 {
+		return nil, fmt.Errorf("block %s has number %d, expected %d", block.ID, block.Number, s.latest.Number+1)
+	}
+
+	return block, nil
+}
+
+// decodeRecord decodes the block of a record without its line break
+func decodeRecord(record []byte) (*model.Block, error) {
+	record = bytes.TrimSuffix(record, []byte{'\n'})
+	if bytes.HasPrefix(record, []byte{'{'}) {
+		var block model.Block
+		if err := json.Unmarshal(record, &block); err != nil {
+			return nil, err
+		}
+		return &block, nil
+	}
+
+	data := make([]byte, hex.DecodedLen(len(record)))
+	if _, err := hex.Decode(data, record); err != nil {
+		return nil, err
+	}
+	return model.DecodeBlock(data)
+}
+
+// Append writes a block to the store and syncs it to disk
+func (s *BlockStore) Append(block *model.Block) error {
+	encoded, err := model.EncodeBlock(block)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, hex.EncodedLen(len(encoded))+1)
+	hex.Encode(data, encoded)
+	data[len(data)-1] = '\n'
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("block store is closed")
+	}
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write block: %v", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync block store: %v", err)
+	}
+
+	if s.latest == nil {
+		s.first = block.Number
+	}
+	s.latest = block
+	s.count++
+	return nil
+}
+
+// Latest returns the last stored block, or nil if the store is empty
+func (s *BlockStore) Latest() *model.Block {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.latest
+}
+
+// Recent returns the most recent blocks loaded when the store was opened, oldest first
+func (s *BlockStore) Recent() []*model.Block {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.recent
+}
+
+// Count returns the number of stored blocks
+func (s *BlockStore) Count() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.count
+}
+
+// Close syncs and closes the store
+func (s *BlockStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Sync()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := s.checkpoints.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
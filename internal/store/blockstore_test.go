@@ -0,0 +1,261 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flashblock/internal/deterministic"
+	"flashblock/internal/model"
+	"flashblock/internal/txindex"
+	"flashblock/pkg/codec"
+)
+
+// makeChain creates n linked blocks with one transaction each, numbered from
+// 1 and one second apart, using seed for the transaction IDs
+func makeChain(t *testing.T, n int, seed string) []*model.Block {
+	t.Helper()
+	factory, clock := deterministic.NewFactory(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), seed)
+	blocks := make([]*model.Block, n)
+	prev := ""
+	for i := range blocks {
+		clock.Advance(time.Second)
+		tx := factory.NewTransaction([]byte(fmt.Sprintf("tx-%d", i)), 1)
+		block := factory.NewBlock(uint64(i+1), []*model.Transaction{tx}, prev)
+		block.Version = codec.Version
+		id, err := model.BlockID(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.ID = id
+		blocks[i] = block
+		prev = id
+	}
+	return blocks
+}
+
+// createStore writes blocks to a new store in a temporary directory and
+// returns its path
+func createStore(t *testing.T, blocks []*model.Block) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "blocks.jsonl")
+	s, _, err := OpenBlockStore(path, OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range blocks {
+		if err := s.Append(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// appendToFile appends raw data to the file at path
+func appendToFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	blocks := makeChain(t, 5, "seed")
+	path := createStore(t, blocks)
+
+	s, info, err := OpenBlockStore(path, OpenOptions{Recent: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if info.Blocks != 5 || info.TruncatedBytes != 0 {
+		t.Errorf("recovery = %+v, want 5 blocks and nothing truncated", info)
+	}
+	if latest := s.Latest(); latest == nil || latest.ID != blocks[4].ID {
+		t.Fatalf("latest = %v, want block %s", latest, blocks[4].ID)
+	}
+	recent := s.Recent()
+	if len(recent) != 2 || recent[0].ID != blocks[3].ID || recent[1].ID != blocks[4].ID {
+		t.Errorf("recent blocks are not the last two")
+	}
+	if len(recent[1].Transactions) != 1 || recent[1].Transactions[0].ID != blocks[4].Transactions[0].ID {
+		t.Errorf("transactions not restored")
+	}
+}
+
+func TestTruncatedTail(t *testing.T) {
+	blocks := makeChain(t, 3, "seed")
+	path := createStore(t, blocks)
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A write interrupted by a crash leaves an incomplete record
+	torn := []byte("f8a1b2c3")
+	appendToFile(t, path, torn)
+
+	if _, _, err := OpenBlockStore(path, OpenOptions{}); err == nil {
+		t.Fatal("opened a store with an incomplete record without repair")
+	}
+
+	s, info, err := OpenBlockStore(path, OpenOptions{Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Blocks != 3 || info.TruncatedBytes != int64(len(torn)) {
+		t.Errorf("recovery = %+v, want 3 blocks and %d bytes truncated", info, len(torn))
+	}
+	if after, err := os.Stat(path); err != nil || after.Size() != before.Size() {
+		t.Fatalf("store not truncated to its last valid record")
+	}
+
+	// New blocks are appended after the repaired tail
+	next := makeChain(t, 4, "seed")[3]
+	if err := s.Append(next); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, info, err = OpenBlockStore(path, OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if info.Blocks != 4 || s.Latest().ID != next.ID {
+		t.Errorf("reopened store has %d blocks, latest %s, want 4 and %s", info.Blocks, s.Latest().ID, next.ID)
+	}
+}
+
+func TestCorruptedRecord(t *testing.T) {
+	blocks := makeChain(t, 3, "seed")
+	path := createStore(t, blocks)
+
+	// A block that does not link to the previous one is not a torn write, and
+	// is not repaired unless it is the last record
+	other := makeChain(t, 4, "other")[3]
+	data, err := model.EncodeBlock(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendToFile(t, path, []byte(fmt.Sprintf("%x\n", data)))
+	appendToFile(t, path, []byte(fmt.Sprintf("%x\n", data)))
+
+	if _, _, err := OpenBlockStore(path, OpenOptions{Repair: true}); err == nil {
+		t.Fatal("opened a store corrupted before its tail")
+	}
+}
+
+func TestTamperedBlock(t *testing.T) {
+	blocks := makeChain(t, 2, "seed")
+	blocks[1].Transactions[0].Priority = 5
+	path := createStore(t, blocks)
+
+	if _, _, err := OpenBlockStore(path, OpenOptions{}); err == nil {
+		t.Fatal("opened a store with a block that does not match its ID")
+	}
+}
+
+func TestIndexCatchUp(t *testing.T) {
+	blocks := makeChain(t, 4, "seed")
+	path := createStore(t, blocks)
+
+	// The index missed the last two blocks, e.g. after a crash between the
+	// store and the index writes
+	index := txindex.New()
+	for _, block := range blocks[:2] {
+		if err := index.Add(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, info, err := OpenBlockStore(path, OpenOptions{Index: index})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if info.Indexed != 2 || info.Reindexed {
+		t.Errorf("recovery = %+v, want 2 blocks indexed without rebuilding", info)
+	}
+	if number, id := index.Head(); number != 4 || id != blocks[3].ID {
+		t.Errorf("index head = %d %s, want 4 %s", number, id, blocks[3].ID)
+	}
+	if index.Len() != 4 {
+		t.Errorf("index has %d transactions, want 4", index.Len())
+	}
+}
+
+func TestIndexAheadOfStore(t *testing.T) {
+	blocks := makeChain(t, 4, "seed")
+	path := createStore(t, blocks[:3])
+
+	// The store lost its last block, which was indexed
+	index := txindex.New()
+	for _, block := range blocks {
+		if err := index.Add(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, info, err := OpenBlockStore(path, OpenOptions{Index: index})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if info.Reindexed {
+		t.Error("index rebuilt instead of truncated")
+	}
+	if number, id := index.Head(); number != 3 || id != blocks[2].ID {
+		t.Errorf("index head = %d %s, want 3 %s", number, id, blocks[2].ID)
+	}
+	if _, ok := index.Lookup(blocks[3].Transactions[0].ID); ok {
+		t.Error("transaction of the lost block still indexed")
+	}
+	if index.Len() != 3 {
+		t.Errorf("index has %d transactions, want 3", index.Len())
+	}
+}
+
+func TestIndexOfOtherChain(t *testing.T) {
+	blocks := makeChain(t, 3, "seed")
+	path := createStore(t, blocks)
+
+	index := txindex.New()
+	for _, block := range makeChain(t, 3, "other") {
+		if err := index.Add(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, info, err := OpenBlockStore(path, OpenOptions{Index: index})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if !info.Reindexed || info.Indexed != 3 {
+		t.Errorf("recovery = %+v, want the index rebuilt from 3 blocks", info)
+	}
+	for _, block := range blocks {
+		loc, ok := index.Lookup(block.Transactions[0].ID)
+		if !ok || loc.BlockID != block.ID {
+			t.Errorf("transaction of block %d not indexed", block.Number)
+		}
+	}
+	if index.Len() != 3 {
+		t.Errorf("index has %d transactions, want 3", index.Len())
+	}
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"flashblock/internal/model"
+)
+
+// checkpointFile holds the checkpoint blocks next to the block store
+const checkpointFile = "checkpoints.jsonl"
+
+// RetentionPolicy selects the blocks pruning keeps. A block is removed only
+// when every configured rule allows it; without rules everything is kept.
+// The latest block and checkpoint blocks are never lost.
+type RetentionPolicy struct {
+	KeepBlocks         uint64        // Most recent blocks kept (0 = no block limit)
+	KeepAge            time.Duration // Blocks younger than this are kept (0 = no age limit)
+	CheckpointInterval uint64        // Blocks whose number is a multiple are kept as checkpoints (0 = none)
+	MinBlocks          uint64        // Most recent blocks kept under any rule, e.g. those restored at startup
+}
+
+// Enabled reports whether the policy removes any blocks
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepBlocks > 0 || p.KeepAge > 0
+}
+
+// expired reports whether a block falls outside the retention window
+func (p RetentionPolicy) expired(block *model.Block, latest uint64, now time.Time) bool {
+	if !p.Enabled() || block.Number >= latest || latest-block.Number < p.MinBlocks {
+		return false
+	}
+	if p.KeepBlocks > 0 && latest-block.Number < p.KeepBlocks {
+		return false
+	}
+	if p.KeepAge > 0 && now.Sub(block.Timestamp) < p.KeepAge {
+		return false
+	}
+	return true
+}
+
+// checkpoint reports whether a block is a checkpoint
+func (p RetentionPolicy) checkpoint(block *model.Block) bool {
+	return p.CheckpointInterval > 0 && block.Number%p.CheckpointInterval == 0
+}
+
+// PruneResult describes a pruning pass
+type PruneResult struct {
+	Removed     uint64 `json:"removed"`     // Blocks removed from the store
+	Checkpoints int    `json:"checkpoints"` // Removed blocks kept as checkpoints
+	FirstBlock  uint64 `json:"first_block"` // Number of the first block left in the store
+	Reclaimed   int64  `json:"reclaimed"`   // Bytes freed
+}
+
+// Prune removes the blocks that expired under the policy. The retained
+// records are copied to a new file that replaces the store atomically, so
+// appends continue during the pass and a crash leaves either file intact.
+// Expired checkpoint blocks are moved to the checkpoint file first.
+func (s *BlockStore) Prune(policy RetentionPolicy) (*PruneResult, error) {
+	s.pruneMu.Lock()
+	defer s.pruneMu.Unlock()
+
+	s.mu.Lock()
+	if s.file == nil {
+		s.mu.Unlock()
+		return nil, errors.New("block store is closed")
+	}
+	var latest uint64
+	if s.latest != nil {
+		latest = s.latest.Number
+	}
+	end, err := s.file.Seek(0, io.SeekCurrent)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PruneResult{}
+	if !policy.Enabled() || latest == 0 {
+		result.FirstBlock = s.First()
+		return result, nil
+	}
+
+	// Find the first retained record; blocks are stored in order
+	source, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open block store: %v", err)
+	}
+	defer source.Close()
+	reader := bufio.NewReader(io.LimitReader(source, end))
+
+	now := time.Now()
+	var offset int64
+	var first []byte
+	var firstBlock *model.Block
+	for {
+		record, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block store: %v", err)
+		}
+		block, err := decodeRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record at offset %d: %v", offset, err)
+		}
+		if !policy.expired(block, latest, now) {
+			first, firstBlock = record, block
+			break
+		}
+		if policy.checkpoint(block) && block.Number > s.lastCheckpoint {
+			if err := s.appendCheckpoint(block, record); err != nil {
+				return nil, err
+			}
+			result.Checkpoints++
+		}
+		offset += int64(len(record))
+		result.Removed++
+	}
+	if result.Removed == 0 || firstBlock == nil {
+		result.Removed = 0
+		result.FirstBlock = s.First()
+		return result, nil
+	}
+
+	// Copy the retained records to a new file
+	tmpPath := s.path + ".prune"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pruned block store: %v", err)
+	}
+	fail := func(err error) (*PruneResult, error) {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if _, err := tmp.Write(first); err != nil {
+		return fail(fmt.Errorf("failed to write pruned block store: %v", err))
+	}
+	if _, err := io.Copy(tmp, reader); err != nil {
+		return fail(fmt.Errorf("failed to write pruned block store: %v", err))
+	}
+
+	// Blocks appended during the copy follow; appends wait until the swap is done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fail(errors.New("block store is closed"))
+	}
+	if _, err := source.Seek(end, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	if _, err := io.Copy(tmp, source); err != nil {
+		return fail(fmt.Errorf("failed to write pruned block store: %v", err))
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(fmt.Errorf("failed to sync pruned block store: %v", err))
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fail(fmt.Errorf("failed to replace block store: %v", err))
+	}
+	syncDir(s.path)
+
+	s.file.Close()
+	s.file = tmp
+	s.first = firstBlock.Number
+	s.count -= result.Removed
+
+	result.FirstBlock = s.first
+	result.Reclaimed = offset
+	return result, nil
+}
+
+// Usage describes the disk usage of a block store
+type Usage struct {
+	Bytes             int64  `json:"bytes"`              // Size of the stored blocks
+	CheckpointBytes   int64  `json:"checkpoint_bytes"`   // Size of the checkpoint blocks
+	ReclaimableBytes  int64  `json:"reclaimable_bytes"`  // Bytes a pruning pass would free now
+	ReclaimableBlocks uint64 `json:"reclaimable_blocks"` // Blocks a pruning pass would remove now
+}
+
+// Usage returns the size of the store and of its checkpoints, and what
+// pruning under the policy would remove now, without removing anything
+func (s *BlockStore) Usage(policy RetentionPolicy) (*Usage, error) {
+	s.mu.Lock()
+	if s.file == nil {
+		s.mu.Unlock()
+		return nil, errors.New("block store is closed")
+	}
+	var latest uint64
+	if s.latest != nil {
+		latest = s.latest.Number
+	}
+	end, err := s.file.Seek(0, io.SeekCurrent)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &Usage{Bytes: end}
+	if info, err := os.Stat(s.checkpointPath); err == nil {
+		usage.CheckpointBytes = info.Size()
+	}
+	if !policy.Enabled() || latest == 0 {
+		return usage, nil
+	}
+
+	source, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open block store: %v", err)
+	}
+	defer source.Close()
+	reader := bufio.NewReader(io.LimitReader(source, end))
+
+	// Blocks are stored in order, so the expired blocks are a prefix
+	now := time.Now()
+	for {
+		record, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block store: %v", err)
+		}
+		block, err := decodeRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record at offset %d: %v", usage.ReclaimableBytes, err)
+		}
+		if !policy.expired(block, latest, now) {
+			break
+		}
+		usage.ReclaimableBytes += int64(len(record))
+		usage.ReclaimableBlocks++
+	}
+	return usage, nil
+}
+
+// First returns the number of the first stored block
+func (s *BlockStore) First() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.first
+}
+
+// Checkpoints returns the number of checkpoint blocks and the number of the
+// latest one
+func (s *BlockStore) Checkpoints() (int, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.numCheckpoints, s.lastCheckpoint
+}
+
+// openCheckpoints opens the checkpoint file, dropping a record that was not
+// completely written
+func (s *BlockStore) openCheckpoints() error {
+	file, err := os.OpenFile(s.checkpointPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoints: %v", err)
+	}
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		record, err := reader.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		block, err := decodeRecord(record)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("invalid checkpoint at offset %d: %v", offset, err)
+		}
+		offset += int64(len(record))
+		s.lastCheckpoint = block.Number
+		s.numCheckpoints++
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to truncate checkpoints: %v", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	s.checkpoints = file
+	return nil
+}
+
+// appendCheckpoint writes the record of a checkpoint block and syncs it to disk
+func (s *BlockStore) appendCheckpoint(block *model.Block, record []byte) error {
+	if _, err := s.checkpoints.Write(record); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	if err := s.checkpoints.Sync(); err != nil {
+		return fmt.Errorf("failed to sync checkpoints: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastCheckpoint = block.Number
+	s.numCheckpoints++
+	return nil
+}
+
+// syncDir syncs the directory of path so a rename is durable
+func syncDir(path string) {
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+}
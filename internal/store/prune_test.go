@@ -0,0 +1,160 @@
+package store
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"flashblock/internal/txindex"
+)
+
+func TestPruneKeepBlocks(t *testing.T) {
+	blocks := makeChain(t, 12, "seed")
+	path := createStore(t, blocks[:10])
+	s, _, err := OpenBlockStore(path, OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := RetentionPolicy{KeepBlocks: 3, CheckpointInterval: 4}
+	usage, err := s.Usage(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.Prune(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Removed != 7 || result.FirstBlock != 8 || result.Checkpoints != 1 {
+		t.Errorf("result = %+v, want 7 blocks removed, 1 checkpoint, first block 8", result)
+	}
+	if usage.ReclaimableBlocks != result.Removed || usage.ReclaimableBytes != result.Reclaimed {
+		t.Errorf("usage = %+v, want what the pass removed: %+v", usage, result)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Size()-after.Size() != result.Reclaimed {
+		t.Errorf("store shrank by %d bytes, want %d", before.Size()-after.Size(), result.Reclaimed)
+	}
+	if s.Count() != 3 || s.First() != 8 {
+		t.Errorf("store has %d blocks from %d, want 3 from 8", s.Count(), s.First())
+	}
+	if n, last := s.Checkpoints(); n != 1 || last != 4 {
+		t.Errorf("checkpoints = %d up to %d, want 1 up to 4", n, last)
+	}
+
+	// Appends continue on the pruned store, and a second pass keeps the
+	// checkpoints it already moved
+	for _, block := range blocks[10:] {
+		if err := s.Append(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if result, err = s.Prune(policy); err != nil {
+		t.Fatal(err)
+	}
+	if result.Removed != 2 || result.FirstBlock != 10 || result.Checkpoints != 1 {
+		t.Errorf("second result = %+v, want 2 blocks removed, 1 checkpoint, first block 10", result)
+	}
+	s.Close()
+
+	// The pruned store starts with a block whose parent is gone, which is
+	// accepted when it is opened
+	s, info, err := OpenBlockStore(path, OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Blocks != 3 || s.First() != 10 || s.Latest().ID != blocks[11].ID {
+		t.Errorf("reopened store has %d blocks from %d, want 3 from 10", info.Blocks, s.First())
+	}
+	if n, last := s.Checkpoints(); n != 2 || last != 8 {
+		t.Errorf("checkpoints = %d up to %d, want 2 up to 8", n, last)
+	}
+}
+
+func TestPruneKeepAge(t *testing.T) {
+	// The blocks are from 2024, so they all expired under an age limit
+	blocks := makeChain(t, 5, "seed")
+	s, _, err := OpenBlockStore(createStore(t, blocks), OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Blocks are only removed when every rule allows it
+	result, err := s.Prune(RetentionPolicy{KeepBlocks: 2, KeepAge: 100 * 365 * 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Removed != 0 {
+		t.Fatalf("removed %d blocks younger than the age limit", result.Removed)
+	}
+
+	// Blocks restored at startup are kept under any rule
+	if result, err = s.Prune(RetentionPolicy{KeepAge: time.Hour, MinBlocks: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if result.Removed != 3 || s.First() != 4 {
+		t.Errorf("result = %+v, want 3 blocks removed up to the 2 restored", result)
+	}
+
+	// The latest block is never removed
+	if result, err = s.Prune(RetentionPolicy{KeepAge: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 1 || s.Latest().ID != blocks[4].ID {
+		t.Errorf("store has %d blocks, want only the latest", s.Count())
+	}
+}
+
+func TestPruneDisabled(t *testing.T) {
+	s, _, err := OpenBlockStore(createStore(t, makeChain(t, 5, "seed")), OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result, err := s.Prune(RetentionPolicy{CheckpointInterval: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Removed != 0 || result.FirstBlock != 1 || s.Count() != 5 {
+		t.Errorf("result = %+v, want nothing removed without a retention rule", result)
+	}
+}
+
+func TestPrunerPrunesIndex(t *testing.T) {
+	blocks := makeChain(t, 6, "seed")
+	index := txindex.New()
+	s, _, err := OpenBlockStore(createStore(t, blocks), OpenOptions{Index: index})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	p := NewPruner(s, index, RetentionPolicy{KeepBlocks: 2}, time.Hour)
+	if _, err := p.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range blocks {
+		_, ok := index.Lookup(block.Transactions[0].ID)
+		if want := block.Number >= 5; ok != want {
+			t.Errorf("transaction of block %d indexed: %v, want %v", block.Number, ok, want)
+		}
+	}
+	if index.Len() != 2 {
+		t.Errorf("index has %d transactions, want 2", index.Len())
+	}
+
+	status := p.Status()
+	if status.TotalRemoved != 4 || status.FirstBlock != 5 || status.LastRun == nil || status.LastError != "" {
+		t.Errorf("status = %+v, want 4 blocks removed", status)
+	}
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/txindex"
+)
+
+// PruneStatus reports the retention policy and the pruning passes of a store
+type PruneStatus struct {
+	KeepBlocks         uint64       `json:"keep_blocks"`
+	KeepAge            string       `json:"keep_age"`
+	CheckpointInterval uint64       `json:"checkpoint_interval"`
+	Interval           string       `json:"interval"`        // Time between background passes
+	Blocks             uint64       `json:"blocks"`          // Blocks in the store
+	FirstBlock         uint64       `json:"first_block"`     // Number of the first stored block
+	Checkpoints        int          `json:"checkpoints"`     // Checkpoint blocks kept outside the store
+	LastCheckpoint     uint64       `json:"last_checkpoint"` // Number of the latest checkpoint
+	TotalRemoved       uint64       `json:"total_removed"`   // Blocks removed since the start
+	LastRun            *time.Time   `json:"last_run,omitempty"`
+	LastResult         *PruneResult `json:"last_result,omitempty"`
+	LastError          string       `json:"last_error,omitempty"`
+}
+
+// Pruner applies a retention policy to a block store in the background and
+// removes the pruned transactions from the index
+type Pruner struct {
+	store    *BlockStore
+	index    *txindex.Index
+	policy   RetentionPolicy
+	interval time.Duration
+
+	mu           sync.Mutex
+	lastRun      time.Time
+	lastResult   *PruneResult
+	lastError    string
+	totalRemoved uint64
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPruner creates a pruner that runs a pass every interval
+func NewPruner(store *BlockStore, index *txindex.Index, policy RetentionPolicy, interval time.Duration) *Pruner {
+	return &Pruner{
+		store:    store,
+		index:    index,
+		policy:   policy,
+		interval: interval,
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start runs the background passes, the first one right away
+func (p *Pruner) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			p.Prune()
+			select {
+			case <-ticker.C:
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background passes, waiting for a pass in progress
+func (p *Pruner) Close() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// Prune runs a pass now
+func (p *Pruner) Prune() (*PruneResult, error) {
+	start := time.Now()
+	result, err := p.store.Prune(p.policy)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastRun = start
+	if err != nil {
+		p.lastError = err.Error()
+		logging.Errorf("Failed to prune block store: %v", err)
+		return nil, err
+	}
+	p.lastResult = result
+	p.lastError = ""
+	p.totalRemoved += result.Removed
+
+	if result.Removed > 0 {
+		if p.index != nil {
+			if err := p.index.Prune(result.FirstBlock); err != nil {
+				logging.Errorf("Failed to prune transaction index: %v", err)
+			}
+		}
+		logging.Infof("Pruned %d blocks (%d bytes, %d checkpoints kept), the store starts at block %d in %v",
+			result.Removed, result.Reclaimed, result.Checkpoints, result.FirstBlock, time.Since(start))
+	}
+	return result, nil
+}
+
+// Usage returns the size of the store and what a pass would remove now
+func (p *Pruner) Usage() (*Usage, error) {
+	return p.store.Usage(p.policy)
+}
+
+// Status returns the policy and the result of the last pass
+func (p *Pruner) Status() PruneStatus {
+	checkpoints, lastCheckpoint := p.store.Checkpoints()
+	status := PruneStatus{
+		KeepBlocks:         p.policy.KeepBlocks,
+		KeepAge:            p.policy.KeepAge.String(),
+		CheckpointInterval: p.policy.CheckpointInterval,
+		Interval:           p.interval.String(),
+		Blocks:             p.store.Count(),
+		FirstBlock:         p.store.First(),
+		Checkpoints:        checkpoints,
+		LastCheckpoint:     lastCheckpoint,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status.TotalRemoved = p.totalRemoved
+	status.LastResult = p.lastResult
+	status.LastError = p.lastError
+	if !p.lastRun.IsZero() {
+		lastRun := p.lastRun
+		status.LastRun = &lastRun
+	}
+	return status
+}
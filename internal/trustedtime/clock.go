@@ -0,0 +1,428 @@
+// Package trustedtime timestamps blocks with time obtained from Roughtime
+// servers instead of the host clock, which is not trusted inside the TEE.
+//
+// Every synchronization queries all servers with fresh nonces and verifies
+// their signed answers against pinned public keys. The intervals of the
+// answers, widened by the round trips, must overlap for a quorum of servers;
+// the trusted time is the middle of the overlap. Between synchronizations the
+// time is extrapolated with the monotonic clock, whose rate error is bounded by
+// MaxDrift: the uncertainty grows with the time since the last
+// synchronization, and a synchronization that finds the monotonic clock
+// outside the bound stops the timestamps until the next one agrees again.
+package trustedtime
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"flashblock/internal/logging"
+	"flashblock/internal/model"
+)
+
+// Source is the name of the source in the time proofs of blocks
+const Source = "roughtime"
+
+// Errors
+var (
+	ErrNotSynchronized = errors.New("trusted time is not synchronized")
+	ErrUncertain       = errors.New("trusted time is too uncertain")
+)
+
+// Config holds configuration for the trusted clock
+type Config struct {
+	Servers        []Server
+	Quorum         int           // Servers that must agree on the time (a majority if 0)
+	SyncInterval   time.Duration // Time between synchronizations
+	Timeout        time.Duration // Deadline of a query
+	MaxDrift       float64       // Maximum rate error of the monotonic clock in parts per million
+	MaxUncertainty time.Duration // Timestamps are refused while the error bound is larger
+	Fallback       bool          // Timestamp with the host clock, without a proof, while the time cannot be trusted
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		SyncInterval:   time.Minute,
+		Timeout:        2 * time.Second,
+		MaxDrift:       200,
+		MaxUncertainty: time.Second,
+	}
+}
+
+// ServerStatus reports the last answer of a server
+type ServerStatus struct {
+	Name     string     `json:"name"`
+	Address  string     `json:"address"`
+	Midpoint *time.Time `json:"midpoint,omitempty"`
+	Radius   string     `json:"radius,omitempty"`
+	RTT      string     `json:"rtt,omitempty"`
+	Agreed   bool       `json:"agreed"` // Part of the quorum
+	Error    string     `json:"error,omitempty"`
+}
+
+// Status reports the synchronization of the clock
+type Status struct {
+	Trusted     bool           `json:"trusted"` // Blocks are timestamped with a proof
+	Now         time.Time      `json:"now"`
+	Uncertainty string         `json:"uncertainty,omitempty"`
+	LastSync    *time.Time     `json:"last_sync,omitempty"`
+	Drift       float64        `json:"drift_ppm"`   // Rate error of the monotonic clock measured between the last synchronizations
+	HostOffset  string         `json:"host_offset"` // Host clock minus trusted time at the last synchronization
+	Error       string         `json:"error,omitempty"`
+	Servers     []ServerStatus `json:"servers"`
+}
+
+// anchor is a synchronization: the trusted time at a monotonic clock reading
+type anchor struct {
+	time    time.Time
+	mono    time.Time // Local reading with a monotonic component
+	radius  time.Duration
+	servers []string
+}
+
+// Clock tells the trusted time. It implements model.Clock so it can replace
+// the wall clock, and stamps blocks with the proof of their time.
+type Clock struct {
+	config *Config
+
+	mu         sync.Mutex
+	anchor     *anchor
+	violation  error     // Set while the monotonic clock is outside the drift bound
+	syncErr    error     // Error of the last synchronization
+	last       time.Time // Latest stamped time, so timestamps never go backwards
+	trusted    bool      // State of the last stamp, to log the transitions
+	drift      float64
+	hostOffset time.Duration
+	servers    []ServerStatus
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a clock synchronized with the servers of the configuration
+func New(config *Config) (*Clock, error) {
+	if len(config.Servers) == 0 {
+		return nil, errors.New("no roughtime servers configured")
+	}
+	if config.Quorum <= 0 {
+		config.Quorum = len(config.Servers)/2 + 1
+	}
+	if config.Quorum > len(config.Servers) {
+		return nil, fmt.Errorf("quorum of %d exceeds the %d servers", config.Quorum, len(config.Servers))
+	}
+	defaults := DefaultConfig()
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = defaults.SyncInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MaxUncertainty <= 0 {
+		config.MaxUncertainty = defaults.MaxUncertainty
+	}
+
+	c := &Clock{
+		config:  config,
+		trusted: true,
+		quit:    make(chan struct{}),
+	}
+	for _, server := range config.Servers {
+		c.servers = append(c.servers, ServerStatus{Name: server.Name, Address: server.Address})
+	}
+	return c, nil
+}
+
+// Start synchronizes the clock, then again every sync interval in the
+// background. A failed first synchronization is returned, but the clock keeps
+// trying.
+func (c *Clock) Start() error {
+	err := c.Sync()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.config.SyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Sync(); err != nil {
+					logging.Warnf("Failed to synchronize trusted time: %v", err)
+				}
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+	return err
+}
+
+// Close stops the synchronizations
+func (c *Clock) Close() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// Now returns the trusted time, or the host time before the first
+// synchronization
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.anchor == nil {
+		return time.Now()
+	}
+	return c.anchor.time.Add(time.Since(c.anchor.mono))
+}
+
+// AfterFunc calls f after d; durations are measured by the monotonic clock
+func (c *Clock) AfterFunc(d time.Duration, f func()) model.Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Stamp returns the trusted time for a block and the proof of its bounds.
+// Stamped times increase strictly. While the time cannot be trusted, an error
+// is returned, or the host time without a proof with Fallback.
+func (c *Clock) Stamp() (time.Time, *model.TimeProof, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, uncertainty, err := c.current()
+	if err != nil {
+		if c.trusted {
+			logging.Warnf("Block timestamps cannot be trusted: %v", err)
+			c.trusted = false
+		}
+		if !c.config.Fallback {
+			return time.Time{}, nil, err
+		}
+		return c.next(time.Now().Round(0)), nil, nil
+	}
+	if !c.trusted {
+		logging.Infof("Block timestamps are trusted again (uncertainty %v)", uncertainty)
+		c.trusted = true
+	}
+
+	// A synchronization may have moved the time back; the difference is added
+	// to the uncertainty of the clamped time
+	stamped := c.next(t)
+	uncertainty += stamped.Sub(t)
+	return stamped, &model.TimeProof{
+		Source:      Source,
+		Servers:     c.anchor.servers,
+		Synced:      c.anchor.time,
+		Uncertainty: uncertainty,
+	}, nil
+}
+
+// next returns t, or just after the last stamped time if t is not later, and
+// records it as the last stamped time
+func (c *Clock) next(t time.Time) time.Time {
+	if !t.After(c.last) {
+		t = c.last.Add(time.Nanosecond)
+	}
+	c.last = t
+	return t
+}
+
+// current returns the trusted time and its uncertainty, or why the time
+// cannot be trusted
+func (c *Clock) current() (time.Time, time.Duration, error) {
+	if c.anchor == nil {
+		if c.syncErr != nil {
+			return time.Time{}, 0, fmt.Errorf("%w: %v", ErrNotSynchronized, c.syncErr)
+		}
+		return time.Time{}, 0, ErrNotSynchronized
+	}
+	if c.violation != nil {
+		return time.Time{}, 0, c.violation
+	}
+	elapsed := time.Since(c.anchor.mono)
+	uncertainty := c.anchor.radius + c.driftBound(elapsed)
+	if uncertainty > c.config.MaxUncertainty {
+		return time.Time{}, 0, fmt.Errorf("%w: %v since the last synchronization, error bound %v exceeds %v",
+			ErrUncertain, elapsed.Round(time.Millisecond), uncertainty, c.config.MaxUncertainty)
+	}
+	return c.anchor.time.Add(elapsed), uncertainty, nil
+}
+
+// driftBound returns the maximum error the monotonic clock accumulates over d
+func (c *Clock) driftBound(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * c.config.MaxDrift / 1e6)
+}
+
+// interval is the range an answer places the true time in at a common local reading
+type interval struct {
+	lo, hi time.Time
+	server string
+}
+
+// Sync queries every server and moves the clock to the time a quorum agrees
+// on. The monotonic clock is checked against the previous synchronization.
+func (c *Clock) Sync() error {
+	servers := c.config.Servers
+	samples := make([]*Sample, len(servers))
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			samples[i], errs[i] = query(server, c.config.Timeout)
+		}()
+	}
+	wg.Wait()
+
+	// Every answer is moved to the same local reading; the server signed its
+	// time at an unknown point of the round trip, which widens the interval
+	ref := time.Now()
+	var intervals []interval
+	status := make([]ServerStatus, len(servers))
+	for i, server := range servers {
+		status[i] = ServerStatus{Name: server.Name, Address: server.Address}
+		if errs[i] != nil {
+			status[i].Error = errs[i].Error()
+			logging.Debugf("Roughtime server %s failed: %v", server.Name, errs[i])
+			continue
+		}
+		s := samples[i]
+		rtt := s.Received.Sub(s.Sent)
+		midpoint := s.Midpoint
+		status[i].Midpoint = &midpoint
+		status[i].Radius = s.Radius.String()
+		status[i].RTT = rtt.String()
+
+		local := s.Sent.Add(rtt / 2)
+		at := s.Midpoint.Add(ref.Sub(local))
+		width := s.Radius + rtt/2 + c.driftBound(ref.Sub(s.Sent))
+		intervals = append(intervals, interval{lo: at.Add(-width), hi: at.Add(width), server: server.Name})
+	}
+
+	lo, hi, agreed := agree(intervals)
+	if len(agreed) < c.config.Quorum {
+		err := fmt.Errorf("%d of %d servers agree on the time (%d answered), %d required", len(agreed), len(servers), len(intervals), c.config.Quorum)
+		c.mu.Lock()
+		c.syncErr = err
+		c.servers = status
+		c.mu.Unlock()
+		return err
+	}
+	for i := range status {
+		for _, name := range agreed {
+			if status[i].Name == name {
+				status[i].Agreed = true
+			}
+		}
+	}
+	next := &anchor{
+		time:    lo.Add(hi.Sub(lo) / 2),
+		mono:    ref,
+		radius:  hi.Sub(lo) / 2,
+		servers: agreed,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.syncErr = nil
+	c.servers = status
+	c.hostOffset = ref.Round(0).Sub(next.time)
+
+	// The monotonic clock must have measured the time between the
+	// synchronizations within the drift bound
+	if prev := c.anchor; prev != nil {
+		trusted := next.time.Sub(prev.time)
+		local := next.mono.Sub(prev.mono)
+		diff := trusted - local
+		if diff < 0 {
+			diff = -diff
+		}
+		c.drift = float64(trusted-local) / float64(local) * 1e6
+		if bound := prev.radius + next.radius + c.driftBound(local); diff > bound {
+			c.violation = fmt.Errorf("local clock measured %v between synchronizations that were %v apart, more than the bound of %v",
+				local.Round(time.Microsecond), trusted.Round(time.Microsecond), bound)
+			logging.Errorf("Trusted time drift bound exceeded: %v", c.violation)
+		} else {
+			c.violation = nil
+		}
+	}
+	if c.anchor == nil {
+		logging.Infof("Synchronized trusted time with %d of %d servers (uncertainty %v, host clock offset %v)",
+			len(agreed), len(servers), next.radius, c.hostOffset)
+	} else {
+		logging.Debugf("Synchronized trusted time with %d of %d servers (uncertainty %v, drift %.1f ppm, host clock offset %v)",
+			len(agreed), len(servers), next.radius, c.drift, c.hostOffset)
+	}
+	c.anchor = next
+	return nil
+}
+
+// agree returns the range where most intervals overlap and the servers whose
+// intervals contain it (Marzullo's algorithm)
+func agree(intervals []interval) (time.Time, time.Time, []string) {
+	type edge struct {
+		t     time.Time
+		start bool
+	}
+	edges := make([]edge, 0, 2*len(intervals))
+	for _, iv := range intervals {
+		edges = append(edges, edge{iv.lo, true}, edge{iv.hi, false})
+	}
+	// Starts sort before ends at the same time, so touching intervals overlap
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].t.Equal(edges[j].t) {
+			return edges[i].start && !edges[j].start
+		}
+		return edges[i].t.Before(edges[j].t)
+	})
+
+	var lo, hi time.Time
+	count, best := 0, 0
+	for i, e := range edges {
+		if !e.start {
+			count--
+			continue
+		}
+		count++
+		if count > best {
+			best = count
+			lo, hi = e.t, edges[i+1].t
+		}
+	}
+
+	var servers []string
+	for _, iv := range intervals {
+		if best > 0 && !iv.lo.After(lo) && !iv.hi.Before(hi) {
+			servers = append(servers, iv.server)
+		}
+	}
+	return lo, hi, servers
+}
+
+// Status returns the synchronization state of the clock
+func (c *Clock) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := Status{
+		Drift:      c.drift,
+		HostOffset: c.hostOffset.String(),
+		Servers:    append([]ServerStatus(nil), c.servers...),
+	}
+	t, uncertainty, err := c.current()
+	if err != nil {
+		status.Error = err.Error()
+		status.Now = time.Now()
+	} else {
+		status.Trusted = true
+		status.Now = t
+		status.Uncertainty = uncertainty.String()
+	}
+	if c.anchor != nil {
+		synced := c.anchor.time
+		status.LastSync = &synced
+	}
+	return status
+}
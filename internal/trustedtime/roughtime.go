@@ -0,0 +1,352 @@
+package trustedtime
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Roughtime messages are maps of 32-bit tags to values whose lengths are
+// multiples of 4 bytes: the number of tags, the offsets of all values but the
+// first, the tags in increasing order and the values, all little endian.
+// Requests are padded to requestSize so servers do not amplify traffic.
+const (
+	requestSize = 1024
+	nonceSize   = 64
+	maxResponse = 4096
+)
+
+// Tags of the messages
+var (
+	tagSIG  = makeTag("SIG\x00")
+	tagNONC = makeTag("NONC")
+	tagPAD  = makeTag("PAD\xff")
+	tagPATH = makeTag("PATH")
+	tagSREP = makeTag("SREP")
+	tagCERT = makeTag("CERT")
+	tagINDX = makeTag("INDX")
+	tagROOT = makeTag("ROOT")
+	tagMIDP = makeTag("MIDP")
+	tagRADI = makeTag("RADI")
+	tagDELE = makeTag("DELE")
+	tagMINT = makeTag("MINT")
+	tagMAXT = makeTag("MAXT")
+	tagPUBK = makeTag("PUBK")
+)
+
+// Contexts of the signatures made by the servers
+var (
+	responseContext   = []byte("RoughTime v1 response signature\x00")
+	delegationContext = []byte("RoughTime v1 delegation signature--\x00")
+)
+
+// Server is a Roughtime server and its long-term Ed25519 public key
+type Server struct {
+	Name      string
+	Address   string // UDP host:port
+	PublicKey ed25519.PublicKey
+}
+
+// ParsePublicKey decodes a base64 or hex Ed25519 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		key, err = hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	}
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key %q (expected %d base64 or hex bytes)", s, ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Sample is the verified answer of a server: the true time was within Radius
+// of Midpoint when the server signed it, which happened between Sent and
+// Received on the local clock
+type Sample struct {
+	Server   string
+	Midpoint time.Time
+	Radius   time.Duration
+	Sent     time.Time
+	Received time.Time
+}
+
+// query asks a server for the time with a fresh nonce and verifies the
+// signed response
+func query(server Server, timeout time.Duration) (*Sample, error) {
+	var nonce [nonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	request, err := encodeMessage(map[uint32][]byte{tagNONC: nonce[:]}, requestSize)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("udp", server.Address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	sent := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxResponse)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	received := time.Now()
+
+	midpoint, radius, err := verifyResponse(buf[:n], nonce[:], server.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Sample{
+		Server:   server.Name,
+		Midpoint: midpoint,
+		Radius:   radius,
+		Sent:     sent,
+		Received: received,
+	}, nil
+}
+
+// verifyResponse checks that a response is signed by a key delegated by the
+// root key for the time it reports, and that its Merkle tree includes the
+// nonce, and returns the reported midpoint and radius
+func verifyResponse(data, nonce []byte, rootKey ed25519.PublicKey) (time.Time, time.Duration, error) {
+	response, err := decodeMessage(data)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	values, err := lookup(response, tagSIG, tagSREP, tagCERT, tagPATH, tagINDX)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	sig, srepData, certData, path, indexData := values[0], values[1], values[2], values[3], values[4]
+
+	// The certificate delegates signing to an online key for a time range
+	cert, err := decodeMessage(certData)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid certificate: %v", err)
+	}
+	certSig, ok := cert[tagSIG]
+	if !ok {
+		return time.Time{}, 0, errors.New("certificate has no signature")
+	}
+	deleData, ok := cert[tagDELE]
+	if !ok {
+		return time.Time{}, 0, errors.New("certificate has no delegation")
+	}
+	if !ed25519.Verify(rootKey, append(append([]byte{}, delegationContext...), deleData...), certSig) {
+		return time.Time{}, 0, errors.New("invalid delegation signature")
+	}
+	dele, err := decodeMessage(deleData)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid delegation: %v", err)
+	}
+	minTime, err := readUint64(dele, tagMINT)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	maxTime, err := readUint64(dele, tagMAXT)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	onlineKey, ok := dele[tagPUBK]
+	if !ok || len(onlineKey) != ed25519.PublicKeySize {
+		return time.Time{}, 0, errors.New("delegation has no valid public key")
+	}
+
+	// The online key signs the root of the tree of the nonces it answered
+	if !ed25519.Verify(ed25519.PublicKey(onlineKey), append(append([]byte{}, responseContext...), srepData...), sig) {
+		return time.Time{}, 0, errors.New("invalid response signature")
+	}
+	srep, err := decodeMessage(srepData)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid signed response: %v", err)
+	}
+	root, ok := srep[tagROOT]
+	if !ok {
+		return time.Time{}, 0, errors.New("signed response has no root")
+	}
+	midpoint, err := readUint64(srep, tagMIDP)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	radius, err := readUint32(srep, tagRADI)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if midpoint < minTime || midpoint > maxTime {
+		return time.Time{}, 0, errors.New("time is outside the validity of the delegation")
+	}
+
+	if len(indexData) != 4 || len(path)%sha512.Size != 0 {
+		return time.Time{}, 0, errors.New("invalid Merkle path")
+	}
+	index := binary.LittleEndian.Uint32(indexData)
+	hash := hashLeaf(nonce)
+	for i := 0; i < len(path); i += sha512.Size {
+		if index&1 == 0 {
+			hash = hashNode(hash, path[i:i+sha512.Size])
+		} else {
+			hash = hashNode(path[i:i+sha512.Size], hash)
+		}
+		index >>= 1
+	}
+	if !bytes.Equal(hash, root) {
+		return time.Time{}, 0, errors.New("nonce is not included in the signed response")
+	}
+
+	return time.UnixMicro(int64(midpoint)).UTC(), time.Duration(radius) * time.Microsecond, nil
+}
+
+// hashLeaf returns the hash of a leaf of the Merkle tree
+func hashLeaf(data []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// hashNode returns the hash of an inner node of the Merkle tree
+func hashNode(left, right []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// makeTag returns the value of a four character tag
+func makeTag(name string) uint32 {
+	return binary.LittleEndian.Uint32([]byte(name))
+}
+
+// encodeMessage encodes a message, adding a PAD value so that it is at least
+// minSize bytes long
+func encodeMessage(values map[uint32][]byte, minSize int) ([]byte, error) {
+	size := func(values map[uint32][]byte) int {
+		n := 4 + 8*len(values) - 4
+		for _, v := range values {
+			n += len(v)
+		}
+		return n
+	}
+	if n := size(values); n < minSize {
+		padding := minSize - n - 8
+		if _, ok := values[tagPAD]; ok || padding < 0 {
+			return nil, errors.New("cannot pad message")
+		}
+		values[tagPAD] = make([]byte, padding)
+	}
+
+	tags := make([]uint32, 0, len(values))
+	for tag, value := range values {
+		if len(value)%4 != 0 {
+			return nil, fmt.Errorf("value of tag %08x is not a multiple of 4 bytes", tag)
+		}
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	msg := binary.LittleEndian.AppendUint32(nil, uint32(len(tags)))
+	var offset uint32
+	for _, tag := range tags[:len(tags)-1] {
+		offset += uint32(len(values[tag]))
+		msg = binary.LittleEndian.AppendUint32(msg, offset)
+	}
+	for _, tag := range tags {
+		msg = binary.LittleEndian.AppendUint32(msg, tag)
+	}
+	for _, tag := range tags {
+		msg = append(msg, values[tag]...)
+	}
+	return msg, nil
+}
+
+// decodeMessage decodes a message, checking that its tags are in increasing
+// order and its offsets within bounds
+func decodeMessage(data []byte) (map[uint32][]byte, error) {
+	if len(data) < 4 || len(data)%4 != 0 {
+		return nil, errors.New("invalid message length")
+	}
+	n := int(binary.LittleEndian.Uint32(data))
+	if n == 0 || n > (len(data)-4)/8+1 {
+		return nil, errors.New("invalid number of tags")
+	}
+	header := 4 + 4*(n-1) + 4*n
+	if header > len(data) {
+		return nil, errors.New("truncated message header")
+	}
+	values := data[header:]
+
+	message := make(map[uint32][]byte, n)
+	var start uint32
+	var last uint32
+	for i := 0; i < n; i++ {
+		end := uint32(len(values))
+		if i < n-1 {
+			end = binary.LittleEndian.Uint32(data[4+4*i:])
+		}
+		tag := binary.LittleEndian.Uint32(data[4+4*(n-1)+4*i:])
+		if end < start || end > uint32(len(values)) || end%4 != 0 {
+			return nil, fmt.Errorf("invalid offset of tag %08x", tag)
+		}
+		if i > 0 && tag <= last {
+			return nil, errors.New("tags are not in increasing order")
+		}
+		message[tag] = values[start:end]
+		start, last = end, tag
+	}
+	return message, nil
+}
+
+// lookup returns the values of tags that must all be present
+func lookup(message map[uint32][]byte, tags ...uint32) ([][]byte, error) {
+	values := make([][]byte, len(tags))
+	for i, tag := range tags {
+		value, ok := message[tag]
+		if !ok {
+			return nil, fmt.Errorf("message has no %s", tagName(tag))
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// tagName returns the characters of a tag for error messages
+func tagName(tag uint32) string {
+	return strings.TrimRight(string(binary.LittleEndian.AppendUint32(nil, tag)), "\x00\xff")
+}
+
+// readUint64 reads a little endian 64-bit value
+func readUint64(message map[uint32][]byte, tag uint32) (uint64, error) {
+	value, ok := message[tag]
+	if !ok || len(value) != 8 {
+		return 0, fmt.Errorf("missing or invalid %s", tagName(tag))
+	}
+	return binary.LittleEndian.Uint64(value), nil
+}
+
+// readUint32 reads a little endian 32-bit value
+func readUint32(message map[uint32][]byte, tag uint32) (uint32, error) {
+	value, ok := message[tag]
+	if !ok || len(value) != 4 {
+		return 0, fmt.Errorf("missing or invalid %s", tagName(tag))
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
@@ -0,0 +1,383 @@
+package trustedtime
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServer signs Roughtime responses with an online key delegated by its
+// root key, reporting the host time moved by offset
+type testServer struct {
+	rootKey   ed25519.PrivateKey
+	onlineKey ed25519.PrivateKey
+	offset    time.Duration
+	radius    time.Duration
+	minTime   time.Time // Validity of the delegation
+	maxTime   time.Time
+}
+
+func newTestServer(t *testing.T, offset time.Duration) *testServer {
+	t.Helper()
+	_, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, onlineKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testServer{
+		rootKey:   rootKey,
+		onlineKey: onlineKey,
+		offset:    offset,
+		radius:    time.Millisecond,
+		minTime:   time.Now().Add(-24 * time.Hour),
+		maxTime:   time.Now().Add(24 * time.Hour),
+	}
+}
+
+func (s *testServer) publicKey() ed25519.PublicKey {
+	return s.rootKey.Public().(ed25519.PublicKey)
+}
+
+// respond answers the nonce at index of a batch of nonces
+func (s *testServer) respond(nonces [][]byte, index int) []byte {
+	// Build the Merkle tree of the nonces, a power of two of them, and the
+	// path from the leaf at index to the root
+	level := make([][]byte, len(nonces))
+	for i, nonce := range nonces {
+		level[i] = hashLeaf(nonce)
+	}
+	var path []byte
+	for i := index; len(level) > 1; i /= 2 {
+		path = append(path, level[i^1]...)
+		next := make([][]byte, len(level)/2)
+		for j := range next {
+			next[j] = hashNode(level[2*j], level[2*j+1])
+		}
+		level = next
+	}
+
+	midpoint := time.Now().Add(s.offset)
+	srep := mustEncode(map[uint32][]byte{
+		tagROOT: level[0],
+		tagMIDP: binary.LittleEndian.AppendUint64(nil, uint64(midpoint.UnixMicro())),
+		tagRADI: binary.LittleEndian.AppendUint32(nil, uint32(s.radius.Microseconds())),
+	})
+	dele := mustEncode(map[uint32][]byte{
+		tagMINT: binary.LittleEndian.AppendUint64(nil, uint64(s.minTime.UnixMicro())),
+		tagMAXT: binary.LittleEndian.AppendUint64(nil, uint64(s.maxTime.UnixMicro())),
+		tagPUBK: s.onlineKey.Public().(ed25519.PublicKey),
+	})
+	cert := mustEncode(map[uint32][]byte{
+		tagSIG:  ed25519.Sign(s.rootKey, append(append([]byte{}, delegationContext...), dele...)),
+		tagDELE: dele,
+	})
+	return mustEncode(map[uint32][]byte{
+		tagSIG:  ed25519.Sign(s.onlineKey, append(append([]byte{}, responseContext...), srep...)),
+		tagSREP: srep,
+		tagCERT: cert,
+		tagPATH: path,
+		tagINDX: binary.LittleEndian.AppendUint32(nil, uint32(index)),
+	})
+}
+
+// listen answers requests on a local UDP port until the test ends
+func (s *testServer) listen(t *testing.T, name string) Server {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request, err := decodeMessage(buf[:n])
+			if err != nil || n < requestSize {
+				continue
+			}
+			nonce := request[tagNONC]
+			conn.WriteTo(s.respond([][]byte{nonce}, 0), addr)
+		}
+	}()
+	return Server{Name: name, Address: conn.LocalAddr().String(), PublicKey: s.publicKey()}
+}
+
+// mustEncode encodes a message whose values are all multiples of 4 bytes
+func mustEncode(values map[uint32][]byte) []byte {
+	msg, err := encodeMessage(values, 0)
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
+
+func testNonce(b byte) []byte {
+	return bytes.Repeat([]byte{b}, nonceSize)
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	values := map[uint32][]byte{
+		tagNONC: testNonce(1),
+		tagMIDP: make([]byte, 8),
+		tagROOT: make([]byte, 64),
+	}
+	msg := mustEncode(values)
+	decoded, err := decodeMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(values) {
+		t.Fatalf("decoded %d tags, want %d", len(decoded), len(values))
+	}
+	for tag, value := range values {
+		if !bytes.Equal(decoded[tag], value) {
+			t.Errorf("value of %s = %x, want %x", tagName(tag), decoded[tag], value)
+		}
+	}
+
+	// Requests are padded so servers do not amplify traffic
+	request, err := encodeMessage(map[uint32][]byte{tagNONC: testNonce(1)}, requestSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(request) != requestSize {
+		t.Errorf("request is %d bytes, want %d", len(request), requestSize)
+	}
+	if decoded, err := decodeMessage(request); err != nil || !bytes.Equal(decoded[tagNONC], testNonce(1)) {
+		t.Errorf("padded request decoded as %v (%v)", decoded, err)
+	}
+}
+
+func TestDecodeInvalidMessage(t *testing.T) {
+	valid := mustEncode(map[uint32][]byte{tagNONC: testNonce(1), tagMIDP: make([]byte, 8)})
+	unsorted := append([]byte(nil), valid...)
+	// Swap the two tags
+	copy(unsorted[8:12], valid[12:16])
+	copy(unsorted[12:16], valid[8:12])
+	badOffset := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(badOffset[4:], 1000)
+
+	for name, data := range map[string][]byte{
+		"empty":          nil,
+		"unaligned":      valid[:len(valid)-1],
+		"no tags":        make([]byte, 4),
+		"too many tags":  binary.LittleEndian.AppendUint32(nil, 100),
+		"truncated":      valid[:12],
+		"unsorted tags":  unsorted,
+		"offset too big": badOffset,
+	} {
+		if _, err := decodeMessage(data); err == nil {
+			t.Errorf("%s message decoded", name)
+		}
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+	s := newTestServer(t, time.Hour)
+	nonce := testNonce(1)
+	before := time.Now().Add(time.Hour)
+	midpoint, radius, err := verifyResponse(s.respond([][]byte{nonce}, 0), nonce, s.publicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now().Add(time.Hour)
+	if midpoint.Before(before.Truncate(time.Microsecond)) || midpoint.After(after) {
+		t.Errorf("midpoint = %v, want between %v and %v", midpoint, before, after)
+	}
+	if radius != s.radius {
+		t.Errorf("radius = %v, want %v", radius, s.radius)
+	}
+}
+
+func TestVerifyBatchedResponse(t *testing.T) {
+	s := newTestServer(t, 0)
+	nonces := [][]byte{testNonce(1), testNonce(2), testNonce(3), testNonce(4)}
+	for i, nonce := range nonces {
+		if _, _, err := verifyResponse(s.respond(nonces, i), nonce, s.publicKey()); err != nil {
+			t.Errorf("nonce %d: %v", i, err)
+		}
+	}
+
+	// The path of one nonce does not prove the inclusion of another
+	if _, _, err := verifyResponse(s.respond(nonces, 1), nonces[2], s.publicKey()); err == nil {
+		t.Error("verified a response for another nonce of the batch")
+	}
+}
+
+func TestVerifyResponseRejects(t *testing.T) {
+	nonce := testNonce(1)
+	s := newTestServer(t, 0)
+	other := newTestServer(t, 0)
+
+	expired := newTestServer(t, 0)
+	expired.maxTime = time.Now().Add(-time.Hour)
+
+	// Signed by an online key the root key did not delegate to
+	forged, err := decodeMessage(s.respond([][]byte{nonce}, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged[tagSIG] = ed25519.Sign(other.onlineKey, append(append([]byte{}, responseContext...), forged[tagSREP]...))
+	forgedResponse := mustEncode(forged)
+
+	// The decoded values share the bytes of the response, so this flips a bit
+	// of the signed response in place
+	tampered := s.respond([][]byte{nonce}, 0)
+	message, err := decodeMessage(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message[tagSREP][len(message[tagSREP])-1] ^= 1
+
+	for name, c := range map[string]struct {
+		response []byte
+		nonce    []byte
+		key      ed25519.PublicKey
+	}{
+		"other nonce":     {s.respond([][]byte{nonce}, 0), testNonce(2), s.publicKey()},
+		"other root key":  {s.respond([][]byte{nonce}, 0), nonce, other.publicKey()},
+		"undelegated key": {forgedResponse, nonce, s.publicKey()},
+		"expired":         {expired.respond([][]byte{nonce}, 0), nonce, expired.publicKey()},
+		"tampered":        {tampered, nonce, s.publicKey()},
+		"garbage":         {[]byte("not a message"), nonce, s.publicKey()},
+	} {
+		if _, _, err := verifyResponse(c.response, c.nonce, c.key); err == nil {
+			t.Errorf("%s: response verified", name)
+		}
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key := newTestServer(t, 0).publicKey()
+	for _, s := range []string{
+		base64.StdEncoding.EncodeToString(key),
+		hex.EncodeToString(key),
+		"0x" + hex.EncodeToString(key),
+	} {
+		parsed, err := ParsePublicKey(s)
+		if err != nil || !parsed.Equal(key) {
+			t.Errorf("ParsePublicKey(%q) = %x, %v", s, parsed, err)
+		}
+	}
+	for _, s := range []string{"", "abcd", strings.Repeat("zz", 32)} {
+		if _, err := ParsePublicKey(s); err == nil {
+			t.Errorf("ParsePublicKey(%q) succeeded", s)
+		}
+	}
+}
+
+func TestAgree(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(lo, hi int) (time.Time, time.Time) {
+		return base.Add(time.Duration(lo) * time.Second), base.Add(time.Duration(hi) * time.Second)
+	}
+	var intervals []interval
+	for _, iv := range []struct {
+		lo, hi int
+		name   string
+	}{{0, 10, "a"}, {5, 15, "b"}, {8, 20, "c"}, {100, 110, "liar"}} {
+		lo, hi := at(iv.lo, iv.hi)
+		intervals = append(intervals, interval{lo: lo, hi: hi, server: iv.name})
+	}
+
+	lo, hi, servers := agree(intervals)
+	wantLo, wantHi := at(8, 10)
+	if !lo.Equal(wantLo) || !hi.Equal(wantHi) {
+		t.Errorf("overlap = %v..%v, want %v..%v", lo, hi, wantLo, wantHi)
+	}
+	if strings.Join(servers, ",") != "a,b,c" {
+		t.Errorf("agreeing servers = %v, want [a b c]", servers)
+	}
+
+	if _, _, servers := agree(nil); len(servers) != 0 {
+		t.Errorf("servers agreed without intervals: %v", servers)
+	}
+}
+
+func TestSync(t *testing.T) {
+	// Two honest servers an hour ahead of the host, and one that is not
+	offset := time.Hour
+	config := DefaultConfig()
+	config.Servers = []Server{
+		newTestServer(t, offset).listen(t, "a"),
+		newTestServer(t, offset).listen(t, "b"),
+		newTestServer(t, 3*offset).listen(t, "liar"),
+	}
+	c, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Stamp(); !errors.Is(err, ErrNotSynchronized) {
+		t.Fatalf("Stamp before the first synchronization = %v, want %v", err, ErrNotSynchronized)
+	}
+
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	stamped, proof, err := c.Stamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := stamped.Sub(time.Now().Add(offset)); diff < -time.Second || diff > time.Second {
+		t.Errorf("stamped %v, %v from the time of the servers", stamped, diff)
+	}
+	if proof.Source != Source || strings.Join(proof.Servers, ",") != "a,b" || proof.Uncertainty <= 0 || proof.Uncertainty > config.MaxUncertainty {
+		t.Errorf("proof = %+v, want servers a and b within the maximum uncertainty", proof)
+	}
+
+	// Stamped times increase strictly
+	next, _, err := c.Stamp()
+	if err != nil || !next.After(stamped) {
+		t.Errorf("second stamp %v (%v), want after %v", next, err, stamped)
+	}
+
+	status := c.Status()
+	if !status.Trusted || len(status.Servers) != 3 || status.Servers[2].Agreed {
+		t.Errorf("status = %+v, want trusted without the liar", status)
+	}
+}
+
+func TestSyncWithoutQuorum(t *testing.T) {
+	config := DefaultConfig()
+	config.Servers = []Server{
+		newTestServer(t, 0).listen(t, "a"),
+		newTestServer(t, time.Hour).listen(t, "b"),
+	}
+	config.Timeout = time.Second
+	c, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(); err == nil {
+		t.Fatal("synchronized with servers that disagree")
+	}
+	if _, _, err := c.Stamp(); !errors.Is(err, ErrNotSynchronized) {
+		t.Errorf("Stamp = %v, want %v", err, ErrNotSynchronized)
+	}
+
+	// With the fallback, blocks are stamped with the host time without a proof
+	config.Fallback = true
+	stamped, proof, err := c.Stamp()
+	if err != nil || proof != nil {
+		t.Fatalf("Stamp with fallback = %v, %v, want the host time without a proof", proof, err)
+	}
+	if diff := time.Since(stamped); diff < 0 || diff > time.Second {
+		t.Errorf("stamped %v, want the host time", stamped)
+	}
+}
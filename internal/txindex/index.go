@@ -0,0 +1,234 @@
+package txindex
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"flashblock/internal/model"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+// Keys of the index in the database, which it shares with the account state
+var (
+	locationPrefix = []byte("txindex-l-") // + transaction ID -> JSON location
+	blockPrefix    = []byte("txindex-b-") // + big-endian block number -> block ID
+	headKey        = []byte("txindex-head")
+)
+
+// Location identifies where a transaction was included
+type Location struct {
+	BlockID     string `json:"block_id"`
+	BlockNumber uint64 `json:"block_number"`
+	Index       int    `json:"index"`              // Position of the transaction in the block
+	Failed      bool   `json:"failed,omitempty"`   // Execution reverted
+	GasUsed     uint64 `json:"gas_used,omitempty"` // Gas used by the execution
+}
+
+// head records the last indexed block, written in the batch of its transactions
+type head struct {
+	Number  uint64 `json:"number"`
+	BlockID string `json:"block_id"`
+	Count   int    `json:"count"` // Number of indexed transactions
+}
+
+// Index maps the ID of every included transaction to its location. The
+// locations of a block and the new head of the index are written in a single
+// batch as the block is committed, so a crash never leaves a block partly
+// indexed. When the server starts, the index is reconciled with the block
+// store, which is authoritative: blocks the store lost are removed and stored
+// blocks after the head are indexed.
+type Index struct {
+	db ethdb.KeyValueStore
+
+	mu   sync.RWMutex // Serializes writes and protects head
+	head head
+}
+
+// New creates an empty index in memory
+func New() *Index {
+	return &Index{db: memorydb.New()}
+}
+
+// Open opens the index kept in db
+func Open(db ethdb.KeyValueStore) (*Index, error) {
+	idx := &Index{db: db}
+	data, err := db.Get(headKey)
+	if err != nil {
+		// Nothing was indexed yet
+		return idx, nil
+	}
+	if err := json.Unmarshal(data, &idx.head); err != nil {
+		return nil, fmt.Errorf("invalid transaction index head: %v", err)
+	}
+	return idx, nil
+}
+
+// Head returns the number and ID of the last indexed block ("" if none)
+func (idx *Index) Head() (uint64, string) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return idx.head.Number, idx.head.BlockID
+}
+
+// BlockID returns the ID of the indexed block with the given number ("" if none)
+func (idx *Index) BlockID(number uint64) string {
+	id, err := idx.db.Get(blockKey(number))
+	if err != nil {
+		return ""
+	}
+	return string(id)
+}
+
+// Add records the locations of the transactions of a block
+func (idx *Index) Add(block *model.Block) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	batch := idx.db.NewBatch()
+	next := head{Number: block.Number, BlockID: block.ID, Count: idx.head.Count}
+
+	// Blocks created before the transactions were executed have no receipts
+	hasReceipts := len(block.Receipts) == len(block.Transactions)
+	for i, tx := range block.Transactions {
+		loc := Location{
+			BlockID:     block.ID,
+			BlockNumber: block.Number,
+			Index:       i,
+		}
+		if hasReceipts {
+			loc.Failed = block.Receipts[i].Status == model.ReceiptStatusFailed
+			loc.GasUsed = block.Receipts[i].GasUsed
+		}
+		if ok, _ := idx.db.Has(locationKey(tx.ID)); !ok {
+			next.Count++
+		}
+		if err := putJSON(batch, locationKey(tx.ID), loc); err != nil {
+			return err
+		}
+	}
+	if err := batch.Put(blockKey(block.Number), []byte(block.ID)); err != nil {
+		return err
+	}
+	if err := putJSON(batch, headKey, next); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to index block %d: %v", block.Number, err)
+	}
+	idx.head = next
+	return nil
+}
+
+// Prune removes the transactions of the blocks numbered below first, which
+// were pruned from the block store
+func (idx *Index) Prune(first uint64) error {
+	return idx.remove(func(number uint64) bool { return number < first }, nil)
+}
+
+// Truncate removes the transactions of the blocks after latest, which the
+// block store no longer holds, and makes latest the head. A nil latest
+// empties the index.
+func (idx *Index) Truncate(latest *model.Block) error {
+	if latest == nil {
+		return idx.remove(func(uint64) bool { return true }, &head{})
+	}
+	return idx.remove(func(number uint64) bool { return number > latest.Number }, &head{Number: latest.Number, BlockID: latest.ID})
+}
+
+// remove deletes the entries of the blocks whose number matches drop and
+// moves the head to newHead unless it is nil
+func (idx *Index) remove(drop func(number uint64) bool, newHead *head) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	next := idx.head
+	if newHead != nil {
+		next.Number, next.BlockID = newHead.Number, newHead.BlockID
+	}
+	batch := idx.db.NewBatch()
+	it := idx.db.NewIterator(locationPrefix, nil)
+	for it.Next() {
+		var loc Location
+		if err := json.Unmarshal(it.Value(), &loc); err == nil && !drop(loc.BlockNumber) {
+			continue
+		}
+		if err := batch.Delete(common.CopyBytes(it.Key())); err != nil {
+			it.Release()
+			return err
+		}
+		next.Count--
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("failed to scan transaction index: %v", err)
+	}
+
+	it = idx.db.NewIterator(blockPrefix, nil)
+	for it.Next() {
+		if key := it.Key(); len(key) != len(blockPrefix)+8 || drop(binary.BigEndian.Uint64(key[len(blockPrefix):])) {
+			if err := batch.Delete(common.CopyBytes(key)); err != nil {
+				it.Release()
+				return err
+			}
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("failed to scan transaction index: %v", err)
+	}
+
+	if err := putJSON(batch, headKey, next); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to update transaction index: %v", err)
+	}
+	idx.head = next
+	return nil
+}
+
+// Lookup returns the location of an included transaction
+func (idx *Index) Lookup(txID string) (Location, bool) {
+	data, err := idx.db.Get(locationKey(txID))
+	if err != nil {
+		return Location{}, false
+	}
+	var loc Location
+	if err := json.Unmarshal(data, &loc); err != nil {
+		return Location{}, false
+	}
+	return loc, true
+}
+
+// Len returns the number of indexed transactions
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return idx.head.Count
+}
+
+// blockKey returns the key of the ID of a block
+func blockKey(number uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte(nil), blockPrefix...), number)
+}
+
+// locationKey returns the key of the location of a transaction
+func locationKey(txID string) []byte {
+	return append(append([]byte(nil), locationPrefix...), txID...)
+}
+
+// putJSON adds the JSON encoding of v to a batch
+func putJSON(batch ethdb.Batch, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return batch.Put(key, data)
+}
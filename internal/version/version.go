@@ -0,0 +1,70 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, set at build time with
+//
+//	-ldflags "-X flashblock/internal/version.Version=... -X flashblock/internal/version.Commit=... -X flashblock/internal/version.Date=..."
+//
+// Commit falls back to the VCS revision recorded by the Go toolchain. Date is
+// left empty otherwise: the VCS time is when the commit was made, not the build.
+var (
+	Version = "1.0.0" // FlashBlock release version
+	Commit  = ""      // Git commit the binary was built from
+	Date    = ""      // Build date (RFC 3339)
+)
+
+// modified reports whether the VCS checkout had uncommitted changes at build time
+var modified bool
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if Commit == "" {
+				Commit = setting.Value
+			}
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+}
+
+// ShortCommit returns the abbreviated commit hash, or "unknown"
+func ShortCommit() string {
+	if Commit == "" {
+		return "unknown"
+	}
+
+	short := Commit
+	if len(short) > 12 {
+		short = short[:12]
+	}
+	if modified {
+		short += "-dirty"
+	}
+	return short
+}
+
+// String returns the human readable version string
+func String() string {
+	date := Date
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("flashblock %s (commit %s, built %s, %s)", Version, ShortCommit(), date, runtime.Version())
+}
+
+// ClientVersion returns the version in the web3_clientVersion format
+func ClientVersion() string {
+	return fmt.Sprintf("flashblock/v%s-%s/%s-%s/%s", Version, ShortCommit(), runtime.GOOS, runtime.GOARCH, runtime.Version())
+}
@@ -0,0 +1,191 @@
+// Package wal implements an append-only log of newline-terminated records
+// with group commit: records appended while a commit is in progress are
+// written and synced together by the next one, so many concurrent writers
+// share a single fsync.
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned for records appended after the log was closed
+var ErrClosed = errors.New("write-ahead log is closed")
+
+// Config holds configuration for the log
+type Config struct {
+	Sync        bool          // Sync every commit to disk, not only to the page cache
+	CommitDelay time.Duration // Time a commit waits for more records before it starts (0 = none)
+}
+
+// Stats counts the commits of the log
+type Stats struct {
+	Records uint64 // Records committed
+	Commits uint64 // Commits, each with one write and at most one sync
+}
+
+// Log is a write-ahead log open for appending
+type Log struct {
+	file   *os.File
+	config Config
+
+	mu        sync.Mutex
+	committed *sync.Cond // Signalled after every commit
+	buf       []byte     // Records appended since the last commit started
+	appended  uint64     // Sequence number of the last appended record
+	done      uint64     // Sequence number of the last committed record
+	err       error      // First commit error; later commits fail with it
+	closed    bool
+	stats     Stats
+
+	pending chan struct{} // Wakes the committer when records are appended
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Open opens the log at path for appending, creating it if needed
+func Open(path string, config Config) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write-ahead log: %v", err)
+	}
+	l := &Log{
+		file:    file,
+		config:  config,
+		pending: make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+	l.committed = sync.NewCond(&l.mu)
+
+	l.wg.Add(1)
+	go l.run()
+	return l, nil
+}
+
+// Append adds a record, which must not contain a newline, to the next
+// commit and returns its sequence number without waiting for the commit
+func (l *Log) Append(record []byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return 0, ErrClosed
+	}
+	l.buf = append(l.buf, record...)
+	l.buf = append(l.buf, '\n')
+	l.appended++
+
+	select {
+	case l.pending <- struct{}{}:
+	default:
+	}
+	return l.appended, nil
+}
+
+// Wait blocks until the record with the given sequence number is committed
+func (l *Log) Wait(seq uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.done < seq && l.err == nil {
+		l.committed.Wait()
+	}
+	if l.done < seq {
+		return l.err
+	}
+	return nil
+}
+
+// Write appends a record and waits until it is committed
+func (l *Log) Write(record []byte) error {
+	seq, err := l.Append(record)
+	if err != nil {
+		return err
+	}
+	return l.Wait(seq)
+}
+
+// Stats returns the number of committed records and commits
+func (l *Log) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// Close commits the appended records, syncs and closes the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	close(l.quit)
+	l.wg.Wait()
+	l.commit()
+
+	l.mu.Lock()
+	err := l.err
+	l.mu.Unlock()
+	if syncErr := l.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// run commits the appended records until the log is closed
+func (l *Log) run() {
+	defer l.wg.Done()
+	for {
+		select {
+		case <-l.pending:
+		case <-l.quit:
+			return
+		}
+		if l.config.CommitDelay > 0 {
+			select {
+			case <-time.After(l.config.CommitDelay):
+			case <-l.quit:
+				return
+			}
+		}
+		l.commit()
+	}
+}
+
+// commit writes and syncs the records appended since the last commit and
+// wakes their writers
+func (l *Log) commit() {
+	l.mu.Lock()
+	buf, seq, records := l.buf, l.appended, l.appended-l.done
+	l.buf = nil
+	failed := l.err != nil
+	l.mu.Unlock()
+	if len(buf) == 0 || failed {
+		return
+	}
+
+	_, err := l.file.Write(buf)
+	if err == nil && l.config.Sync {
+		err = l.file.Sync()
+	}
+
+	l.mu.Lock()
+	if err != nil {
+		l.err = fmt.Errorf("failed to commit write-ahead log: %v", err)
+	} else {
+		l.done = seq
+		l.stats.Records += records
+		l.stats.Commits++
+	}
+	l.mu.Unlock()
+	l.committed.Broadcast()
+}
@@ -0,0 +1,182 @@
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readLines returns the records in the file at path
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if !bytes.HasSuffix(data, []byte{'\n'}) {
+		t.Fatalf("log does not end with a complete record: %q", data)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	l, err := Open(path, Config{Sync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := l.Write([]byte(fmt.Sprintf("record-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+		// A written record is in the file before Write returns
+		if lines := readLines(t, path); len(lines) != i+1 {
+			t.Fatalf("%d records in the log after write %d", len(lines), i)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"record-0", "record-1", "record-2"}
+	if got := readLines(t, path); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+	if stats := l.Stats(); stats.Records != 3 || stats.Commits != 3 {
+		t.Errorf("stats = %+v, want 3 records in 3 commits", stats)
+	}
+}
+
+func TestGroupCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	l, err := Open(path, Config{Sync: true, CommitDelay: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- l.Write([]byte(fmt.Sprintf("record-%02d", i)))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := l.Stats()
+	if stats.Records != writers {
+		t.Errorf("%d records committed, want %d", stats.Records, writers)
+	}
+	// The writers arriving during the commit delay share a commit
+	if stats.Commits >= writers/2 {
+		t.Errorf("%d commits for %d concurrent writers, want them grouped", stats.Commits, writers)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readLines(t, path)
+	sort.Strings(lines)
+	if len(lines) != writers {
+		t.Fatalf("%d records in the log, want %d", len(lines), writers)
+	}
+	for i, line := range lines {
+		if want := fmt.Sprintf("record-%02d", i); line != want {
+			t.Fatalf("record %d = %q, want %q", i, line, want)
+		}
+	}
+}
+
+func TestCloseCommitsAppended(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	l, err := Open(path, Config{CommitDelay: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := l.Append([]byte("pending"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The commit delay has not ended, but Close commits the record
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Wait(seq); err != nil {
+		t.Fatalf("Wait after Close: %v", err)
+	}
+	if lines := readLines(t, path); len(lines) != 1 || lines[0] != "pending" {
+		t.Errorf("records = %v, want [pending]", lines)
+	}
+
+	if _, err := l.Append([]byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Append after Close = %v, want %v", err, ErrClosed)
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestReopenAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	for _, record := range []string{"first", "second"} {
+		l, err := Open(path, Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines := readLines(t, path); strings.Join(lines, ",") != "first,second" {
+		t.Errorf("records = %v, want [first second]", lines)
+	}
+}
+
+func TestCommitFailure(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "wal"), Config{Sync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Write([]byte("committed")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes fail once the file is gone; the failure is sticky, so no record
+	// is acknowledged after a lost one
+	l.file.Close()
+	if err := l.Write([]byte("lost")); err == nil {
+		t.Fatal("write to a closed file acknowledged")
+	}
+	if err := l.Write([]byte("after")); err == nil {
+		t.Fatal("write after a failed commit acknowledged")
+	}
+	if stats := l.Stats(); stats.Records != 1 {
+		t.Errorf("%d records committed, want 1", stats.Records)
+	}
+	if err := l.Close(); err == nil {
+		t.Error("Close did not report the failed commit")
+	}
+}
@@ -0,0 +1,256 @@
+// Package codec defines the canonical wire format of transactions and blocks,
+// and the flashblock payloads streamed to flashblocks consumers.
+//
+// An encoding is a version byte followed by the RLP encoding of the fields of
+// the value in the order of the wire types below. Integers are unsigned and
+// minimal, signed values (priorities, timestamps in Unix nanoseconds) are
+// encoded as their two's complement, and IDs, addresses and hashes are the
+// strings of the JSON encoding. Equal values have equal encodings, so every
+// subsystem, and other implementations, agree on the bytes of a block.
+//
+// The ID of a version 1 block is the hex SHA-256 hash of the version byte
+// followed by the RLP encoding of its header, which commits to the complete
+// transactions. Blocks created before the codec (version 0) keep the IDs
+// derived by LegacyID.
+package codec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Version is the version of the encodings and block IDs produced by this package
+const Version = 1
+
+// Errors
+var (
+	ErrEmpty              = errors.New("empty encoding")
+	ErrUnsupportedVersion = errors.New("unsupported encoding version")
+)
+
+// Transaction is the wire type of a transaction
+type Transaction struct {
+	ID        string
+	Data      []byte
+	Priority  uint64 // Two's complement of the signed priority
+	Timestamp uint64 // Unix nanoseconds
+	From      string
+	To        string
+	Value     *big.Int
+	GasPrice  *big.Int
+	GasLimit  uint64
+	Nonce     uint64
+	RawData   string
+
+	// Added after version 1 was released; transactions without blobs keep
+	// their encoding
+	BlobHashes []string `rlp:"optional"`
+}
+
+// Header holds the fields of a block its ID commits to
+type Header struct {
+	Number        uint64
+	Timestamp     uint64 // Unix nanoseconds
+	PrevBlockID   string
+	StateRoot     string
+	GasLimit      uint64
+	GasUsed       uint64
+	FeeRecipient  string
+	Transactions  []*Transaction
+	EncryptionKey string
+	Commitments   []string
+
+	// Added after version 1 was released; blocks without a trusted timestamp
+	// keep their encoding and IDs
+	TimeProof *TimeProof `rlp:"optional"`
+}
+
+// TimeProof is the wire type of the proof of a block timestamp
+type TimeProof struct {
+	Source      string
+	Servers     []string
+	Synced      uint64 // Unix nanoseconds
+	Uncertainty uint64 // Nanoseconds
+}
+
+// Block is the wire type of a block
+type Block struct {
+	Version  uint8 // Version of the block ID (0 for legacy IDs)
+	ID       string
+	Header   *Header
+	Receipts []*Receipt
+	TDXQuote []byte
+	DA       *DAReference `rlp:"nil"`
+}
+
+// Receipt is the wire type of a receipt
+type Receipt struct {
+	Status            uint64
+	GasUsed           uint64
+	CumulativeGasUsed uint64
+	ContractAddress   string
+	Logs              []*Log
+	Error             string
+}
+
+// Log is the wire type of a log
+type Log struct {
+	Address string
+	Topics  []string
+	Data    []byte
+}
+
+// DAReference is the wire type of a DA reference
+type DAReference struct {
+	Layer      string
+	Height     uint64
+	Commitment string
+	Namespace  string
+	TxHash     string
+	BlobHashes []string
+	FirstBlock uint64
+	LastBlock  uint64
+}
+
+// EncodeTransaction returns the canonical encoding of a transaction
+func EncodeTransaction(tx *Transaction) ([]byte, error) {
+	return encode(tx)
+}
+
+// DecodeTransaction decodes a transaction from its canonical encoding
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	var tx Transaction
+	if err := decode(data, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// EncodeBlock returns the canonical encoding of a block
+func EncodeBlock(block *Block) ([]byte, error) {
+	if block.Header == nil {
+		return nil, errors.New("block has no header")
+	}
+	return encode(block)
+}
+
+// DecodeBlock decodes a block from its canonical encoding. The ID is not
+// verified; see BlockID.
+func DecodeBlock(data []byte) (*Block, error) {
+	var block Block
+	if err := decode(data, &block); err != nil {
+		return nil, err
+	}
+	if block.Header == nil {
+		return nil, errors.New("block has no header")
+	}
+	return &block, nil
+}
+
+// BlockID returns the ID of a block derived from its header according to the
+// version of the block. Receipts, attestation quotes and DA references are
+// not part of the ID.
+func BlockID(block *Block) (string, error) {
+	if block.Header == nil {
+		return "", errors.New("block has no header")
+	}
+	switch block.Version {
+	case 0:
+		return LegacyID(block.Header), nil
+	case Version:
+		data, err := encode(block.Header)
+		if err != nil {
+			return "", err
+		}
+		hash := sha256.Sum256(data)
+		return hex.EncodeToString(hash[:]), nil
+	default:
+		return "", fmt.Errorf("%w: block version %d", ErrUnsupportedVersion, block.Version)
+	}
+}
+
+// VerifyBlockID checks that the ID of a block matches its contents
+func VerifyBlockID(block *Block) error {
+	id, err := BlockID(block)
+	if err != nil {
+		return err
+	}
+	if id != block.ID {
+		return fmt.Errorf("block %s has mismatching ID %s", block.ID, id)
+	}
+	return nil
+}
+
+// LegacyID returns the ID of a version 0 block: the hex SHA-256 hash of the
+// concatenated transaction IDs, the decimal number and timestamp and the
+// previous block ID, followed by the fields that were added over time when
+// they are set. Receipts are not part of the ID; they are verified by
+// re-execution, and neither are DA references, which are only known after
+// publication.
+func LegacyID(h *Header) string {
+	var data []byte
+	for _, tx := range h.Transactions {
+		data = append(data, tx.ID...)
+	}
+	data = strconv.AppendUint(data, h.Number, 10)
+	data = strconv.AppendInt(data, int64(h.Timestamp), 10)
+	data = append(data, h.PrevBlockID...)
+
+	// Blocks created before the state was tracked have no state root, and
+	// blocks created before the transactions were executed no gas limit
+	if h.StateRoot != "" {
+		data = append(data, h.StateRoot...)
+	}
+	if h.GasLimit > 0 {
+		data = strconv.AppendUint(data, h.GasLimit, 10)
+		data = strconv.AppendUint(data, h.GasUsed, 10)
+		data = append(data, h.FeeRecipient...)
+	}
+	// Blocks without encrypted transactions do not commit to an encryption key
+	if len(h.Commitments) > 0 {
+		data = append(data, h.EncryptionKey...)
+		for _, commitment := range h.Commitments {
+			data = append(data, commitment...)
+		}
+	}
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Nanos returns a time as the Unix nanoseconds of the wire types
+func Nanos(t time.Time) uint64 {
+	return uint64(t.UnixNano())
+}
+
+// Time converts the Unix nanoseconds of the wire types to a UTC time
+func Time(nanos uint64) time.Time {
+	return time.Unix(0, int64(nanos)).UTC()
+}
+
+// encode prefixes the RLP encoding of a wire value with the version
+func encode(wire any) ([]byte, error) {
+	data, err := rlp.EncodeToBytes(wire)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{Version}, data...), nil
+}
+
+// decode checks the version of an encoding and decodes the wire value
+func decode(data []byte, wire any) error {
+	if len(data) == 0 {
+		return ErrEmpty
+	}
+	if data[0] != Version {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, data[0])
+	}
+	return rlp.DecodeBytes(data[1:], wire)
+}
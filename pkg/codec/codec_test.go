@@ -0,0 +1,303 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var testTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testPriority is negative, so its two's complement is encoded
+var testPriority int64 = -2
+
+func testTransaction() *Transaction {
+	return &Transaction{
+		ID:        "0x3f1c",
+		Data:      []byte{0xde, 0xad, 0xbe, 0xef},
+		Priority:  uint64(testPriority),
+		Timestamp: Nanos(testTime),
+		From:      "0xfe3b557e8fb62b89f4916b721be55ceb828dbd73",
+		To:        "0x627306090abab3a6e1400e9345bc60c78a8bef57",
+		Value:     big.NewInt(1_000_000_000_000_000_000),
+		GasPrice:  big.NewInt(2_000_000_000),
+		GasLimit:  21000,
+		Nonce:     7,
+		RawData:   "0xf86c07",
+	}
+}
+
+func testBlock() *Block {
+	return &Block{
+		Version: Version,
+		Header: &Header{
+			Number:        42,
+			Timestamp:     Nanos(testTime.Add(time.Second)),
+			PrevBlockID:   "9c56cc51b374c3ba189210d5b6d4bf57790d351c96c47c02190ecf1e430635ab",
+			StateRoot:     "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+			GasLimit:      30_000_000,
+			GasUsed:       21000,
+			FeeRecipient:  "0x0000000000000000000000000000000000000001",
+			Transactions:  []*Transaction{testTransaction()},
+			EncryptionKey: "0x01",
+			Commitments:   []string{"0x02", "0x03"},
+		},
+		Receipts: []*Receipt{{
+			Status:            1,
+			GasUsed:           21000,
+			CumulativeGasUsed: 21000,
+			Logs:              []*Log{{Address: "0x0000000000000000000000000000000000000002", Topics: []string{"0x04"}, Data: []byte{1}}},
+		}},
+		TDXQuote: []byte{0x05, 0x06},
+		DA:       &DAReference{Layer: "ethereum", Height: 100, Commitment: "0x07", TxHash: "0x08", BlobHashes: []string{"0x07"}, FirstBlock: 40, LastBlock: 42},
+	}
+}
+
+// withID sets the ID of a block derived from its header
+func withID(t *testing.T, block *Block) *Block {
+	t.Helper()
+	id, err := BlockID(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.ID = id
+	return block
+}
+
+func TestTransactionRoundTrip(t *testing.T) {
+	for _, tx := range []*Transaction{
+		testTransaction(),
+		{ID: "empty", Value: new(big.Int), GasPrice: new(big.Int)},
+		func() *Transaction {
+			tx := testTransaction()
+			tx.BlobHashes = []string{"0x01aa", "0x01bb"}
+			return tx
+		}(),
+	} {
+		data, err := EncodeTransaction(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data[0] != Version {
+			t.Errorf("encoding starts with %d, want version %d", data[0], Version)
+		}
+		decoded, err := DecodeTransaction(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded.Data) == 0 && len(tx.Data) == 0 {
+			decoded.Data = tx.Data
+		}
+		if !reflect.DeepEqual(decoded, tx) {
+			t.Errorf("decoded %+v, want %+v", decoded, tx)
+		}
+
+		// Equal values have equal encodings
+		again, err := EncodeTransaction(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again, data) {
+			t.Errorf("re-encoding differs: %x, %x", again, data)
+		}
+	}
+}
+
+func TestNegativePriority(t *testing.T) {
+	data, err := EncodeTransaction(testTransaction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeTransaction(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(decoded.Priority) != -2 {
+		t.Errorf("priority = %d, want -2", int64(decoded.Priority))
+	}
+	if got := Time(decoded.Timestamp); !got.Equal(testTime) {
+		t.Errorf("timestamp = %v, want %v", got, testTime)
+	}
+}
+
+func TestBlockRoundTrip(t *testing.T) {
+	block := withID(t, testBlock())
+	data, err := EncodeBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeBlock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, block) {
+		t.Errorf("decoded %+v, want %+v", decoded, block)
+	}
+	if err := VerifyBlockID(decoded); err != nil {
+		t.Error(err)
+	}
+
+	// Blocks without a DA reference decode with none
+	block.DA = nil
+	data, err = EncodeBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = DecodeBlock(data); err != nil || decoded.DA != nil {
+		t.Errorf("decoded DA reference %+v (%v), want none", decoded.DA, err)
+	}
+}
+
+func TestBlockIDStability(t *testing.T) {
+	// The IDs of stored and attested blocks depend on these values; they may
+	// only change with a new version
+	block := testBlock()
+	id, err := BlockID(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "3c358df4978b186a451e876d9dab8fb257921e238f83bb3ae3594adbe80fdc34"; id != want {
+		t.Errorf("version 1 ID = %s, want %s", id, want)
+	}
+
+	block.Version = 0
+	if id, err = BlockID(block); err != nil {
+		t.Fatal(err)
+	}
+	if want := "3dc321a8b46e81505bdc8b2662b516b9c12699d83c7586e5cc0f3d47d53af6ae"; id != want {
+		t.Errorf("legacy ID = %s, want %s", id, want)
+	}
+}
+
+func TestBlockIDIgnoresUnhashedFields(t *testing.T) {
+	block := withID(t, testBlock())
+
+	// Receipts, quotes and DA references are added after the ID is derived
+	block.Receipts = nil
+	block.TDXQuote = nil
+	block.DA = nil
+	if err := VerifyBlockID(block); err != nil {
+		t.Errorf("ID changed with fields outside the header: %v", err)
+	}
+}
+
+func TestBlockIDCommitsToHeader(t *testing.T) {
+	for name, change := range map[string]func(h *Header){
+		"number":       func(h *Header) { h.Number++ },
+		"timestamp":    func(h *Header) { h.Timestamp++ },
+		"parent":       func(h *Header) { h.PrevBlockID = "00" },
+		"state root":   func(h *Header) { h.StateRoot = "0x00" },
+		"gas used":     func(h *Header) { h.GasUsed++ },
+		"transaction":  func(h *Header) { h.Transactions[0].Nonce++ },
+		"transactions": func(h *Header) { h.Transactions = nil },
+		"commitments":  func(h *Header) { h.Commitments = h.Commitments[:1] },
+		"time proof": func(h *Header) {
+			h.TimeProof = &TimeProof{Source: "roughtime", Servers: []string{"a"}, Synced: 1, Uncertainty: 2}
+		},
+	} {
+		block := withID(t, testBlock())
+		change(block.Header)
+		if err := VerifyBlockID(block); err == nil {
+			t.Errorf("ID does not commit to the %s", name)
+		}
+	}
+}
+
+func TestOptionalFieldsKeepEncoding(t *testing.T) {
+	// Fields added after version 1 are omitted when unset, so the encodings,
+	// and the IDs, of earlier values do not change
+	type headerV1 struct {
+		Number        uint64
+		Timestamp     uint64
+		PrevBlockID   string
+		StateRoot     string
+		GasLimit      uint64
+		GasUsed       uint64
+		FeeRecipient  string
+		Transactions  []*Transaction
+		EncryptionKey string
+		Commitments   []string
+	}
+	h := testBlock().Header
+	current, err := encode(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlier, err := encode(&headerV1{h.Number, h.Timestamp, h.PrevBlockID, h.StateRoot, h.GasLimit, h.GasUsed, h.FeeRecipient, h.Transactions, h.EncryptionKey, h.Commitments})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(current, earlier) {
+		t.Error("header without a time proof is encoded differently")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := DecodeBlock(nil); !errors.Is(err, ErrEmpty) {
+		t.Errorf("empty encoding: %v, want %v", err, ErrEmpty)
+	}
+	data, err := EncodeTransaction(testTransaction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[0] = Version + 1
+	if _, err := DecodeTransaction(data); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("unknown version: %v, want %v", err, ErrUnsupportedVersion)
+	}
+	data[0] = Version
+	if _, err := DecodeTransaction(data[:len(data)-1]); err == nil {
+		t.Error("decoded a truncated encoding")
+	}
+	if _, err := BlockID(&Block{Version: Version + 1, Header: &Header{}}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("ID of unknown version: %v, want %v", err, ErrUnsupportedVersion)
+	}
+}
+
+func TestFlashblockRoundTrip(t *testing.T) {
+	hash := common.HexToHash("0x01")
+	payload := &Flashblock{
+		PayloadID: hexutil.Bytes{1, 2, 3, 4, 5, 6, 7, 8},
+		Index:     0,
+		Base: &FlashblockBase{
+			ParentHash:    hash,
+			BlockNumber:   42,
+			GasLimit:      30_000_000,
+			Timestamp:     1704067200,
+			ExtraData:     hexutil.Bytes{},
+			BaseFeePerGas: (*hexutil.Big)(big.NewInt(7)),
+		},
+		Diff: FlashblockDiff{
+			StateRoot:    hash,
+			GasUsed:      21000,
+			Transactions: []hexutil.Bytes{{0xf8, 0x6c}},
+			Withdrawals:  []any{},
+		},
+		Metadata: FlashblockMetadata{
+			BlockNumber:        42,
+			NewAccountBalances: map[common.Address]*hexutil.Big{common.HexToAddress("0x02"): (*hexutil.Big)(big.NewInt(3))},
+			Receipts: map[common.Hash]map[string]*FlashblockReceipt{
+				hash: {"Legacy": {Status: 1, CumulativeGasUsed: 21000, Logs: []FlashblockLog{}}},
+			},
+		},
+	}
+	data, err := EncodeFlashblock(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeFlashblock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, payload) {
+		t.Errorf("decoded %+v, want %+v", decoded, payload)
+	}
+	if _, err := DecodeFlashblock(nil); !errors.Is(err, ErrEmpty) {
+		t.Errorf("empty payload: %v, want %v", err, ErrEmpty)
+	}
+}
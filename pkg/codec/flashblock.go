@@ -0,0 +1,85 @@
+package codec
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Flashblock is a flashblock payload in the format streamed by rollup-boost.
+// A payload groups consecutive blocks; the first one (index 0) carries the
+// base of the payload, and every block carries the diff accumulated so far.
+// Unlike blocks and transactions, flashblocks are encoded as JSON, which is
+// what flashblocks consumers expect.
+type Flashblock struct {
+	PayloadID hexutil.Bytes      `json:"payload_id"`
+	Index     uint64             `json:"index"`
+	Base      *FlashblockBase    `json:"base,omitempty"`
+	Diff      FlashblockDiff     `json:"diff"`
+	Metadata  FlashblockMetadata `json:"metadata"`
+}
+
+// FlashblockBase holds the fields of a payload that are known when it starts
+type FlashblockBase struct {
+	ParentBeaconBlockRoot common.Hash    `json:"parent_beacon_block_root"`
+	ParentHash            common.Hash    `json:"parent_hash"`
+	FeeRecipient          common.Address `json:"fee_recipient"`
+	PrevRandao            common.Hash    `json:"prev_randao"`
+	BlockNumber           hexutil.Uint64 `json:"block_number"`
+	GasLimit              hexutil.Uint64 `json:"gas_limit"`
+	Timestamp             hexutil.Uint64 `json:"timestamp"`
+	ExtraData             hexutil.Bytes  `json:"extra_data"`
+	BaseFeePerGas         *hexutil.Big   `json:"base_fee_per_gas"`
+}
+
+// FlashblockDiff holds the transactions of a block and the payload fields after it
+type FlashblockDiff struct {
+	StateRoot       common.Hash     `json:"state_root"`
+	ReceiptsRoot    common.Hash     `json:"receipts_root"`
+	LogsBloom       types.Bloom     `json:"logs_bloom"`
+	GasUsed         hexutil.Uint64  `json:"gas_used"` // Used by the payload so far
+	BlockHash       common.Hash     `json:"block_hash"`
+	Transactions    []hexutil.Bytes `json:"transactions"`
+	Withdrawals     []any           `json:"withdrawals"`
+	WithdrawalsRoot common.Hash     `json:"withdrawals_root"`
+}
+
+// FlashblockMetadata holds the execution results of a block
+type FlashblockMetadata struct {
+	BlockNumber        uint64                                        `json:"block_number"`
+	NewAccountBalances map[common.Address]*hexutil.Big               `json:"new_account_balances"`
+	Receipts           map[common.Hash]map[string]*FlashblockReceipt `json:"receipts"` // By transaction hash, keyed by receipt type
+}
+
+// FlashblockReceipt is the receipt of a transaction in the metadata
+type FlashblockReceipt struct {
+	Status            hexutil.Uint64  `json:"status"`
+	CumulativeGasUsed hexutil.Uint64  `json:"cumulativeGasUsed"` // Within the payload
+	Logs              []FlashblockLog `json:"logs"`
+}
+
+// FlashblockLog is an event emitted by a transaction
+type FlashblockLog struct {
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+}
+
+// EncodeFlashblock returns the JSON text of a flashblock payload
+func EncodeFlashblock(payload *Flashblock) ([]byte, error) {
+	return json.Marshal(payload)
+}
+
+// DecodeFlashblock decodes a flashblock payload from its JSON text
+func DecodeFlashblock(data []byte) (*Flashblock, error) {
+	if len(data) == 0 {
+		return nil, ErrEmpty
+	}
+	var payload Flashblock
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
@@ -0,0 +1,66 @@
+package extension
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Built-in extensions
+func init() {
+	RegisterOrderer("priority", func(map[string]string) (Orderer, error) {
+		return OrdererFunc(byPriority), nil
+	})
+	RegisterOrderer("fifo", func(map[string]string) (Orderer, error) {
+		return OrdererFunc(byArrival), nil
+	})
+	RegisterValidator("max-data-size", newMaxDataSize)
+	RegisterValidator("signed-only", func(map[string]string) (Validator, error) {
+		return ValidatorFunc(signedOnly), nil
+	})
+}
+
+// byPriority orders transactions by priority, highest first, which is the
+// order of the block processor without an orderer
+func byPriority(txs []*Transaction) []*Transaction {
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].Priority > txs[j].Priority
+	})
+	return txs
+}
+
+// byArrival orders transactions by the time they were received, oldest first
+func byArrival(txs []*Transaction) []*Transaction {
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].Timestamp.Before(txs[j].Timestamp)
+	})
+	return txs
+}
+
+// newMaxDataSize creates a validator rejecting transactions whose data is
+// larger than the max_bytes option
+func newMaxDataSize(options map[string]string) (Validator, error) {
+	limit, err := strconv.Atoi(options["max_bytes"])
+	if err != nil || limit <= 0 {
+		return nil, fmt.Errorf("max_bytes must be a positive integer, got %q", options["max_bytes"])
+	}
+	return ValidatorFunc(func(tx *Transaction) error {
+		if len(tx.Data) > limit {
+			return fmt.Errorf("data of %d bytes exceeds the limit of %d", len(tx.Data), limit)
+		}
+		return nil
+	}), nil
+}
+
+// errUnsigned rejects transactions without a sender
+var errUnsigned = errors.New("transaction is not signed")
+
+// signedOnly rejects transactions without a sender, such as the raw payloads
+// of flash_submitTransaction
+func signedOnly(tx *Transaction) error {
+	if tx.From == "" {
+		return errUnsigned
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package extension lets deployments add admission validators and ordering
+// strategies without changing the mempool or the block processor.
+//
+// An extension registers a named factory from an init function, in a package
+// compiled into the server or in a Go plugin loaded at startup:
+//
+//	func init() {
+//		extension.RegisterValidator("min-gas", func(options map[string]string) (extension.Validator, error) {
+//			return &minGas{}, nil
+//		})
+//	}
+//
+// The configuration then selects registered extensions by name and passes
+// them their options. Validators run after the built-in admission rules, in
+// the configured order; the orderer replaces the priority order of the
+// transactions the block processor tries to include.
+package extension
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Validator decides whether a new transaction is admitted to the mempool. A
+// non-nil error rejects the transaction and is returned to the submitter.
+// Validate is called with the mempool locked, so it must be fast and must
+// not call back into the node.
+type Validator interface {
+	Validate(tx *Transaction) error
+}
+
+// Orderer orders the candidate transactions of the next block. The
+// transactions are tried in the returned order until the block is full;
+// transactions left out of the result stay pending. Order may reorder the
+// given slice in place. Transactions it was not given and repeated
+// transactions are dropped from the result.
+type Orderer interface {
+	Order(txs []*Transaction) []*Transaction
+}
+
+// ValidatorFunc adapts a function to the Validator interface
+type ValidatorFunc func(tx *Transaction) error
+
+// Validate calls f(tx)
+func (f ValidatorFunc) Validate(tx *Transaction) error {
+	return f(tx)
+}
+
+// OrdererFunc adapts a function to the Orderer interface
+type OrdererFunc func(txs []*Transaction) []*Transaction
+
+// Order calls f(txs)
+func (f OrdererFunc) Order(txs []*Transaction) []*Transaction {
+	return f(txs)
+}
+
+// ValidatorFactory creates a validator from its configured options
+type ValidatorFactory func(options map[string]string) (Validator, error)
+
+// OrdererFactory creates an orderer from its configured options
+type OrdererFactory func(options map[string]string) (Orderer, error)
+
+var (
+	mu         sync.RWMutex
+	validators = make(map[string]ValidatorFactory)
+	orderers   = make(map[string]OrdererFactory)
+)
+
+// RegisterValidator makes a validator available by name. It panics if the
+// name is empty or already registered, like database/sql.Register.
+func RegisterValidator(name string, factory ValidatorFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if name == "" || factory == nil {
+		panic("extension: validator needs a name and a factory")
+	}
+	if _, ok := validators[name]; ok {
+		panic("extension: validator " + name + " registered twice")
+	}
+	validators[name] = factory
+}
+
+// RegisterOrderer makes an orderer available by name. It panics if the name
+// is empty or already registered.
+func RegisterOrderer(name string, factory OrdererFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if name == "" || factory == nil {
+		panic("extension: orderer needs a name and a factory")
+	}
+	if _, ok := orderers[name]; ok {
+		panic("extension: orderer " + name + " registered twice")
+	}
+	orderers[name] = factory
+}
+
+// NewValidator creates the registered validator of the given name. Errors of
+// the validator are prefixed with its name.
+func NewValidator(name string, options map[string]string) (Validator, error) {
+	mu.RLock()
+	factory, ok := validators[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown validator %q (registered: %v)", name, Validators())
+	}
+	v, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("validator %s: %v", name, err)
+	}
+	return &namedValidator{name: name, validator: v}, nil
+}
+
+// NewOrderer creates the registered orderer of the given name
+func NewOrderer(name string, options map[string]string) (Orderer, error) {
+	mu.RLock()
+	factory, ok := orderers[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown orderer %q (registered: %v)", name, Orderers())
+	}
+	o, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("orderer %s: %v", name, err)
+	}
+	return o, nil
+}
+
+// Validators returns the names of the registered validators
+func Validators() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sortedNames(validators)
+}
+
+// Orderers returns the names of the registered orderers
+func Orderers() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sortedNames(orderers)
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namedValidator prefixes the errors of a validator with its name
+type namedValidator struct {
+	name      string
+	validator Validator
+}
+
+func (v *namedValidator) Validate(tx *Transaction) error {
+	if err := v.validator.Validate(tx); err != nil {
+		return fmt.Errorf("rejected by %s: %w", v.name, err)
+	}
+	return nil
+}
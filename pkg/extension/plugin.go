@@ -0,0 +1,15 @@
+package extension
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// LoadPlugin opens a Go plugin, built with -buildmode=plugin against the same
+// version of this module, whose init functions register its extensions
+func LoadPlugin(path string) error {
+	if _, err := plugin.Open(path); err != nil {
+		return fmt.Errorf("failed to load plugin %s: %v", path, err)
+	}
+	return nil
+}
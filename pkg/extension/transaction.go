@@ -0,0 +1,24 @@
+package extension
+
+import (
+	"math/big"
+	"time"
+)
+
+// Transaction is the view of a transaction given to extensions. Its fields
+// are copies, except for Data, which is shared and must not be modified.
+// Transactions without a sender are raw payloads, such as those of
+// flash_submitTransaction, and have no Ethereum fields.
+type Transaction struct {
+	ID        string    // Transaction hash of signed transactions, hex without 0x
+	Data      []byte    // Payload or call data
+	Priority  int       // Priority fee used by the default order
+	Timestamp time.Time // Time the transaction was received
+
+	From     string   // Sender address (empty if unsigned)
+	To       string   // Recipient address (empty for contract creations)
+	Value    *big.Int // Value in wei
+	GasPrice *big.Int // Gas price in wei
+	GasLimit uint64
+	Nonce    uint64
+}